@@ -0,0 +1,158 @@
+// Package addresses encodes, decodes and validates gochain addresses
+// without any key material, so services can check addresses entered by
+// users without depending on the wallet package.
+//
+// An address is the base58 encoding of a version byte, the 20 byte hash of
+// a public key, and the first ChecksumLength bytes of the double sha256 of
+// the two. The version byte identifies the network the address is for.
+package addresses
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+const (
+	// MainNet is the version byte of addresses on the main network.
+	MainNet = byte(0x00)
+
+	// TestNet is the version byte of addresses on test networks.
+	TestNet = byte(0x6f)
+
+	// PubKeyHashLength is the length in bytes of the public key hash in an
+	// address.
+	PubKeyHashLength = 20
+)
+
+// ChecksumLength is the number of checksum bytes at the end of addresses
+// and private keys in wallet import format.
+var ChecksumLength = 4
+
+// networks names the networks of the known version bytes.
+var networks = map[byte]string{
+	MainNet: "main",
+	TestNet: "test",
+}
+
+// Address is a decoded address.
+type Address struct {
+	Version    byte
+	PubKeyHash []byte
+}
+
+// String encodes the address.
+func (a Address) String() string {
+	return Encode(a.Version, a.PubKeyHash)
+}
+
+// Equal returns whether a and b are the same address.
+func (a Address) Equal(b Address) bool {
+	return a.Version == b.Version && bytes.Equal(a.PubKeyHash, b.PubKeyHash)
+}
+
+// MarshalText encodes the address.
+func (a Address) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText decodes an address, validating its length and checksum.
+func (a *Address) UnmarshalText(text []byte) error {
+	decoded, err := Decode(string(text))
+	if err != nil {
+		return err
+	}
+	*a = decoded
+	return nil
+}
+
+// Network returns the name of the network of the address version, and
+// whether the version is known.
+func (a Address) Network() (string, bool) {
+	name, ok := networks[a.Version]
+	return name, ok
+}
+
+// Checksum returns the first ChecksumLength bytes of the double sha256 of
+// payload.
+func Checksum(payload []byte) []byte {
+	hash := sha256.Sum256(payload)
+	rehash := sha256.Sum256(hash[:])
+	return rehash[:ChecksumLength]
+}
+
+// Encode returns the address of a public key hash with a version byte.
+func Encode(version byte, pubKeyHash []byte) string {
+	vHash := append([]byte{version}, pubKeyHash...)
+	return base58.Encode(append(vHash, Checksum(vHash)...))
+}
+
+// Decode decodes an address, validating its length and checksum.
+func Decode(address string) (Address, error) {
+	decoded := base58.Decode(address)
+	if len(decoded) != 1+PubKeyHashLength+ChecksumLength {
+		return Address{}, errors.New("invalid address length")
+	}
+
+	// validate checksum
+	vHash := decoded[:len(decoded)-ChecksumLength]
+	if !bytes.Equal(decoded[len(decoded)-ChecksumLength:], Checksum(vHash)) {
+		return Address{}, errors.New("invalid address checksum")
+	}
+
+	return Address{Version: vHash[0], PubKeyHash: vHash[1:]}, nil
+}
+
+// Validate returns whether address is a well formed address of any
+// version.
+func Validate(address string) bool {
+	_, err := Decode(address)
+	return err == nil
+}
+
+// Version returns the version byte of an address.
+func Version(address string) (byte, error) {
+	a, err := Decode(address)
+	if err != nil {
+		return 0, err
+	}
+	return a.Version, nil
+}
+
+// VersionForPrefix returns the lowest version byte whose addresses all
+// start with the base58 character prefix, so a network can choose the
+// first character of its addresses.
+func VersionForPrefix(prefix string) (byte, error) {
+	if len(prefix) != 1 || len(base58.Decode(prefix)) == 0 {
+		return 0, fmt.Errorf("address prefix %q is not a base58 character", prefix)
+	}
+
+	// addresses are ordered by value, so the addresses of a version all
+	// start with prefix if its lowest and highest do
+	low := make([]byte, 1+PubKeyHashLength+ChecksumLength)
+	high := bytes.Repeat([]byte{0xff}, len(low))
+	for version := 0; version < 256; version++ {
+		low[0], high[0] = byte(version), byte(version)
+		if base58.Encode(low)[:1] == prefix && base58.Encode(high)[:1] == prefix {
+			return byte(version), nil
+		}
+	}
+	return 0, fmt.Errorf("no version byte gives addresses starting with %s", prefix)
+}
+
+// Network returns the name of the network an address is for, such as main
+// or test.
+func Network(address string) (string, error) {
+	a, err := Decode(address)
+	if err != nil {
+		return "", err
+	}
+	name, ok := a.Network()
+	if !ok {
+		return "", fmt.Errorf("unknown address version 0x%02x", a.Version)
+	}
+	return name, nil
+}
@@ -0,0 +1,154 @@
+package addresses
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+func TestDecode(t *testing.T) {
+	pubKeyHash := bytes.Repeat([]byte{0xab}, PubKeyHashLength)
+	main := Encode(MainNet, pubKeyHash)
+	test := Encode(TestNet, pubKeyHash)
+
+	// corrupt the checksum by changing the last character
+	last := main[len(main)-1:]
+	replacement := "2"
+	if last == replacement {
+		replacement = "3"
+	}
+	badChecksum := main[:len(main)-1] + replacement
+
+	for _, c := range []struct {
+		name    string
+		address string
+		version byte
+		network string
+		err     string
+	}{
+		{"main network", main, MainNet, "main", ""},
+		{"test network", test, TestNet, "test", ""},
+		{"unknown version", Encode(0x42, pubKeyHash), 0x42, "", "unknown address version 0x42"},
+		{"bad checksum", badChecksum, 0, "", "checksum"},
+		{"short hash", Encode(MainNet, pubKeyHash[1:]), 0, "", "length"},
+		{"not base58", "0OIl", 0, "", "length"},
+		{"empty", "", 0, "", "length"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			version, err := Version(c.address)
+			network, networkErr := Network(c.address)
+			if c.err == "" {
+				if err != nil || networkErr != nil {
+					t.Fatalf("got errors %v, %v", err, networkErr)
+				}
+				if !Validate(c.address) || version != c.version || network != c.network {
+					t.Fatalf("got version 0x%02x on %s, want 0x%02x on %s", version, network, c.version, c.network)
+				}
+				a, _ := Decode(c.address)
+				if !bytes.Equal(a.PubKeyHash, pubKeyHash) || a.String() != c.address {
+					t.Fatalf("got %x, which encodes to %s", a.PubKeyHash, a)
+				}
+				return
+			}
+
+			// unknown versions are valid addresses of no known network
+			if c.version != 0 {
+				if err != nil || version != c.version || !Validate(c.address) {
+					t.Fatalf("got version 0x%02x, %v", version, err)
+				}
+				err = networkErr
+			} else if Validate(c.address) {
+				t.Fatal("invalid address validated")
+			}
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("got error %v, want %q", err, c.err)
+			}
+		})
+	}
+}
+
+func TestChecksumLength(t *testing.T) {
+	defer func(length int) { ChecksumLength = length }(ChecksumLength)
+
+	pubKeyHash := bytes.Repeat([]byte{0x01}, PubKeyHashLength)
+	address := Encode(MainNet, pubKeyHash)
+	if got := len(base58.Decode(address)); got != 1+PubKeyHashLength+4 {
+		t.Fatalf("got %d bytes, want a 4 byte checksum", got)
+	}
+
+	// addresses with a different checksum length don't validate
+	ChecksumLength = 2
+	if Validate(address) {
+		t.Fatal("address with a 4 byte checksum validated with a 2 byte checksum")
+	}
+	if !Validate(Encode(MainNet, pubKeyHash)) {
+		t.Fatal("address with a 2 byte checksum did not validate")
+	}
+}
+
+func TestAddressText(t *testing.T) {
+	a := Address{Version: TestNet, PubKeyHash: bytes.Repeat([]byte{0xcd}, PubKeyHashLength)}
+	text, err := a.MarshalText()
+	if err != nil || string(text) != a.String() {
+		t.Fatalf("got %s, %v, want %s", text, err, a)
+	}
+
+	var decoded Address
+	if err := decoded.UnmarshalText(text); err != nil || !decoded.Equal(a) {
+		t.Fatalf("got %v, %v, want %v", decoded, err, a)
+	}
+	if decoded.Equal(Address{Version: MainNet, PubKeyHash: a.PubKeyHash}) {
+		t.Fatal("addresses of different versions are equal")
+	}
+	if err := decoded.UnmarshalText([]byte("0OIl")); err == nil {
+		t.Fatal("decoded an invalid address, want error")
+	}
+}
+
+func TestVersionForPrefix(t *testing.T) {
+	for _, prefix := range []string{"1", "g", "Z", "z"} {
+		version, err := VersionForPrefix(prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, fill := range []byte{0x00, 0x5a, 0xff} {
+			if address := Encode(version, bytes.Repeat([]byte{fill}, PubKeyHashLength)); !strings.HasPrefix(address, prefix) {
+				t.Errorf("address %s of version %d does not start with %s", address, version, prefix)
+			}
+		}
+	}
+	if version, _ := VersionForPrefix("1"); version != MainNet {
+		t.Errorf("got version %d for prefix 1, want the main network", version)
+	}
+	for _, bad := range []string{"", "0", "l", "gg"} {
+		if _, err := VersionForPrefix(bad); err == nil {
+			t.Errorf("found a version for prefix %q", bad)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(Encode(MainNet, bytes.Repeat([]byte{0xab}, PubKeyHashLength)))
+	f.Add(Encode(TestNet, make([]byte, PubKeyHashLength)))
+	f.Add("1")
+	f.Add("0OIl")
+	f.Add("")
+
+	// any string decodes to an address or an error, and decoded addresses
+	// encode to the string decoded
+	f.Fuzz(func(t *testing.T, address string) {
+		a, err := Decode(address)
+		if err != nil {
+			return
+		}
+		if len(a.PubKeyHash) != PubKeyHashLength {
+			t.Fatalf("decoded %q to a public key hash of %d bytes", address, len(a.PubKeyHash))
+		}
+		again, err := Decode(a.String())
+		if err != nil || !again.Equal(a) {
+			t.Fatalf("address %q encodes to %q, which decodes to %v, %v", address, a.String(), again, err)
+		}
+	})
+}
@@ -0,0 +1,245 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+)
+
+var (
+	// addrPrefix is the key prefix for the address index, which maps a
+	// public key hash and transaction id to an addrEntry for every
+	// confirmed transaction paying to or spending from the key.
+	addrPrefix = []byte("addr-")
+
+	// addrTipKey holds the hash of the block the address index reflects.
+	addrTipKey = []byte("addrtip")
+)
+
+// addrEntry records how a transaction in the best chain affects an address.
+type addrEntry struct {
+	BlockHash []byte
+	Height    int
+	Received  units.Amount
+	Sent      units.Amount
+
+	// Outputs are the indexes of the outputs paying to the address.
+	Outputs []int
+}
+
+// addrKey returns the db key for the address index entry of a transaction.
+func addrKey(pubKeyHash, txID []byte) []byte {
+	key := append(append([]byte{}, addrPrefix...), pubKeyHash...)
+	return append(key, txID...)
+}
+
+// addrEntries returns the address index entries for the transactions of a
+// block, keyed by public key hash and then transaction id. spent holds the
+// outputs spent by the block, as stored in its undo record.
+func addrEntries(block *Block, spent []spentOutput) map[string]map[string]*addrEntry {
+	entries := make(map[string]map[string]*addrEntry)
+	spentOutputs := make(map[string]TxOutput)
+	for _, s := range spent {
+		spentOutputs[outpoint(s.TxID, s.Out)] = s.Output
+	}
+
+	// entry returns the entry of a transaction for a key, creating it
+	entry := func(pubKeyHash []byte, tx *Transaction) *addrEntry {
+		if entries[string(pubKeyHash)] == nil {
+			entries[string(pubKeyHash)] = make(map[string]*addrEntry)
+		}
+		e, ok := entries[string(pubKeyHash)][string(tx.ID)]
+		if !ok {
+			e = &addrEntry{BlockHash: block.Hash, Height: block.Height}
+			entries[string(pubKeyHash)][string(tx.ID)] = e
+		}
+		return e
+	}
+
+	for _, tx := range block.Transactions {
+
+		// debit the spent outputs from the keys they were locked to
+		if !tx.IsCoinbase() {
+			for _, in := range tx.Inputs {
+				if out, ok := spentOutputs[outpoint(in.ID, in.Out)]; ok {
+					entry(out.PubKeyHash, tx).Sent += out.Value
+				}
+			}
+		}
+
+		// credit the outputs to the keys they are locked to
+		for outIdx, out := range tx.Outputs {
+			e := entry(out.PubKeyHash, tx)
+			e.Received += out.Value
+			e.Outputs = append(e.Outputs, outIdx)
+		}
+	}
+
+	return entries
+}
+
+// indexAddresses adds the transactions of a block being connected to the
+// address index.
+func indexAddresses(txn *badger.Txn, block *Block, spent []spentOutput) error {
+	for pubKeyHash, txs := range addrEntries(block, spent) {
+		for txID, e := range txs {
+			var buffer bytes.Buffer
+			if err := gob.NewEncoder(&buffer).Encode(e); err != nil {
+				return err
+			}
+			if err := txn.Set(addrKey([]byte(pubKeyHash), []byte(txID)), buffer.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+
+	// record the new tip of the address index
+	return txn.Set(addrTipKey, block.Hash)
+}
+
+// unindexAddresses removes the transactions of a block being disconnected
+// from the address index.
+func unindexAddresses(txn *badger.Txn, block *Block, spent []spentOutput) error {
+	for pubKeyHash, txs := range addrEntries(block, spent) {
+		for txID := range txs {
+			if err := txn.Delete(addrKey([]byte(pubKeyHash), []byte(txID))); err != nil {
+				return err
+			}
+		}
+	}
+
+	// move the tip of the address index back
+	return txn.Set(addrTipKey, block.PrevHash)
+}
+
+// addrTip returns the hash of the block the address index reflects, or nil
+// if the index has not been built.
+func (bc *BlockChain) addrTip() ([]byte, error) {
+	var tip []byte
+
+	// initiate read only transaction on db to get the tip
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(addrTipKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		tip, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New("unable to read address index tip - " + err.Error())
+	}
+
+	return tip, nil
+}
+
+// readAddrIndex returns the address index entries for pubKeyHash keyed by
+// transaction id.
+func readAddrIndex(txn *badger.Txn, pubKeyHash []byte) (map[string]addrEntry, error) {
+	entries := make(map[string]addrEntry)
+	prefix := addrKey(pubKeyHash, nil)
+
+	// iterate over the entries with the prefix of the key
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		var e addrEntry
+		value, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&e); err != nil {
+			return nil, errors.New("unable to read address index - " + err.Error())
+		}
+		entries[string(it.Item().Key()[len(prefix):])] = e
+	}
+
+	return entries, nil
+}
+
+// ReindexAddresses rebuilds the address index from the blocks in the best
+// chain and their undo records.
+func (bc *BlockChain) ReindexAddresses() error {
+	return bc.ReindexAddressesContext(context.Background(), false, nil)
+}
+
+// ReindexAddressesContext rebuilds the address index like ReindexAddresses,
+// calling progress, if set, after each block. If ctx is done it stops
+// between blocks with ErrJobCancelled. If resume is set and the index
+// reflects a block of the best chain, such as after an interrupted rebuild,
+// the blocks after it are indexed instead of starting over.
+func (bc *BlockChain) ReindexAddressesContext(ctx context.Context, resume bool, progress func(JobProgress)) error {
+	from := 0
+	if resume {
+		tip, err := bc.addrTip()
+		if err != nil {
+			return err
+		}
+		from = bc.resumeHeight(tip)
+	}
+
+	// remove the existing address index, starting with its tip so an
+	// interrupted removal is not resumed
+	if from == 0 {
+		if err := bc.deleteTip(addrTipKey); err != nil {
+			return err
+		}
+		if err := bc.deletePrefix(addrPrefix); err != nil {
+			return err
+		}
+	}
+
+	// index each block from genesis forward, one db transaction per block
+	height := bc.Height()
+	for h := from; h <= height; h++ {
+		if ctx.Err() != nil {
+			return ErrJobCancelled
+		}
+		block, err := bc.GetBlockByHeight(h)
+		if err != nil {
+			return err
+		}
+		if block.Pruned() {
+			return fmt.Errorf("unable to index pruned block %x", block.Hash)
+		}
+		err = bc.DB.Update(func(txn *badger.Txn) error {
+			spent, err := getUndo(txn, block.Hash)
+			if err != nil {
+				return err
+			}
+			return indexAddresses(txn, block, spent)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to index block %x - %s", block.Hash, err.Error())
+		}
+		if progress != nil {
+			progress(JobProgress{Done: h + 1, Total: height + 1, Last: block.Hash})
+		}
+	}
+
+	return nil
+}
+
+// findIndexedUnspentOutputs finds the confirmed unspent outputs that can be
+// unlocked by pubKeyHash using the address index.
+func (bc *BlockChain) findIndexedUnspentOutputs(pubKeyHash []byte) ([]UnspentOutput, error) {
+	s, err := bc.Snapshot()
+	if err != nil {
+		return nil, errors.New("unable to read unspent outputs - " + err.Error())
+	}
+	defer s.Discard()
+
+	unspent, err := s.UnspentOutputs(pubKeyHash)
+	if err != nil {
+		return nil, errors.New("unable to read unspent outputs - " + err.Error())
+	}
+	return unspent, nil
+}
@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestAddrEntries(t *testing.T) {
+	funding := &Transaction{ID: []byte("funding"), Outputs: []TxOutput{*NewTXOutput(50, alice.Address().String())}}
+	payment := &Transaction{
+		ID:     []byte("payment"),
+		Inputs: []TxInput{{ID: funding.ID, Out: 0}},
+		Outputs: []TxOutput{
+			*NewTXOutput(30, bob.Address().String()),
+			*NewTXOutput(15, alice.Address().String()),
+		},
+	}
+	coinbase := CoinbaseTx(carol.Address().String(), "", 5)
+	block := &Block{BlockHeader: BlockHeader{Hash: []byte("block"), Height: 7}, Transactions: []*Transaction{coinbase, payment}}
+	entries := addrEntries(block, []spentOutput{{TxID: funding.ID, Out: 0, Output: funding.Outputs[0]}})
+
+	for _, c := range []struct {
+		name     string
+		w        *wallet.Wallet
+		tx       *Transaction
+		received units.Amount
+		sent     units.Amount
+		outputs  int
+	}{
+		{"sender debited and credited change", alice, payment, 15, 50, 1},
+		{"recipient credited", bob, payment, 30, 0, 1},
+		{"miner credited", carol, coinbase, Subsidy + 5, 0, 1},
+	} {
+		e, ok := entries[string(wallet.GeneratePublicKeyHash(c.w.PublicKey))][string(c.tx.ID)]
+		if !ok {
+			t.Errorf("%s: no entry", c.name)
+			continue
+		}
+		if e.Received != c.received || e.Sent != c.sent || len(e.Outputs) != c.outputs || e.Height != 7 {
+			t.Errorf("%s: got received %d, sent %d, %d outputs at height %d", c.name, e.Received, e.Sent, len(e.Outputs), e.Height)
+		}
+	}
+}
+
+func TestReindexAddressesMatchesIndex(t *testing.T) {
+	bc := newTestChain(t)
+	tx := send(t, bc, alice, bob, 10, 1)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, carol)
+
+	before := map[string]units.Amount{}
+	for _, w := range []*wallet.Wallet{alice, bob, carol} {
+		before[w.Address().String()] = balance(t, bc, w)
+	}
+	if err := bc.ReindexAddresses(); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []*wallet.Wallet{alice, bob, carol} {
+		if got := balance(t, bc, w); got != before[w.Address().String()] {
+			t.Errorf("%s has %d after reindexing, want %d", w.Address(), got, before[w.Address().String()])
+		}
+	}
+}
+
+func TestFindUnspentOutputsReturnsIndexErrors(t *testing.T) {
+	bc := newTestChain(t)
+	pubKeyHash := wallet.GeneratePublicKeyHash(alice.PublicKey)
+
+	// corrupt an entry of the address index
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(addrKey(pubKeyHash, []byte("corrupt")), []byte("not gob"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bc.FindUnspentOutputs(pubKeyHash); err == nil || !strings.Contains(err.Error(), "address index") {
+		t.Fatalf("got error %v, want address index error", err)
+	}
+	if _, err := bc.NewTransaction(alice, bob.Address().String(), 1, 0, ""); err == nil {
+		t.Fatal("transaction was created from a corrupt address index")
+	}
+}
@@ -0,0 +1,154 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
+)
+
+const (
+	// OpReturn marks the public key hash of a data output, after the
+	// script opcode other chains use for outputs carrying data.
+	OpReturn = 0x6a
+
+	// MaxDataSize is the most bytes of data an output may carry.
+	MaxDataSize = 80
+)
+
+// dataPrefix starts the public key hash of a data output, followed by its
+// data. It is longer than the hash of any key, so no key unlocks it.
+var dataPrefix = append([]byte{OpReturn}, make([]byte, 20)...)
+
+// Anchor is a data output confirmed in the best chain.
+type Anchor struct {
+	TxID      Hash
+	BlockHash Hash
+	Height    int
+	Timestamp int64
+}
+
+// NewDataOutput returns an unspendable output carrying data, which is
+// recorded in the chain when the output is mined.
+func NewDataOutput(data []byte) (*TxOutput, error) {
+	if len(data) == 0 {
+		return nil, errors.New("data output has no data")
+	}
+	if len(data) > MaxDataSize {
+		return nil, fmt.Errorf("data is %d bytes, more than %d", len(data), MaxDataSize)
+	}
+	return &TxOutput{Value: 0, PubKeyHash: append(append([]byte{}, dataPrefix...), data...)}, nil
+}
+
+// IsData returns whether the output carries data instead of paying to a
+// key.
+func (out *TxOutput) IsData() bool {
+	return bytes.HasPrefix(out.PubKeyHash, dataPrefix)
+}
+
+// Data returns the data carried by the output, or nil if it is not a data
+// output.
+func (out *TxOutput) Data() []byte {
+	if !out.IsData() {
+		return nil
+	}
+	return out.PubKeyHash[len(dataPrefix):]
+}
+
+// checkDataOutputs verifies that the data outputs of a transaction hold no
+// value, since they can never be spent, and are within the data limit.
+func (tx *Transaction) checkDataOutputs() error {
+	for outIdx, out := range tx.Outputs {
+		if !out.IsData() {
+			continue
+		}
+		if out.Value != 0 {
+			return fmt.Errorf("data output %d of transaction %x holds a value", outIdx, tx.ID)
+		}
+		if size := len(out.Data()); size == 0 || size > MaxDataSize {
+			return fmt.Errorf("data output %d of transaction %x holds %d bytes, not 1 to %d", outIdx, tx.ID, size, MaxDataSize)
+		}
+	}
+	return nil
+}
+
+// NewAnchorTransaction creates a transaction from the address of wallet w
+// with an output carrying data, paying fee and sending the change to the
+// change address, or back to the address of w if change is empty.
+func (bc *BlockChain) NewAnchorTransaction(w keys.Key, data []byte, fee units.Amount, change string) (*Transaction, error) {
+	out, err := NewDataOutput(data)
+	if err != nil {
+		return nil, err
+	}
+	if fee < 0 {
+		return nil, errors.New("fee is negative")
+	}
+	return bc.fundTransaction(w, []TxOutput{*out}, fee, change)
+}
+
+// FindAnchor returns the earliest transaction in the best chain with an
+// output carrying data, using the address index, which indexes data outputs
+// by their public key hash. found is false if data was never anchored.
+func (bc *BlockChain) FindAnchor(data []byte) (anchor Anchor, found bool, err error) {
+	out, err := NewDataOutput(data)
+	if err != nil {
+		return Anchor{}, false, err
+	}
+	prefix := addrKey(out.PubKeyHash, nil)
+
+	// initiate read only transaction on db to iterate over the entries of
+	// the data
+	err = bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+
+			// keys of longer data starting with the data are skipped, as
+			// the rest of the key is the transaction id
+			key := it.Item().Key()
+			if len(key) != len(prefix)+HashLength {
+				continue
+			}
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var e addrEntry
+			if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&e); err != nil {
+				return errors.New("unable to read address index - " + err.Error())
+			}
+			if found && e.Height >= anchor.Height {
+				continue
+			}
+			if anchor.TxID, err = HashFromBytes(key[len(prefix):]); err != nil {
+				return err
+			}
+			if anchor.BlockHash, err = HashFromBytes(e.BlockHash); err != nil {
+				return err
+			}
+			anchor.Height = e.Height
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		return Anchor{}, false, errors.New("unable to find anchor - " + err.Error())
+	}
+	if !found {
+		return Anchor{}, false, nil
+	}
+
+	// read the time of the block from its header
+	block, err := bc.GetBlock(anchor.BlockHash.Bytes())
+	if err != nil {
+		return Anchor{}, false, err
+	}
+	anchor.Timestamp = block.Timestamp
+
+	return anchor, true, nil
+}
@@ -0,0 +1,92 @@
+package blockchain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestAnchor(t *testing.T) {
+	bc := newTestChain(t)
+	data := []byte("document digest")
+
+	// nothing is anchored before the transaction is mined
+	if _, found, err := bc.FindAnchor(data); err != nil || found {
+		t.Fatalf("got found %v, %v before anchoring", found, err)
+	}
+
+	fee := units.Coin
+	tx, err := bc.NewAnchorTransaction(alice, data, fee, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	block := minePending(t, bc, carol)
+
+	// the data output is not spendable and only the fee left alice
+	if got := balance(t, bc, alice); got != genesisAllocation-fee {
+		t.Fatalf("alice has %s, want %s", got, genesisAllocation-fee)
+	}
+	for outIdx, out := range tx.Outputs {
+		if _, ok := bc.GetUnspentOutput(tx.ID, outIdx); ok == out.IsData() {
+			t.Fatalf("output %d is data %v but unspent %v", outIdx, out.IsData(), ok)
+		}
+	}
+
+	anchor, found, err := bc.FindAnchor(data)
+	if err != nil || !found {
+		t.Fatalf("got found %v, %v after mining", found, err)
+	}
+	if !bytes.Equal(anchor.TxID.Bytes(), tx.ID) || !bytes.Equal(anchor.BlockHash.Bytes(), block.Hash) ||
+		anchor.Height != block.Height || anchor.Timestamp != block.Timestamp {
+		t.Fatalf("got %+v, want transaction %x in block %x", anchor, tx.ID, block.Hash)
+	}
+
+	// data that only starts with the anchored data is not found
+	if _, found, err := bc.FindAnchor(data[:8]); err != nil || found {
+		t.Fatalf("got found %v, %v for a prefix of the data", found, err)
+	}
+}
+
+func TestDataOutputRules(t *testing.T) {
+	bc := newTestChain(t)
+
+	if _, err := NewDataOutput(bytes.Repeat([]byte{1}, MaxDataSize+1)); err == nil {
+		t.Fatal("data output larger than the limit was created")
+	}
+
+	// a data output holding value is rejected, as is spending a data output
+	tx, err := bc.NewAnchorTransaction(alice, []byte("data"), 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range tx.Outputs {
+		if tx.Outputs[i].IsData() {
+			tx.Outputs[i].Value = 1
+		}
+	}
+	if err := bc.AddToMempool(tx); err == nil || !strings.Contains(err.Error(), "holds a value") {
+		t.Fatalf("got %v, want a data output holding value to be rejected", err)
+	}
+
+	tx, err = bc.NewAnchorTransaction(alice, []byte("data"), 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	spend := &Transaction{
+		Inputs:  []TxInput{{ID: tx.ID, Out: 0, PubKey: alice.PublicKey}},
+		Outputs: []TxOutput{*NewTXOutput(1, bob.Address().String())},
+		Version: TxVersion,
+	}
+	spend.ID = spend.GenerateHash()
+	if err := bc.AddToMempool(spend); err == nil || !strings.Contains(err.Error(), "missing or spent") {
+		t.Fatalf("got %v, want spending a data output to be rejected", err)
+	}
+}
@@ -0,0 +1,205 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// AuditReport summarizes an audit of the best chain.
+type AuditReport struct {
+	Blocks       int
+	Transactions int
+
+	// Unspent is the number of unspent outputs after the tip and Supply
+	// their value.
+	Unspent int
+	Supply  units.Amount
+}
+
+// AuditChain replays the best chain from the genesis block to the tip,
+// which can be used after a crash or manual edits of the database. The
+// hash, proof of work, Merkle root, transaction ids, parent and difficulty
+// of every block are verified, and every transaction must spend unspent
+// outputs of the replayed chain with valid signatures. The undo record of
+// each block must hold the outputs it spent, and the stored UTXO set must
+// match the replayed one. It returns the first violation found, or
+// ErrJobCancelled if ctx is done first, with the report of the blocks
+// audited until then. Pruned chains can't be audited. progress, if set,
+// is called after each block.
+func (bc *BlockChain) AuditChain(ctx context.Context, progress func(JobProgress)) (AuditReport, error) {
+	var report AuditReport
+	var parent *Block
+	unspent := make(map[string]UnspentOutput)
+	lookup := func(txID []byte, out int) (TxOutput, bool, error) {
+		u, ok := unspent[outpoint(txID, out)]
+		return u.Output, ok, nil
+	}
+
+	height := bc.Height()
+	for h := 0; h <= height; h++ {
+		if ctx.Err() != nil {
+			return report, ErrJobCancelled
+		}
+		block, err := bc.GetBlockByHeight(h)
+		if err != nil {
+			return report, err
+		}
+		if block.Pruned() {
+			return report, fmt.Errorf("block %x is pruned and can't be audited", block.Hash)
+		}
+		if err := checkBlock(block, bc.mining.authorities); err != nil {
+			return report, err
+		}
+
+		// verify the block links to its parent and spends what the chain
+		// before it left unspent
+		if parent == nil {
+			if len(block.PrevHash) != 0 || block.Height != 0 {
+				return report, errors.New("best chain does not start with a genesis block at height 0")
+			}
+			if err := bc.checkCheckpoint(block); err != nil {
+				return report, err
+			}
+		} else {
+			if !bytes.Equal(block.PrevHash, parent.Hash) {
+				return report, fmt.Errorf("block %x at height %d does not link to block %x", block.Hash, h, parent.Hash)
+			}
+			if err := bc.checkParent(block, parent); err != nil {
+				return report, err
+			}
+			if err := verifyBlockSpends(lookup, block, bc.scheme, true); err != nil {
+				return report, fmt.Errorf("block %x is invalid: %s", block.Hash, err.Error())
+			}
+		}
+
+		// apply the block to the replayed set, collecting what it spent
+		var spent []spentOutput
+		for _, tx := range block.Transactions {
+			if !tx.IsCoinbase() {
+				for _, in := range tx.Inputs {
+					point := outpoint(in.ID, in.Out)
+					spent = append(spent, spentOutput{in.ID, in.Out, unspent[point].Output})
+					delete(unspent, point)
+				}
+			}
+			for outIdx, out := range tx.Outputs {
+				if !out.IsData() {
+					unspent[outpoint(tx.ID, outIdx)] = UnspentOutput{tx.ID, outIdx, out}
+				}
+			}
+		}
+		if err := bc.checkUndo(block, spent); err != nil {
+			return report, err
+		}
+
+		report.Blocks++
+		report.Transactions += len(block.Transactions)
+		if progress != nil {
+			progress(JobProgress{Done: h + 1, Total: height + 1, Last: block.Hash})
+		}
+		parent = block
+	}
+
+	for _, u := range unspent {
+		report.Unspent++
+		report.Supply += u.Output.Value
+	}
+	return report, bc.checkUTXOSet(unspent)
+}
+
+// checkUndo verifies that the undo record of a block holds the outputs the
+// block spent.
+func (bc *BlockChain) checkUndo(block *Block, spent []spentOutput) error {
+	return bc.DB.View(func(txn *badger.Txn) error {
+		recorded, err := getUndo(txn, block.Hash)
+		if err != nil {
+			return fmt.Errorf("block %x has no undo record - %s", block.Hash, err.Error())
+		}
+		if len(recorded) != len(spent) {
+			return fmt.Errorf("undo record of block %x holds %d outputs, the block spent %d", block.Hash, len(recorded), len(spent))
+		}
+		want := make(map[string]TxOutput)
+		for _, s := range spent {
+			want[outpoint(s.TxID, s.Out)] = s.Output
+		}
+		for _, r := range recorded {
+			out, ok := want[outpoint(r.TxID, r.Out)]
+			if !ok || !equalOutputs(out, r.Output) {
+				return fmt.Errorf("undo record of block %x does not match output %s it spent", block.Hash, outpoint(r.TxID, r.Out))
+			}
+		}
+		return nil
+	})
+}
+
+// checkUTXOSet verifies that the stored UTXO set reflects the tip and holds
+// exactly the unspent outputs replayed by an audit.
+func (bc *BlockChain) checkUTXOSet(unspent map[string]UnspentOutput) error {
+	tip, err := bc.utxoTip()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(tip, bc.Tip()) {
+		return fmt.Errorf("UTXO set reflects block %x, not the tip %x", tip, bc.Tip())
+	}
+
+	var extra []string
+	seen := make(map[string]bool)
+	err = bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(utxoPrefix); it.ValidForPrefix(utxoPrefix); it.Next() {
+
+			// key is prefix + txID + 8 byte output index
+			key := it.Item().Key()
+			if len(key) != len(utxoPrefix)+HashLength+8 {
+				return fmt.Errorf("UTXO set has a malformed key %x", key)
+			}
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			point := outpoint(key[len(utxoPrefix):len(utxoPrefix)+HashLength], int(FromBytes(key[len(utxoPrefix)+HashLength:])))
+			seen[point] = true
+			u, ok := unspent[point]
+			if !ok {
+				extra = append(extra, point)
+			} else if !equalOutputs(u.Output, deserializeOutput(value)) {
+				return fmt.Errorf("UTXO set holds a different output %s than the chain created", point)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(extra) > 0 {
+		sort.Strings(extra)
+		return fmt.Errorf("UTXO set holds %d outputs the chain does not leave unspent, such as %s", len(extra), extra[0])
+	}
+
+	// every replayed output must be in the set
+	var missing []string
+	for point := range unspent {
+		if !seen[point] {
+			missing = append(missing, point)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("UTXO set is missing %d unspent outputs of the chain, such as %s", len(missing), missing[0])
+	}
+	return nil
+}
+
+// equalOutputs returns whether two outputs have the same value and lock.
+func equalOutputs(a, b TxOutput) bool {
+	return a.Value == b.Value && bytes.Equal(a.PubKeyHash, b.PubKeyHash)
+}
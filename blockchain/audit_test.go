@@ -0,0 +1,61 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestAuditChain(t *testing.T) {
+	bc := newTestChain(t)
+	tx := send(t, bc, alice, bob, 10*units.Coin, units.Coin)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, carol)
+	minePending(t, bc, carol)
+
+	// a consistent chain passes with its supply unspent
+	report, err := bc.AuditChain(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Blocks != 3 || report.Transactions != 4 || report.Supply != genesisAllocation+2*Subsidy {
+		t.Fatalf("got %+v, want 3 blocks, 4 transactions and a supply of %s", report, genesisAllocation+2*Subsidy)
+	}
+
+	// an output removed from the UTXO set is reported missing
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(utxoKey(tx.ID, 0))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AuditChain(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("got %v, want a missing output", err)
+	}
+
+	// an undo record that lost the outputs its block spent is reported
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		block, err := bc.GetBlockByHeight(1)
+		if err != nil {
+			return err
+		}
+		var buffer bytes.Buffer
+		if err := gob.NewEncoder(&buffer).Encode([]spentOutput{}); err != nil {
+			return err
+		}
+		return txn.Set(undoKey(block.Hash), buffer.Bytes())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AuditChain(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "undo record") {
+		t.Fatalf("got %v, want a mismatched undo record", err)
+	}
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/edwintcloud/gochain/keys"
+)
+
+var (
+	// ErrNotAuthority is returned when a node without the key of an
+	// authority mines a block of a proof of authority network.
+	ErrNotAuthority = errors.New("node does not hold the key of an authority of the network")
+
+	// ErrNotInTurn is returned when an authority mines a block another
+	// authority is to seal.
+	ErrNotInTurn = errors.New("another authority is to seal the next block")
+
+	// ErrSealedBlocks is returned for block templates of proof of authority
+	// networks, whose blocks are sealed by their authorities instead of
+	// mined.
+	ErrSealedBlocks = errors.New("blocks of proof of authority networks are sealed by their authorities, not mined")
+)
+
+// Authorities are the keys that seal the blocks of a proof of authority
+// network in turn, instead of mining them: the block at height h is sealed
+// by the key at (h - 1) modulo their number, which signs its hash. Sealed
+// blocks are made at the lowest difficulty, so the best chain is the
+// longest. The network stalls while the authority whose turn it is is
+// offline.
+type Authorities struct {
+	// Keys are the public keys of the authorities, the concatenated x and
+	// y coordinates of points on the curve of Scheme.
+	Keys [][]byte
+
+	// Scheme is the signature scheme seals are made with.
+	Scheme keys.Scheme
+}
+
+// ParseAuthorities parses the public keys of authorities given in hex,
+// which must be points on the curve of scheme and given once each.
+func ParseAuthorities(hexKeys []string, scheme keys.Scheme) (*Authorities, error) {
+	authorities := &Authorities{Scheme: scheme}
+	for _, hexKey := range hexKeys {
+		key, err := hex.DecodeString(hexKey)
+		if err != nil {
+			return nil, fmt.Errorf("authority %s is not hex", hexKey)
+		}
+		x := new(big.Int).SetBytes(key[:len(key)/2])
+		y := new(big.Int).SetBytes(key[len(key)/2:])
+		if len(key) == 0 || len(key)%2 != 0 || !scheme.Curve().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("authority %s is not a %s public key", hexKey, scheme.Name())
+		}
+		if authorities.index(key) >= 0 {
+			return nil, fmt.Errorf("authority %s is given twice", hexKey)
+		}
+		authorities.Keys = append(authorities.Keys, key)
+	}
+	return authorities, nil
+}
+
+// InTurn returns the public key of the authority sealing the block at a
+// height.
+func (a *Authorities) InTurn(height int) []byte {
+	return a.Keys[(height-1)%len(a.Keys)]
+}
+
+// index returns the position of key among the authorities, or -1 if it is
+// not one of them.
+func (a *Authorities) index(key []byte) int {
+	for i, k := range a.Keys {
+		if bytes.Equal(k, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// seal seals a block with the key of the authority whose turn it is,
+// setting its hash, which commits to the same data as a proof of work with
+// nonce 0, and its signature of the hash.
+func (a *Authorities) seal(b *Block, key keys.Key) error {
+	if key == nil || a.index(key.PubKey()) < 0 {
+		return ErrNotAuthority
+	}
+	if !bytes.Equal(key.PubKey(), a.InTurn(b.Height)) {
+		return ErrNotInTurn
+	}
+
+	b.Nonce = 0
+	b.Hash = NewProof(b).Sum(b.Nonce)
+	signature, _, err := key.Sign(b.Hash)
+	if err != nil {
+		return errors.New("unable to seal block - " + err.Error())
+	}
+	b.Signature = signature
+	return nil
+}
+
+// checkSeal verifies that a header is signed by the authority whose turn it
+// is at its height.
+func (a *Authorities) checkSeal(h *BlockHeader) error {
+	if len(h.Signature) == 0 {
+		return fmt.Errorf("block %x is not sealed by an authority", h.Hash)
+	}
+	if !a.Scheme.Verify(a.InTurn(h.Height), h.Hash, h.Signature) {
+		return fmt.Errorf("block %x is not sealed by authority %x, whose turn it is", h.Hash, a.InTurn(h.Height))
+	}
+	return nil
+}
+
+// CheckHeaders verifies headers of a proof of authority network like the
+// function CheckHeaders does those of a proof of work network, requiring
+// each to be sealed by the authority whose turn it is instead of a proof of
+// work.
+func (a *Authorities) CheckHeaders(headers []*BlockHeader) error {
+	return checkHeaders(headers, a)
+}
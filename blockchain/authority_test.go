@@ -0,0 +1,136 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/logging"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// newAuthorityChain opens a new test chain of a proof of authority network
+// sealed by alice and bob in turn, with the key of authority.
+func newAuthorityChain(t *testing.T, authority keys.Key) *BlockChain {
+	t.Helper()
+	genesis := testGenesis()
+	genesis.Authorities = []string{hex.EncodeToString(alice.PubKey()), hex.EncodeToString(bob.PubKey())}
+	bc, err := Open(Config{Path: t.TempDir(), Genesis: genesis, Logger: logging.Discard, Authority: authority})
+	if err != nil {
+		t.Fatalf("unable to open chain: %s", err)
+	}
+	t.Cleanup(func() { bc.Close() })
+	return bc
+}
+
+// sealOn seals a block holding a coinbase paying sealer on parent with the
+// key of sealer, whether or not it is its turn.
+func sealOn(t *testing.T, bc *BlockChain, parent *Block, sealer *wallet.Wallet) *Block {
+	t.Helper()
+	block := mineOn(t, bc, parent, sealer)
+	block.Nonce = 0
+	block.Hash = NewProof(block).Sum(0)
+	signature, _, err := sealer.Sign(block.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Signature = signature
+	return block
+}
+
+func TestAuthoritySealing(t *testing.T) {
+	bc := newAuthorityChain(t, alice)
+
+	// alice seals the first block, and bob the second
+	first := minePending(t, bc, alice)
+	if err := checkProof(first, bc.mining.authorities); err != nil {
+		t.Fatalf("sealed block is invalid: %s", err)
+	}
+	if first.GetDifficulty() != 1 {
+		t.Fatalf("sealed block has difficulty %d, want 1", first.GetDifficulty())
+	}
+	if _, err := bc.MinePending(alice.Address().String()); err != ErrNotInTurn {
+		t.Fatalf("sealing out of turn returned %v, want ErrNotInTurn", err)
+	}
+	second := sealOn(t, bc, first, bob)
+	if err := bc.AcceptBlock(second); err != nil {
+		t.Fatalf("block sealed by bob in turn is rejected: %s", err)
+	}
+	third := minePending(t, bc, alice)
+
+	// the seal survives storage and is checked with the headers
+	stored, err := bc.GetBlock(third.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(stored.Signature) != hex.EncodeToString(third.Signature) {
+		t.Fatal("stored block lost its seal")
+	}
+	headers, err := bc.Headers(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.mining.authorities.CheckHeaders(headers); err != nil {
+		t.Fatalf("sealed headers are invalid: %s", err)
+	}
+	if err := CheckHeaders(headers); err == nil || !strings.Contains(err.Error(), "is sealed") {
+		t.Fatalf("proof of work header check returned %v for sealed headers", err)
+	}
+
+	// blocks sealed out of turn, by others or not at all are rejected
+	for _, c := range []struct {
+		name  string
+		block *Block
+		want  string
+	}{
+		{"out of turn", sealOn(t, bc, third, alice), "whose turn it is"},
+		{"not an authority", sealOn(t, bc, third, carol), "whose turn it is"},
+		{"mined", mineOn(t, bc, third, bob), "not sealed"},
+	} {
+		if err := bc.ValidateBlock(c.block); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: ValidateBlock returned %v, want %q", c.name, err, c.want)
+		}
+	}
+
+	// nodes without the key of an authority only accept blocks
+	if _, err := newAuthorityChain(t, carol).MinePending(carol.Address().String()); err != ErrNotAuthority {
+		t.Fatalf("sealing without an authority key returned %v, want ErrNotAuthority", err)
+	}
+	if _, err := bc.GetBlockTemplate(alice.Address().String()); err != ErrSealedBlocks {
+		t.Fatalf("GetBlockTemplate returned %v, want ErrSealedBlocks", err)
+	}
+}
+
+func TestSealedBlockOnProofOfWorkChain(t *testing.T) {
+	bc := newTestChain(t)
+	block := sealOn(t, bc, tip(t, bc), alice)
+	if err := bc.AcceptBlock(block); err == nil || !strings.Contains(err.Error(), "not a proof of authority network") {
+		t.Fatalf("AcceptBlock returned %v for a sealed block", err)
+	}
+}
+
+func TestAuthoritiesGenesis(t *testing.T) {
+	pow := testGenesis()
+	poa := testGenesis()
+	poa.Authorities = []string{hex.EncodeToString(alice.PubKey())}
+	if hex.EncodeToString(pow.Block().Hash) == hex.EncodeToString(poa.Block().Hash) {
+		t.Fatal("genesis block does not commit to the authorities")
+	}
+	if rules := poa.DifficultyRules(); rules.Min != 1 || rules.Max != 1 {
+		t.Fatalf("proof of authority difficulty rules are %+v", rules)
+	}
+
+	for _, c := range []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"zz"}, "not hex"},
+		{[]string{"0102"}, "not a p256 public key"},
+		{[]string{hex.EncodeToString(alice.PubKey()), hex.EncodeToString(alice.PubKey())}, "given twice"},
+	} {
+		if _, err := ParseAuthorities(c.keys, keys.P256); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("ParseAuthorities(%v) returned %v, want %q", c.keys, err, c.want)
+		}
+	}
+}
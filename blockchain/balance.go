@@ -0,0 +1,178 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/edwintcloud/gochain/consensus"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// CoinbaseMaturity is the number of confirmations a mining reward needs
+// before balance queries count it as trusted. Rewards vanish with their
+// block when it is reorganized away, so younger ones are reported apart as
+// immature. The chain itself does not enforce it.
+const CoinbaseMaturity = consensus.CoinbaseMaturity
+
+// Balances splits the unspent value locked to a key by how far it can be
+// trusted.
+type Balances struct {
+
+	// Trusted is the value of confirmed outputs with at least the required
+	// confirmations, which is safe to spend.
+	Trusted units.Amount
+
+	// UntrustedPending is the value of outputs in the mempool and of
+	// confirmed outputs with fewer than the required confirmations.
+	UntrustedPending units.Amount
+
+	// Immature is the value of mining rewards with fewer than
+	// CoinbaseMaturity confirmations.
+	Immature units.Amount
+}
+
+// Total returns the value of every unspent output in the balances.
+func (b Balances) Total() units.Amount {
+	return b.Trusted + b.UntrustedPending + b.Immature
+}
+
+// Balances returns the value of the outputs that can be unlocked by
+// pubKeyHash and are not spent in the mempool, split by how far they can be
+// trusted. Confirmed outputs are trusted once they have minConf
+// confirmations; with a minConf of 0 the outputs of the mempool are trusted
+// too.
+func (bc *BlockChain) Balances(pubKeyHash []byte, minConf int) (Balances, error) {
+	var balances Balances
+	pending := bc.MempoolTransactions()
+	spentByPending := make(map[string]bool)
+
+	// collect the outputs spent by pending transactions
+	for _, tx := range pending {
+		for _, in := range tx.Inputs {
+			spentByPending[outpoint(in.ID, in.Out)] = true
+		}
+	}
+
+	s, err := bc.Snapshot()
+	if err != nil {
+		return Balances{}, errors.New("unable to read balances - " + err.Error())
+	}
+	defer s.Discard()
+
+	entries, err := readAddrIndex(s.txn, pubKeyHash)
+	if err != nil {
+		return Balances{}, errors.New("unable to read balances - " + err.Error())
+	}
+	unspent, err := s.UnspentOutputs(pubKeyHash)
+	if err != nil {
+		return Balances{}, errors.New("unable to read balances - " + err.Error())
+	}
+
+	// sort the confirmed outputs by the depth of their transaction
+	for _, u := range unspent {
+		if spentByPending[outpoint(u.TxID, u.Out)] {
+			continue
+		}
+		height := entries[string(u.TxID)].Height
+		confirmations := s.Height() - height + 1
+		coinbase, err := s.isCoinbase(u.TxID, height)
+		if err != nil {
+			return Balances{}, errors.New("unable to read balances - " + err.Error())
+		}
+		switch {
+		case coinbase && !consensus.Mature(confirmations):
+			balances.Immature += u.Output.Value
+		case confirmations < minConf:
+			balances.UntrustedPending += u.Output.Value
+		default:
+			balances.Trusted += u.Output.Value
+		}
+	}
+
+	// add the unspent outputs of pending transactions
+	for _, tx := range pending {
+		for outIdx, out := range tx.Outputs {
+			if !out.IsLockedWithKey(pubKeyHash) || spentByPending[outpoint(tx.ID, outIdx)] {
+				continue
+			}
+			if minConf <= 0 {
+				balances.Trusted += out.Value
+			} else {
+				balances.UntrustedPending += out.Value
+			}
+		}
+	}
+
+	return balances, nil
+}
+
+// PendingTransfers is the value moved to and from a key by pending
+// transactions, each netting the change it pays back to the key.
+type PendingTransfers struct {
+
+	// Incoming is the value pending transactions pay the key beyond what
+	// they spend of it.
+	Incoming units.Amount
+
+	// Outgoing is the value pending transactions spend of the key beyond
+	// what they pay it back.
+	Outgoing units.Amount
+}
+
+// PendingTransfers returns the value the transactions in the mempool move
+// to and from the outputs that can be unlocked by pubKeyHash.
+func (bc *BlockChain) PendingTransfers(pubKeyHash []byte) PendingTransfers {
+	var transfers PendingTransfers
+	pending := bc.MempoolTransactions()
+
+	// index the outputs of pending transactions, which others may spend
+	outputs := make(map[string]TxOutput)
+	for _, tx := range pending {
+		for outIdx, out := range tx.Outputs {
+			outputs[outpoint(tx.ID, outIdx)] = out
+		}
+	}
+
+	for _, tx := range pending {
+		var spent, received units.Amount
+		for _, in := range tx.Inputs {
+			out, ok := outputs[outpoint(in.ID, in.Out)]
+			if !ok {
+				out, ok = bc.GetUnspentOutput(in.ID, in.Out)
+			}
+			if ok && out.IsLockedWithKey(pubKeyHash) {
+				spent += out.Value
+			}
+		}
+		for _, out := range tx.Outputs {
+			if out.IsLockedWithKey(pubKeyHash) {
+				received += out.Value
+			}
+		}
+		if received > spent {
+			transfers.Incoming += received - spent
+		} else {
+			transfers.Outgoing += spent - received
+		}
+	}
+
+	return transfers
+}
+
+// isCoinbase returns whether the transaction txID in the block at height is
+// its mining reward. The allocations of the genesis block can't be
+// reorganized away and outputs of pruned blocks are long buried, so neither
+// counts as a reward.
+func (s *Snapshot) isCoinbase(txID []byte, height int) (bool, error) {
+	if height == 0 {
+		return false, nil
+	}
+	block, err := s.GetBlockByHeight(height)
+	if err != nil {
+		return false, err
+	}
+	if len(block.Transactions) == 0 || !block.Transactions[0].IsCoinbase() {
+		return false, nil
+	}
+	return bytes.Equal(block.Transactions[0].ID, txID), nil
+}
@@ -0,0 +1,76 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestBalances(t *testing.T) {
+	bc := newTestChain(t)
+
+	// balances returns the balances of w trusting minConf confirmations
+	balances := func(w *wallet.Wallet, minConf int) Balances {
+		t.Helper()
+		b, err := bc.Balances(wallet.GeneratePublicKeyHash(w.PublicKey), minConf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	// the genesis allocation is trusted, not an immature reward
+	if got, want := balances(alice, 1), (Balances{Trusted: genesisAllocation}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	// a pending payment is untrusted for its receiver and the change is
+	// untrusted for its sender, unless unconfirmed outputs are trusted
+	fee := units.Coin
+	tx := send(t, bc, alice, bob, 10*units.Coin, fee)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	change := genesisAllocation - 10*units.Coin - fee
+	if got, want := balances(alice, 1), (Balances{UntrustedPending: change}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got, want := balances(bob, 1), (Balances{UntrustedPending: 10 * units.Coin}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got, want := balances(bob, 0), (Balances{Trusted: 10 * units.Coin}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	// the payment is outgoing for its sender, net of the change, and
+	// incoming for its receiver
+	aliceTransfers := bc.PendingTransfers(wallet.GeneratePublicKeyHash(alice.PublicKey))
+	if want := (PendingTransfers{Outgoing: 10*units.Coin + fee}); aliceTransfers != want {
+		t.Fatalf("got %+v, want %+v", aliceTransfers, want)
+	}
+	bobTransfers := bc.PendingTransfers(wallet.GeneratePublicKeyHash(bob.PublicKey))
+	if want := (PendingTransfers{Incoming: 10 * units.Coin}); bobTransfers != want {
+		t.Fatalf("got %+v, want %+v", bobTransfers, want)
+	}
+
+	// once mined the payment is trusted with one confirmation, but not with
+	// two, and the reward of the miner is immature
+	minePending(t, bc, carol)
+	if got, want := balances(bob, 1), (Balances{Trusted: 10 * units.Coin}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if transfers := bc.PendingTransfers(wallet.GeneratePublicKeyHash(bob.PublicKey)); transfers != (PendingTransfers{}) {
+		t.Fatalf("got %+v pending once mined", transfers)
+	}
+	if got, want := balances(bob, 2), (Balances{UntrustedPending: 10 * units.Coin}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	reward := balances(carol, 0)
+	if reward.Immature == 0 || reward.Trusted != 0 || reward.UntrustedPending != 0 {
+		t.Fatalf("got %+v, want only an immature reward", reward)
+	}
+	if total := reward.Total(); total != balance(t, bc, carol) {
+		t.Fatalf("got total %s, want %s", total, balance(t, bc, carol))
+	}
+}
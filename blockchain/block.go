@@ -2,93 +2,273 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/sha256"
+	"context"
 	"encoding/gob"
+	"errors"
 	"log"
+	"math/big"
+	"time"
+
+	"github.com/edwintcloud/gochain/keys"
 )
 
+// ErrMiningCancelled is returned when a block is not mined because its
+// context was cancelled.
+var ErrMiningCancelled = errors.New("mining cancelled")
+
+// BlockVersion is the version of the blocks mined by this node. Version 0
+// blocks, mined before blocks had a version, leave their version and height
+// out of their proof of work so their hashes are unchanged. The proof of
+// work of version 1 blocks commits to both, so a header alone proves its
+// height. Consensus changes can key off later versions.
+const BlockVersion = 1
+
+// BlockHeader is the part of a block its proof of work commits to. It is
+// serialized separately from the transactions of the block, so headers can
+// be synced and their proof of work verified without the transactions.
+type BlockHeader struct {
+	Version  int
+	Hash     []byte
+	PrevHash []byte
+
+	// MerkleRoot is the root of the Merkle tree of the ids of the
+	// transactions of the block, and the only record of them once the block
+	// is pruned
+	MerkleRoot []byte
+	Nonce      int
+	Height     int
+	Timestamp  int64
+
+	// Difficulty is the number of leading zero bits the hash must have, or
+	// 0 for the default Difficulty
+	Difficulty int
+
+	// Signature is the signature of the hash by the authority that sealed
+	// the block, for blocks of proof of authority networks. The hash
+	// doesn't commit to it
+	Signature []byte
+}
+
 // Block represents a block in the blockchain.
 type Block struct {
+	BlockHeader
+	Transactions []*Transaction
+}
+
+// legacyBlock is a Block as serialized with gob by earlier versions, before
+// the header was a struct of its own. TxHash was only set for pruned
+// blocks.
+type legacyBlock struct {
 	Hash         []byte
 	Transactions []*Transaction
 	PrevHash     []byte
 	Nonce        int
+	Height       int
+	Timestamp    int64
+	Difficulty   int
+	TxHash       []byte
 }
 
-// HashTransactions hashes transactions into a byte slice.
+// HashTransactions returns the Merkle root of the transactions of the
+// block, which the proof of work commits to.
 func (b *Block) HashTransactions() []byte {
-	var txHashes [][]byte
-	var txHash [32]byte
 
-	// add each transaction from block into txHashes
-	for _, tx := range b.Transactions {
-		txHashes = append(txHashes, tx.ID)
+	// use the stored root if the transactions have been pruned
+	if b.Pruned() {
+		return b.MerkleRoot
 	}
 
-	// join txHashes together and hash them into txHash
-	txHash = sha256.Sum256(bytes.Join(txHashes, []byte{}))
+	// return the root of the Merkle tree of transaction ids
+	return merkleRoot(b.Transactions)
+}
 
-	// return hash of transactions
-	return txHash[:]
+// Header returns the header of the block, with the Merkle root of its
+// transactions if the block was made without one.
+func (b *Block) Header() *BlockHeader {
+	header := b.BlockHeader
+	if header.MerkleRoot == nil {
+		header.MerkleRoot = b.HashTransactions()
+	}
+	return &header
+}
+
+// Work returns the expected number of hashes needed to mine the block,
+// which is 2^256 / (target + 1).
+func (b *Block) Work() *big.Int {
+	target := new(big.Int).Add(NewProof(b).Target, big.NewInt(1))
+	work := new(big.Int).Lsh(big.NewInt(1), 256)
+
+	// return work
+	return work.Div(work, target)
 }
 
 // CreateBlock creates a new block with a hash and returns a referrence
-// to the created block.
-func CreateBlock(txs []*Transaction, prevHash []byte) *Block {
+// to the created block. Height is the number of blocks before it in the chain.
+func CreateBlock(txs []*Transaction, prevHash []byte, height, difficulty int) *Block {
+
+	// mining can't be cancelled without a deadline or cancel func
+	block, _ := CreateBlockContext(context.Background(), txs, prevHash, height, difficulty)
+	return block
+}
+
+// CreateBlockContext creates a new block like CreateBlock, stopping the
+// proof of work and returning ErrMiningCancelled if ctx is done first.
+func CreateBlockContext(ctx context.Context, txs []*Transaction, prevHash []byte, height, difficulty int) (*Block, error) {
+	return createBlock(ctx, txs, prevHash, height, difficulty, time.Now().Unix(), miningOptions{})
+}
+
+// miningOptions are the settings of the proof of work of blocks mined for a
+// chain. hash is the proof of work hash of the network, SHA256 if nil.
+type miningOptions struct {
+	progress MiningProgress
+	midstate bool
+	hash     PowHash
+
+	// authorities seal blocks instead of mining them if set, with the key
+	// of the authority of the node
+	authorities *Authorities
+	authority   keys.Key
+}
+
+// powHash returns the proof of work hash blocks are mined with.
+func (opts miningOptions) powHash() PowHash {
+	if opts.hash == nil {
+		return SHA256
+	}
+	return opts.hash
+}
+
+// version returns the version of the blocks mined, committing to their
+// proof of work hash.
+func (opts miningOptions) version() int {
+	return blockVersion(opts.powHash())
+}
+
+// createBlock creates and mines a new block like CreateBlockContext at
+// timestamp with the mining options of a chain.
+func createBlock(ctx context.Context, txs []*Transaction, prevHash []byte, height, difficulty int, timestamp int64, opts miningOptions) (*Block, error) {
 
 	// create new block from data and prev block hash
 	block := Block{
-		Hash:         []byte{},
+		BlockHeader: BlockHeader{
+			Version:    opts.version(),
+			Hash:       []byte{},
+			PrevHash:   prevHash,
+			MerkleRoot: merkleRoot(txs),
+			Nonce:      0,
+			Height:     height,
+			Timestamp:  timestamp,
+			Difficulty: difficulty,
+		},
 		Transactions: txs,
-		PrevHash:     prevHash,
-		Nonce:        0,
+	}
+
+	// mine block and return a reference to it
+	if err := block.mine(ctx, opts); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// mine runs the proof of work for a block with opts, setting its hash and
+// nonce, or seals it if opts has authorities. Genesis blocks are always
+// mined.
+func (b *Block) mine(ctx context.Context, opts miningOptions) error {
+	if opts.authorities != nil && b.Height > 0 {
+		return opts.authorities.seal(b, opts.authority)
 	}
 
 	// create proof of work for block
-	pow := NewProof(&block)
+	pow := NewProof(b)
+	pow.Progress = opts.progress
+	pow.Midstate = opts.midstate
 
-	// run proof of work on data
-	nonce, hash := pow.Run()
+	// run proof of work on data until a nonce is found or ctx is done
+	nonce, hash, ok := pow.RunWithCancel(ctx.Done())
+	if !ok {
+		return ErrMiningCancelled
+	}
 
 	// update block with hash and nonce
-	block.Hash = hash[:]
-	block.Nonce = nonce
+	b.Hash = hash[:]
+	b.Nonce = nonce
 
-	// return a reference to the new block
-	return &block
+	return nil
 }
 
-// Serialize serializes a block into a byte slice so it can be stored in the db.
+// Serialize serializes a block into a byte slice so it can be stored in the
+// db, exported and sent to miners: the format version blockFormatProtobuf
+// followed by the Block message of gochain.proto, which tools outside Go
+// can decode.
 func (b *Block) Serialize() []byte {
-	var buffer bytes.Buffer
-
-	// create encoder on res bytes buffer
-	encoder := gob.NewEncoder(&buffer)
-
-	// use encoder to encode block into byte slice
-	err := encoder.Encode(b)
-	if err != nil {
-		log.Panicf("Unable to encode block structure into byte slice: %s", err.Error())
-	}
-
-	// return bytes from buffer
-	return buffer.Bytes()
+	return append([]byte{blockFormatProtobuf}, b.MarshalProto()...)
 }
 
 // Deserialize deserializes a byte slice into a new Block and returns a
 // reference to the created Block.
 func Deserialize(data []byte) *Block {
-	var block Block
+	block, err := DecodeBlock(data)
+	if err != nil {
+		log.Panicf("Unable to decode byte slice into a new Block struct: %s", err.Error())
+	}
+	return block
+}
 
-	// create decoder on a bytes reader of the data byte slice
-	decoder := gob.NewDecoder(bytes.NewReader(data))
+// DecodeBlock decodes a block serialized by Serialize, or with gob by
+// earlier versions. Blocks serialized before headers held the Merkle root
+// get the root of their transactions.
+func DecodeBlock(data []byte) (*Block, error) {
+	if len(data) > 0 && data[0] == blockFormatProtobuf {
+		block, err := UnmarshalBlockProto(data[1:])
+		if err != nil {
+			return nil, errors.New("unable to decode block - " + err.Error())
+		}
+		block.BlockHeader = *block.Header()
+		return block, nil
+	}
 
-	// use decoder to decode bytes reader into created block
-	err := decoder.Decode(&block)
+	// decode blocks serialized before the format version
+	var legacy legacyBlock
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&legacy); err != nil {
+		return nil, errors.New("unable to decode block - " + err.Error())
+	}
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Hash:       legacy.Hash,
+			PrevHash:   legacy.PrevHash,
+			MerkleRoot: legacy.TxHash,
+			Nonce:      legacy.Nonce,
+			Height:     legacy.Height,
+			Timestamp:  legacy.Timestamp,
+			Difficulty: legacy.Difficulty,
+		},
+		Transactions: legacy.Transactions,
+	}
+	block.BlockHeader = *block.Header()
+	return block, nil
+}
+
+// Serialize serializes a block header into a byte slice so it can be synced
+// without the transactions of its block: the format version
+// blockFormatProtobuf followed by the BlockHeader message of gochain.proto.
+func (h *BlockHeader) Serialize() []byte {
+	return append([]byte{blockFormatProtobuf}, h.marshalProto()...)
+}
+
+// DecodeHeader decodes a block header serialized by Serialize.
+func DecodeHeader(data []byte) (*BlockHeader, error) {
+	if len(data) == 0 || data[0] != blockFormatProtobuf {
+		return nil, errors.New("unable to decode block header - unknown format")
+	}
+	header, err := unmarshalHeaderProto(data[1:])
 	if err != nil {
-		log.Panicf("Unable to decode byte slice into a new Block struct: %s", err.Error())
+		return nil, errors.New("unable to decode block header - " + err.Error())
 	}
+	return header, nil
+}
 
-	// return reference to decoded block
-	return &block
+// legacyBlockFormat returns whether a serialized block is in the gob format
+// of earlier versions.
+func legacyBlockFormat(data []byte) bool {
+	return len(data) == 0 || data[0] != blockFormatProtobuf
 }
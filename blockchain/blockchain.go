@@ -2,20 +2,121 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/ecdsa"
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/hooks"
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/logging"
+	"github.com/edwintcloud/gochain/units"
 )
 
 // BlockChain is the representation of our blockchain.
+//
+// A BlockChain is safe for concurrent use. Reads such as Tip, Height,
+// GetBlock, NewIterator, GetUnspentOutput and MempoolTransactions may run alongside
+// each other and alongside writers, and see the chain before or after a
+// change, never part of one. Changes to the best chain and the mempool,
+// made by AddBlock, MinePending, AcceptBlock, AddToMempool, Prune, Freeze
+// and Unfreeze, are serialized. Maintenance such as ReindexUTXO, CompactDB
+// and Close must not run while the chain is in use.
 type BlockChain struct {
-	PrevHash []byte
-	DB       *badger.DB
+	DB *badger.DB
+
+	// tip is the hash of the last block of the best chain, read with Tip
+	// and replaced with setTip while holding mutex
+	tip      []byte
+	tipMutex sync.RWMutex
+
+	// mutex serializes changes to the best chain and the mempool
+	mutex sync.Mutex
+
+	// rules are the difficulty rules of the network of the chain
+	rules DifficultyRules
+
+	// scheme is the signature scheme of the network of the chain
+	scheme keys.Scheme
+
+	// pruneDepth is the number of recent blocks whose transactions are
+	// kept, or 0 to keep every block
+	pruneDepth int
+
+	// events receives the blocks, transactions and reorgs of the chain
+	events *events.Bus
+
+	// lifecycle holds the hooks embedders registered to follow the chain
+	// and the mempool
+	lifecycle lifecycleHooks
+
+	// mining holds the settings of the proof of work of blocks mined for
+	// the chain
+	mining miningOptions
+
+	// log receives the status messages of the chain
+	log logging.Logger
+
+	// checkpoints are the checkpoints of the network ordered by height
+	checkpoints []Checkpoint
+
+	// finalityDepth is the number of confirmations after which blocks of
+	// the best chain are final, or 0 if blocks are never final
+	finalityDepth int
+
+	// priorityKeys are the public key hashes whose pending transactions
+	// are placed in blocks first
+	priorityKeys [][]byte
+
+	// path is the directory of the database, which Close removes if
+	// temporary is set
+	path      string
+	temporary bool
+
+	// readOnly is set if the database was opened read-only, so blocks
+	// can't be added
+	readOnly bool
+
+	// gcDiscardRatio is the fraction of a value log file that must be
+	// reclaimable for garbage collection to rewrite it
+	gcDiscardRatio float64
+
+	// utxoCache caches unspent outputs, or is nil if they aren't cached
+	utxoCache *utxoCache
+
+	// clock gives the current time, or is nil for the system clock
+	clock func() time.Time
+
+	// feeHistogram counts the pending transactions in each fee band
+	feeHistogram feeHistogram
+
+	// mempoolLimits bound the mempool, and mempoolStats counts the
+	// transactions they removed
+	mempoolLimits MempoolLimits
+	mempoolStats  mempoolStats
+
+	// journalPath is the journal file, or empty if the journal is not
+	// kept, and journalMutex serializes appending to it
+	journalPath  string
+	journalMutex sync.Mutex
+
+	// reorgLogPath is the reorg log file, or empty if it is not kept
+	reorgLogPath string
+
+	// stopGC stops the value log garbage collection started by Open, and
+	// gcDone is closed once it stopped
+	stopGC chan struct{}
+	gcDone chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // Iterator is a structure used to iterate over
@@ -25,59 +126,298 @@ type Iterator struct {
 	DB          *badger.DB
 }
 
-// InitBlockChain initializes a new BlockChain with an initial Genesis block or
-// if the blockchain already exists, loads the prevHash.
-func InitBlockChain(address string) *BlockChain {
-	var prevHash []byte
-	dbPath := os.Getenv("DB_PATH")
+// Config is the configuration used to open a BlockChain.
+type Config struct {
+
+	// Path is the directory of the database, which is created if needed.
+	Path string
+
+	// Genesis describes the genesis block of the network. A database
+	// holding a chain with a different genesis block is refused.
+	Genesis *Genesis
+
+	// GenesisAddress receives the reward of the genesis block when a new
+	// chain is created without a Genesis configuration.
+	GenesisAddress string
+
+	// Network gives the genesis difficulty and difficulty rules of chains
+	// without a Genesis configuration. It is the main network if nil.
+	Network *NetworkParams
+
+	// PruneDepth, if set, is the number of recent blocks whose transactions
+	// are kept. The transactions of older blocks are deleted as new blocks
+	// are added.
+	PruneDepth int
+
+	// Events, if set, receives newBlock, newTx and reorg events as blocks
+	// are connected, transactions enter the mempool and the best chain is
+	// switched.
+	Events *events.Bus
+
+	// MiningProgress, if set, receives the progress of mining blocks added
+	// with AddBlock and MinePending.
+	MiningProgress MiningProgress
+
+	// MigrationProgress, if set, receives the progress of the migrations
+	// Open runs to bring the format of a database written by an earlier
+	// version up to date.
+	MigrationProgress MigrationProgress
+
+	// MidstateMining mines blocks by resuming SHA-256 from the state after
+	// the data before the nonce instead of hashing all of it for every
+	// nonce. The blocks mined are the same, but faster.
+	MidstateMining bool
+
+	// SpentIndex keeps an index of the transaction spending each output of
+	// the best chain, so explorers can link outputs to where they were
+	// spent. Unlike the undo records of blocks, the index is kept when
+	// blocks are pruned. Opening a chain without it removes the index.
+	SpentIndex bool
+
+	// Logger, if set, receives the status messages of the chain, such as
+	// blocks being connected and indexes being rebuilt, and the database
+	// failures it panics on. They are written to stderr by default.
+	Logger logging.Logger
+
+	// Checkpoints are blocks the best chain must pass through. Chains
+	// forking below the latest checkpoint the best chain reached are
+	// refused, and the signatures of blocks at or below the latest
+	// checkpoint are not verified, which makes syncing faster.
+	Checkpoints []Checkpoint
+
+	// FinalityDepth, if set, is the number of confirmations after which
+	// blocks of the best chain are final: side chains forking below them
+	// are refused however much work they have, so they are never
+	// reorganized away. This gives private networks a settlement guarantee,
+	// at the cost of nodes that followed a longer fork for this many blocks
+	// needing to be resynced.
+	FinalityDepth int
+
+	// PriorityKeys are public key hashes, such as those of the wallets of
+	// the node operator, whose pending transactions are placed in mined
+	// blocks before any other regardless of their fee, so they are never
+	// left out of a full block. This is a mining policy, not a consensus
+	// rule.
+	PriorityKeys [][]byte
+
+	// GCInterval, if set, is how often the value log of the database is
+	// garbage collected while the chain is open, reclaiming the space of
+	// deleted and overwritten values.
+	GCInterval time.Duration
+
+	// GCDiscardRatio is the fraction of a value log file that must be
+	// reclaimable for garbage collection to rewrite it. It is
+	// DefaultGCDiscardRatio if 0.
+	GCDiscardRatio float64
+
+	// TruncateValueLog truncates the value log at the last valid entry when
+	// the database is opened after a crash left a partial write, instead
+	// of refusing to open it.
+	TruncateValueLog bool
+
+	// NoSyncWrites doesn't wait for writes to reach the disk before
+	// committing them, which is faster but can lose the latest blocks in a
+	// crash.
+	NoSyncWrites bool
+
+	// JournalPath, if set, is a file the state transitions of the best
+	// chain are appended to, one JSON JournalEntry per line with the
+	// outputs each connected or disconnected block created and spent, so
+	// external systems can replicate the UTXO set without validating
+	// blocks. Opening a chain without it stops the journal, and it is
+	// rewritten from the genesis block when started again.
+	JournalPath string
+
+	// ReorgLogPath, if set, is a file every reorganization of the best
+	// chain is appended to, one JSON ReorgRecord per line, for operators
+	// investigating chain instability. The records are also kept in the
+	// database and returned by Reorgs.
+	ReorgLogPath string
+
+	// UTXOCacheSize, if set, is the number of unspent outputs kept in
+	// memory, so validating transactions and connecting blocks read fewer
+	// of them from the database.
+	UTXOCacheSize int
+
+	// Mempool bounds the pending transactions, which are otherwise kept
+	// until mined however many there are and however long they wait.
+	Mempool MempoolLimits
+
+	// Authority, if set, is the key of an authority of a proof of authority
+	// network, which seals the blocks added in its turn. Nodes without one
+	// can't add blocks to such networks, only accept them.
+	Authority keys.Key
+
+	// Clock, if set, gives the time blocks are mined at and transactions
+	// enter the mempool at instead of the system clock, so tests can
+	// control block timestamps, the difficulty and mempool expiry.
+	Clock func() time.Time
+
+	// ReadOnly opens the database without writing to it, so several
+	// processes can query a chain at once, or one on read-only storage.
+	// The chain must exist with indexes matching its tip, no chain is
+	// created and nothing can be added to it. The database takes a lock
+	// shared only with other read-only processes, so it still can't be
+	// opened while a process writing to it, such as a node, holds it.
+	ReadOnly bool
+}
 
-	// configure badgerDB
-	opts := badger.DefaultOptions
-	opts.Dir = dbPath
-	opts.ValueDir = dbPath
+// logger returns the logger of the configuration.
+func (cfg Config) logger() logging.Logger {
+	if cfg.Logger == nil {
+		return logging.Default
+	}
+	return cfg.Logger
+}
+
+// network returns the network parameters of the configuration.
+func (cfg Config) network() *NetworkParams {
+	if cfg.Network == nil {
+		return &MainNetParams
+	}
+	return cfg.Network
+}
+
+// NetworkName returns the name of the network described by the
+// configuration.
+func (cfg Config) NetworkName() string {
+	if cfg.Genesis != nil {
+		return cfg.Genesis.Network
+	}
+	return cfg.network().Name
+}
+
+// DifficultyRules returns the difficulty rules of the network described by
+// the configuration.
+func (cfg Config) DifficultyRules() DifficultyRules {
+	if cfg.Genesis == nil {
+		return cfg.network().Rules
+	}
+	return cfg.Genesis.DifficultyRules()
+}
+
+// SignatureScheme returns the signature scheme of the network described by
+// the configuration.
+func (cfg Config) SignatureScheme() (keys.Scheme, error) {
+	if cfg.Genesis == nil {
+		return cfg.network().scheme(), nil
+	}
+	return keys.SchemeByName(cfg.Genesis.SignatureScheme)
+}
+
+// PowHash returns the proof of work hash of the network described by the
+// configuration.
+func (cfg Config) PowHash() (PowHash, error) {
+	if cfg.Genesis == nil {
+		return cfg.network().powHash(), nil
+	}
+	return PowHashByName(cfg.Genesis.PowHash)
+}
+
+// Authorities returns the authorities of the proof of authority network
+// described by the configuration, or nil if its blocks are mined.
+func (cfg Config) Authorities() (*Authorities, error) {
+	if cfg.Genesis == nil {
+		return nil, nil
+	}
+	return cfg.Genesis.authorities()
+}
+
+// AddressVersion returns the version byte of the addresses of the chain,
+// that of the address prefix of the genesis configuration if it has one,
+// or else that of the network.
+func (cfg Config) AddressVersion() (byte, error) {
+	if cfg.Genesis == nil || cfg.Genesis.AddressPrefix == "" {
+		return cfg.network().AddressVersion, nil
+	}
+	return cfg.Genesis.addressVersion()
+}
+
+// ErrNoBlockChain is returned by Open when the database does not contain a
+// blockchain and the configuration can't create one.
+var ErrNoBlockChain = errors.New("no existing blockchain found in database")
+
+// ErrLocked is returned by Open when another process has the database open.
+var ErrLocked = errors.New("database is in use by another process")
+
+// ErrNeedsWrite is returned by Open for a read-only chain when the database
+// must be written to before it is used, because it was not closed cleanly,
+// is in the format of an earlier version or its indexes don't match its
+// tip. Opening it writable once fixes it.
+var ErrNeedsWrite = errors.New("database must be opened writable once before it can be read, such as by running getblockcount")
+
+// ErrReadOnly is returned when a block is mined or accepted on a chain
+// opened read-only.
+var ErrReadOnly = errors.New("blockchain is open read-only")
+
+// Open opens the BlockChain in the database at cfg.Path, creating it with a
+// genesis block if the database does not contain a blockchain.
+func Open(cfg Config) (*BlockChain, error) {
+	var prevHash []byte
+	var genesis *Block
+	logger := cfg.logger()
+	scheme, err := cfg.SignatureScheme()
+	if err != nil {
+		return nil, err
+	}
+	powHash, err := cfg.PowHash()
+	if err != nil {
+		return nil, err
+	}
+	authorities, err := cfg.Authorities()
+	if err != nil {
+		return nil, err
+	}
+	mining := miningOptions{progress: cfg.MiningProgress, midstate: cfg.MidstateMining, hash: powHash, authorities: authorities, authority: cfg.Authority}
+	if err := cfg.Mempool.validate(); err != nil {
+		return nil, err
+	}
 
 	// open database
-	db, err := badger.Open(opts)
+	db, err := openDB(cfg)
 	if err != nil {
-		log.Panicf("Unable to open database at path %s: %s", dbPath, err.Error())
+		return nil, err
 	}
 
 	// initiate update on the database by passing in closure
 	// update allows read and write (view allows read only)
-	err = db.Update(func(txn *badger.Txn) error {
+	update := db.Update
+	if cfg.ReadOnly {
+		update = db.View
+	}
+	err = update(func(txn *badger.Txn) error {
 
-		// check if blockchain in database
-		if _, err := txn.Get([]byte("lh")); err == badger.ErrKeyNotFound {
+		// check if blockchain in database, which a read-only chain can't
+		// create
+		if _, err := txn.Get([]byte("lh")); err == badger.ErrKeyNotFound && cfg.ReadOnly {
+			return ErrNoBlockChain
+		} else if err == badger.ErrKeyNotFound {
 
 			// blockchain was not found in db
-			fmt.Println("No existing blockchain found in database.")
-
-			// create Coinbase transaction with address
-			cbTx := CoinbaseTx(address, "Genesis Block")
-
-			// create Genesis block
-			genesis := CreateBlock([]*Transaction{cbTx}, []byte{})
-			fmt.Println("Genesis block created")
-
-			// put genesis in db with the hash as key
-			// and byte slice of block as value
-			err = txn.Set(genesis.Hash, genesis.Serialize())
-			if err != nil {
-				// return from closure with error
-				return errors.New("unable to set genesis hash - " + err.Error())
+			logger.Info("No existing blockchain found in database", "path", cfg.Path)
+
+			// create Genesis block from the genesis configuration, or
+			// with a Coinbase transaction to the genesis address
+			switch {
+			case cfg.Genesis != nil:
+				genesis = cfg.Genesis.Block()
+			case keys.ValidateAddress(cfg.GenesisAddress):
+				cbTx := CoinbaseTx(cfg.GenesisAddress, "Genesis Block", 0)
+				genesis, _ = createBlock(context.Background(), []*Transaction{cbTx}, []byte{}, 0, cfg.network().GenesisDifficulty, time.Now().Unix(), miningOptions{hash: powHash})
+			default:
+				return ErrNoBlockChain
 			}
+			logger.Info("Genesis block created", "hash", genesis.Hash)
 
-			// put genesis in db as previous hash (Hash is a byte slice)
-			// and set prevHash
-			err = txn.Set([]byte("lh"), genesis.Hash)
+			// store genesis and set prevHash
 			prevHash = genesis.Hash
 
 			// return from closure
-			return err
+			return storeGenesis(txn, genesis)
 		}
 
 		// blockchain was found in db
-		fmt.Println("Blockchain found in database.")
+		logger.Info("Blockchain found in database", "path", cfg.Path)
 
 		// get previous hash item from db
 		prevHashItem, err := txn.Get([]byte("lh"))
@@ -87,28 +427,346 @@ func InitBlockChain(address string) *BlockChain {
 		}
 
 		// set prevHash to value of prevHashItem
-		prevHash, err = prevHashItem.Value()
+		prevHash, err = prevHashItem.ValueCopy(nil)
 
 		// return from closure
 		return err
 	})
 	if err != nil {
-		log.Panicf("Unable to update database: %s", err.Error())
+		db.Close()
+		return nil, err
 	}
 
 	// create blockchain with db reference and prevHash from db
-	// and return it's reference
-	return &BlockChain{
-		PrevHash: prevHash,
-		DB:       db,
+	bc := &BlockChain{
+		tip:            prevHash,
+		DB:             db,
+		rules:          cfg.DifficultyRules(),
+		scheme:         scheme,
+		pruneDepth:     cfg.PruneDepth,
+		events:         cfg.Events,
+		mining:         mining,
+		log:            logger,
+		checkpoints:    sortCheckpoints(cfg.Checkpoints),
+		finalityDepth:  cfg.FinalityDepth,
+		priorityKeys:   cfg.PriorityKeys,
+		path:           cfg.Path,
+		gcDiscardRatio: cfg.gcDiscardRatio(),
+		journalPath:    cfg.JournalPath,
+		reorgLogPath:   cfg.ReorgLogPath,
+		mempoolLimits:  cfg.Mempool,
+		utxoCache:      newUTXOCache(cfg.UTXOCacheSize),
+		clock:          cfg.Clock,
+		readOnly:       cfg.ReadOnly,
+	}
+	if genesis != nil {
+		bc.notifyBlock(hooks.BlockConnected, genesis)
+	}
+
+	// bring the indexes up to the tip, or check they are for a read-only
+	// chain
+	if cfg.ReadOnly {
+		err = bc.checkIndexes(prevHash, cfg.SpentIndex)
+	} else {
+		err = bc.updateIndexes(prevHash, cfg)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// refuse to use a chain from a different network
+	if cfg.Genesis != nil {
+		genesis, err := bc.GetBlockByHeight(0)
+		if err != nil {
+			db.Close()
+			return nil, errors.New("unable to get genesis block - " + err.Error())
+		}
+		if !cfg.Genesis.Matches(genesis) {
+			db.Close()
+			return nil, fmt.Errorf("genesis block %x in database does not match network %s", genesis.Hash, cfg.Genesis.Network)
+		}
+	}
+
+	// collect the garbage of the value log while the chain is open
+	if cfg.GCInterval > 0 && !cfg.ReadOnly {
+		bc.startGC(cfg.GCInterval)
 	}
+
+	// return reference to blockchain
+	return bc, nil
 }
 
-// AddBlock adds a block to the receiver BlockChain.
-func (bc *BlockChain) AddBlock(transactions []*Transaction) {
-	var prevHash []byte
+// updateIndexes runs the migrations the format of the database needs,
+// rebuilds the indexes of the chain that don't match its tip prevHash, such
+// as for databases created before they were stored or a rebuild that was
+// interrupted, builds or removes the spent index and starts or stops the
+// journal as cfg asks, and prunes blocks deeper than the prune depth.
+func (bc *BlockChain) updateIndexes(prevHash []byte, cfg Config) error {
+	logger := cfg.logger()
+
+	// bring the format of the stored data up to date before the indexes
+	// are read
+	if err := bc.migrate(cfg); err != nil {
+		return err
+	}
+
+	// rebuild the UTXO set and height index if they do not match the tip,
+	// such as for databases created before they were stored, continuing an
+	// interrupted rebuild
+	tip, err := bc.utxoTip()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(tip, prevHash) || !bc.heightIndexed() {
+		logger.Info("Reindexing unspent transaction outputs")
+		if err := bc.ReindexUTXOContext(context.Background(), true, nil); err != nil {
+			return err
+		}
+	}
+
+	// rebuild the address index if it does not match the tip, such as for
+	// databases created before it was stored, continuing an interrupted
+	// rebuild
+	addrTip, err := bc.addrTip()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(addrTip, prevHash) {
+		logger.Info("Reindexing addresses")
+		if err := bc.ReindexAddressesContext(context.Background(), true, nil); err != nil {
+			return err
+		}
+	}
+
+	// rebuild the transaction index if it does not match the tip, such as
+	// for databases created before it was stored, continuing an
+	// interrupted rebuild
+	txTip, err := bc.txIndexTip()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(txTip, prevHash) {
+		logger.Info("Reindexing transactions")
+		if err := bc.ReindexTransactionsContext(context.Background(), true, nil); err != nil {
+			return err
+		}
+	}
+
+	// record the chart points again if they do not match the tip, such as
+	// for databases created before they were recorded
+	chartTip, err := bc.chartTip()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(chartTip, prevHash) {
+		logger.Info("Reindexing charts")
+		if err := bc.ReindexCharts(); err != nil {
+			return err
+		}
+	}
+
+	// record the running totals of the chain again if they do not match the
+	// tip, such as for databases created before they were recorded
+	statsTip, err := bc.statsTip()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(statsTip, prevHash) {
+		logger.Info("Reindexing chain stats")
+		if err := bc.ReindexStats(); err != nil {
+			return err
+		}
+	}
+
+	// build the spent index if it is wanted and does not match the tip, or
+	// remove it if it is no longer wanted
+	spentTip, err := bc.spentIndexTip()
+	if err != nil {
+		return err
+	}
+	if cfg.SpentIndex && !bytes.Equal(spentTip, prevHash) {
+		logger.Info("Reindexing spent outputs")
+		if err := bc.ReindexSpends(); err != nil {
+			return err
+		}
+	} else if !cfg.SpentIndex && spentTip != nil {
+		logger.Info("Removing spent output index")
+		if err := bc.dropSpentIndex(); err != nil {
+			return err
+		}
+	}
+
+	// start or stop the journal, appending the entries a crash left
+	// pending to the file
+	if cfg.JournalPath != "" {
+		if err := bc.startJournal(); err != nil {
+			return errors.New("unable to start journal - " + err.Error())
+		}
+		if err := bc.flushJournal(); err != nil {
+			return err
+		}
+	} else if err := bc.dropJournal(); err != nil {
+		return err
+	}
+
+	// find the side chain tips of databases created before they were
+	// recorded
+	if err := bc.indexForkTips(); err != nil {
+		return errors.New("unable to index side chain tips - " + err.Error())
+	}
+
+	// prune blocks that are now deeper than the prune depth
+	return bc.prune()
+}
+
+// checkIndexes returns ErrNeedsWrite unless the database of a read-only
+// chain is in the current format and its indexes match its tip prevHash,
+// including the spent index if spentIndex is set, so queries never read
+// stale indexes. It returns ErrNewerSchema for a database in a later format.
+func (bc *BlockChain) checkIndexes(prevHash []byte, spentIndex bool) error {
+	if err := bc.checkSchema(); err != nil {
+		return err
+	}
+	tips := []func() ([]byte, error){bc.utxoTip, bc.addrTip, bc.txIndexTip, bc.chartTip, bc.statsTip}
+	if spentIndex {
+		tips = append(tips, bc.spentIndexTip)
+	}
+	for _, indexTip := range tips {
+		tip, err := indexTip()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(tip, prevHash) {
+			return ErrNeedsWrite
+		}
+	}
+	if !bc.heightIndexed() {
+		return ErrNeedsWrite
+	}
+	if indexed, err := bc.forkTipsIndexed(); err != nil {
+		return err
+	} else if !indexed {
+		return ErrNeedsWrite
+	}
+	return nil
+}
+
+// openDB opens the badger database at cfg.Path with the database options
+// of cfg, creating the directory if it does not exist unless the database
+// is opened read-only.
+func openDB(cfg Config) (*badger.DB, error) {
+	dbPath := cfg.Path
+	if cfg.ReadOnly {
+		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+			return nil, ErrNoBlockChain
+		}
+	} else if err := os.MkdirAll(dbPath, os.ModePerm); err != nil {
+		return nil, errors.New("unable to create database directory - " + err.Error())
+	}
+
+	// configure badgerDB
+	opts := badger.DefaultOptions
+	opts.Dir = dbPath
+	opts.ValueDir = dbPath
+	opts.Truncate = cfg.TruncateValueLog
+	opts.SyncWrites = !cfg.NoSyncWrites
+	opts.ReadOnly = cfg.ReadOnly
+
+	// open database, which fails while another process holds its lock
+	db, err := badger.Open(opts)
+	if err != nil && strings.Contains(err.Error(), "Another process is using this Badger database") {
+		return nil, ErrLocked
+	} else if err == badger.ErrReplayNeeded {
+		return nil, ErrNeedsWrite
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to open database at path %s - %s", dbPath, err.Error())
+	}
+
+	// return database
+	return db, nil
+}
 
-	// initiate read-only transaction on db to get previous hash from db
+// storeGenesis stores the genesis block as the tip of a new chain.
+func storeGenesis(txn *badger.Txn, genesis *Block) error {
+
+	// put genesis in db with the hash as key
+	// and byte slice of block as value
+	err := putBlock(txn, genesis)
+	if err != nil {
+		return errors.New("unable to set genesis hash - " + err.Error())
+	}
+
+	// add genesis outputs to the UTXO set and store its chain work
+	err = connectBlock(newUTXOView(txn, nil), genesis)
+	if err != nil {
+		return errors.New("unable to connect genesis block - " + err.Error())
+	}
+	err = setChainWork(txn, genesis)
+	if err != nil {
+		return errors.New("unable to set genesis chain work - " + err.Error())
+	}
+
+	// a new database is in the current format
+	err = setSchemaVersion(txn, SchemaVersion)
+	if err != nil {
+		return errors.New("unable to set schema version - " + err.Error())
+	}
+
+	// put genesis in db as previous hash (Hash is a byte slice)
+	return txn.Set([]byte("lh"), genesis.Hash)
+}
+
+// panicf logs a database failure at the error level and panics with it.
+// Failures of the database are not expected, so methods that don't return
+// errors panic on them, and callers that must survive them can recover.
+func (bc *BlockChain) panicf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	bc.log.Error(msg)
+	panic(msg)
+}
+
+// Close flushes pending writes to disk and closes the database, removing
+// the database of a chain created by InitInMemory. It is safe to call more
+// than once, and every call returns the result of the first.
+func (bc *BlockChain) Close() error {
+	bc.closeOnce.Do(func() {
+		bc.stopGarbageCollection()
+		bc.closeErr = bc.DB.Close()
+		if bc.temporary {
+			os.RemoveAll(bc.path)
+		}
+	})
+	return bc.closeErr
+}
+
+// AddBlock adds a block to the receiver BlockChain and returns a reference
+// to the new block. Any included transactions are removed from the mempool.
+// ErrChainFrozen is returned if the chain is frozen, ErrReadOnly if it was
+// opened read-only, and ErrTipChanged if another block became the tip while
+// the block was mined.
+func (bc *BlockChain) AddBlock(transactions []*Transaction) (*Block, error) {
+
+	// mining can't be cancelled without a deadline or cancel func
+	return bc.AddBlockContext(context.Background(), transactions)
+}
+
+// AddBlockContext adds a block like AddBlock, returning ErrMiningCancelled
+// without changing the chain if ctx is done before the block is mined.
+func (bc *BlockChain) AddBlockContext(ctx context.Context, transactions []*Transaction) (*Block, error) {
+	var prevBlock *Block
+
+	// don't start mining a block that can't be stored
+	if bc.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	// don't start mining while the chain is frozen
+	if _, frozen := bc.Frozen(); frozen {
+		return nil, ErrChainFrozen
+	}
+
+	// initiate read-only transaction on db to get previous block from db
 	err := bc.DB.View(func(txn *badger.Txn) error {
 
 		// get previous hash item from db
@@ -118,47 +776,125 @@ func (bc *BlockChain) AddBlock(transactions []*Transaction) {
 			return errors.New("unable to get previous hash item - " + err.Error())
 		}
 
-		// set prevHash to value of prevHashItem
-		prevHash, err = prevHashItem.Value()
+		// get value of prevHashItem
+		prevHash, err := prevHashItem.Value()
+		if err != nil {
+			// return from closure with error
+			return errors.New("unable to get value from previous hash item - " + err.Error())
+		}
+
+		// get previous block
+		prevBlock, err = getBlock(txn, prevHash)
 
 		// return from closure
 		return err
 	})
 	if err != nil {
-		log.Panicf("Unable to read previous hash from database: %s", err.Error())
+		bc.panicf("Unable to read previous block from database: %s", err.Error())
 	}
 
-	// create new block with previous hash and data
-	newBlock := CreateBlock(transactions, prevHash)
+	// create new block on top of the previous block with data
+	timestamp := bc.now().Unix()
+	difficulty := bc.NextDifficulty(prevBlock, timestamp)
+	newBlock, err := createBlock(ctx, transactions, prevBlock.Hash, prevBlock.Height+1, difficulty, timestamp, bc.mining)
+	if err != nil {
+		return nil, err
+	}
+
+	// refuse the block if another block became the tip while mining
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	if !bytes.Equal(bc.Tip(), prevBlock.Hash) {
+		return nil, ErrTipChanged
+	}
 
 	// initiate rw transaction on db to insert newBlock
+	var view *utxoView
 	err = bc.DB.Update(func(txn *badger.Txn) error {
+		view = newUTXOView(txn, bc.utxoCache)
+
+		// refuse the block if the chain was frozen while mining
+		if err := checkFrozen(txn); err != nil {
+			return err
+		}
 
 		// put newBlock in db with the hash as key
 		// and byte slice of block as value
-		err = txn.Set(newBlock.Hash, newBlock.Serialize())
+		start := time.Now()
+		data := newBlock.Serialize()
+		err = txn.Set(newBlock.Hash, data)
 		if err != nil {
 			// return from closure with error
 			return errors.New("unable to set newBlock hash - " + err.Error())
 		}
 
+		// update the UTXO set and store the chain work of newBlock
+		err = connectBlock(view, newBlock)
+		if err != nil {
+			// return from closure with error
+			return errors.New("unable to connect newBlock - " + err.Error())
+		}
+		err = journalBlock(txn, JournalConnect, newBlock)
+		if err != nil {
+			// return from closure with error
+			return errors.New("unable to journal newBlock - " + err.Error())
+		}
+		err = setChainWork(txn, newBlock)
+		if err != nil {
+			// return from closure with error
+			return errors.New("unable to set newBlock chain work - " + err.Error())
+		}
+		err = recordMetrics(txn, newBlock, len(data), time.Since(start))
+		if err != nil {
+			// return from closure with error
+			return errors.New("unable to record newBlock metrics - " + err.Error())
+		}
+
 		// put newBlock in db as previous hash (Hash is a byte slice)
-		// and set blockchain PrevHash
 		err = txn.Set([]byte("lh"), newBlock.Hash)
-		bc.PrevHash = newBlock.Hash
+		if err != nil {
+			// return from closure with error
+			return errors.New("unable to set last hash - " + err.Error())
+		}
+
+		// remove mined transactions from the mempool
+		for _, tx := range transactions {
+			err = deleteFromMempool(txn, tx.ID)
+			if err != nil {
+				// return from closure with error
+				return errors.New("unable to remove transaction from mempool - " + err.Error())
+			}
+		}
 
 		// return from closure
-		return err
+		return nil
 	})
-	if err != nil {
-		log.Panicf("Unable to update database with new block: %s", err.Error())
+	if err == ErrChainFrozen {
+		return nil, err
+	} else if err != nil {
+		bc.panicf("Unable to update database with new block: %s", err.Error())
+	}
+	view.commit()
+	bc.setTip(newBlock.Hash)
+	if err := bc.flushJournal(); err != nil {
+		bc.panicf("Unable to append new block to journal: %s", err.Error())
 	}
+	bc.feeHistogram.remove(newBlock.Transactions)
+	bc.notifyBlock(hooks.BlockConnected, newBlock)
+
+	// prune blocks that are now deeper than the prune depth
+	if err := bc.prune(); err != nil {
+		bc.panicf("Unable to prune blocks: %s", err.Error())
+	}
+
+	// return reference to the new block
+	return newBlock, nil
 }
 
 // NewIterator initializes and returns a reference to a
 // new blockchain Iterator from a BlockChain.
 func (bc *BlockChain) NewIterator() *Iterator {
-	return &Iterator{bc.PrevHash, bc.DB}
+	return &Iterator{bc.Tip(), bc.DB}
 }
 
 // Next returns the next Block in a blockchain Iterator (order is reversed).
@@ -168,24 +904,9 @@ func (iter *Iterator) Next() *Block {
 	// initiate read only transaction on db to get next block
 	err := iter.DB.View(func(txn *badger.Txn) error {
 
-		// get current hash item from db
-		nextItem, err := txn.Get(iter.CurrentHash)
-		if err != nil {
-			// return from closure with error
-			return errors.New("unable to get next hash item - " + err.Error())
-		}
-
-		// get byte slice value from nextItem
-		encodedBlock, err := nextItem.Value()
-		if err != nil {
-			// return from closure with error
-			return errors.New("unable to get value from nextItem - " + err.Error())
-		}
-
-		// deserialize encodedBlock into a new Block
-		block = Deserialize(encodedBlock)
-
-		// return from closure
+		// get the current block from db
+		var err error
+		block, err = getBlock(txn, iter.CurrentHash)
 		return err
 	})
 	if err != nil {
@@ -199,32 +920,55 @@ func (iter *Iterator) Next() *Block {
 	return block
 }
 
-// FindTransaction finds a transaction in the Blockchain by ID.
+// FindTransaction finds a transaction in the mempool or Blockchain by ID,
+// looking confirmed transactions up in the transaction index.
 func (bc *BlockChain) FindTransaction(ID []byte) (Transaction, error) {
-	iter := bc.NewIterator()
-
-	// iterate over blocks
-	for {
-		block := iter.Next()
+	var pending []byte
 
-		// iterate through transactions for current block
-		for _, tx := range block.Transactions {
-			if bytes.Compare(tx.ID, ID) == 0 {
-				return *tx, nil
-			}
+	// initiate read only transaction on db to look for a pending transaction
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(mempoolKey(ID))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
 		}
+		pending, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		bc.panicf("Unable to read mempool from database: %s", err.Error())
+	}
+	if pending != nil {
+		return DeserializeTransaction(pending), nil
+	}
 
-		if len(block.PrevHash) == 0 {
-			break
-		}
+	// look the transaction up in the transaction index
+	var tx *Transaction
+	err = bc.DB.View(func(txn *badger.Txn) error {
+		var err error
+		tx, _, err = indexedTransaction(txn, ID)
+		return err
+	})
+	if err != nil {
+		return Transaction{}, errors.New("unable to read transaction index - " + err.Error())
+	}
+	if tx != nil {
+		return *tx, nil
+	}
+
+	// rebuild transactions in pruned blocks from their unspent outputs
+	if tx, ok := bc.unspentTransaction(ID); ok {
+		return tx, nil
 	}
 
 	// return empty transaction and error if transaction was not found
 	return Transaction{}, errors.New("transaction does not exist")
 }
 
-// SignTransaction signs a blockchain Transaction.
-func (bc *BlockChain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) {
+// SignTransaction signs a blockchain Transaction with signer, such as a
+// wallet or a hardware wallet holding the key of the outputs it spends.
+func (bc *BlockChain) SignTransaction(tx *Transaction, signer keys.Signer) error {
 	prevTXs := make(map[string]Transaction)
 
 	// iterate over TxInputs in Transaction and populate
@@ -232,17 +976,38 @@ func (bc *BlockChain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey)
 	for _, in := range tx.Inputs {
 		prevTX, err := bc.FindTransaction(in.ID)
 		if err != nil {
-			log.Panicln("Unable to sign blockchain transaction: ", err.Error())
+			bc.panicf("Unable to sign blockchain transaction: %s", err.Error())
 		}
 		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
 	}
 
 	// sign Transaction using Transaction method
-	tx.Sign(privKey, prevTXs)
+	return tx.Sign(signer, prevTXs)
+}
+
+// VerifyTransaction verifies the signatures of a Transaction against the
+// outputs it spends, returning an error if any of them can't be found.
+func (bc *BlockChain) VerifyTransaction(tx *Transaction) (bool, error) {
+
+	// return true for a Coinbase Transaction, which spends nothing
+	if tx.IsCoinbase() {
+		return true, nil
+	}
+
+	prevTXs, err := bc.prevTransactions(tx)
+	if err != nil {
+		return false, err
+	}
+
+	// verify Transaction using Transaction method
+	// and return result
+	return tx.Verify(bc.scheme, prevTXs), nil
 }
 
-// VerifyTransaction verifies a Transaction on the blockchain.
-func (bc *BlockChain) VerifyTransaction(tx *Transaction) bool {
+// prevTransactions finds the transactions whose outputs are spent by the
+// inputs of a Transaction, keyed by hex id, ensuring each spent output
+// exists.
+func (bc *BlockChain) prevTransactions(tx *Transaction) (map[string]Transaction, error) {
 	prevTXs := make(map[string]Transaction)
 
 	// iterate over TxInputs in Transaction and populate
@@ -250,66 +1015,99 @@ func (bc *BlockChain) VerifyTransaction(tx *Transaction) bool {
 	for _, in := range tx.Inputs {
 		prevTX, err := bc.FindTransaction(in.ID)
 		if err != nil {
-			log.Panicln("Unable to verify blockchain transaction: ", err.Error())
+			return nil, fmt.Errorf("unable to find output %s - %s", outpoint(in.ID, in.Out), err.Error())
+		}
+		if in.Out < 0 || in.Out >= len(prevTX.Outputs) {
+			return nil, fmt.Errorf("transaction %x has no output %d", in.ID, in.Out)
 		}
 		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
 	}
 
-	// verify Transaction using Transaction method
-	// and return result
-	return tx.Verify(prevTXs)
+	return prevTXs, nil
 }
 
-// FindUnspentTransactions determines how many tokens an address has by
-// finding transactions that have outputs which are not referenced
-// by other inputs.
-func (bc *BlockChain) FindUnspentTransactions(pubKeyHash []byte) []Transaction {
-	var unspentTxs []Transaction
-	spentTxOutputs := make(map[string][]int)
+// Confirmations returns how many blocks deep a transaction is buried, where
+// a transaction in the last block has one confirmation. The height of the
+// transaction comes from the address index, so transactions in pruned
+// blocks are counted too. Pending and unknown transactions, and coinbase
+// transactions without outputs, have zero confirmations.
+func (bc *BlockChain) Confirmations(txID Hash) int {
+	var height int
+	found := false
+
+	// initiate read only transaction on db to look up the transaction
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		var err error
+		height, found, err = indexedHeight(txn, txID.Bytes())
+		return err
+	})
+	if err != nil {
+		bc.panicf("Unable to read address index from database: %s", err.Error())
+	}
+	if !found {
+		return 0
+	}
+
+	return bc.Height() - height + 1
+}
+
+// TransactionTime returns when a transaction happened as a timestamp in
+// unix seconds: the timestamp of its block if it is confirmed, or when it
+// entered the mempool if it is pending. Like Confirmations it finds the
+// transactions of pruned blocks too, and it returns false for unknown
+// ones.
+func (bc *BlockChain) TransactionTime(txID Hash) (int64, bool, error) {
+	var height int
+	var entered []byte
+	found := false
+
+	// initiate read only transaction on db to look up the transaction
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		var err error
+		height, found, err = indexedHeight(txn, txID.Bytes())
+		if err != nil || found {
+			return err
+		}
+		item, err := txn.Get(mempoolTimeKey(txID.Bytes()))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		entered, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return 0, false, errors.New("unable to look up transaction time - " + err.Error())
+	}
+	if entered != nil {
+		return FromBytes(entered), true, nil
+	}
+	if !found {
+		return 0, false, nil
+	}
+
+	block, err := bc.GetBlockByHeight(height)
+	if err != nil {
+		return 0, false, err
+	}
+	return block.Timestamp, true, nil
+}
 
+// FindPayments finds confirmed transactions with outputs that can be
+// unlocked by pubKeyHash, newest first.
+func (bc *BlockChain) FindPayments(pubKeyHash []byte) []Transaction {
+	var payments []Transaction
 	iter := bc.NewIterator()
 
 	// iterate over blocks
 	for {
 		block := iter.Next()
 
-		// iterate over transactions for current block
+		// add each transaction paying to pubKeyHash
 		for _, tx := range block.Transactions {
-			txID := hex.EncodeToString(tx.ID)
-
-		Outputs: // a label to continue from
-			// iterate over outputs for current Transaction
-			for outIdx, out := range tx.Outputs {
-				// check if current output inside spentTxOutputs
-				if spentTxOutputs[txID] != nil {
-					for _, spentOut := range spentTxOutputs[txID] {
-						if spentOut == outIdx {
-							// current output is spent, continue
-							continue Outputs
-						}
-					}
-				}
-				// if transaction is unspent and can be unlocked by
-				// address, add it to unspentTxs
-				if out.IsLockedWithKey(pubKeyHash) {
-					unspentTxs = append(unspentTxs, *tx)
-				}
-			}
-			// LABEL END - Outputs
-
-			// if transaction is not coinbase tx, find other
-			// transactions that are referenced by inputs
-			// that can be unlocked by the address
-			if tx.IsCoinbase() == false {
-				// iterate over inputs
-				for _, in := range tx.Inputs {
-					if in.UsesKey(pubKeyHash) {
-						// if address can unlock the output referenced
-						// by the input, add the tx to spentTXOutputs
-						inTxID := hex.EncodeToString(in.ID)
-						spentTxOutputs[inTxID] = append(spentTxOutputs[inTxID], in.Out)
-					}
-				}
+			if tx.ValueTo(pubKeyHash) > 0 {
+				payments = append(payments, *tx)
 			}
 		}
 
@@ -319,65 +1117,104 @@ func (bc *BlockChain) FindUnspentTransactions(pubKeyHash []byte) []Transaction {
 		}
 	}
 
-	// return unspent transactions
-	return unspentTxs
+	// return payments
+	return payments
+}
+
+// TransactionFee returns the fee of a Transaction, which is the value of
+// the outputs it spends minus the value of its own outputs. An error is
+// returned if a spent output can't be found.
+func (bc *BlockChain) TransactionFee(tx *Transaction) (units.Amount, error) {
+
+	// coinbase transactions do not pay fees
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	prevTXs, err := bc.prevTransactions(tx)
+	if err != nil {
+		return 0, err
+	}
+	var fee units.Amount
+
+	// add the value of each spent output
+	for _, in := range tx.Inputs {
+		fee += prevTXs[hex.EncodeToString(in.ID)].Outputs[in.Out].Value
+	}
+
+	// subtract the value of each output
+	for _, out := range tx.Outputs {
+		fee -= out.Value
+	}
+
+	// return inputs minus outputs
+	return fee, nil
 }
 
 // FindUnspentTxOutputs finds all unspent transaction outputs that
 // correspond to an address.
-func (bc *BlockChain) FindUnspentTxOutputs(pubKeyHash []byte) []TxOutput {
+func (bc *BlockChain) FindUnspentTxOutputs(pubKeyHash []byte) ([]TxOutput, error) {
 	var unspentTxOutputs []TxOutput
 
-	// get unspent transactions
-	unspentTxs := bc.FindUnspentTransactions(pubKeyHash)
-
-	// iterate over unspent transactions
-	for _, tx := range unspentTxs {
-		// iterate over outputs for current tx
-		for _, out := range tx.Outputs {
-			// if the output can be unlocked by the address,
-			// add it to unspentTxOutputs
-			if out.IsLockedWithKey(pubKeyHash) {
-				unspentTxOutputs = append(unspentTxOutputs, out)
-			}
-		}
+	unspentOutputs, err := bc.FindUnspentOutputs(pubKeyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect the output of each unspent output
+	for _, unspent := range unspentOutputs {
+		unspentTxOutputs = append(unspentTxOutputs, unspent.Output)
 	}
 
 	// return unspent transaction outputs
-	return unspentTxOutputs
+	return unspentTxOutputs, nil
 }
 
 // FindSpendableOutputs ensures enough tokens exists in unspent transaction
-// outputs to cover the amount.
-func (bc *BlockChain) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int) {
+// outputs to cover the amount. Locked outputs are never selected.
+func (bc *BlockChain) FindSpendableOutputs(pubKeyHash []byte, amount units.Amount) (units.Amount, map[string][]int, error) {
 	spendableOutputs := make(map[string][]int)
-	unspentTxs := bc.FindUnspentTransactions(pubKeyHash)
-	accumulated := 0
-
-Work: // a label to continue from
-	// iterate over unspent transactions
-	for _, tx := range unspentTxs {
-		txID := hex.EncodeToString(tx.ID)
-
-		// iterate over outputs for current tx
-		for outIdx, out := range tx.Outputs {
-			// if output can be unlocked by address and accumulated is less
-			// than amount, increment accumulated by out value and add
-			// tx to spendableOutputs
-			if out.IsLockedWithKey(pubKeyHash) && accumulated < amount {
-				accumulated += out.Value
-				spendableOutputs[txID] = append(spendableOutputs[txID], outIdx)
-
-				// once accumulated reaches or exceeds the amount, we have found
-				// enough spendable outputs and can break
-				if accumulated >= amount {
-					break Work
-				}
+	lockedOutputs := bc.LockedOutputs()
+	var accumulated units.Amount
+
+	unspentOutputs, err := bc.FindUnspentOutputs(pubKeyHash)
+	if err != nil {
+		return 0, nil, err
+	}
+	tokens, err := bc.tokenOutputs(unspentOutputs)
+	if err != nil {
+		return 0, nil, err
+	}
+
+Outputs: // a label to continue from
+	// iterate over unspent outputs
+	for _, unspent := range unspentOutputs {
+		txID := hex.EncodeToString(unspent.TxID)
+
+		// skip outputs locked by lockunspent
+		for _, lockedOut := range lockedOutputs[txID] {
+			if lockedOut == unspent.Out {
+				continue Outputs
 			}
+		}
+
+		// skip outputs carrying tokens, which only token transfers spend
+		if _, ok := tokens[outpoint(unspent.TxID, unspent.Out)]; ok {
+			continue
+		}
 
+		// increment accumulated by out value and add output to
+		// spendableOutputs
+		accumulated += unspent.Output.Value
+		spendableOutputs[txID] = append(spendableOutputs[txID], unspent.Out)
+
+		// once accumulated reaches or exceeds the amount, we have found
+		// enough spendable outputs and can break
+		if accumulated >= amount {
+			break
 		}
 	}
 
 	// return accumulated amount and spendable outputs
-	return accumulated, spendableOutputs
+	return accumulated, spendableOutputs, nil
 }
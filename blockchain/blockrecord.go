@@ -0,0 +1,211 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/dgraph-io/badger"
+)
+
+// blockRecordMagic starts the db records of blocks stored with a checksum.
+// It is followed by the CRC-32C of the serialized block and the serialized
+// block. Records of blocks stored by earlier versions have no checksum.
+var blockRecordMagic = []byte("gcb1")
+
+// crcTable is the Castagnoli table used for the checksums of block records.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// CorruptBlockError is returned when reading a block record whose contents
+// changed since it was stored, such as from disk corruption. The block can
+// be restored with RepairBlock.
+type CorruptBlockError struct {
+	Hash   []byte
+	Reason string
+}
+
+// Error describes the corrupt record.
+func (e *CorruptBlockError) Error() string {
+	return fmt.Sprintf("block record %x is corrupt - %s", e.Hash, e.Reason)
+}
+
+// blockRecord returns the db record of a block serialized into data.
+func blockRecord(data []byte) []byte {
+	record := make([]byte, len(blockRecordMagic)+4, len(blockRecordMagic)+4+len(data))
+	copy(record, blockRecordMagic)
+	binary.BigEndian.PutUint32(record[len(blockRecordMagic):], crc32.Checksum(data, crcTable))
+	return append(record, data...)
+}
+
+// recordData returns the serialized block of a db record, which follows the
+// checksum of records that have one.
+func recordData(record []byte) []byte {
+	if bytes.HasPrefix(record, blockRecordMagic) && len(record) >= len(blockRecordMagic)+4 {
+		return record[len(blockRecordMagic)+4:]
+	}
+	return record
+}
+
+// decodeBlockRecord decodes the db record of the block with hash, verifying
+// its checksum and that it holds the block with that hash.
+func decodeBlockRecord(hash, record []byte) (*Block, error) {
+	data := record
+	if bytes.HasPrefix(record, blockRecordMagic) {
+		if len(record) < len(blockRecordMagic)+4 {
+			return nil, &CorruptBlockError{hash, "record is truncated"}
+		}
+		sum := binary.BigEndian.Uint32(record[len(blockRecordMagic):])
+		data = record[len(blockRecordMagic)+4:]
+		if crc32.Checksum(data, crcTable) != sum {
+			return nil, &CorruptBlockError{hash, "checksum does not match"}
+		}
+	}
+
+	block, err := DecodeBlock(data)
+	if err != nil {
+		return nil, &CorruptBlockError{hash, err.Error()}
+	}
+	if !bytes.Equal(block.Hash, hash) {
+		return nil, &CorruptBlockError{hash, fmt.Sprintf("record holds block %x", block.Hash)}
+	}
+	return block, nil
+}
+
+// putBlock stores the record of a block in txn.
+func putBlock(txn *badger.Txn, block *Block) error {
+	return txn.Set(block.Hash, blockRecord(block.Serialize()))
+}
+
+// CorruptBlocks returns the hashes of the stored blocks whose records are
+// corrupt.
+func (bc *BlockChain) CorruptBlocks() ([][]byte, error) {
+	var corrupt [][]byte
+
+	// every stored block has its chain work recorded, so the blocks are
+	// found from the work keys
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(workPrefix); it.ValidForPrefix(workPrefix); it.Next() {
+			hash := append([]byte{}, bytes.TrimPrefix(it.Item().Key(), workPrefix)...)
+			_, err := getBlock(txn, hash)
+			if _, ok := err.(*CorruptBlockError); ok || err == badger.ErrKeyNotFound {
+				corrupt = append(corrupt, hash)
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return corrupt, err
+}
+
+// MigrateBlocks rewrites the records of the stored blocks that earlier
+// versions serialized with gob in the protobuf format of Serialize, so tools
+// outside Go can decode every block in the database, and returns how many
+// were rewritten. Blocks in either format are read, so chains work without
+// migrating. Corrupt records are left for RepairBlock. Open rewrites them
+// as the first schema migration, so only blocks stored since, such as by an
+// earlier version sharing the database, are left to rewrite.
+func (bc *BlockChain) MigrateBlocks() (int, error) {
+	return bc.migrateBlocks((*badger.Txn).Set, nil)
+}
+
+// migrateBlocks rewrites the gob records of blocks with set, reporting the
+// progress to progress if it is not nil.
+func (bc *BlockChain) migrateBlocks(set func(txn *badger.Txn, key, value []byte) error, progress func(JobProgress)) (int, error) {
+	var legacy [][]byte
+
+	// find the blocks from the work keys, as CorruptBlocks does
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(workPrefix); it.ValidForPrefix(workPrefix); it.Next() {
+			hash := bytes.TrimPrefix(it.Item().Key(), workPrefix)
+			item, err := txn.Get(hash)
+			if err == badger.ErrKeyNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			record, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if legacyBlockFormat(recordData(record)) {
+				legacy = append(legacy, append([]byte{}, hash...))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.New("unable to find blocks to migrate - " + err.Error())
+	}
+
+	// rewrite each block in its own db transaction
+	migrated := 0
+	for i, hash := range legacy {
+		err := bc.DB.Update(func(txn *badger.Txn) error {
+			block, err := getBlock(txn, hash)
+			if _, ok := err.(*CorruptBlockError); ok {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := set(txn, block.Hash, blockRecord(block.Serialize())); err != nil {
+				return err
+			}
+			migrated++
+			return nil
+		})
+		if err != nil {
+			return migrated, fmt.Errorf("unable to migrate block %x - %s", hash, err.Error())
+		}
+		if progress != nil && ((i+1)%100 == 0 || i+1 == len(legacy)) {
+			progress(JobProgress{Done: i + 1, Total: len(legacy), Last: hash})
+		}
+	}
+
+	return migrated, nil
+}
+
+// RepairBlock replaces the record of a stored block with a copy of the
+// block, such as one from a chain export, which must match its hash. The
+// transactions of a block that was pruned are pruned from the copy.
+func (bc *BlockChain) RepairBlock(block *Block) error {
+	if err := checkProof(block, bc.mining.authorities); err != nil {
+		return err
+	}
+	prunedHeight, err := bc.prunedHeight()
+	if err != nil {
+		return err
+	}
+
+	return bc.DB.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(workKey(block.Hash)); err != nil {
+			return fmt.Errorf("block %x is not stored", block.Hash)
+		}
+
+		// blocks of the best chain below the pruned height stay pruned
+		if !block.Pruned() && block.Height > 0 && block.Height < prunedHeight {
+			item, err := txn.Get(heightKey(block.Height))
+			if err != nil && err != badger.ErrKeyNotFound {
+				return err
+			}
+			if err == nil {
+				best, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				if bytes.Equal(best, block.Hash) {
+					block.MerkleRoot = block.HashTransactions()
+					block.Transactions = nil
+				}
+			}
+		}
+		return putBlock(txn, block)
+	})
+}
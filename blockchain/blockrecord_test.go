@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+)
+
+// corruptRecord flips a byte near the end of the stored record of a block.
+func corruptRecord(t *testing.T, bc *BlockChain, hash []byte) {
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(hash)
+		if err != nil {
+			return err
+		}
+		record, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		record[len(record)-2] ^= 0xff
+		return txn.Set(hash, record)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCorruptBlockRecord(t *testing.T) {
+	bc := newTestChain(t)
+	block := mineOn(t, bc, tip(t, bc), carol)
+	if err := bc.AcceptBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	var export bytes.Buffer
+	if _, err := bc.ExportChain(&export); err != nil {
+		t.Fatal(err)
+	}
+
+	// a corrupt record is reported instead of decoded
+	corruptRecord(t, bc, block.Hash)
+	if _, err := bc.GetBlock(block.Hash); err == nil {
+		t.Fatal("got no error reading a corrupt block")
+	} else if _, ok := err.(*CorruptBlockError); !ok {
+		t.Fatalf("got %v, want a CorruptBlockError", err)
+	}
+	if _, err := bc.VerifyChain(0); err == nil {
+		t.Fatal("verified a chain with a corrupt block")
+	}
+	corrupt, err := bc.CorruptBlocks()
+	if err != nil || len(corrupt) != 1 || !bytes.Equal(corrupt[0], block.Hash) {
+		t.Fatalf("got corrupt blocks %x, %v, want only %x", corrupt, err, block.Hash)
+	}
+
+	// the export restores it
+	repaired, unrepaired, err := bc.RepairFromExport(&export)
+	if err != nil || repaired != 1 || len(unrepaired) != 0 {
+		t.Fatalf("got %d repaired, %x unrepaired, %v", repaired, unrepaired, err)
+	}
+	if _, err := bc.VerifyChain(0); err != nil {
+		t.Fatal(err)
+	}
+	if corrupt, err := bc.CorruptBlocks(); err != nil || len(corrupt) != 0 {
+		t.Fatalf("got corrupt blocks %x, %v after repair", corrupt, err)
+	}
+
+	// a block that is not stored can't be repaired
+	other := mineOn(t, bc, block, carol)
+	if err := bc.RepairBlock(other); err == nil {
+		t.Fatal("repaired a block that is not stored")
+	}
+}
+
+func TestLegacyBlockRecord(t *testing.T) {
+	bc := newTestChain(t)
+	block := tip(t, bc)
+
+	// records stored without a checksum still read
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(block.Hash, block.Serialize())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := bc.GetBlock(block.Hash)
+	if err != nil || !bytes.Equal(got.Hash, block.Hash) {
+		t.Fatalf("got %v, %v reading a legacy record", got, err)
+	}
+}
@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// BlockCandidate is the block the miner would assemble from the mempool on
+// the tip right now, so the packing policy can be audited without mining.
+type BlockCandidate struct {
+	Height     int    `json:"height"`
+	PrevHash   string `json:"prevHash"`
+	Difficulty int    `json:"difficulty"`
+
+	// Transactions are the pending transactions placed in the block after
+	// the coinbase, in block order, and Excluded those left in the mempool
+	// because they don't fit or spend one that doesn't
+	Transactions []CandidateTransaction `json:"transactions"`
+	Excluded     []CandidateTransaction `json:"excluded"`
+
+	// Size is the size of the block's transactions including the coinbase
+	Size int `json:"size"`
+
+	// Fees is the sum of the fees of the block's transactions, and Reward
+	// what the coinbase pays, the fees plus the subsidy
+	Fees    units.Amount `json:"fees"`
+	Subsidy units.Amount `json:"subsidy"`
+	Reward  units.Amount `json:"reward"`
+}
+
+// CandidateTransaction is a pending transaction of a BlockCandidate.
+// FeeRate is its fee in base units per byte, and Priority is set if it was
+// moved to the front for a priority key.
+type CandidateTransaction struct {
+	TxID     string       `json:"txid"`
+	Fee      units.Amount `json:"fee"`
+	Size     int          `json:"size"`
+	FeeRate  float64      `json:"feeRate"`
+	Priority bool         `json:"priority,omitempty"`
+}
+
+// BlockCandidate returns the block MinePending would mine on the tip for
+// minerAddress right now, without mining it or changing the mempool.
+func (bc *BlockChain) BlockCandidate(minerAddress string) (*BlockCandidate, error) {
+	if !keys.ValidateAddress(minerAddress) {
+		return nil, fmt.Errorf("miner address %s is not valid", minerAddress)
+	}
+	tip, err := bc.GetBlock(bc.Tip())
+	if err != nil {
+		return nil, err
+	}
+	assembly := bc.assembleBlock(minerAddress)
+	coinbase := assembly.transactions[0]
+
+	candidate := &BlockCandidate{
+		Height:       tip.Height + 1,
+		PrevHash:     hex.EncodeToString(tip.Hash),
+		Difficulty:   bc.NextDifficulty(tip, bc.now().Unix()),
+		Transactions: []CandidateTransaction{},
+		Excluded:     []CandidateTransaction{},
+		Size:         len(coinbase.Serialize()),
+		Subsidy:      Subsidy,
+	}
+
+	// report the pending transactions in the order they were fitted
+	included := make(map[string]bool)
+	for _, tx := range assembly.transactions[1:] {
+		included[string(tx.ID)] = true
+	}
+	for _, tx := range assembly.pending {
+		size := len(tx.Serialize())
+		c := CandidateTransaction{
+			TxID:     hex.EncodeToString(tx.ID),
+			Fee:      assembly.fees[string(tx.ID)],
+			Size:     size,
+			FeeRate:  float64(assembly.fees[string(tx.ID)]) / float64(size),
+			Priority: assembly.priority[string(tx.ID)],
+		}
+		if !included[string(tx.ID)] {
+			candidate.Excluded = append(candidate.Excluded, c)
+			continue
+		}
+		candidate.Transactions = append(candidate.Transactions, c)
+		candidate.Size += size
+		candidate.Fees += c.Fee
+	}
+	candidate.Reward = candidate.Subsidy + candidate.Fees
+
+	return candidate, nil
+}
@@ -0,0 +1,57 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestBlockCandidate(t *testing.T) {
+	bc := newTestChainWithConfig(t, Config{PriorityKeys: [][]byte{wallet.GeneratePublicKeyHash(bob.PublicKey)}})
+	toBob := send(t, bc, alice, bob, 50, 2)
+	if err := bc.AddToMempool(toBob); err != nil {
+		t.Fatal(err)
+	}
+	fromBob := send(t, bc, bob, carol, 10, 3)
+	if err := bc.AddToMempool(fromBob); err != nil {
+		t.Fatal(err)
+	}
+	toCarol := send(t, bc, alice, carol, 5, 1)
+	if err := bc.AddToMempool(toCarol); err != nil {
+		t.Fatal(err)
+	}
+
+	// bob's payment and its parent come first, and nothing is mined
+	candidate, err := bc.BlockCandidate(carol.Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Transaction{toBob, fromBob, toCarol}
+	if len(candidate.Transactions) != len(want) || len(candidate.Excluded) != 0 {
+		t.Fatalf("got %d transactions and %d excluded, want 3 and none", len(candidate.Transactions), len(candidate.Excluded))
+	}
+	for i, tx := range want {
+		got := candidate.Transactions[i]
+		if got.TxID != hex.EncodeToString(tx.ID) || got.Size == 0 || got.FeeRate != float64(got.Fee)/float64(got.Size) {
+			t.Fatalf("transaction %d is %+v, want %x", i, got, tx.ID)
+		}
+		if got.Priority != (i < 2) {
+			t.Errorf("transaction %d has priority %v", i, got.Priority)
+		}
+	}
+	if candidate.Height != 1 || candidate.Fees != 6 || candidate.Reward != Subsidy+6 {
+		t.Fatalf("got height %d, fees %d and reward %d", candidate.Height, candidate.Fees, candidate.Reward)
+	}
+	if bc.Height() != 0 || len(bc.MempoolTransactions()) != 3 {
+		t.Fatal("candidate changed the chain or mempool")
+	}
+
+	// mining produces the candidate
+	block := minePending(t, bc, carol)
+	for i, tx := range block.Transactions[1:] {
+		if hex.EncodeToString(tx.ID) != candidate.Transactions[i].TxID {
+			t.Fatalf("mined transaction %d is %x, want %s", i, tx.ID, candidate.Transactions[i].TxID)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+)
+
+var (
+	// chartPrefix is the key prefix for the chart points of the blocks in
+	// the best chain, indexed by height.
+	chartPrefix = []byte("chart-")
+
+	// chartTipKey holds the hash of the block the chart points reflect.
+	chartTipKey = []byte("charttip")
+)
+
+// chartKey returns the db key for the chart point of the block at a height.
+func chartKey(height int) []byte {
+	return append(append([]byte{}, chartPrefix...), ToBytes(int64(height))...)
+}
+
+// chartPoint is what a block of the best chain adds to the chart series,
+// recorded as it is connected so series are read without the blocks.
+// FeeRate is the median fee rate of its transactions other than the
+// coinbase, and Fees is set if it had any, which isn't known for a block
+// pruned before its point was recorded.
+type chartPoint struct {
+	Height     int
+	Timestamp  int64
+	Difficulty int
+	FeeRate    float64
+	Fees       bool
+}
+
+// recordChartPoint stores the chart point of a block being connected, whose
+// undo record holds spent.
+func recordChartPoint(txn *badger.Txn, block *Block, spent []spentOutput) error {
+	point := chartPoint{Height: block.Height, Timestamp: block.Timestamp, Difficulty: block.Difficulty}
+	if rates := blockFeeRates(block, spent); len(rates) > 0 {
+		point.FeeRate = newPercentiles(rates).P50
+		point.Fees = true
+	}
+
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(point); err != nil {
+		return errors.New("unable to encode chart point - " + err.Error())
+	}
+	if err := txn.Set(chartKey(block.Height), buffer.Bytes()); err != nil {
+		return err
+	}
+	return txn.Set(chartTipKey, block.Hash)
+}
+
+// removeChartPoint removes the chart point of a block being disconnected.
+func removeChartPoint(txn *badger.Txn, block *Block) error {
+	if err := txn.Delete(chartKey(block.Height)); err != nil {
+		return err
+	}
+	return txn.Set(chartTipKey, block.PrevHash)
+}
+
+// chartTip returns the hash of the block the chart points reflect, or nil
+// if they have not been recorded.
+func (bc *BlockChain) chartTip() ([]byte, error) {
+	var tip []byte
+
+	// initiate read only transaction on db to get the tip
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(chartTipKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		tip, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New("unable to read chart tip - " + err.Error())
+	}
+
+	return tip, nil
+}
+
+// ReindexCharts records the chart points of the blocks in the best chain
+// again. The fee rates of pruned blocks are no longer known and are left
+// out of the series.
+func (bc *BlockChain) ReindexCharts() error {
+	if err := bc.deletePrefix(chartPrefix); err != nil {
+		return err
+	}
+
+	// record each block from genesis forward, one db transaction per block
+	return bc.IterateForward(func(block *Block) error {
+		err := bc.DB.Update(func(txn *badger.Txn) error {
+			var spent []spentOutput
+			if !block.Pruned() {
+				var err error
+				if spent, err = getUndo(txn, block.Hash); err != nil {
+					return err
+				}
+			}
+			return recordChartPoint(txn, block, spent)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to record chart point of block %x - %s", block.Hash, err.Error())
+		}
+		return nil
+	})
+}
+
+// ChartBucket summarizes the blocks of the best chain with a timestamp in
+// the bucket starting at Start, in unix seconds. FeeRate is the median of
+// the median fee rates of the blocks that paid fees, in base units per
+// byte, Difficulty that of the highest block, and Interval the mean number
+// of seconds between the blocks and their parents.
+type ChartBucket struct {
+	Start      int64   `json:"start"`
+	Blocks     int     `json:"blocks"`
+	FeeRate    float64 `json:"feeRate"`
+	Difficulty int     `json:"difficulty"`
+	Interval   float64 `json:"interval"`
+}
+
+// ChartSeries returns the blocks of the best chain with a timestamp from
+// from up to to, in unix seconds, summarized in buckets of bucket seconds
+// aligned to the unix epoch, in time order. Buckets without blocks are left
+// out. It reads the points recorded as blocks were connected, so charts
+// don't need the blocks themselves.
+func (bc *BlockChain) ChartSeries(from, to, bucket int64) ([]ChartBucket, error) {
+	if bucket <= 0 {
+		return nil, fmt.Errorf("bucket of %d seconds is not positive", bucket)
+	}
+
+	// the fee rates and intervals of the blocks in each bucket
+	type bucketPoints struct {
+		ChartBucket
+		height    int
+		rates     []float64
+		intervals []int64
+	}
+	buckets := make(map[int64]*bucketPoints)
+
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		var parent *chartPoint
+		for it.Seek(chartPrefix); it.ValidForPrefix(chartPrefix); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			point := &chartPoint{}
+			if err := gob.NewDecoder(bytes.NewReader(value)).Decode(point); err != nil {
+				return errors.New("unable to decode chart point - " + err.Error())
+			}
+			if point.Timestamp >= from && point.Timestamp <= to {
+				start := point.Timestamp - point.Timestamp%bucket
+				b := buckets[start]
+				if b == nil {
+					b = &bucketPoints{ChartBucket: ChartBucket{Start: start}}
+					buckets[start] = b
+				}
+				b.Blocks++
+				if point.Height >= b.height {
+					b.height = point.Height
+					b.Difficulty = point.Difficulty
+				}
+				if point.Fees {
+					b.rates = append(b.rates, point.FeeRate)
+				}
+				if parent != nil {
+					b.intervals = append(b.intervals, point.Timestamp-parent.Timestamp)
+				}
+			}
+			parent = point
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// summarize the buckets in time order
+	series := []ChartBucket{}
+	for _, b := range buckets {
+		b.FeeRate = newPercentiles(b.rates).P50
+		if len(b.intervals) > 0 {
+			var total int64
+			for _, interval := range b.intervals {
+				total += interval
+			}
+			b.Interval = float64(total) / float64(len(b.intervals))
+		}
+		series = append(series, b.ChartBucket)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Start < series[j].Start })
+
+	return series, nil
+}
@@ -0,0 +1,74 @@
+package blockchain
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestChartSeries(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+	if _, err := bc.ChartSeries(0, math.MaxInt64, 0); err == nil {
+		t.Fatal("got a series of empty buckets")
+	}
+
+	// the block paying a fee sets the fee rate of the series
+	tx := send(t, bc, alice, bob, 10, 400)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	mined := minePending(t, bc, alice)
+	series, err := bc.ChartSeries(0, math.MaxInt64, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rate := 400 / float64(len(tx.Serialize()))
+	want := ChartBucket{
+		Blocks:     2,
+		FeeRate:    rate,
+		Difficulty: mined.Difficulty,
+		Interval:   float64(mined.Timestamp - genesis.Timestamp),
+	}
+	if len(series) != 1 || series[0] != want {
+		t.Fatalf("got %+v, want %+v", series, want)
+	}
+
+	// blocks outside the time range are left out
+	series, err = bc.ChartSeries(0, genesis.Timestamp-1, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 0 {
+		t.Fatalf("got %+v before the genesis block", series)
+	}
+
+	// the points of a disconnected block are removed by a reorg
+	side1 := mineOn(t, bc, genesis, carol)
+	if err := bc.AcceptBlock(side1); err != nil {
+		t.Fatal(err)
+	}
+	side2 := mineOn(t, bc, side1, carol)
+	if err := bc.AcceptBlock(side2); err != nil {
+		t.Fatal(err)
+	}
+	series, err = bc.ChartSeries(0, math.MaxInt64, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || series[0].Blocks != 3 || series[0].FeeRate != 0 || series[0].Difficulty != side2.Difficulty {
+		t.Fatalf("got %+v after the fee paying block was disconnected", series)
+	}
+
+	// recording the points again gives the same series
+	if err := bc.ReindexCharts(); err != nil {
+		t.Fatal(err)
+	}
+	reindexed, err := bc.ChartSeries(0, math.MaxInt64, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reindexed, series) {
+		t.Fatalf("got %+v after reindexing, want %+v", reindexed, series)
+	}
+}
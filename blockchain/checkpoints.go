@@ -0,0 +1,226 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/keys"
+)
+
+// Checkpoint is the hash of the block of the best chain at a height. The
+// best chain must pass through every checkpoint, so the chain below the
+// latest checkpoint it reached can't be reorganized, and the signatures of
+// the blocks below the latest checkpoint are trusted instead of verified
+// when they are connected.
+type Checkpoint struct {
+	Height int
+	Hash   []byte
+}
+
+// ParseCheckpoints parses checkpoints written as HEIGHT:HASH pairs
+// separated by commas, with the hashes in hex. An empty string has no
+// checkpoints.
+func ParseCheckpoints(s string) ([]Checkpoint, error) {
+	var checkpoints []Checkpoint
+	if s == "" {
+		return nil, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("checkpoint %q is not HEIGHT:HASH", pair)
+		}
+		height, err := strconv.Atoi(parts[0])
+		if err != nil || height < 0 {
+			return nil, fmt.Errorf("checkpoint %q has an invalid height", pair)
+		}
+		hash, err := hex.DecodeString(parts[1])
+		if err != nil || len(hash) == 0 {
+			return nil, fmt.Errorf("checkpoint %q has an invalid hash", pair)
+		}
+		checkpoints = append(checkpoints, Checkpoint{Height: height, Hash: hash})
+	}
+
+	return checkpoints, nil
+}
+
+// sortCheckpoints returns a copy of checkpoints ordered by height.
+func sortCheckpoints(checkpoints []Checkpoint) []Checkpoint {
+	sorted := append([]Checkpoint{}, checkpoints...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Height < sorted[j].Height
+	})
+	return sorted
+}
+
+// lastCheckpoint returns the height of the latest checkpoint at or below
+// height, or -1 if there is none.
+func (bc *BlockChain) lastCheckpoint(height int) int {
+	last := -1
+	for _, c := range bc.checkpoints {
+		if c.Height <= height {
+			last = c.Height
+		}
+	}
+	return last
+}
+
+// CheckpointHeight returns the height of the latest checkpoint, or -1 if
+// the chain has no checkpoints. The signatures of blocks at or below it are
+// not verified when they are connected.
+func (bc *BlockChain) CheckpointHeight() int {
+	if len(bc.checkpoints) == 0 {
+		return -1
+	}
+	return bc.checkpoints[len(bc.checkpoints)-1].Height
+}
+
+// checkCheckpoint returns an error if a block is at the height of a
+// checkpoint but is not the checkpoint block.
+func (bc *BlockChain) checkCheckpoint(block *Block) error {
+	for _, c := range bc.checkpoints {
+		if c.Height == block.Height && !bytes.Equal(c.Hash, block.Hash) {
+			return fmt.Errorf("block %x at height %d does not match checkpoint %x", block.Hash, block.Height, c.Hash)
+		}
+	}
+	return nil
+}
+
+// checkReorgDepth returns an error if replacing the best chain above
+// forkHeight would replace a checkpoint it passed through or a final block.
+func (bc *BlockChain) checkReorgDepth(forkHeight int) error {
+	if checkpoint := bc.lastCheckpoint(bc.Height()); forkHeight < checkpoint {
+		return fmt.Errorf("chain forks at height %d, below the checkpoint at height %d", forkHeight, checkpoint)
+	}
+	if final := bc.FinalHeight(); forkHeight < final {
+		return fmt.Errorf("chain forks at height %d, below the final block at height %d", forkHeight, final)
+	}
+	return nil
+}
+
+// VerifyChain verifies the best chain from the tip down to the genesis
+// block, which auditors can use to check a chain that was synced without
+// verifying the signatures below the latest checkpoint. The hash, proof of
+// work, transaction ids, parent and difficulty of every block and the
+// checkpoints are verified, and the signatures of the transactions of the
+// blocks at or above from are verified against the outputs they spent.
+// Pruned blocks have no transactions to verify. It returns the number of
+// blocks verified.
+func (bc *BlockChain) VerifyChain(from int) (int, error) {
+	return bc.VerifyChainContext(context.Background(), from, nil, nil)
+}
+
+// VerifyChainContext verifies the best chain like VerifyChain, calling
+// progress, if set, after each block. If ctx is done it stops between
+// blocks with ErrJobCancelled. If resume is the progress of an interrupted
+// verification whose last block is still in the best chain, verification
+// continues below that block, and the blocks above it are not verified
+// again. The returned number of blocks verified includes those of resume.
+func (bc *BlockChain) VerifyChainContext(ctx context.Context, from int, resume *JobProgress, progress func(JobProgress)) (int, error) {
+	var child *Block
+	verified := 0
+	total := 0
+
+	// blocks are read with GetBlock, so a corrupt record ends verification
+	// with its error
+	hash := bc.Tip()
+	if resume != nil && resume.Last != nil {
+		last, err := bc.GetBlock(resume.Last)
+		if err == nil && bc.inBestChain(last) && len(last.PrevHash) > 0 {
+			child = last
+			hash = last.PrevHash
+			verified = resume.Done
+			total = resume.Total
+		}
+	}
+	for {
+		if ctx.Err() != nil {
+			return verified, ErrJobCancelled
+		}
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			return verified, err
+		}
+		if total == 0 {
+			total = block.Height + 1
+		}
+
+		if block.Pruned() {
+			if err := checkProof(block, bc.mining.authorities); err != nil {
+				return verified, err
+			}
+		} else if err := checkBlock(block, bc.mining.authorities); err != nil {
+			return verified, err
+		}
+		if child != nil {
+			if err := bc.checkParent(child, block); err != nil {
+				return verified, err
+			}
+		}
+
+		// verify the signatures with the outputs in the undo record of the
+		// block, which every block with spending transactions has
+		if block.Height >= from && !block.Pruned() && len(block.Transactions) > 1 {
+			err := bc.DB.View(func(txn *badger.Txn) error {
+				spent, err := getUndo(txn, block.Hash)
+				if err != nil {
+					return err
+				}
+				return verifySignatures(block, spent, bc.scheme)
+			})
+			if err != nil {
+				return verified, fmt.Errorf("block %x is invalid: %s", block.Hash, err.Error())
+			}
+		}
+		verified++
+		if progress != nil {
+			progress(JobProgress{Done: verified, Total: total, Last: block.Hash})
+		}
+
+		// the genesis block has no parent to be checked against
+		if len(block.PrevHash) == 0 {
+			if block.Height != 0 {
+				return verified, errors.New("best chain does not start at height 0")
+			}
+			return verified, bc.checkCheckpoint(block)
+		}
+		child = block
+		hash = block.PrevHash
+	}
+}
+
+// verifySignatures verifies the signatures of the transactions of a block
+// with the outputs it spent.
+func verifySignatures(block *Block, spent []spentOutput, scheme keys.Scheme) error {
+	outputs := make(map[string]TxOutput)
+	for _, s := range spent {
+		outputs[outpoint(s.TxID, s.Out)] = s.Output
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+		prevTXs := make(map[string]Transaction)
+		for _, in := range tx.Inputs {
+			out, ok := outputs[outpoint(in.ID, in.Out)]
+			if !ok {
+				return fmt.Errorf("transaction %x spends output %s missing from the undo record", tx.ID, outpoint(in.ID, in.Out))
+			}
+			addPrevOutput(prevTXs, in, out)
+		}
+		if err := tx.CheckSignatures(scheme, prevTXs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package blockchain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestParseCheckpoints(t *testing.T) {
+	for _, c := range []struct {
+		input string
+		want  []Checkpoint
+		err   string
+	}{
+		{"", nil, ""},
+		{"1:ab, 20:cd", []Checkpoint{{1, []byte{0xab}}, {20, []byte{0xcd}}}, ""},
+		{"1", nil, "not HEIGHT:HASH"},
+		{"-1:ab", nil, "invalid height"},
+		{"1:xyz", nil, "invalid hash"},
+		{"1:", nil, "invalid hash"},
+	} {
+		got, err := ParseCheckpoints(c.input)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%q: got error %v, want %q", c.input, err, c.err)
+			}
+			continue
+		}
+		if err != nil || len(got) != len(c.want) {
+			t.Fatalf("%q: got %v, %v, want %v", c.input, got, err, c.want)
+		}
+		for i := range got {
+			if got[i].Height != c.want[i].Height || !bytes.Equal(got[i].Hash, c.want[i].Hash) {
+				t.Errorf("%q: got checkpoint %v, want %v", c.input, got[i], c.want[i])
+			}
+		}
+	}
+}
+
+func TestCheckpointsRejectDeepReorgs(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+	var chain []*Block
+	for parent := genesis; len(chain) < 2; parent = chain[len(chain)-1] {
+		block := mineOn(t, bc, parent, alice)
+		if err := bc.AcceptBlock(block); err != nil {
+			t.Fatal(err)
+		}
+		chain = append(chain, block)
+	}
+	bc.checkpoints = []Checkpoint{{2, chain[1].Hash}}
+
+	// a different block at the checkpoint height is refused
+	other := mineOn(t, bc, chain[0], carol)
+	if err := bc.AcceptBlock(other); err == nil || !strings.Contains(err.Error(), "does not match checkpoint") {
+		t.Fatalf("got error %v, want the checkpoint to be enforced", err)
+	}
+
+	// so is a side chain forking below it, however long it gets
+	side := mineOn(t, bc, genesis, carol)
+	if err := bc.AcceptBlock(side); err == nil || !strings.Contains(err.Error(), "below the checkpoint") {
+		t.Fatalf("got error %v, want the fork to be refused", err)
+	}
+
+	// blocks after the checkpoint are accepted
+	if err := bc.AcceptBlock(mineOn(t, bc, chain[1], carol)); err != nil {
+		t.Fatal(err)
+	}
+	if bc.Height() != 3 {
+		t.Fatalf("got height %d, want 3", bc.Height())
+	}
+}
+
+func TestCheckpointsSkipSignatures(t *testing.T) {
+	bc := newTestChain(t)
+	bc.checkpoints = []Checkpoint{{5, []byte{1}}}
+
+	// a block below the checkpoint is connected without its signatures
+	// being verified
+	tx := send(t, bc, alice, bob, units.Coin, 0)
+	tx.Inputs[0].Signature[0] ^= 0xff
+	block := mineOn(t, bc, tip(t, bc), alice, tx)
+	if err := bc.AcceptBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	// verifying the chain after the checkpoint trusts it, but auditors
+	// verifying every block find it
+	if verified, err := bc.VerifyChain(bc.CheckpointHeight() + 1); err != nil || verified != 2 {
+		t.Fatalf("got %d, %v, want 2 blocks verified", verified, err)
+	}
+	if _, err := bc.VerifyChain(0); err == nil || !strings.Contains(err.Error(), "invalid signature") {
+		t.Fatalf("got error %v, want the invalid signature to be found", err)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	bc := newTestChainWithConfig(t, Config{PruneDepth: 2})
+
+	// spend in a few blocks so some pruned and some unpruned blocks have
+	// signatures
+	for i := 0; i < 4; i++ {
+		if err := bc.AddToMempool(send(t, bc, alice, bob, units.Coin, 1)); err != nil {
+			t.Fatal(err)
+		}
+		minePending(t, bc, alice)
+	}
+
+	verified, err := bc.VerifyChain(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified != 5 {
+		t.Fatalf("verified %d blocks, want 5", verified)
+	}
+}
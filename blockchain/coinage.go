@@ -0,0 +1,118 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// AgeBucketLimits are the upper bounds, in blocks, of the age buckets of an
+// AgeReport. Outputs at least as old as the last limit fall in a final
+// bucket without a bound.
+var AgeBucketLimits = []int{10, 100, 1000, 10000}
+
+// AgedOutput is a confirmed unspent output with the number of blocks mined
+// on top of the block confirming it.
+type AgedOutput struct {
+	UnspentOutput
+	Height int
+	Age    int
+}
+
+// AgeBucket counts the outputs whose age is at least Min and less than Max
+// blocks, or at least Min if Max is 0.
+type AgeBucket struct {
+	Min     int
+	Max     int
+	Outputs int
+	Value   units.Amount
+}
+
+// AgeReport is the age distribution of the confirmed unspent outputs of a
+// set of keys, oldest first.
+type AgeReport struct {
+	Outputs []AgedOutput
+	Buckets []AgeBucket
+	Value   units.Amount
+
+	// CoinAge is the sum of the value of each output times its age, in
+	// base units times blocks.
+	CoinAge float64
+}
+
+// WeightedAge returns the age of the outputs weighted by their value, which
+// is how long the value of the report has been held on average.
+func (r *AgeReport) WeightedAge() float64 {
+	if r.Value == 0 {
+		return 0
+	}
+	return r.CoinAge / float64(r.Value)
+}
+
+// AgeReport reports the age of the confirmed unspent outputs that can be
+// unlocked by any of pubKeyHashes.
+func (bc *BlockChain) AgeReport(pubKeyHashes [][]byte) (*AgeReport, error) {
+	s, err := bc.Snapshot()
+	if err != nil {
+		return nil, errors.New("unable to report output ages - " + err.Error())
+	}
+	defer s.Discard()
+	return s.AgeReport(pubKeyHashes)
+}
+
+// AgeReport reports the age of the confirmed unspent outputs of the
+// snapshot that can be unlocked by any of pubKeyHashes, using the address
+// index to find the height of the block confirming each.
+func (s *Snapshot) AgeReport(pubKeyHashes [][]byte) (*AgeReport, error) {
+	report := &AgeReport{}
+	min := 0
+	for _, max := range AgeBucketLimits {
+		report.Buckets = append(report.Buckets, AgeBucket{Min: min, Max: max})
+		min = max
+	}
+	report.Buckets = append(report.Buckets, AgeBucket{Min: min})
+
+	for _, pubKeyHash := range pubKeyHashes {
+		entries, err := readAddrIndex(s.txn, pubKeyHash)
+		if err != nil {
+			return nil, err
+		}
+		unspent, err := s.UnspentOutputs(pubKeyHash)
+		if err != nil {
+			return nil, err
+		}
+
+		// age each output and add it to its bucket
+		for _, u := range unspent {
+			height := entries[string(u.TxID)].Height
+			aged := AgedOutput{UnspentOutput: u, Height: height, Age: s.Height() - height}
+			report.Outputs = append(report.Outputs, aged)
+			report.Value += u.Output.Value
+			report.CoinAge += float64(u.Output.Value) * float64(aged.Age)
+			for i := range report.Buckets {
+				b := &report.Buckets[i]
+				if aged.Age >= b.Min && (b.Max == 0 || aged.Age < b.Max) {
+					b.Outputs++
+					b.Value += u.Output.Value
+					break
+				}
+			}
+		}
+	}
+
+	// order the outputs oldest first, then by outpoint
+	sort.Slice(report.Outputs, func(i, j int) bool {
+		a, b := report.Outputs[i], report.Outputs[j]
+		if a.Height != b.Height {
+			return a.Height < b.Height
+		}
+		if c := bytes.Compare(a.TxID, b.TxID); c != 0 {
+			return c < 0
+		}
+		return a.Out < b.Out
+	})
+
+	return report, nil
+}
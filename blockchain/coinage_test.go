@@ -0,0 +1,48 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestAgeReport(t *testing.T) {
+	bc := newTestChain(t)
+
+	// bob is paid once, then 11 more blocks are mined on top
+	if err := bc.AddToMempool(send(t, bc, alice, bob, 10*units.Coin, 0)); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, carol)
+	for i := 0; i < 11; i++ {
+		minePending(t, bc, carol)
+	}
+
+	report, err := bc.AgeReport([][]byte{
+		wallet.GeneratePublicKeyHash(alice.PublicKey),
+		wallet.GeneratePublicKeyHash(bob.PublicKey),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the payment and alice's change are 11 blocks old
+	if len(report.Outputs) != 2 || report.Value != genesisAllocation {
+		t.Fatalf("got %d outputs worth %s, want 2 worth %s", len(report.Outputs), report.Value, genesisAllocation)
+	}
+	for _, o := range report.Outputs {
+		if o.Height != 1 || o.Age != 11 {
+			t.Fatalf("got output at height %d aged %d, want height 1 aged 11", o.Height, o.Age)
+		}
+	}
+	if age := report.WeightedAge(); age != 11 {
+		t.Fatalf("got weighted age %f, want 11", age)
+	}
+	if b := report.Buckets[1]; b.Min != 10 || b.Max != 100 || b.Outputs != 2 || b.Value != genesisAllocation {
+		t.Fatalf("got bucket %+v, want both outputs aged 10 to 99", b)
+	}
+	if b := report.Buckets[len(report.Buckets)-1]; b.Max != 0 || b.Outputs != 0 {
+		t.Fatalf("got last bucket %+v, want an empty unbounded bucket", b)
+	}
+}
@@ -0,0 +1,299 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/addresses"
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// depositWatchPrefix is the key prefix of deposit watches, followed by the
+// public key hash of the watched address and the URL notified.
+var depositWatchPrefix = []byte("depositwatch-")
+
+const (
+	// DepositConfirmed is the type of the event sent when a deposit to a
+	// watched address reaches the confirmations of the watch.
+	DepositConfirmed = "depositconfirmed"
+
+	// DepositInvalidated is the type of the event sent when a reorg takes a
+	// deposit that was notified as confirmed out of the best chain.
+	DepositInvalidated = "depositinvalidated"
+)
+
+// DepositWatch asks for URL to be notified once each deposit to Address
+// has Confirmations confirmations, and again if a reorg then takes the
+// deposit out of the best chain, as exchanges crediting deposits need.
+type DepositWatch struct {
+	Address       string `json:"address"`
+	Confirmations int    `json:"confirmations"`
+	URL           string `json:"url"`
+}
+
+// DepositEvent notifies the URL of a DepositWatch of a deposit to its
+// address, a transaction paying Value to it.
+type DepositEvent struct {
+	Type          string       `json:"type"`
+	Address       string       `json:"address"`
+	URL           string       `json:"url"`
+	TxID          string       `json:"txid"`
+	Value         units.Amount `json:"value"`
+	BlockHash     string       `json:"blockHash"`
+	Height        int          `json:"height"`
+	Confirmations int          `json:"confirmations"`
+}
+
+// depositWatchRecord is the db record of a DepositWatch with the deposits
+// notified as confirmed, keyed by transaction id.
+type depositWatchRecord struct {
+	Watch    DepositWatch
+	Notified map[string]notifiedDeposit
+}
+
+// notifiedDeposit is a deposit notified as confirmed.
+type notifiedDeposit struct {
+	Value     units.Amount
+	BlockHash []byte
+	Height    int
+}
+
+// depositWatchKey returns the db key of the watch of url on an address.
+func depositWatchKey(pubKeyHash []byte, url string) []byte {
+	key := append(append([]byte{}, depositWatchPrefix...), pubKeyHash...)
+	return append(key, url...)
+}
+
+// WatchDeposits adds a deposit watch, or changes the confirmations of the
+// watch of its URL on its address. Deposits already in the chain with
+// enough confirmations are notified like new ones.
+func (bc *BlockChain) WatchDeposits(w DepositWatch) error {
+	if !keys.ValidateAddress(w.Address) {
+		return errors.New("unable to watch deposits - address not valid")
+	}
+	if w.Confirmations < 1 {
+		return errors.New("unable to watch deposits - confirmations must be positive")
+	}
+	if w.URL == "" {
+		return errors.New("unable to watch deposits - no URL given")
+	}
+	address, _ := addresses.Decode(w.Address)
+
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		key := depositWatchKey(address.PubKeyHash, w.URL)
+		record, err := readDepositWatch(txn, key)
+		if err == badger.ErrKeyNotFound {
+			record = &depositWatchRecord{Notified: make(map[string]notifiedDeposit)}
+		} else if err != nil {
+			return err
+		}
+		record.Watch = w
+		return putDepositWatch(txn, key, record)
+	})
+	if err != nil {
+		return errors.New("unable to watch deposits - " + err.Error())
+	}
+	return nil
+}
+
+// UnwatchDeposits removes the watch of url on an address, returning
+// whether there was one.
+func (bc *BlockChain) UnwatchDeposits(address, url string) (bool, error) {
+	decoded, err := addresses.Decode(address)
+	if err != nil {
+		return false, errors.New("unable to unwatch deposits - " + err.Error())
+	}
+
+	found := false
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		key := depositWatchKey(decoded.PubKeyHash, url)
+		if _, err := txn.Get(key); err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		found = true
+		return txn.Delete(key)
+	})
+	if err != nil {
+		return false, errors.New("unable to unwatch deposits - " + err.Error())
+	}
+	return found, nil
+}
+
+// DepositWatches returns the deposit watches.
+func (bc *BlockChain) DepositWatches() ([]DepositWatch, error) {
+	watches := []DepositWatch{}
+	err := bc.forEachDepositWatch(func(txn *badger.Txn, key []byte, record *depositWatchRecord) error {
+		watches = append(watches, record.Watch)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("unable to read deposit watches - " + err.Error())
+	}
+	return watches, nil
+}
+
+// PendingDepositEvents returns the deposit events not delivered yet: a
+// DepositConfirmed event for each deposit to a watched address in the best
+// chain with the confirmations of the watch that has not been notified,
+// and a DepositInvalidated event for each notified deposit no longer in the
+// best chain. Events are pending until delivered with AckDepositEvent, so
+// a notifier retries those its URL didn't take.
+func (bc *BlockChain) PendingDepositEvents() ([]DepositEvent, error) {
+	events := []DepositEvent{}
+	height := bc.Height()
+	err := bc.forEachDepositWatch(func(txn *badger.Txn, key []byte, record *depositWatchRecord) error {
+		entries, err := readAddrIndex(txn, key[len(depositWatchPrefix):len(key)-len(record.Watch.URL)])
+		if err != nil {
+			return err
+		}
+		event := func(eventType, txID string, value units.Amount, blockHash []byte, blockHeight, confirmations int) DepositEvent {
+			return DepositEvent{
+				Type:          eventType,
+				Address:       record.Watch.Address,
+				URL:           record.Watch.URL,
+				TxID:          txID,
+				Value:         value,
+				BlockHash:     hex.EncodeToString(blockHash),
+				Height:        blockHeight,
+				Confirmations: confirmations,
+			}
+		}
+
+		// deposits that reached the confirmations of the watch
+		for id, e := range entries {
+			txID := hex.EncodeToString([]byte(id))
+			confirmations := height - e.Height + 1
+			if _, ok := record.Notified[txID]; ok || e.Received == 0 || confirmations < record.Watch.Confirmations {
+				continue
+			}
+			events = append(events, event(DepositConfirmed, txID, e.Received, e.BlockHash, e.Height, confirmations))
+		}
+
+		// notified deposits a reorg took out of the best chain
+		for txID, deposit := range record.Notified {
+			id, err := hex.DecodeString(txID)
+			if err != nil {
+				return err
+			}
+			if _, ok := entries[string(id)]; !ok {
+				events = append(events, event(DepositInvalidated, txID, deposit.Value, deposit.BlockHash, deposit.Height, 0))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("unable to find deposit events - " + err.Error())
+	}
+
+	// deliver the deposits of each watch in the order they were made
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.Address != b.Address || a.URL != b.URL {
+			return a.Address+a.URL < b.Address+b.URL
+		}
+		if a.Height != b.Height {
+			return a.Height < b.Height
+		}
+		return a.TxID < b.TxID
+	})
+	return events, nil
+}
+
+// AckDepositEvent records that an event from PendingDepositEvents was
+// delivered, so it is not returned again. Events of watches removed since
+// are ignored.
+func (bc *BlockChain) AckDepositEvent(e DepositEvent) error {
+	address, err := addresses.Decode(e.Address)
+	if err != nil {
+		return errors.New("unable to acknowledge deposit event - " + err.Error())
+	}
+	blockHash, err := hex.DecodeString(e.BlockHash)
+	if err != nil {
+		return errors.New("unable to acknowledge deposit event - " + err.Error())
+	}
+
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		key := depositWatchKey(address.PubKeyHash, e.URL)
+		record, err := readDepositWatch(txn, key)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch e.Type {
+		case DepositConfirmed:
+			record.Notified[e.TxID] = notifiedDeposit{Value: e.Value, BlockHash: blockHash, Height: e.Height}
+		case DepositInvalidated:
+			delete(record.Notified, e.TxID)
+		default:
+			return fmt.Errorf("unknown event type %q", e.Type)
+		}
+		return putDepositWatch(txn, key, record)
+	})
+	if err != nil {
+		return errors.New("unable to acknowledge deposit event - " + err.Error())
+	}
+	return nil
+}
+
+// forEachDepositWatch calls fn with the key and record of each deposit
+// watch in a read only db transaction. The watches are read before fn is
+// called, since a transaction can only iterate once at a time.
+func (bc *BlockChain) forEachDepositWatch(fn func(txn *badger.Txn, key []byte, record *depositWatchRecord) error) error {
+	return bc.DB.View(func(txn *badger.Txn) error {
+		var watchKeys [][]byte
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		for it.Seek(depositWatchPrefix); it.ValidForPrefix(depositWatchPrefix); it.Next() {
+			watchKeys = append(watchKeys, it.Item().KeyCopy(nil))
+		}
+		it.Close()
+
+		for _, key := range watchKeys {
+			record, err := readDepositWatch(txn, key)
+			if err != nil {
+				return err
+			}
+			if err := fn(txn, key, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readDepositWatch reads the record of the deposit watch with key.
+func readDepositWatch(txn *badger.Txn, key []byte) (*depositWatchRecord, error) {
+	item, err := txn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var record depositWatchRecord
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&record); err != nil {
+		return nil, errors.New("unable to decode deposit watch - " + err.Error())
+	}
+	if record.Notified == nil {
+		record.Notified = make(map[string]notifiedDeposit)
+	}
+	return &record, nil
+}
+
+// putDepositWatch stores the record of a deposit watch.
+func putDepositWatch(txn *badger.Txn, key []byte, record *depositWatchRecord) error {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(record); err != nil {
+		return err
+	}
+	return txn.Set(key, buffer.Bytes())
+}
@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDepositEvents(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+	url := "http://localhost/deposits"
+	if err := bc.WatchDeposits(DepositWatch{Address: bob.Address().String(), Confirmations: 2, URL: url}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.WatchDeposits(DepositWatch{Address: bob.Address().String(), URL: url}); err == nil {
+		t.Fatal("watched deposits without confirmations")
+	}
+
+	// pending returns the events not delivered yet
+	pending := func(want int) []DepositEvent {
+		t.Helper()
+		events, err := bc.PendingDepositEvents()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != want {
+			t.Fatalf("got %d deposit events %+v, want %d", len(events), events, want)
+		}
+		return events
+	}
+	accept := func(block *Block) *Block {
+		t.Helper()
+		if err := bc.AcceptBlock(block); err != nil {
+			t.Fatal(err)
+		}
+		return block
+	}
+
+	// the deposit is notified once it has two confirmations
+	deposit := send(t, bc, alice, bob, 300, 10)
+	first := accept(mineOn(t, bc, genesis, carol, deposit))
+	pending(0)
+	accept(mineOn(t, bc, first, carol))
+	events := pending(1)
+	e := events[0]
+	if e.Type != DepositConfirmed || e.TxID != hex.EncodeToString(deposit.ID) || e.Value != 300 || e.Confirmations != 2 || e.Height != first.Height {
+		t.Fatalf("got event %+v, want the deposit confirmed", e)
+	}
+
+	// until it is delivered
+	pending(1)
+	if err := bc.AckDepositEvent(e); err != nil {
+		t.Fatal(err)
+	}
+	pending(0)
+
+	// a reorg taking it out of the chain invalidates it once
+	side := accept(mineOn(t, bc, genesis, carol))
+	side = accept(mineOn(t, bc, side, carol))
+	accept(mineOn(t, bc, side, carol))
+	if events := pending(1); events[0].Type != DepositInvalidated || events[0].TxID != e.TxID || events[0].BlockHash != e.BlockHash {
+		t.Fatalf("got event %+v, want the deposit invalidated", events[0])
+	} else if err := bc.AckDepositEvent(events[0]); err != nil {
+		t.Fatal(err)
+	}
+	pending(0)
+
+	// removed watches are not notified
+	if found, err := bc.UnwatchDeposits(bob.Address().String(), url); err != nil || !found {
+		t.Fatalf("got %v and error %v removing the watch", found, err)
+	}
+	if watches, err := bc.DepositWatches(); err != nil || len(watches) != 0 {
+		t.Fatalf("got watches %+v and error %v after removing the watch", watches, err)
+	}
+}
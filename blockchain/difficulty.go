@@ -0,0 +1,77 @@
+package blockchain
+
+import "fmt"
+
+// DifficultyRules are the limits on the proof of work difficulty of the
+// blocks of a network.
+type DifficultyRules struct {
+
+	// Min and Max bound the difficulty of every block after genesis.
+	Min int
+	Max int
+
+	// TargetSpacing is the expected number of seconds between blocks.
+	TargetSpacing int64
+
+	// AllowMinDifficulty lets a block be mined at Min when no block has been
+	// found for twice TargetSpacing, so test networks don't stall when
+	// miners leave. The next block returns to the normal difficulty.
+	AllowMinDifficulty bool
+}
+
+// DefaultDifficultyRules are the rules of chains created without a genesis
+// configuration, which allow any difficulty.
+var DefaultDifficultyRules = DifficultyRules{Min: 1, Max: 255}
+
+// NextDifficulty returns the difficulty of a block mined on parent at
+// timestamp.
+func (bc *BlockChain) NextDifficulty(parent *Block, timestamp int64) int {
+	if bc.minDifficultyAllowed(parent, timestamp) {
+		return bc.rules.Min
+	}
+	return bc.normalDifficulty(parent)
+}
+
+// minDifficultyAllowed returns whether a block mined on parent at timestamp
+// may use the minimum difficulty because the network has stalled.
+func (bc *BlockChain) minDifficultyAllowed(parent *Block, timestamp int64) bool {
+	return bc.rules.AllowMinDifficulty && timestamp > parent.Timestamp+2*bc.rules.TargetSpacing
+}
+
+// normalDifficulty returns the difficulty a block mined on parent has when
+// the network has not stalled, which is that of the last block before it
+// that was not mined at the minimum difficulty because of a stall.
+func (bc *BlockChain) normalDifficulty(parent *Block) int {
+	block := parent
+
+	// skip back over blocks mined at the minimum difficulty after a stall
+	for bc.rules.AllowMinDifficulty && block.Height > 0 && block.GetDifficulty() == bc.rules.Min {
+		prev, err := bc.GetBlock(block.PrevHash)
+		if err != nil || !bc.minDifficultyAllowed(prev, block.Timestamp) {
+			break
+		}
+		block = prev
+	}
+
+	// keep the difficulty within the limits of the network
+	difficulty := block.GetDifficulty()
+	if difficulty < bc.rules.Min {
+		difficulty = bc.rules.Min
+	}
+	if difficulty > bc.rules.Max {
+		difficulty = bc.rules.Max
+	}
+	return difficulty
+}
+
+// checkDifficulty returns an error if the difficulty of a block mined on
+// parent is not allowed. Mining at the normal difficulty is always allowed,
+// even when the minimum difficulty could be used.
+func (bc *BlockChain) checkDifficulty(block, parent *Block) error {
+	difficulty := block.GetDifficulty()
+	if difficulty == bc.normalDifficulty(parent) ||
+		difficulty == bc.rules.Min && bc.minDifficultyAllowed(parent, block.Timestamp) {
+		return nil
+	}
+	return fmt.Errorf("block %x has difficulty %d, expected %d", block.Hash, difficulty, bc.NextDifficulty(parent, block.Timestamp))
+}
@@ -0,0 +1,116 @@
+package blockchain
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/logging"
+)
+
+func TestNextDifficulty(t *testing.T) {
+	parent := &Block{BlockHeader: BlockHeader{Height: 1, Difficulty: 5, Timestamp: 100}}
+	for _, c := range []struct {
+		name      string
+		rules     DifficultyRules
+		parent    *Block
+		timestamp int64
+		want      int
+	}{
+		{"within limits", DifficultyRules{Min: 1, Max: 10}, parent, 101, 5},
+		{"raised to min", DifficultyRules{Min: 7, Max: 10}, parent, 101, 7},
+		{"lowered to max", DifficultyRules{Min: 1, Max: 3}, parent, 101, 3},
+		{"default difficulty", DifficultyRules{Min: 1, Max: 255}, &Block{BlockHeader: BlockHeader{Height: 1}}, 101, Difficulty},
+		{"stalled", DifficultyRules{Min: 1, Max: 10, TargetSpacing: 10, AllowMinDifficulty: true}, parent, 121, 1},
+		{"at twice spacing", DifficultyRules{Min: 1, Max: 10, TargetSpacing: 10, AllowMinDifficulty: true}, parent, 120, 5},
+		{"stalled without allow", DifficultyRules{Min: 1, Max: 10, TargetSpacing: 10}, parent, 1000, 5},
+	} {
+		bc := &BlockChain{rules: c.rules}
+		if got := bc.NextDifficulty(c.parent, c.timestamp); got != c.want {
+			t.Errorf("%s: got difficulty %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMinDifficultyAfterStall(t *testing.T) {
+	genesis := testGenesis()
+	genesis.Difficulty = 4
+	genesis.TargetSpacing = 10
+	genesis.AllowMinDifficulty = true
+	bc, err := Open(Config{Path: t.TempDir(), Genesis: genesis, Logger: logging.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	// mine a block at timestamp on parent with difficulty
+	mine := func(parent *Block, timestamp int64, difficulty int) *Block {
+		block := &Block{
+			BlockHeader: BlockHeader{
+				Hash:       []byte{},
+				PrevHash:   parent.Hash,
+				Height:     parent.Height + 1,
+				Timestamp:  timestamp,
+				Difficulty: difficulty,
+			},
+			Transactions: []*Transaction{CoinbaseTx(carol.Address().String(), "", 0)},
+		}
+		if err := block.mine(context.Background(), miningOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		return block
+	}
+
+	// a block after a stall may use the minimum difficulty
+	stalled := mine(tip(t, bc), 1000, 1)
+	if err := bc.AcceptBlock(stalled); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next block returns to the difficulty before the stall
+	if got := bc.NextDifficulty(stalled, 1005); got != 4 {
+		t.Fatalf("got difficulty %d after the stall, want 4", got)
+	}
+	for _, c := range []struct {
+		name       string
+		timestamp  int64
+		difficulty int
+		want       string
+	}{
+		{"min difficulty without a stall", 1005, 1, "expected 4"},
+		{"higher difficulty", 1005, 5, "expected 4"},
+		{"normal difficulty", 1005, 4, ""},
+		{"normal difficulty after another stall", 2000, 4, ""},
+	} {
+		err := bc.checkDifficulty(mine(stalled, c.timestamp, c.difficulty), stalled)
+		if c.want == "" && err != nil || c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)) {
+			t.Errorf("%s: got error %v, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestLoadGenesisDifficultyLimits(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		json string
+		want string
+	}{
+		{"defaults", `{"network":"n","timestamp":1}`, ""},
+		{"limits", `{"network":"n","timestamp":1,"difficulty":8,"minDifficulty":4,"maxDifficulty":12}`, ""},
+		{"min above max", `{"network":"n","timestamp":1,"minDifficulty":20,"maxDifficulty":12}`, "limits"},
+		{"max out of range", `{"network":"n","timestamp":1,"maxDifficulty":256}`, "limits"},
+		{"difficulty below min", `{"network":"n","timestamp":1,"difficulty":2,"minDifficulty":4}`, "difficulty 2"},
+		{"allow min without spacing", `{"network":"n","timestamp":1,"allowMinDifficulty":true}`, "target spacing"},
+	} {
+		path := filepath.Join(t.TempDir(), "genesis.json")
+		if err := ioutil.WriteFile(path, []byte(c.json), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadGenesis(path)
+		if c.want == "" && err != nil || c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)) {
+			t.Errorf("%s: got error %v, want %q", c.name, err, c.want)
+		}
+	}
+}
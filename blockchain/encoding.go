@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	gobtx "github.com/edwintcloud/gochain/blockchain/gobtx"
+)
+
+// TxVersion is the version of the transactions created by this node.
+// Version 0 transactions have their ids and signature hashes computed over
+// their gob encoding, which depends on the Go version and on the fields of
+// the transaction types, and their signatures don't cover their outputs.
+// Version 1 transactions are hashed over CanonicalBytes, and their
+// signatures cover their outputs. Version 0 transactions are only valid in
+// blocks, which the existing chains hold them in. They are refused by the
+// mempool, as anyone relaying one could change where its outputs pay.
+const TxVersion = 1
+
+// CanonicalBytes returns the canonical encoding of a transaction without
+// its id, which the id and signature hashes of transactions from version 1
+// are computed over. It depends only on the values of the transaction:
+//
+//	version         uint32
+//	input count     uint32
+//	for each input  id, out, signature, public key, coinbase data
+//	output count    uint32
+//	for each output value, public key hash
+//
+// where integers are big endian, out and value are int64, and byte strings
+// are a uint32 length followed by the bytes.
+func (tx *Transaction) CanonicalBytes() []byte {
+	var buffer bytes.Buffer
+	writeUint32 := func(n int) {
+		binary.Write(&buffer, binary.BigEndian, uint32(n))
+	}
+	writeBytes := func(b []byte) {
+		writeUint32(len(b))
+		buffer.Write(b)
+	}
+
+	writeUint32(tx.Version)
+	writeUint32(len(tx.Inputs))
+	for _, in := range tx.Inputs {
+		writeBytes(in.ID)
+		binary.Write(&buffer, binary.BigEndian, int64(in.Out))
+		writeBytes(in.Signature)
+		writeBytes(in.PubKey)
+		writeBytes(in.CoinbaseData)
+	}
+	writeUint32(len(tx.Outputs))
+	for _, out := range tx.Outputs {
+		binary.Write(&buffer, binary.BigEndian, int64(out.Value))
+		writeBytes(out.PubKeyHash)
+	}
+
+	return buffer.Bytes()
+}
+
+// legacyBytes returns the gob encoding of a version 0 transaction, as it
+// was before transactions had a version.
+func (tx *Transaction) legacyBytes() []byte {
+	legacy := gobtx.Transaction{ID: tx.ID}
+	for _, in := range tx.Inputs {
+		legacy.Inputs = append(legacy.Inputs, gobtx.TxInput{
+			ID:           in.ID,
+			Out:          in.Out,
+			Signature:    in.Signature,
+			PubKey:       in.PubKey,
+			CoinbaseData: in.CoinbaseData,
+		})
+	}
+	for _, out := range tx.Outputs {
+		legacy.Outputs = append(legacy.Outputs, gobtx.TxOutput{Value: out.Value, PubKeyHash: out.PubKeyHash})
+	}
+	return gobtx.Encode(legacy)
+}
@@ -0,0 +1,87 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLegacyHashes(t *testing.T) {
+	// hashes computed before transactions had a version
+	tx := Transaction{
+		Inputs:  []TxInput{{ID: []byte{1, 2, 3}, Out: 1, Signature: []byte{4, 5}, PubKey: []byte{6, 7}}},
+		Outputs: []TxOutput{{Value: 42, PubKeyHash: []byte{8, 9}}},
+	}
+	if got := hex.EncodeToString(tx.GenerateHash()); got != "970b6f842addbf4cc0a1c0ce5d53479f748318707dbd6afce70076e903e1aaaa" {
+		t.Errorf("got transaction hash %s", got)
+	}
+	trimmed := tx.TrimmedCopy()
+	trimmed.Inputs[0].PubKey = []byte{8, 9}
+	if got := hex.EncodeToString(trimmed.GenerateHash()); got != "da31743a9c60816b49c152aac303c41b097d09dd676e85aff50acb0e3f811437" {
+		t.Errorf("got signature hash %s", got)
+	}
+
+	genesis := tip(t, newTestChain(t))
+	if got := hex.EncodeToString(genesis.Hash); got != "451bf595154948c029525aa8a89e77ab420db9578116bf9fb51da841d7e7be34" {
+		t.Errorf("got genesis block hash %s", got)
+	}
+	if got := hex.EncodeToString(genesis.Transactions[0].ID); got != "1e9d5f3df2bf5c73a248922f2b3d232078c980c7c290f51e206e6e13d16e01cf" {
+		t.Errorf("got genesis transaction id %s", got)
+	}
+}
+
+func TestCanonicalBytes(t *testing.T) {
+	tx := Transaction{
+		Version: 1,
+		Inputs:  []TxInput{{ID: []byte{1}, Out: 2}},
+		Outputs: []TxOutput{{Value: 3, PubKeyHash: []byte{4}}},
+	}
+	want := "00000001" + "00000001" +
+		"0000000101" + "0000000000000002" + "00000000" + "00000000" + "00000000" +
+		"00000001" +
+		"0000000000000003" + "0000000104"
+	if got := hex.EncodeToString(tx.CanonicalBytes()); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestSignatureCoversOutputs(t *testing.T) {
+	bc := newTestChain(t)
+
+	// a version 1 signature no longer verifies once an output is changed
+	tx := send(t, bc, alice, bob, 10, 1)
+	if tx.Version != TxVersion {
+		t.Fatalf("got version %d, want %d", tx.Version, TxVersion)
+	}
+	tx.Outputs[0].Value++
+	if valid, err := bc.VerifyTransaction(tx); err != nil || valid {
+		t.Fatalf("got valid %t and error %v for a changed output", valid, err)
+	}
+
+	// version 0 transactions are still signed without their outputs, so
+	// they are refused by the mempool but accepted in blocks
+	legacy := send(t, bc, alice, bob, 10, 1)
+	legacy.Version = 0
+	for i := range legacy.Inputs {
+		legacy.Inputs[i].Signature = nil
+	}
+	legacy.ID = legacy.GenerateHash()
+	if err := bc.SignTransaction(legacy, alice); err != nil {
+		t.Fatal(err)
+	}
+	var rejected *RejectError
+	if err := bc.AddToMempool(legacy); !errors.As(err, &rejected) || rejected.Rule != RejectVersion {
+		t.Fatalf("got error %v, want the version 0 transaction rejected", err)
+	}
+	if err := bc.AcceptBlock(mineOn(t, bc, tip(t, bc), carol, legacy)); err != nil {
+		t.Fatalf("unable to accept a block holding a version 0 transaction: %s", err)
+	}
+
+	// versions this node can't hash are refused
+	future := send(t, bc, alice, bob, 10, 1)
+	future.Version = TxVersion + 1
+	if err := bc.AddToMempool(future); err == nil || !strings.Contains(err.Error(), "unsupported version") {
+		t.Fatalf("got error %v, want an unsupported version refused", err)
+	}
+}
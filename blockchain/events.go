@@ -0,0 +1,94 @@
+package blockchain
+
+import (
+	"encoding/hex"
+
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/hooks"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// blockEvent is the payload sent to plugins for block events.
+type blockEvent struct {
+	Hash         string   `json:"hash"`
+	PrevHash     string   `json:"prevHash"`
+	Height       int      `json:"height"`
+	Timestamp    int64    `json:"timestamp"`
+	Transactions []string `json:"transactions"`
+}
+
+// txEvent is the payload sent to plugins for transaction events.
+type txEvent struct {
+	ID      string          `json:"id"`
+	Fee     units.Amount    `json:"fee"`
+	Outputs []txOutputEvent `json:"outputs"`
+}
+
+// txOutputEvent describes an output in a txEvent.
+type txOutputEvent struct {
+	Value      units.Amount `json:"value"`
+	PubKeyHash string       `json:"pubKeyHash"`
+}
+
+// reorgEvent is the payload published to subscribers when the best chain
+// is switched, listing the hashes of the blocks that left and joined it in
+// the order they did.
+type reorgEvent struct {
+	Disconnected []string `json:"disconnected"`
+	Connected    []string `json:"connected"`
+}
+
+// newBlockEvent creates the plugin payload for a block.
+func newBlockEvent(block *Block) blockEvent {
+	event := blockEvent{
+		Hash:      hex.EncodeToString(block.Hash),
+		PrevHash:  hex.EncodeToString(block.PrevHash),
+		Height:    block.Height,
+		Timestamp: block.Timestamp,
+	}
+	for _, tx := range block.Transactions {
+		event.Transactions = append(event.Transactions, hex.EncodeToString(tx.ID))
+	}
+	return event
+}
+
+// newTxEvent creates the plugin payload for a transaction.
+func newTxEvent(tx *Transaction, fee units.Amount) txEvent {
+	event := txEvent{
+		ID:  hex.EncodeToString(tx.ID),
+		Fee: fee,
+	}
+	for _, out := range tx.Outputs {
+		event.Outputs = append(event.Outputs, txOutputEvent{out.Value, hex.EncodeToString(out.PubKeyHash)})
+	}
+	return event
+}
+
+// newReorgEvent creates the subscriber payload for a switch of the best
+// chain. disconnect is ordered from the old tip down and connect from the
+// new tip down, as collected by reorganize.
+func newReorgEvent(disconnect, connect []*Block) reorgEvent {
+	var event reorgEvent
+	for _, block := range disconnect {
+		event.Disconnected = append(event.Disconnected, hex.EncodeToString(block.Hash))
+	}
+	for i := len(connect) - 1; i >= 0; i-- {
+		event.Connected = append(event.Connected, hex.EncodeToString(connect[i].Hash))
+	}
+	return event
+}
+
+// notifyBlock calls the lifecycle hooks and sends a block event to plugins,
+// and publishes blocks joining the best chain to subscribers.
+func (bc *BlockChain) notifyBlock(event string, block *Block) {
+	bc.log.Debug("Sending block event", "event", event, "height", block.Height, "hash", block.Hash)
+	if event == hooks.BlockConnected {
+		bc.lifecycle.blockConnected(block)
+	} else {
+		bc.lifecycle.blockDisconnected(block)
+	}
+	hooks.Notify(event, newBlockEvent(block))
+	if event == hooks.BlockConnected {
+		bc.events.Publish(events.Event{Type: events.NewBlock, Payload: block})
+	}
+}
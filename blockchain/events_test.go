@@ -0,0 +1,76 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/edwintcloud/gochain/events"
+)
+
+// nextEvent returns the next published event, failing if there is none.
+func nextEvent(t *testing.T, sub *events.Subscription) events.Event {
+	t.Helper()
+	select {
+	case event := <-sub.C:
+		return event
+	default:
+		t.Fatal("expected an event")
+		return events.Event{}
+	}
+}
+
+func TestEventsArePublished(t *testing.T) {
+	bc, sub := newTestChainWithEvents(t)
+	genesis := tip(t, bc)
+
+	// the genesis block is connected when the chain is created
+	if event := nextEvent(t, sub); event.Type != events.NewBlock || !equalHash(event.Payload.(*Block), genesis) {
+		t.Fatalf("got %s event, want genesis newBlock", event.Type)
+	}
+
+	tx := send(t, bc, alice, bob, 10, 1)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(t, sub); event.Type != events.NewTx || hex.EncodeToString(event.Payload.(*Transaction).ID) != hex.EncodeToString(tx.ID) {
+		t.Fatalf("got %s event, want newTx", event.Type)
+	}
+
+	mined := minePending(t, bc, alice)
+	if event := nextEvent(t, sub); event.Type != events.NewBlock || !equalHash(event.Payload.(*Block), mined) {
+		t.Fatalf("got %s event, want newBlock", event.Type)
+	}
+
+	// a heavier side chain from genesis switches the best chain
+	side1 := mineOn(t, bc, genesis, carol)
+	if err := bc.AcceptBlock(side1); err != nil {
+		t.Fatal(err)
+	}
+	side2 := mineOn(t, bc, side1, carol)
+	if err := bc.AcceptBlock(side2); err != nil {
+		t.Fatal(err)
+	}
+
+	event := nextEvent(t, sub)
+	if event.Type != events.Reorg {
+		t.Fatalf("got %s event, want reorg", event.Type)
+	}
+	reorg := event.Payload.(reorgEvent)
+	if len(reorg.Disconnected) != 1 || reorg.Disconnected[0] != hex.EncodeToString(mined.Hash) {
+		t.Fatalf("got disconnected %v", reorg.Disconnected)
+	}
+	if len(reorg.Connected) != 2 || reorg.Connected[0] != hex.EncodeToString(side1.Hash) ||
+		reorg.Connected[1] != hex.EncodeToString(side2.Hash) {
+		t.Fatalf("got connected %v", reorg.Connected)
+	}
+	for _, want := range []*Block{side1, side2} {
+		if event := nextEvent(t, sub); event.Type != events.NewBlock || !equalHash(event.Payload.(*Block), want) {
+			t.Fatalf("got %s event, want newBlock %x", event.Type, want.Hash)
+		}
+	}
+}
+
+// equalHash returns whether two blocks have the same hash.
+func equalHash(a, b *Block) bool {
+	return hex.EncodeToString(a.Hash) == hex.EncodeToString(b.Hash)
+}
@@ -0,0 +1,77 @@
+package blockchain_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// This example creates a throwaway chain whose genesis block allocates
+// coins to a wallet, and reads the balance of the wallet.
+func ExampleInitInMemory() {
+	alice := wallet.NewFromSeed([]byte("alice"))
+	bc, err := blockchain.InitInMemory(&blockchain.Genesis{
+		Network:     "example",
+		Allocations: map[string]units.Amount{alice.Address().String(): 50 * units.Coin},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer bc.Close()
+
+	balances, err := bc.Balances(wallet.GeneratePublicKeyHash(alice.PublicKey), 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("height:", bc.Height())
+	fmt.Println("alice:", units.FormatAmount(balances.Trusted))
+	// Output:
+	// height: 0
+	// alice: 50
+}
+
+// This example sends coins from one wallet to another, adds the payment to
+// the mempool and mines it into a block rewarding a third wallet.
+func ExampleBlockChain_NewTransaction() {
+	alice := wallet.NewFromSeed([]byte("alice"))
+	bob := wallet.NewFromSeed([]byte("bob"))
+	miner := wallet.NewFromSeed([]byte("miner"))
+	bc, err := blockchain.InitInMemory(&blockchain.Genesis{
+		Network:     "example",
+		Allocations: map[string]units.Amount{alice.Address().String(): 50 * units.Coin},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer bc.Close()
+
+	// pay bob 10 coins with a fee of 0.01, sending the change back to alice
+	tx, err := bc.NewTransaction(alice, bob.Address().String(), 10*units.Coin, units.Coin/100, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := bc.AddToMempool(tx); err != nil {
+		log.Fatal(err)
+	}
+	block, err := bc.MinePending(miner.Address().String())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, w := range []*wallet.Wallet{alice, bob, miner} {
+		balances, err := bc.Balances(wallet.GeneratePublicKeyHash(w.PublicKey), 1)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(units.FormatAmount(balances.Trusted + balances.Immature))
+	}
+	fmt.Println("transactions in block:", len(block.Transactions))
+	// Output:
+	// 39.99
+	// 10
+	// 100.01
+	// transactions in block: 2
+}
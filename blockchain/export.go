@@ -0,0 +1,240 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dgraph-io/badger"
+)
+
+// exportMagic identifies a file written by ExportChain.
+var exportMagic = []byte("GOCHAIN1")
+
+// maxExportedBlockSize is the largest block size accepted by ImportChain.
+const maxExportedBlockSize = 32 << 20
+
+// ExportChain writes the blocks of the best chain to w in height order. Each
+// block is written as its length followed by the serialized block.
+func (bc *BlockChain) ExportChain(w io.Writer) (int, error) {
+	buffered := bufio.NewWriter(w)
+
+	if _, err := buffered.Write(exportMagic); err != nil {
+		return 0, err
+	}
+
+	// write each block from genesis to the tip
+	blocks := 0
+	err := bc.IterateForward(func(block *Block) error {
+		if block.Pruned() {
+			return fmt.Errorf("unable to export pruned block %x", block.Hash)
+		}
+		data := block.Serialize()
+		if _, err := buffered.Write(ToBytes(int64(len(data)))); err != nil {
+			return err
+		}
+		if _, err := buffered.Write(data); err != nil {
+			return err
+		}
+		blocks++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return blocks, buffered.Flush()
+}
+
+// ImportChain creates a new blockchain in the database at cfg.Path from
+// blocks written by ExportChain. Every block is validated as it is
+// replayed, and the genesis block must match cfg.Genesis if it is set. The
+// database must not already contain a blockchain.
+func ImportChain(cfg Config, r io.Reader) (*BlockChain, error) {
+	return importChain(cfg, r, nil)
+}
+
+// ImportChainFromUTXOSet creates a new blockchain like ImportChain, but
+// starts from set, such as one written by UTXOSet.WriteTo on another node,
+// instead of validating the transactions of the blocks up to its height.
+// Those blocks are only checked to be a chain of valid proofs of work
+// leading to the block of the set, and are stored pruned, and the blocks
+// after it are validated as they are replayed. The set must have
+// commitment, which should come from a source trusted to have checked it,
+// as the set is not verified against the blocks.
+func ImportChainFromUTXOSet(cfg Config, r io.Reader, set *UTXOSet, commitment Hash) (*BlockChain, error) {
+	if got := set.Commitment(); got != commitment {
+		return nil, fmt.Errorf("UTXO set has commitment %s, not %s", got, commitment)
+	}
+	return importChain(cfg, r, set)
+}
+
+// importChain creates a new blockchain from blocks written by ExportChain,
+// starting from set after the block at its height if it is not nil.
+func importChain(cfg Config, r io.Reader, set *UTXOSet) (*BlockChain, error) {
+	buffered := bufio.NewReader(r)
+
+	// check the file format
+	magic := make([]byte, len(exportMagic))
+	if _, err := io.ReadFull(buffered, magic); err != nil || !bytes.Equal(magic, exportMagic) {
+		return nil, errors.New("file is not a chain export")
+	}
+
+	// read and validate the genesis block
+	genesis, err := readExportedBlock(buffered)
+	if err != nil {
+		return nil, err
+	}
+	if genesis == nil {
+		return nil, errors.New("chain export is empty")
+	}
+	if len(genesis.PrevHash) != 0 || genesis.Height != 0 || !NewProof(genesis).Validate() {
+		return nil, fmt.Errorf("genesis block %x is invalid", genesis.Hash)
+	}
+	if cfg.Genesis != nil && !cfg.Genesis.Matches(genesis) {
+		return nil, fmt.Errorf("genesis block %x does not match network %s", genesis.Hash, cfg.Genesis.Network)
+	}
+
+	// store the genesis block in a new database
+	scheme, err := cfg.SignatureScheme()
+	if err != nil {
+		return nil, err
+	}
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte("lh")); err != badger.ErrKeyNotFound {
+			return errors.New("database already contains a blockchain")
+		}
+		return storeGenesis(txn, genesis)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	bc := &BlockChain{
+		tip:            genesis.Hash,
+		DB:             db,
+		rules:          cfg.DifficultyRules(),
+		scheme:         scheme,
+		pruneDepth:     cfg.PruneDepth,
+		log:            cfg.logger(),
+		checkpoints:    sortCheckpoints(cfg.Checkpoints),
+		path:           cfg.Path,
+		gcDiscardRatio: cfg.gcDiscardRatio(),
+	}
+
+	// replay the rest of the blocks, each of which must extend the tip,
+	// storing the headers of those up to the UTXO set and loading the set
+	// once its block is the tip
+	parent := genesis
+	loaded := set == nil
+	for {
+		if !loaded && parent.Height == set.Height {
+			if err := bc.loadUTXOSet(set, parent); err != nil {
+				return bc, err
+			}
+			loaded = true
+		}
+		block, err := readExportedBlock(buffered)
+		if err != nil {
+			return bc, err
+		}
+		if block == nil {
+			break
+		}
+		if !bytes.Equal(block.PrevHash, bc.Tip()) {
+			return bc, fmt.Errorf("block %x does not extend block %x", block.Hash, bc.Tip())
+		}
+		if !loaded {
+			err = bc.connectHeader(block, parent)
+		} else {
+			err = bc.AcceptBlock(block)
+		}
+		if err != nil {
+			return bc, err
+		}
+		parent = block
+	}
+	if !loaded {
+		return bc, fmt.Errorf("chain export ends at height %d, below the UTXO set at height %d", parent.Height, set.Height)
+	}
+
+	return bc, nil
+}
+
+// RepairFromExport restores the corrupt block records with the blocks
+// written by ExportChain to r, returning the number of blocks repaired and
+// the hashes of corrupt blocks the export does not have.
+func (bc *BlockChain) RepairFromExport(r io.Reader) (int, [][]byte, error) {
+	corrupt, err := bc.CorruptBlocks()
+	if err != nil || len(corrupt) == 0 {
+		return 0, nil, err
+	}
+	missing := make(map[string]bool, len(corrupt))
+	for _, hash := range corrupt {
+		missing[string(hash)] = true
+	}
+
+	// check the file format
+	buffered := bufio.NewReader(r)
+	magic := make([]byte, len(exportMagic))
+	if _, err := io.ReadFull(buffered, magic); err != nil || !bytes.Equal(magic, exportMagic) {
+		return 0, nil, errors.New("file is not a chain export")
+	}
+
+	// repair each corrupt block found in the export
+	repaired := 0
+	for len(missing) > 0 {
+		block, err := readExportedBlock(buffered)
+		if err != nil {
+			return repaired, nil, err
+		}
+		if block == nil {
+			break
+		}
+		if !missing[string(block.Hash)] {
+			continue
+		}
+		if err := bc.RepairBlock(block); err != nil {
+			return repaired, nil, err
+		}
+		delete(missing, string(block.Hash))
+		repaired++
+	}
+
+	var unrepaired [][]byte
+	for _, hash := range corrupt {
+		if missing[string(hash)] {
+			unrepaired = append(unrepaired, hash)
+		}
+	}
+	return repaired, unrepaired, nil
+}
+
+// readExportedBlock reads the next block written by ExportChain, returning
+// nil at the end of the file.
+func readExportedBlock(r io.Reader) (*Block, error) {
+	size := make([]byte, 8)
+	if _, err := io.ReadFull(r, size); err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New("unable to read block size - " + err.Error())
+	}
+
+	length := FromBytes(size)
+	if length <= 0 || length > maxExportedBlockSize {
+		return nil, fmt.Errorf("block size %d is invalid", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, errors.New("unable to read block - " + err.Error())
+	}
+
+	return Deserialize(data), nil
+}
@@ -0,0 +1,146 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// FeeEstimateBlocks is the number of recent blocks whose fee rates are
+// sampled by EstimateFee.
+const FeeEstimateBlocks = 100
+
+// FeeEstimate is a fee rate suggested for a transaction to be mined within
+// TargetBlocks blocks. Rates are in base units per byte.
+type FeeEstimate struct {
+	TargetBlocks int     `json:"targetBlocks"`
+	FeeRate      float64 `json:"feeRate"`
+
+	// RecentRate is the median fee rate of the Samples transactions mined
+	// in the last Blocks blocks, leaving out pruned blocks
+	RecentRate float64 `json:"recentRate"`
+	Blocks     int     `json:"blocks"`
+	Samples    int     `json:"samples"`
+
+	// CongestionRate is the fee rate needed to be among the pending
+	// transactions that fill the next TargetBlocks blocks, or 0 if the
+	// MempoolSize bytes pending fit in them
+	CongestionRate float64 `json:"congestionRate"`
+	MempoolSize    int     `json:"mempoolSize"`
+}
+
+// Fee returns the fee suggested for a transaction of size bytes.
+func (e FeeEstimate) Fee(size int) units.Amount {
+	return units.Amount(math.Ceil(e.FeeRate * float64(size)))
+}
+
+// feeRate is the fee rate and size of a transaction.
+type feeRate struct {
+	rate float64
+	size int
+}
+
+// blockFeeRates returns the fee rates of the transactions of a block other
+// than the coinbase, from the outputs they spent in its undo record, which holds
+// them in input order. A fee is what the spent outputs held beyond the
+// outputs of the transaction spending them.
+func blockFeeRates(block *Block, spent []spentOutput) []float64 {
+	var rates []float64
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+		var fee units.Amount
+		for range tx.Inputs {
+			fee += spent[0].Output.Value
+			spent = spent[1:]
+		}
+		for _, out := range tx.Outputs {
+			fee -= out.Value
+		}
+		rates = append(rates, float64(fee)/float64(len(tx.Serialize())))
+	}
+	return rates
+}
+
+// EstimateFee suggests a fee rate for a transaction to be mined within
+// targetBlocks blocks. It is the higher of the median fee rate paid in the
+// last FeeEstimateBlocks blocks and the rate a transaction would need to
+// get ahead of the mempool backlog if blocks took the best paying pending
+// transactions first. It is 0 while no fees have been paid and the mempool
+// isn't congested.
+func (bc *BlockChain) EstimateFee(targetBlocks int) (FeeEstimate, error) {
+	if targetBlocks < 1 {
+		return FeeEstimate{}, fmt.Errorf("target of %d blocks is not positive", targetBlocks)
+	}
+	estimate := FeeEstimate{TargetBlocks: targetBlocks}
+
+	// sample the fee rates of the transactions of recent blocks
+	var recent []float64
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		tip, err := getBlock(txn, bc.Tip())
+		if err != nil {
+			return errors.New("unable to get tip - " + err.Error())
+		}
+		for height := tip.Height; height > 0 && height > tip.Height-FeeEstimateBlocks; height-- {
+			item, err := txn.Get(heightKey(height))
+			if err != nil {
+				return fmt.Errorf("no block at height %d - %s", height, err.Error())
+			}
+			hash, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			block, err := getBlock(txn, hash)
+			if err != nil {
+				return err
+			}
+			if block.Pruned() {
+				continue
+			}
+			spent, err := getUndo(txn, block.Hash)
+			if err != nil {
+				return err
+			}
+			estimate.Blocks++
+			recent = append(recent, blockFeeRates(block, spent)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return FeeEstimate{}, err
+	}
+	estimate.Samples = len(recent)
+	estimate.RecentRate = newPercentiles(recent).P50
+
+	// find the rate of the last pending transaction that fits in the next
+	// target blocks when the best paying are taken first
+	var pending []feeRate
+	for _, tx := range bc.MempoolTransactions() {
+		fee, err := bc.TransactionFee(tx)
+		if err != nil {
+			continue
+		}
+		size := len(tx.Serialize())
+		pending = append(pending, feeRate{float64(fee) / float64(size), size})
+		estimate.MempoolSize += size
+	}
+	if estimate.MempoolSize > targetBlocks*MaxBlockSize {
+		sort.Slice(pending, func(i, j int) bool { return pending[i].rate > pending[j].rate })
+		room := targetBlocks * MaxBlockSize
+		for _, p := range pending {
+			if p.size > room {
+				break
+			}
+			room -= p.size
+			estimate.CongestionRate = p.rate
+		}
+	}
+
+	estimate.FeeRate = math.Max(estimate.RecentRate, estimate.CongestionRate)
+	return estimate, nil
+}
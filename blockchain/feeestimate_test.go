@@ -0,0 +1,46 @@
+package blockchain
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestEstimateFee(t *testing.T) {
+	bc := newTestChain(t)
+	if _, err := bc.EstimateFee(0); err == nil {
+		t.Fatal("estimated a fee for a target of 0 blocks")
+	}
+
+	// nothing is suggested before fees are paid
+	estimate, err := bc.EstimateFee(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.FeeRate != 0 || estimate.Fee(250) != 0 || estimate.Samples != 0 {
+		t.Fatalf("got %+v before any fees were paid", estimate)
+	}
+
+	// the median rate paid in recent blocks is suggested
+	var rates []float64
+	for _, fee := range []int{100, 500, 300} {
+		tx := send(t, bc, alice, bob, 1, units.Amount(fee))
+		if err := bc.AddToMempool(tx); err != nil {
+			t.Fatal(err)
+		}
+		rates = append(rates, float64(fee)/float64(len(tx.Serialize())))
+		minePending(t, bc, carol)
+	}
+	sort.Float64s(rates)
+	estimate, err = bc.EstimateFee(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.Blocks != 3 || estimate.Samples != 3 || estimate.RecentRate != rates[1] || estimate.FeeRate != rates[1] {
+		t.Fatalf("got %+v, want a rate of %v from 3 blocks", estimate, rates[1])
+	}
+	if estimate.CongestionRate != 0 {
+		t.Fatalf("got a congestion rate of %v with an empty mempool", estimate.CongestionRate)
+	}
+}
@@ -0,0 +1,26 @@
+package blockchain
+
+import (
+	"fmt"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// FeeFilter returns the lowest fee rate, in base units per byte, of the
+// transactions the mempool takes: the MinFeeRate of the mempool limits,
+// raised to the lowest fee rate pending while the mempool is at its count
+// or size limit, since a transaction paying less would be evicted as soon
+// as it entered. Nodes advertise it so wallets and relays don't send
+// transactions that would be refused.
+func (bc *BlockChain) FeeFilter() float64 {
+	return bc.MempoolInfo().FeeFilter
+}
+
+// checkFeeFilter verifies that a transaction of size bytes paying fee pays
+// at least the MinFeeRate of the mempool limits.
+func (bc *BlockChain) checkFeeFilter(fee units.Amount, size int) error {
+	minRate := bc.mempoolLimits.MinFeeRate
+	if rate := float64(fee) / float64(size); rate < minRate {
+		return fmt.Errorf("transaction pays %g per byte, less than the minimum relay fee rate of %g", rate, minRate)
+	}
+	return nil
+}
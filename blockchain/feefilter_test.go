@@ -0,0 +1,27 @@
+package blockchain
+
+import "testing"
+
+func TestFeeFilter(t *testing.T) {
+	bc := newFundedTestChain(t, MempoolLimits{MaxCount: 1, MinFeeRate: 0.1})
+	if rate := bc.FeeFilter(); rate != 0.1 {
+		t.Fatalf("got fee filter %g, want the minimum fee rate", rate)
+	}
+
+	// transactions paying less than the minimum fee rate are refused
+	cheap := send(t, bc, alice, bob, 100, 10)
+	err := bc.AddToMempool(cheap)
+	if rejected, ok := err.(*RejectError); !ok || rejected.Rule != RejectFeeFilter {
+		t.Fatalf("got error %v, want the transaction refused by the fee filter", err)
+	}
+
+	// a full mempool raises the filter to its lowest fee rate
+	tx := send(t, bc, alice, bob, 100, 200)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	want := 200 / float64(len(tx.Serialize()))
+	if rate := bc.FeeFilter(); rate != want {
+		t.Fatalf("got fee filter %g, want the fee rate %g of the pending transaction", rate, want)
+	}
+}
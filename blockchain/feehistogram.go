@@ -0,0 +1,120 @@
+package blockchain
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// FeeBands are the lowest fee rates of the bands of the mempool fee
+// histogram, in base units per byte.
+var FeeBands = []float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+// FeeBand is a band of the mempool fee histogram: the pending transactions
+// paying at least MinRate base units per byte, and less than the MinRate of
+// the next band, and their serialized size in bytes.
+type FeeBand struct {
+	MinRate      float64 `json:"minRate"`
+	Transactions int     `json:"transactions"`
+	Bytes        int     `json:"bytes"`
+}
+
+// histogramEntry is the band and size of a pending transaction counted in
+// the fee histogram.
+type histogramEntry struct {
+	band int
+	size int
+}
+
+// feeHistogram counts the pending transactions in each fee band, updated
+// as transactions enter and leave the mempool so it isn't recomputed for
+// every query. It is built from the mempool when first read, and again
+// after a reorg returns transactions to the mempool, whose fees aren't
+// known without looking up the outputs they spend.
+type feeHistogram struct {
+	mutex   sync.Mutex
+	built   bool
+	entries map[string]histogramEntry
+	bands   []FeeBand
+}
+
+// feeBand returns the index of the band of FeeBands holding rate.
+func feeBand(rate float64) int {
+	return sort.Search(len(FeeBands), func(i int) bool { return FeeBands[i] > rate }) - 1
+}
+
+// add counts a transaction entering the mempool if the histogram is built.
+func (h *feeHistogram) add(tx *Transaction, fee units.Amount) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.built {
+		h.count(tx, fee)
+	}
+}
+
+// count adds a transaction to its band.
+func (h *feeHistogram) count(tx *Transaction, fee units.Amount) {
+	if _, ok := h.entries[string(tx.ID)]; ok {
+		return
+	}
+	size := len(tx.Serialize())
+	entry := histogramEntry{band: feeBand(float64(fee) / float64(size)), size: size}
+	if entry.band < 0 {
+		entry.band = 0
+	}
+	h.entries[string(tx.ID)] = entry
+	h.bands[entry.band].Transactions++
+	h.bands[entry.band].Bytes += size
+}
+
+// remove uncounts transactions leaving the mempool, such as the
+// transactions of a connected block. Transactions not in the mempool are
+// ignored.
+func (h *feeHistogram) remove(txs []*Transaction) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, tx := range txs {
+		entry, ok := h.entries[string(tx.ID)]
+		if !ok {
+			continue
+		}
+		delete(h.entries, string(tx.ID))
+		h.bands[entry.band].Transactions--
+		h.bands[entry.band].Bytes -= entry.size
+	}
+}
+
+// reset makes the histogram be built again when next read.
+func (h *feeHistogram) reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.built = false
+}
+
+// FeeHistogram returns the pending transactions and their size in each band
+// of FeeBands, from the lowest fee rate to the highest, so wallets can see
+// the fee rate needed to outbid the backlog.
+func (bc *BlockChain) FeeHistogram() []FeeBand {
+	h := &bc.feeHistogram
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if !h.built {
+		h.entries = make(map[string]histogramEntry)
+		h.bands = make([]FeeBand, len(FeeBands))
+		for i, rate := range FeeBands {
+			h.bands[i].MinRate = rate
+		}
+		for _, tx := range bc.MempoolTransactions() {
+			fee, err := bc.TransactionFee(tx)
+			if err != nil {
+				continue
+			}
+			h.count(tx, fee)
+		}
+		h.built = true
+	}
+
+	return append([]FeeBand{}, h.bands...)
+}
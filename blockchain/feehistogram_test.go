@@ -0,0 +1,54 @@
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestFeeHistogram(t *testing.T) {
+	bc := newTestChain(t)
+	pending := func(bands []FeeBand) (txs, size int) {
+		for _, band := range bands {
+			txs += band.Transactions
+			size += band.Bytes
+		}
+		return txs, size
+	}
+	if txs, _ := pending(bc.FeeHistogram()); txs != 0 {
+		t.Fatalf("got %d transactions in an empty mempool", txs)
+	}
+
+	// transactions are counted in the band of their fee rate as they
+	// enter the mempool
+	cheap := send(t, bc, alice, bob, 10, 1)
+	if err := bc.AddToMempool(cheap); err != nil {
+		t.Fatal(err)
+	}
+	size := len(cheap.Serialize())
+	dear := send(t, bc, alice, carol, 10, units.Amount(25*size))
+	if err := bc.AddToMempool(dear); err != nil {
+		t.Fatal(err)
+	}
+	bands := bc.FeeHistogram()
+	if bands[0].Transactions != 1 || bands[0].Bytes != size || bands[feeBand(25)].Transactions != 1 {
+		t.Fatalf("got %+v, want one transaction under 1/byte and one at 25/byte", bands)
+	}
+
+	// they leave it when mined, matching the histogram built from scratch
+	minePending(t, bc, alice)
+	bands = bc.FeeHistogram()
+	if txs, _ := pending(bands); txs != 0 {
+		t.Fatalf("got %d transactions after mining them", txs)
+	}
+	later := send(t, bc, bob, carol, 5, 1)
+	if err := bc.AddToMempool(later); err != nil {
+		t.Fatal(err)
+	}
+	bands = bc.FeeHistogram()
+	bc.feeHistogram.reset()
+	if rebuilt := bc.FeeHistogram(); !reflect.DeepEqual(rebuilt, bands) {
+		t.Fatalf("got %+v, want %+v as rebuilt", bands, rebuilt)
+	}
+}
@@ -0,0 +1,40 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestMinedFees(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		fees []units.Amount
+		want units.Amount
+	}{
+		{"mined with fees", []units.Amount{3, 4}, Subsidy + 7},
+		{"mined without fees", nil, Subsidy},
+		{"mined with zero fees", []units.Amount{0}, Subsidy},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bc := newTestChain(t)
+			for _, fee := range c.fees {
+				tx := send(t, bc, alice, bob, 10, fee)
+				if got, err := bc.TransactionFee(tx); err != nil || got != fee {
+					t.Fatalf("got fee %d, %v, want %d", got, err, fee)
+				}
+				if err := bc.AddToMempool(tx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			block := minePending(t, bc, carol)
+			if !block.Transactions[0].IsCoinbase() {
+				t.Fatal("first transaction is not a coinbase")
+			}
+			if got := balance(t, bc, carol); got != c.want {
+				t.Fatalf("miner has %d, want %d", got, c.want)
+			}
+		})
+	}
+}
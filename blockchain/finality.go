@@ -0,0 +1,33 @@
+package blockchain
+
+// FinalityDepth returns the number of confirmations after which blocks of
+// the best chain are final, or 0 if blocks are never final.
+func (bc *BlockChain) FinalityDepth() int {
+	return bc.finalityDepth
+}
+
+// Final returns whether a block or transaction with the given number of
+// confirmations is final, so it can't be reorganized away.
+func (bc *BlockChain) Final(confirmations int) bool {
+	return bc.finalityDepth > 0 && confirmations >= bc.finalityDepth
+}
+
+// FinalHeight returns the height of the highest final block of the best
+// chain, or -1 if no block is final. Side chains forking below it are
+// refused.
+func (bc *BlockChain) FinalHeight() int {
+	if bc.finalityDepth <= 0 || bc.Height() < bc.finalityDepth-1 {
+		return -1
+	}
+	return bc.Height() - bc.finalityDepth + 1
+}
+
+// BlockConfirmations returns how many blocks deep a block of the best chain
+// is buried, where the tip has one confirmation, or 0 for a block of a side
+// chain.
+func (bc *BlockChain) BlockConfirmations(block *Block) int {
+	if !bc.inBestChain(block) {
+		return 0
+	}
+	return bc.Height() - block.Height + 1
+}
@@ -0,0 +1,45 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFinalityDepth(t *testing.T) {
+	bc := newTestChainWithConfig(t, Config{FinalityDepth: 2})
+	genesis := tip(t, bc)
+	if bc.FinalHeight() != -1 || bc.Final(bc.BlockConfirmations(genesis)) {
+		t.Fatalf("got final height %d with only the genesis block", bc.FinalHeight())
+	}
+	var chain []*Block
+	for parent := genesis; len(chain) < 2; parent = chain[len(chain)-1] {
+		block := mineOn(t, bc, parent, alice)
+		if err := bc.AcceptBlock(block); err != nil {
+			t.Fatal(err)
+		}
+		chain = append(chain, block)
+	}
+
+	// blocks with two confirmations are final
+	if bc.FinalHeight() != 1 || !bc.Final(bc.BlockConfirmations(chain[0])) || bc.Final(bc.BlockConfirmations(chain[1])) {
+		t.Fatalf("got final height %d, want 1", bc.FinalHeight())
+	}
+
+	// a side chain replacing a final block is refused
+	side := mineOn(t, bc, genesis, carol)
+	if err := bc.AcceptBlock(side); err == nil || !strings.Contains(err.Error(), "below the final block") {
+		t.Fatalf("got error %v, want the fork to be refused", err)
+	}
+
+	// one replacing only blocks that are not final yet wins with more work
+	side = mineOn(t, bc, chain[0], carol)
+	if err := bc.AcceptBlock(side); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AcceptBlock(mineOn(t, bc, side, carol)); err != nil {
+		t.Fatal(err)
+	}
+	if bc.Height() != 3 || bc.BlockConfirmations(chain[1]) != 0 || !bc.Final(bc.BlockConfirmations(side)) {
+		t.Fatalf("got height %d, want the side chain to be the best chain", bc.Height())
+	}
+}
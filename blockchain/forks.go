@@ -0,0 +1,293 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+)
+
+var (
+	// forkTipPrefix is the key prefix of the tips of the side chains, the
+	// stored blocks outside the best chain that no stored block builds on.
+	forkTipPrefix = []byte("forktip-")
+
+	// forkTipsIndexedKey is set once the side chain tips of the database
+	// are recorded, so those of databases created before they were are
+	// found once.
+	forkTipsIndexedKey = []byte("forktipsindexed")
+
+	// reorgPrefix is the key prefix of the records of reorganizations.
+	reorgPrefix = []byte("reorg-")
+
+	// reorgSeqKey holds the sequence number of the latest reorganization.
+	reorgSeqKey = []byte("reorgseq")
+)
+
+// forkTipKey returns the db key marking a block as the tip of a side chain.
+func forkTipKey(hash []byte) []byte {
+	return append(append([]byte{}, forkTipPrefix...), hash...)
+}
+
+// reorgKey returns the db key of the record of a reorganization with a
+// sequence number, which orders the keys by it.
+func reorgKey(seq int64) []byte {
+	return append(append([]byte{}, reorgPrefix...), ToBytes(seq)...)
+}
+
+// ChainTip is the tip of a chain the node knows of: the best chain, or a
+// side chain competing with it.
+type ChainTip struct {
+	Hash   []byte
+	Height int
+
+	// Work is the cumulative work of the chain ending at the tip
+	Work *big.Int
+
+	// ForkHeight is the height of the block of the best chain the chain
+	// branches off at, and BranchLength the number of blocks it has
+	// above it, which is zero for the best chain
+	ForkHeight   int
+	BranchLength int
+
+	// Active is set for the tip of the best chain
+	Active bool
+}
+
+// ReorgRecord is a reorganization of the best chain, kept in the database
+// and appended to the reorg log.
+type ReorgRecord struct {
+	Seq        int64  `json:"seq"`
+	Time       int64  `json:"time"`
+	OldTip     string `json:"oldTip"`
+	OldHeight  int    `json:"oldHeight"`
+	NewTip     string `json:"newTip"`
+	NewHeight  int    `json:"newHeight"`
+	Fork       string `json:"fork"`
+	ForkHeight int    `json:"forkHeight"`
+
+	// Depth is the number of blocks that left the best chain, and
+	// Connected the number that joined it
+	Depth     int `json:"depth"`
+	Connected int `json:"connected"`
+}
+
+// setForkTip records block as the tip of a side chain in place of its
+// parent, which it builds on.
+func setForkTip(txn *badger.Txn, block *Block) error {
+	if err := txn.Delete(forkTipKey(block.PrevHash)); err != nil {
+		return err
+	}
+	return txn.Set(forkTipKey(block.Hash), nil)
+}
+
+// recordReorg stores record with the next sequence number, which it sets.
+func recordReorg(txn *badger.Txn, record *ReorgRecord) error {
+	item, err := txn.Get(reorgSeqKey)
+	if err == nil {
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		record.Seq = FromBytes(value)
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+	record.Seq++
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := txn.Set(reorgKey(record.Seq), data); err != nil {
+		return err
+	}
+	return txn.Set(reorgSeqKey, ToBytes(record.Seq))
+}
+
+// appendReorgLog appends record to the reorg log as a line of JSON, if the
+// chain keeps one.
+func (bc *BlockChain) appendReorgLog(record ReorgRecord) error {
+	if bc.reorgLogPath == "" {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(bc.reorgLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Reorgs returns the latest n reorganizations of the best chain, or all of
+// them if n is zero, oldest first.
+func (bc *BlockChain) Reorgs(n int) ([]ReorgRecord, error) {
+	var records []ReorgRecord
+
+	// initiate read only transaction on db to iterate over the records
+	// from the latest
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(reorgKey(1 << 62)); it.ValidForPrefix(reorgPrefix); it.Next() {
+			if n > 0 && len(records) == n {
+				break
+			}
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var record ReorgRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return errors.New("unable to decode reorg record - " + err.Error())
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// return them oldest first
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// ChainTips returns the tip of the best chain followed by the tips of the
+// side chains competing with it, by cumulative work from the most.
+func (bc *BlockChain) ChainTips() ([]ChainTip, error) {
+	hashes := [][]byte{bc.Tip()}
+
+	// initiate read only transaction on db to collect the side chain tips
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(forkTipPrefix); it.ValidForPrefix(forkTipPrefix); it.Next() {
+			hashes = append(hashes, it.Item().KeyCopy(nil)[len(forkTipPrefix):])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tips := make([]ChainTip, 0, len(hashes))
+	for i, hash := range hashes {
+		tip, err := bc.chainTip(hash)
+		if err != nil {
+			return nil, err
+		}
+		tip.Active = i == 0
+		tips = append(tips, tip)
+	}
+	sort.SliceStable(tips[1:], func(i, j int) bool {
+		return tips[1+i].Work.Cmp(tips[1+j].Work) > 0
+	})
+	return tips, nil
+}
+
+// chainTip describes the chain ending at the block hash, walking it back to
+// where it joins the best chain.
+func (bc *BlockChain) chainTip(hash []byte) (ChainTip, error) {
+	block, err := bc.GetBlock(hash)
+	if err != nil {
+		return ChainTip{}, err
+	}
+	tip := ChainTip{Hash: block.Hash, Height: block.Height}
+	err = bc.DB.View(func(txn *badger.Txn) error {
+		var err error
+		tip.Work, err = getChainWork(txn, block.Hash)
+		return err
+	})
+	if err != nil {
+		return ChainTip{}, errors.New("unable to read chain work - " + err.Error())
+	}
+
+	// walk back until the block at the height of the best chain is reached
+	for {
+		best, err := bc.GetBlockByHeight(block.Height)
+		if err == nil && bytes.Equal(best.Hash, block.Hash) {
+			break
+		}
+		if block, err = bc.GetBlock(block.PrevHash); err != nil {
+			return ChainTip{}, err
+		}
+	}
+	tip.ForkHeight = block.Height
+	tip.BranchLength = tip.Height - block.Height
+	return tip, nil
+}
+
+// indexForkTips records the tips of the side chains of a database created
+// before they were recorded, which are the stored blocks no stored block
+// builds on other than the tip of the best chain.
+func (bc *BlockChain) indexForkTips() error {
+	return bc.DB.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(forkTipsIndexedKey); err == nil {
+			return nil
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		// every stored block has its cumulative work stored
+		blocks := make(map[string]bool)
+		parents := make(map[string]bool)
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		for it.Seek(workPrefix); it.ValidForPrefix(workPrefix); it.Next() {
+			hash := it.Item().KeyCopy(nil)[len(workPrefix):]
+			block, err := getBlock(txn, hash)
+			if err != nil {
+				it.Close()
+				return err
+			}
+			blocks[string(hash)] = true
+			parents[string(block.PrevHash)] = true
+		}
+		it.Close()
+
+		for hash := range blocks {
+			if parents[hash] || hash == string(bc.Tip()) {
+				continue
+			}
+			if err := txn.Set(forkTipKey([]byte(hash)), nil); err != nil {
+				return err
+			}
+		}
+		return txn.Set(forkTipsIndexedKey, nil)
+	})
+}
+
+// forkTipsIndexed returns whether the side chain tips of the database are
+// recorded.
+func (bc *BlockChain) forkTipsIndexed() (bool, error) {
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(forkTipsIndexedKey)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
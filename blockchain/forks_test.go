@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/logging"
+)
+
+func TestForksAndReorgLog(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Path: filepath.Join(dir, "db"), Genesis: testGenesis(), Logger: logging.Discard, ReorgLogPath: filepath.Join(dir, "reorgs.log")}
+	bc, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { bc.Close() }()
+	genesis := tip(t, bc)
+
+	// a side chain from the genesis block overtakes the best chain
+	old := minePending(t, bc, alice)
+	side := mineOn(t, bc, genesis, carol)
+	if err := bc.AcceptBlock(side); err != nil {
+		t.Fatal(err)
+	}
+	if tips, err := bc.ChainTips(); err != nil || len(tips) != 2 || !bytes.Equal(tips[1].Hash, side.Hash) {
+		t.Fatalf("got tips %+v, %v, want the side block competing", tips, err)
+	}
+	newTip := mineOn(t, bc, side, carol)
+	if err := bc.AcceptBlock(newTip); err != nil {
+		t.Fatal(err)
+	}
+
+	// the old tip is now the only side chain, branching off the genesis
+	// block
+	tips, err := bc.ChainTips()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChainTip{
+		{Hash: newTip.Hash, Height: 2, ForkHeight: 2, Active: true},
+		{Hash: old.Hash, Height: 1, ForkHeight: 0, BranchLength: 1},
+	}
+	if len(tips) != len(want) {
+		t.Fatalf("got %d tips, want %d", len(tips), len(want))
+	}
+	for i, w := range want {
+		got := tips[i]
+		if !bytes.Equal(got.Hash, w.Hash) || got.Height != w.Height || got.ForkHeight != w.ForkHeight || got.BranchLength != w.BranchLength || got.Active != w.Active {
+			t.Errorf("tip %d: got %x at %d forking at %d by %d, active %v, want %x at %d forking at %d by %d, active %v",
+				i, got.Hash, got.Height, got.ForkHeight, got.BranchLength, got.Active, w.Hash, w.Height, w.ForkHeight, w.BranchLength, w.Active)
+		}
+	}
+	if tips[0].Work.Cmp(tips[1].Work) <= 0 {
+		t.Errorf("best chain has work %s, not more than the side chain's %s", tips[0].Work, tips[1].Work)
+	}
+
+	// the reorg is kept in the database and appended to the log
+	wantRecord := ReorgRecord{
+		Seq:        1,
+		OldTip:     hex.EncodeToString(old.Hash),
+		OldHeight:  1,
+		NewTip:     hex.EncodeToString(newTip.Hash),
+		NewHeight:  2,
+		Fork:       hex.EncodeToString(genesis.Hash),
+		ForkHeight: 0,
+		Depth:      1,
+		Connected:  2,
+	}
+	records, err := bc.Reorgs(0)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("got reorgs %+v, %v, want one", records, err)
+	}
+	records[0].Time = 0
+	if records[0] != wantRecord {
+		t.Fatalf("got reorg %+v, want %+v", records[0], wantRecord)
+	}
+	data, err := ioutil.ReadFile(cfg.ReorgLogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var logged ReorgRecord
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &logged) != nil || logged.NewTip != wantRecord.NewTip || logged.Depth != 1 {
+		t.Fatalf("got reorg log %q", data)
+	}
+
+	// the side chain tips of databases created before they were recorded
+	// are found when they are opened
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Delete(forkTipKey(old.Hash)); err != nil {
+			return err
+		}
+		return txn.Delete(forkTipsIndexedKey)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.Close()
+	if bc, err = Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+	tips, err = bc.ChainTips()
+	if err != nil || len(tips) != 2 || !bytes.Equal(tips[1].Hash, old.Hash) {
+		t.Fatalf("got tips %+v, %v after indexing, want the old tip competing", tips, err)
+	}
+}
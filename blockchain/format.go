@@ -0,0 +1,195 @@
+package blockchain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// Verbosity is the level of detail used when formatting blocks and
+// transactions.
+type Verbosity int
+
+const (
+	// Summary formats blocks and transactions on a single line each.
+	Summary Verbosity = iota
+
+	// Standard formats the fields most useful for reading the chain.
+	Standard
+
+	// Full formats every field, including signatures and keys in hex.
+	Full
+)
+
+// ansi escape codes used for colored output
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+)
+
+// ParseVerbosity parses a verbosity level name: summary, standard or full.
+func ParseVerbosity(name string) (Verbosity, error) {
+	switch name {
+	case "summary":
+		return Summary, nil
+	case "standard":
+		return Standard, nil
+	case "full":
+		return Full, nil
+	}
+	return Standard, fmt.Errorf("unknown verbosity %s", name)
+}
+
+// formatter formats blocks and transactions, optionally with colors.
+type formatter struct {
+	verbosity Verbosity
+	color     bool
+}
+
+// paint wraps s in an ansi color code if colors are enabled.
+func (f formatter) paint(color, s string) string {
+	if !f.color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// hash formats a hash, shortening it unless the verbosity is Full.
+func (f formatter) hash(hash []byte) string {
+	s := fmt.Sprintf("%x", hash)
+	if f.verbosity < Full && len(s) > 16 {
+		s = s[:16] + "..."
+	}
+	return f.paint(colorYellow, s)
+}
+
+// value formats an amount in base units as coins.
+func (f formatter) value(value units.Amount) string {
+	return f.paint(colorGreen, units.FormatAmount(value))
+}
+
+// address formats an address.
+func (f formatter) address(address string) string {
+	return f.paint(colorCyan, address)
+}
+
+// output formats the value and address of an output, or the data of a
+// data output.
+func (f formatter) output(out TxOutput) string {
+	if out.IsData() {
+		return fmt.Sprintf("data %x", out.Data())
+	}
+	return fmt.Sprintf("%s to %s", f.value(out.Value), f.address(out.Address()))
+}
+
+// FormatTimestamp formats a timestamp in unix seconds, such as that of a
+// block, as an RFC 3339 time in the local time zone.
+func FormatTimestamp(timestamp int64) string {
+	return time.Unix(timestamp, 0).Format(time.RFC3339)
+}
+
+// Format returns a human readable representation of a Block and its
+// transactions. If color is set, ansi colors are used for terminals.
+func (b *Block) Format(verbosity Verbosity, color bool) string {
+	f := formatter{verbosity, color}
+
+	// summarize the block on one line
+	if verbosity == Summary {
+		return fmt.Sprintf("%s %d %s %s %d transactions",
+			f.paint(colorBold, "Block"), b.Height, f.hash(b.Hash),
+			FormatTimestamp(b.Timestamp), len(b.Transactions))
+	}
+
+	pow := f.paint(colorGreen, "valid")
+	if !NewProof(b).Validate() {
+		pow = f.paint(colorRed, "invalid")
+	}
+
+	result := []string{
+		f.paint(colorBold, fmt.Sprintf("=== Block %d", b.Height)),
+		fmt.Sprintf("Hash:          %s", f.hash(b.Hash)),
+		fmt.Sprintf("Previous Hash: %s", f.hash(b.PrevHash)),
+		fmt.Sprintf("Timestamp:     %s", FormatTimestamp(b.Timestamp)),
+		fmt.Sprintf("Difficulty:    %d", b.GetDifficulty()),
+		fmt.Sprintf("PoW:           %s", pow),
+	}
+	if verbosity == Full {
+		result = append(result,
+			fmt.Sprintf("Version:       %d", b.Version),
+			fmt.Sprintf("Merkle Root:   %s", f.hash(b.Header().MerkleRoot)),
+			fmt.Sprintf("Nonce:         %d", b.Nonce))
+	}
+	if b.Pruned() {
+		result = append(result, fmt.Sprintf("Transactions:  %s", f.paint(colorYellow, "pruned")))
+	}
+
+	// add each transaction
+	for _, tx := range b.Transactions {
+		result = append(result, f.transaction(tx))
+	}
+
+	// return the result as a joined string
+	return strings.Join(result, "\n")
+}
+
+// Format returns a human readable representation of a Transaction. If color
+// is set, ansi colors are used for terminals.
+func (tx *Transaction) Format(verbosity Verbosity, color bool) string {
+	return formatter{verbosity, color}.transaction(tx)
+}
+
+// transaction formats a Transaction.
+func (f formatter) transaction(tx *Transaction) string {
+
+	// summarize the transaction on one line
+	if f.verbosity == Summary {
+		var outputs []string
+		for _, out := range tx.Outputs {
+			outputs = append(outputs, f.output(out))
+		}
+		return fmt.Sprintf("Transaction %s: %s", f.hash(tx.ID), strings.Join(outputs, ", "))
+	}
+
+	result := []string{
+		fmt.Sprintf("--- Transaction %s:", f.hash(tx.ID)),
+	}
+
+	// iterate over inputs
+	for inID, in := range tx.Inputs {
+		if tx.IsCoinbase() {
+			result = append(result, fmt.Sprintf("\tInput %d:\tcoinbase", inID))
+		} else {
+			input := fmt.Sprintf("\tInput %d:\t%s:%d", inID, f.hash(in.ID), in.Out)
+			if address := in.Address(); address != "" {
+				input += " from " + f.address(address)
+			}
+			result = append(result, input)
+		}
+		if f.verbosity == Full && tx.IsCoinbase() {
+			result = append(result, fmt.Sprintf("\t\tData:\t%x", in.CoinbaseData))
+		} else if f.verbosity == Full {
+			result = append(result,
+				fmt.Sprintf("\t\tSignature:\t%x", in.Signature),
+				fmt.Sprintf("\t\tPubKey:\t%x", in.PubKey),
+			)
+		}
+	}
+
+	// iterate over outputs
+	for outID, out := range tx.Outputs {
+		result = append(result,
+			fmt.Sprintf("\tOutput %d:\t%s", outID, f.output(out)))
+		if f.verbosity == Full {
+			result = append(result, fmt.Sprintf("\t\tScript:\t%x", out.PubKeyHash))
+		}
+	}
+
+	// return the result as a joined string
+	return strings.Join(result, "\n")
+}
@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger"
+)
+
+// frozenKey holds the reason the chain was frozen while it is frozen.
+var frozenKey = []byte("frozen")
+
+// ErrChainFrozen is returned when a block is mined or accepted while the
+// chain is frozen for maintenance.
+var ErrChainFrozen = errors.New("chain is frozen for maintenance")
+
+// Freeze stops blocks from being mined or accepted until Unfreeze is called,
+// so the chain stays consistent while it is backed up or checked. The
+// mempool still accepts transactions, which are mined once the chain is
+// unfrozen. The freeze is stored in the database so it applies to every
+// process using it.
+func (bc *BlockChain) Freeze(reason string) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(frozenKey, []byte(reason))
+	})
+	if err != nil {
+		return errors.New("unable to freeze chain - " + err.Error())
+	}
+	return nil
+}
+
+// Unfreeze lets blocks be mined and accepted again after Freeze.
+func (bc *BlockChain) Unfreeze() error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(frozenKey)
+	})
+	if err != nil {
+		return errors.New("unable to unfreeze chain - " + err.Error())
+	}
+	return nil
+}
+
+// Frozen returns whether the chain is frozen and the reason it was frozen.
+func (bc *BlockChain) Frozen() (string, bool) {
+	var reason []byte
+	frozen := false
+
+	// initiate read only transaction on db to get the reason
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(frozenKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		frozen = true
+		reason, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		bc.panicf("Unable to read freeze from database: %s", err.Error())
+	}
+
+	return string(reason), frozen
+}
+
+// checkFrozen returns ErrChainFrozen if the chain is frozen.
+func checkFrozen(txn *badger.Txn) error {
+	_, err := txn.Get(frozenKey)
+	if err == badger.ErrKeyNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return ErrChainFrozen
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFrozenChainRefusesBlocks(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+	mined := minePending(t, bc, alice)
+
+	// blocks mined before the freeze are refused when they arrive
+	next := mineOn(t, bc, mined, alice)
+	side1 := mineOn(t, bc, genesis, carol)
+	side2 := mineOn(t, bc, side1, carol)
+	if err := bc.AcceptBlock(side1); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.Freeze("backup"); err != nil {
+		t.Fatal(err)
+	}
+	if reason, frozen := bc.Frozen(); !frozen || reason != "backup" {
+		t.Fatalf("got frozen %v with reason %q", frozen, reason)
+	}
+
+	for _, c := range []struct {
+		name string
+		err  func() error
+	}{
+		{"mine", func() error {
+			_, err := bc.MinePending(alice.Address().String())
+			return err
+		}},
+		{"add block", func() error {
+			_, err := bc.AddBlock([]*Transaction{CoinbaseTx(alice.Address().String(), "", 0)})
+			return err
+		}},
+		{"extend tip", func() error { return bc.AcceptBlock(next) }},
+		{"side block", func() error { return bc.AcceptBlock(mineOn(t, bc, genesis, bob)) }},
+		{"reorganize", func() error { return bc.AcceptBlock(side2) }},
+	} {
+		if err := c.err(); err != ErrChainFrozen {
+			t.Errorf("%s: got error %v, want ErrChainFrozen", c.name, err)
+		}
+	}
+	if !bytes.Equal(bc.Tip(), mined.Hash) {
+		t.Fatal("tip changed while frozen")
+	}
+
+	// transactions are still accepted, and everything resumes after
+	// unfreezing
+	if err := bc.AddToMempool(send(t, bc, alice, bob, 10, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(bc.MempoolTransactions()); got != 1 {
+		t.Fatalf("got %d pending transactions, want 1", got)
+	}
+	if err := bc.Unfreeze(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AcceptBlock(side2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bc.Tip(), side2.Hash) {
+		t.Fatal("chain did not reorganize after unfreezing")
+	}
+	if block := minePending(t, bc, carol); !bytes.Equal(bc.Tip(), block.Hash) {
+		t.Fatal("mined block is not the tip after unfreezing")
+	}
+}
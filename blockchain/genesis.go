@@ -0,0 +1,259 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/edwintcloud/gochain/addresses"
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// Genesis is the configuration of the genesis block of a network. Networks
+// with different configurations have different genesis hashes, so their
+// chains are incompatible. Allocations are amounts keyed by address, given
+// in base units or as coin strings. The difficulty limits are not part of the genesis block, so
+// every node of a network must use the same ones.
+type Genesis struct {
+	Network     string                  `json:"network"`
+	Message     string                  `json:"message"`
+	Allocations map[string]units.Amount `json:"allocations"`
+	Difficulty  int                     `json:"difficulty"`
+	Timestamp   int64                   `json:"timestamp"`
+
+	MinDifficulty      int   `json:"minDifficulty"`
+	MaxDifficulty      int   `json:"maxDifficulty"`
+	TargetSpacing      int64 `json:"targetSpacing"`
+	AllowMinDifficulty bool  `json:"allowMinDifficulty"`
+
+	// SignatureScheme is the name of the signature scheme transactions are
+	// signed with, p256 if it is empty or secp256k1.
+	SignatureScheme string `json:"signatureScheme"`
+
+	// AddressPrefix is the base58 character the addresses of the network
+	// start with, such as "g", instead of those of the version byte of its
+	// network parameters.
+	AddressPrefix string `json:"addressPrefix"`
+
+	// PowHash is the name of the proof of work hash of blocks, sha256 if it
+	// is empty, or sha256d, sha3, scrypt or argon2id.
+	PowHash string `json:"powHash"`
+
+	// Authorities are the public keys in hex of the authorities sealing the
+	// blocks of a proof of authority network in turn, in order. Blocks are
+	// mined if there are none. The genesis block commits to them.
+	Authorities []string `json:"authorities"`
+}
+
+// LoadGenesis loads the genesis configuration from the file at path. It
+// returns nil if path is empty.
+func LoadGenesis(path string) (*Genesis, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	// read and decode the genesis file
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("unable to read genesis file - " + err.Error())
+	}
+	var g Genesis
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, errors.New("unable to decode genesis file - " + err.Error())
+	}
+
+	// use the default difficulty if none is given, or the lowest for proof
+	// of authority networks
+	if g.Difficulty == 0 {
+		g.Difficulty = Difficulty
+		if len(g.Authorities) > 0 {
+			g.Difficulty = 1
+		}
+	}
+
+	// validate the configuration
+	if g.Network == "" {
+		return nil, errors.New("genesis network name is required")
+	}
+	rules := g.DifficultyRules()
+	if rules.Min < 1 || rules.Max > 255 || rules.Min > rules.Max {
+		return nil, fmt.Errorf("genesis difficulty limits %d to %d are out of range", rules.Min, rules.Max)
+	}
+	if g.Difficulty < rules.Min || g.Difficulty > rules.Max {
+		return nil, fmt.Errorf("genesis difficulty %d is out of range", g.Difficulty)
+	}
+	if g.TargetSpacing < 0 || g.AllowMinDifficulty && g.TargetSpacing == 0 {
+		return nil, errors.New("genesis target spacing must be positive to allow minimum difficulty blocks")
+	}
+	if g.Timestamp <= 0 {
+		return nil, errors.New("genesis timestamp is required")
+	}
+	if _, err := keys.SchemeByName(g.SignatureScheme); err != nil {
+		return nil, errors.New("genesis " + err.Error())
+	}
+	if _, err := PowHashByName(g.PowHash); err != nil {
+		return nil, errors.New("genesis " + err.Error())
+	}
+	if _, err := g.authorities(); err != nil {
+		return nil, errors.New("genesis " + err.Error())
+	}
+	if len(g.Authorities) > 0 && (g.MinDifficulty != 0 || g.MaxDifficulty != 0 || g.AllowMinDifficulty) {
+		return nil, errors.New("genesis difficulty limits do not apply to proof of authority networks")
+	}
+	version, err := g.addressVersion()
+	if err != nil {
+		return nil, errors.New("genesis " + err.Error())
+	}
+	for address, value := range g.Allocations {
+		decoded, err := addresses.Decode(address)
+		if err != nil || g.AddressPrefix != "" && decoded.Version != version {
+			return nil, fmt.Errorf("genesis allocation address %s is not valid", address)
+		}
+		if value <= 0 {
+			return nil, fmt.Errorf("genesis allocation to %s must be positive", address)
+		}
+	}
+
+	// return reference to genesis configuration
+	return &g, nil
+}
+
+// addressVersion returns the version byte of the address prefix, or 0 if
+// there is none.
+func (g *Genesis) addressVersion() (byte, error) {
+	if g.AddressPrefix == "" {
+		return 0, nil
+	}
+	return addresses.VersionForPrefix(g.AddressPrefix)
+}
+
+// authorities returns the authorities of the network, or nil if its blocks
+// are mined.
+func (g *Genesis) authorities() (*Authorities, error) {
+	if len(g.Authorities) == 0 {
+		return nil, nil
+	}
+	scheme, err := keys.SchemeByName(g.SignatureScheme)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAuthorities(g.Authorities, scheme)
+}
+
+// DifficultyRules returns the difficulty rules of the network, using the
+// default limits for those that are not given. Blocks of proof of
+// authority networks are all of the lowest difficulty.
+func (g *Genesis) DifficultyRules() DifficultyRules {
+	if len(g.Authorities) > 0 {
+		return DifficultyRules{Min: 1, Max: 1}
+	}
+	rules := DifficultyRules{
+		Min:                g.MinDifficulty,
+		Max:                g.MaxDifficulty,
+		TargetSpacing:      g.TargetSpacing,
+		AllowMinDifficulty: g.AllowMinDifficulty,
+	}
+	if rules.Min == 0 {
+		rules.Min = DefaultDifficultyRules.Min
+	}
+	if rules.Max == 0 {
+		rules.Max = DefaultDifficultyRules.Max
+	}
+	return rules
+}
+
+// Transaction returns the coinbase transaction of the genesis block, which
+// pays each allocation in order of address.
+func (g *Genesis) Transaction() *Transaction {
+	var addresses []string
+	var txOutputs []TxOutput
+
+	// sort addresses so the transaction is the same on every node
+	for address := range g.Allocations {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		txOutputs = append(txOutputs, *NewTXOutput(g.Allocations[address], address))
+	}
+
+	// create transaction with the network name and message as its data,
+	// followed by the authorities of proof of authority networks, left at
+	// version 0 so the genesis blocks of existing networks keep their hash
+	data := g.Network + ": " + g.Message
+	if len(g.Authorities) > 0 {
+		data += "\nauthorities: " + strings.Join(g.Authorities, ",")
+	}
+	tx := Transaction{
+		ID: nil,
+		Inputs: []TxInput{{
+			ID:           []byte{},
+			Out:          -1,
+			CoinbaseData: []byte(data),
+		}},
+		Outputs: txOutputs,
+	}
+
+	// generate hash id for transaction
+	tx.SetID()
+
+	// return a reference to transaction
+	return &tx
+}
+
+// Block mines the genesis block described by the configuration. The result
+// is the same on every node. Genesis blocks of networks mined with SHA256
+// are left at version 0 so existing networks keep their hash, and those of
+// other hashes commit to theirs in their version.
+func (g *Genesis) Block() *Block {
+	txs := []*Transaction{g.Transaction()}
+	block := Block{
+		BlockHeader: BlockHeader{
+			Version:    g.version(),
+			Hash:       []byte{},
+			PrevHash:   []byte{},
+			MerkleRoot: merkleRoot(txs),
+			Nonce:      0,
+			Height:     0,
+			Timestamp:  g.Timestamp,
+			Difficulty: g.Difficulty,
+		},
+		Transactions: txs,
+	}
+
+	// mine block and return a reference to it
+	block.mine(context.Background(), miningOptions{})
+	return &block
+}
+
+// version returns the version of the genesis block, 0 for SHA256 and
+// otherwise that of the blocks mined with the proof of work hash.
+func (g *Genesis) version() int {
+	hash, err := PowHashByName(g.PowHash)
+	if err != nil || hash.ID() == SHA256.ID() {
+		return 0
+	}
+	return blockVersion(hash)
+}
+
+// Matches returns whether a block is the genesis block described by the
+// configuration, without mining it.
+func (g *Genesis) Matches(block *Block) bool {
+	expected := Block{
+		BlockHeader:  BlockHeader{Timestamp: g.Timestamp, Difficulty: g.Difficulty},
+		Transactions: []*Transaction{g.Transaction()},
+	}
+
+	return len(block.PrevHash) == 0 &&
+		block.Version == g.version() &&
+		block.Timestamp == expected.Timestamp &&
+		block.GetDifficulty() == expected.GetDifficulty() &&
+		bytes.Equal(block.HashTransactions(), expected.HashTransactions()) &&
+		NewProof(block).Validate()
+}
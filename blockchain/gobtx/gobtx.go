@@ -0,0 +1,50 @@
+// Package blockchain, imported as gobtx, holds the gob encoding of version
+// 0 transactions, whose ids and signature hashes are computed over it. gob
+// writes the names of the types it encodes, with the name of their package
+// for slices, and descriptions of their fields into the encoding, so these
+// types mirror the transaction types of package blockchain as they were
+// when version 0 was defined, under the same package name, and must never
+// change.
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"log"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// Transaction is a version 0 transaction.
+type Transaction struct {
+	ID      []byte
+	Inputs  []TxInput
+	Outputs []TxOutput
+}
+
+// TxInput is an input of a version 0 transaction.
+type TxInput struct {
+	ID           []byte
+	Out          int
+	Signature    []byte
+	PubKey       []byte
+	CoinbaseData []byte
+}
+
+// TxOutput is an output of a version 0 transaction.
+type TxOutput struct {
+	Value      units.Amount
+	PubKeyHash []byte
+}
+
+// Encode returns the gob encoding of a version 0 transaction. gob assigns
+// type ids in the order types are first used in a process and includes them
+// in the encoded bytes, so Encode must be called before anything else is
+// encoded, as package blockchain does when it is initialized.
+func Encode(tx Transaction) []byte {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(tx); err != nil {
+		log.Panicf("Unable to encode Transaction structure into byte slice: %s", err.Error())
+	}
+	return buffer.Bytes()
+}
@@ -0,0 +1,60 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// HashLength is the length in bytes of block hashes and transaction ids.
+const HashLength = sha256.Size
+
+// Hash is a block hash or transaction id. Lookups taking ids from outside
+// the chain take a Hash, so a public key hash or other bytes can't be
+// passed by mistake and ids of the wrong length are rejected when parsed.
+type Hash [HashLength]byte
+
+// HashFromBytes returns the Hash held in b, which must be HashLength bytes.
+func HashFromBytes(b []byte) (Hash, error) {
+	var hash Hash
+	if len(b) != HashLength {
+		return hash, fmt.Errorf("hash is %d bytes, not %d", len(b), HashLength)
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
+
+// ParseHash parses a hex encoded Hash.
+func ParseHash(s string) (Hash, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return Hash{}, fmt.Errorf("hash %q is not hex", s)
+	}
+	return HashFromBytes(b)
+}
+
+// Bytes returns the hash as a byte slice, as it is stored in blocks and
+// transactions.
+func (h Hash) Bytes() []byte {
+	return append([]byte{}, h[:]...)
+}
+
+// String returns the hash hex encoded.
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// MarshalText encodes the hash as hex.
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText decodes a hex encoded hash.
+func (h *Hash) UnmarshalText(text []byte) error {
+	hash, err := ParseHash(string(text))
+	if err != nil {
+		return err
+	}
+	*h = hash
+	return nil
+}
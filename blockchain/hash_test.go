@@ -0,0 +1,40 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseHash(t *testing.T) {
+	s := strings.Repeat("0f", HashLength)
+	hash, err := ParseHash(s)
+	if err != nil || hash.String() != s || hash[0] != 0x0f {
+		t.Fatalf("got %s, %v, want %s", hash, err, s)
+	}
+
+	for _, bad := range []string{"", "zz", "0f0f", s + "00"} {
+		if _, err := ParseHash(bad); err == nil {
+			t.Errorf("parsed hash %q, want error", bad)
+		}
+	}
+	if _, err := HashFromBytes([]byte("not a hash")); err == nil {
+		t.Error("got hash from 10 bytes, want error")
+	}
+}
+
+func TestHashJSON(t *testing.T) {
+	hash := Hash{1, 2, 3}
+	data, err := json.Marshal(map[string]Hash{"hash": hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]Hash
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded["hash"] != hash {
+		t.Fatalf("got %v, %v, want %s", decoded, err, hash)
+	}
+	if err := json.Unmarshal([]byte(`{"hash":"0102"}`), &decoded); err == nil {
+		t.Fatal("decoded a short hash, want error")
+	}
+}
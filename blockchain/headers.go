@@ -0,0 +1,67 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// MaxHeaders is the most headers returned by Headers at once.
+const MaxHeaders = 2000
+
+// Headers returns the headers of the blocks of the best chain from height
+// from, at most count and MaxHeaders of them, so another node can sync and
+// verify headers with CheckHeaders before fetching their blocks.
+func (bc *BlockChain) Headers(from, count int) ([]*BlockHeader, error) {
+	if from < 0 || count < 1 {
+		return nil, fmt.Errorf("invalid header range from %d, count %d", from, count)
+	}
+	if count > MaxHeaders {
+		count = MaxHeaders
+	}
+
+	// collect the header of each block up to the tip
+	headers := []*BlockHeader{}
+	for height := from; height < from+count && height <= bc.Height(); height++ {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, block.Header())
+	}
+
+	return headers, nil
+}
+
+// CheckHeaders verifies that each of headers, such as those returned by
+// Headers on another node, has a valid proof of work of a known version and
+// follows the one before it at the next height, without their blocks.
+func CheckHeaders(headers []*BlockHeader) error {
+	return checkHeaders(headers, nil)
+}
+
+// checkHeaders verifies headers like CheckHeaders, requiring them to be
+// sealed by authorities if set.
+func checkHeaders(headers []*BlockHeader, authorities *Authorities) error {
+	for i, h := range headers {
+		if !h.knownVersion() {
+			return fmt.Errorf("header %x has unsupported version %d", h.Hash, h.Version)
+		}
+		if h.MerkleRoot == nil {
+			return fmt.Errorf("header %x has no Merkle root", h.Hash)
+		}
+
+		// a block without transactions proves its work with the Merkle root
+		if err := checkProof(&Block{BlockHeader: *h}, authorities); err != nil {
+			return err
+		}
+		if i == 0 {
+			continue
+		}
+		prev := headers[i-1]
+		if !bytes.Equal(h.PrevHash, prev.Hash) || h.Height != prev.Height+1 {
+			return fmt.Errorf("header %x does not follow header %x", h.Hash, prev.Hash)
+		}
+	}
+
+	return nil
+}
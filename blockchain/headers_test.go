@@ -0,0 +1,65 @@
+package blockchain
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeaders(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+	minePending(t, bc, alice)
+	mined := minePending(t, bc, alice)
+	if mined.Version != BlockVersion || genesis.Version != 0 {
+		t.Fatalf("got versions %d and %d, want %d for mined blocks and 0 for the genesis block", mined.Version, genesis.Version, BlockVersion)
+	}
+
+	headers, err := bc.Headers(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 3 || !reflect.DeepEqual(headers[2], &mined.BlockHeader) {
+		t.Fatalf("got %d headers ending with %+v, want 3 ending with %+v", len(headers), headers[len(headers)-1], mined.BlockHeader)
+	}
+	if err := CheckHeaders(headers); err != nil {
+		t.Fatal(err)
+	}
+
+	// headers are serialized without their blocks
+	for _, header := range headers {
+		decoded, err := DecodeHeader(header.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, header) {
+			t.Fatalf("got %+v, want %+v", decoded, header)
+		}
+	}
+	if _, err := DecodeHeader([]byte{0}); err == nil {
+		t.Fatal("decoded a header of an unknown format")
+	}
+
+	// the proof of work of version 1 headers commits to their height
+	moved := *headers[2]
+	moved.Height++
+	if err := CheckHeaders([]*BlockHeader{&moved}); err == nil || !strings.Contains(err.Error(), "does not match its contents") {
+		t.Fatalf("got error %v, want the moved header refused", err)
+	}
+	if err := CheckHeaders([]*BlockHeader{headers[0], headers[2]}); err == nil || !strings.Contains(err.Error(), "does not follow") {
+		t.Fatalf("got error %v, want the gap refused", err)
+	}
+	future := *headers[2]
+	future.Version = BlockVersion + 1
+	if err := CheckHeaders([]*BlockHeader{&future}); err == nil || !strings.Contains(err.Error(), "unsupported version") {
+		t.Fatalf("got error %v, want the version refused", err)
+	}
+
+	// at most count headers are returned, none past the tip
+	if headers, err := bc.Headers(1, 1); err != nil || len(headers) != 1 || headers[0].Height != 1 {
+		t.Fatalf("got %d headers and error %v, want the header at height 1", len(headers), err)
+	}
+	if headers, err := bc.Headers(5, 1); err != nil || len(headers) != 0 {
+		t.Fatalf("got %d headers and error %v past the tip", len(headers), err)
+	}
+}
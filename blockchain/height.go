@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+// heightPrefix is the key prefix for the hashes of blocks in the best chain
+// indexed by height.
+var heightPrefix = []byte("height-")
+
+// heightKey returns the db key for the block at a height.
+func heightKey(height int) []byte {
+	return append(append([]byte{}, heightPrefix...), ToBytes(int64(height))...)
+}
+
+// Height returns the height of the tip of the chain. The genesis block has
+// a height of 0.
+func (bc *BlockChain) Height() int {
+	block, err := bc.GetBlock(bc.Tip())
+	if err != nil {
+		bc.panicf("Unable to get tip of the chain: %s", err.Error())
+	}
+
+	// return height of the tip
+	return block.Height
+}
+
+// GetBlockByHeight returns the block at a height in the best chain.
+func (bc *BlockChain) GetBlockByHeight(height int) (*Block, error) {
+	var block *Block
+
+	// initiate read only transaction on db to look up the block
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(heightKey(height))
+		if err != nil {
+			return err
+		}
+		hash, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		block, err = getBlock(txn, hash)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("no block at height %d", height)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// heightIndexed returns whether the tip of the chain is in the height index.
+func (bc *BlockChain) heightIndexed() bool {
+	tip, err := bc.GetBlock(bc.Tip())
+	if err != nil {
+		return false
+	}
+	block, err := bc.GetBlockByHeight(tip.Height)
+	return err == nil && bytes.Equal(block.Hash, bc.Tip())
+}
+
+// inBestChain returns whether a block is part of the best chain.
+func (bc *BlockChain) inBestChain(block *Block) bool {
+	best, err := bc.GetBlockByHeight(block.Height)
+	return err == nil && bytes.Equal(best.Hash, block.Hash)
+}
+
+// TipChanges returns the blocks that left and joined the best chain since
+// the block with hash last was its tip. Disconnected blocks are ordered from
+// the old tip down, and connected blocks from the fork point up, so they can
+// be applied in order to follow the chain.
+func (bc *BlockChain) TipChanges(last []byte) (disconnected, connected []*Block, err error) {
+
+	// walk back from the old tip until reaching the best chain
+	block, err := bc.GetBlock(last)
+	if err != nil {
+		return nil, nil, err
+	}
+	for !bc.inBestChain(block) {
+		disconnected = append(disconnected, block)
+		if block, err = bc.GetBlock(block.PrevHash); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// collect the blocks above the fork point
+	for height := block.Height + 1; height <= bc.Height(); height++ {
+		next, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			return nil, nil, err
+		}
+		connected = append(connected, next)
+	}
+
+	return disconnected, connected, nil
+}
@@ -0,0 +1,155 @@
+package blockchain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/logging"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+var (
+	// alice receives the genesis allocation of test chains
+	alice = wallet.NewFromSeed([]byte("alice"))
+
+	// bob and carol start without coins
+	bob   = wallet.NewFromSeed([]byte("bob"))
+	carol = wallet.NewFromSeed([]byte("carol"))
+)
+
+// genesisAllocation is the amount alice holds in a new test chain.
+const genesisAllocation = 1000 * units.Coin
+
+// testGenesis is a network with the lowest difficulty so tests mine quickly.
+func testGenesis() *Genesis {
+	return &Genesis{
+		Network:     "test",
+		Message:     "test",
+		Allocations: map[string]units.Amount{alice.Address().String(): genesisAllocation},
+		Difficulty:  1,
+		Timestamp:   1,
+	}
+}
+
+// newTestChain opens a new test chain in a temporary directory.
+func newTestChain(t testing.TB) *BlockChain {
+	return newTestChainWithConfig(t, Config{})
+}
+
+// newTestChainWithConfig opens a new test chain in a temporary directory
+// with cfg, filling in the path and genesis, and discarding its status
+// messages unless it has a logger.
+func newTestChainWithConfig(t testing.TB, cfg Config) *BlockChain {
+	t.Helper()
+	cfg.Path = t.TempDir()
+	cfg.Genesis = testGenesis()
+	if cfg.Logger == nil {
+		cfg.Logger = logging.Discard
+	}
+	bc, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("unable to open chain: %s", err)
+	}
+	t.Cleanup(func() { bc.Close() })
+	return bc
+}
+
+// newTestChainWithEvents opens a test chain publishing on a new bus, and
+// returns a subscription to every event type.
+func newTestChainWithEvents(t *testing.T) (*BlockChain, *events.Subscription) {
+	bus := events.NewBus()
+	sub := bus.Subscribe(100)
+	for _, topic := range events.Topics {
+		sub.Add(topic)
+	}
+	return newTestChainWithConfig(t, Config{Events: bus}), sub
+}
+
+// mineOn mines a block holding txs after a coinbase paying miner on parent
+// without adding it to the chain.
+func mineOn(t *testing.T, bc *BlockChain, parent *Block, miner *wallet.Wallet, txs ...*Transaction) *Block {
+	t.Helper()
+	coinbase := CoinbaseTx(miner.Address().String(), "", 0)
+	return mineBlock(t, bc, parent, append([]*Transaction{coinbase}, txs...))
+}
+
+// mineBlock mines a block holding exactly txs on parent without adding it
+// to the chain.
+func mineBlock(t *testing.T, bc *BlockChain, parent *Block, txs []*Transaction) *Block {
+	t.Helper()
+	timestamp := time.Now().Unix()
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:    BlockVersion,
+			Hash:       []byte{},
+			PrevHash:   parent.Hash,
+			MerkleRoot: merkleRoot(txs),
+			Height:     parent.Height + 1,
+			Timestamp:  timestamp,
+			Difficulty: bc.NextDifficulty(parent, timestamp),
+		},
+		Transactions: txs,
+	}
+	if err := block.mine(context.Background(), miningOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// minePending mines the mempool into a block paying miner.
+func minePending(t testing.TB, bc *BlockChain, miner *wallet.Wallet) *Block {
+	t.Helper()
+	block, err := bc.MinePending(miner.Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// tip returns the tip of the best chain.
+func tip(t testing.TB, bc *BlockChain) *Block {
+	t.Helper()
+	block, err := bc.GetBlock(bc.Tip())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// send creates and signs a transaction paying amount from w to to, with
+// change going back to w.
+func send(t testing.TB, bc *BlockChain, w *wallet.Wallet, to *wallet.Wallet, amount, fee units.Amount) *Transaction {
+	t.Helper()
+	tx, err := bc.NewTransaction(w, to.Address().String(), amount, fee, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// balance returns the spendable balance of w, including pending change.
+func balance(t *testing.T, bc *BlockChain, w *wallet.Wallet) units.Amount {
+	t.Helper()
+	outs, err := bc.FindUnspentTxOutputs(wallet.GeneratePublicKeyHash(w.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total units.Amount
+	for _, out := range outs {
+		total += out.Value
+	}
+	return total
+}
+
+// hashOf returns the Hash of a block hash or transaction id.
+func hashOf(t *testing.T, id []byte) Hash {
+	t.Helper()
+	hash, err := HashFromBytes(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
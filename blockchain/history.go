@@ -0,0 +1,59 @@
+package blockchain
+
+import "github.com/edwintcloud/gochain/units"
+
+// HistoryEntry is a confirmed transaction that pays to or spends from an
+// address.
+type HistoryEntry struct {
+	Tx            *Transaction
+	BlockHash     []byte
+	Height        int
+	Confirmations int
+
+	// Timestamp is the timestamp of the block, when the transaction was
+	// mined
+	Timestamp int64
+
+	// Received is the value of the outputs paying to the address and Sent
+	// is the value of the outputs of the address spent by the transaction.
+	Received units.Amount
+	Sent     units.Amount
+
+	// Spends are the spends of the outputs paying to the address keyed by
+	// output index, if the chain keeps the spent index. Unspent outputs
+	// have no spend.
+	Spends map[int]*Spend
+}
+
+// Amount returns the change in the balance of the address caused by the
+// transaction.
+func (e HistoryEntry) Amount() units.Amount {
+	return e.Received - e.Sent
+}
+
+// Direction returns "received" or "sent" depending on whether the balance
+// of the address went up or down, or "self" for a transaction moving funds
+// back to the address it spends from.
+func (e HistoryEntry) Direction() string {
+	switch {
+	case e.Sent == 0:
+		return "received"
+	case e.Amount() < 0:
+		return "sent"
+	default:
+		return "self"
+	}
+}
+
+// History finds every confirmed transaction that pays to or spends from
+// pubKeyHash, oldest first, using the address index. Transactions in pruned
+// blocks are left out.
+func (bc *BlockChain) History(pubKeyHash []byte) ([]HistoryEntry, error) {
+	s, err := bc.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Discard()
+
+	return s.History(pubKeyHash)
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestConfirmations(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		pruneDepth int
+	}{
+		{"full chain", 0},
+		{"pruned chain", 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bc := newTestChainWithConfig(t, Config{PruneDepth: c.pruneDepth})
+
+			confirmed := send(t, bc, alice, bob, 10, 0)
+			if err := bc.AddToMempool(confirmed); err != nil {
+				t.Fatal(err)
+			}
+			minePending(t, bc, alice)
+			pending := send(t, bc, alice, bob, 10, 0)
+			if err := bc.AddToMempool(pending); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range []int{1, 2, 3} {
+				if got := bc.Confirmations(hashOf(t, confirmed.ID)); got != want {
+					t.Fatalf("got %d confirmations, want %d", got, want)
+				}
+				if got := bc.Confirmations(hashOf(t, pending.ID)); got != 0 {
+					t.Fatalf("pending transaction has %d confirmations", got)
+				}
+				mined := mineOn(t, bc, tip(t, bc), carol)
+				if err := bc.AcceptBlock(mined); err != nil {
+					t.Fatal(err)
+				}
+				if c.pruneDepth > 0 {
+					if _, err := bc.Prune(c.pruneDepth); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if got := bc.Confirmations(Hash{}); got != 0 {
+				t.Fatalf("unknown transaction has %d confirmations", got)
+			}
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	bc := newTestChain(t)
+	paid := send(t, bc, alice, bob, 30, 1)
+	if err := bc.AddToMempool(paid); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, carol)
+	spent := send(t, bc, bob, carol, 20, 0)
+	if err := bc.AddToMempool(spent); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, carol)
+
+	history, err := bc.History(wallet.GeneratePublicKeyHash(bob.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range []struct {
+		tx            *Transaction
+		direction     string
+		amount        units.Amount
+		confirmations int
+	}{
+		{paid, "received", 30, 2},
+		{spent, "sent", -20, 1},
+	} {
+		if i >= len(history) {
+			t.Fatalf("got %d history entries, want 2", len(history))
+		}
+		e := history[i]
+		if !equalID(e.Tx, c.tx) || e.Direction() != c.direction || e.Amount() != c.amount || e.Confirmations != c.confirmations {
+			t.Errorf("entry %d: got %s %d with %d confirmations, want %s %d with %d",
+				i, e.Direction(), e.Amount(), e.Confirmations, c.direction, c.amount, c.confirmations)
+		}
+	}
+}
+
+func TestTransactionTime(t *testing.T) {
+	now := time.Unix(1000, 0)
+	bc := newTestChainWithConfig(t, Config{Clock: func() time.Time { return now }})
+
+	// pending transactions happened when they entered the mempool
+	paid := send(t, bc, alice, bob, 30, 1)
+	if err := bc.AddToMempool(paid); err != nil {
+		t.Fatal(err)
+	}
+	txID := hashOf(t, paid.ID)
+	if got, known, err := bc.TransactionTime(txID); err != nil || !known || got != 1000 {
+		t.Fatalf("pending transaction time is %d, %v, %v, want 1000", got, known, err)
+	}
+
+	// confirmed transactions happened when their block was mined
+	now = time.Unix(2000, 0)
+	block := minePending(t, bc, carol)
+	if got, known, err := bc.TransactionTime(txID); err != nil || !known || got != block.Timestamp || got != 2000 {
+		t.Fatalf("confirmed transaction time is %d, %v, %v, want 2000", got, known, err)
+	}
+	history, err := bc.History(wallet.GeneratePublicKeyHash(bob.PublicKey))
+	if err != nil || len(history) != 1 || history[0].Timestamp != 2000 {
+		t.Fatalf("got history %+v, %v, want one entry at 2000", history, err)
+	}
+
+	if _, known, err := bc.TransactionTime(Hash{}); err != nil || known {
+		t.Fatalf("unknown transaction time is known %v, %v", known, err)
+	}
+}
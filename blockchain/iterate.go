@@ -0,0 +1,74 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+// ErrStopIteration is returned by the function passed to IterateForward or
+// IterateRange to stop iterating without an error.
+var ErrStopIteration = errors.New("stop iteration")
+
+// IterateForward calls fn with each block of the best chain from the
+// genesis block to the tip, like IterateRange.
+func (bc *BlockChain) IterateForward(fn func(*Block) error) error {
+	return bc.IterateRange(0, -1, fn)
+}
+
+// IterateRange calls fn with each block of the best chain from height from
+// to height to, inclusive, in height order, or up to the tip if to is
+// negative. The blocks are read one at a time through the height index from
+// the chain as it was when iteration started, so blocks connected or
+// disconnected meanwhile are not seen. Pruned blocks are passed without
+// their transactions. Iteration stops at the first error returned by fn,
+// which is returned unless it is ErrStopIteration.
+func (bc *BlockChain) IterateRange(from, to int, fn func(*Block) error) error {
+	err := bc.DB.View(func(txn *badger.Txn) error {
+
+		// find the height of the tip in this snapshot of the chain
+		item, err := txn.Get([]byte("lh"))
+		if err != nil {
+			return errors.New("unable to get last hash - " + err.Error())
+		}
+		tipHash, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		tip, err := getBlock(txn, tipHash)
+		if err != nil {
+			return errors.New("unable to get tip - " + err.Error())
+		}
+		if to < 0 {
+			to = tip.Height
+		}
+		if from < 0 || from > to || to > tip.Height {
+			return fmt.Errorf("heights %d to %d are not in a chain of height %d", from, to, tip.Height)
+		}
+
+		// read and pass on each block by height
+		for height := from; height <= to; height++ {
+			item, err := txn.Get(heightKey(height))
+			if err != nil {
+				return fmt.Errorf("unable to find block at height %d - %s", height, err.Error())
+			}
+			hash, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			block, err := getBlock(txn, hash)
+			if err != nil {
+				return fmt.Errorf("unable to get block at height %d - %s", height, err.Error())
+			}
+			if err := fn(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == ErrStopIteration {
+		return nil
+	}
+	return err
+}
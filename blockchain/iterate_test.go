@@ -0,0 +1,60 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIterateForward(t *testing.T) {
+	bc := newTestChain(t)
+	for i := 0; i < 3; i++ {
+		minePending(t, bc, bob)
+	}
+	heights := func(from, to int) ([]int, error) {
+		var got []int
+		err := bc.IterateRange(from, to, func(block *Block) error {
+			got = append(got, block.Height)
+			return nil
+		})
+		return got, err
+	}
+
+	// blocks come in height order, ending at the tip
+	var got []int
+	var last []byte
+	err := bc.IterateForward(func(block *Block) error {
+		got = append(got, block.Height)
+		last = block.Hash
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(got, []int{0, 1, 2, 3}) || !bytes.Equal(last, bc.Tip()) {
+		t.Fatalf("got heights %v and error %v, want 0 to the tip at 3", got, err)
+	}
+	if got, err := heights(1, 2); err != nil || !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("got heights %v and error %v, want 1 and 2", got, err)
+	}
+	for _, r := range [][2]int{{-1, 2}, {2, 1}, {0, 4}} {
+		if _, err := heights(r[0], r[1]); err == nil {
+			t.Errorf("iterated heights %d to %d", r[0], r[1])
+		}
+	}
+
+	// ErrStopIteration stops without an error, other errors are returned
+	got = nil
+	err = bc.IterateForward(func(block *Block) error {
+		got = append(got, block.Height)
+		if block.Height == 1 {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("got heights %v and error %v, want to stop at 1", got, err)
+	}
+	failed := errors.New("failed")
+	if err := bc.IterateForward(func(*Block) error { return failed }); err != failed {
+		t.Fatalf("got error %v, want the error of fn", err)
+	}
+}
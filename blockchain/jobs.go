@@ -0,0 +1,47 @@
+package blockchain
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger"
+)
+
+// ErrJobCancelled is returned by long-running jobs over the blocks of the
+// chain when their context is done before they finish. The work done so far
+// is kept, so the job can be resumed.
+var ErrJobCancelled = errors.New("job cancelled")
+
+// JobProgress is how far a long-running job over the blocks of the chain
+// has come.
+type JobProgress struct {
+
+	// Done is the number of blocks processed out of Total.
+	Done  int
+	Total int
+
+	// Last is the hash of the last block processed, from which an
+	// interrupted verification resumes.
+	Last []byte
+}
+
+// resumeHeight returns the height after the block with hash tip if it is in
+// the best chain, which is where a job whose work reached tip continues,
+// or 0 if the job has to start over.
+func (bc *BlockChain) resumeHeight(tip []byte) int {
+	if tip == nil {
+		return 0
+	}
+	block, err := bc.GetBlock(tip)
+	if err != nil || !bc.inBestChain(block) {
+		return 0
+	}
+	return block.Height + 1
+}
+
+// deleteTip removes the tip key of an index about to be rebuilt, so a
+// rebuild interrupted while the index is being removed starts over.
+func (bc *BlockChain) deleteTip(key []byte) error {
+	return bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+}
@@ -0,0 +1,78 @@
+package blockchain
+
+import (
+	"context"
+	"testing"
+)
+
+// cancelAfter returns a context cancelled by the returned progress function
+// once n blocks are done, and the last progress reported.
+func cancelAfter(n int) (context.Context, func(JobProgress), *JobProgress) {
+	ctx, cancel := context.WithCancel(context.Background())
+	last := &JobProgress{}
+	return ctx, func(p JobProgress) {
+		*last = p
+		if p.Done >= n {
+			cancel()
+		}
+	}, last
+}
+
+func TestResumeReindex(t *testing.T) {
+	bc := newTestChain(t)
+	for i := 0; i < 4; i++ {
+		minePending(t, bc, carol)
+	}
+	want := balance(t, bc, carol)
+
+	reindexes := map[string]func(context.Context, bool, func(JobProgress)) error{
+		"utxo":         bc.ReindexUTXOContext,
+		"addresses":    bc.ReindexAddressesContext,
+		"transactions": bc.ReindexTransactionsContext,
+	}
+	for name, reindex := range reindexes {
+
+		// an interrupted rebuild stops between blocks
+		ctx, progress, last := cancelAfter(2)
+		if err := reindex(ctx, false, progress); err != ErrJobCancelled {
+			t.Fatalf("%s: got %v, want ErrJobCancelled", name, err)
+		}
+		if last.Done != 2 || last.Total != bc.Height()+1 {
+			t.Fatalf("%s: stopped at %+v, want 2 of %d blocks", name, last, bc.Height()+1)
+		}
+
+		// resuming indexes only the remaining blocks
+		done := 0
+		if err := reindex(context.Background(), true, func(JobProgress) { done++ }); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if done != bc.Height()+1-2 {
+			t.Fatalf("%s: resumed with %d blocks, want %d", name, done, bc.Height()+1-2)
+		}
+		if got := balance(t, bc, carol); got != want {
+			t.Fatalf("%s: got balance %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestResumeVerifyChain(t *testing.T) {
+	bc := newTestChain(t)
+	for i := 0; i < 4; i++ {
+		minePending(t, bc, carol)
+	}
+
+	ctx, progress, last := cancelAfter(3)
+	if _, err := bc.VerifyChainContext(ctx, 0, nil, progress); err != ErrJobCancelled {
+		t.Fatalf("got %v, want ErrJobCancelled", err)
+	}
+
+	// the resumed verification counts the blocks verified before
+	done := 0
+	verified, err := bc.VerifyChainContext(context.Background(), 0, last, func(JobProgress) { done++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified != bc.Height()+1 || done != bc.Height()+1-3 {
+		t.Fatalf("verified %d blocks, %d after resuming, want %d and %d", verified, done, bc.Height()+1, bc.Height()+1-3)
+	}
+}
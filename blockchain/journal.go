@@ -0,0 +1,303 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+)
+
+var (
+	// journalPrefix is the key prefix for journal entries committed with
+	// the blocks they describe and not yet appended to the journal file.
+	journalPrefix = []byte("journal-")
+
+	// journalSeqKey holds the sequence number of the latest journal entry.
+	// The journal is only kept while the key exists.
+	journalSeqKey = []byte("journalseq")
+
+	// journalSizeKey holds the size of the journal file after the latest
+	// entry appended to it.
+	journalSizeKey = []byte("journalsize")
+)
+
+// types of journal entries
+const (
+	JournalConnect    = "connect"
+	JournalDisconnect = "disconnect"
+)
+
+// JournalEntry is a state transition of the best chain in the journal. A
+// connect entry adds Created to the UTXO set and removes Spent from it, and
+// a disconnect entry reverts the same block by removing Created and
+// restoring Spent.
+type JournalEntry struct {
+	Seq      int64           `json:"seq"`
+	Type     string          `json:"type"`
+	Hash     string          `json:"hash"`
+	PrevHash string          `json:"prevHash"`
+	Height   int             `json:"height"`
+	Created  []JournalOutput `json:"created"`
+	Spent    []JournalOutput `json:"spent"`
+}
+
+// JournalOutput is an output created or spent by a block in a JournalEntry.
+type JournalOutput struct {
+	TxID       string       `json:"txid"`
+	Out        int          `json:"out"`
+	Value      units.Amount `json:"value"`
+	PubKeyHash string       `json:"pubKeyHash"`
+}
+
+// journalKey returns the db key for the journal entry with a sequence
+// number, which orders the keys by it.
+func journalKey(seq int64) []byte {
+	return append(append([]byte{}, journalPrefix...), ToBytes(seq)...)
+}
+
+// newJournalEntry creates the journal entry for a block being connected or
+// disconnected, which spends the outputs in its undo record.
+func newJournalEntry(seq int64, entryType string, block *Block, spent []spentOutput) JournalEntry {
+	entry := JournalEntry{
+		Seq:      seq,
+		Type:     entryType,
+		Hash:     hex.EncodeToString(block.Hash),
+		PrevHash: hex.EncodeToString(block.PrevHash),
+		Height:   block.Height,
+		Created:  []JournalOutput{},
+		Spent:    []JournalOutput{},
+	}
+	for _, tx := range block.Transactions {
+		for outIdx, out := range tx.Outputs {
+			if !out.IsData() {
+				entry.Created = append(entry.Created, newJournalOutput(tx.ID, outIdx, out))
+			}
+		}
+	}
+	for _, s := range spent {
+		entry.Spent = append(entry.Spent, newJournalOutput(s.TxID, s.Out, s.Output))
+	}
+	return entry
+}
+
+// newJournalOutput creates the journal description of an output.
+func newJournalOutput(txID []byte, out int, output TxOutput) JournalOutput {
+	return JournalOutput{
+		TxID:       hex.EncodeToString(txID),
+		Out:        out,
+		Value:      output.Value,
+		PubKeyHash: hex.EncodeToString(output.PubKeyHash),
+	}
+}
+
+// journalSeq returns the sequence number of the latest journal entry in
+// txn, and whether the journal is kept.
+func journalSeq(txn *badger.Txn) (int64, bool, error) {
+	item, err := txn.Get(journalSeqKey)
+	if err == badger.ErrKeyNotFound {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, false, err
+	}
+	return FromBytes(value), true, nil
+}
+
+// journalBlock records the journal entry of a block being connected, after
+// connectBlock stored its undo record, or disconnected, before
+// disconnectBlock removes it, if the journal is kept. The entry is
+// committed with the block and appended to the journal file by
+// flushJournal, so a crash in between can't lose it.
+func journalBlock(txn *badger.Txn, entryType string, block *Block) error {
+	seq, kept, err := journalSeq(txn)
+	if err != nil || !kept {
+		return err
+	}
+	spent, err := getUndo(txn, block.Hash)
+	if err != nil {
+		return err
+	}
+	seq++
+	data, err := json.Marshal(newJournalEntry(seq, entryType, block, spent))
+	if err != nil {
+		return err
+	}
+	if err := txn.Set(journalKey(seq), data); err != nil {
+		return err
+	}
+	return txn.Set(journalSeqKey, ToBytes(seq))
+}
+
+// flushJournal appends the journal entries committed since the last flush
+// to the journal file, if the journal is kept. The file is first truncated
+// to the size recorded with the last flush, which drops what a crash left
+// of an unrecorded append, so every entry is in the file exactly once.
+func (bc *BlockChain) flushJournal() error {
+	if bc.journalPath == "" {
+		return nil
+	}
+	bc.journalMutex.Lock()
+	defer bc.journalMutex.Unlock()
+
+	// collect the pending entries in order of their sequence numbers
+	var keys, entries [][]byte
+	var size int64
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(journalSizeKey)
+		if err == nil {
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			size = FromBytes(value)
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(journalPrefix); it.ValidForPrefix(journalPrefix); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, it.Item().KeyCopy(nil))
+			entries = append(entries, value)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.New("unable to read journal entries - " + err.Error())
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	size, err = appendJournal(bc.journalPath, size, entries)
+	if err != nil {
+		return err
+	}
+
+	// forget the appended entries with the new size of the file
+	return bc.DB.Update(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return txn.Set(journalSizeKey, ToBytes(size))
+	})
+}
+
+// appendJournal appends entries to the journal file at path after its first
+// size bytes, one per line, and syncs it to disk. It returns the new size
+// of the file.
+func appendJournal(path string, size int64, entries [][]byte) (int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, errors.New("unable to open journal - " + err.Error())
+	}
+	defer file.Close()
+
+	if err := file.Truncate(size); err != nil {
+		return 0, errors.New("unable to truncate journal - " + err.Error())
+	}
+	if _, err := file.Seek(size, 0); err != nil {
+		return 0, errors.New("unable to seek journal - " + err.Error())
+	}
+	for _, entry := range entries {
+		n, err := file.Write(append(entry, '\n'))
+		if err != nil {
+			return 0, errors.New("unable to append to journal - " + err.Error())
+		}
+		size += int64(n)
+	}
+	if err := file.Sync(); err != nil {
+		return 0, errors.New("unable to sync journal - " + err.Error())
+	}
+	return size, nil
+}
+
+// startJournal starts the journal if it is not kept yet, rewriting the
+// journal file with a connect entry for every block of the best chain so
+// replicas can start from nothing. The journal can't be started on a
+// pruned chain.
+func (bc *BlockChain) startJournal() error {
+	var kept bool
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		var err error
+		_, kept, err = journalSeq(txn)
+		return err
+	})
+	if err != nil || kept {
+		return err
+	}
+
+	// describe the best chain from genesis to the tip
+	var entries [][]byte
+	height := bc.Height()
+	for h := 0; h <= height; h++ {
+		block, err := bc.GetBlockByHeight(h)
+		if err != nil {
+			return err
+		}
+		if block.Pruned() {
+			return fmt.Errorf("unable to journal pruned block %x", block.Hash)
+		}
+		var spent []spentOutput
+		err = bc.DB.View(func(txn *badger.Txn) error {
+			spent, err = getUndo(txn, block.Hash)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(newJournalEntry(int64(h+1), JournalConnect, block, spent))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, data)
+	}
+
+	size, err := appendJournal(bc.journalPath, 0, entries)
+	if err != nil {
+		return err
+	}
+	return bc.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(journalSizeKey, ToBytes(size)); err != nil {
+			return err
+		}
+		return txn.Set(journalSeqKey, ToBytes(int64(len(entries))))
+	})
+}
+
+// dropJournal stops keeping the journal, so it starts over when the chain
+// is opened with one again.
+func (bc *BlockChain) dropJournal() error {
+	var kept bool
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		var err error
+		_, kept, err = journalSeq(txn)
+		return err
+	})
+	if err != nil || !kept {
+		return err
+	}
+
+	if err := bc.deletePrefix(journalPrefix); err != nil {
+		return err
+	}
+	return bc.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Delete(journalSizeKey); err != nil {
+			return err
+		}
+		return txn.Delete(journalSeqKey)
+	})
+}
@@ -0,0 +1,148 @@
+package blockchain
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+)
+
+// replayJournal applies the entries of the journal file at path to an empty
+// UTXO set, checking their sequence numbers, and returns the set.
+func replayJournal(t *testing.T, path string) map[string]JournalOutput {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	unspent := make(map[string]JournalOutput)
+	key := func(o JournalOutput) string { return fmt.Sprintf("%s:%d", o.TxID, o.Out) }
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for seq := int64(1); scanner.Scan(); seq++ {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("entry %d: %s", seq, err)
+		}
+		if entry.Seq != seq {
+			t.Fatalf("got entry %d, want %d", entry.Seq, seq)
+		}
+		add, remove := entry.Created, entry.Spent
+		if entry.Type == JournalDisconnect {
+			add, remove = entry.Spent, entry.Created
+		}
+		for _, o := range remove {
+			delete(unspent, key(o))
+		}
+		for _, o := range add {
+			unspent[key(o)] = o
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return unspent
+}
+
+// checkReplica fails the test unless replaying the journal gives the UTXO
+// set of the chain.
+func checkReplica(t *testing.T, bc *BlockChain, path string) {
+	t.Helper()
+	replica := replayJournal(t, path)
+	count := 0
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(utxoPrefix); it.ValidForPrefix(utxoPrefix); it.Next() {
+			key := it.Item().Key()[len(utxoPrefix):]
+			txID := hex.EncodeToString(key[:HashLength])
+			out := int(FromBytes(key[HashLength:]))
+			o, ok := replica[fmt.Sprintf("%s:%d", txID, out)]
+			if !ok {
+				t.Errorf("replica is missing output %s:%d", txID, out)
+			}
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if ok && o.Value != deserializeOutput(value).Value {
+				t.Errorf("replica has value %d for output %s:%d", o.Value, txID, out)
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(replica) {
+		t.Fatalf("replica has %d outputs, chain %d", len(replica), count)
+	}
+}
+
+func TestJournalReplicatesUTXOSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	bc := newTestChainWithConfig(t, Config{JournalPath: path})
+	genesis := tip(t, bc)
+	checkReplica(t, bc, path)
+
+	// blocks connected on the tip are journaled
+	tx := send(t, bc, alice, bob, 10, 0)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, alice)
+	checkReplica(t, bc, path)
+
+	// a reorganization disconnects the old chain before connecting the new
+	side1 := mineOn(t, bc, genesis, carol)
+	if err := bc.AcceptBlock(side1); err != nil {
+		t.Fatal(err)
+	}
+	side2 := mineOn(t, bc, side1, carol)
+	if err := bc.AcceptBlock(side2); err != nil {
+		t.Fatal(err)
+	}
+	checkReplica(t, bc, path)
+
+	// a partial append left by a crash is dropped by the next flush
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"seq":`)
+	file.Close()
+	minePending(t, bc, carol)
+	checkReplica(t, bc, path)
+}
+
+func TestJournalRestarts(t *testing.T) {
+	bc := newTestChain(t)
+	minePending(t, bc, carol)
+	minePending(t, bc, carol)
+
+	// a journal started on an existing chain describes it from genesis
+	path := filepath.Join(t.TempDir(), "journal")
+	bc.journalPath = path
+	if err := bc.startJournal(); err != nil {
+		t.Fatal(err)
+	}
+	checkReplica(t, bc, path)
+
+	// a stopped journal is rewritten when started again
+	if err := bc.dropJournal(); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, carol)
+	if err := bc.startJournal(); err != nil {
+		t.Fatal(err)
+	}
+	checkReplica(t, bc, path)
+}
@@ -0,0 +1,219 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// jsonBlock is the JSON representation of a Block. Time is the timestamp
+// formatted for people and is ignored when decoding.
+type jsonBlock struct {
+	Version      int            `json:"version"`
+	Hash         string         `json:"hash"`
+	PrevHash     string         `json:"prevHash"`
+	MerkleRoot   string         `json:"merkleRoot"`
+	Height       int            `json:"height"`
+	Timestamp    int64          `json:"timestamp"`
+	Time         string         `json:"time"`
+	Difficulty   int            `json:"difficulty"`
+	Nonce        int            `json:"nonce"`
+	Transactions []*Transaction `json:"transactions"`
+
+	// Signature is only set for sealed blocks
+	Signature string `json:"signature,omitempty"`
+}
+
+// jsonTransaction is the JSON representation of a Transaction.
+type jsonTransaction struct {
+	ID      string     `json:"id"`
+	Version int        `json:"version"`
+	Inputs  []TxInput  `json:"inputs"`
+	Outputs []TxOutput `json:"outputs"`
+}
+
+// jsonTxInput is the JSON representation of a TxInput.
+type jsonTxInput struct {
+	TxID      string `json:"txid"`
+	Out       int    `json:"vout"`
+	Signature string `json:"signature"`
+	PubKey    string `json:"pubKey"`
+	Address   string `json:"address,omitempty"`
+
+	// CoinbaseData is only set for coinbase inputs
+	CoinbaseData string `json:"coinbaseData,omitempty"`
+}
+
+// jsonTxOutput is the JSON representation of a TxOutput. The address is
+// derived from the public key hash and is ignored when decoding.
+type jsonTxOutput struct {
+	Value      units.Amount `json:"value"`
+	Address    string       `json:"address"`
+	PubKeyHash string       `json:"pubKeyHash"`
+
+	// Data is only set for data outputs
+	Data string `json:"data,omitempty"`
+}
+
+// MarshalJSON encodes a Block as JSON with hex encoded hashes.
+func (b Block) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonBlock{
+		Version:      b.Version,
+		Hash:         hex.EncodeToString(b.Hash),
+		PrevHash:     hex.EncodeToString(b.PrevHash),
+		MerkleRoot:   hex.EncodeToString(b.Header().MerkleRoot),
+		Height:       b.Height,
+		Timestamp:    b.Timestamp,
+		Time:         FormatTimestamp(b.Timestamp),
+		Difficulty:   b.GetDifficulty(),
+		Nonce:        b.Nonce,
+		Transactions: b.Transactions,
+		Signature:    hex.EncodeToString(b.Signature),
+	})
+}
+
+// UnmarshalJSON decodes a Block from JSON written by MarshalJSON.
+func (b *Block) UnmarshalJSON(data []byte) error {
+	var j jsonBlock
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	hash, err := hex.DecodeString(j.Hash)
+	if err != nil {
+		return errors.New("invalid block hash - " + err.Error())
+	}
+	prevHash, err := hex.DecodeString(j.PrevHash)
+	if err != nil {
+		return errors.New("invalid previous block hash - " + err.Error())
+	}
+
+	merkleRoot, err := hex.DecodeString(j.MerkleRoot)
+	if err != nil {
+		return errors.New("invalid Merkle root - " + err.Error())
+	}
+	signature, err := hex.DecodeString(j.Signature)
+	if err != nil {
+		return errors.New("invalid block signature - " + err.Error())
+	}
+
+	*b = Block{
+		BlockHeader: BlockHeader{
+			Version:    j.Version,
+			Hash:       hash,
+			PrevHash:   prevHash,
+			MerkleRoot: copyBytes(merkleRoot),
+			Nonce:      j.Nonce,
+			Height:     j.Height,
+			Timestamp:  j.Timestamp,
+			Difficulty: j.Difficulty,
+			Signature:  copyBytes(signature),
+		},
+		Transactions: j.Transactions,
+	}
+	b.BlockHeader = *b.Header()
+	return nil
+}
+
+// MarshalJSON encodes a Transaction as JSON with a hex encoded ID.
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonTransaction{
+		ID:      hex.EncodeToString(tx.ID),
+		Version: tx.Version,
+		Inputs:  tx.Inputs,
+		Outputs: tx.Outputs,
+	})
+}
+
+// UnmarshalJSON decodes a Transaction from JSON written by MarshalJSON.
+func (tx *Transaction) UnmarshalJSON(data []byte) error {
+	var j jsonTransaction
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	id, err := hex.DecodeString(j.ID)
+	if err != nil {
+		return errors.New("invalid transaction id - " + err.Error())
+	}
+
+	*tx = Transaction{
+		ID:      id,
+		Version: j.Version,
+		Inputs:  j.Inputs,
+		Outputs: j.Outputs,
+	}
+	return nil
+}
+
+// MarshalJSON encodes a TxInput as JSON with hex encoded fields.
+func (in TxInput) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonTxInput{
+		TxID:      hex.EncodeToString(in.ID),
+		Out:       in.Out,
+		Signature: hex.EncodeToString(in.Signature),
+		PubKey:    hex.EncodeToString(in.PubKey),
+		Address:   in.Address(),
+
+		CoinbaseData: hex.EncodeToString(in.CoinbaseData),
+	})
+}
+
+// UnmarshalJSON decodes a TxInput from JSON written by MarshalJSON.
+func (in *TxInput) UnmarshalJSON(data []byte) error {
+	var j jsonTxInput
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	var fields [4][]byte
+	for i, value := range []string{j.TxID, j.Signature, j.PubKey, j.CoinbaseData} {
+		decoded, err := hex.DecodeString(value)
+		if err != nil {
+			return errors.New("invalid transaction input - " + err.Error())
+		}
+		fields[i] = decoded
+	}
+
+	*in = TxInput{
+		ID:        fields[0],
+		Out:       j.Out,
+		Signature: fields[1],
+		PubKey:    fields[2],
+
+		CoinbaseData: fields[3],
+	}
+	return nil
+}
+
+// MarshalJSON encodes a TxOutput as JSON with its address and hex encoded
+// public key hash.
+func (out TxOutput) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonTxOutput{
+		Value:      out.Value,
+		Address:    out.Address(),
+		PubKeyHash: hex.EncodeToString(out.PubKeyHash),
+		Data:       hex.EncodeToString(out.Data()),
+	})
+}
+
+// UnmarshalJSON decodes a TxOutput from JSON written by MarshalJSON.
+func (out *TxOutput) UnmarshalJSON(data []byte) error {
+	var j jsonTxOutput
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	pubKeyHash, err := hex.DecodeString(j.PubKeyHash)
+	if err != nil {
+		return errors.New("invalid output public key hash - " + err.Error())
+	}
+
+	*out = TxOutput{
+		Value:      j.Value,
+		PubKeyHash: pubKeyHash,
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package blockchain
+
+import (
+	"sync"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// BlockHook is called with a block that joined or left the best chain.
+type BlockHook func(block *Block)
+
+// TxHook is called with a transaction added to the mempool and its fee.
+type TxHook func(tx *Transaction, fee units.Amount)
+
+// TxPolicy is called with a transaction and its fee before the transaction
+// is added to the mempool, and rejects it by returning an error.
+type TxPolicy func(tx *Transaction, fee units.Amount) error
+
+// lifecycleHooks are the functions registered by embedders of the package
+// to follow the chain and the mempool, in the order they were registered.
+type lifecycleHooks struct {
+	mutex        sync.RWMutex
+	connected    []BlockHook
+	disconnected []BlockHook
+	accepted     []TxHook
+	policies     []TxPolicy
+}
+
+// OnBlockConnected registers h to be called with each block that joins the
+// best chain from then on, such as to maintain a custom index.
+//
+// Hooks are called in the order they were registered, after the changes
+// to the chain are stored and outside any db transaction, and in the order
+// the chain changed, so during a reorganization the blocks leaving the best
+// chain are passed to the hooks of OnBlockDisconnected from the old tip down
+// before those joining it are passed to these from the fork up. The chain is
+// locked while they run, so they must not add blocks or transactions to it.
+func (bc *BlockChain) OnBlockConnected(h BlockHook) {
+	bc.lifecycle.mutex.Lock()
+	defer bc.lifecycle.mutex.Unlock()
+	bc.lifecycle.connected = append(bc.lifecycle.connected, h)
+}
+
+// OnBlockDisconnected registers h to be called with each block that leaves
+// the best chain in a reorganization from then on, ordered like the hooks
+// of OnBlockConnected.
+func (bc *BlockChain) OnBlockDisconnected(h BlockHook) {
+	bc.lifecycle.mutex.Lock()
+	defer bc.lifecycle.mutex.Unlock()
+	bc.lifecycle.disconnected = append(bc.lifecycle.disconnected, h)
+}
+
+// OnTxAccepted registers h to be called with each transaction added to the
+// mempool by AddToMempool from then on, after it is stored, in the order
+// they were added. Like block hooks, it runs while the chain is locked.
+func (bc *BlockChain) OnTxAccepted(h TxHook) {
+	bc.lifecycle.mutex.Lock()
+	defer bc.lifecycle.mutex.Unlock()
+	bc.lifecycle.accepted = append(bc.lifecycle.accepted, h)
+}
+
+// OnTxAccept registers p to check each transaction once it passed the
+// checks of the mempool, before plugins and before it is added. Policies
+// run in the order they were registered, and the first to return an error
+// rejects the transaction with RejectPolicy.
+func (bc *BlockChain) OnTxAccept(p TxPolicy) {
+	bc.lifecycle.mutex.Lock()
+	defer bc.lifecycle.mutex.Unlock()
+	bc.lifecycle.policies = append(bc.lifecycle.policies, p)
+}
+
+// blockConnected calls the hooks of OnBlockConnected with block.
+func (l *lifecycleHooks) blockConnected(block *Block) {
+	l.mutex.RLock()
+	registered := l.connected
+	l.mutex.RUnlock()
+	for _, h := range registered {
+		h(block)
+	}
+}
+
+// blockDisconnected calls the hooks of OnBlockDisconnected with block.
+func (l *lifecycleHooks) blockDisconnected(block *Block) {
+	l.mutex.RLock()
+	registered := l.disconnected
+	l.mutex.RUnlock()
+	for _, h := range registered {
+		h(block)
+	}
+}
+
+// txAccepted calls the hooks of OnTxAccepted with tx and its fee.
+func (l *lifecycleHooks) txAccepted(tx *Transaction, fee units.Amount) {
+	l.mutex.RLock()
+	registered := l.accepted
+	l.mutex.RUnlock()
+	for _, h := range registered {
+		h(tx, fee)
+	}
+}
+
+// checkPolicies returns the error of the first policy of OnTxAccept that
+// rejects tx.
+func (l *lifecycleHooks) checkPolicies(tx *Transaction, fee units.Amount) error {
+	l.mutex.RLock()
+	policies := l.policies
+	l.mutex.RUnlock()
+	for _, p := range policies {
+		if err := p(tx, fee); err != nil {
+			return err
+		}
+	}
+	return nil
+}
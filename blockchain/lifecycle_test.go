@@ -0,0 +1,69 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestLifecycleHooks(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+
+	// record each call of the hooks in order
+	var calls []string
+	bc.OnBlockConnected(func(block *Block) {
+		calls = append(calls, fmt.Sprintf("connected %d", block.Height))
+	})
+	bc.OnBlockConnected(func(block *Block) {
+		calls = append(calls, fmt.Sprintf("indexed %d", block.Height))
+	})
+	bc.OnBlockDisconnected(func(block *Block) {
+		calls = append(calls, fmt.Sprintf("disconnected %d", block.Height))
+	})
+	bc.OnTxAccepted(func(tx *Transaction, fee units.Amount) {
+		calls = append(calls, fmt.Sprintf("accepted fee %d", fee))
+	})
+
+	// a policy rejects transactions paying bob more than a coin
+	bc.OnTxAccept(func(tx *Transaction, fee units.Amount) error {
+		for _, out := range tx.Outputs {
+			if out.IsLockedWithKey(bob.Address().PubKeyHash) && out.Value > units.Coin {
+				return errors.New("too much for bob")
+			}
+		}
+		return nil
+	})
+	err := bc.AddToMempool(send(t, bc, alice, bob, 2*units.Coin, 0))
+	if rejected, ok := err.(*RejectError); !ok || rejected.Rule != RejectPolicy {
+		t.Fatalf("got %v, want a policy rejection", err)
+	}
+
+	if err := bc.AddToMempool(send(t, bc, alice, bob, units.Coin, 5)); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, alice)
+
+	// a heavier side chain disconnects the block before connecting its own
+	side1 := mineOn(t, bc, genesis, carol)
+	if err := bc.AcceptBlock(side1); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AcceptBlock(mineOn(t, bc, side1, carol)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"accepted fee 5",
+		"connected 1", "indexed 1",
+		"disconnected 1",
+		"connected 1", "indexed 1",
+		"connected 2", "indexed 2",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("got hook calls %q, want %q", calls, want)
+	}
+}
@@ -0,0 +1,73 @@
+package blockchain
+
+import "github.com/edwintcloud/gochain/consensus"
+
+// The size limits are consensus rules: blocks breaking them are rejected,
+// so every node of a network must use the same ones. Sizes are those of
+// transactions serialized with Serialize.
+const (
+	// MaxBlockSize is the most bytes the serialized transactions of a
+	// block may take together.
+	MaxBlockSize = consensus.MaxBlockSize
+
+	// MaxTxSize is the most bytes a serialized transaction may take.
+	MaxTxSize = consensus.MaxTxSize
+
+	// MaxTxInputs and MaxTxOutputs are the most inputs and outputs a
+	// transaction may have.
+	MaxTxInputs  = consensus.MaxTxInputs
+	MaxTxOutputs = consensus.MaxTxOutputs
+)
+
+// checkSize verifies that a transaction is within the size limits,
+// returning its serialized size.
+func (tx *Transaction) checkSize() (int, error) {
+	size := len(tx.Serialize())
+	if err := consensus.CheckTxSize(tx.ID, len(tx.Inputs), len(tx.Outputs), size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// checkBlockSize verifies that a block and its transactions are within the
+// size limits.
+func checkBlockSize(b *Block) error {
+	total := 0
+	for _, tx := range b.Transactions {
+		size, err := tx.checkSize()
+		if err != nil {
+			return err
+		}
+		total += size
+	}
+	return consensus.CheckBlockSize(b.Hash, total)
+}
+
+// fitBlock returns the transactions of txs, ordered with parents first,
+// that fit in a block of maxSize bytes after a coinbase of coinbaseSize
+// bytes, in order. Transactions spending a transaction left out are left
+// out too.
+func fitBlock(txs []*Transaction, coinbaseSize, maxSize int) []*Transaction {
+	var fitted []*Transaction
+	left := make(map[string]bool)
+	size := coinbaseSize
+
+Transactions: // a label to continue from
+	for _, tx := range txs {
+		for _, in := range tx.Inputs {
+			if left[string(in.ID)] {
+				left[string(tx.ID)] = true
+				continue Transactions
+			}
+		}
+		txSize := len(tx.Serialize())
+		if size+txSize > maxSize {
+			left[string(tx.ID)] = true
+			continue
+		}
+		size += txSize
+		fitted = append(fitted, tx)
+	}
+
+	return fitted
+}
@@ -0,0 +1,74 @@
+package blockchain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// paddedTx returns a transaction with data of size bytes in its input.
+func paddedTx(size int, spends []byte) *Transaction {
+	tx := &Transaction{
+		Inputs:  []TxInput{{ID: spends, Out: 0, CoinbaseData: bytes.Repeat([]byte{1}, size)}},
+		Outputs: []TxOutput{*NewTXOutput(1, bob.Address().String())},
+	}
+	if spends == nil {
+		tx.Inputs[0].Out = -1
+	}
+	tx.ID = tx.GenerateHash()
+	return tx
+}
+
+func TestCheckBlockSize(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		txs  []*Transaction
+		err  string
+	}{
+		{"within limits", []*Transaction{paddedTx(MaxTxSize/2, nil)}, ""},
+		{"large transaction", []*Transaction{paddedTx(MaxTxSize, nil)}, "bytes, more than"},
+		{"many outputs", []*Transaction{{Outputs: make([]TxOutput, MaxTxOutputs+1)}}, "outputs, more than"},
+		{"many inputs", []*Transaction{{Inputs: make([]TxInput, MaxTxInputs+1)}}, "inputs, more than"},
+		{"large block", func() []*Transaction {
+			var txs []*Transaction
+			for i := 0; i <= MaxBlockSize/(MaxTxSize/2); i++ {
+				txs = append(txs, paddedTx(MaxTxSize/2+i, nil))
+			}
+			return txs
+		}(), "bytes of transactions"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkBlockSize(&Block{Transactions: c.txs})
+			if c.err == "" && err != nil || c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+				t.Fatalf("got %v, want %q", err, c.err)
+			}
+		})
+	}
+}
+
+func TestFitBlock(t *testing.T) {
+	small := paddedTx(100, nil)
+	large := paddedTx(1000, nil)
+	child := paddedTx(100, large.ID)
+	size := func(tx *Transaction) int { return len(tx.Serialize()) }
+
+	// the large transaction and its child are left out, the rest fit in order
+	maxSize := 10 + size(small) + size(child)
+	got := fitBlock([]*Transaction{small, large, child}, 10, maxSize)
+	if len(got) != 1 || got[0] != small {
+		t.Fatalf("got %d transactions, want only the small one", len(got))
+	}
+	got = fitBlock([]*Transaction{small, large, child}, 0, size(small)+size(large)+size(child))
+	if len(got) != 3 {
+		t.Fatalf("got %d transactions, want all 3", len(got))
+	}
+}
+
+func TestMempoolRejectsLargeTransactions(t *testing.T) {
+	bc := newTestChain(t)
+	tx := send(t, bc, alice, bob, 1, 0)
+	tx.Inputs[0].PubKey = bytes.Repeat([]byte{1}, MaxTxSize)
+	if err := bc.AddToMempool(tx); err == nil || !strings.Contains(err.Error(), "more than") {
+		t.Fatalf("got %v, want the transaction rejected for its size", err)
+	}
+}
@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+// lockPrefix is the key prefix for outputs locked against coin selection.
+var lockPrefix = []byte("locked-")
+
+// lockKey returns the db key for a locked transaction output.
+func lockKey(txID []byte, out int) []byte {
+	key := append(append([]byte{}, lockPrefix...), txID...)
+	return append(key, ToBytes(int64(out))...)
+}
+
+// LockUnspent locks a transaction output so it is excluded from automatic
+// coin selection until it is unlocked.
+func (bc *BlockChain) LockUnspent(txID Hash, out int) error {
+
+	// ensure the referenced output exists
+	tx, err := bc.FindTransaction(txID.Bytes())
+	if err != nil {
+		return err
+	}
+	if out < 0 || out >= len(tx.Outputs) {
+		return fmt.Errorf("transaction %s has no output %d", txID, out)
+	}
+
+	// initiate rw transaction on db to store the lock
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(lockKey(txID.Bytes(), out), ToBytes(int64(out)))
+	})
+	if err != nil {
+		bc.panicf("Unable to lock transaction output: %s", err.Error())
+	}
+
+	return nil
+}
+
+// UnlockUnspent removes the lock from a transaction output.
+func (bc *BlockChain) UnlockUnspent(txID Hash, out int) error {
+	if !bc.IsLocked(txID, out) {
+		return fmt.Errorf("output %s:%d is not locked", txID, out)
+	}
+
+	// initiate rw transaction on db to remove the lock
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(lockKey(txID.Bytes(), out))
+	})
+	if err != nil {
+		bc.panicf("Unable to unlock transaction output: %s", err.Error())
+	}
+
+	return nil
+}
+
+// IsLocked reports whether a transaction output is locked.
+func (bc *BlockChain) IsLocked(txID Hash, out int) bool {
+	locked := false
+
+	// initiate read only transaction on db to look for the lock
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(lockKey(txID.Bytes(), out))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		locked = err == nil
+		return err
+	})
+	if err != nil {
+		bc.panicf("Unable to read output lock from database: %s", err.Error())
+	}
+
+	return locked
+}
+
+// LockedOutputs returns the locked output indexes keyed by hex encoded
+// transaction ID.
+func (bc *BlockChain) LockedOutputs() map[string][]int {
+	locked := make(map[string][]int)
+
+	// initiate read only transaction on db to iterate over locks
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(lockPrefix); it.ValidForPrefix(lockPrefix); it.Next() {
+			key := it.Item().Key()
+			if len(key) < len(lockPrefix)+8 {
+				// return from closure with error
+				return errors.New("malformed output lock key")
+			}
+
+			// key is prefix + txID + 8 byte output index
+			txID := key[len(lockPrefix) : len(key)-8]
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				// return from closure with error
+				return errors.New("unable to get value from lock item - " + err.Error())
+			}
+			out := int(FromBytes(value))
+
+			id := hex.EncodeToString(txID)
+			locked[id] = append(locked[id], out)
+		}
+
+		// return from closure
+		return nil
+	})
+	if err != nil {
+		bc.panicf("Unable to read output locks from database: %s", err.Error())
+	}
+
+	return locked
+}
@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/edwintcloud/gochain/logging"
+)
+
+// recordingLogger is a logging.Logger keeping the level and message of
+// every message.
+type recordingLogger struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) record(level logging.Level, msg string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.messages = append(l.messages, level.String()+": "+msg)
+}
+
+func (l *recordingLogger) Debug(msg string, _ ...interface{}) { l.record(logging.DebugLevel, msg) }
+func (l *recordingLogger) Info(msg string, _ ...interface{})  { l.record(logging.InfoLevel, msg) }
+func (l *recordingLogger) Warn(msg string, _ ...interface{})  { l.record(logging.WarnLevel, msg) }
+func (l *recordingLogger) Error(msg string, _ ...interface{}) { l.record(logging.ErrorLevel, msg) }
+
+// logged returns whether msg was logged.
+func (l *recordingLogger) logged(msg string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, m := range l.messages {
+		if m == msg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestInjectedLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	bc := newTestChainWithConfig(t, Config{Logger: logger})
+	genesis := tip(t, bc)
+
+	// switch to a heavier side chain
+	minePending(t, bc, alice)
+	side1 := mineOn(t, bc, genesis, carol)
+	side2 := mineOn(t, bc, side1, carol)
+	for _, block := range []*Block{side1, side2} {
+		if err := bc.AcceptBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// database failures are logged before panicking
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+		bc.panicf("Unable to read %s", "tip")
+	}()
+
+	for _, want := range []string{
+		"info: Genesis block created",
+		"debug: Sending block event",
+		"warn: Reorganized best chain",
+		"error: Unable to read tip",
+	} {
+		if !logger.logged(want) {
+			t.Errorf("%q was not logged, got %q", want, logger.messages)
+		}
+	}
+}
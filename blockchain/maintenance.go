@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+// DefaultGCDiscardRatio is the fraction of a value log file that must be
+// reclaimable for garbage collection to rewrite it when the configuration
+// doesn't give one.
+const DefaultGCDiscardRatio = 0.5
+
+// gcDiscardRatio returns the garbage collection discard ratio of the
+// configuration.
+func (cfg Config) gcDiscardRatio() float64 {
+	if cfg.GCDiscardRatio == 0 {
+		return DefaultGCDiscardRatio
+	}
+	return cfg.GCDiscardRatio
+}
+
+// CompactReport is the result of compacting the database.
+type CompactReport struct {
+
+	// Rewrites is the number of value log files rewritten.
+	Rewrites int
+
+	// Before and After are the size in bytes of the database files before
+	// and after compacting.
+	Before int64
+	After  int64
+}
+
+// CompactDB garbage collects the value log of the database, rewriting every
+// file of which at least discardRatio is reclaimable, or the GCDiscardRatio
+// of the configuration of the chain if discardRatio is 0.
+func (bc *BlockChain) CompactDB(discardRatio float64) (CompactReport, error) {
+	var report CompactReport
+	if discardRatio == 0 {
+		discardRatio = bc.gcDiscardRatio
+	}
+	if discardRatio <= 0 || discardRatio >= 1 {
+		return report, errors.New("discard ratio must be between 0 and 1")
+	}
+
+	before, err := dirSize(bc.path)
+	if err != nil {
+		return report, errors.New("unable to get database size - " + err.Error())
+	}
+	report.Before = before
+
+	report.Rewrites, err = collectGarbage(bc.DB, discardRatio)
+	if err != nil {
+		return report, errors.New("unable to collect value log garbage - " + err.Error())
+	}
+
+	report.After, err = dirSize(bc.path)
+	if err != nil {
+		return report, errors.New("unable to get database size - " + err.Error())
+	}
+	return report, nil
+}
+
+// collectGarbage rewrites value log files of db until none has discardRatio
+// of it reclaimable, returning the number of files rewritten. Badger
+// rewrites at most one file per call.
+func collectGarbage(db *badger.DB, discardRatio float64) (int, error) {
+	rewrites := 0
+	for {
+		err := db.RunValueLogGC(discardRatio)
+		if err == badger.ErrNoRewrite {
+			return rewrites, nil
+		} else if err != nil {
+			return rewrites, err
+		}
+		rewrites++
+	}
+}
+
+// startGC garbage collects the value log every interval until the chain is
+// closed.
+func (bc *BlockChain) startGC(interval time.Duration) {
+	bc.stopGC = make(chan struct{})
+	bc.gcDone = make(chan struct{})
+	go func() {
+		defer close(bc.gcDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-bc.stopGC:
+				return
+			case <-ticker.C:
+			}
+
+			// another collection such as CompactDB being run is not a
+			// failure, the next tick tries again
+			rewrites, err := collectGarbage(bc.DB, bc.gcDiscardRatio)
+			if err == badger.ErrRejected {
+				continue
+			} else if err != nil {
+				bc.log.Warn("Unable to collect value log garbage", "error", err.Error())
+			} else if rewrites > 0 {
+				bc.log.Debug("Collected value log garbage", "rewrites", rewrites)
+			}
+		}
+	}()
+}
+
+// stopGarbageCollection stops the garbage collection started by startGC, if
+// any, and waits for a collection in progress to finish.
+func (bc *BlockChain) stopGarbageCollection() {
+	if bc.stopGC == nil {
+		return
+	}
+	close(bc.stopGC)
+	<-bc.gcDone
+}
+
+// dirSize returns the total size in bytes of the files in a directory.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
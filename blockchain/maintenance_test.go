@@ -0,0 +1,31 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompactDB(t *testing.T) {
+	bc := newTestChainWithConfig(t, Config{GCInterval: time.Millisecond, NoSyncWrites: true})
+	minePending(t, bc, carol)
+
+	// the chain keeps working while its value log is collected
+	time.Sleep(10 * time.Millisecond)
+	minePending(t, bc, carol)
+
+	report, err := bc.CompactDB(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Before <= 0 || report.After <= 0 {
+		t.Fatalf("got sizes %d and %d, want the size of the database", report.Before, report.After)
+	}
+	if _, err := bc.CompactDB(1); err == nil {
+		t.Fatal("compacted with a discard ratio of 1")
+	}
+
+	// closing stops the collection before closing the database
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,48 @@
+package blockchain
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"github.com/edwintcloud/gochain/logging"
+)
+
+// InitInMemory creates a throwaway chain for tests, examples and
+// simulations, with the genesis block of genesisCfg or of an empty network
+// if it is nil. Every block is mined at difficulty 1, so blocks are found
+// instantly, and the same configuration always gives the same genesis
+// block. Coinbase data and signatures are still random, so the hashes of
+// later blocks differ between runs. The database needs no path and is
+// removed by Close. Badger 1.5 has no in-memory mode, so it is kept in a
+// temporary directory written without syncing.
+func InitInMemory(genesisCfg *Genesis) (*BlockChain, error) {
+	genesis := Genesis{Network: "memory", Timestamp: 1}
+	if genesisCfg != nil {
+		genesis = *genesisCfg
+	}
+	genesis.Difficulty = 1
+	genesis.MinDifficulty = 1
+	genesis.MaxDifficulty = 1
+	genesis.AllowMinDifficulty = false
+	if genesis.Timestamp == 0 {
+		genesis.Timestamp = 1
+	}
+
+	dir, err := ioutil.TempDir("", "gochain-memory-")
+	if err != nil {
+		return nil, errors.New("unable to create in-memory database - " + err.Error())
+	}
+	bc, err := Open(Config{
+		Path:         dir,
+		Genesis:      &genesis,
+		Logger:       logging.Discard,
+		NoSyncWrites: true,
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	bc.temporary = true
+	return bc, nil
+}
@@ -0,0 +1,50 @@
+package blockchain
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestInitInMemory(t *testing.T) {
+	genesis := &Genesis{
+		Network:     "example",
+		Allocations: map[string]units.Amount{alice.Address().String(): genesisAllocation},
+		Difficulty:  20,
+	}
+	bc, err := InitInMemory(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := InitInMemory(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if !bytes.Equal(bc.Tip(), other.Tip()) {
+		t.Fatal("the same configuration gave different genesis blocks")
+	}
+
+	// blocks are mined at the trivial difficulty
+	tx := send(t, bc, alice, bob, 10*units.Coin, 0)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	block := minePending(t, bc, carol)
+	if block.Difficulty != 1 {
+		t.Fatalf("block mined at difficulty %d, want 1", block.Difficulty)
+	}
+	if got := balance(t, bc, bob); got != 10*units.Coin {
+		t.Fatalf("bob has %s, want 10", got)
+	}
+
+	// closing removes the database
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(bc.path); !os.IsNotExist(err) {
+		t.Fatalf("database %s was not removed", bc.path)
+	}
+}
@@ -0,0 +1,351 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/consensus"
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/hooks"
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// mempoolPrefix is the key prefix for pending transactions stored in the db.
+var mempoolPrefix = []byte("mempool-")
+
+// mempoolKey returns the db key for a pending transaction ID.
+func mempoolKey(ID []byte) []byte {
+	return append(append([]byte{}, mempoolPrefix...), ID...)
+}
+
+// AddToMempool verifies a Transaction and stores it in the mempool so it
+// can be mined later. Transactions in the mempool may spend outputs of
+// other pending transactions.
+func (bc *BlockChain) AddToMempool(tx *Transaction) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.addToMempool(tx, "")
+}
+
+// AddRequestToMempool adds a Transaction to the mempool like AddToMempool
+// and records it as the result of a client request ID, so a retried request
+// can find the transaction instead of sending again.
+func (bc *BlockChain) AddRequestToMempool(requestID string, tx *Transaction) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	if _, ok := bc.RequestTransaction(requestID); ok {
+		return fmt.Errorf("request %s has already been completed", requestID)
+	}
+	return bc.addToMempool(tx, requestID)
+}
+
+// addToMempool verifies and stores a Transaction in the mempool, recording
+// the request ID with it in the same db transaction if one is given.
+func (bc *BlockChain) addToMempool(tx *Transaction, requestID string) error {
+	fee, replaced, err := bc.checkMempoolTransaction(tx)
+	if err != nil {
+		return err
+	}
+	evicted, err := bc.mempoolEvictions(tx, fee, replaced)
+	if err != nil {
+		return err
+	}
+
+	// initiate rw transaction on db to store the pending transaction in
+	// place of those it replaces and evicts
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		for _, p := range append(replaced, evicted...) {
+			if err := deleteFromMempool(txn, p.ID); err != nil {
+				return err
+			}
+		}
+		if requestID != "" {
+			if err := txn.Set(requestKey(requestID), tx.ID); err != nil {
+				return err
+			}
+		}
+		return addToMempoolTxn(txn, tx, bc.now().Unix())
+	})
+	if err != nil {
+		bc.panicf("Unable to add transaction to mempool: %s", err.Error())
+	}
+	bc.feeHistogram.remove(replaced)
+	bc.feeHistogram.add(tx, fee)
+	if len(evicted) > 0 {
+		bc.mempoolEvicted(evicted)
+	}
+	if len(replaced) > 0 {
+		bc.log.Debug("Transactions replaced in mempool", "tx", tx.ID, "replaced", len(replaced))
+		bc.events.Publish(events.Event{Type: events.ReplacedTx, Payload: newReplaceEvent(replaced, tx)})
+	}
+	bc.log.Debug("Transaction added to mempool", "tx", tx.ID, "fee", fee)
+	bc.lifecycle.txAccepted(tx, fee)
+	bc.events.Publish(events.Event{Type: events.NewTx, Payload: tx})
+
+	return nil
+}
+
+// checkMempoolTransaction verifies a Transaction against the chain and the
+// mempool, returning its fee and the pending transactions it replaces, or a
+// *RejectError with the rule it breaks. Every input must spend an output
+// of the UTXO set or of a pending transaction, it must be at least version
+// 1, so its signatures cover its outputs, and the values it spends
+// must not overflow. Its outputs must pass the same consensus checks as
+// those of blocks.
+func (bc *BlockChain) checkMempoolTransaction(tx *Transaction) (units.Amount, []*Transaction, error) {
+	if err := tx.checkInputs(); err != nil {
+		return 0, nil, reject(RejectMalformed, err)
+	}
+	if tx.Version == 0 {
+		return 0, nil, reject(RejectVersion, fmt.Errorf("transaction %x is version 0, whose signatures don't cover its outputs", tx.ID))
+	}
+	if _, err := tx.checkSize(); err != nil {
+		return 0, nil, reject(RejectSize, err)
+	}
+	if err := tx.checkDataOutputs(); err != nil {
+		return 0, nil, reject(RejectDataOutputs, err)
+	}
+	outputValue, err := consensus.CheckOutputs(tx.consensusTx())
+	if err != nil {
+		return 0, nil, reject(RejectValue, err)
+	}
+	pending := bc.MempoolTransactions()
+
+	// ensure every input spends an output that is unspent on the chain or
+	// created by a pending transaction, and is not spent twice by the
+	// transaction, adding up the value spent
+	available := make(map[string]TxOutput)
+	for _, p := range pending {
+		for outIdx, out := range p.Outputs {
+			if !out.IsData() {
+				available[outpoint(p.ID, outIdx)] = out
+			}
+		}
+	}
+	spent := make(map[string]bool)
+	var inputValue units.Amount
+	for _, in := range tx.Inputs {
+		if spent[outpoint(in.ID, in.Out)] {
+			return 0, nil, reject(RejectDuplicateInput, fmt.Errorf("output %s is spent twice by the transaction", outpoint(in.ID, in.Out)))
+		}
+		spent[outpoint(in.ID, in.Out)] = true
+		out, ok := bc.GetUnspentOutput(in.ID, in.Out)
+		if !ok {
+			out, ok = available[outpoint(in.ID, in.Out)]
+		}
+		if !ok {
+			return 0, nil, reject(RejectMissingInputs, fmt.Errorf("output %s is missing or spent", outpoint(in.ID, in.Out)))
+		}
+		if inputValue, ok = units.Add(inputValue, out.Value); !ok {
+			return 0, nil, reject(RejectValue, errors.New("values of the outputs spent by the transaction overflow"))
+		}
+	}
+
+	// verify transaction signatures against previous transactions
+	valid, err := bc.VerifyTransaction(tx)
+	if err != nil {
+		return 0, nil, reject(RejectMissingInputs, err)
+	} else if !valid {
+		return 0, nil, reject(RejectSignature, errors.New("transaction has an invalid signature"))
+	}
+
+	// new transactions must use canonical signatures, which blocks only
+	// allow to be in the earlier encoding for existing chains
+	if err := tx.checkSignatureEncoding(bc.scheme); err != nil {
+		return 0, nil, reject(RejectSignatureEncoding, err)
+	}
+
+	// ensure the outputs do not spend more than the inputs
+	fee := inputValue - outputValue
+	if fee < 0 {
+		return 0, nil, reject(RejectFee, fmt.Errorf("transaction outputs of %s exceed its inputs of %s", units.FormatAmount(outputValue), units.FormatAmount(inputValue)))
+	}
+	if err := bc.checkFeeFilter(fee, len(tx.Serialize())); err != nil {
+		return 0, nil, reject(RejectFeeFilter, err)
+	}
+	if err := bc.checkTokens(tx); err != nil {
+		return 0, nil, reject(RejectToken, err)
+	}
+
+	// a transaction spending outputs pending transactions already spend
+	// replaces them only if it pays more
+	replaced, err := bc.findReplaced(tx, fee, pending)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// let embedders and plugins apply their own policy
+	if err := bc.lifecycle.checkPolicies(tx, fee); err != nil {
+		return 0, nil, reject(RejectPolicy, errors.New("transaction rejected by policy - "+err.Error()))
+	}
+	if err := hooks.Run(hooks.TxAccept, newTxEvent(tx, fee)); err != nil {
+		return 0, nil, reject(RejectPolicy, errors.New("transaction rejected by plugin - "+err.Error()))
+	}
+
+	return fee, replaced, nil
+}
+
+// MempoolTransactions returns all pending transactions, ordered so that a
+// transaction always comes after the pending transactions it spends from.
+func (bc *BlockChain) MempoolTransactions() []*Transaction {
+	var txs []*Transaction
+
+	// initiate read only transaction on db to iterate over pending transactions
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(mempoolPrefix); it.ValidForPrefix(mempoolPrefix); it.Next() {
+			encodedTx, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				// return from closure with error
+				return errors.New("unable to get value from mempool item - " + err.Error())
+			}
+			tx := DeserializeTransaction(encodedTx)
+			txs = append(txs, &tx)
+		}
+
+		// return from closure
+		return nil
+	})
+	if err != nil {
+		bc.panicf("Unable to read mempool from database: %s", err.Error())
+	}
+
+	// return transactions with parents first
+	return orderByDependency(txs)
+}
+
+// MinePending mines a new block containing the transactions in the mempool
+// that fit in a block, leaving the rest pending. The block's coinbase
+// transaction rewards the miner address with the block subsidy plus the
+// fees of the transactions. ErrChainFrozen is returned if the chain is
+// frozen.
+func (bc *BlockChain) MinePending(minerAddress string) (*Block, error) {
+
+	// mining can't be cancelled without a deadline or cancel func
+	return bc.MinePendingContext(context.Background(), minerAddress)
+}
+
+// MinePendingContext mines a block like MinePending, returning
+// ErrMiningCancelled and leaving the mempool unchanged if ctx is done
+// before the block is mined.
+func (bc *BlockChain) MinePendingContext(ctx context.Context, minerAddress string) (*Block, error) {
+	if !keys.ValidateAddress(minerAddress) {
+		return nil, fmt.Errorf("miner address %s is not valid", minerAddress)
+	}
+
+	// add block, which also removes the mined transactions from the mempool
+	return bc.AddBlockContext(ctx, bc.pendingBlockTransactions(minerAddress))
+}
+
+// pendingBlockTransactions returns the transactions of a block holding the
+// transactions in the mempool that fit in it, after a coinbase transaction rewarding
+// minerAddress with the block subsidy plus their fees. Transactions of the
+// priority keys of the chain are fitted first.
+func (bc *BlockChain) pendingBlockTransactions(minerAddress string) []*Transaction {
+	return bc.assembleBlock(minerAddress).transactions
+}
+
+// blockAssembly is the block assembled from the mempool by assembleBlock.
+type blockAssembly struct {
+	// transactions are those of the block, starting with the coinbase
+	transactions []*Transaction
+
+	// pending are the transactions in the mempool in the order they were
+	// fitted, and fees their fees by id
+	pending []*Transaction
+	fees    map[string]units.Amount
+
+	// priority holds the ids of the pending transactions that were moved
+	// to the front for the priority keys of the chain
+	priority map[string]bool
+}
+
+// assembleBlock fits the transactions in the mempool in a block like
+// pendingBlockTransactions, returning the fees and order used.
+func (bc *BlockChain) assembleBlock(minerAddress string) blockAssembly {
+	pending := bc.MempoolTransactions()
+	fees := make(map[string]units.Amount)
+	var allFees units.Amount
+
+	// verify every pending transaction and find their fees
+	for _, tx := range pending {
+		valid, err := bc.VerifyTransaction(tx)
+		if err != nil || !valid {
+			bc.panicf("Unable to mine block: pending transaction %x is invalid", tx.ID)
+		}
+		fee, err := bc.TransactionFee(tx)
+		if err != nil {
+			bc.panicf("Unable to mine block: %s", err.Error())
+		}
+		fees[string(tx.ID)] = fee
+		allFees += fee
+	}
+
+	// leave the transactions that don't fit in the block in the mempool,
+	// after those of the priority keys. The coinbase is no larger than one
+	// collecting every fee.
+	priority := markPriority(pending, bc.priorityKeys)
+	pending = prioritize(pending, bc.priorityKeys)
+	fitted := fitBlock(pending, len(CoinbaseTx(minerAddress, "", allFees).Serialize()), MaxBlockSize)
+	var blockFees units.Amount
+	for _, tx := range fitted {
+		blockFees += fees[string(tx.ID)]
+	}
+
+	// create coinbase transaction as the first transaction in the block
+	return blockAssembly{
+		transactions: append([]*Transaction{CoinbaseTx(minerAddress, "", blockFees)}, fitted...),
+		pending:      pending,
+		fees:         fees,
+		priority:     priority,
+	}
+}
+
+// orderByDependency orders transactions so that every transaction comes after
+// any transaction in the slice whose outputs it spends.
+func orderByDependency(txs []*Transaction) []*Transaction {
+	var ordered []*Transaction
+	remaining := make(map[string]*Transaction)
+
+	for _, tx := range txs {
+		remaining[hex.EncodeToString(tx.ID)] = tx
+	}
+
+	// repeatedly take transactions whose parents have all been taken
+	for len(remaining) > 0 {
+		progress := false
+		for _, tx := range txs {
+			txID := hex.EncodeToString(tx.ID)
+			if remaining[txID] == nil {
+				continue
+			}
+
+			ready := true
+			for _, in := range tx.Inputs {
+				if remaining[hex.EncodeToString(in.ID)] != nil {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				ordered = append(ordered, tx)
+				delete(remaining, txID)
+				progress = true
+			}
+		}
+
+		// a cycle can not be resolved, stop ordering
+		if !progress {
+			break
+		}
+	}
+
+	return ordered
+}
@@ -0,0 +1,187 @@
+package blockchain
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestMempoolChainedSpends(t *testing.T) {
+	bc := newTestChain(t)
+
+	// bob spends the output of a pending payment before it is mined
+	tx1 := send(t, bc, alice, bob, 30, 1)
+	if err := bc.AddToMempool(tx1); err != nil {
+		t.Fatal(err)
+	}
+	tx2 := send(t, bc, bob, carol, 20, 2)
+	if err := bc.AddToMempool(tx2); err != nil {
+		t.Fatal(err)
+	}
+
+	// alice spends her pending change
+	tx3 := send(t, bc, alice, carol, 5, 0)
+	if err := bc.AddToMempool(tx3); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bc.MempoolTransactions(); len(got) != 3 || !equalID(got[0], tx1) && !equalID(got[0], tx3) {
+		t.Fatalf("got %d pending transactions, want 3 with parents first", len(got))
+	}
+
+	minePending(t, bc, carol)
+	if got := len(bc.MempoolTransactions()); got != 0 {
+		t.Fatalf("got %d pending transactions after mining, want 0", got)
+	}
+	for _, c := range []struct {
+		name string
+		got  units.Amount
+		want units.Amount
+	}{
+		{"alice", balance(t, bc, alice), genesisAllocation - 30 - 1 - 5},
+		{"bob", balance(t, bc, bob), 30 - 20 - 2},
+		{"carol", balance(t, bc, carol), 20 + 5 + Subsidy + 3},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s has %d, want %d", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestMempoolRejectsUnavailableOutputs(t *testing.T) {
+	bc := newTestChain(t)
+
+	// mine a payment so its input is spent on the chain
+	confirmed := send(t, bc, alice, bob, 10, 0)
+	if err := bc.AddToMempool(confirmed); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, alice)
+
+	// build two payments from the same output before either is pending
+	first := send(t, bc, bob, carol, 5, 0)
+	conflicting := send(t, bc, bob, alice, 5, 0)
+	if err := bc.AddToMempool(first); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := send(t, bc, alice, bob, 1, 0)
+	missing.Inputs[0].ID = []byte("missing")
+	twice := send(t, bc, alice, bob, 1, 0)
+	twice.Inputs = append(twice.Inputs, twice.Inputs[0])
+
+	for _, c := range []struct {
+		name string
+		tx   *Transaction
+		want string
+	}{
+		{"spent on chain", confirmed, "missing or spent"},
+		{"missing transaction", missing, "missing or spent"},
+		{"spent by pending", conflicting, "already spent by pending transaction"},
+		{"spent twice", twice, "spent twice"},
+		{"coinbase", CoinbaseTx(bob.Address().String(), "", 0), "missing or spent"},
+	} {
+		err := bc.AddToMempool(c.tx)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %v, want %q", c.name, err, c.want)
+		}
+	}
+
+	// the rejected transactions don't stop the mempool from being mined
+	minePending(t, bc, alice)
+	if got := balance(t, bc, carol); got != 5 {
+		t.Fatalf("carol has %d, want 5", got)
+	}
+}
+
+func TestOrderByDependency(t *testing.T) {
+	parent := &Transaction{ID: []byte("parent")}
+	child := &Transaction{ID: []byte("child"), Inputs: []TxInput{{ID: parent.ID}}}
+	grandchild := &Transaction{ID: []byte("grandchild"), Inputs: []TxInput{{ID: child.ID}}}
+
+	ordered := orderByDependency([]*Transaction{grandchild, child, parent})
+	if len(ordered) != 3 || ordered[0] != parent || ordered[1] != child || ordered[2] != grandchild {
+		t.Fatalf("got %s, want parent, child, grandchild", ids(ordered))
+	}
+}
+
+// equalID returns whether two transactions have the same id.
+func equalID(a, b *Transaction) bool {
+	return string(a.ID) == string(b.ID)
+}
+
+// ids joins the ids of transactions for messages.
+func ids(txs []*Transaction) string {
+	var s []string
+	for _, tx := range txs {
+		s = append(s, string(tx.ID))
+	}
+	return strings.Join(s, ", ")
+}
+
+func TestTestMempoolAccept(t *testing.T) {
+	bc := newTestChain(t)
+
+	// a valid payment would be accepted but is not added
+	tx := send(t, bc, alice, bob, 10, 1)
+	if result := bc.TestMempoolAccept(tx); !result.Allowed || result.Fee != 1 || result.Size == 0 {
+		t.Fatalf("got %+v, want the payment accepted with its fee", result)
+	}
+	if len(bc.MempoolTransactions()) != 0 {
+		t.Fatal("tested transaction was added to the mempool")
+	}
+
+	// each broken rule is reported
+	badSignature := *tx
+	badSignature.Inputs = append([]TxInput{}, tx.Inputs...)
+	badSignature.Inputs[0].Signature = append([]byte{}, tx.Inputs[0].Signature...)
+	badSignature.Inputs[0].Signature[10] ^= 1
+
+	overspend := send(t, bc, alice, bob, 10, 0)
+	overspend.Outputs[0].Value = genesisAllocation + 1
+	prevTXs, err := bc.prevTransactions(overspend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := overspend.Sign(alice, prevTXs); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := send(t, bc, alice, bob, 10, 0)
+	missing.Inputs[0].Out = 7
+
+	zero := send(t, bc, alice, bob, 10, 0)
+	zero.Outputs[0].Value = 0
+	overflow := send(t, bc, alice, bob, 10, 0)
+	overflow.Outputs[0].Value = math.MaxInt64
+	overflow.Outputs[1].Value = math.MaxInt64
+
+	conflict := send(t, bc, alice, carol, 10, 0)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name string
+		tx   *Transaction
+		rule string
+	}{
+		{"bad signature", &badSignature, RejectSignature},
+		{"overspend", overspend, RejectFee},
+		{"missing input", missing, RejectMissingInputs},
+		{"zero output", zero, RejectValue},
+		{"overflowing outputs", overflow, RejectValue},
+		{"double spend", conflict, RejectConflict},
+	} {
+		result := bc.TestMempoolAccept(c.tx)
+		if result.Allowed || result.Rule != c.rule || result.Reason == "" {
+			t.Errorf("%s: got %+v, want rule %s", c.name, result, c.rule)
+		}
+		err := bc.AddToMempool(c.tx)
+		if rejected, ok := err.(*RejectError); !ok || rejected.Rule != c.rule {
+			t.Errorf("%s: got error %v from AddToMempool, want rule %s", c.name, err, c.rule)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package blockchain
+
+import (
+	"encoding/hex"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// The rules a transaction must pass to enter the mempool, as reported in a
+// RejectError.
+const (
+	// RejectMalformed is an input spending nothing or holding coinbase data
+	RejectMalformed = "malformed"
+
+	// RejectVersion is a version 0 transaction, whose signatures don't
+	// cover its outputs
+	RejectVersion = "version"
+
+	// RejectSize is a transaction over the size or count limits
+	RejectSize = "size"
+
+	// RejectDataOutputs is a data output holding a value or too much data
+	RejectDataOutputs = "data-outputs"
+
+	// RejectValue is a value output that isn't positive, or values that
+	// sum past the most an amount holds
+	RejectValue = "bad-value"
+
+	// RejectDuplicateInput is an output spent twice by the transaction
+	RejectDuplicateInput = "duplicate-input"
+
+	// RejectMissingInputs is an input spending an output that doesn't
+	// exist or is already spent on the chain
+	RejectMissingInputs = "missing-inputs"
+
+	// RejectSignature is an input whose signature doesn't verify
+	RejectSignature = "bad-signature"
+
+	// RejectSignatureEncoding is a signature in a non-canonical encoding
+	RejectSignatureEncoding = "signature-encoding"
+
+	// RejectFee is a transaction whose outputs exceed its inputs
+	RejectFee = "fee-too-low"
+
+	// RejectFeeFilter is a transaction paying less than the minimum relay
+	// fee rate of the node
+	RejectFeeFilter = "min-relay-fee"
+
+	// RejectToken is a transaction moving tokens it doesn't hold
+	RejectToken = "token"
+
+	// RejectConflict is an input spending an output a pending transaction
+	// already spends, without paying more than the pending transactions it
+	// would replace
+	RejectConflict = "mempool-conflict"
+
+	// RejectPolicy is a transaction refused by a policy of OnTxAccept or
+	// a plugin
+	RejectPolicy = "plugin-policy"
+
+	// RejectMempoolFull is a transaction the mempool limits would evict as
+	// soon as it entered
+	RejectMempoolFull = "mempool-full"
+)
+
+// RejectError is returned when a transaction is refused from the mempool,
+// holding the rule it breaks. Its message is that of the underlying error.
+type RejectError struct {
+	Rule string
+	Err  error
+}
+
+// Error returns the message of the underlying error.
+func (e *RejectError) Error() string {
+	return e.Err.Error()
+}
+
+// reject returns a RejectError for a transaction breaking rule.
+func reject(rule string, err error) error {
+	return &RejectError{Rule: rule, Err: err}
+}
+
+// MempoolAcceptResult reports whether a transaction would enter the
+// mempool, and if not, the rule it breaks and why.
+type MempoolAcceptResult struct {
+	TxID    string       `json:"txid"`
+	Allowed bool         `json:"allowed"`
+	Rule    string       `json:"rule,omitempty"`
+	Reason  string       `json:"reason,omitempty"`
+	Fee     units.Amount `json:"fee"`
+	Size    int          `json:"size"`
+
+	// Replaces holds the ids of the pending transactions it would replace
+	Replaces []string `json:"replaces,omitempty"`
+}
+
+// TestMempoolAccept runs a Transaction through every check of AddToMempool
+// without adding it, so the reason a wallet's transaction is refused can be
+// found without broadcasting it.
+func (bc *BlockChain) TestMempoolAccept(tx *Transaction) MempoolAcceptResult {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	result := MempoolAcceptResult{TxID: hex.EncodeToString(tx.ID), Size: len(tx.Serialize())}
+	fee, replaced, err := bc.checkMempoolTransaction(tx)
+	if err == nil {
+		_, err = bc.mempoolEvictions(tx, fee, replaced)
+	}
+	if err != nil {
+		result.Reason = err.Error()
+		if rejected, ok := err.(*RejectError); ok {
+			result.Rule = rejected.Rule
+		}
+		return result
+	}
+	result.Allowed = true
+	result.Fee = fee
+	for _, p := range replaced {
+		result.Replaces = append(result.Replaces, hex.EncodeToString(p.ID))
+	}
+	return result
+}
@@ -0,0 +1,418 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// mempoolTimePrefix is the key prefix of the time each pending transaction
+// entered the mempool, followed by its id. The time is unix seconds encoded
+// with ToBytes.
+var mempoolTimePrefix = []byte("mempooltime-")
+
+// mempoolTimeKey returns the db key of the entry time of a pending
+// transaction.
+func mempoolTimeKey(ID []byte) []byte {
+	return append(append([]byte{}, mempoolTimePrefix...), ID...)
+}
+
+// The eviction policies of a full mempool.
+const (
+	// EvictLowestFeeRate evicts the pending transaction paying the lowest
+	// fee per byte first.
+	EvictLowestFeeRate = "feerate"
+
+	// EvictOldest evicts the pending transaction that entered the mempool
+	// first.
+	EvictOldest = "oldest"
+)
+
+// MempoolLimits bound the mempool of a chain, so a long-running node
+// doesn't grow without bound. A limit of 0 is no limit.
+type MempoolLimits struct {
+
+	// MaxCount is the most transactions the mempool holds, and MaxBytes
+	// the most bytes they take serialized. A transaction taking the
+	// mempool over either evicts pending transactions by the Evict policy,
+	// along with the pending transactions spending them, and is refused if
+	// it would be evicted itself.
+	MaxCount int
+	MaxBytes int
+
+	// MaxAge is how long a transaction may stay pending. Older ones are
+	// removed, along with the pending transactions spending them, by
+	// ExpireMempool.
+	MaxAge time.Duration
+
+	// Evict is EvictLowestFeeRate or EvictOldest, EvictLowestFeeRate if
+	// empty.
+	Evict string
+
+	// MinFeeRate is the lowest fee in base units per byte a transaction
+	// must pay to enter the mempool.
+	MinFeeRate float64
+}
+
+// validate verifies that the limits are not negative and the eviction
+// policy is known.
+func (l MempoolLimits) validate() error {
+	if l.MaxCount < 0 || l.MaxBytes < 0 || l.MaxAge < 0 || l.MinFeeRate < 0 {
+		return errors.New("mempool limits must not be negative")
+	}
+	switch l.Evict {
+	case "", EvictLowestFeeRate, EvictOldest:
+		return nil
+	}
+	return fmt.Errorf("unknown mempool eviction policy %q", l.Evict)
+}
+
+// policy returns the eviction policy of the limits.
+func (l MempoolLimits) policy() string {
+	if l.Evict == "" {
+		return EvictLowestFeeRate
+	}
+	return l.Evict
+}
+
+// mempoolStats counts the transactions removed from the mempool by its
+// limits since the chain was opened.
+type mempoolStats struct {
+	mutex   sync.Mutex
+	evicted int
+	expired int
+}
+
+// MempoolInfo describes the mempool of a chain and its limits.
+type MempoolInfo struct {
+	Transactions int          `json:"transactions"`
+	Bytes        int          `json:"bytes"`
+	Fees         units.Amount `json:"fees"`
+
+	// MinFeeRate is the lowest fee per byte paid by a pending transaction,
+	// and Oldest the unix time the oldest one entered the mempool, or 0
+	// when it is empty
+	MinFeeRate float64 `json:"minFeeRate"`
+	Oldest     int64   `json:"oldest,omitempty"`
+
+	// FeeFilter is the lowest fee rate the mempool takes, as returned by
+	// FeeFilter
+	FeeFilter float64 `json:"feeFilter"`
+
+	MaxCount int    `json:"maxCount,omitempty"`
+	MaxBytes int    `json:"maxBytes,omitempty"`
+	MaxAge   string `json:"maxAge,omitempty"`
+	Evict    string `json:"evict"`
+
+	// Evicted and Expired count the transactions removed by the limits
+	// since the chain was opened
+	Evicted int `json:"evicted"`
+	Expired int `json:"expired"`
+}
+
+// MempoolInfo returns the size, fees and limits of the mempool, and how
+// many transactions the limits removed.
+func (bc *BlockChain) MempoolInfo() MempoolInfo {
+	limits := bc.mempoolLimits
+	info := MempoolInfo{MaxCount: limits.MaxCount, MaxBytes: limits.MaxBytes, Evict: limits.policy()}
+	if limits.MaxAge > 0 {
+		info.MaxAge = limits.MaxAge.String()
+	}
+
+	pending := bc.MempoolTransactions()
+	times := bc.mempoolTimes()
+	rated := false
+	for _, tx := range pending {
+		size := len(tx.Serialize())
+		info.Transactions++
+		info.Bytes += size
+		if fee, err := bc.TransactionFee(tx); err == nil {
+			info.Fees += fee
+			if rate := float64(fee) / float64(size); !rated || rate < info.MinFeeRate {
+				info.MinFeeRate = rate
+				rated = true
+			}
+		}
+		if t, ok := times[string(tx.ID)]; ok && (info.Oldest == 0 || t < info.Oldest) {
+			info.Oldest = t
+		}
+	}
+
+	// a full mempool takes only transactions paying more than its lowest
+	// fee rate
+	info.FeeFilter = limits.MinFeeRate
+	full := (limits.MaxCount > 0 && info.Transactions >= limits.MaxCount) || (limits.MaxBytes > 0 && info.Bytes >= limits.MaxBytes)
+	if full && info.MinFeeRate > info.FeeFilter {
+		info.FeeFilter = info.MinFeeRate
+	}
+
+	bc.mempoolStats.mutex.Lock()
+	info.Evicted = bc.mempoolStats.evicted
+	info.Expired = bc.mempoolStats.expired
+	bc.mempoolStats.mutex.Unlock()
+	return info
+}
+
+// mempoolEvictions returns the pending transactions to evict for tx to
+// enter the mempool in place of the replaced ones within its count and
+// size limits, or a RejectError if tx would be evicted itself. Without a
+// tx, it returns those to evict for the mempool to be within its limits.
+// The caller holds bc.mutex.
+func (bc *BlockChain) mempoolEvictions(tx *Transaction, fee units.Amount, replaced []*Transaction) ([]*Transaction, error) {
+	limits := bc.mempoolLimits
+	if limits.MaxCount == 0 && limits.MaxBytes == 0 {
+		return nil, nil
+	}
+
+	// the mempool as it would be with tx, which entered it last
+	leaving := make(map[string]bool)
+	for _, p := range replaced {
+		leaving[string(p.ID)] = true
+	}
+	var pending []*Transaction
+	for _, p := range bc.MempoolTransactions() {
+		if !leaving[string(p.ID)] {
+			pending = append(pending, p)
+		}
+	}
+	if tx != nil {
+		pending = append(pending, tx)
+	}
+	sizes := make(map[string]int)
+	count, bytes := len(pending), 0
+	for _, p := range pending {
+		sizes[string(p.ID)] = len(p.Serialize())
+		bytes += sizes[string(p.ID)]
+	}
+	full := func() bool {
+		return (limits.MaxCount > 0 && count > limits.MaxCount) || (limits.MaxBytes > 0 && bytes > limits.MaxBytes)
+	}
+	if !full() {
+		return nil, nil
+	}
+
+	// rank the transactions by the policy, the first to evict first
+	times := bc.mempoolTimes()
+	if tx != nil {
+		times[string(tx.ID)] = bc.now().Unix()
+	}
+	rates := make(map[string]float64)
+	for _, p := range pending {
+		pFee := fee
+		if p != tx {
+			var err error
+			if pFee, err = bc.TransactionFee(p); err != nil {
+				pFee = 0
+			}
+		}
+		rates[string(p.ID)] = float64(pFee) / float64(sizes[string(p.ID)])
+	}
+	ranked := append([]*Transaction{}, pending...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := string(ranked[i].ID), string(ranked[j].ID)
+		if limits.policy() == EvictLowestFeeRate && rates[a] != rates[b] {
+			return rates[a] < rates[b]
+		}
+		return times[a] < times[b]
+	})
+
+	// evict until within the limits, taking the descendants of each
+	// transaction evicted with it
+	evicted := make(map[string]bool)
+	for _, victim := range ranked {
+		if !full() {
+			break
+		}
+		if evicted[string(victim.ID)] {
+			continue
+		}
+		for _, p := range withDescendants(pending, victim) {
+			if !evicted[string(p.ID)] {
+				evicted[string(p.ID)] = true
+				count--
+				bytes -= sizes[string(p.ID)]
+			}
+		}
+	}
+	if tx != nil && evicted[string(tx.ID)] {
+		return nil, reject(RejectMempoolFull, fmt.Errorf("mempool is full and transaction %x pays too little to stay in it", tx.ID))
+	}
+
+	var victims []*Transaction
+	for _, p := range pending {
+		if evicted[string(p.ID)] {
+			victims = append(victims, p)
+		}
+	}
+	return victims, nil
+}
+
+// trimMempool evicts pending transactions until the mempool is within its
+// count and size limits, such as after a reorg returned the transactions of
+// disconnected blocks to it. The caller holds bc.mutex.
+func (bc *BlockChain) trimMempool() {
+	evicted, _ := bc.mempoolEvictions(nil, 0, nil)
+	if len(evicted) == 0 {
+		return
+	}
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		for _, tx := range evicted {
+			if err := deleteFromMempool(txn, tx.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		bc.panicf("Unable to evict transactions from mempool: %s", err.Error())
+	}
+	bc.mempoolEvicted(evicted)
+}
+
+// mempoolEvicted uncounts transactions evicted from the mempool from the
+// fee histogram and counts them in the mempool stats.
+func (bc *BlockChain) mempoolEvicted(evicted []*Transaction) {
+	bc.feeHistogram.remove(evicted)
+	bc.log.Info("Evicted transactions from full mempool", "transactions", len(evicted))
+	bc.mempoolStats.mutex.Lock()
+	bc.mempoolStats.evicted += len(evicted)
+	bc.mempoolStats.mutex.Unlock()
+}
+
+// withDescendants returns tx and the transactions of pending, which are
+// ordered with parents first, that spend its outputs directly or through
+// other transactions of pending.
+func withDescendants(pending []*Transaction, tx *Transaction) []*Transaction {
+	family := map[string]bool{string(tx.ID): true}
+	txs := []*Transaction{tx}
+	for _, p := range pending {
+		if family[string(p.ID)] {
+			continue
+		}
+		for _, in := range p.Inputs {
+			if family[string(in.ID)] {
+				family[string(p.ID)] = true
+				txs = append(txs, p)
+				break
+			}
+		}
+	}
+	return txs
+}
+
+// ExpireMempool removes the pending transactions older than the MaxAge of
+// the mempool limits, along with the pending transactions spending them,
+// returning how many were removed. Pending transactions whose entry time
+// is unknown, such as those added before entry times were kept, are given
+// the current time.
+func (bc *BlockChain) ExpireMempool() (int, error) {
+	if bc.mempoolLimits.MaxAge == 0 {
+		return 0, nil
+	}
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	now := bc.now().Unix()
+	cutoff := bc.now().Add(-bc.mempoolLimits.MaxAge).Unix()
+	pending := bc.MempoolTransactions()
+	times := bc.mempoolTimes()
+
+	// find the expired transactions and their descendants
+	expired := make(map[string]bool)
+	var removed, unknown []*Transaction
+	for _, tx := range pending {
+		t, ok := times[string(tx.ID)]
+		if !ok {
+			unknown = append(unknown, tx)
+			continue
+		}
+		if t >= cutoff || expired[string(tx.ID)] {
+			continue
+		}
+		for _, p := range withDescendants(pending, tx) {
+			if !expired[string(p.ID)] {
+				expired[string(p.ID)] = true
+				removed = append(removed, p)
+			}
+		}
+	}
+
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		for _, tx := range removed {
+			if err := deleteFromMempool(txn, tx.ID); err != nil {
+				return err
+			}
+		}
+		for _, tx := range unknown {
+			if !expired[string(tx.ID)] {
+				if err := txn.Set(mempoolTimeKey(tx.ID), ToBytes(now)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.New("unable to expire mempool - " + err.Error())
+	}
+	bc.feeHistogram.remove(removed)
+	if len(removed) > 0 {
+		bc.log.Info("Expired transactions from mempool", "transactions", len(removed))
+	}
+
+	bc.mempoolStats.mutex.Lock()
+	bc.mempoolStats.expired += len(removed)
+	bc.mempoolStats.mutex.Unlock()
+	return len(removed), nil
+}
+
+// mempoolTimes returns the entry times of the pending transactions by id.
+func (bc *BlockChain) mempoolTimes() map[string]int64 {
+	times := make(map[string]int64)
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(mempoolTimePrefix); it.ValidForPrefix(mempoolTimePrefix); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			times[string(it.Item().Key()[len(mempoolTimePrefix):])] = FromBytes(value)
+		}
+		return nil
+	})
+	if err != nil {
+		bc.panicf("Unable to read mempool entry times from database: %s", err.Error())
+	}
+	return times
+}
+
+// addToMempoolTxn stores a pending transaction entering the mempool at
+// now.
+func addToMempoolTxn(txn *badger.Txn, tx *Transaction, now int64) error {
+	if err := txn.Set(mempoolKey(tx.ID), tx.Serialize()); err != nil {
+		return err
+	}
+	return txn.Set(mempoolTimeKey(tx.ID), ToBytes(now))
+}
+
+// now returns the current time of the clock of the chain.
+func (bc *BlockChain) now() time.Time {
+	if bc.clock == nil {
+		return time.Now()
+	}
+	return bc.clock()
+}
+
+// deleteFromMempool removes a pending transaction and its entry time.
+func deleteFromMempool(txn *badger.Txn, ID []byte) error {
+	if err := txn.Delete(mempoolKey(ID)); err != nil {
+		return err
+	}
+	return txn.Delete(mempoolTimeKey(ID))
+}
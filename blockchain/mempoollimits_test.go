@@ -0,0 +1,145 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// newFundedTestChain opens a test chain with limits where alice, bob and
+// carol each hold confirmed outputs.
+func newFundedTestChain(t *testing.T, limits MempoolLimits) *BlockChain {
+	t.Helper()
+	bc := newTestChainWithConfig(t, Config{Mempool: limits})
+	for _, to := range []*wallet.Wallet{bob, carol} {
+		if err := bc.AddToMempool(send(t, bc, alice, to, 1000, 100)); err != nil {
+			t.Fatal(err)
+		}
+		minePending(t, bc, alice)
+	}
+	return bc
+}
+
+func TestMempoolEviction(t *testing.T) {
+	bc := newFundedTestChain(t, MempoolLimits{MaxCount: 2})
+	low := send(t, bc, alice, bob, 100, 10)
+	high := send(t, bc, bob, carol, 100, 50)
+	for _, tx := range []*Transaction{low, high} {
+		if err := bc.AddToMempool(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a transaction paying less than every pending one is refused
+	lowest := send(t, bc, carol, alice, 100, 5)
+	err := bc.AddToMempool(lowest)
+	if rejected, ok := err.(*RejectError); !ok || rejected.Rule != RejectMempoolFull {
+		t.Fatalf("got error %v, want the transaction refused as the mempool is full", err)
+	}
+	if result := bc.TestMempoolAccept(lowest); result.Allowed || result.Rule != RejectMempoolFull {
+		t.Fatalf("got %+v testing the transaction, want it refused", result)
+	}
+
+	// one paying more evicts the lowest fee rate
+	highest := send(t, bc, carol, alice, 100, 100)
+	if err := bc.AddToMempool(highest); err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range bc.MempoolTransactions() {
+		if string(tx.ID) == string(low.ID) {
+			t.Fatal("the lowest fee rate transaction was not evicted")
+		}
+	}
+	info := bc.MempoolInfo()
+	if info.Transactions != 2 || info.Evicted != 1 || info.Evict != EvictLowestFeeRate {
+		t.Fatalf("got mempool info %+v, want 2 transactions after evicting 1", info)
+	}
+}
+
+func TestMempoolEvictionOldest(t *testing.T) {
+	bc := newFundedTestChain(t, MempoolLimits{MaxCount: 1, Evict: EvictOldest})
+	parent := send(t, bc, alice, bob, 100, 100)
+	if err := bc.AddToMempool(parent); err != nil {
+		t.Fatal(err)
+	}
+
+	// the oldest transaction is evicted however much it pays
+	newest := send(t, bc, bob, carol, 100, 1)
+	if err := bc.AddToMempool(newest); err != nil {
+		t.Fatal(err)
+	}
+	if txs := bc.MempoolTransactions(); len(txs) != 1 || string(txs[0].ID) != string(newest.ID) {
+		t.Fatalf("got %d pending transactions, want only the newest", len(txs))
+	}
+}
+
+func TestMempoolEvictionDescendants(t *testing.T) {
+	bc := newFundedTestChain(t, MempoolLimits{MaxBytes: 1200})
+	parent := send(t, bc, alice, bob, 100, 10)
+	if err := bc.AddToMempool(parent); err != nil {
+		t.Fatal(err)
+	}
+
+	// the child spends the pending output of the parent to bob
+	child, err := bc.fundTransactionWith(bob, []UnspentOutput{{TxID: parent.ID, Out: 0, Output: parent.Outputs[0]}},
+		[]TxOutput{*NewTXOutput(50, carol.Address().String())}, 50, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AddToMempool(child); err != nil {
+		t.Fatal(err)
+	}
+
+	// evicting the parent evicts the child spending it
+	other := send(t, bc, carol, bob, 100, 200)
+	if err := bc.AddToMempool(other); err != nil {
+		t.Fatal(err)
+	}
+	if txs := bc.MempoolTransactions(); len(txs) != 1 || string(txs[0].ID) != string(other.ID) {
+		t.Fatalf("got %d pending transactions, want the parent and child evicted", len(txs))
+	}
+	if info := bc.MempoolInfo(); info.Evicted != 2 || info.Bytes > 1200 {
+		t.Fatalf("got mempool info %+v, want 2 evicted within 1200 bytes", info)
+	}
+}
+
+func TestExpireMempool(t *testing.T) {
+	bc := newFundedTestChain(t, MempoolLimits{MaxAge: time.Hour})
+	old := send(t, bc, alice, bob, 100, 10)
+	fresh := send(t, bc, bob, carol, 100, 10)
+	for _, tx := range []*Transaction{old, fresh} {
+		if err := bc.AddToMempool(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// backdate one transaction past the age limit
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(mempoolTimeKey(old.ID), ToBytes(time.Now().Add(-2*time.Hour).Unix()))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := bc.MempoolInfo(); info.Oldest > time.Now().Add(-time.Hour).Unix() {
+		t.Fatalf("got oldest entry time %d, want the backdated one", info.Oldest)
+	}
+
+	if n, err := bc.ExpireMempool(); err != nil || n != 1 {
+		t.Fatalf("expired %d transactions with error %v, want 1", n, err)
+	}
+	if txs := bc.MempoolTransactions(); len(txs) != 1 || string(txs[0].ID) != string(fresh.ID) {
+		t.Fatalf("got %d pending transactions, want only the fresh one", len(txs))
+	}
+	if info := bc.MempoolInfo(); info.Expired != 1 || info.MaxAge != "1h0m0s" {
+		t.Fatalf("got mempool info %+v, want 1 expired", info)
+	}
+}
+
+func TestMempoolLimitsValidate(t *testing.T) {
+	_, err := Open(Config{Path: t.TempDir(), Genesis: testGenesis(), Mempool: MempoolLimits{Evict: "random"}})
+	if err == nil {
+		t.Fatal("opened a chain with an unknown eviction policy")
+	}
+}
@@ -0,0 +1,129 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// MerkleProof is the branch of the Merkle tree of a block's transaction ids
+// needed to show that a transaction is in the block without the rest of
+// its transactions.
+type MerkleProof struct {
+	BlockHash []byte
+
+	// Index is the position of the transaction in the block, which gives
+	// the side each branch hash is on.
+	Index int
+
+	// Branch holds the sibling hashes from the transaction up to the root.
+	Branch [][]byte
+}
+
+// merkleParent hashes two sibling nodes of a Merkle tree.
+func merkleParent(left, right []byte) []byte {
+	hash := sha256.Sum256(append(append([]byte{}, left...), right...))
+	return hash[:]
+}
+
+// merkleLevels returns every level of the Merkle tree of hashes from the
+// leaves up to the root. A level with an odd number of nodes pairs its last
+// node with itself.
+func merkleLevels(hashes [][]byte) [][][]byte {
+	levels := [][][]byte{hashes}
+	for level := hashes; len(level) > 1; {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, merkleParent(level[i], right))
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels
+}
+
+// merkleRoot returns the root of the Merkle tree of the ids of the
+// transactions of a block. The root is part of the proof of work, so a
+// proof against it shows a transaction is in a block with a valid header.
+// A block without transactions has the hash of no data as its root.
+func merkleRoot(txs []*Transaction) []byte {
+	var txIDs [][]byte
+	for _, tx := range txs {
+		txIDs = append(txIDs, tx.ID)
+	}
+	if len(txIDs) == 0 {
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	}
+	levels := merkleLevels(txIDs)
+	return levels[len(levels)-1][0]
+}
+
+// GetMerkleProof returns a proof that a transaction is in a block of the
+// best chain.
+func (bc *BlockChain) GetMerkleProof(txID Hash) (*MerkleProof, error) {
+	iter := bc.NewIterator()
+
+	// iterate over blocks
+	for {
+		block := iter.Next()
+
+		// build the branch if the transaction is in the block
+		for i, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, txID[:]) {
+				return merkleProof(block, i), nil
+			}
+		}
+
+		// break once PrevHash is empty (Genesis block has been reached)
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("transaction %s is not in the chain", txID)
+}
+
+// merkleProof returns the proof that the transaction at index is in block.
+func merkleProof(block *Block, index int) *MerkleProof {
+	var txIDs [][]byte
+	for _, tx := range block.Transactions {
+		txIDs = append(txIDs, tx.ID)
+	}
+
+	// collect the sibling of the node on the path at each level
+	proof := &MerkleProof{BlockHash: block.Hash, Index: index}
+	levels := merkleLevels(txIDs)
+	for _, level := range levels[:len(levels)-1] {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index
+		}
+		proof.Branch = append(proof.Branch, level[sibling])
+		index /= 2
+	}
+	return proof
+}
+
+// VerifyMerkleProof returns whether proof shows that the transaction with
+// id txID is in the block with the Merkle root root.
+func VerifyMerkleProof(root []byte, proof *MerkleProof, txID []byte) bool {
+	hash := txID
+	index := proof.Index
+
+	// hash up the tree, with the branch hash on the side given by the index
+	for _, sibling := range proof.Branch {
+		if index%2 == 0 {
+			hash = merkleParent(hash, sibling)
+		} else {
+			hash = merkleParent(sibling, hash)
+		}
+		index /= 2
+	}
+
+	return index == 0 && bytes.Equal(hash, root)
+}
@@ -0,0 +1,125 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// leafTxs returns n distinct transactions with 32 byte ids.
+func leafTxs(n int) []*Transaction {
+	var txs []*Transaction
+	for i := 0; i < n; i++ {
+		id := sha256.Sum256([]byte(fmt.Sprint(i)))
+		txs = append(txs, &Transaction{ID: id[:]})
+	}
+	return txs
+}
+
+func TestMerkleRoot(t *testing.T) {
+	txs := leafTxs(3)
+	a, b, c := txs[0].ID, txs[1].ID, txs[2].ID
+	empty := sha256.Sum256(nil)
+
+	for _, test := range []struct {
+		name string
+		txs  []*Transaction
+		want []byte
+	}{
+		{"no transactions", nil, empty[:]},
+		{"one", txs[:1], a},
+		{"two", txs[:2], merkleParent(a, b)},
+		{"three pairs the last with itself", txs, merkleParent(merkleParent(a, b), merkleParent(c, c))},
+	} {
+		block := &Block{Transactions: test.txs}
+		if got := merkleRoot(test.txs); !bytes.Equal(got, test.want) {
+			t.Errorf("%s: got root %x, want %x", test.name, got, test.want)
+		}
+		if got := block.HashTransactions(); !bytes.Equal(got, test.want) {
+			t.Errorf("%s: proof of work commits to %x, want the root %x", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMerkleProofs(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 5, 6, 7, 8, 9} {
+		t.Run(fmt.Sprint(n, " transactions"), func(t *testing.T) {
+			bc := newTestChain(t)
+			txs := leafTxs(n)
+			block := &Block{BlockHeader: BlockHeader{Hash: []byte("block")}, Transactions: txs}
+			root := merkleRoot(txs)
+
+			for index, tx := range txs {
+				proof := merkleProof(block, index)
+				if !VerifyMerkleProof(root, proof, tx.ID) {
+					t.Fatalf("proof of transaction %d does not verify", index)
+				}
+
+				// the proof fails for another transaction, index, root or
+				// branch
+				if n == 1 {
+					continue
+				}
+				other := txs[(index+1)%n].ID
+				if VerifyMerkleProof(root, proof, other) {
+					t.Fatalf("proof of transaction %d verifies another transaction", index)
+				}
+				moved := *proof
+				moved.Index = index + 1<<uint(len(proof.Branch))
+				if VerifyMerkleProof(root, &moved, tx.ID) {
+					t.Fatalf("proof of transaction %d verifies past the tree", index)
+				}
+				if VerifyMerkleProof(bc.Tip(), proof, tx.ID) {
+					t.Fatal("proof verifies against another root")
+				}
+				tampered := *proof
+				tampered.Branch = append([][]byte{}, proof.Branch...)
+				tampered.Branch[len(tampered.Branch)-1] = leafTxs(n + 1)[n].ID
+				if VerifyMerkleProof(root, &tampered, tx.ID) {
+					t.Fatalf("tampered proof of transaction %d verifies", index)
+				}
+			}
+		})
+	}
+}
+
+func TestGetMerkleProof(t *testing.T) {
+	bc := newTestChain(t)
+	var sent []*Transaction
+	for i := 0; i < 4; i++ {
+		tx := send(t, bc, alice, bob, units.Amount(10+i), 0)
+		if err := bc.AddToMempool(tx); err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, tx)
+	}
+	mined := minePending(t, bc, carol)
+
+	for _, tx := range append(sent, mined.Transactions[0]) {
+		proof, err := bc.GetMerkleProof(hashOf(t, tx.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := bc.GetBlock(proof.BlockHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(block.Hash, mined.Hash) || !VerifyMerkleProof(block.HashTransactions(), proof, tx.ID) {
+			t.Fatalf("proof of %x does not verify against the mined block", tx.ID)
+		}
+	}
+	if _, err := bc.GetMerkleProof(Hash{}); err == nil {
+		t.Fatal("got a proof for an unknown transaction")
+	}
+
+	// changing a transaction changes the data the proof of work hashes
+	before := NewProof(mined).InitData(mined.Nonce)
+	mined.Transactions[1].Outputs[0].Value++
+	mined.Transactions[1].ID = mined.Transactions[1].GenerateHash()
+	if bytes.Equal(NewProof(mined).InitData(mined.Nonce), before) {
+		t.Fatal("proof of work does not commit to the transactions")
+	}
+}
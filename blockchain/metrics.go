@@ -0,0 +1,155 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+// metricsPrefix is the key prefix for the metrics of connected blocks.
+var metricsPrefix = []byte("metrics-")
+
+// metricsKey returns the db key for the metrics of a block.
+func metricsKey(hash []byte) []byte {
+	return append(append([]byte{}, metricsPrefix...), hash...)
+}
+
+// BlockMetrics are recorded for every block connected to the best chain,
+// and are kept when the block is pruned or disconnected so the performance
+// of the node can be looked at historically. Validation is the time spent
+// verifying the transactions of the block and connecting it to the UTXO
+// set, and Connected is the unix time it was connected at.
+type BlockMetrics struct {
+	Hash         []byte
+	Height       int
+	Size         int
+	Transactions int
+	Validation   time.Duration
+	Connected    int64
+}
+
+// recordMetrics stores the metrics of a block connected in txn after
+// validation time was spent on it. Metrics of a block that is connected
+// again are replaced.
+func recordMetrics(txn *badger.Txn, block *Block, size int, validation time.Duration) error {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(BlockMetrics{
+		Hash:         block.Hash,
+		Height:       block.Height,
+		Size:         size,
+		Transactions: len(block.Transactions),
+		Validation:   validation,
+		Connected:    time.Now().Unix(),
+	})
+	if err != nil {
+		return errors.New("unable to encode block metrics - " + err.Error())
+	}
+	return txn.Set(metricsKey(block.Hash), buffer.Bytes())
+}
+
+// BlockMetrics returns the recorded metrics of every block, ordered by
+// height and then by the time they were connected.
+func (bc *BlockChain) BlockMetrics() ([]BlockMetrics, error) {
+	var metrics []BlockMetrics
+
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(metricsPrefix); it.ValidForPrefix(metricsPrefix); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var m BlockMetrics
+			if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&m); err != nil {
+				return errors.New("unable to decode block metrics - " + err.Error())
+			}
+			metrics = append(metrics, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].Height != metrics[j].Height {
+			return metrics[i].Height < metrics[j].Height
+		}
+		return metrics[i].Connected < metrics[j].Connected
+	})
+	return metrics, nil
+}
+
+// Percentiles summarizes a set of values.
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// newPercentiles returns the nearest rank percentiles of values, sorting
+// them.
+func newPercentiles(values []float64) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sort.Float64s(values)
+	rank := func(p float64) float64 {
+		return values[int(math.Ceil(p/100*float64(len(values))))-1]
+	}
+	return Percentiles{P50: rank(50), P90: rank(90), P99: rank(99), Max: values[len(values)-1]}
+}
+
+// PerfStats summarizes the metrics of the blocks between two heights.
+// Validation percentiles are in nanoseconds and Size percentiles in bytes.
+type PerfStats struct {
+	FromHeight   int         `json:"fromHeight"`
+	ToHeight     int         `json:"toHeight"`
+	Blocks       int         `json:"blocks"`
+	Validation   Percentiles `json:"validation"`
+	Size         Percentiles `json:"size"`
+	Transactions Percentiles `json:"transactions"`
+}
+
+// SummarizeMetrics summarizes metrics ordered by height, as returned by
+// BlockMetrics, in windows of window blocks by height, or in a single
+// summary if window is 0, so changes in performance over the history of
+// the chain stand out.
+func SummarizeMetrics(metrics []BlockMetrics, window int) []PerfStats {
+	var stats []PerfStats
+
+	for start := 0; start < len(metrics); {
+
+		// collect the metrics in the window of the first block
+		end := start + 1
+		for end < len(metrics) && (window == 0 || metrics[end].Height/window == metrics[start].Height/window) {
+			end++
+		}
+
+		var validation, size, transactions []float64
+		for _, m := range metrics[start:end] {
+			validation = append(validation, float64(m.Validation))
+			size = append(size, float64(m.Size))
+			transactions = append(transactions, float64(m.Transactions))
+		}
+		stats = append(stats, PerfStats{
+			FromHeight:   metrics[start].Height,
+			ToHeight:     metrics[end-1].Height,
+			Blocks:       end - start,
+			Validation:   newPercentiles(validation),
+			Size:         newPercentiles(size),
+			Transactions: newPercentiles(transactions),
+		})
+		start = end
+	}
+
+	return stats
+}
@@ -0,0 +1,120 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBlockMetricsAreRecorded(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+
+	// mined, accepted and reorganized blocks all record metrics
+	if err := bc.AddToMempool(send(t, bc, alice, bob, 10, 1)); err != nil {
+		t.Fatal(err)
+	}
+	mined := minePending(t, bc, alice)
+	side1 := mineOn(t, bc, genesis, carol)
+	side2 := mineOn(t, bc, side1, carol)
+	for _, block := range []*Block{side1, side2} {
+		if err := bc.AcceptBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	metrics, err := bc.BlockMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 3 {
+		t.Fatalf("got metrics for %d blocks, want 3", len(metrics))
+	}
+
+	// both blocks at height 1 are kept, in the order they were connected
+	// unless they were connected in the same second
+	want := map[string]int{string(mined.Hash): 2, string(side1.Hash): 1, string(side2.Hash): 1}
+	for _, m := range metrics {
+		transactions, ok := want[string(m.Hash)]
+		if !ok {
+			t.Fatalf("got metrics for unknown block %x", m.Hash)
+		}
+		delete(want, string(m.Hash))
+		if m.Transactions != transactions || m.Size <= 0 || m.Validation <= 0 || m.Connected == 0 {
+			t.Errorf("got metrics %+v, want %d transactions", m, transactions)
+		}
+	}
+	if !bytes.Equal(metrics[2].Hash, side2.Hash) || metrics[2].Height != 2 {
+		t.Errorf("got metrics for block %x last, want the tip", metrics[2].Hash)
+	}
+}
+
+func TestSummarizeMetrics(t *testing.T) {
+	var metrics []BlockMetrics
+	for height := 1; height <= 20; height++ {
+		metrics = append(metrics, BlockMetrics{
+			Height:       height,
+			Size:         height * 100,
+			Transactions: height % 3,
+			Validation:   time.Duration(height) * time.Millisecond,
+		})
+	}
+
+	for _, c := range []struct {
+		name   string
+		window int
+		want   []PerfStats
+	}{
+		{"all", 0, []PerfStats{{
+			FromHeight:   1,
+			ToHeight:     20,
+			Blocks:       20,
+			Validation:   Percentiles{10e6, 18e6, 20e6, 20e6},
+			Size:         Percentiles{1000, 1800, 2000, 2000},
+			Transactions: Percentiles{1, 2, 2, 2},
+		}}},
+		{"windows", 10, []PerfStats{
+			{
+				FromHeight:   1,
+				ToHeight:     9,
+				Blocks:       9,
+				Validation:   Percentiles{5e6, 9e6, 9e6, 9e6},
+				Size:         Percentiles{500, 900, 900, 900},
+				Transactions: Percentiles{1, 2, 2, 2},
+			},
+			{
+				FromHeight:   10,
+				ToHeight:     19,
+				Blocks:       10,
+				Validation:   Percentiles{14e6, 18e6, 19e6, 19e6},
+				Size:         Percentiles{1400, 1800, 1900, 1900},
+				Transactions: Percentiles{1, 2, 2, 2},
+			},
+			{
+				FromHeight:   20,
+				ToHeight:     20,
+				Blocks:       1,
+				Validation:   Percentiles{20e6, 20e6, 20e6, 20e6},
+				Size:         Percentiles{2000, 2000, 2000, 2000},
+				Transactions: Percentiles{2, 2, 2, 2},
+			},
+		}},
+		{"empty", 10, nil},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			input := metrics
+			if c.want == nil {
+				input = nil
+			}
+			got := SummarizeMetrics(input, c.window)
+			if len(got) != len(c.want) {
+				t.Fatalf("got %d summaries, want %d", len(got), len(c.want))
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Errorf("summary %d is %+v, want %+v", i, got[i], c.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+var (
+	// schemaVersionKey holds the version of the format of the data stored
+	// in the database, the number of migrations it went through.
+	schemaVersionKey = []byte("schemaversion")
+
+	// migrationRunningKey holds the version a migration is upgrading the
+	// database to while it runs, so one interrupted by a crash is found
+	// when the database is opened again.
+	migrationRunningKey = []byte("migrationrunning")
+
+	// migrationUndoPrefix is the key prefix of the values the running
+	// migration replaced, which are restored if it fails. The value is a
+	// byte that is 1 if the key was set, followed by the value it held.
+	migrationUndoPrefix = []byte("migrationundo-")
+)
+
+// ErrNewerSchema is returned by Open when the database was written by a
+// later version whose format this version can't read.
+var ErrNewerSchema = errors.New("database was written by a newer version of gochain")
+
+// MigrationProgress reports the progress of the migration upgrading the
+// database to version, such as the number of blocks rewritten so far.
+type MigrationProgress func(version int, name string, progress JobProgress)
+
+// migration upgrades the data stored in the database from the version
+// before it. Its changes must be written with migrationSet and
+// migrationDelete so they are rolled back if it fails.
+type migration struct {
+	name string
+	run  func(bc *BlockChain, progress func(JobProgress)) error
+}
+
+// migrations upgrade the format of the data stored in the database, the
+// one at index i upgrading it to version i+1. Open runs the ones a
+// database has not been through, so chains created by earlier versions
+// keep working after a format change. The indexes derived from the blocks
+// are not migrated here: they are rebuilt whenever they don't match the
+// tip of the chain.
+var migrations = []migration{
+	{"rewrite gob blocks as protobuf", func(bc *BlockChain, progress func(JobProgress)) error {
+		_, err := bc.migrateBlocks(migrationSet, progress)
+		return err
+	}},
+}
+
+// SchemaVersion is the version of the format of the data stored in the
+// database by this version of gochain.
+var SchemaVersion = len(migrations)
+
+// migrationUndoKey returns the db key of the value key held before the
+// running migration replaced it.
+func migrationUndoKey(key []byte) []byte {
+	return append(append([]byte{}, migrationUndoPrefix...), key...)
+}
+
+// migrationSet sets key to value in txn for a migration, saving the value
+// it held so it can be rolled back.
+func migrationSet(txn *badger.Txn, key, value []byte) error {
+	if err := saveMigrationUndo(txn, key); err != nil {
+		return err
+	}
+	return txn.Set(key, value)
+}
+
+// migrationDelete deletes key in txn for a migration, saving the value it
+// held so it can be rolled back.
+func migrationDelete(txn *badger.Txn, key []byte) error {
+	if err := saveMigrationUndo(txn, key); err != nil {
+		return err
+	}
+	return txn.Delete(key)
+}
+
+// saveMigrationUndo saves the value key holds before the running migration
+// first changes it.
+func saveMigrationUndo(txn *badger.Txn, key []byte) error {
+	undoKey := migrationUndoKey(key)
+	if _, err := txn.Get(undoKey); err == nil {
+		return nil
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+
+	undo := []byte{0}
+	item, err := txn.Get(key)
+	if err == nil {
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		undo = append([]byte{1}, value...)
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+	return txn.Set(undoKey, undo)
+}
+
+// schemaVersion returns the version of the format of the database, which
+// is 0 for databases created before it was stored, and the version a
+// migration was running to, or 0 if none was.
+func (bc *BlockChain) schemaVersion() (version, running int, err error) {
+	err = bc.DB.View(func(txn *badger.Txn) error {
+		for _, v := range []struct {
+			key   []byte
+			value *int
+		}{{schemaVersionKey, &version}, {migrationRunningKey, &running}} {
+			item, err := txn.Get(v.key)
+			if err == badger.ErrKeyNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			*v.value = int(FromBytes(value))
+		}
+		return nil
+	})
+	return version, running, err
+}
+
+// setSchemaVersion records that the database is in the format of version.
+func setSchemaVersion(txn *badger.Txn, version int) error {
+	return txn.Set(schemaVersionKey, ToBytes(int64(version)))
+}
+
+// migrate runs the migrations the database has not been through, reporting
+// their progress to cfg. A migration that fails is rolled back, leaving
+// the database in the format it had before it, and so is one a crash
+// interrupted before it runs again.
+func (bc *BlockChain) migrate(cfg Config) error {
+	logger := cfg.logger()
+	version, running, err := bc.schemaVersion()
+	if err != nil {
+		return errors.New("unable to read schema version - " + err.Error())
+	}
+	if version > SchemaVersion {
+		return ErrNewerSchema
+	}
+
+	// finish with a migration a crash interrupted: keep its changes if it
+	// completed, or undo them so it runs again
+	if running > version {
+		logger.Info("Rolling back interrupted migration", "version", running)
+		if err := bc.endMigration(true); err != nil {
+			return errors.New("unable to roll back interrupted migration - " + err.Error())
+		}
+	} else if running > 0 {
+		if err := bc.endMigration(false); err != nil {
+			return errors.New("unable to finish migration - " + err.Error())
+		}
+	}
+
+	for ; version < SchemaVersion; version++ {
+		to, m := version+1, migrations[version]
+		logger.Info("Migrating database", "version", to, "migration", m.name)
+		err := bc.DB.Update(func(txn *badger.Txn) error {
+			return txn.Set(migrationRunningKey, ToBytes(int64(to)))
+		})
+		if err != nil {
+			return errors.New("unable to start migration - " + err.Error())
+		}
+
+		// run the migration, undoing what it changed if it fails
+		progress := func(p JobProgress) {
+			if cfg.MigrationProgress != nil {
+				cfg.MigrationProgress(to, m.name, p)
+			}
+		}
+		if err := m.run(bc, progress); err != nil {
+			if rollbackErr := bc.endMigration(true); rollbackErr != nil {
+				return fmt.Errorf("unable to migrate database to version %d - %s, and unable to roll back - %s", to, err.Error(), rollbackErr.Error())
+			}
+			return fmt.Errorf("unable to migrate database to version %d, rolled back - %s", to, err.Error())
+		}
+
+		// record the new version, then forget the values the migration
+		// replaced
+		err = bc.DB.Update(func(txn *badger.Txn) error {
+			return setSchemaVersion(txn, to)
+		})
+		if err != nil {
+			return errors.New("unable to set schema version - " + err.Error())
+		}
+		if err := bc.endMigration(false); err != nil {
+			return errors.New("unable to finish migration - " + err.Error())
+		}
+		logger.Info("Migrated database", "version", to)
+	}
+	return nil
+}
+
+// endMigration removes the values the running migration replaced,
+// restoring them first if rollback is set, and then the running key.
+// Removing them takes as many db transactions as needed, so an interrupted
+// call is continued by calling it again.
+func (bc *BlockChain) endMigration(rollback bool) error {
+	const batch = 1000
+	for {
+		var keys, undos [][]byte
+
+		// collect a batch of the replaced values
+		err := bc.DB.View(func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			defer it.Close()
+			for it.Seek(migrationUndoPrefix); it.ValidForPrefix(migrationUndoPrefix) && len(keys) < batch; it.Next() {
+				undo, err := it.Item().ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				keys = append(keys, bytes.TrimPrefix(it.Item().KeyCopy(nil), migrationUndoPrefix))
+				undos = append(undos, undo)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			break
+		}
+
+		// restore them if rolling back, and remove them
+		err = bc.DB.Update(func(txn *badger.Txn) error {
+			for i, key := range keys {
+				if rollback {
+					var err error
+					if len(undos[i]) > 0 && undos[i][0] == 1 {
+						err = txn.Set(key, undos[i][1:])
+					} else {
+						err = txn.Delete(key)
+					}
+					if err != nil {
+						return err
+					}
+				}
+				if err := txn.Delete(migrationUndoKey(key)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(migrationRunningKey)
+	})
+}
+
+// checkSchema returns ErrNeedsWrite unless the database of a read-only
+// chain is in the format of this version, or ErrNewerSchema if it is in a
+// later one.
+func (bc *BlockChain) checkSchema() error {
+	version, running, err := bc.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return ErrNewerSchema
+	}
+	if version < SchemaVersion || running > 0 {
+		return ErrNeedsWrite
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/logging"
+)
+
+func TestMigrations(t *testing.T) {
+	cfg := Config{Path: t.TempDir(), Genesis: testGenesis(), Logger: logging.Discard}
+	bc, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, running, err := bc.schemaVersion(); err != nil || version != SchemaVersion || running != 0 {
+		t.Fatalf("new chain has schema version %d running %d, %v, want %d", version, running, err, SchemaVersion)
+	}
+	genesis := tip(t, bc)
+	minePending(t, bc, alice)
+
+	// a database written before schema versions, with a gob block, is
+	// migrated when opened
+	var legacy bytes.Buffer
+	if err := gob.NewEncoder(&legacy).Encode(legacyBlockOf(genesis)); err != nil {
+		t.Fatal(err)
+	}
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(genesis.Hash, blockRecord(legacy.Bytes())); err != nil {
+			return err
+		}
+		return txn.Delete(schemaVersionKey)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.Close()
+
+	cfg.ReadOnly = true
+	if _, err := Open(cfg); err != ErrNeedsWrite {
+		t.Fatalf("read-only open of an old database returned %v, want ErrNeedsWrite", err)
+	}
+	cfg.ReadOnly = false
+	var reported []JobProgress
+	cfg.MigrationProgress = func(version int, name string, progress JobProgress) {
+		reported = append(reported, progress)
+	}
+	if bc, err = Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if version, _, err := bc.schemaVersion(); err != nil || version != SchemaVersion {
+		t.Fatalf("migrated chain has schema version %d, %v, want %d", version, err, SchemaVersion)
+	}
+	if record := rawValue(t, bc, genesis.Hash); legacyBlockFormat(recordData(record)) {
+		t.Fatal("genesis block is still stored with gob")
+	}
+	if len(reported) != 1 || reported[0].Done != 1 || reported[0].Total != 1 {
+		t.Fatalf("got progress %+v, want one block of one", reported)
+	}
+	bc.Close()
+
+	// a migration that fails is rolled back and the version is kept
+	saved := migrations
+	defer func() { migrations, SchemaVersion = saved, len(saved) }()
+	migrations = append(append([]migration{}, saved...), migration{"failing", func(bc *BlockChain, progress func(JobProgress)) error {
+		err := bc.DB.Update(func(txn *badger.Txn) error {
+			if err := migrationSet(txn, genesis.Hash, []byte("replaced")); err != nil {
+				return err
+			}
+			if err := migrationDelete(txn, []byte("lh")); err != nil {
+				return err
+			}
+			return migrationSet(txn, []byte("added"), []byte("new"))
+		})
+		if err != nil {
+			return err
+		}
+		return errors.New("failed")
+	}})
+	SchemaVersion = len(migrations)
+	if _, err := Open(cfg); err == nil {
+		t.Fatal("opened chain with a failing migration")
+	}
+	bc = openRaw(t, cfg)
+	if version, running, err := bc.schemaVersion(); err != nil || version != len(saved) || running != 0 {
+		t.Fatalf("got schema version %d running %d, %v after a failed migration, want %d", version, running, err, len(saved))
+	}
+	checkRolledBack(t, bc, genesis)
+
+	// a migration a crash interrupted is rolled back when the database is
+	// opened again
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(migrationRunningKey, ToBytes(int64(len(saved)+1))); err != nil {
+			return err
+		}
+		if err := migrationSet(txn, genesis.Hash, []byte("replaced")); err != nil {
+			return err
+		}
+		return migrationDelete(txn, []byte("lh"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.Close()
+	migrations, SchemaVersion = saved, len(saved)
+	if bc, err = Open(cfg); err != nil {
+		t.Fatalf("unable to open chain after an interrupted migration: %s", err)
+	}
+	checkRolledBack(t, bc, genesis)
+
+	// a database from a later version is refused
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		return setSchemaVersion(txn, SchemaVersion+1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.Close()
+	if _, err := Open(cfg); err != ErrNewerSchema {
+		t.Fatalf("open of a newer database returned %v, want ErrNewerSchema", err)
+	}
+}
+
+// openRaw opens the database of cfg without opening the chain in it.
+func openRaw(t *testing.T, cfg Config) *BlockChain {
+	db, err := openDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &BlockChain{DB: db}
+}
+
+// rawValue returns the value stored in the database under key, or nil if
+// there is none.
+func rawValue(t *testing.T, bc *BlockChain, key []byte) []byte {
+	var value []byte
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+// checkRolledBack fails the test unless the changes of the test migrations
+// were rolled back.
+func checkRolledBack(t *testing.T, bc *BlockChain, genesis *Block) {
+	t.Helper()
+	if block, err := decodeBlockRecord(genesis.Hash, rawValue(t, bc, genesis.Hash)); err != nil || !bytes.Equal(block.Hash, genesis.Hash) {
+		t.Fatalf("genesis block was not restored: %v", err)
+	}
+	if rawValue(t, bc, []byte("lh")) == nil {
+		t.Fatal("deleted tip key was not restored")
+	}
+	if value := rawValue(t, bc, []byte("added")); value != nil {
+		t.Fatalf("added key holds %q after the rollback", value)
+	}
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		if it.Seek(migrationUndoPrefix); it.ValidForPrefix(migrationUndoPrefix) {
+			return errors.New("undo records were left")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,131 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// MinerReport summarizes the blocks an address was rewarded for mining.
+// Blocks and the rewards are counted over the best chain, split into the
+// block subsidy and the fees of the transactions in the blocks. Orphaned
+// counts the blocks paying the address that are not in the best chain, and
+// Pruned the blocks of the best chain whose transactions were pruned, which
+// can't be attributed to a miner.
+type MinerReport struct {
+	Blocks   int
+	Subsidy  units.Amount
+	Fees     units.Amount
+	Orphaned int
+	Pruned   int
+}
+
+// OrphanRate returns the fraction of the blocks mined by the address that
+// are not in the best chain.
+func (r MinerReport) OrphanRate() float64 {
+	if r.Blocks+r.Orphaned == 0 {
+		return 0
+	}
+	return float64(r.Orphaned) / float64(r.Blocks+r.Orphaned)
+}
+
+// MinerReport summarizes the blocks whose coinbase pays pubKeyHash, using
+// the height index to walk the best chain and the undo records of its
+// blocks to find their fees.
+func (bc *BlockChain) MinerReport(pubKeyHash []byte) (MinerReport, error) {
+	var report MinerReport
+
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		tip, err := getBlock(txn, bc.Tip())
+		if err != nil {
+			return err
+		}
+
+		// the genesis block is not mined, so start above it
+		best := make(map[string]bool)
+		for height := 1; height <= tip.Height; height++ {
+			item, err := txn.Get(heightKey(height))
+			if err != nil {
+				return fmt.Errorf("no block at height %d - %s", height, err.Error())
+			}
+			hash, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			best[string(hash)] = true
+			block, err := getBlock(txn, hash)
+			if err != nil {
+				return err
+			}
+			if block.Pruned() {
+				report.Pruned++
+				continue
+			}
+			reward := coinbaseReward(block, pubKeyHash)
+			if reward == 0 {
+				continue
+			}
+
+			// the fees are what the spent outputs held beyond the outputs
+			// of the transactions spending them
+			spent, err := getUndo(txn, block.Hash)
+			if err != nil {
+				return err
+			}
+			var fees units.Amount
+			for _, s := range spent {
+				fees += s.Output.Value
+			}
+			for _, tx := range block.Transactions[1:] {
+				for _, out := range tx.Outputs {
+					fees -= out.Value
+				}
+			}
+			if fees > reward {
+				fees = reward
+			}
+
+			report.Blocks++
+			report.Fees += fees
+			report.Subsidy += reward - fees
+		}
+
+		// every stored block has its chain work recorded, so blocks off the
+		// best chain are found from the work keys
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(workPrefix); it.ValidForPrefix(workPrefix); it.Next() {
+			hash := bytes.TrimPrefix(it.Item().Key(), workPrefix)
+			if best[string(hash)] {
+				continue
+			}
+			block, err := getBlock(txn, hash)
+			if err != nil {
+				return err
+			}
+			if block.Height > 0 && !block.Pruned() && coinbaseReward(block, pubKeyHash) > 0 {
+				report.Orphaned++
+			}
+		}
+		return nil
+	})
+
+	return report, err
+}
+
+// coinbaseReward returns the value the coinbase of a block pays to
+// pubKeyHash.
+func coinbaseReward(block *Block, pubKeyHash []byte) units.Amount {
+	if len(block.Transactions) == 0 || !block.Transactions[0].IsCoinbase() {
+		return 0
+	}
+	var reward units.Amount
+	for _, out := range block.Transactions[0].Outputs {
+		if out.IsLockedWithKey(pubKeyHash) {
+			reward += out.Value
+		}
+	}
+	return reward
+}
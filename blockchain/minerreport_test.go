@@ -0,0 +1,42 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestMinerReport(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+
+	// carol mines a block collecting a fee and an empty block, and a block
+	// that loses to them
+	fee := 2 * units.Coin
+	tx := send(t, bc, alice, bob, units.Coin, fee)
+	first := mineBlock(t, bc, genesis, []*Transaction{CoinbaseTx(carol.Address().String(), "", fee), tx})
+	for _, block := range []*Block{first, mineOn(t, bc, first, carol), mineOn(t, bc, genesis, carol)} {
+		if err := bc.AcceptBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := bc.MinerReport(wallet.GeneratePublicKeyHash(carol.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MinerReport{Blocks: 2, Subsidy: 2 * Subsidy, Fees: fee, Orphaned: 1}
+	if report != want {
+		t.Fatalf("got %+v, want %+v", report, want)
+	}
+	if rate := report.OrphanRate(); rate < 0.33 || rate > 0.34 {
+		t.Fatalf("got orphan rate %f, want 1/3", rate)
+	}
+
+	// an address that mined nothing has an empty report
+	report, err = bc.MinerReport(wallet.GeneratePublicKeyHash(bob.PublicKey))
+	if err != nil || report != (MinerReport{}) || report.OrphanRate() != 0 {
+		t.Fatalf("got %+v, %v, want an empty report", report, err)
+	}
+}
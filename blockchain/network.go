@@ -0,0 +1,103 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/edwintcloud/gochain/addresses"
+	"github.com/edwintcloud/gochain/keys"
+)
+
+// NetworkParams are the parameters that set a network apart. Chains
+// created without a genesis configuration get their genesis difficulty and
+// difficulty rules from the network, and nodes of different networks keep
+// their data apart and listen on different ports.
+type NetworkParams struct {
+	Name string
+
+	// AddressVersion is the version byte of the addresses of the network.
+	AddressVersion byte
+
+	// GenesisDifficulty is the difficulty of a genesis block paying its
+	// reward to an address, and Rules limit the difficulty of the blocks
+	// after it.
+	GenesisDifficulty int
+	Rules             DifficultyRules
+
+	// Scheme is the signature scheme transactions are signed with. It is
+	// P-256 if nil.
+	Scheme keys.Scheme
+
+	// PowHash is the hash of the proof of work of blocks. It is SHA256 if
+	// nil.
+	PowHash PowHash
+
+	// PathSuffix is appended to the paths of the database and wallets
+	// file, so networks don't share them.
+	PathSuffix string
+
+	// Port is the port nodes of the network listen on by default.
+	Port string
+}
+
+var (
+	// MainNetParams are the parameters of the main network.
+	MainNetParams = NetworkParams{
+		Name:              "main",
+		AddressVersion:    addresses.MainNet,
+		GenesisDifficulty: Difficulty,
+		Rules:             DefaultDifficultyRules,
+		Port:              "8546",
+	}
+
+	// TestNetParams are the parameters of the public test network, which
+	// is easier to mine and doesn't stall when miners leave.
+	TestNetParams = NetworkParams{
+		Name:              "test",
+		AddressVersion:    addresses.TestNet,
+		GenesisDifficulty: 12,
+		Rules:             DifficultyRules{Min: 1, Max: 255, TargetSpacing: 60, AllowMinDifficulty: true},
+		PathSuffix:        "-testnet",
+		Port:              "18546",
+	}
+
+	// RegTestParams are the parameters of regression test networks, whose
+	// blocks are all mined at the lowest difficulty so integration tests
+	// and local development get blocks instantly.
+	RegTestParams = NetworkParams{
+		Name:              "regtest",
+		AddressVersion:    addresses.TestNet,
+		GenesisDifficulty: 1,
+		Rules:             DifficultyRules{Min: 1, Max: 1},
+		PathSuffix:        "-regtest",
+		Port:              "18646",
+	}
+)
+
+// scheme returns the signature scheme of the network.
+func (p *NetworkParams) scheme() keys.Scheme {
+	if p.Scheme == nil {
+		return keys.P256
+	}
+	return p.Scheme
+}
+
+// powHash returns the proof of work hash of the network.
+func (p *NetworkParams) powHash() PowHash {
+	if p.PowHash == nil {
+		return SHA256
+	}
+	return p.PowHash
+}
+
+// Networks are the parameters of the known networks.
+var Networks = []*NetworkParams{&MainNetParams, &TestNetParams, &RegTestParams}
+
+// NetworkByName returns the parameters of the network called name.
+func NetworkByName(name string) (*NetworkParams, error) {
+	for _, network := range Networks {
+		if network.Name == name {
+			return network, nil
+		}
+	}
+	return nil, fmt.Errorf("network %q is not main, test or regtest", name)
+}
@@ -0,0 +1,76 @@
+package blockchain
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/edwintcloud/gochain/addresses"
+	"github.com/edwintcloud/gochain/logging"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestRegTestNetwork(t *testing.T) {
+	network, err := NetworkByName("regtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := Open(Config{
+		Path:           t.TempDir(),
+		GenesisAddress: alice.Address().String(),
+		Network:        network,
+		Logger:         logging.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	// blocks are mined at the lowest difficulty from the genesis block on
+	genesis := tip(t, bc)
+	if genesis.GetDifficulty() != 1 || bc.NextDifficulty(genesis, genesis.Timestamp) != 1 {
+		t.Fatalf("got difficulty %d then %d, want 1", genesis.GetDifficulty(), bc.NextDifficulty(genesis, genesis.Timestamp))
+	}
+	if name := (Config{Network: network}).NetworkName(); name != "regtest" {
+		t.Fatalf("got network %s, want regtest", name)
+	}
+
+	if _, err := NetworkByName("moon"); err == nil {
+		t.Fatal("unknown network was found")
+	}
+}
+
+func TestGenesisAddressPrefix(t *testing.T) {
+	version, err := addresses.VersionForPrefix("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vanity := addresses.Encode(version, wallet.GeneratePublicKeyHash(alice.PublicKey))
+	write := func(json string) string {
+		path := filepath.Join(t.TempDir(), "genesis.json")
+		if err := ioutil.WriteFile(path, []byte(json), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// allocations must use the prefix, which sets the address version
+	genesis, err := LoadGenesis(write(`{"network":"n","timestamp":1,"addressPrefix":"g","allocations":{"` + vanity + `":10}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := (Config{Genesis: genesis}).AddressVersion(); err != nil || got != version {
+		t.Fatalf("got version %d and error %v, want %d", got, err, version)
+	}
+	if got, _ := (Config{Network: &TestNetParams}).AddressVersion(); got != addresses.TestNet {
+		t.Fatalf("got version %d without a prefix, want that of the network", got)
+	}
+	for _, json := range []string{
+		`{"network":"n","timestamp":1,"addressPrefix":"g","allocations":{"` + alice.Address().String() + `":10}}`,
+		`{"network":"n","timestamp":1,"addressPrefix":"0"}`,
+	} {
+		if _, err := LoadGenesis(write(json)); err == nil {
+			t.Errorf("loaded %s", json)
+		}
+	}
+}
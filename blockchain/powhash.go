@@ -0,0 +1,156 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
+)
+
+// PowHash is the hash function of the proof of work of a network. Its id
+// is committed to in the version of every block after genesis, so a block
+// can't claim to be mined with a cheaper hash than its network uses.
+type PowHash interface {
+	// ID is the id of the hash in block versions, 0 for SHA-256
+	ID() int
+
+	// Name is the name of the hash in genesis configurations
+	Name() string
+
+	// Sum returns the 32 byte hash of proof of work data
+	Sum(data []byte) []byte
+}
+
+// powHashShift is the bit the id of the proof of work hash starts at in a
+// block version, above the version of the block format.
+const powHashShift = 8
+
+var (
+	// SHA256 is the proof of work hash of the built-in networks, and of
+	// blocks mined before the hash could be chosen.
+	SHA256 PowHash = powHash{0, "sha256", func(data []byte) []byte {
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}}
+
+	// DoubleSHA256 hashes the data with SHA-256 twice, like Bitcoin.
+	DoubleSHA256 PowHash = powHash{1, "sha256d", func(data []byte) []byte {
+		first := sha256.Sum256(data)
+		sum := sha256.Sum256(first[:])
+		return sum[:]
+	}}
+
+	// SHA3 hashes the data with SHA3-256.
+	SHA3 PowHash = powHash{2, "sha3", func(data []byte) []byte {
+		sum := sha3.Sum256(data)
+		return sum[:]
+	}}
+
+	// Scrypt hashes the data with scrypt at N=1024, r=1, p=1, salted with
+	// itself, like Litecoin, so each hash needs 128 KiB of memory.
+	Scrypt PowHash = powHash{3, "scrypt", func(data []byte) []byte {
+		sum, err := scrypt.Key(data, data, 1024, 1, 1, 32)
+		if err != nil {
+			log.Panicf("Unable to hash proof of work with scrypt: %s", err.Error())
+		}
+		return sum
+	}}
+
+	// Argon2id hashes the data with a single pass of Argon2id over 1 MiB
+	// of memory, salted with itself, which makes mining on ASICs and GPUs
+	// gain less over CPUs.
+	Argon2id PowHash = powHash{4, "argon2id", func(data []byte) []byte {
+		return argon2.IDKey(data, data, 1, 1024, 1, 32)
+	}}
+)
+
+// PowHashes are the proof of work hashes networks can choose from.
+var PowHashes = []PowHash{SHA256, DoubleSHA256, SHA3, Scrypt, Argon2id}
+
+// powHash is a PowHash of a hash function.
+type powHash struct {
+	id   int
+	name string
+	sum  func(data []byte) []byte
+}
+
+// ID returns the id of the hash in block versions.
+func (h powHash) ID() int {
+	return h.id
+}
+
+// Name returns the name of the hash.
+func (h powHash) Name() string {
+	return h.name
+}
+
+// Sum returns the hash of data.
+func (h powHash) Sum(data []byte) []byte {
+	return h.sum(data)
+}
+
+// PowHashByName returns the proof of work hash called name, SHA256 if name
+// is empty.
+func PowHashByName(name string) (PowHash, error) {
+	if name == "" {
+		return SHA256, nil
+	}
+	for _, h := range PowHashes {
+		if h.Name() == name {
+			return h, nil
+		}
+	}
+	return nil, fmt.Errorf("proof of work hash %q is not sha256, sha256d, sha3, scrypt or argon2id", name)
+}
+
+// powHashByID returns the proof of work hash with id, or false if there is
+// none.
+func powHashByID(id int) (PowHash, bool) {
+	for _, h := range PowHashes {
+		if h.ID() == id {
+			return h, true
+		}
+	}
+	return nil, false
+}
+
+// blockVersion returns the version of the blocks mined with hash, which
+// holds its id above BlockVersion. Blocks mined with SHA256 keep the
+// version they had before the hash could be chosen.
+func blockVersion(hash PowHash) int {
+	return BlockVersion | hash.ID()<<powHashShift
+}
+
+// FormatVersion returns the version of the block format of the header,
+// without the id of its proof of work hash.
+func (h *BlockHeader) FormatVersion() int {
+	return h.Version & (1<<powHashShift - 1)
+}
+
+// PowHash returns the proof of work hash the header commits to in its
+// version, or false if its id is unknown.
+func (h *BlockHeader) PowHash() (PowHash, bool) {
+	if h.Version < 0 {
+		return nil, false
+	}
+	return powHashByID(h.Version >> powHashShift)
+}
+
+// knownVersion returns whether the header is of a known format version and
+// proof of work hash.
+func (h *BlockHeader) knownVersion() bool {
+	_, ok := h.PowHash()
+	return ok && h.FormatVersion() <= BlockVersion
+}
+
+// checkVersion returns an error if the block is of an unknown format
+// version or proof of work hash.
+func (h *BlockHeader) checkVersion() error {
+	if !h.knownVersion() {
+		return fmt.Errorf("block %x has unsupported version %d", h.Hash, h.Version)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPowHashes(t *testing.T) {
+	for _, hash := range PowHashes {
+		t.Run(hash.Name(), func(t *testing.T) {
+			bc, err := InitInMemory(&Genesis{Network: "pow-" + hash.Name(), PowHash: hash.Name()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer bc.Close()
+
+			// blocks commit to the hash of the network in their version
+			block, err := bc.MinePending(alice.Address().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := block.PowHash(); !ok || got.Name() != hash.Name() || block.FormatVersion() != BlockVersion {
+				t.Fatalf("got version %d, want the %s hash", block.Version, hash.Name())
+			}
+			if !bytes.Equal(NewProof(block).Sum(block.Nonce), block.Hash) {
+				t.Fatal("block hash is not its proof of work hash")
+			}
+
+			// blocks mined with another hash are refused
+			other := SHA3
+			if hash.ID() == SHA3.ID() {
+				other = SHA256
+			}
+			foreign, err := createBlock(context.Background(), []*Transaction{CoinbaseTx(bob.Address().String(), "", 0)}, block.Hash, block.Height+1, 1, time.Now().Unix(), miningOptions{hash: other})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := bc.AcceptBlock(foreign); err == nil || !strings.Contains(err.Error(), "proof of work hash") {
+				t.Fatalf("got %v, want a block mined with %s refused", err, other.Name())
+			}
+		})
+	}
+
+	if _, err := PowHashByName("md5"); err == nil {
+		t.Fatal("found an unknown proof of work hash")
+	}
+}
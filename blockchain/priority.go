@@ -0,0 +1,56 @@
+package blockchain
+
+import "github.com/edwintcloud/gochain/keys"
+
+// prioritize moves the transactions of txs spending from one of the public
+// key hashes in pubKeyHashes to the front, along with the transactions of
+// txs they spend, so that they are placed in a block before any other. txs
+// must be ordered so that transactions come after the transactions they
+// spend, and the order is otherwise kept.
+func prioritize(txs []*Transaction, pubKeyHashes [][]byte) []*Transaction {
+	if len(pubKeyHashes) == 0 {
+		return txs
+	}
+	marked := markPriority(txs, pubKeyHashes)
+
+	var first, rest []*Transaction
+	for _, tx := range txs {
+		if marked[string(tx.ID)] {
+			first = append(first, tx)
+		} else {
+			rest = append(rest, tx)
+		}
+	}
+	return append(first, rest...)
+}
+
+// markPriority returns the ids of the transactions of txs that prioritize
+// moves to the front, which spend from one of pubKeyHashes or are spent by
+// one that does.
+func markPriority(txs []*Transaction, pubKeyHashes [][]byte) map[string]bool {
+	priorityKeys := make(map[string]bool)
+	for _, key := range pubKeyHashes {
+		priorityKeys[string(key)] = true
+	}
+
+	// mark the transactions spending from the key hashes, then the
+	// transactions they spend, walking back from the last transaction
+	marked := make(map[string]bool)
+	for i := len(txs) - 1; i >= 0; i-- {
+		tx := txs[i]
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, in := range tx.Inputs {
+			if priorityKeys[string(keys.PublicKeyHash(in.PubKey))] {
+				marked[string(tx.ID)] = true
+			}
+		}
+		if marked[string(tx.ID)] {
+			for _, in := range tx.Inputs {
+				marked[string(in.ID)] = true
+			}
+		}
+	}
+	return marked
+}
@@ -0,0 +1,34 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestPrioritize(t *testing.T) {
+	spending := func(id string, from *wallet.Wallet, parent []byte) *Transaction {
+		return &Transaction{
+			ID:      []byte(id),
+			Inputs:  []TxInput{{ID: parent, Out: 0, PubKey: from.PublicKey}},
+			Outputs: []TxOutput{*NewTXOutput(1, bob.Address().String())},
+		}
+	}
+	carols := spending("carol", carol, []byte("confirmed"))
+	bobs := spending("bob", bob, []byte("confirmed"))
+	alices := spending("alice", alice, bobs.ID)
+
+	// alice's transaction and the transaction of bob it spends go first
+	got := prioritize([]*Transaction{carols, bobs, alices}, [][]byte{wallet.GeneratePublicKeyHash(alice.PublicKey)})
+	want := []*Transaction{bobs, alices, carols}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("transaction %d is %s, want %s", i, got[i].ID, want[i].ID)
+		}
+	}
+
+	// without keys the order is kept
+	if got := prioritize([]*Transaction{carols, bobs}, nil); got[0] != carols || got[1] != bobs {
+		t.Fatal("order changed without priority keys")
+	}
+}
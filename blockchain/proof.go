@@ -3,35 +3,60 @@ package blockchain
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding"
 	"encoding/binary"
-	"fmt"
 	"log"
 	"math"
 	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/edwintcloud/gochain/consensus"
 )
 
-// Difficulty is the mining difficulty.
-const Difficulty = 18
+// Difficulty is the default mining difficulty.
+const Difficulty = consensus.DefaultDifficulty
 
-// ProofOfWork represents a proof of work.
+// ProofOfWork represents a proof of work. Hash is the proof of work hash
+// the version of the block commits to, nil if it is unknown.
 type ProofOfWork struct {
 	Block  *Block
 	Target *big.Int
+	Hash   PowHash
+
+	// Progress, if set, is called about once a second while mining
+	Progress MiningProgress
+
+	// Midstate, if set, makes each worker save the SHA-256 state after the
+	// whole blocks of data before the nonce and resume from it for every
+	// nonce, so that data is hashed once instead of once per nonce. It only
+	// applies to blocks mined with SHA256.
+	Midstate bool
 }
 
+// MiningProgress reports the progress of a proof of work with the number of
+// hashes tried and the time spent so far. done is set on the last report,
+// which is made once a nonce has been found.
+type MiningProgress func(hashes uint64, elapsed time.Duration, done bool)
+
 // NewProof creates a new proof of work and returns a
 // reference to the new proof of work.
 func NewProof(b *Block) *ProofOfWork {
 
-	// cast 1 to big int
-	target := big.NewInt(1)
-
-	// left shift bytes in target by 256 - difficulty
-	// target << 256 - Difficulty
-	target.Lsh(target, uint(256-Difficulty))
+	// the hash must be below 2 to the power of 256 - difficulty
+	hash, _ := b.PowHash()
+	return &ProofOfWork{Block: b, Target: consensus.Target(b.GetDifficulty()), Hash: hash}
+}
 
-	// return new proof of work
-	return &ProofOfWork{b, target}
+// Sum returns the proof of work hash of the data with nonce, or nil if the
+// hash is unknown.
+func (pow *ProofOfWork) Sum(nonce int) []byte {
+	if pow.Hash == nil {
+		return nil
+	}
+	return pow.Hash.Sum(pow.InitData(nonce))
 }
 
 // InitData initializes a proof of work with provided
@@ -44,67 +69,218 @@ func (pow *ProofOfWork) InitData(nonce int) []byte {
 			pow.Block.PrevHash,
 			pow.Block.HashTransactions(),
 			ToBytes(int64(nonce)),
-			ToBytes(int64(Difficulty)),
+			ToBytes(int64(pow.Block.GetDifficulty())),
 		}, []byte{})
 
+	// add the timestamp, which is zero and left out for blocks created
+	// before timestamps were recorded so they remain valid
+	if pow.Block.Timestamp != 0 {
+		data = append(data, ToBytes(pow.Block.Timestamp)...)
+	}
+
+	// add the version and height, which version 0 blocks leave out
+	if pow.Block.Version != 0 {
+		data = append(data, ToBytes(int64(pow.Block.Version))...)
+		data = append(data, ToBytes(int64(pow.Block.Height))...)
+	}
+
 	// return byte slice
 	return data
 }
 
-// Run executes a proof of work.
+// hashBatch is how many hashes a mining worker tries between updates of the
+// shared hash count.
+const hashBatch = 1024
+
+// targetBytes returns the target as a big endian number of the length of a
+// hash, so hashes can be compared with it without converting them.
+func (pow *ProofOfWork) targetBytes() []byte {
+	target := make([]byte, sha256.Size)
+	t := pow.Target.Bytes()
+	copy(target[sha256.Size-len(t):], t)
+	return target
+}
+
+// nonceOffset returns the position of the nonce in the data returned by
+// InitData, which follows the previous hash and the transactions hash.
+func (pow *ProofOfWork) nonceOffset() int {
+	return len(pow.Block.PrevHash) + len(pow.Block.HashTransactions())
+}
+
+// setNonce writes nonce into data returned by InitData in place, giving the
+// same bytes as InitData(nonce) without allocating.
+func setNonce(data []byte, offset int, nonce int64) {
+	binary.BigEndian.PutUint64(data[offset:offset+8], uint64(nonce))
+}
+
+// nonceHasher returns a function hashing data, the proof of work data from
+// InitData, with a nonce written at offset. The returned hash is only valid
+// until the next call. data is reused between calls, so each mining worker
+// needs its own.
+func (pow *ProofOfWork) nonceHasher(data []byte, offset int) func(nonce int64) []byte {
+	var sum [sha256.Size]byte
+
+	// other hashes have no midstate to resume from
+	if pow.Hash.ID() != SHA256.ID() {
+		return func(nonce int64) []byte {
+			setNonce(data, offset, nonce)
+			return pow.Hash.Sum(data)
+		}
+	}
+
+	// whole blocks before the nonce are the same for every nonce
+	skip := offset / sha256.BlockSize * sha256.BlockSize
+	if !pow.Midstate || skip == 0 {
+		return func(nonce int64) []byte {
+			setNonce(data, offset, nonce)
+			sum = sha256.Sum256(data)
+			return sum[:]
+		}
+	}
+
+	// save the state after those blocks and resume from it for each nonce
+	h := sha256.New()
+	h.Write(data[:skip])
+	state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		log.Panicf("Unable to save SHA-256 state: %s", err.Error())
+	}
+	resume := h.(encoding.BinaryUnmarshaler)
+	return func(nonce int64) []byte {
+		setNonce(data, offset, nonce)
+		if err := resume.UnmarshalBinary(state); err != nil {
+			log.Panicf("Unable to restore SHA-256 state: %s", err.Error())
+		}
+		h.Write(data[skip:])
+		return h.Sum(sum[:0])
+	}
+}
+
+// Run executes a proof of work on every CPU core and returns the lowest
+// valid nonce and the resulting hash.
 func (pow *ProofOfWork) Run() (int, []byte) {
-	var intHash big.Int
-	var hash [32]byte
-	nonce := 0
+	nonce, hash, _ := pow.RunWithCancel(nil)
+	return nonce, hash
+}
+
+// RunWithCancel executes a proof of work like Run, stopping early when quit
+// is closed. The nonce space is split between GOMAXPROCS workers, and the
+// workers keep going until every nonce below the best one found has been
+// tried, so the result is the same as mining on a single core. The last
+// return value is false if mining was cancelled.
+func (pow *ProofOfWork) RunWithCancel(quit <-chan struct{}) (int, []byte, bool) {
+	var wg sync.WaitGroup
+	var hashes uint64
+	best := int64(math.MaxInt64)
+	workers := int64(runtime.GOMAXPROCS(0))
+	start := time.Now()
+
+	// build the data once and only rewrite the nonce for each hash, so the
+	// hot loop doesn't allocate
+	template := pow.InitData(0)
+	offset := pow.nonceOffset()
+	target := pow.targetBytes()
 
-	for nonce < math.MaxInt64 {
-		// get a byte slice proof of work with nonce
-		data := pow.InitData(nonce)
+	for first := int64(0); first < workers; first++ {
+		wg.Add(1)
+		go func(first int64) {
+			defer wg.Done()
+			data := append([]byte{}, template...)
+			hashNonce := pow.nonceHasher(data, offset)
 
-		// hash the proof of work data
-		hash = sha256.Sum256(data)
+			// count hashes locally and add them to the total in batches so
+			// workers don't contend on it
+			var counted uint64
+			defer func() { atomic.AddUint64(&hashes, counted) }()
 
-		// print current hash
-		fmt.Printf("\r%x", hash)
+			// try every workers-th nonce until a better nonce is found
+			for nonce := first; nonce < atomic.LoadInt64(&best); nonce += workers {
+				select {
+				case <-quit:
+					return
+				default:
+				}
 
-		// convert hash into big int
-		intHash.SetBytes(hash[:])
+				// hash the proof of work data and compare it with the target
+				hash := hashNonce(nonce)
+				if counted++; counted == hashBatch {
+					atomic.AddUint64(&hashes, counted)
+					counted = 0
+				}
+				if bytes.Compare(hash, target) < 0 {
 
-		// compare proof of work target and intHash
-		if intHash.Cmp(pow.Target) == -1 {
-			// block has been signed, break
-			break
-		} else {
-			// increment nonce
-			nonce++
+					// keep the lowest nonce found by any worker
+					for {
+						current := atomic.LoadInt64(&best)
+						if nonce >= current || atomic.CompareAndSwapInt64(&best, current, nonce) {
+							break
+						}
+					}
+					return
+				}
+			}
+		}(first)
+	}
+
+	// report progress every second until the workers finish
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		case <-ticker.C:
+			if pow.Progress != nil {
+				pow.Progress(atomic.LoadUint64(&hashes), time.Since(start), false)
+			}
 		}
 	}
 
-	// print some space
-	fmt.Println()
+	// return if mining was cancelled before a nonce was found
+	nonce := atomic.LoadInt64(&best)
+	if nonce == math.MaxInt64 {
+		return 0, nil, false
+	}
+	if pow.Progress != nil {
+		pow.Progress(hashes, time.Since(start), true)
+	}
 
 	// return nonce and hash
-	return nonce, hash[:]
+	return int(nonce), pow.Sum(int(nonce)), true
 }
 
 // Validate verifies that a completed proof of work is valid.
 func (pow *ProofOfWork) Validate() bool {
 	var intHash big.Int
 
-	// get a byte slice proof of work with nonce
-	data := pow.InitData(pow.Block.Nonce)
-
-	// hash the proof of work data
-	hash := sha256.Sum256(data)
+	// hash the proof of work data with the nonce
+	hash := pow.Sum(pow.Block.Nonce)
+	if hash == nil {
+		return false
+	}
 
 	// convert hash into big int
-	intHash.SetBytes(hash[:])
+	intHash.SetBytes(hash)
 
 	// compare proof of work target and intHash
 	// return true if match
 	return intHash.Cmp(pow.Target) == -1
 }
 
+// GetDifficulty returns the mining difficulty of a block. Blocks created
+// before the difficulty was recorded use the default Difficulty.
+func (b *Block) GetDifficulty() int {
+	if b.Difficulty == 0 {
+		return Difficulty
+	}
+	return b.Difficulty
+}
+
 // ToBytes decodes a int64 into bytes.
 func ToBytes(num int64) []byte {
 
@@ -120,3 +296,17 @@ func ToBytes(num int64) []byte {
 	// return bytes from buffer
 	return buffer.Bytes()
 }
+
+// FromBytes encodes bytes written by ToBytes back into an int64.
+func FromBytes(data []byte) int64 {
+	var num int64
+
+	// encode bytes into num
+	err := binary.Read(bytes.NewReader(data), binary.BigEndian, &num)
+	if err != nil {
+		log.Panicf("Unable to encode %x into int64: %s", data, err.Error())
+	}
+
+	// return decoded num
+	return num
+}
@@ -0,0 +1,134 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRunReportsProgress(t *testing.T) {
+	block := &Block{BlockHeader: BlockHeader{PrevHash: []byte{}, Difficulty: 8}, Transactions: []*Transaction{CoinbaseTx(alice.Address().String(), "", 0)}}
+	pow := NewProof(block)
+
+	var reports []bool
+	var total uint64
+	pow.Progress = func(hashes uint64, elapsed time.Duration, done bool) {
+		reports = append(reports, done)
+		total = hashes
+	}
+	nonce, hash, ok := pow.RunWithCancel(nil)
+	if !ok {
+		t.Fatal("mining was cancelled")
+	}
+
+	// the last report is made once the nonce is found
+	if len(reports) == 0 || !reports[len(reports)-1] || total == 0 {
+		t.Fatalf("got reports %v after %d hashes, want a final done report", reports, total)
+	}
+	block.Nonce, block.Hash = nonce, hash
+	if !NewProof(block).Validate() {
+		t.Fatal("mined block is not valid")
+	}
+}
+
+func TestSetNonceMatchesInitData(t *testing.T) {
+	coinbase := CoinbaseTx(alice.Address().String(), "", 0)
+	for _, c := range []struct {
+		name  string
+		block *Block
+	}{
+		{"genesis without timestamp", &Block{BlockHeader: BlockHeader{PrevHash: []byte{}}, Transactions: []*Transaction{coinbase}}},
+		{"with timestamp", &Block{BlockHeader: BlockHeader{PrevHash: make([]byte, 32), Timestamp: 1234, Difficulty: 12}, Transactions: []*Transaction{coinbase}}},
+	} {
+		pow := NewProof(c.block)
+		data := pow.InitData(0)
+		for _, nonce := range []int64{0, 1, 255, 256, 1 << 40, math.MaxInt64 - 1} {
+			setNonce(data, pow.nonceOffset(), nonce)
+			if want := pow.InitData(int(nonce)); !bytes.Equal(data, want) {
+				t.Errorf("%s: nonce %d gives %x, want %x", c.name, nonce, data, want)
+			}
+		}
+	}
+}
+
+func TestNonceHasher(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		prevHash []byte
+		midstate bool
+	}{
+		{"full", make([]byte, 32), false},
+		{"midstate", make([]byte, 32), true},
+		{"midstate without whole blocks", []byte{}, true},
+		{"midstate with a long prefix", make([]byte, 100), true},
+	} {
+		pow := NewProof(&Block{BlockHeader: BlockHeader{PrevHash: c.prevHash, Timestamp: 1}, Transactions: []*Transaction{CoinbaseTx(alice.Address().String(), "", 0)}})
+		pow.Midstate = c.midstate
+		data, offset := pow.InitData(0), pow.nonceOffset()
+		hashNonce := pow.nonceHasher(data, offset)
+
+		for _, nonce := range []int64{0, 1, 1 << 33} {
+			want := sha256.Sum256(pow.InitData(int(nonce)))
+			if got := hashNonce(nonce); !bytes.Equal(got, want[:]) {
+				t.Errorf("%s: nonce %d hashes to %x, want %x", c.name, nonce, got, want)
+			}
+		}
+
+		// hashing a nonce doesn't allocate
+		nonce := int64(0)
+		allocs := testing.AllocsPerRun(1000, func() {
+			nonce++
+			hashNonce(nonce)
+		})
+		if allocs != 0 {
+			t.Errorf("%s: hashing a nonce allocates %v times", c.name, allocs)
+		}
+	}
+}
+
+func TestMidstateMinesTheSameBlock(t *testing.T) {
+	coinbase := CoinbaseTx(alice.Address().String(), "", 0)
+	var nonces []int
+	for _, midstate := range []bool{false, true} {
+		block := &Block{BlockHeader: BlockHeader{PrevHash: make([]byte, 32), Timestamp: 1, Difficulty: 10}, Transactions: []*Transaction{coinbase}}
+		if err := block.mine(context.Background(), miningOptions{midstate: midstate}); err != nil {
+			t.Fatal(err)
+		}
+		if !NewProof(block).Validate() {
+			t.Fatalf("block mined with midstate %v is not valid", midstate)
+		}
+		nonces = append(nonces, block.Nonce)
+	}
+	if nonces[0] != nonces[1] {
+		t.Fatalf("got nonce %d with midstate, want %d", nonces[1], nonces[0])
+	}
+}
+
+func BenchmarkHashNonce(b *testing.B) {
+	for _, midstate := range []bool{false, true} {
+		b.Run(fmt.Sprint("midstate=", midstate), func(b *testing.B) {
+			pow := NewProof(&Block{BlockHeader: BlockHeader{PrevHash: make([]byte, 32), Timestamp: 1}, Transactions: []*Transaction{CoinbaseTx(alice.Address().String(), "", 0)}})
+			pow.Midstate = midstate
+			hashNonce := pow.nonceHasher(pow.InitData(0), pow.nonceOffset())
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				hashNonce(int64(i))
+			}
+		})
+	}
+}
+
+func BenchmarkInitData(b *testing.B) {
+	pow := NewProof(&Block{BlockHeader: BlockHeader{PrevHash: make([]byte, 32), Timestamp: 1}, Transactions: []*Transaction{CoinbaseTx(alice.Address().String(), "", 0)}})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		hash := sha256.Sum256(pow.InitData(i))
+		_ = hash
+	}
+}
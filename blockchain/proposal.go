@@ -0,0 +1,147 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// Proposal is an unsigned Transaction proposed by one party to be approved
+// and signed by the holder of the spending key, possibly on another machine.
+// It carries the transactions it spends so it can be reviewed and signed
+// without access to the blockchain.
+type Proposal struct {
+	From    string
+	Tx      Transaction
+	PrevTXs map[string]Transaction
+}
+
+// ProposeTransaction creates an unsigned transaction sending amount from one
+// address to another. The wallet for the from address is not needed.
+func (bc *BlockChain) ProposeTransaction(from, to string, amount, fee units.Amount) (*Proposal, error) {
+	var txInputs []TxInput
+	var txOutputs []TxOutput
+	prevTXs := make(map[string]Transaction)
+	if !keys.ValidateAddress(from) {
+		return nil, fmt.Errorf("address %s is not valid", from)
+	}
+	if !keys.ValidateAddress(to) {
+		return nil, fmt.Errorf("address %s is not valid", to)
+	}
+
+	// find spendable outputs for address and amount plus fee
+	pubKeyHash := NewTXOutput(0, from).PubKeyHash
+	acc, spendableOutputs, err := bc.FindSpendableOutputs(pubKeyHash, amount+fee)
+	if err != nil {
+		return nil, err
+	}
+	if acc < amount+fee {
+		return nil, errors.New("not enough funds to complete transaction")
+	}
+
+	// iterate over spendable outputs, adding unsigned inputs
+	for id, outs := range spendableOutputs {
+		txID, err := hex.DecodeString(id)
+		if err != nil {
+			return nil, err
+		}
+		prevTX, err := bc.FindTransaction(txID)
+		if err != nil {
+			return nil, err
+		}
+		prevTXs[id] = prevTX
+
+		for _, out := range outs {
+			txInputs = append(txInputs, TxInput{
+				ID:        txID,
+				Out:       out,
+				Signature: nil,
+				PubKey:    nil,
+			})
+		}
+	}
+
+	// add a TxOutput for to address and credit excess back to sender
+	txOutputs = append(txOutputs, *NewTXOutput(amount, to))
+	if acc > amount+fee {
+		txOutputs = append(txOutputs, *NewTXOutput(acc-amount-fee, from))
+	}
+
+	// return proposal for the unsigned transaction
+	return &Proposal{
+		From:    from,
+		Tx:      Transaction{ID: nil, Inputs: txInputs, Outputs: txOutputs, Version: TxVersion},
+		PrevTXs: prevTXs,
+	}, nil
+}
+
+// Fee returns the fee paid by the proposed transaction.
+func (p *Proposal) Fee() units.Amount {
+	var fee units.Amount
+	for _, in := range p.Tx.Inputs {
+		fee += p.PrevTXs[hex.EncodeToString(in.ID)].Outputs[in.Out].Value
+	}
+	for _, out := range p.Tx.Outputs {
+		fee -= out.Value
+	}
+	return fee
+}
+
+// Approve signs the proposed transaction with the wallet that owns the
+// outputs it spends.
+func (p *Proposal) Approve(w keys.Key) error {
+	if !w.CanSign() {
+		return fmt.Errorf("unable to approve with %s - %s", w.Address(), keys.ErrWatchOnly.Error())
+	}
+	pubKeyHash := keys.PublicKeyHash(w.PubKey())
+
+	// ensure the wallet can unlock every spent output
+	for inID, in := range p.Tx.Inputs {
+		prevTX, ok := p.PrevTXs[hex.EncodeToString(in.ID)]
+		if !ok || in.Out < 0 || in.Out >= len(prevTX.Outputs) {
+			return fmt.Errorf("proposal is missing output %x:%d", in.ID, in.Out)
+		}
+		if !prevTX.Outputs[in.Out].IsLockedWithKey(pubKeyHash) {
+			return fmt.Errorf("wallet can not unlock output %x:%d", in.ID, in.Out)
+		}
+		p.Tx.Inputs[inID].PubKey = w.PubKey()
+	}
+
+	// generate hash and sign transaction
+	p.Tx.ID = p.Tx.GenerateHash()
+	return p.Tx.Sign(w, p.PrevTXs)
+}
+
+// Serialize serializes a Proposal into bytes so it can be saved to a file.
+func (p *Proposal) Serialize() []byte {
+	var buffer bytes.Buffer
+
+	// use encoder to encode proposal into byte slice
+	err := gob.NewEncoder(&buffer).Encode(p)
+	if err != nil {
+		log.Panicf("Unable to encode Proposal structure into byte slice: %s", err.Error())
+	}
+
+	// return bytes from buffer
+	return buffer.Bytes()
+}
+
+// DeserializeProposal deserializes a byte slice into a Proposal.
+func DeserializeProposal(data []byte) (*Proposal, error) {
+	var p Proposal
+
+	// use decoder to decode byte slice into proposal
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&p)
+	if err != nil {
+		return nil, errors.New("unable to decode proposal - " + err.Error())
+	}
+
+	// return reference to decoded proposal
+	return &p, nil
+}
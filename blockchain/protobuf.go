@@ -0,0 +1,296 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// blockFormatProtobuf is the first byte of blocks serialized as the Block
+// message of gochain.proto. Blocks serialized with gob by earlier versions
+// start with the length of their first gob message, a byte below 0x80 or
+// above 0xf7, so they are told apart and still decoded.
+const blockFormatProtobuf = 0x81
+
+// protobuf wire types of the fields of gochain.proto.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// appendVarint appends the protobuf varint encoding of v to b.
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+// appendInt appends an int64 field to b, leaving it out if it is 0 as
+// proto3 does.
+func appendInt(b []byte, field int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendVarint(b, uint64(field)<<3|wireVarint)
+	return appendVarint(b, uint64(v))
+}
+
+// appendBytes appends a bytes field to b, leaving it out if it is empty as
+// proto3 does.
+func appendBytes(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	return appendMessage(b, field, v)
+}
+
+// appendMessage appends an embedded message field to b, even if it is
+// empty, since it is an element of a repeated field.
+func appendMessage(b []byte, field int, v []byte) []byte {
+	b = appendVarint(b, uint64(field)<<3|wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// MarshalProto encodes a block as a Block message, with its header first
+// so it can be read without the transactions.
+func (b *Block) MarshalProto() []byte {
+	var data []byte
+	data = appendMessage(data, 9, b.Header().marshalProto())
+	for _, tx := range b.Transactions {
+		data = appendMessage(data, 2, tx.MarshalProto())
+	}
+	return data
+}
+
+// marshalProto encodes a block header as a BlockHeader message.
+func (h *BlockHeader) marshalProto() []byte {
+	var data []byte
+	data = appendInt(data, 1, int64(h.Version))
+	data = appendBytes(data, 2, h.Hash)
+	data = appendBytes(data, 3, h.PrevHash)
+	data = appendBytes(data, 4, h.MerkleRoot)
+	data = appendInt(data, 5, int64(h.Nonce))
+	data = appendInt(data, 6, int64(h.Height))
+	data = appendInt(data, 7, h.Timestamp)
+	data = appendInt(data, 8, int64(h.Difficulty))
+	return appendBytes(data, 9, h.Signature)
+}
+
+// MarshalProto encodes a transaction as a Transaction message.
+func (tx *Transaction) MarshalProto() []byte {
+	var data []byte
+	data = appendBytes(data, 1, tx.ID)
+	for _, in := range tx.Inputs {
+		var input []byte
+		input = appendBytes(input, 1, in.ID)
+		input = appendInt(input, 2, int64(in.Out))
+		input = appendBytes(input, 3, in.Signature)
+		input = appendBytes(input, 4, in.PubKey)
+		input = appendBytes(input, 5, in.CoinbaseData)
+		data = appendMessage(data, 2, input)
+	}
+	for _, out := range tx.Outputs {
+		var output []byte
+		output = appendInt(output, 1, int64(out.Value))
+		output = appendBytes(output, 2, out.PubKeyHash)
+		data = appendMessage(data, 3, output)
+	}
+	return appendInt(data, 4, int64(tx.Version))
+}
+
+// protoField is a field read from a protobuf message. value holds the
+// contents of a bytes field, and n the value of a varint field.
+type protoField struct {
+	number int
+	n      uint64
+	value  []byte
+}
+
+// readProtoFields reads the fields of a protobuf message, skipping fixed
+// size fields, which gochain.proto doesn't use but later versions might.
+func readProtoFields(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("invalid field key")
+		}
+		data = data[n:]
+		field := protoField{number: int(key >> 3)}
+
+		switch key & 7 {
+		case wireVarint:
+			field.n, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid varint in field %d", field.number)
+			}
+			data = data[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return nil, fmt.Errorf("invalid length of field %d", field.number)
+			}
+			field.value = data[n : n+int(length)]
+			data = data[n+int(length):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if key&7 == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return nil, fmt.Errorf("field %d is truncated", field.number)
+			}
+			data = data[size:]
+			continue
+		default:
+			return nil, fmt.Errorf("field %d has unsupported wire type %d", field.number, key&7)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// copyBytes returns a copy of a bytes field, or nil if it is empty, as gob
+// decodes empty byte slices.
+func copyBytes(v []byte) []byte {
+	if len(v) == 0 {
+		return nil
+	}
+	return append([]byte{}, v...)
+}
+
+// UnmarshalBlockProto decodes a Block message, with its header in the
+// fields of the Block message if it was serialized before headers were a
+// message of their own.
+func UnmarshalBlockProto(data []byte) (*Block, error) {
+	fields, err := readProtoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	block := &Block{}
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			block.Hash = copyBytes(f.value)
+		case 2:
+			tx, err := UnmarshalTransactionProto(f.value)
+			if err != nil {
+				return nil, fmt.Errorf("transaction %d - %s", len(block.Transactions), err.Error())
+			}
+			block.Transactions = append(block.Transactions, tx)
+		case 3:
+			block.PrevHash = copyBytes(f.value)
+		case 4:
+			block.Nonce = int(int64(f.n))
+		case 5:
+			block.Height = int(int64(f.n))
+		case 6:
+			block.Timestamp = int64(f.n)
+		case 7:
+			block.Difficulty = int(int64(f.n))
+		case 8:
+			block.MerkleRoot = copyBytes(f.value)
+		case 9:
+			header, err := unmarshalHeaderProto(f.value)
+			if err != nil {
+				return nil, errors.New("header - " + err.Error())
+			}
+			block.BlockHeader = *header
+		}
+	}
+	return block, nil
+}
+
+// unmarshalHeaderProto decodes a BlockHeader message.
+func unmarshalHeaderProto(data []byte) (*BlockHeader, error) {
+	fields, err := readProtoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	header := &BlockHeader{}
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			header.Version = int(int64(f.n))
+		case 2:
+			header.Hash = copyBytes(f.value)
+		case 3:
+			header.PrevHash = copyBytes(f.value)
+		case 4:
+			header.MerkleRoot = copyBytes(f.value)
+		case 5:
+			header.Nonce = int(int64(f.n))
+		case 6:
+			header.Height = int(int64(f.n))
+		case 7:
+			header.Timestamp = int64(f.n)
+		case 8:
+			header.Difficulty = int(int64(f.n))
+		case 9:
+			header.Signature = copyBytes(f.value)
+		}
+	}
+	return header, nil
+}
+
+// UnmarshalTransactionProto decodes a Transaction message.
+func UnmarshalTransactionProto(data []byte) (*Transaction, error) {
+	fields, err := readProtoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Transaction{}
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			tx.ID = copyBytes(f.value)
+		case 2:
+			inputFields, err := readProtoFields(f.value)
+			if err != nil {
+				return nil, fmt.Errorf("input %d - %s", len(tx.Inputs), err.Error())
+			}
+			var in TxInput
+			for _, inf := range inputFields {
+				switch inf.number {
+				case 1:
+					in.ID = copyBytes(inf.value)
+				case 2:
+					in.Out = int(int64(inf.n))
+				case 3:
+					in.Signature = copyBytes(inf.value)
+				case 4:
+					in.PubKey = copyBytes(inf.value)
+				case 5:
+					in.CoinbaseData = copyBytes(inf.value)
+				}
+			}
+			tx.Inputs = append(tx.Inputs, in)
+		case 3:
+			outputFields, err := readProtoFields(f.value)
+			if err != nil {
+				return nil, fmt.Errorf("output %d - %s", len(tx.Outputs), err.Error())
+			}
+			var out TxOutput
+			for _, outf := range outputFields {
+				switch outf.number {
+				case 1:
+					out.Value = units.Amount(int64(outf.n))
+				case 2:
+					out.PubKeyHash = copyBytes(outf.value)
+				}
+			}
+			tx.Outputs = append(tx.Outputs, out)
+		case 4:
+			tx.Version = int(int64(f.n))
+		}
+	}
+	return tx, nil
+}
@@ -0,0 +1,144 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+)
+
+func TestBlockProtobuf(t *testing.T) {
+	// the encoding of gochain.proto, field by field
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:    1,
+			Hash:       []byte{1},
+			PrevHash:   []byte{2},
+			MerkleRoot: []byte{10},
+			Nonce:      3,
+			Height:     4,
+			Timestamp:  5,
+			Difficulty: 6,
+		},
+		Transactions: []*Transaction{{
+			ID:      []byte{7},
+			Inputs:  []TxInput{{Out: -1, CoinbaseData: []byte{8}}},
+			Outputs: []TxOutput{{Value: 300, PubKeyHash: []byte{9}}},
+			Version: 1,
+		}},
+	}
+	tx := "121d" + "0a0107" + "120e" + "10ffffffffffffffffff01" + "2a0108" + "1a06" + "08ac02" + "120109" + "2001"
+	want := "81" + "4a13" + "0801" + "120101" + "1a0102" + "22010a" + "2803" + "3004" + "3805" + "4006" + tx
+	data := block.Serialize()
+	if got := hex.EncodeToString(data); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	decoded, err := DecodeBlock(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, block) {
+		t.Fatalf("got %+v, want %+v", decoded, block)
+	}
+
+	// blocks serialized before the header was a message of their own get
+	// the Merkle root of their transactions
+	flat, err := hex.DecodeString("81" + "0a0101" + tx + "1a0102" + "2003" + "2804" + "3005" + "3806")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err = DecodeBlock(flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Version = 0
+	block.MerkleRoot = []byte{7}
+	if !reflect.DeepEqual(decoded, block) {
+		t.Fatalf("got %+v, want %+v", decoded, block)
+	}
+
+	// blocks serialized with gob by earlier versions are still decoded
+	mined := tip(t, newTestChain(t))
+	var legacy bytes.Buffer
+	if err := gob.NewEncoder(&legacy).Encode(legacyBlockOf(mined)); err != nil {
+		t.Fatal(err)
+	}
+	if !legacyBlockFormat(legacy.Bytes()) {
+		t.Fatal("gob encoding taken for protobuf")
+	}
+	decoded, err = DecodeBlock(legacy.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.Serialize(), mined.Serialize()) {
+		t.Fatalf("got %+v from gob, want %+v", decoded, mined)
+	}
+
+	// truncated messages are refused
+	if _, err := DecodeBlock(data[:len(data)-10]); err == nil {
+		t.Fatal("decoded a truncated block")
+	}
+}
+
+func TestMigrateBlocks(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+	mined := minePending(t, bc, alice)
+
+	// store the genesis block as an earlier version would have
+	var legacy bytes.Buffer
+	if err := gob.NewEncoder(&legacy).Encode(legacyBlockOf(genesis)); err != nil {
+		t.Fatal(err)
+	}
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(genesis.Hash, blockRecord(legacy.Bytes()))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block, err := bc.GetBlock(genesis.Hash); err != nil || !bytes.Equal(block.Serialize(), genesis.Serialize()) {
+		t.Fatalf("got %v reading the gob record", err)
+	}
+
+	// only the gob record is rewritten
+	if migrated, err := bc.MigrateBlocks(); err != nil || migrated != 1 {
+		t.Fatalf("got %d migrated and error %v, want 1", migrated, err)
+	}
+	if migrated, err := bc.MigrateBlocks(); err != nil || migrated != 0 {
+		t.Fatalf("got %d migrated and error %v after migrating", migrated, err)
+	}
+	for _, block := range []*Block{genesis, mined} {
+		var record []byte
+		err := bc.DB.View(func(txn *badger.Txn) error {
+			item, err := txn.Get(block.Hash)
+			if err != nil {
+				return err
+			}
+			record, err = item.ValueCopy(nil)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if legacyBlockFormat(recordData(record)) {
+			t.Fatalf("block %x is still stored with gob", block.Hash)
+		}
+	}
+}
+
+// legacyBlockOf returns a version 0 block as earlier versions serialized it
+// with gob.
+func legacyBlockOf(b *Block) legacyBlock {
+	return legacyBlock{
+		Hash:         b.Hash,
+		Transactions: b.Transactions,
+		PrevHash:     b.PrevHash,
+		Nonce:        b.Nonce,
+		Height:       b.Height,
+		Timestamp:    b.Timestamp,
+		Difficulty:   b.Difficulty,
+	}
+}
@@ -0,0 +1,146 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+// prunedHeightKey holds the height of the lowest block of the best chain
+// whose transactions have not been pruned.
+var prunedHeightKey = []byte("prunedheight")
+
+// Pruned returns whether the transactions of the block have been deleted,
+// leaving only its header.
+func (b *Block) Pruned() bool {
+	return len(b.Transactions) == 0 && b.MerkleRoot != nil
+}
+
+// prunedHeight returns the height of the lowest block whose transactions
+// have not been pruned. The genesis block is never pruned.
+func (bc *BlockChain) prunedHeight() (int, error) {
+	height := 1
+
+	// initiate read only transaction on db to get the height
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(prunedHeightKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		height = int(FromBytes(value))
+		return nil
+	})
+	if err != nil {
+		return 0, errors.New("unable to read pruned height - " + err.Error())
+	}
+
+	return height, nil
+}
+
+// Prune deletes the transactions and undo records of the blocks of the best
+// chain more than depth blocks below the tip, keeping their headers, and
+// returns how many blocks were pruned. Outputs of pruned transactions stay
+// spendable through the UTXO set, but pruned blocks can no longer be
+// disconnected by a reorganization or exported.
+func (bc *BlockChain) Prune(depth int) (int, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.pruneTo(depth)
+}
+
+// pruneTo prunes the blocks more than depth blocks below the tip like
+// Prune. The caller must hold bc.mutex.
+func (bc *BlockChain) pruneTo(depth int) (int, error) {
+	if depth < 1 {
+		return 0, fmt.Errorf("prune depth %d must be positive", depth)
+	}
+	start, err := bc.prunedHeight()
+	if err != nil {
+		return 0, err
+	}
+
+	// prune each block in a db transaction with the new pruned height
+	pruned := 0
+	for height := start; height <= bc.Height()-depth; height++ {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			return pruned, err
+		}
+		block.MerkleRoot = block.HashTransactions()
+		block.Transactions = nil
+
+		err = bc.DB.Update(func(txn *badger.Txn) error {
+			if err := putBlock(txn, block); err != nil {
+				return err
+			}
+			if err := txn.Delete(undoKey(block.Hash)); err != nil {
+				return err
+			}
+			if err := txn.Delete(tokenUndoKey(block.Hash)); err != nil {
+				return err
+			}
+			return txn.Set(prunedHeightKey, ToBytes(int64(height+1)))
+		})
+		if err != nil {
+			return pruned, fmt.Errorf("unable to prune block %x - %s", block.Hash, err.Error())
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// prune prunes the chain to the depth it was opened with, if any. The
+// caller must hold bc.mutex.
+func (bc *BlockChain) prune() error {
+	if bc.pruneDepth == 0 {
+		return nil
+	}
+	_, err := bc.pruneTo(bc.pruneDepth)
+	return err
+}
+
+// unspentTransaction rebuilds a transaction in a pruned block from its
+// unspent outputs, which is all that is needed to sign, verify and value
+// transactions spending it. Spent outputs are left empty.
+func (bc *BlockChain) unspentTransaction(txID []byte) (Transaction, bool) {
+	tx := Transaction{ID: txID}
+	prefix := append(append([]byte{}, utxoPrefix...), txID...)
+
+	// initiate read only transaction on db to iterate over the outputs
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+
+			// key is prefix + txID + 8 byte output index
+			key := it.Item().Key()
+			if len(key) != len(prefix)+8 {
+				continue
+			}
+			outIdx := int(FromBytes(key[len(prefix):]))
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			for len(tx.Outputs) <= outIdx {
+				tx.Outputs = append(tx.Outputs, TxOutput{})
+			}
+			tx.Outputs[outIdx] = deserializeOutput(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return Transaction{}, false
+	}
+
+	return tx, len(tx.Outputs) > 0
+}
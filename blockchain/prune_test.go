@@ -0,0 +1,149 @@
+package blockchain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// extend mines n blocks paying carol on the tip of the chain.
+func extend(t *testing.T, bc *BlockChain, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := bc.AcceptBlock(mineOn(t, bc, tip(t, bc), carol)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		depth  int
+		pruned int
+	}{
+		{"deeper than the chain", 10, 0},
+		{"keep the tip", 1, 4},
+		{"keep three blocks", 3, 2},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bc := newTestChain(t)
+			extend(t, bc, 5)
+
+			pruned, err := bc.Prune(c.depth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pruned != c.pruned {
+				t.Fatalf("pruned %d blocks, want %d", pruned, c.pruned)
+			}
+
+			// pruning again has nothing left to do
+			if pruned, err := bc.Prune(c.depth); err != nil || pruned != 0 {
+				t.Fatalf("pruned %d more blocks, %v", pruned, err)
+			}
+
+			// the genesis block and the last depth blocks keep their
+			// transactions, and every header keeps a valid proof of work
+			for height := 0; height <= bc.Height(); height++ {
+				block, err := bc.GetBlockByHeight(height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := height > 0 && height <= c.pruned
+				if block.Pruned() != want {
+					t.Errorf("block %d pruned %v, want %v", height, block.Pruned(), want)
+				}
+				if !NewProof(block).Validate() {
+					t.Errorf("block %d has an invalid proof of work after pruning", height)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneRejectsNonPositiveDepth(t *testing.T) {
+	bc := newTestChain(t)
+	for _, depth := range []int{0, -1} {
+		if _, err := bc.Prune(depth); err == nil {
+			t.Errorf("pruning to depth %d succeeded", depth)
+		}
+	}
+}
+
+func TestSpendFromPrunedBlock(t *testing.T) {
+	bc := newTestChain(t)
+	extend(t, bc, 3)
+	if _, err := bc.Prune(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// carol's block rewards are in pruned blocks but still spendable
+	tx := send(t, bc, carol, bob, Subsidy+1, 1)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, alice)
+	if got := balance(t, bc, bob); got != Subsidy+1 {
+		t.Fatalf("bob has %d, want %d", got, Subsidy+1)
+	}
+}
+
+func TestUndoRestoresSpentOutputs(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		prune bool
+		want  string
+	}{
+		{"full chain", false, ""},
+		{"pruned chain", true, "pruned"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bc := newTestChain(t)
+			genesis := tip(t, bc)
+			in := genesisInput(t, bc)
+
+			// spend the genesis allocation on the best chain
+			tx := send(t, bc, alice, bob, 10, 0)
+			if err := bc.AddToMempool(tx); err != nil {
+				t.Fatal(err)
+			}
+			spent := minePending(t, bc, alice)
+			extend(t, bc, 1)
+			if c.prune {
+				if _, err := bc.Prune(1); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, ok := bc.GetUnspentOutput(in.ID, in.Out); ok {
+				t.Fatal("spent genesis output is still unspent")
+			}
+
+			// a heavier side chain from genesis disconnects the spend
+			side := genesis
+			for i := 0; i < 2; i++ {
+				side = mineOn(t, bc, side, carol)
+				if err := bc.AcceptBlock(side); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := bc.AcceptBlock(mineOn(t, bc, side, carol))
+			if c.want == "" && err != nil || c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)) {
+				t.Fatalf("got error %v, want %q", err, c.want)
+			}
+
+			if c.want != "" {
+				if block, err := bc.GetBlockByHeight(1); err != nil || !bytes.Equal(block.Hash, spent.Hash) {
+					t.Fatal("best chain changed after a failed reorganization")
+				}
+				return
+			}
+			if out, ok := bc.GetUnspentOutput(in.ID, in.Out); !ok || out.Value != genesisAllocation {
+				t.Fatal("genesis output was not restored by the undo record")
+			}
+			if _, ok := bc.GetUnspentOutput(tx.ID, 0); ok {
+				t.Fatal("output of the disconnected spend is still unspent")
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// RawTransaction is a Transaction built from explicit inputs and outputs.
+// It carries the transactions it spends so it can be signed on a machine
+// without the blockchain, and is exchanged as hex so it can be copied
+// between machines.
+type RawTransaction struct {
+	Tx      Transaction
+	PrevTXs map[string]Transaction
+}
+
+// CreateRawTransaction creates an unsigned transaction spending the given
+// unspent outputs. Only the ID and Out of each input are used. Any value
+// not claimed by the outputs is the fee.
+func (bc *BlockChain) CreateRawTransaction(inputs []TxInput, outputs []TxOutput) (*RawTransaction, error) {
+	var txInputs []TxInput
+	prevTXs := make(map[string]Transaction)
+	var value units.Amount
+
+	if len(inputs) == 0 || len(outputs) == 0 {
+		return nil, errors.New("raw transaction needs at least one input and one output")
+	}
+
+	// look up the outputs spent by the inputs
+	seen := make(map[string]bool)
+	for _, in := range inputs {
+		if seen[outpoint(in.ID, in.Out)] {
+			return nil, fmt.Errorf("output %s is spent twice", outpoint(in.ID, in.Out))
+		}
+		seen[outpoint(in.ID, in.Out)] = true
+
+		out, ok := bc.GetUnspentOutput(in.ID, in.Out)
+		if !ok {
+			return nil, fmt.Errorf("output %s is missing or spent", outpoint(in.ID, in.Out))
+		}
+		prevTX, err := bc.FindTransaction(in.ID)
+		if err != nil {
+			return nil, err
+		}
+		prevTXs[hex.EncodeToString(in.ID)] = prevTX
+		value += out.Value
+
+		txInputs = append(txInputs, TxInput{
+			ID:        in.ID,
+			Out:       in.Out,
+			Signature: nil,
+			PubKey:    nil,
+		})
+	}
+
+	// ensure the outputs don't spend more than the inputs
+	for _, out := range outputs {
+		if out.Value <= 0 {
+			return nil, errors.New("output values must be positive")
+		}
+		value -= out.Value
+	}
+	if value < 0 {
+		return nil, errors.New("outputs spend more than the inputs")
+	}
+
+	// return the unsigned transaction
+	return &RawTransaction{
+		Tx:      Transaction{ID: nil, Inputs: txInputs, Outputs: outputs, Version: TxVersion},
+		PrevTXs: prevTXs,
+	}, nil
+}
+
+// Fee returns the fee paid by the raw transaction. An error is returned if
+// the raw transaction is missing an output it spends.
+func (r *RawTransaction) Fee() (units.Amount, error) {
+	var fee units.Amount
+	for _, in := range r.Tx.Inputs {
+		prevTX, ok := r.PrevTXs[hex.EncodeToString(in.ID)]
+		if !ok || in.Out < 0 || in.Out >= len(prevTX.Outputs) {
+			return 0, fmt.Errorf("raw transaction is missing output %s", outpoint(in.ID, in.Out))
+		}
+		fee += prevTX.Outputs[in.Out].Value
+	}
+	for _, out := range r.Tx.Outputs {
+		fee -= out.Value
+	}
+	return fee, nil
+}
+
+// Sign signs every input of the raw transaction with the wallet, keyed by
+// address, that owns the output it spends.
+func (r *RawTransaction) Sign(wallets map[string]keys.Key) error {
+	signers := make([]keys.Key, len(r.Tx.Inputs))
+
+	// find the wallet for every input
+	for inID, in := range r.Tx.Inputs {
+		prevTX, ok := r.PrevTXs[hex.EncodeToString(in.ID)]
+		if !ok || in.Out < 0 || in.Out >= len(prevTX.Outputs) {
+			return fmt.Errorf("raw transaction is missing output %s", outpoint(in.ID, in.Out))
+		}
+		address := prevTX.Outputs[in.Out].Address()
+		w, ok := wallets[address]
+		if !ok {
+			return fmt.Errorf("no wallet for %s to sign output %s", address, outpoint(in.ID, in.Out))
+		}
+		if !w.CanSign() {
+			return fmt.Errorf("wallet for %s can't sign output %s - %s", address, outpoint(in.ID, in.Out), keys.ErrWatchOnly.Error())
+		}
+		signers[inID] = w
+		r.Tx.Inputs[inID].PubKey = w.PubKey()
+	}
+
+	// generate hash, then sign a copy with each wallet and keep the
+	// signatures of the inputs it owns
+	r.Tx.ID = r.Tx.GenerateHash()
+	signedBy := make(map[keys.Key]Transaction)
+	for inID, w := range signers {
+		signed, ok := signedBy[w]
+		if !ok {
+			signed = r.Tx
+			signed.Inputs = append([]TxInput{}, r.Tx.Inputs...)
+			if err := signed.Sign(w, r.PrevTXs); err != nil {
+				return err
+			}
+			signedBy[w] = signed
+		}
+		r.Tx.Inputs[inID].Signature = signed.Inputs[inID].Signature
+	}
+
+	return nil
+}
+
+// Hex encodes the raw transaction as hex.
+func (r *RawTransaction) Hex() string {
+	var buffer bytes.Buffer
+
+	// use encoder to encode raw transaction into byte slice
+	if err := gob.NewEncoder(&buffer).Encode(r); err != nil {
+		log.Panicf("Unable to encode RawTransaction structure into byte slice: %s", err.Error())
+	}
+
+	return hex.EncodeToString(buffer.Bytes())
+}
+
+// DecodeRawTransaction decodes a raw transaction encoded by Hex.
+func DecodeRawTransaction(s string) (*RawTransaction, error) {
+	var r RawTransaction
+
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("unable to decode raw transaction hex - " + err.Error())
+	}
+
+	// use decoder to decode byte slice into raw transaction
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&r); err != nil {
+		return nil, errors.New("unable to decode raw transaction - " + err.Error())
+	}
+
+	return &r, nil
+}
+
+// SendRawTransaction adds a signed raw transaction to the mempool.
+func (bc *BlockChain) SendRawTransaction(r *RawTransaction) error {
+	if r.Tx.ID == nil {
+		return errors.New("raw transaction has not been signed")
+	}
+	return bc.AddToMempool(&r.Tx)
+}
+
+// TestRawTransaction reports whether a signed raw transaction would enter
+// the mempool like TestMempoolAccept, without adding it.
+func (bc *BlockChain) TestRawTransaction(r *RawTransaction) MempoolAcceptResult {
+	if r.Tx.ID == nil {
+		return MempoolAcceptResult{Rule: RejectMalformed, Reason: "raw transaction has not been signed"}
+	}
+	return bc.TestMempoolAccept(&r.Tx)
+}
@@ -0,0 +1,163 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// genesisInput returns an input spending the genesis allocation of alice.
+func genesisInput(t *testing.T, bc *BlockChain) TxInput {
+	t.Helper()
+	tx := tip(t, bc).Transactions[0]
+	for outIdx, out := range tx.Outputs {
+		if out.IsLockedWithKey(wallet.GeneratePublicKeyHash(alice.PublicKey)) {
+			return TxInput{ID: tx.ID, Out: outIdx}
+		}
+	}
+	t.Fatal("genesis does not pay alice")
+	return TxInput{}
+}
+
+func TestRawTransactionWorkflow(t *testing.T) {
+	bc := newTestChain(t)
+	r, err := bc.CreateRawTransaction(
+		[]TxInput{genesisInput(t, bc)},
+		[]TxOutput{*NewTXOutput(10, bob.Address().String()), *NewTXOutput(genesisAllocation-12, alice.Address().String())},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee, err := r.Fee(); err != nil || fee != 2 {
+		t.Fatalf("got fee %d, %v, want 2", fee, err)
+	}
+
+	// sign a copy decoded from hex, as an offline signer would
+	offline, err := DecodeRawTransaction(r.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := offline.Sign(map[string]keys.Key{alice.Address().String(): alice}); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := DecodeRawTransaction(offline.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.SendRawTransaction(signed); err != nil {
+		t.Fatal(err)
+	}
+
+	minePending(t, bc, carol)
+	if got := balance(t, bc, bob); got != 10 {
+		t.Fatalf("bob has %d, want 10", got)
+	}
+	if got := balance(t, bc, carol); got != Subsidy+2 {
+		t.Fatalf("miner has %d, want %d", got, Subsidy+2)
+	}
+}
+
+func TestRawTransactionErrors(t *testing.T) {
+	bc := newTestChain(t)
+	in := genesisInput(t, bc)
+	pay := []TxOutput{*NewTXOutput(10, bob.Address().String())}
+
+	newRaw := func() *RawTransaction {
+		r, err := bc.CreateRawTransaction([]TxInput{in}, pay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	for _, c := range []struct {
+		name string
+		err  func() error
+		want string
+	}{
+		{"create without inputs", func() error {
+			_, err := bc.CreateRawTransaction(nil, pay)
+			return err
+		}, "at least one input"},
+		{"create spending twice", func() error {
+			_, err := bc.CreateRawTransaction([]TxInput{in, in}, pay)
+			return err
+		}, "spent twice"},
+		{"create spending missing output", func() error {
+			_, err := bc.CreateRawTransaction([]TxInput{{ID: in.ID, Out: 99}}, pay)
+			return err
+		}, "missing or spent"},
+		{"create overspending", func() error {
+			_, err := bc.CreateRawTransaction([]TxInput{in}, []TxOutput{*NewTXOutput(genesisAllocation+1, bob.Address().String())})
+			return err
+		}, "more than the inputs"},
+		{"create zero output", func() error {
+			_, err := bc.CreateRawTransaction([]TxInput{in}, []TxOutput{*NewTXOutput(0, bob.Address().String())})
+			return err
+		}, "positive"},
+		{"decode bad hex", func() error {
+			_, err := DecodeRawTransaction("zz")
+			return err
+		}, "hex"},
+		{"decode garbage", func() error {
+			_, err := DecodeRawTransaction("00ff")
+			return err
+		}, "unable to decode raw transaction"},
+		{"fee of missing output", func() error {
+			r := newRaw()
+			r.Tx.Inputs[0].Out = 5
+			_, err := r.Fee()
+			return err
+		}, "missing output"},
+		{"sign without wallet", func() error {
+			return newRaw().Sign(map[string]keys.Key{})
+		}, "no wallet"},
+		{"send unsigned", func() error {
+			return bc.SendRawTransaction(newRaw())
+		}, "not been signed"},
+		{"send unknown input", func() error {
+			r := newRaw()
+			r.Tx.Inputs[0].ID = []byte("unknown")
+			r.Tx.ID = []byte("signed")
+			return bc.SendRawTransaction(r)
+		}, "missing or spent"},
+		{"send bad signature", func() error {
+			r := newRaw()
+			if err := r.Sign(map[string]keys.Key{alice.Address().String(): alice}); err != nil {
+				t.Fatal(err)
+			}
+			r.Tx.Inputs[0].Signature[0] ^= 0xff
+			return bc.SendRawTransaction(r)
+		}, "invalid signature"},
+	} {
+		err := c.err()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %v, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestVerifyAndFeeOfUnknownOutputs(t *testing.T) {
+	bc := newTestChain(t)
+	in := genesisInput(t, bc)
+
+	for _, c := range []struct {
+		name string
+		in   TxInput
+		want string
+	}{
+		{"unknown transaction", TxInput{ID: []byte("unknown"), Out: 0}, "unable to find output"},
+		{"output index too high", TxInput{ID: in.ID, Out: 99}, "has no output 99"},
+		{"negative output index", TxInput{ID: in.ID, Out: -2}, "has no output -2"},
+	} {
+		tx := &Transaction{ID: []byte("tx"), Inputs: []TxInput{c.in}, Outputs: []TxOutput{*NewTXOutput(1, bob.Address().String())}}
+		if _, err := bc.VerifyTransaction(tx); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: VerifyTransaction got error %v, want %q", c.name, err, c.want)
+		}
+		if _, err := bc.TransactionFee(tx); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: TransactionFee got error %v, want %q", c.name, err, c.want)
+		}
+	}
+}
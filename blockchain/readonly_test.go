@@ -0,0 +1,83 @@
+package blockchain
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/edwintcloud/gochain/logging"
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestReadOnly(t *testing.T) {
+	cfg := Config{Path: t.TempDir(), Genesis: testGenesis(), Logger: logging.Discard}
+	bc, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AddToMempool(send(t, bc, alice, bob, units.Coin, 0)); err != nil {
+		t.Fatal(err)
+	}
+	block := minePending(t, bc, carol)
+
+	// a read-only open can't share the database with a writer
+	cfg.ReadOnly = true
+	if _, err := Open(cfg); err != ErrLocked {
+		t.Fatalf("read-only open while the chain is open returned %v, want ErrLocked", err)
+	}
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// readers share the database and see the chain as it was left
+	first, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("unable to open chain read-only: %s", err)
+	}
+	defer first.Close()
+	second, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("unable to open chain read-only twice: %s", err)
+	}
+	defer second.Close()
+	if !bytes.Equal(second.Tip(), block.Hash) {
+		t.Fatalf("read-only chain has tip %x, want %x", second.Tip(), block.Hash)
+	}
+	if got := balance(t, first, bob); got != units.Coin {
+		t.Fatalf("bob holds %s in the read-only chain, want %s", got, units.FormatAmount(units.Coin))
+	}
+
+	// nothing can be added to it
+	if _, err := first.MinePending(carol.Address().String()); err != ErrReadOnly {
+		t.Fatalf("mining a read-only chain returned %v, want ErrReadOnly", err)
+	}
+	if err := first.AcceptBlock(mineOn(t, first, block, carol)); err != ErrReadOnly {
+		t.Fatalf("accepting a block on a read-only chain returned %v, want ErrReadOnly", err)
+	}
+
+	// a writable open needs the readers to close
+	cfg.ReadOnly = false
+	if _, err := Open(cfg); err != ErrLocked {
+		t.Fatalf("writable open while readers are open returned %v, want ErrLocked", err)
+	}
+}
+
+func TestReadOnlyNeedsChain(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Path: filepath.Join(dir, "missing"), Genesis: testGenesis(), Logger: logging.Discard, ReadOnly: true}
+	if _, err := Open(cfg); err != ErrNoBlockChain {
+		t.Fatalf("read-only open of a missing database returned %v, want ErrNoBlockChain", err)
+	}
+
+	// indexes built writable are checked, and a missing one is refused
+	cfg.ReadOnly = false
+	bc, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.Close()
+	cfg.ReadOnly, cfg.SpentIndex = true, true
+	if _, err := Open(cfg); err != ErrNeedsWrite {
+		t.Fatalf("read-only open without the spent index returned %v, want ErrNeedsWrite", err)
+	}
+}
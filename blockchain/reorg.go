@@ -0,0 +1,455 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/hooks"
+)
+
+// workPrefix is the key prefix for the cumulative work of the chain ending
+// at a block.
+var workPrefix = []byte("work-")
+
+// workKey returns the db key for the cumulative work of a block.
+func workKey(hash []byte) []byte {
+	return append(append([]byte{}, workPrefix...), hash...)
+}
+
+// getBlock reads a block from the db within a transaction.
+func getBlock(txn *badger.Txn, hash []byte) (*Block, error) {
+	item, err := txn.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	record, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBlockRecord(hash, record)
+}
+
+// getChainWork reads the cumulative work of the chain ending at a block.
+func getChainWork(txn *badger.Txn, hash []byte) (*big.Int, error) {
+	item, err := txn.Get(workKey(hash))
+	if err != nil {
+		return nil, err
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(value), nil
+}
+
+// setChainWork stores the cumulative work of the chain ending at a block,
+// which is the work of the block plus the cumulative work of its parent.
+func setChainWork(txn *badger.Txn, block *Block) error {
+	work := block.Work()
+
+	if len(block.PrevHash) != 0 {
+		parentWork, err := getChainWork(txn, block.PrevHash)
+		if err != nil {
+			return errors.New("unable to get parent chain work - " + err.Error())
+		}
+		work.Add(work, parentWork)
+	}
+
+	return txn.Set(workKey(block.Hash), work.Bytes())
+}
+
+// GetBlock returns the block with the given hash, whether or not it is part
+// of the best chain.
+func (bc *BlockChain) GetBlock(hash []byte) (*Block, error) {
+	var block *Block
+
+	// initiate read only transaction on db to get the block
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		var err error
+		block, err = getBlock(txn, hash)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("block %x does not exist", hash)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// ChainWork returns the cumulative work of the best chain.
+func (bc *BlockChain) ChainWork() *big.Int {
+	var work *big.Int
+
+	// initiate read only transaction on db to get the tip's work
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		var err error
+		work, err = getChainWork(txn, bc.Tip())
+		return err
+	})
+	if err != nil {
+		bc.panicf("Unable to read chain work from database: %s", err.Error())
+	}
+
+	return work
+}
+
+// AcceptBlock stores a block that was mined elsewhere. A block building on
+// the tip is verified and becomes the new tip. Blocks extending a side chain
+// are kept, and if the chain ending at the block has more cumulative work
+// than the best chain, the tip is switched to it. ErrReadOnly is returned
+// if the chain was opened read-only.
+func (bc *BlockChain) AcceptBlock(block *Block) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	// blocks can't be stored in a read-only chain
+	if bc.readOnly {
+		return ErrReadOnly
+	}
+
+	// ignore blocks that are already stored
+	if _, err := bc.GetBlock(block.Hash); err == nil {
+		return nil
+	}
+
+	// verify the block itself and its proof of work
+	if err := checkBlock(block, bc.mining.authorities); err != nil {
+		return err
+	}
+
+	// the parent must be known to calculate cumulative work
+	parent, err := bc.GetBlock(block.PrevHash)
+	if err != nil {
+		return fmt.Errorf("parent of block %x is unknown", block.Hash)
+	}
+	if err := bc.checkParent(block, parent); err != nil {
+		return err
+	}
+
+	// extend the best chain directly when the block builds on the tip
+	if bytes.Equal(block.PrevHash, bc.Tip()) {
+		return bc.connectTip(block)
+	}
+
+	// refuse side chains that fork below a checkpoint the best chain passed
+	// through, which could never become the best chain
+	if err := bc.checkReorgDepth(parent.Height); err != nil {
+		return fmt.Errorf("block %x is rejected: %s", block.Hash, err.Error())
+	}
+
+	// store the block and its cumulative work, refusing it while the chain
+	// is frozen
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		if err := checkFrozen(txn); err != nil {
+			return err
+		}
+		if err := putBlock(txn, block); err != nil {
+			return err
+		}
+		if err := setForkTip(txn, block); err != nil {
+			return err
+		}
+		return setChainWork(txn, block)
+	})
+	if err == ErrChainFrozen {
+		return err
+	} else if err != nil {
+		bc.panicf("Unable to store block in database: %s", err.Error())
+	}
+
+	// switch to the new chain if it has more work than the best chain
+	var newWork *big.Int
+	err = bc.DB.View(func(txn *badger.Txn) error {
+		var err error
+		newWork, err = getChainWork(txn, block.Hash)
+		return err
+	})
+	if err != nil {
+		bc.panicf("Unable to read chain work from database: %s", err.Error())
+	}
+	if newWork.Cmp(bc.ChainWork()) <= 0 {
+		return nil
+	}
+
+	return bc.reorganize(block)
+}
+
+// connectTip verifies a block that builds on the tip of the best chain and
+// makes it the new tip.
+func (bc *BlockChain) connectTip(block *Block) error {
+	var invalid error
+
+	// verify, store and connect the block in a single db transaction,
+	// refusing it while the chain is frozen
+	var view *utxoView
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		if err := checkFrozen(txn); err != nil {
+			return err
+		}
+		view = newUTXOView(txn, bc.utxoCache)
+		start := time.Now()
+		if invalid = verifyBlockTransactions(view, block, bc.scheme, bc.verifiesSignatures(block)); invalid != nil {
+			return invalid
+		}
+		data := block.Serialize()
+		if err := txn.Set(block.Hash, blockRecord(data)); err != nil {
+			return err
+		}
+		if err := setChainWork(txn, block); err != nil {
+			return err
+		}
+		if err := connectBlock(view, block); err != nil {
+			return err
+		}
+		if err := journalBlock(txn, JournalConnect, block); err != nil {
+			return err
+		}
+		if err := recordMetrics(txn, block, len(data), time.Since(start)); err != nil {
+			return err
+		}
+		for _, tx := range block.Transactions {
+			if err := deleteFromMempool(txn, tx.ID); err != nil {
+				return err
+			}
+		}
+		return txn.Set([]byte("lh"), block.Hash)
+	})
+	if err == ErrChainFrozen {
+		return err
+	} else if invalid != nil {
+		return fmt.Errorf("block %x is invalid: %s", block.Hash, invalid.Error())
+	} else if err != nil {
+		return fmt.Errorf("unable to connect block %x: %s", block.Hash, err.Error())
+	}
+	view.commit()
+	bc.setTip(block.Hash)
+	if err := bc.flushJournal(); err != nil {
+		return fmt.Errorf("unable to append block %x to journal: %s", block.Hash, err.Error())
+	}
+	bc.feeHistogram.remove(block.Transactions)
+	bc.notifyBlock(hooks.BlockConnected, block)
+
+	// drop pending transactions that conflict with the block
+	bc.pruneMempool()
+
+	// prune blocks that are now deeper than the prune depth
+	if err := bc.prune(); err != nil {
+		return fmt.Errorf("unable to prune blocks: %s", err.Error())
+	}
+
+	return nil
+}
+
+// reorganize switches the best chain to the chain ending at newTip by
+// disconnecting blocks from the current tip back to the fork point and
+// connecting the blocks of the new chain. Transactions from disconnected
+// blocks are returned to the mempool. Every connected block is verified
+// like a block extending the tip, and if any is invalid, nothing is changed
+// and an error is returned.
+func (bc *BlockChain) reorganize(newTip *Block) error {
+	mainChain := make(map[string]bool)
+	var disconnect, connect []*Block
+
+	// collect the hashes of the blocks in the best chain
+	iter := bc.NewIterator()
+	for {
+		block := iter.Next()
+		mainChain[hex.EncodeToString(block.Hash)] = true
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	// walk the new chain back to the fork point
+	block := newTip
+	for !mainChain[hex.EncodeToString(block.Hash)] {
+		connect = append(connect, block)
+		parent, err := bc.GetBlock(block.PrevHash)
+		if err != nil {
+			return err
+		}
+		block = parent
+	}
+	forkHash := hex.EncodeToString(block.Hash)
+	if err := bc.checkReorgDepth(block.Height); err != nil {
+		return fmt.Errorf("unable to reorganize to block %x: %s", newTip.Hash, err.Error())
+	}
+
+	forkHeight := block.Height
+
+	// walk the best chain back to the fork point
+	iter = bc.NewIterator()
+	for {
+		block := iter.Next()
+		if hex.EncodeToString(block.Hash) == forkHash {
+			break
+		}
+		disconnect = append(disconnect, block)
+	}
+
+	// the old tip becomes the tip of a side chain
+	oldTip := disconnect[0]
+	record := ReorgRecord{
+		Time:       bc.now().Unix(),
+		OldTip:     hex.EncodeToString(oldTip.Hash),
+		OldHeight:  oldTip.Height,
+		NewTip:     hex.EncodeToString(newTip.Hash),
+		NewHeight:  newTip.Height,
+		Fork:       forkHash,
+		ForkHeight: forkHeight,
+		Depth:      len(disconnect),
+		Connected:  len(connect),
+	}
+
+	// switch chains in a single db transaction so a failure leaves the
+	// best chain unchanged, refusing to switch while the chain is frozen
+	var view *utxoView
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		if err := checkFrozen(txn); err != nil {
+			return err
+		}
+		view = newUTXOView(txn, bc.utxoCache)
+
+		// disconnect from the tip down, returning transactions to the mempool
+		for _, block := range disconnect {
+			if err := journalBlock(txn, JournalDisconnect, block); err != nil {
+				return err
+			}
+			if err := disconnectBlock(view, block); err != nil {
+				return err
+			}
+			for _, tx := range block.Transactions {
+				if tx.IsCoinbase() {
+					continue
+				}
+				if err := addToMempoolTxn(txn, tx, bc.now().Unix()); err != nil {
+					return err
+				}
+			}
+		}
+
+		// verify and connect from the fork up, removing transactions from
+		// the mempool, so an invalid block aborts the whole switch
+		for i := len(connect) - 1; i >= 0; i-- {
+			start := time.Now()
+			if err := verifyBlockTransactions(view, connect[i], bc.scheme, bc.verifiesSignatures(connect[i])); err != nil {
+				return fmt.Errorf("block %x is invalid: %s", connect[i].Hash, err.Error())
+			}
+			if err := connectBlock(view, connect[i]); err != nil {
+				return err
+			}
+			if err := journalBlock(txn, JournalConnect, connect[i]); err != nil {
+				return err
+			}
+			if err := recordMetrics(txn, connect[i], len(connect[i].Serialize()), time.Since(start)); err != nil {
+				return err
+			}
+			for _, tx := range connect[i].Transactions {
+				if err := deleteFromMempool(txn, tx.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		// record the switch, with the old tip as the tip of a side chain
+		if err := txn.Delete(forkTipKey(newTip.Hash)); err != nil {
+			return err
+		}
+		if err := txn.Set(forkTipKey(oldTip.Hash), nil); err != nil {
+			return err
+		}
+		if err := recordReorg(txn, &record); err != nil {
+			return err
+		}
+
+		// set the new tip
+		return txn.Set([]byte("lh"), newTip.Hash)
+	})
+	if err == ErrChainFrozen {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("unable to reorganize to block %x: %s", newTip.Hash, err.Error())
+	}
+	view.commit()
+	bc.setTip(newTip.Hash)
+	if err := bc.flushJournal(); err != nil {
+		return fmt.Errorf("unable to append reorganization to block %x to journal: %s", newTip.Hash, err.Error())
+	}
+	bc.feeHistogram.reset()
+	bc.log.Warn("Reorganized best chain", "tip", newTip.Hash, "fork", forkHash,
+		"disconnected", len(disconnect), "connected", len(connect))
+	if err := bc.appendReorgLog(record); err != nil {
+		bc.log.Error("Unable to append to reorg log", "path", bc.reorgLogPath, "err", err)
+	}
+
+	// notify plugins and subscribers of the blocks that left and joined
+	// the best chain
+	bc.events.Publish(events.Event{Type: events.Reorg, Payload: newReorgEvent(disconnect, connect)})
+	for _, block := range disconnect {
+		bc.notifyBlock(hooks.BlockDisconnected, block)
+	}
+	for i := len(connect) - 1; i >= 0; i-- {
+		bc.notifyBlock(hooks.BlockConnected, connect[i])
+	}
+
+	// drop pending transactions that are no longer valid on the new chain,
+	// and evict those the mempool has no room for
+	bc.pruneMempool()
+	bc.trimMempool()
+
+	// prune blocks that are now deeper than the prune depth
+	if err := bc.prune(); err != nil {
+		return fmt.Errorf("unable to prune blocks: %s", err.Error())
+	}
+
+	return nil
+}
+
+// pruneMempool removes pending transactions that spend outputs which are
+// neither unspent on the chain nor created by another pending transaction.
+func (bc *BlockChain) pruneMempool() {
+	pending := bc.MempoolTransactions()
+	available := make(map[string]bool)
+
+	// outputs of pending transactions are available to their children
+	for _, tx := range pending {
+		for outIdx := range tx.Outputs {
+			available[outpoint(tx.ID, outIdx)] = true
+		}
+	}
+
+	// parents come before children, so removing a parent removes its
+	// outputs before the children are checked
+	for _, tx := range pending {
+		valid := true
+		for _, in := range tx.Inputs {
+			if _, ok := bc.GetUnspentOutput(in.ID, in.Out); !ok && !available[outpoint(in.ID, in.Out)] {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			continue
+		}
+		bc.log.Info("Removing invalid transaction from mempool", "tx", tx.ID)
+
+		for outIdx := range tx.Outputs {
+			delete(available, outpoint(tx.ID, outIdx))
+		}
+		err := bc.DB.Update(func(txn *badger.Txn) error {
+			return deleteFromMempool(txn, tx.ID)
+		})
+		if err != nil {
+			bc.panicf("Unable to remove transaction from mempool: %s", err.Error())
+		}
+		bc.feeHistogram.remove([]*Transaction{tx})
+	}
+}
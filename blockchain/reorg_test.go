@@ -0,0 +1,143 @@
+package blockchain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReorganizeToHeavierChain(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+
+	// pay bob on the best chain
+	tx := send(t, bc, alice, bob, 10, 0)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	mined := minePending(t, bc, alice)
+
+	// a side chain of equal work is stored without switching
+	side1 := mineOn(t, bc, genesis, carol)
+	if err := bc.AcceptBlock(side1); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bc.Tip(), mined.Hash) {
+		t.Fatal("tip switched to a side chain without more work")
+	}
+
+	// extending it makes it the best chain
+	side2 := mineOn(t, bc, side1, carol)
+	if err := bc.AcceptBlock(side2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bc.Tip(), side2.Hash) || bc.Height() != 2 {
+		t.Fatalf("got tip %x at height %d, want side chain tip", bc.Tip(), bc.Height())
+	}
+
+	// the payment is undone and returned to the mempool, and the block
+	// rewards of the old chain are gone
+	pending := bc.MempoolTransactions()
+	if len(pending) != 1 || !equalID(pending[0], tx) {
+		t.Fatalf("got %d pending transactions, want the disconnected payment", len(pending))
+	}
+	if _, ok := bc.GetUnspentOutput(tx.ID, 0); ok {
+		t.Fatal("output of disconnected payment is still unspent")
+	}
+	if got := balance(t, bc, carol); got != 2*Subsidy {
+		t.Fatalf("carol has %d, want %d", got, 2*Subsidy)
+	}
+	if got := balance(t, bc, alice); got != genesisAllocation-10 {
+		t.Fatalf("alice has %d including pending change, want %d", got, genesisAllocation-10)
+	}
+	if block, err := bc.GetBlockByHeight(1); err != nil || !bytes.Equal(block.Hash, side1.Hash) {
+		t.Fatal("height index does not follow the new chain")
+	}
+}
+
+func TestReorganizeRejectsInvalidBlocks(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		txs  func(bc *BlockChain) []*Transaction
+		want string
+	}{
+		{
+			name: "inflated coinbase",
+			txs: func(bc *BlockChain) []*Transaction {
+				return []*Transaction{CoinbaseTx(carol.Address().String(), "", 1)}
+			},
+			want: "more than the subsidy",
+		},
+		{
+			name: "bad signature",
+			txs: func(bc *BlockChain) []*Transaction {
+				tx := send(t, bc, alice, carol, 10, 0)
+				tx.Inputs[0].Signature[0] ^= 0xff
+				return []*Transaction{CoinbaseTx(carol.Address().String(), "", 0), tx}
+			},
+			want: "invalid signature",
+		},
+		{
+			name: "missing coinbase",
+			txs: func(bc *BlockChain) []*Transaction {
+				return []*Transaction{send(t, bc, alice, carol, 10, 0)}
+			},
+			want: "not a coinbase",
+		},
+		{
+			name: "double spend",
+			txs: func(bc *BlockChain) []*Transaction {
+				tx := send(t, bc, alice, carol, 10, 0)
+				return []*Transaction{CoinbaseTx(carol.Address().String(), "", 0), tx, tx}
+			},
+			want: "twice",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bc := newTestChain(t)
+			genesis := tip(t, bc)
+
+			// build the side chain transactions while only the genesis
+			// outputs exist
+			txs := c.txs(bc)
+			mined := mineOn(t, bc, genesis, alice)
+			if err := bc.AcceptBlock(mined); err != nil {
+				t.Fatal(err)
+			}
+
+			// a valid side block followed by an invalid one with more work
+			side1 := mineOn(t, bc, genesis, carol)
+			if err := bc.AcceptBlock(side1); err != nil {
+				t.Fatal(err)
+			}
+			side2 := mineBlock(t, bc, side1, txs)
+			err := bc.AcceptBlock(side2)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("got error %v, want %q", err, c.want)
+			}
+
+			// nothing changed
+			if !bytes.Equal(bc.Tip(), mined.Hash) {
+				t.Fatal("tip switched to an invalid chain")
+			}
+			if got := balance(t, bc, carol); got != 0 {
+				t.Fatalf("carol has %d, want 0", got)
+			}
+			if got := balance(t, bc, alice); got != genesisAllocation+Subsidy {
+				t.Fatalf("alice has %d, want %d", got, genesisAllocation+Subsidy)
+			}
+		})
+	}
+}
+
+func TestConnectTipRejectsInvalidBlocks(t *testing.T) {
+	bc := newTestChain(t)
+	block := mineBlock(t, bc, tip(t, bc), []*Transaction{CoinbaseTx(carol.Address().String(), "", 1)})
+
+	if err := bc.AcceptBlock(block); err == nil || !strings.Contains(err.Error(), "more than the subsidy") {
+		t.Fatalf("got error %v, want inflated coinbase to be rejected", err)
+	}
+	if bc.Height() != 0 {
+		t.Fatal("invalid block was connected")
+	}
+}
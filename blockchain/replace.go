@@ -0,0 +1,164 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// replaceEvent is the payload published to subscribers when pending
+// transactions are evicted from the mempool by a replacement paying a
+// higher fee.
+type replaceEvent struct {
+	Replaced []string `json:"replaced"`
+	By       string   `json:"by"`
+}
+
+// findReplaced returns the pending transactions tx would replace: those
+// spending an output tx spends, and the pending transactions spending
+// their outputs, which would no longer be valid. tx may only replace them
+// if its fee is strictly higher than all of theirs together, and may not
+// spend their outputs.
+func (bc *BlockChain) findReplaced(tx *Transaction, fee units.Amount, pending []*Transaction) ([]*Transaction, error) {
+	spends := make(map[string]bool)
+	for _, in := range tx.Inputs {
+		spends[outpoint(in.ID, in.Out)] = true
+	}
+
+	// collect the conflicting transactions, and their descendants, which
+	// come after them in dependency order
+	var replaced []*Transaction
+	evicted := make(map[string]bool)
+	var conflict *TxInput
+	for _, p := range pending {
+		evict := false
+		for i, in := range p.Inputs {
+			if spends[outpoint(in.ID, in.Out)] {
+				evict = true
+				if conflict == nil {
+					conflict = &p.Inputs[i]
+				}
+			}
+			if evicted[string(in.ID)] {
+				evict = true
+			}
+		}
+		if evict {
+			replaced = append(replaced, p)
+			evicted[string(p.ID)] = true
+		}
+	}
+	if len(replaced) == 0 {
+		return nil, nil
+	}
+
+	// the replacement must not depend on what it evicts, and must pay more
+	var replacedFees units.Amount
+	for _, p := range replaced {
+		pFee, err := bc.TransactionFee(p)
+		if err != nil {
+			return nil, reject(RejectMissingInputs, err)
+		}
+		replacedFees += pFee
+	}
+	for _, in := range tx.Inputs {
+		if evicted[string(in.ID)] {
+			return nil, reject(RejectConflict, fmt.Errorf("output %x:%d is created by pending transaction %x, which the transaction would replace",
+				in.ID, in.Out, in.ID))
+		}
+	}
+	if fee <= replacedFees {
+		return nil, reject(RejectConflict, fmt.Errorf("output %x:%d is already spent by pending transaction %x, and the fee of %s does not exceed the %s it would replace",
+			conflict.ID, conflict.Out, replaced[0].ID, units.FormatAmount(fee), units.FormatAmount(replacedFees)))
+	}
+
+	return replaced, nil
+}
+
+// newReplaceEvent creates the subscriber payload for pending transactions
+// replaced by tx.
+func newReplaceEvent(replaced []*Transaction, tx *Transaction) replaceEvent {
+	event := replaceEvent{By: hex.EncodeToString(tx.ID)}
+	for _, p := range replaced {
+		event.Replaced = append(event.Replaced, hex.EncodeToString(p.ID))
+	}
+	return event
+}
+
+// BumpFee creates a transaction replacing the pending transaction with id
+// txID, spending the same inputs with the same outputs but paying fee,
+// taken from its change, the last output paying one of wallets, which are
+// keyed by address. The inputs must be held by one of wallets to sign
+// them. AddToMempool accepts it in place of the original if fee is higher
+// than the fees of the original and the pending transactions spending its
+// outputs.
+func (bc *BlockChain) BumpFee(wallets map[string]keys.Key, txID []byte, fee units.Amount) (*Transaction, error) {
+	var original *Transaction
+	for _, p := range bc.MempoolTransactions() {
+		if bytes.Equal(p.ID, txID) {
+			original = p
+		}
+	}
+	if original == nil {
+		return nil, fmt.Errorf("transaction %x is not pending", txID)
+	}
+	for _, out := range original.Outputs {
+		if out.IsData() {
+			return nil, fmt.Errorf("transaction %x has a data output, whose tokens or data could refer to its change", txID)
+		}
+	}
+
+	// find the wallet holding every input
+	from := original.Inputs[0].Address()
+	w, ok := wallets[from]
+	if !ok || !w.CanSign() {
+		return nil, fmt.Errorf("unable to sign for %s - no wallet with its private key", from)
+	}
+	for _, in := range original.Inputs {
+		if !bytes.Equal(in.PubKey, w.PubKey()) {
+			return nil, fmt.Errorf("transaction %x spends outputs not held by %s", txID, from)
+		}
+	}
+	oldFee, err := bc.TransactionFee(original)
+	if err != nil {
+		return nil, err
+	}
+	if fee <= oldFee {
+		return nil, fmt.Errorf("fee of %s does not exceed the %s the transaction pays", units.FormatAmount(fee), units.FormatAmount(oldFee))
+	}
+
+	// take the higher fee from the change
+	tx := Transaction{
+		Inputs:  make([]TxInput, len(original.Inputs)),
+		Outputs: append([]TxOutput{}, original.Outputs...),
+		Version: TxVersion,
+	}
+	for i, in := range original.Inputs {
+		tx.Inputs[i] = TxInput{ID: in.ID, Out: in.Out, PubKey: in.PubKey}
+	}
+	change := -1
+	for i, out := range tx.Outputs {
+		if _, ok := wallets[out.Address()]; ok {
+			change = i
+		}
+	}
+	if change < 0 {
+		return nil, errors.New("transaction has no change to pay a higher fee from")
+	}
+	if tx.Outputs[change].Value <= fee-oldFee {
+		return nil, fmt.Errorf("change of %s can't pay the %s more in fees", units.FormatAmount(tx.Outputs[change].Value), units.FormatAmount(fee-oldFee))
+	}
+	tx.Outputs[change].Value -= fee - oldFee
+
+	// generate hash and sign transaction
+	tx.ID = tx.GenerateHash()
+	if err := bc.SignTransaction(&tx, w); err != nil {
+		return nil, err
+	}
+
+	return &tx, nil
+}
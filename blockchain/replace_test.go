@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/keys"
+)
+
+func TestReplaceByFee(t *testing.T) {
+	bc, sub := newTestChainWithEvents(t)
+	nextEvent(t, sub)
+
+	// alice pays bob, who pays carol from the pending payment
+	stuck := send(t, bc, alice, bob, 10, 1)
+	if err := bc.AddToMempool(stuck); err != nil {
+		t.Fatal(err)
+	}
+	child := send(t, bc, bob, carol, 5, 1)
+	if err := bc.AddToMempool(child); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, sub)
+	nextEvent(t, sub)
+
+	// a replacement must pay more than the payment and its child together
+	wallets := map[string]keys.Key{alice.Address().String(): alice}
+	if _, err := bc.BumpFee(wallets, stuck.ID, 1); err == nil {
+		t.Fatal("bumped the fee to the same fee")
+	}
+	if _, err := bc.BumpFee(map[string]keys.Key{}, stuck.ID, 5); err == nil {
+		t.Fatal("bumped the fee without the sender's wallet")
+	}
+	low, err := bc.BumpFee(wallets, stuck.ID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := bc.TestMempoolAccept(low); result.Allowed || result.Rule != RejectConflict {
+		t.Fatalf("got %+v, want a replacement not paying more refused", result)
+	}
+
+	// a higher fee replaces both, and subscribers are told
+	replacement, err := bc.BumpFee(wallets, stuck.ID, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee, _ := bc.TransactionFee(replacement); fee != 3 || !reflect.DeepEqual(replacement.Inputs[0].ID, stuck.Inputs[0].ID) {
+		t.Fatalf("replacement pays %d, want 3 spending the same inputs", fee)
+	}
+	want := []string{hex.EncodeToString(stuck.ID), hex.EncodeToString(child.ID)}
+	if result := bc.TestMempoolAccept(replacement); !result.Allowed || !reflect.DeepEqual(result.Replaces, want) {
+		t.Fatalf("got %+v, want a replacement of %v", result, want)
+	}
+	if err := bc.AddToMempool(replacement); err != nil {
+		t.Fatal(err)
+	}
+	pending := bc.MempoolTransactions()
+	if len(pending) != 1 || !bytes.Equal(pending[0].ID, replacement.ID) {
+		t.Fatalf("got %d pending transactions, want only the replacement", len(pending))
+	}
+	event := nextEvent(t, sub)
+	if event.Type != events.ReplacedTx || !reflect.DeepEqual(event.Payload, replaceEvent{Replaced: want, By: hex.EncodeToString(replacement.ID)}) {
+		t.Fatalf("got %s event %+v, want replacedTx", event.Type, event.Payload)
+	}
+	if event := nextEvent(t, sub); event.Type != events.NewTx {
+		t.Fatalf("got %s event, want newTx", event.Type)
+	}
+
+	// the replacement is mined, paying bob and less change to alice
+	minePending(t, bc, carol)
+	if got := balance(t, bc, alice); got != genesisAllocation-10-3 {
+		t.Fatalf("alice has %d, want %d", got, genesisAllocation-13)
+	}
+}
@@ -0,0 +1,34 @@
+package blockchain
+
+import "github.com/dgraph-io/badger"
+
+// requestPrefix is the key prefix for client request IDs of completed sends.
+var requestPrefix = []byte("request-")
+
+// requestKey returns the db key for a client request ID.
+func requestKey(requestID string) []byte {
+	return append(append([]byte{}, requestPrefix...), requestID...)
+}
+
+// RequestTransaction returns the ID of the transaction created for a client
+// request ID, and whether the request has been completed.
+func (bc *BlockChain) RequestTransaction(requestID string) ([]byte, bool) {
+	var txID []byte
+
+	// initiate read only transaction on db to look up the request
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(requestKey(requestID))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		txID, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		bc.panicf("Unable to read request from database: %s", err.Error())
+	}
+
+	return txID, txID != nil
+}
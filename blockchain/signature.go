@@ -0,0 +1,22 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/edwintcloud/gochain/keys"
+)
+
+// checkSignatureEncoding returns an error if an input of a transaction has
+// a signature that is not in the encoding scheme signs with. Blocks may
+// hold signatures in earlier encodings, but new transactions must not.
+func (tx *Transaction) checkSignatureEncoding(scheme keys.Scheme) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+	for i, in := range tx.Inputs {
+		if err := scheme.CheckEncoding(in.Signature); err != nil {
+			return fmt.Errorf("input %d %s", i, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Retarget algorithms a difficulty simulation can model. RetargetFixed is
+// the rule of the chain, which keeps the difficulty of the parent apart
+// from the minimum difficulty blocks of stalled test networks. The others
+// are candidate rules to compare it with before launching a network, and
+// are not enforced by the chain.
+const (
+	RetargetFixed    = "fixed"
+	RetargetWindow   = "window"
+	RetargetPerBlock = "perblock"
+)
+
+// maxRetargetStep is the most the window algorithm changes the difficulty
+// by at a retarget, which bounds the target to a factor of 4 like Bitcoin.
+const maxRetargetStep = 2
+
+// HashRatePoint is the hash rate of the network, in hashes per second, at
+// a height.
+type HashRatePoint struct {
+	Height int
+	Rate   float64
+}
+
+// HashRateCurve is the hash rate of the network over the heights of a
+// simulation, interpolated linearly between its points ordered by height
+// and constant before the first and after the last.
+type HashRateCurve []HashRatePoint
+
+// ParseHashRateCurve parses a hash rate curve from a single rate, such as
+// 1e6, or from HEIGHT:RATE points separated by commas, such as
+// 0:1e6,500:4e6,1000:1e5.
+func ParseHashRateCurve(s string) (HashRateCurve, error) {
+	var curve HashRateCurve
+	for _, point := range strings.Split(s, ",") {
+		height, rate := "0", point
+		if i := strings.Index(point, ":"); i >= 0 {
+			height, rate = point[:i], point[i+1:]
+		}
+		h, err := strconv.Atoi(strings.TrimSpace(height))
+		if err != nil || h < 0 {
+			return nil, fmt.Errorf("hash rate point %q has an invalid height", point)
+		}
+		r, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		if err != nil || r <= 0 || math.IsInf(r, 0) {
+			return nil, fmt.Errorf("hash rate point %q has an invalid rate", point)
+		}
+		curve = append(curve, HashRatePoint{h, r})
+	}
+	sort.SliceStable(curve, func(i, j int) bool { return curve[i].Height < curve[j].Height })
+	return curve, nil
+}
+
+// At returns the hash rate of the curve at a height.
+func (c HashRateCurve) At(height int) float64 {
+	if len(c) == 0 {
+		return 0
+	}
+	if height <= c[0].Height {
+		return c[0].Rate
+	}
+	for i := 1; i < len(c); i++ {
+		if height <= c[i].Height {
+			a, b := c[i-1], c[i]
+			return a.Rate + (b.Rate-a.Rate)*float64(height-a.Height)/float64(b.Height-a.Height)
+		}
+	}
+	return c[len(c)-1].Rate
+}
+
+// SimulationConfig describes a simulation of block production.
+type SimulationConfig struct {
+
+	// Rules are the difficulty rules of the simulated network, which must
+	// have a target spacing unless Algorithm is RetargetFixed.
+	Rules DifficultyRules
+
+	// Difficulty is the difficulty of the genesis block.
+	Difficulty int
+
+	// HashRate is the hash rate of the network over the simulation.
+	HashRate HashRateCurve
+
+	// Blocks is the number of blocks mined after genesis.
+	Blocks int
+
+	// Algorithm is the retarget algorithm, RetargetFixed if empty, and
+	// Window the number of blocks between retargets of RetargetWindow.
+	Algorithm string
+	Window    int
+
+	// Seed seeds the random times blocks are found at, so a simulation can
+	// be repeated.
+	Seed int64
+}
+
+// SimulatedBlock is a block of a simulation, found Interval seconds after
+// its parent at Difficulty by a network hashing at HashRate.
+type SimulatedBlock struct {
+	Height        int     `json:"height"`
+	Difficulty    int     `json:"difficulty"`
+	MinDifficulty bool    `json:"minDifficulty"`
+	HashRate      float64 `json:"hashRate"`
+	Interval      float64 `json:"interval"`
+}
+
+// SimulateDifficulty models the production of blocks by a network with the
+// hash rate curve of cfg. Finding a block at difficulty d takes 2^d hashes
+// on average, so the time to the next block is drawn from an exponential
+// distribution with a mean of 2^d over the hash rate.
+func SimulateDifficulty(cfg SimulationConfig) ([]SimulatedBlock, error) {
+	rules := cfg.Rules
+	algorithm := cfg.Algorithm
+	if algorithm == "" {
+		algorithm = RetargetFixed
+	}
+	switch {
+	case algorithm != RetargetFixed && algorithm != RetargetWindow && algorithm != RetargetPerBlock:
+		return nil, fmt.Errorf("unknown retarget algorithm %q", algorithm)
+	case algorithm != RetargetFixed && rules.TargetSpacing <= 0:
+		return nil, errors.New("retarget algorithms need a target spacing")
+	case algorithm == RetargetWindow && cfg.Window <= 0:
+		return nil, errors.New("window algorithm needs a window of blocks")
+	case len(cfg.HashRate) == 0:
+		return nil, errors.New("hash rate curve has no points")
+	case cfg.Blocks <= 0:
+		return nil, errors.New("number of blocks must be positive")
+	}
+
+	random := rand.New(rand.NewSource(cfg.Seed))
+	spacing := float64(rules.TargetSpacing)
+	difficulty := cfg.Difficulty
+	var blocks []SimulatedBlock
+	for height := 1; height <= cfg.Blocks; height++ {
+
+		// keep the difficulty within the limits of the network, like
+		// normalDifficulty
+		if difficulty < rules.Min {
+			difficulty = rules.Min
+		}
+		if rules.Max > 0 && difficulty > rules.Max {
+			difficulty = rules.Max
+		}
+
+		// find the block at the normal difficulty, or at the minimum one
+		// once the network stalled for twice the target spacing
+		rate := cfg.HashRate.At(height)
+		block := SimulatedBlock{Height: height, Difficulty: difficulty, HashRate: rate}
+		block.Interval = random.ExpFloat64() * math.Exp2(float64(difficulty)) / rate
+		if rules.AllowMinDifficulty && spacing > 0 && block.Interval > 2*spacing {
+			stalled := 2*spacing + random.ExpFloat64()*math.Exp2(float64(rules.Min))/rate
+			if stalled < block.Interval {
+				block.Interval = stalled
+				block.Difficulty = rules.Min
+				block.MinDifficulty = true
+			}
+		}
+		blocks = append(blocks, block)
+
+		// retarget the difficulty of the next block, which minimum
+		// difficulty blocks don't change
+		switch algorithm {
+		case RetargetWindow:
+			if height%cfg.Window == 0 {
+				actual := 0.0
+				for _, b := range blocks[len(blocks)-cfg.Window:] {
+					actual += b.Interval
+				}
+				step := int(math.Round(math.Log2(spacing * float64(cfg.Window) / actual)))
+				if step > maxRetargetStep {
+					step = maxRetargetStep
+				} else if step < -maxRetargetStep {
+					step = -maxRetargetStep
+				}
+				difficulty += step
+			}
+		case RetargetPerBlock:
+			if block.MinDifficulty {
+				break
+			}
+			if block.Interval < spacing/2 {
+				difficulty++
+			} else if block.Interval > 2*spacing {
+				difficulty--
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// SimulationStats summarizes the blocks of a simulation between two
+// heights. Interval percentiles and the mean are in seconds.
+type SimulationStats struct {
+	FromHeight          int         `json:"fromHeight"`
+	ToHeight            int         `json:"toHeight"`
+	Blocks              int         `json:"blocks"`
+	MeanInterval        float64     `json:"meanInterval"`
+	Interval            Percentiles `json:"interval"`
+	MinDifficulty       int         `json:"minDifficulty"`
+	MaxDifficulty       int         `json:"maxDifficulty"`
+	MinDifficultyBlocks int         `json:"minDifficultyBlocks"`
+}
+
+// SummarizeSimulation summarizes the blocks of a simulation in windows of
+// window blocks by height, or in a single summary if window is 0, like
+// SummarizeMetrics.
+func SummarizeSimulation(blocks []SimulatedBlock, window int) []SimulationStats {
+	var stats []SimulationStats
+
+	for start := 0; start < len(blocks); {
+		end := start + 1
+		for end < len(blocks) && (window == 0 || blocks[end].Height/window == blocks[start].Height/window) {
+			end++
+		}
+
+		s := SimulationStats{
+			FromHeight:    blocks[start].Height,
+			ToHeight:      blocks[end-1].Height,
+			Blocks:        end - start,
+			MinDifficulty: blocks[start].Difficulty,
+			MaxDifficulty: blocks[start].Difficulty,
+		}
+		var intervals []float64
+		for _, b := range blocks[start:end] {
+			intervals = append(intervals, b.Interval)
+			s.MeanInterval += b.Interval / float64(end-start)
+			if b.Difficulty < s.MinDifficulty {
+				s.MinDifficulty = b.Difficulty
+			}
+			if b.Difficulty > s.MaxDifficulty {
+				s.MaxDifficulty = b.Difficulty
+			}
+			if b.MinDifficulty {
+				s.MinDifficultyBlocks++
+			}
+		}
+		s.Interval = newPercentiles(intervals)
+		stats = append(stats, s)
+		start = end
+	}
+
+	return stats
+}
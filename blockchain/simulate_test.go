@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestHashRateCurve(t *testing.T) {
+	curve, err := ParseHashRateCurve("100:3000,0:1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for height, want := range map[int]float64{0: 1000, 50: 2000, 100: 3000, 500: 3000} {
+		if got := curve.At(height); got != want {
+			t.Errorf("rate at %d is %f, want %f", height, got, want)
+		}
+	}
+	for _, bad := range []string{"", "fast", "-1:10", "10:0"} {
+		if _, err := ParseHashRateCurve(bad); err == nil {
+			t.Errorf("parsed %q", bad)
+		}
+	}
+}
+
+func TestSimulateDifficulty(t *testing.T) {
+	rules := DifficultyRules{Min: 1, Max: 255, TargetSpacing: 60}
+	cfg := SimulationConfig{
+		Rules:      rules,
+		Difficulty: 10,
+		HashRate:   HashRateCurve{{1000, 1 << 16}, {2000, 1 << 18}},
+		Blocks:     4000,
+		Algorithm:  RetargetWindow,
+		Window:     50,
+		Seed:       1,
+	}
+
+	// the same seed gives the same blocks
+	blocks, err := SimulateDifficulty(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := SimulateDifficulty(cfg)
+	if !reflect.DeepEqual(blocks, again) {
+		t.Fatal("simulation with the same seed differs")
+	}
+
+	// the window algorithm finds the difficulty of the target spacing,
+	// 2^22 hashes at 2^16 per second, and follows the hash rate to 2^24
+	stats := SummarizeSimulation(blocks, 500)
+	if len(stats) != 9 {
+		t.Fatalf("got %d summaries, want 9", len(stats))
+	}
+	if s := stats[1]; s.MinDifficulty < 21 || s.MaxDifficulty > 23 {
+		t.Errorf("difficulty ranged from %d to %d, want around 22", s.MinDifficulty, s.MaxDifficulty)
+	}
+	if s := stats[6]; s.MinDifficulty < 23 || s.MaxDifficulty > 25 || math.Abs(s.MeanInterval-60) > 30 {
+		t.Errorf("got difficulty %d to %d and mean interval %f, want around 24 and 60", s.MinDifficulty, s.MaxDifficulty, s.MeanInterval)
+	}
+
+	// with the fixed rule the difficulty never changes, and a stalled
+	// network mines at the minimum difficulty
+	cfg.Algorithm = RetargetFixed
+	cfg.Rules.AllowMinDifficulty = true
+	cfg.Difficulty = 24
+	blocks, err = SimulateDifficulty(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := SummarizeSimulation(blocks, 0)[0]
+	if total.MaxDifficulty != 24 || total.MinDifficulty != 1 || total.MinDifficultyBlocks == 0 {
+		t.Fatalf("got %+v, want blocks at 24 and stalls at 1", total)
+	}
+
+	cfg.Algorithm = "lwma"
+	if _, err := SimulateDifficulty(cfg); err == nil {
+		t.Fatal("simulated an unknown algorithm")
+	}
+}
@@ -0,0 +1,199 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// Snapshot is a read only view of the chain pinned to the tip at the time
+// it was taken. Blocks added after the snapshot was taken are not seen, so
+// several queries made through a snapshot agree with each other. Pending
+// transactions are not part of a snapshot. A snapshot must be discarded
+// once it is no longer needed.
+type Snapshot struct {
+	txn *badger.Txn
+	tip *Block
+}
+
+// Snapshot takes a snapshot of the chain at its current tip.
+func (bc *BlockChain) Snapshot() (*Snapshot, error) {
+	txn := bc.DB.NewTransaction(false)
+
+	// read the tip as seen by the snapshot
+	item, err := txn.Get([]byte("lh"))
+	if err != nil {
+		txn.Discard()
+		return nil, errors.New("unable to get previous hash item - " + err.Error())
+	}
+	hash, err := item.ValueCopy(nil)
+	if err != nil {
+		txn.Discard()
+		return nil, err
+	}
+	tip, err := getBlock(txn, hash)
+	if err != nil {
+		txn.Discard()
+		return nil, errors.New("unable to get tip of the chain - " + err.Error())
+	}
+
+	return &Snapshot{txn: txn, tip: tip}, nil
+}
+
+// Discard releases the snapshot.
+func (s *Snapshot) Discard() {
+	s.txn.Discard()
+}
+
+// Tip returns the tip of the chain when the snapshot was taken.
+func (s *Snapshot) Tip() *Block {
+	return s.tip
+}
+
+// Height returns the height of the tip of the snapshot.
+func (s *Snapshot) Height() int {
+	return s.tip.Height
+}
+
+// GetBlock returns the block with the given hash.
+func (s *Snapshot) GetBlock(hash []byte) (*Block, error) {
+	block, err := getBlock(s.txn, hash)
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("block %x does not exist", hash)
+	}
+	return block, err
+}
+
+// GetBlockByHeight returns the block at a height in the best chain of the
+// snapshot.
+func (s *Snapshot) GetBlockByHeight(height int) (*Block, error) {
+	item, err := s.txn.Get(heightKey(height))
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("no block at height %d", height)
+	} else if err != nil {
+		return nil, err
+	}
+	hash, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBlock(hash)
+}
+
+// UnspentOutputs returns the confirmed unspent outputs that can be unlocked
+// by pubKeyHash.
+func (s *Snapshot) UnspentOutputs(pubKeyHash []byte) ([]UnspentOutput, error) {
+	var unspent []UnspentOutput
+
+	entries, err := readAddrIndex(s.txn, pubKeyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// look up each output paying to pubKeyHash in the UTXO set
+	for txID, e := range entries {
+		for _, outIdx := range e.Outputs {
+			item, err := s.txn.Get(utxoKey([]byte(txID), outIdx))
+			if err == badger.ErrKeyNotFound {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return nil, err
+			}
+			unspent = append(unspent, UnspentOutput{[]byte(txID), outIdx, deserializeOutput(value)})
+		}
+	}
+
+	return unspent, nil
+}
+
+// Balance returns the value of the confirmed unspent outputs that can be
+// unlocked by pubKeyHash.
+func (s *Snapshot) Balance(pubKeyHash []byte) (units.Amount, error) {
+	unspent, err := s.UnspentOutputs(pubKeyHash)
+	if err != nil {
+		return 0, err
+	}
+
+	var balance units.Amount
+	for _, u := range unspent {
+		balance += u.Output.Value
+	}
+	return balance, nil
+}
+
+// History finds every confirmed transaction that pays to or spends from
+// pubKeyHash, oldest first, using the address index. Transactions in pruned
+// blocks are left out.
+func (s *Snapshot) History(pubKeyHash []byte) ([]HistoryEntry, error) {
+	var history []HistoryEntry
+
+	entries, err := readAddrIndex(s.txn, pubKeyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// group the indexed transactions by block
+	byBlock := make(map[string]map[string]addrEntry)
+	var heights []int
+	blockAt := make(map[int][]byte)
+	for txID, e := range entries {
+		if byBlock[string(e.BlockHash)] == nil {
+			byBlock[string(e.BlockHash)] = make(map[string]addrEntry)
+			heights = append(heights, e.Height)
+			blockAt[e.Height] = e.BlockHash
+		}
+		byBlock[string(e.BlockHash)][txID] = e
+	}
+	sort.Ints(heights)
+	indexed, err := spentIndexed(s.txn)
+	if err != nil {
+		return nil, err
+	}
+
+	// load each block once, adding its transactions in block order
+	for _, height := range heights {
+		block, err := s.GetBlock(blockAt[height])
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range block.Transactions {
+			e, ok := byBlock[string(block.Hash)][string(tx.ID)]
+			if !ok {
+				continue
+			}
+			entry := HistoryEntry{
+				Tx:            tx,
+				BlockHash:     block.Hash,
+				Height:        block.Height,
+				Confirmations: s.Height() - block.Height + 1,
+				Timestamp:     block.Timestamp,
+				Received:      e.Received,
+				Sent:          e.Sent,
+			}
+
+			// link the outputs of the address to where they were spent
+			if indexed {
+				entry.Spends = make(map[int]*Spend)
+				for _, out := range e.Outputs {
+					spend, err := getSpend(s.txn, tx.ID, out)
+					if err != nil {
+						return nil, err
+					}
+					if spend != nil {
+						entry.Spends[out] = spend
+					}
+				}
+			}
+			history = append(history, entry)
+		}
+	}
+
+	return history, nil
+}
@@ -0,0 +1,205 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+var (
+	// spentPrefix is the key prefix for the spent index, which maps an
+	// output spent in the best chain to the Spend of it. Entries are kept
+	// when blocks are pruned.
+	spentPrefix = []byte("spent-")
+
+	// spentTipKey holds the hash of the block the spent index reflects. The
+	// index is only kept up to date while the key exists.
+	spentTipKey = []byte("spenttip")
+)
+
+// ErrNoSpentIndex is returned when looking up spends in a chain opened
+// without Config.SpentIndex.
+var ErrNoSpentIndex = errors.New("spent index is not enabled")
+
+// Spend is the input of a transaction in the best chain spending an output.
+type Spend struct {
+	TxID      []byte
+	Input     int
+	BlockHash []byte
+	Height    int
+}
+
+// spentKey returns the db key for the spent index entry of an output.
+func spentKey(txID []byte, out int) []byte {
+	key := append(append([]byte{}, spentPrefix...), txID...)
+	return append(key, ToBytes(int64(out))...)
+}
+
+// spentIndexed reports whether the spent index is kept in txn.
+func spentIndexed(txn *badger.Txn) (bool, error) {
+	_, err := txn.Get(spentTipKey)
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// indexSpends adds the outputs spent by a block being connected to the
+// spent index, if it is kept.
+func indexSpends(txn *badger.Txn, block *Block) error {
+	if indexed, err := spentIndexed(txn); err != nil || !indexed {
+		return err
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+		for i, in := range tx.Inputs {
+			var buffer bytes.Buffer
+			spend := Spend{TxID: tx.ID, Input: i, BlockHash: block.Hash, Height: block.Height}
+			if err := gob.NewEncoder(&buffer).Encode(spend); err != nil {
+				return err
+			}
+			if err := txn.Set(spentKey(in.ID, in.Out), buffer.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+
+	// record the new tip of the spent index
+	return txn.Set(spentTipKey, block.Hash)
+}
+
+// unindexSpends removes the outputs spent by a block being disconnected
+// from the spent index, if it is kept.
+func unindexSpends(txn *badger.Txn, block *Block) error {
+	if indexed, err := spentIndexed(txn); err != nil || !indexed {
+		return err
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, in := range tx.Inputs {
+			if err := txn.Delete(spentKey(in.ID, in.Out)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// move the tip of the spent index back
+	return txn.Set(spentTipKey, block.PrevHash)
+}
+
+// getSpend returns the spend of an output from the spent index, or nil if
+// the output is not spent in the best chain.
+func getSpend(txn *badger.Txn, txID []byte, out int) (*Spend, error) {
+	if indexed, err := spentIndexed(txn); err != nil {
+		return nil, err
+	} else if !indexed {
+		return nil, ErrNoSpentIndex
+	}
+
+	item, err := txn.Get(spentKey(txID, out))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var spend Spend
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&spend); err != nil {
+		return nil, errors.New("unable to read spent index - " + err.Error())
+	}
+	return &spend, nil
+}
+
+// SpentBy returns the spend of the output out of the transaction txID in
+// the best chain, or nil if the output is not spent. It returns
+// ErrNoSpentIndex if the chain doesn't keep the spent index.
+func (bc *BlockChain) SpentBy(txID Hash, out int) (*Spend, error) {
+	var spend *Spend
+
+	// initiate read only transaction on db to get the spend
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		var err error
+		spend, err = getSpend(txn, txID.Bytes(), out)
+		return err
+	})
+
+	return spend, err
+}
+
+// spentIndexTip returns the hash of the block the spent index reflects, or
+// nil if the index is not kept.
+func (bc *BlockChain) spentIndexTip() ([]byte, error) {
+	var tip []byte
+
+	// initiate read only transaction on db to get the tip
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(spentTipKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		tip, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New("unable to read spent index tip - " + err.Error())
+	}
+
+	return tip, nil
+}
+
+// ReindexSpends rebuilds the spent index from the blocks in the best chain
+// and keeps it up to date from then on. The spends in pruned blocks can't
+// be indexed and are left out.
+func (bc *BlockChain) ReindexSpends() error {
+
+	// remove the existing spent index
+	if err := bc.deletePrefix(spentPrefix); err != nil {
+		return err
+	}
+
+	// index each block from genesis forward, one db transaction per block
+	for height := 0; height <= bc.Height(); height++ {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			return err
+		}
+		err = bc.DB.Update(func(txn *badger.Txn) error {
+			if block.Pruned() {
+				return txn.Set(spentTipKey, block.Hash)
+			}
+			if err := txn.Set(spentTipKey, block.PrevHash); err != nil {
+				return err
+			}
+			return indexSpends(txn, block)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to index spends of block %x - %s", block.Hash, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// dropSpentIndex removes the spent index, which is no longer kept.
+func (bc *BlockChain) dropSpentIndex() error {
+	if err := bc.deletePrefix(spentPrefix); err != nil {
+		return err
+	}
+	return bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(spentTipKey)
+	})
+}
@@ -0,0 +1,60 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestSpentIndex(t *testing.T) {
+	bc := newTestChainWithConfig(t, Config{SpentIndex: true})
+	genesis := tip(t, bc)
+	allocation := genesis.Transactions[0]
+
+	// alice spends the genesis allocation
+	tx := send(t, bc, alice, bob, units.Coin, 0)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	block := minePending(t, bc, carol)
+	spend, err := bc.SpentBy(hashOf(t, allocation.ID), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Spend{TxID: tx.ID, Input: 0, BlockHash: block.Hash, Height: 1}
+	if spend == nil || !bytes.Equal(spend.TxID, want.TxID) || !bytes.Equal(spend.BlockHash, want.BlockHash) || spend.Height != want.Height {
+		t.Fatalf("got spend %+v, want %+v", spend, want)
+	}
+	if spend, err := bc.SpentBy(hashOf(t, tx.ID), 0); err != nil || spend != nil {
+		t.Fatalf("got spend %+v, %v of an unspent output", spend, err)
+	}
+
+	// the history of alice links the allocation to its spend
+	history, err := bc.History(wallet.GeneratePublicKeyHash(alice.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) == 0 || history[0].Spends[0] == nil || !bytes.Equal(history[0].Spends[0].TxID, tx.ID) {
+		t.Fatalf("got history %+v, want the allocation spent by %x", history, tx.ID)
+	}
+
+	// a reorg away from the block unspends the allocation
+	fork := mineOn(t, bc, genesis, carol)
+	for _, b := range []*Block{fork, mineOn(t, bc, fork, carol)} {
+		if err := bc.AcceptBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if spend, err := bc.SpentBy(hashOf(t, allocation.ID), 0); err != nil || spend != nil {
+		t.Fatalf("got spend %+v, %v after the reorg", spend, err)
+	}
+}
+
+func TestSpentIndexDisabled(t *testing.T) {
+	bc := newTestChain(t)
+	if _, err := bc.SpentBy(hashOf(t, tip(t, bc).Transactions[0].ID), 0); err != ErrNoSpentIndex {
+		t.Fatalf("got %v, want ErrNoSpentIndex", err)
+	}
+}
@@ -0,0 +1,244 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// DefaultStatsWindow is the number of blocks ChainStats averages the block
+// interval and hash rate over unless asked for another.
+const DefaultStatsWindow = 100
+
+var (
+	// statsPrefix is the key prefix for the running totals of the blocks in
+	// the best chain, indexed by height.
+	statsPrefix = []byte("chainstats-")
+
+	// statsTipKey holds the hash of the block the running totals reflect.
+	statsTipKey = []byte("chainstatstip")
+)
+
+// statsKey returns the db key for the running totals up to the block at a
+// height.
+func statsKey(height int) []byte {
+	return append(append([]byte{}, statsPrefix...), ToBytes(int64(height))...)
+}
+
+// statsPoint holds the running totals of the best chain up to a block,
+// recorded as it is connected so statistics don't walk the chain. Supply is
+// the value of the UTXO set. Partial is set if a block before it was
+// pruned when the totals were reindexed, leaving it out of them.
+type statsPoint struct {
+	Transactions int64
+	Supply       units.Amount
+	Partial      bool
+}
+
+// getStatsPoint reads the running totals up to the block at a height.
+func getStatsPoint(txn *badger.Txn, height int) (statsPoint, error) {
+	var point statsPoint
+	item, err := txn.Get(statsKey(height))
+	if err != nil {
+		return point, err
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return point, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&point); err != nil {
+		return point, errors.New("unable to decode chain stats - " + err.Error())
+	}
+	return point, nil
+}
+
+// recordStats adds a block being connected, whose undo record holds spent,
+// to the running totals of its parent. Pruned blocks are left out.
+func recordStats(txn *badger.Txn, block *Block, spent []spentOutput) error {
+	var point statsPoint
+	if len(block.PrevHash) != 0 {
+		var err error
+		if point, err = getStatsPoint(txn, block.Height-1); err != nil {
+			return errors.New("unable to read parent chain stats - " + err.Error())
+		}
+	}
+
+	if block.Pruned() {
+		point.Partial = true
+	}
+	point.Transactions += int64(len(block.Transactions))
+	for _, tx := range block.Transactions {
+		for _, out := range tx.Outputs {
+			point.Supply += out.Value
+		}
+	}
+	for _, s := range spent {
+		point.Supply -= s.Output.Value
+	}
+
+	if err := putStatsPoint(txn, block.Height, point); err != nil {
+		return err
+	}
+	return txn.Set(statsTipKey, block.Hash)
+}
+
+// putStatsPoint stores the running totals up to the block at a height.
+func putStatsPoint(txn *badger.Txn, height int, point statsPoint) error {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(point); err != nil {
+		return errors.New("unable to encode chain stats - " + err.Error())
+	}
+	return txn.Set(statsKey(height), buffer.Bytes())
+}
+
+// removeStats removes the running totals up to a block being disconnected.
+func removeStats(txn *badger.Txn, block *Block) error {
+	if err := txn.Delete(statsKey(block.Height)); err != nil {
+		return err
+	}
+	return txn.Set(statsTipKey, block.PrevHash)
+}
+
+// statsTip returns the hash of the block the running totals reflect, or nil
+// if they have not been recorded.
+func (bc *BlockChain) statsTip() ([]byte, error) {
+	var tip []byte
+
+	// initiate read only transaction on db to get the tip
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(statsTipKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		tip, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New("unable to read chain stats tip - " + err.Error())
+	}
+
+	return tip, nil
+}
+
+// ReindexStats records the running totals of the blocks in the best chain
+// again. Pruned blocks are no longer known and are left out of them.
+func (bc *BlockChain) ReindexStats() error {
+	if err := bc.deletePrefix(statsPrefix); err != nil {
+		return err
+	}
+
+	// record each block from genesis forward, one db transaction per block
+	return bc.IterateForward(func(block *Block) error {
+		err := bc.DB.Update(func(txn *badger.Txn) error {
+			var spent []spentOutput
+			if !block.Pruned() {
+				var err error
+				if spent, err = getUndo(txn, block.Hash); err != nil {
+					return err
+				}
+			}
+			return recordStats(txn, block, spent)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to record chain stats of block %x - %s", block.Hash, err.Error())
+		}
+		return nil
+	})
+}
+
+// ChainStats summarizes the best chain. Difficulty is that of the tip, and
+// Interval and HashRate, in seconds and hashes per second, are averaged
+// over the last Window blocks. Partial is set if pruned blocks are left out
+// of Transactions and Supply.
+type ChainStats struct {
+	Height       int          `json:"height"`
+	Tip          string       `json:"tip"`
+	Transactions int64        `json:"transactions"`
+	Supply       units.Amount `json:"supply"`
+	Difficulty   int          `json:"difficulty"`
+	Interval     float64      `json:"interval"`
+	HashRate     float64      `json:"hashRate"`
+	Window       int          `json:"window"`
+	Partial      bool         `json:"partial,omitempty"`
+}
+
+// ChainStats returns statistics of the best chain, averaging the block
+// interval and hash rate over the last window blocks. It reads the running
+// totals recorded as blocks were connected and the tip and the block window
+// blocks below it, so it doesn't walk the chain.
+func (bc *BlockChain) ChainStats(window int) (ChainStats, error) {
+	if window <= 0 {
+		return ChainStats{}, fmt.Errorf("window of %d blocks is not positive", window)
+	}
+
+	var stats ChainStats
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		tip, err := getBlock(txn, bc.Tip())
+		if err != nil {
+			return err
+		}
+		point, err := getStatsPoint(txn, tip.Height)
+		if err != nil {
+			return err
+		}
+		stats = ChainStats{
+			Height:       tip.Height,
+			Tip:          hex.EncodeToString(tip.Hash),
+			Transactions: point.Transactions,
+			Supply:       point.Supply,
+			Difficulty:   tip.Difficulty,
+			Partial:      point.Partial,
+		}
+		if tip.Height == 0 {
+			return nil
+		}
+
+		// average over the blocks after the one window blocks below the tip
+		if window > tip.Height {
+			window = tip.Height
+		}
+		stats.Window = window
+		item, err := txn.Get(heightKey(tip.Height - window))
+		if err != nil {
+			return err
+		}
+		startHash, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		start, err := getBlock(txn, startHash)
+		if err != nil {
+			return err
+		}
+		elapsed := tip.Timestamp - start.Timestamp
+		stats.Interval = float64(elapsed) / float64(window)
+		if elapsed <= 0 {
+			return nil
+		}
+
+		// the hash rate is the work of the blocks over the time they took
+		tipWork, err := getChainWork(txn, tip.Hash)
+		if err != nil {
+			return err
+		}
+		startWork, err := getChainWork(txn, start.Hash)
+		if err != nil {
+			return err
+		}
+		work, _ := new(big.Float).SetInt(new(big.Int).Sub(tipWork, startWork)).Float64()
+		stats.HashRate = work / float64(elapsed)
+		return nil
+	})
+	if err != nil {
+		return ChainStats{}, errors.New("unable to read chain stats - " + err.Error())
+	}
+	return stats, nil
+}
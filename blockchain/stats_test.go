@@ -0,0 +1,72 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestChainStats(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+	if _, err := bc.ChainStats(0); err == nil {
+		t.Fatal("got stats over an empty window")
+	}
+	start, err := bc.ChainStats(DefaultStatsWindow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start.Height != 0 || start.Transactions != int64(len(genesis.Transactions)) || start.Supply <= 0 || start.HashRate != 0 {
+		t.Fatalf("got stats %+v of the genesis block", start)
+	}
+
+	// fees move coins to the miner, so the supply grows by the subsidy
+	if err := bc.AddToMempool(send(t, bc, alice, bob, 10, 400)); err != nil {
+		t.Fatal(err)
+	}
+	mined := minePending(t, bc, alice)
+	stats, err := bc.ChainStats(DefaultStatsWindow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ChainStats{
+		Height:       1,
+		Tip:          hex.EncodeToString(mined.Hash),
+		Transactions: start.Transactions + 2,
+		Supply:       start.Supply + Subsidy,
+		Difficulty:   mined.Difficulty,
+		Interval:     float64(mined.Timestamp - genesis.Timestamp),
+		Window:       1,
+	}
+	if elapsed := mined.Timestamp - genesis.Timestamp; elapsed > 0 {
+		work, _ := mined.Work().Float64()
+		want.HashRate = work / float64(elapsed)
+	}
+	if stats != want {
+		t.Fatalf("got stats %+v, want %+v", stats, want)
+	}
+
+	// the totals of a disconnected block are removed by a reorg
+	side1 := mineOn(t, bc, genesis, carol)
+	if err := bc.AcceptBlock(side1); err != nil {
+		t.Fatal(err)
+	}
+	side2 := mineOn(t, bc, side1, carol)
+	if err := bc.AcceptBlock(side2); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = bc.ChainStats(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Height != 2 || stats.Transactions != start.Transactions+2 || stats.Supply != start.Supply+2*Subsidy || stats.Window != 1 {
+		t.Fatalf("got stats %+v after the reorg", stats)
+	}
+
+	// reindexing records the same totals
+	if err := bc.ReindexStats(); err != nil {
+		t.Fatal(err)
+	}
+	if reindexed, err := bc.ChainStats(1); err != nil || reindexed != stats {
+		t.Fatalf("got stats %+v, %v after reindexing, want %+v", reindexed, err, stats)
+	}
+}
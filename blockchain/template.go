@@ -0,0 +1,142 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// NonceSize is the size in bytes of the big endian nonce in a block header.
+const NonceSize = 8
+
+// Header returns the bytes hashed by the proof of work with a zero nonce,
+// and the offset of the nonce in them. External miners can write each
+// nonce at the offset and compare the proof of work hash of the header
+// with the target without reimplementing InitData.
+func (pow *ProofOfWork) Header() ([]byte, int) {
+	return pow.InitData(0), len(pow.Block.PrevHash) + sha256.Size
+}
+
+// BlockTemplate describes a block for a miner to build on the tip of the
+// chain: the block it extends, the target its hash must be below, the
+// pending transactions selected for it with their fees, and the coinbase
+// collecting them. Block is the template block itself.
+type BlockTemplate struct {
+	Block *Block `json:"-"`
+
+	PrevHash     string                `json:"prevHash"`
+	Height       int                   `json:"height"`
+	Timestamp    int64                 `json:"timestamp"`
+	Difficulty   int                   `json:"difficulty"`
+	Target       string                `json:"target"`
+	Coinbase     TemplateCoinbase      `json:"coinbase"`
+	Transactions []TemplateTransaction `json:"transactions"`
+}
+
+// TemplateCoinbase is the coinbase of a block template, paying Subsidy and
+// Fees to Address. Hex is the serialized transaction.
+type TemplateCoinbase struct {
+	TxID    string       `json:"txid"`
+	Address string       `json:"address"`
+	Subsidy units.Amount `json:"subsidy"`
+	Fees    units.Amount `json:"fees"`
+	Hex     string       `json:"hex"`
+}
+
+// TemplateTransaction is a pending transaction selected for a block
+// template.
+type TemplateTransaction struct {
+	TxID string       `json:"txid"`
+	Fee  units.Amount `json:"fee"`
+	Size int          `json:"size"`
+}
+
+// GetBlockTemplate returns a template for an unmined block on the tip of
+// the chain holding the transactions in the mempool that fit in a block,
+// with a coinbase transaction rewarding minerAddress. Once a nonce is found
+// the block can be added with SubmitBlock. Proof of authority networks
+// have no templates and return ErrSealedBlocks.
+func (bc *BlockChain) GetBlockTemplate(minerAddress string) (*BlockTemplate, error) {
+	if bc.mining.authorities != nil {
+		return nil, ErrSealedBlocks
+	}
+	if !keys.ValidateAddress(minerAddress) {
+		return nil, fmt.Errorf("miner address %s is not valid", minerAddress)
+	}
+	tip, err := bc.GetBlock(bc.Tip())
+	if err != nil {
+		return nil, err
+	}
+	timestamp := bc.now().Unix()
+	assembly := bc.assembleBlock(minerAddress)
+	txs := assembly.transactions
+
+	// the block is returned without a hash or nonce
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:    bc.mining.version(),
+			Hash:       []byte{},
+			PrevHash:   tip.Hash,
+			MerkleRoot: merkleRoot(txs),
+			Nonce:      0,
+			Height:     tip.Height + 1,
+			Timestamp:  timestamp,
+			Difficulty: bc.NextDifficulty(tip, timestamp),
+		},
+		Transactions: txs,
+	}
+	template := &BlockTemplate{
+		Block:        block,
+		PrevHash:     hex.EncodeToString(tip.Hash),
+		Height:       block.Height,
+		Timestamp:    timestamp,
+		Difficulty:   block.Difficulty,
+		Target:       fmt.Sprintf("%064x", NewProof(block).Target),
+		Transactions: []TemplateTransaction{},
+	}
+
+	// describe the selected transactions and the coinbase collecting their
+	// fees
+	coinbase := txs[0]
+	template.Coinbase = TemplateCoinbase{
+		TxID:    hex.EncodeToString(coinbase.ID),
+		Address: minerAddress,
+		Hex:     hex.EncodeToString(coinbase.Serialize()),
+	}
+	for _, tx := range txs[1:] {
+		fee := assembly.fees[string(tx.ID)]
+		template.Coinbase.Fees += fee
+		template.Transactions = append(template.Transactions, TemplateTransaction{
+			TxID: hex.EncodeToString(tx.ID),
+			Fee:  fee,
+			Size: len(tx.Serialize()),
+		})
+	}
+	for _, out := range coinbase.Outputs {
+		template.Coinbase.Subsidy += out.Value
+	}
+	template.Coinbase.Subsidy -= template.Coinbase.Fees
+
+	return template, nil
+}
+
+// NewBlockTemplate returns the block of GetBlockTemplate.
+func (bc *BlockChain) NewBlockTemplate(minerAddress string) (*Block, error) {
+	template, err := bc.GetBlockTemplate(minerAddress)
+	if err != nil {
+		return nil, err
+	}
+	return template.Block, nil
+}
+
+// SubmitBlock adds a block created by NewBlockTemplate that was mined with
+// nonce to the chain.
+func (bc *BlockChain) SubmitBlock(block *Block, nonce int) error {
+	block.Nonce = nonce
+	block.Hash = NewProof(block).Sum(nonce)
+
+	return bc.AcceptBlock(block)
+}
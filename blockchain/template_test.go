@@ -0,0 +1,45 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestGetBlockTemplate(t *testing.T) {
+	bc := newTestChain(t)
+	tx := send(t, bc, alice, bob, 10*units.Coin, 3)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the template selects the pending transaction and collects its fee
+	template, err := bc.GetBlockTemplate(carol.Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.PrevHash != hex.EncodeToString(bc.Tip()) || template.Height != 1 {
+		t.Fatalf("got template on %s at height %d, want the tip", template.PrevHash, template.Height)
+	}
+	if len(template.Transactions) != 1 || template.Transactions[0].TxID != hex.EncodeToString(tx.ID) || template.Transactions[0].Fee != 3 {
+		t.Fatalf("got transactions %+v, want the pending one", template.Transactions)
+	}
+	if template.Coinbase.Subsidy != Subsidy || template.Coinbase.Fees != 3 {
+		t.Fatalf("got coinbase %+v, want the subsidy and fee", template.Coinbase)
+	}
+
+	// the mined template is accepted
+	block := template.Block
+	if err := block.mine(context.Background(), miningOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.SubmitBlock(block, block.Nonce); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bc.Tip(), block.Hash) || len(bc.MempoolTransactions()) != 0 {
+		t.Fatal("submitted template did not become the tip")
+	}
+}
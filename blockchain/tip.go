@@ -0,0 +1,25 @@
+package blockchain
+
+import "errors"
+
+// ErrTipChanged is returned when a block mined on the tip is not added
+// because another block became the tip while it was mined. Its
+// transactions stay in the mempool to be mined on the new tip.
+var ErrTipChanged = errors.New("tip changed while the block was mined")
+
+// Tip returns the hash of the last block of the best chain. It is safe to
+// call while the chain is changed, and the returned hash is not modified
+// afterwards.
+func (bc *BlockChain) Tip() []byte {
+	bc.tipMutex.RLock()
+	defer bc.tipMutex.RUnlock()
+	return bc.tip
+}
+
+// setTip makes the block with hash the tip once it has been stored as the
+// last hash. The caller must hold bc.mutex.
+func (bc *BlockChain) setTip(hash []byte) {
+	bc.tipMutex.Lock()
+	defer bc.tipMutex.Unlock()
+	bc.tip = hash
+}
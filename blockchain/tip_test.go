@@ -0,0 +1,106 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// TestConcurrentUse mines, accepts transactions and reads the chain from
+// several goroutines, which go test -race checks for data races.
+func TestConcurrentUse(t *testing.T) {
+	bc := newTestChain(t)
+	var writers, readers sync.WaitGroup
+	done := make(chan struct{})
+	errs := make(chan error, 100)
+
+	// two miners race to extend the tip, and one of them may lose
+	var mutex sync.Mutex
+	mined := 0
+	for i := 0; i < 2; i++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for j := 0; j < 5; j++ {
+				_, err := bc.MinePending(carol.Address().String())
+				if err == ErrTipChanged {
+					continue
+				} else if err != nil {
+					errs <- err
+					return
+				}
+				mutex.Lock()
+				mined++
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	// alice pays bob while blocks are mined
+	writers.Add(1)
+	go func() {
+		defer writers.Done()
+		for j := 0; j < 5; j++ {
+			tx, err := bc.NewTransaction(alice, bob.Address().String(), units.Coin, 0, "")
+			if err != nil {
+				errs <- err
+				return
+			}
+			bc.AddToMempool(tx)
+		}
+	}()
+
+	// readers always see a stored tip with a complete chain below it
+	for i := 0; i < 2; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				block, err := bc.GetBlock(bc.Tip())
+				if err != nil {
+					errs <- err
+					return
+				}
+				blocks := 0
+				iter := bc.NewIterator()
+				for {
+					b := iter.Next()
+					blocks++
+					if len(b.PrevHash) == 0 {
+						break
+					}
+				}
+				if blocks < block.Height+1 {
+					errs <- fmt.Errorf("read %d blocks below a tip at height %d", blocks, block.Height)
+					return
+				}
+				bc.MempoolTransactions()
+				bc.Height()
+			}
+		}()
+	}
+
+	writers.Wait()
+	close(done)
+	readers.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// every block that was mined extends the chain, which is consistent
+	if bc.Height() != mined || mined == 0 {
+		t.Fatalf("chain has height %d after mining %d blocks", bc.Height(), mined)
+	}
+	if _, err := bc.AuditChain(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+}
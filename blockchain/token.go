@@ -0,0 +1,495 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// Tokens are assets issued and transferred on top of transactions. A
+// transaction issues a token, identified by the id of the transaction, with
+// a data output recording its supply, which is carried by the first other
+// output of the transaction. A transfer spends outputs carrying a token
+// and allocates their amount to its outputs with a data output. Tokens
+// spent by a transaction that doesn't allocate them, or by an invalid
+// transfer, are burned, so the mempool rejects such transactions.
+const (
+	// TokenCarrierValue is the value of the outputs created to carry
+	// tokens.
+	TokenCarrierValue units.Amount = 1000
+
+	// MaxTokenTransfers is the most outputs a transfer can allocate a
+	// token to, as its data output is limited to MaxDataSize.
+	MaxTokenTransfers = 4
+)
+
+// tokenMagic starts the data of the data outputs of the token protocol,
+// followed by tokenIssue and the supply, or tokenTransfer, the token id
+// and the allocations.
+var tokenMagic = []byte("GCT1")
+
+const (
+	tokenIssue    = 'I'
+	tokenTransfer = 'T'
+)
+
+var (
+	// tokenPrefix is the key prefix for the token index, which maps an
+	// unspent output of the best chain carrying a token to its tokenEntry.
+	tokenPrefix = []byte("token-")
+
+	// tokenUndoPrefix is the key prefix for the token undo records, which
+	// hold the token entries of the outputs spent by a block.
+	tokenUndoPrefix = []byte("tokenundo-")
+)
+
+// TokenTransfer allocates Amount of a token to the output at index Out.
+type TokenTransfer struct {
+	Out    int
+	Amount int64
+}
+
+// tokenRecord is the token protocol data of a transaction.
+type tokenRecord struct {
+	Issue     bool
+	Supply    int64
+	TokenID   Hash
+	Transfers []TokenTransfer
+}
+
+// tokenEntry is the amount of a token carried by an output.
+type tokenEntry struct {
+	TokenID Hash
+	Amount  int64
+}
+
+// spentToken is the token entry of an output spent by a block, kept so the
+// block can be disconnected.
+type spentToken struct {
+	TxID  []byte
+	Out   int
+	Entry tokenEntry
+}
+
+// tokenKey returns the db key for the token index entry of an output.
+func tokenKey(txID []byte, out int) []byte {
+	key := append(append([]byte{}, tokenPrefix...), txID...)
+	return append(key, ToBytes(int64(out))...)
+}
+
+// tokenUndoKey returns the db key for the token undo record of a block.
+func tokenUndoKey(hash []byte) []byte {
+	return append(append([]byte{}, tokenUndoPrefix...), hash...)
+}
+
+// NewTokenIssueOutput returns the data output of a transaction issuing
+// supply of a new token.
+func NewTokenIssueOutput(supply int64) (*TxOutput, error) {
+	if supply <= 0 {
+		return nil, errors.New("token supply is not positive")
+	}
+	data := append(append([]byte{}, tokenMagic...), tokenIssue)
+	return NewDataOutput(append(data, ToBytes(supply)...))
+}
+
+// NewTokenTransferOutput returns the data output of a transaction
+// allocating a token to its outputs.
+func NewTokenTransferOutput(tokenID Hash, transfers []TokenTransfer) (*TxOutput, error) {
+	if len(transfers) == 0 || len(transfers) > MaxTokenTransfers {
+		return nil, fmt.Errorf("transfer allocates to %d outputs, not 1 to %d", len(transfers), MaxTokenTransfers)
+	}
+	data := append(append([]byte{}, tokenMagic...), tokenTransfer)
+	data = append(data, tokenID.Bytes()...)
+	for _, t := range transfers {
+		if t.Out < 0 || t.Out > 255 {
+			return nil, fmt.Errorf("transfer to output %d is out of range", t.Out)
+		}
+		data = append(append(data, byte(t.Out)), ToBytes(t.Amount)...)
+	}
+	return NewDataOutput(data)
+}
+
+// tokenRecord returns the token protocol data of the first data output of
+// a transaction that has any. found is false if the transaction has none,
+// and an error is returned if the data is malformed.
+func (tx *Transaction) tokenRecord() (rec *tokenRecord, found bool, err error) {
+	for _, out := range tx.Outputs {
+		data := out.Data()
+		if !bytes.HasPrefix(data, tokenMagic) {
+			continue
+		}
+		data = data[len(tokenMagic):]
+		switch {
+		case len(data) == 9 && data[0] == tokenIssue:
+			return &tokenRecord{Issue: true, Supply: int64(binary.BigEndian.Uint64(data[1:]))}, true, nil
+		case len(data) > 1+HashLength && data[0] == tokenTransfer && (len(data)-1-HashLength)%9 == 0:
+			rec := &tokenRecord{}
+			copy(rec.TokenID[:], data[1:1+HashLength])
+			for t := data[1+HashLength:]; len(t) > 0; t = t[9:] {
+				rec.Transfers = append(rec.Transfers, TokenTransfer{int(t[0]), int64(binary.BigEndian.Uint64(t[1:9]))})
+			}
+			return rec, true, nil
+		}
+		return nil, true, errors.New("token data is malformed")
+	}
+	return nil, false, nil
+}
+
+// tokenEffects returns the token entries of the outputs of a transaction
+// spending outputs carrying the entries in inputs, which are zero for
+// inputs carrying no token. burned is set if tokens of the inputs are not
+// carried on, and err describes why the token data of the transaction is
+// invalid, in which case every token of the inputs is burned.
+func tokenEffects(tx *Transaction, inputs []tokenEntry) (outputs map[int]tokenEntry, burned bool, err error) {
+	outputs = make(map[int]tokenEntry)
+	var carried bool
+	for _, in := range inputs {
+		carried = carried || in.Amount > 0
+	}
+
+	rec, found, err := tx.tokenRecord()
+	if err != nil || !found {
+		return outputs, carried, err
+	}
+
+	// the supply of an issuance goes to its first output that is not data
+	if rec.Issue {
+		if rec.Supply <= 0 {
+			return outputs, carried, errors.New("token supply is not positive")
+		}
+		for outIdx, out := range tx.Outputs {
+			if !out.IsData() {
+				id, err := HashFromBytes(tx.ID)
+				if err != nil {
+					return map[int]tokenEntry{}, carried, err
+				}
+				outputs[outIdx] = tokenEntry{id, rec.Supply}
+				return outputs, carried, nil
+			}
+		}
+		return outputs, carried, errors.New("token issuance has no output to carry the supply")
+	}
+
+	// a transfer allocates at most the amount of the token in its inputs
+	var available, allocated int64
+	for _, in := range inputs {
+		if in.Amount > 0 && in.TokenID == rec.TokenID {
+			available += in.Amount
+		} else if in.Amount > 0 {
+			burned = true
+		}
+	}
+	for _, t := range rec.Transfers {
+		if t.Out >= len(tx.Outputs) || tx.Outputs[t.Out].IsData() {
+			return map[int]tokenEntry{}, carried, fmt.Errorf("token transfer to output %d, which can't carry it", t.Out)
+		}
+		if _, ok := outputs[t.Out]; ok || t.Amount <= 0 {
+			return map[int]tokenEntry{}, carried, fmt.Errorf("token transfer to output %d is not positive or repeated", t.Out)
+		}
+		if t.Amount > available-allocated {
+			return map[int]tokenEntry{}, carried, fmt.Errorf("token transfer allocates more than the %d tokens in its inputs", available)
+		}
+		outputs[t.Out] = tokenEntry{rec.TokenID, t.Amount}
+		allocated += t.Amount
+	}
+	return outputs, burned || allocated < available, nil
+}
+
+// getTokenEntry reads the token entry of an unspent output of the best
+// chain from txn. found is false if the output carries no token.
+func getTokenEntry(txn *badger.Txn, txID []byte, out int) (entry tokenEntry, found bool, err error) {
+	item, err := txn.Get(tokenKey(txID, out))
+	if err == badger.ErrKeyNotFound {
+		return tokenEntry{}, false, nil
+	} else if err != nil {
+		return tokenEntry{}, false, err
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return tokenEntry{}, false, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&entry); err != nil {
+		return tokenEntry{}, false, errors.New("unable to read token index - " + err.Error())
+	}
+	return entry, true, nil
+}
+
+// putTokenEntry stores the token entry of an output in txn.
+func putTokenEntry(txn *badger.Txn, txID []byte, out int, entry tokenEntry) error {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(entry); err != nil {
+		return err
+	}
+	return txn.Set(tokenKey(txID, out), buffer.Bytes())
+}
+
+// indexTokens updates the token index for a block being connected,
+// storing the entries of the outputs it spends so it can be disconnected.
+func indexTokens(txn *badger.Txn, block *Block) error {
+	var spent []spentToken
+
+	for _, tx := range block.Transactions {
+		inputs := make([]tokenEntry, len(tx.Inputs))
+		if !tx.IsCoinbase() {
+			for i, in := range tx.Inputs {
+				entry, found, err := getTokenEntry(txn, in.ID, in.Out)
+				if err != nil {
+					return err
+				}
+				if !found {
+					continue
+				}
+				inputs[i] = entry
+				spent = append(spent, spentToken{in.ID, in.Out, entry})
+				if err := txn.Delete(tokenKey(in.ID, in.Out)); err != nil {
+					return err
+				}
+			}
+		}
+
+		// invalid token data burns the tokens of the inputs
+		outputs, _, _ := tokenEffects(tx, inputs)
+		for outIdx, entry := range outputs {
+			if err := putTokenEntry(txn, tx.ID, outIdx, entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(spent) == 0 {
+		return nil
+	}
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(spent); err != nil {
+		return err
+	}
+	return txn.Set(tokenUndoKey(block.Hash), buffer.Bytes())
+}
+
+// unindexTokens reverts the token index changes made by a block being
+// disconnected.
+func unindexTokens(txn *badger.Txn, block *Block) error {
+	for _, tx := range block.Transactions {
+		for outIdx := range tx.Outputs {
+			if err := txn.Delete(tokenKey(tx.ID, outIdx)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// restore the entries of the outputs spent by the block
+	item, err := txn.Get(tokenUndoKey(block.Hash))
+	if err == badger.ErrKeyNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	var spent []spentToken
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&spent); err != nil {
+		return errors.New("unable to read token undo record - " + err.Error())
+	}
+	for _, s := range spent {
+		if err := putTokenEntry(txn, s.TxID, s.Out, s.Entry); err != nil {
+			return err
+		}
+	}
+	return txn.Delete(tokenUndoKey(block.Hash))
+}
+
+// pendingTokens returns the token entries of the outputs of pending
+// transactions, keyed by outpoint. Entries of the confirmed outputs they
+// spend are read from txn.
+func (bc *BlockChain) pendingTokens(txn *badger.Txn) (map[string]tokenEntry, error) {
+	entries := make(map[string]tokenEntry)
+	for _, tx := range bc.MempoolTransactions() {
+		inputs, err := inputTokens(txn, tx, entries)
+		if err != nil {
+			return nil, err
+		}
+		outputs, _, _ := tokenEffects(tx, inputs)
+		for outIdx, entry := range outputs {
+			entries[outpoint(tx.ID, outIdx)] = entry
+		}
+	}
+	return entries, nil
+}
+
+// inputTokens returns the token entries of the outputs spent by the inputs
+// of tx, from pending or the token index in txn.
+func inputTokens(txn *badger.Txn, tx *Transaction, pending map[string]tokenEntry) ([]tokenEntry, error) {
+	inputs := make([]tokenEntry, len(tx.Inputs))
+	if tx.IsCoinbase() {
+		return inputs, nil
+	}
+	for i, in := range tx.Inputs {
+		if entry, ok := pending[outpoint(in.ID, in.Out)]; ok {
+			inputs[i] = entry
+			continue
+		}
+		entry, _, err := getTokenEntry(txn, in.ID, in.Out)
+		if err != nil {
+			return nil, err
+		}
+		inputs[i] = entry
+	}
+	return inputs, nil
+}
+
+// checkTokens verifies that a transaction entering the mempool has valid
+// token data and does not burn tokens.
+func (bc *BlockChain) checkTokens(tx *Transaction) error {
+	return bc.DB.View(func(txn *badger.Txn) error {
+		pending, err := bc.pendingTokens(txn)
+		if err != nil {
+			return err
+		}
+		inputs, err := inputTokens(txn, tx, pending)
+		if err != nil {
+			return err
+		}
+		_, burned, err := tokenEffects(tx, inputs)
+		if err != nil {
+			return fmt.Errorf("transaction %x has invalid token data - %s", tx.ID, err.Error())
+		}
+		if burned {
+			return fmt.Errorf("transaction %x would burn tokens it spends", tx.ID)
+		}
+		return nil
+	})
+}
+
+// tokenOutputs returns the token entries of the outputs in unspent that
+// carry a token, confirmed or pending, keyed by outpoint.
+func (bc *BlockChain) tokenOutputs(unspent []UnspentOutput) (map[string]tokenEntry, error) {
+	entries := make(map[string]tokenEntry)
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		pending, err := bc.pendingTokens(txn)
+		if err != nil {
+			return err
+		}
+		for _, u := range unspent {
+			point := outpoint(u.TxID, u.Out)
+			if entry, ok := pending[point]; ok {
+				entries[point] = entry
+				continue
+			}
+			entry, found, err := getTokenEntry(txn, u.TxID, u.Out)
+			if err != nil {
+				return err
+			}
+			if found {
+				entries[point] = entry
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("unable to read token index - " + err.Error())
+	}
+	return entries, nil
+}
+
+// TokenBalances returns the amount of each token carried by the confirmed
+// unspent outputs that can be unlocked by pubKeyHash.
+func (s *Snapshot) TokenBalances(pubKeyHash []byte) (map[Hash]int64, error) {
+	unspent, err := s.UnspentOutputs(pubKeyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[Hash]int64)
+	for _, u := range unspent {
+		entry, found, err := getTokenEntry(s.txn, u.TxID, u.Out)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			balances[entry.TokenID] += entry.Amount
+		}
+	}
+	return balances, nil
+}
+
+// TokenBalance returns the amount of a token carried by the confirmed
+// unspent outputs that can be unlocked by pubKeyHash.
+func (bc *BlockChain) TokenBalance(pubKeyHash []byte, tokenID Hash) (int64, error) {
+	s, err := bc.Snapshot()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Discard()
+
+	balances, err := s.TokenBalances(pubKeyHash)
+	if err != nil {
+		return 0, errors.New("unable to read token balance - " + err.Error())
+	}
+	return balances[tokenID], nil
+}
+
+// NewTokenIssueTransaction creates a transaction from the address of
+// wallet w issuing supply of a new token to that address, paying fee. The
+// id of the token is the id of the transaction.
+func (bc *BlockChain) NewTokenIssueTransaction(w keys.Key, supply int64, fee units.Amount) (*Transaction, error) {
+	issue, err := NewTokenIssueOutput(supply)
+	if err != nil {
+		return nil, err
+	}
+	carrier := NewTXOutput(TokenCarrierValue, w.Address().String())
+	return bc.fundTransaction(w, []TxOutput{*carrier, *issue}, fee, "")
+}
+
+// NewTokenTransferTransaction creates a transaction sending amount of a
+// token from the address of wallet w to the to address, paying fee.
+// Tokens left over go back to the address of w.
+func (bc *BlockChain) NewTokenTransferTransaction(w keys.Key, tokenID Hash, to string, amount int64, fee units.Amount) (*Transaction, error) {
+	if !keys.ValidateAddress(to) {
+		return nil, fmt.Errorf("address %s is not valid", to)
+	}
+	if amount <= 0 {
+		return nil, errors.New("token amount is not positive")
+	}
+	unspent, err := bc.FindUnspentOutputs(keys.PublicKeyHash(w.PubKey()))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := bc.tokenOutputs(unspent)
+	if err != nil {
+		return nil, err
+	}
+
+	// select outputs carrying the token until they hold the amount
+	var carriers []UnspentOutput
+	var available int64
+	for _, u := range unspent {
+		if entry, ok := entries[outpoint(u.TxID, u.Out)]; ok && entry.TokenID == tokenID && available < amount {
+			carriers = append(carriers, u)
+			available += entry.Amount
+		}
+	}
+	if available < amount {
+		return nil, fmt.Errorf("not enough of token %s, %d of %d available", tokenID, available, amount)
+	}
+
+	// carry the amount to the recipient and the rest back to w
+	outputs := []TxOutput{*NewTXOutput(TokenCarrierValue, to)}
+	transfers := []TokenTransfer{{0, amount}}
+	if available > amount {
+		outputs = append(outputs, *NewTXOutput(TokenCarrierValue, w.Address().String()))
+		transfers = append(transfers, TokenTransfer{1, available - amount})
+	}
+	transfer, err := NewTokenTransferOutput(tokenID, transfers)
+	if err != nil {
+		return nil, err
+	}
+	return bc.fundTransactionWith(w, carriers, append(outputs, *transfer), fee, "")
+}
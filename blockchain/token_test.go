@@ -0,0 +1,138 @@
+package blockchain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// tokenBalance returns the confirmed amount of a token held by w.
+func tokenBalance(t *testing.T, bc *BlockChain, w *wallet.Wallet, tokenID Hash) int64 {
+	t.Helper()
+	amount, err := bc.TokenBalance(wallet.GeneratePublicKeyHash(w.PublicKey), tokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return amount
+}
+
+func TestTokens(t *testing.T) {
+	bc := newTestChain(t)
+
+	// alice issues a token
+	issue, err := bc.NewTokenIssueTransaction(alice, 1000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AddToMempool(issue); err != nil {
+		t.Fatal(err)
+	}
+	issued := minePending(t, bc, carol)
+	tokenID := hashOf(t, issue.ID)
+	if got := tokenBalance(t, bc, alice, tokenID); got != 1000 {
+		t.Fatalf("alice has %d tokens, want 1000", got)
+	}
+
+	// payments leave the outputs carrying tokens alone
+	payment := send(t, bc, alice, carol, balance(t, bc, alice)-TokenCarrierValue, 0)
+	for _, in := range payment.Inputs {
+		if bytes.Equal(in.ID, issue.ID) && in.Out == 0 {
+			t.Fatal("payment spends the output carrying the token")
+		}
+	}
+
+	// alice sends some tokens to bob
+	transfer, err := bc.NewTokenTransferTransaction(alice, tokenID, bob.Address().String(), 300, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AddToMempool(transfer); err != nil {
+		t.Fatal(err)
+	}
+	transferred := minePending(t, bc, carol)
+	if alices, bobs := tokenBalance(t, bc, alice, tokenID), tokenBalance(t, bc, bob, tokenID); alices != 700 || bobs != 300 {
+		t.Fatalf("alice has %d and bob %d tokens, want 700 and 300", alices, bobs)
+	}
+	if _, err := bc.NewTokenTransferTransaction(bob, tokenID, alice.Address().String(), 301, 0); err == nil {
+		t.Fatal("transfer of more tokens than bob holds was created")
+	}
+
+	// a transaction spending tokens without allocating them is rejected
+	burn := &Transaction{
+		Inputs:  []TxInput{{ID: transfer.ID, Out: 0, PubKey: bob.PublicKey}},
+		Outputs: []TxOutput{*NewTXOutput(TokenCarrierValue, bob.Address().String())},
+		Version: TxVersion,
+	}
+	burn.ID = burn.GenerateHash()
+	if err := bc.SignTransaction(burn, bob); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AddToMempool(burn); err == nil || !strings.Contains(err.Error(), "burn") {
+		t.Fatalf("got %v, want a transaction burning tokens to be rejected", err)
+	}
+
+	// disconnecting the transfer gives the tokens back to alice
+	side := mineOn(t, bc, issued, carol)
+	for _, block := range []*Block{side, mineOn(t, bc, side, carol)} {
+		if err := bc.AcceptBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bc.Height() != transferred.Height+1 {
+		t.Fatalf("chain is at height %d, want the side chain", bc.Height())
+	}
+	if alices, bobs := tokenBalance(t, bc, alice, tokenID), tokenBalance(t, bc, bob, tokenID); alices != 1000 || bobs != 0 {
+		t.Fatalf("alice has %d and bob %d tokens after the reorg, want 1000 and 0", alices, bobs)
+	}
+}
+
+func TestTokenEffects(t *testing.T) {
+	tokenID := Hash{1}
+	other := Hash{2}
+	transferTx := func(transfers ...TokenTransfer) *Transaction {
+		out, err := NewTokenTransferOutput(tokenID, transfers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Transaction{Outputs: []TxOutput{
+			*NewTXOutput(1, bob.Address().String()),
+			*NewTXOutput(1, alice.Address().String()),
+			*out,
+		}}
+	}
+
+	for _, c := range []struct {
+		name    string
+		tx      *Transaction
+		inputs  []tokenEntry
+		outputs int
+		burned  bool
+		invalid bool
+	}{
+		{"payment", &Transaction{Outputs: []TxOutput{*NewTXOutput(1, bob.Address().String())}}, []tokenEntry{{}}, 0, false, false},
+		{"payment spending tokens", &Transaction{Outputs: []TxOutput{*NewTXOutput(1, bob.Address().String())}}, []tokenEntry{{tokenID, 5}}, 0, true, false},
+		{"transfer", transferTx(TokenTransfer{0, 3}, TokenTransfer{1, 2}), []tokenEntry{{tokenID, 5}}, 2, false, false},
+		{"partial transfer", transferTx(TokenTransfer{0, 3}), []tokenEntry{{tokenID, 5}}, 1, true, false},
+		{"transfer of another token", transferTx(TokenTransfer{0, 3}), []tokenEntry{{tokenID, 3}, {other, 1}}, 1, true, false},
+		{"overspending transfer", transferTx(TokenTransfer{0, 6}), []tokenEntry{{tokenID, 5}}, 0, true, true},
+		{"transfer to data", transferTx(TokenTransfer{2, 5}), []tokenEntry{{tokenID, 5}}, 0, true, true},
+		{"repeated transfer", transferTx(TokenTransfer{0, 2}, TokenTransfer{0, 3}), []tokenEntry{{tokenID, 5}}, 0, true, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.tx.ID = c.tx.GenerateHash()
+			outputs, burned, err := tokenEffects(c.tx, c.inputs)
+			if len(outputs) != c.outputs || burned != c.burned || (err != nil) != c.invalid {
+				t.Fatalf("got %d outputs, burned %v, %v", len(outputs), burned, err)
+			}
+		})
+	}
+
+	if _, err := NewTokenTransferOutput(tokenID, make([]TokenTransfer, MaxTokenTransfers+1)); err == nil {
+		t.Fatal("transfer output with too many allocations was created")
+	}
+	if _, err := NewTokenIssueOutput(0); err == nil {
+		t.Fatal("issuance of no supply was created")
+	}
+}
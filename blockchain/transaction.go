@@ -2,18 +2,18 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
-	"math/big"
-	"strings"
+	"math"
 
-	"github.com/edwintcloud/gochain/wallet"
+	"github.com/edwintcloud/gochain/consensus"
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
 )
 
 // Transaction represents a blockchain transaction.
@@ -21,6 +21,20 @@ type Transaction struct {
 	ID      []byte
 	Inputs  []TxInput
 	Outputs []TxOutput
+
+	// Version selects the encoding the id and signature hashes are
+	// computed over, as described by TxVersion
+	Version int
+}
+
+// init encodes a version 0 Transaction before anything else is encoded.
+// gob assigns type ids in the order types are first used in a process and
+// includes them in the encoded bytes, so without this, the hashes of
+// version 0 transactions would depend on what the process did first.
+func init() {
+	var tx Transaction
+	tx.legacyBytes()
+	tx.Serialize()
 }
 
 // Serialize serializes a Transaction into bytes.
@@ -40,9 +54,37 @@ func (tx *Transaction) Serialize() []byte {
 	return buffer.Bytes()
 }
 
-// GenerateHash generates a sha256 hash from the bytes of a Transaction
-// structure. It is important we do not use a pointer receiver here so
-// that the original Transaction is not modified.
+// DeserializeTransaction deserializes a byte slice into a Transaction.
+func DeserializeTransaction(data []byte) Transaction {
+	tx, err := DecodeTransaction(data)
+	if err != nil {
+		log.Panicf("Unable to decode byte slice into a new Transaction struct: %s", err.Error())
+	}
+	return tx
+}
+
+// DecodeTransaction decodes a Transaction serialized by Serialize,
+// returning an error instead of panicking for data that is not one, such
+// as data received from the network.
+func DecodeTransaction(data []byte) (Transaction, error) {
+	var tx Transaction
+
+	// create decoder on a bytes reader of the data byte slice
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+
+	// use decoder to decode bytes reader into created transaction
+	if err := decoder.Decode(&tx); err != nil {
+		return Transaction{}, errors.New("unable to decode transaction - " + err.Error())
+	}
+
+	// return decoded transaction
+	return tx, nil
+}
+
+// GenerateHash generates a sha256 hash of a Transaction without its ID,
+// over its canonical encoding, or its gob encoding for version 0. It is
+// important we do not use a pointer receiver here so that the original
+// Transaction is not modified.
 func (tx Transaction) GenerateHash() []byte {
 	var hash [32]byte
 
@@ -50,7 +92,11 @@ func (tx Transaction) GenerateHash() []byte {
 	tx.ID = []byte{}
 
 	// generate hash
-	hash = sha256.Sum256(tx.Serialize())
+	if tx.Version == 0 {
+		hash = sha256.Sum256(tx.legacyBytes())
+	} else {
+		hash = sha256.Sum256(tx.CanonicalBytes())
+	}
 
 	// return hash
 	return hash[:]
@@ -58,48 +104,40 @@ func (tx Transaction) GenerateHash() []byte {
 
 // SetID generates a hash id for a transaction.
 func (tx *Transaction) SetID() {
-	var buffer bytes.Buffer
-	var hash [32]byte
-
-	// create encoder on res bytes buffer
-	encoder := gob.NewEncoder(&buffer)
-
-	// use encoder to encode transaction into byte slice
-	err := encoder.Encode(tx)
-	if err != nil {
-		log.Panicf("Unable to encode block structure into byte slice: %s", err.Error())
-	}
-
-	// convert byte slice from buffer into a hash
-	hash = sha256.Sum256(buffer.Bytes())
-
-	// update transaction with generated hash
-	tx.ID = hash[:]
+	tx.ID = tx.GenerateHash()
 }
 
-// CoinbaseTx is a transfer for rewarding an account for mining a block.
-func CoinbaseTx(to, data string) *Transaction {
+// Subsidy is the amount of new tokens in base units rewarded for mining a
+// block.
+const Subsidy = consensus.Subsidy
+
+// CoinbaseTx is a transfer for rewarding an account for mining a block. The
+// reward is the block subsidy plus the fees of the transactions in the block.
+func CoinbaseTx(to, data string, fees units.Amount) *Transaction {
 
-	// ensure data string is not empty
+	// ensure data string is not empty, using random data so
+	// that coinbase transactions to the same address have
+	// unique ids
 	if data == "" {
-		data = fmt.Sprintf("Coins to %s", to)
+		randData := make([]byte, 24)
+		_, err := rand.Read(randData)
+		if err != nil {
+			log.Panicln("Unable to generate random coinbase data: ", err.Error())
+		}
+		data = fmt.Sprintf("%x", randData)
 	}
 
 	// create transaction structures
 	txIn := TxInput{
-		ID:        []byte{},
-		Out:       -1,
-		Signature: nil,
-		PubKey:    []byte(data),
-	}
-	txOut := NewTXOutput(
-		100,
-		to,
-	)
+		ID:           []byte{},
+		Out:          -1,
+		CoinbaseData: []byte(data),
+	}
 	tx := Transaction{
 		ID:      nil,
 		Inputs:  []TxInput{txIn},
-		Outputs: []TxOutput{*txOut},
+		Outputs: []TxOutput{*NewTXOutput(Subsidy+fees, to)},
+		Version: TxVersion,
 	}
 
 	// generate hash id for transaction
@@ -109,32 +147,107 @@ func CoinbaseTx(to, data string) *Transaction {
 	return &tx
 }
 
-// NewTransaction initiates a new blockchain transaction.
-func (bc *BlockChain) NewTransaction(from, to string, amount int) *Transaction {
-	var txInputs []TxInput
+// Payment is an amount of coins paid to an address by a transaction.
+type Payment struct {
+	To     string
+	Amount units.Amount
+}
+
+// NewTransaction initiates a new blockchain transaction sending amount from
+// the address of wallet w. Any change is sent to the change address, or
+// back to the address of w if change is empty. The fee is left unclaimed by
+// the outputs so it can be collected by the miner.
+func (bc *BlockChain) NewTransaction(w keys.Key, to string, amount, fee units.Amount, change string) (*Transaction, error) {
+	return bc.NewPaymentTransaction(w, []Payment{{To: to, Amount: amount}}, fee, change)
+}
+
+// NewPaymentTransaction creates a single transaction from the address of
+// wallet w paying every payment, in order, like NewTransaction. Inputs are
+// selected once for the total of the payments and the fee, and any change
+// is sent to a single output after the payments.
+func (bc *BlockChain) NewPaymentTransaction(w keys.Key, payments []Payment, fee units.Amount, change string) (*Transaction, error) {
 	var txOutputs []TxOutput
 
-	// create wallets and generate public key for from addressed wallet
-	wallets, err := wallet.CreateWallets()
-	if err != nil {
-		log.Panicln("Unable to load wallets while creating new blockchain transaction: ", err.Error())
+	// validate the payments and add an output for each
+	if len(payments) == 0 {
+		return nil, errors.New("transaction has no payments")
+	}
+	for _, payment := range payments {
+		if !keys.ValidateAddress(payment.To) {
+			return nil, fmt.Errorf("payment address %s is not valid", payment.To)
+		}
+		if payment.Amount <= 0 {
+			return nil, fmt.Errorf("payment of %s to %s is not positive", units.FormatAmount(payment.Amount), payment.To)
+		}
+		txOutputs = append(txOutputs, *NewTXOutput(
+			payment.Amount,
+			payment.To,
+		))
+	}
+
+	return bc.fundTransaction(w, txOutputs, fee, change)
+}
+
+// fundTransaction creates a transaction from the address of wallet w with
+// outputs, selecting inputs for their total and the fee and sending any
+// change to the change address, or back to the address of w if change is
+// empty.
+func (bc *BlockChain) fundTransaction(w keys.Key, txOutputs []TxOutput, fee units.Amount, change string) (*Transaction, error) {
+	return bc.fundTransactionWith(w, nil, txOutputs, fee, change)
+}
+
+// fundTransactionWith creates a transaction like fundTransaction that
+// spends the selected outputs of w, adding inputs if they don't cover the
+// outputs and fee.
+func (bc *BlockChain) fundTransactionWith(w keys.Key, selected []UnspentOutput, txOutputs []TxOutput, fee units.Amount, change string) (*Transaction, error) {
+	var txInputs []TxInput
+	if !w.CanSign() {
+		return nil, fmt.Errorf("unable to send from %s - %s", w.Address(), keys.ErrWatchOnly.Error())
+	}
+	if change == "" {
+		change = w.Address().String()
 	}
-	w := wallets[from]
-	pubKeyHash := wallet.GeneratePublicKeyHash(w.PublicKey)
+	pubKeyHash := keys.PublicKeyHash(w.PubKey())
 
-	// find spendable outputs for address and amount
-	acc, spendableOutputs := bc.FindSpendableOutputs(pubKeyHash, amount)
+	// total the outputs
+	var amount units.Amount
+	for _, out := range txOutputs {
+		amount += out.Value
+	}
 
-	// quit program if not enough funds to cover amount
-	if acc < amount {
-		log.Panic("Error: not enough funds to complete transaction")
+	// spend the selected outputs, and find spendable outputs for the rest
+	// of the amount plus fee
+	var acc units.Amount
+	for _, u := range selected {
+		acc += u.Output.Value
+		txInputs = append(txInputs, TxInput{
+			ID:        u.TxID,
+			Out:       u.Out,
+			Signature: nil,
+			PubKey:    w.PubKey(),
+		})
+	}
+	spendableOutputs := make(map[string][]int)
+	if acc < amount+fee || len(selected) == 0 {
+		found, outputs, err := bc.FindSpendableOutputs(pubKeyHash, amount+fee-acc)
+		if err != nil {
+			return nil, err
+		}
+
+		// ensure there are enough funds to cover amount and fee, and an
+		// input to sign
+		if acc+found < amount+fee || len(outputs) == 0 {
+			return nil, errors.New("not enough funds to complete transaction")
+		}
+		acc += found
+		spendableOutputs = outputs
 	}
 
 	// iterate over spendable outputs
 	for id, outs := range spendableOutputs {
 		txID, err := hex.DecodeString(id)
 		if err != nil {
-			log.Panicf("Unable to decode id  %v to string: %s", id, err.Error())
+			return nil, errors.New("unable to decode output transaction id - " + err.Error())
 		}
 
 		// iterate over current spendable outputs slice of out id's
@@ -144,22 +257,16 @@ func (bc *BlockChain) NewTransaction(from, to string, amount int) *Transaction {
 				ID:        txID,
 				Out:       out,
 				Signature: nil,
-				PubKey:    w.PublicKey,
+				PubKey:    w.PubKey(),
 			})
 		}
 	}
 
-	// add a TxOutput to txOutputs for to address
-	txOutputs = append(txOutputs, *NewTXOutput(
-		amount,
-		to,
-	))
-
-	// credit excess back to sender
-	if acc > amount {
+	// credit excess to the change address
+	if acc > amount+fee {
 		txOutputs = append(txOutputs, *NewTXOutput(
-			acc-amount,
-			from,
+			acc-amount-fee,
+			change,
 		))
 	}
 
@@ -168,15 +275,87 @@ func (bc *BlockChain) NewTransaction(from, to string, amount int) *Transaction {
 		ID:      nil,
 		Inputs:  txInputs,
 		Outputs: txOutputs,
+		Version: TxVersion,
 	}
 
 	// generate hash and sign transaction
 	tx.ID = tx.GenerateHash()
-	bc.SignTransaction(&tx, w.PrivateKey)
+	if err := bc.SignTransaction(&tx, w); err != nil {
+		return nil, err
+	}
 
 	// return a reference to the transaction
-	return &tx
+	return &tx, nil
+}
+
+// SweepTransaction creates a transaction moving every spendable output of a
+// wallet to a single address, less the fee. The wallet does not need to be
+// in the wallets file.
+func (bc *BlockChain) SweepTransaction(w keys.Key, to string, fee units.Amount) (*Transaction, error) {
+	var txInputs []TxInput
+	if !keys.ValidateAddress(to) {
+		return nil, fmt.Errorf("address %s is not valid", to)
+	}
+	pubKeyHash := keys.PublicKeyHash(w.PubKey())
+
+	// find every spendable output for the wallet
+	acc, spendableOutputs, err := bc.FindSpendableOutputs(pubKeyHash, math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+
+	// ensure there is something left to sweep after the fee
+	if acc <= fee {
+		return nil, fmt.Errorf("not enough funds to sweep: found %s with a fee of %s",
+			units.FormatAmount(acc), units.FormatAmount(fee))
+	}
+
+	// iterate over spendable outputs
+	for id, outs := range spendableOutputs {
+		txID, err := hex.DecodeString(id)
+		if err != nil {
+			return nil, err
+		}
+
+		// add a TxInput to txInputs for each output
+		for _, out := range outs {
+			txInputs = append(txInputs, TxInput{
+				ID:        txID,
+				Out:       out,
+				Signature: nil,
+				PubKey:    w.PubKey(),
+			})
+		}
+	}
+
+	// create transaction with a single output for the swept amount
+	tx := Transaction{
+		ID:      nil,
+		Inputs:  txInputs,
+		Outputs: []TxOutput{*NewTXOutput(acc-fee, to)},
+		Version: TxVersion,
+	}
+
+	// generate hash and sign transaction
+	tx.ID = tx.GenerateHash()
+	if err := bc.SignTransaction(&tx, w); err != nil {
+		return nil, err
+	}
+
+	// return a reference to the transaction
+	return &tx, nil
+}
 
+// ValueTo returns the total value of the outputs of a Transaction that can be
+// unlocked by pubKeyHash.
+func (tx *Transaction) ValueTo(pubKeyHash []byte) units.Amount {
+	var value units.Amount
+	for _, out := range tx.Outputs {
+		if out.IsLockedWithKey(pubKeyHash) {
+			value += out.Value
+		}
+	}
+	return value
 }
 
 // IsCoinbase verifies if transaction is a Coinbase transaction.
@@ -185,21 +364,18 @@ func (tx *Transaction) IsCoinbase() bool {
 		tx.Inputs[0].Out == -1
 }
 
-// Sign signs a Transaction.
-func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
+// Sign signs every input of a Transaction with signer, which must sign with
+// the public key of each input.
+func (tx *Transaction) Sign(signer keys.Signer, prevTXs map[string]Transaction) error {
 
 	// verify Transaction is not a Coinbase Transaction
 	if tx.IsCoinbase() {
-		return
+		return nil
 	}
 
-	// iterate over Transaction Inputs
-	for _, in := range tx.Inputs {
-		// verify that the transaction referenced in prevTXs
-		// by the current TxInput does not have a nil ID
-		if prevTXs[hex.EncodeToString(in.ID)].ID == nil {
-			log.Panicln("Unable to sign Transaction: the previous transaction does not exist")
-		}
+	// verify that prevTXs holds the output spent by each input
+	if err := tx.checkPrevOutputs(prevTXs); err != nil {
+		return errors.New("unable to sign transaction - " + err.Error())
 	}
 
 	// create a trimmed copy of the Transaction so we don't modify
@@ -214,82 +390,88 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transac
 		txCopy.ID = txCopy.GenerateHash()
 		txCopy.Inputs[inID].PubKey = nil
 
-		// sign ID using privKey
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)
+		// sign ID with signer, ensuring it signed with the key of the
+		// input, and add the signature to original Transaction input
+		signature, pubKey, err := signer.Sign(txCopy.ID)
 		if err != nil {
-			log.Panicln("Unable to sign Transaction: ", err.Error())
+			return errors.New("unable to sign transaction - " + err.Error())
 		}
-
-		// add signature (concatenaton of signing outputs) to original Transaction input
-		tx.Inputs[inID].Signature = append(r.Bytes(), s.Bytes()...)
+		if !bytes.Equal(pubKey, tx.Inputs[inID].PubKey) {
+			return fmt.Errorf("unable to sign transaction - signer does not hold the key of input %d", inID)
+		}
+		tx.Inputs[inID].Signature = signature
 
 	}
 
+	return nil
 }
 
-// Verify verifies a Transaction.
-func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+// Verify verifies the signatures of a Transaction made with scheme, the
+// signature scheme of the network, returning false instead of panicking
+// for malformed transactions, keys and signatures.
+func (tx *Transaction) Verify(scheme keys.Scheme, prevTXs map[string]Transaction) bool {
+	return tx.CheckSignatures(scheme, prevTXs) == nil
+}
+
+// CheckSignatures verifies the signatures of a Transaction like Verify,
+// returning why they are invalid. It is safe to call on transactions
+// received from the network, whose inputs may spend outputs missing from
+// prevTXs.
+func (tx *Transaction) CheckSignatures(scheme keys.Scheme, prevTXs map[string]Transaction) error {
 
-	// return true for a Coinbase Transaction
+	// return nil for a Coinbase Transaction
 	if tx.IsCoinbase() {
-		return true
+		return nil
 	}
 
-	// iterate over Transaction Inputs
-	for _, in := range tx.Inputs {
-		// verify that the transaction referenced in prevTXs
-		// by the current TxInput does not have a nil ID
-		if prevTXs[hex.EncodeToString(in.ID)].ID == nil {
-			log.Panicln("Unable to verify Transaction: the previous transaction does not exist")
-		}
+	// verify that prevTXs holds the output spent by each input
+	if err := tx.checkPrevOutputs(prevTXs); err != nil {
+		return err
 	}
 
 	// create a trimmed copy of the Transaction so we don't modify
 	// the original while signing
 	txCopy := tx.TrimmedCopy()
 
-	// define the curve for checking the signature of each input
-	curve := elliptic.P256()
-
-	// iterate over txCopy inputs
-	for inID, in := range txCopy.Inputs {
+	// iterate over Transaction inputs, using txCopy to generate the
+	// signed hash since the original inputs hold the signatures
+	for inID, in := range tx.Inputs {
 		prevTX := prevTXs[hex.EncodeToString(in.ID)]
 		txCopy.Inputs[inID].Signature = nil
 		txCopy.Inputs[inID].PubKey = prevTX.Outputs[in.Out].PubKeyHash
 		txCopy.ID = txCopy.GenerateHash()
 		txCopy.Inputs[inID].PubKey = nil
 
-		// unpack r and s from signature
-		r := big.Int{}
-		s := big.Int{}
-		sigMedian := len(in.Signature) / 2
-		r.SetBytes(in.Signature[:sigMedian])
-		s.SetBytes(in.Signature[sigMedian:])
-
-		// unpack x and y from public key
-		x := big.Int{}
-		y := big.Int{}
-		keyMedian := len(in.PubKey) / 2
-		x.SetBytes(in.PubKey[:keyMedian])
-		y.SetBytes(in.PubKey[keyMedian:])
-
-		// create ecdsa public key using curve, x, and y
-		pubKey := ecdsa.PublicKey{curve, &x, &y}
-
-		// verify the private key with the public key
-		if !ecdsa.Verify(&pubKey, txCopy.ID, &r, &s) {
-			return false
+		// verify the signature with the public key
+		if !scheme.Verify(in.PubKey, txCopy.ID, in.Signature) {
+			return fmt.Errorf("input %d of transaction %x has an invalid signature", inID, tx.ID)
 		}
 	}
 
-	// return true if all inputs were verified
-	return true
+	// return nil if all inputs were verified
+	return nil
+}
+
+// checkPrevOutputs returns an error unless prevTXs holds the output spent
+// by each input of a Transaction.
+func (tx *Transaction) checkPrevOutputs(prevTXs map[string]Transaction) error {
+	for inID, in := range tx.Inputs {
+		prevTX, ok := prevTXs[hex.EncodeToString(in.ID)]
+		if !ok || prevTX.ID == nil {
+			return fmt.Errorf("the previous transaction %x of input %d does not exist", in.ID, inID)
+		}
+		if in.Out < 0 || in.Out >= len(prevTX.Outputs) {
+			return fmt.Errorf("input %d spends output %d of transaction %x, which has %d outputs", inID, in.Out, in.ID, len(prevTX.Outputs))
+		}
+	}
+	return nil
 }
 
 // TrimmedCopy makes a deep copy of a Transaction excluding the signature and
-// public key for each TxInput.
+// public key for each TxInput, which signatures are made over. The outputs
+// are left out of the copy of a version 0 Transaction, as they always were.
 func (tx *Transaction) TrimmedCopy() Transaction {
-	newTx := Transaction{}
+	newTx := Transaction{Version: tx.Version}
 
 	for _, in := range tx.Inputs {
 		newTx.Inputs = append(newTx.Inputs, TxInput{
@@ -300,37 +482,14 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 		})
 	}
 
-	copy(newTx.Outputs, tx.Outputs)
+	if tx.Version >= 1 {
+		newTx.Outputs = append([]TxOutput{}, tx.Outputs...)
+	}
 
 	return newTx
 }
 
-// ToString returns a string representation of a Transaction.
+// String returns a string representation of a Transaction with every field.
 func (tx *Transaction) String() string {
-	result := []string{
-		fmt.Sprintf("--- Transaction %x:", tx.ID),
-	}
-
-	// iterate over inputs
-	for inID, in := range tx.Inputs {
-		result = append(result,
-			fmt.Sprintf("\tInput %d:", inID),
-			fmt.Sprintf("\t\tTXID:\t%x", in.ID),
-			fmt.Sprintf("\t\tOut:\t%d", in.Out),
-			fmt.Sprintf("\t\tSignature:\t%x", in.Signature),
-			fmt.Sprintf("\t\tPubKey:\t%x", in.PubKey),
-		)
-	}
-
-	// iterate over outputs
-	for outID, out := range tx.Outputs {
-		result = append(result,
-			fmt.Sprintf("\tOutput %d:", outID),
-			fmt.Sprintf("\t\tValue:\t%d", out.Value),
-			fmt.Sprintf("\t\tScript:\t%x", out.PubKeyHash),
-		)
-	}
-
-	// return the result as a joined string
-	return strings.Join(result, "\n")
+	return tx.Format(Full, false)
 }
@@ -0,0 +1,296 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/logging"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestNewTransactionChange(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		amount     units.Amount
+		fee        units.Amount
+		change     string
+		wantChange units.Amount
+	}{
+		{"change to change address", 10, 1, carol.Address().String(), genesisAllocation - 11},
+		{"change to sender", 10, 1, "", genesisAllocation - 11},
+		{"no change", genesisAllocation - 1, 1, carol.Address().String(), 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bc := newTestChain(t)
+			tx, err := bc.NewTransaction(alice, bob.Address().String(), c.amount, c.fee, c.change)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			changeKey := wallet.GeneratePublicKeyHash(alice.PublicKey)
+			if c.change != "" {
+				changeKey = wallet.GeneratePublicKeyHash(carol.PublicKey)
+			}
+			if got := tx.ValueTo(changeKey); got != c.wantChange {
+				t.Fatalf("got change %d, want %d", got, c.wantChange)
+			}
+			if got := tx.ValueTo(wallet.GeneratePublicKeyHash(bob.PublicKey)); got != c.amount {
+				t.Fatalf("got payment %d, want %d", got, c.amount)
+			}
+			if c.wantChange == 0 && len(tx.Outputs) != 1 {
+				t.Fatalf("got %d outputs, want only the payment", len(tx.Outputs))
+			}
+		})
+	}
+}
+
+func TestNewTransactionNotEnoughFunds(t *testing.T) {
+	bc := newTestChain(t)
+	if _, err := bc.NewTransaction(alice, bob.Address().String(), genesisAllocation, 1, ""); err == nil {
+		t.Fatal("spending more than the balance succeeded")
+	}
+}
+
+func TestNewTransactionWatchOnly(t *testing.T) {
+	bc := newTestChain(t)
+	watched, err := wallet.NewWatchOnly(alice.Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.NewTransaction(watched, bob.Address().String(), units.Coin, 0, ""); err == nil || !strings.Contains(err.Error(), "watch-only") {
+		t.Fatalf("got error %v, want watch-only wallets to be refused", err)
+	}
+}
+
+// externalSigner signs like a hardware wallet holding the key of a wallet.
+type externalSigner struct {
+	w     *wallet.Wallet
+	signs int
+}
+
+func (s *externalSigner) Sign(hash []byte) ([]byte, []byte, error) {
+	s.signs++
+	return s.w.Sign(hash)
+}
+
+func TestNewTransactionExternalSigner(t *testing.T) {
+	bc := newTestChain(t)
+	watched, err := wallet.NewWatchOnlyPublicKey(alice.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &externalSigner{w: alice}
+	watched.SetSigner(signer)
+
+	// a watch-only wallet sends with the signer holding its key
+	tx, err := bc.NewTransaction(watched, bob.Address().String(), units.Coin, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AddToMempool(tx); err != nil || signer.signs != len(tx.Inputs) {
+		t.Fatalf("got %v after %d signs, want a valid transaction", err, signer.signs)
+	}
+
+	// a signer without the key of the inputs is refused
+	watched.SetSigner(&externalSigner{w: bob})
+	if _, err := bc.NewTransaction(watched, bob.Address().String(), units.Coin, 0, ""); err == nil || !strings.Contains(err.Error(), "does not hold the key") {
+		t.Fatalf("got error %v, want signers of other keys to be refused", err)
+	}
+}
+
+func TestNewPaymentTransaction(t *testing.T) {
+	bobAddr, carolAddr := bob.Address().String(), carol.Address().String()
+	for _, c := range []struct {
+		name     string
+		payments []Payment
+		fee      units.Amount
+		outputs  int
+		err      string
+	}{
+		{"two recipients", []Payment{{bobAddr, 10}, {carolAddr, 20}}, 3, 3, ""},
+		{"same recipient twice", []Payment{{bobAddr, 10}, {bobAddr, 5}}, 0, 3, ""},
+		{"no change", []Payment{{bobAddr, 600 * units.Coin}, {carolAddr, genesisAllocation - 600*units.Coin - 1}}, 1, 2, ""},
+		{"no payments", nil, 0, 0, "no payments"},
+		{"zero amount", []Payment{{bobAddr, 10}, {carolAddr, 0}}, 0, 0, "not positive"},
+		{"invalid address", []Payment{{"nope", 10}}, 0, 0, "not valid"},
+		{"not enough funds", []Payment{{bobAddr, 600 * units.Coin}, {carolAddr, 400 * units.Coin}}, 1, 0, "not enough funds"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bc := newTestChain(t)
+			tx, err := bc.NewPaymentTransaction(alice, c.payments, c.fee, "")
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("got error %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tx.Outputs) != c.outputs {
+				t.Fatalf("got %d outputs, want %d", len(tx.Outputs), c.outputs)
+			}
+
+			// every recipient is paid by the one transaction
+			if err := bc.AddToMempool(tx); err != nil {
+				t.Fatal(err)
+			}
+			minePending(t, bc, alice)
+			want := map[*wallet.Wallet]units.Amount{alice: genesisAllocation + Subsidy}
+			for _, payment := range c.payments {
+				recipient := bob
+				if payment.To == carolAddr {
+					recipient = carol
+				}
+				want[recipient] += payment.Amount
+				want[alice] -= payment.Amount
+			}
+			for w, amount := range want {
+				if got := balance(t, bc, w); got != amount {
+					t.Errorf("%s has %d, want %d", w.Address(), got, amount)
+				}
+			}
+		})
+	}
+}
+
+func TestSignaturesAlwaysVerify(t *testing.T) {
+	prev := Transaction{ID: []byte("prev"), Outputs: []TxOutput{*NewTXOutput(10, alice.Address().String())}}
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): prev}
+
+	// r or s is shorter than 32 bytes in about one in a hundred signatures,
+	// and s is high in half of them, so sign enough times to hit both
+	for i := 0; i < 1000; i++ {
+		tx := Transaction{
+			Inputs:  []TxInput{{ID: prev.ID, Out: 0, PubKey: alice.PublicKey}},
+			Outputs: []TxOutput{*NewTXOutput(units.Amount(i+1), bob.Address().String())},
+		}
+		tx.ID = tx.GenerateHash()
+		if err := tx.Sign(alice, prevTXs); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.checkSignatureEncoding(keys.P256); err != nil || !tx.Verify(keys.P256, prevTXs) {
+			t.Fatalf("signature %x does not verify: %v", tx.Inputs[0].Signature, err)
+		}
+	}
+}
+
+func TestSecp256k1Chain(t *testing.T) {
+	dave := wallet.NewFromSeedWithScheme(keys.Secp256k1, []byte("dave"))
+	genesis := testGenesis()
+	genesis.Allocations[dave.Address().String()] = genesisAllocation
+	genesis.SignatureScheme = keys.Secp256k1.Name()
+	bc, err := Open(Config{Path: t.TempDir(), Genesis: genesis, Logger: logging.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	// transactions signed with secp256k1 keys are accepted
+	tx := send(t, bc, dave, bob, units.Coin, 0)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, carol)
+	if got := balance(t, bc, bob); got != units.Coin {
+		t.Fatalf("bob has %d, want %d", got, units.Coin)
+	}
+
+	// while those signed with P-256 keys are not
+	tx = send(t, bc, alice, bob, units.Coin, 0)
+	if err := bc.AddToMempool(tx); err == nil || !strings.Contains(err.Error(), "invalid signature") {
+		t.Fatalf("got %v adding a transaction signed with P-256, want an invalid signature", err)
+	}
+}
+
+func TestCheckSignaturesMalformed(t *testing.T) {
+	prev := Transaction{ID: []byte("prev"), Outputs: []TxOutput{*NewTXOutput(10, alice.Address().String())}}
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): prev}
+	signed := Transaction{
+		Inputs:  []TxInput{{ID: prev.ID, Out: 0, PubKey: alice.PublicKey}},
+		Outputs: []TxOutput{*NewTXOutput(5, bob.Address().String())},
+	}
+	signed.ID = signed.GenerateHash()
+	if err := signed.Sign(alice, prevTXs); err != nil {
+		t.Fatal(err)
+	}
+	sig := signed.Inputs[0].Signature
+
+	// malformed inputs, keys and signatures are errors, never panics
+	for _, c := range []struct {
+		name string
+		in   TxInput
+		want string
+	}{
+		{"missing previous transaction", TxInput{ID: []byte("other"), Out: 0, PubKey: alice.PublicKey, Signature: sig}, "does not exist"},
+		{"output out of range", TxInput{ID: prev.ID, Out: 1, PubKey: alice.PublicKey, Signature: sig}, "which has 1 outputs"},
+		{"negative output", TxInput{ID: prev.ID, Out: -2, PubKey: alice.PublicKey, Signature: sig}, "which has 1 outputs"},
+		{"odd length signature", TxInput{ID: prev.ID, Out: 0, PubKey: alice.PublicKey, Signature: sig[:len(sig)-1]}, "invalid signature"},
+		{"empty signature", TxInput{ID: prev.ID, Out: 0, PubKey: alice.PublicKey}, "invalid signature"},
+		{"oversized signature", TxInput{ID: prev.ID, Out: 0, PubKey: alice.PublicKey, Signature: make([]byte, 4096)}, "invalid signature"},
+		{"empty key", TxInput{ID: prev.ID, Out: 0, Signature: sig}, "invalid signature"},
+		{"odd length key", TxInput{ID: prev.ID, Out: 0, PubKey: alice.PublicKey[1:], Signature: sig}, "invalid signature"},
+		{"oversized key", TxInput{ID: prev.ID, Out: 0, PubKey: make([]byte, 4096), Signature: sig}, "invalid signature"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			tx := signed
+			tx.Inputs = []TxInput{c.in}
+			if err := tx.CheckSignatures(keys.P256, prevTXs); err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("got error %v, want %q", err, c.want)
+			}
+			if tx.Verify(keys.P256, prevTXs) {
+				t.Fatal("malformed transaction verifies")
+			}
+		})
+	}
+	if err := signed.CheckSignatures(keys.P256, prevTXs); err != nil {
+		t.Fatalf("signed transaction is invalid: %s", err)
+	}
+}
+
+func FuzzDecodeTransaction(f *testing.F) {
+	prev := Transaction{ID: []byte("prev"), Outputs: []TxOutput{*NewTXOutput(10, alice.Address().String())}}
+	tx := Transaction{
+		Version: TxVersion,
+		Inputs:  []TxInput{{ID: prev.ID, Out: 0, PubKey: alice.PublicKey, Signature: []byte{1, 2, 3}}},
+		Outputs: []TxOutput{*NewTXOutput(5, bob.Address().String())},
+	}
+	tx.ID = tx.GenerateHash()
+	f.Add(tx.Serialize())
+	f.Add(CoinbaseTx(alice.Address().String(), "fuzz", 0).Serialize())
+	f.Add([]byte{})
+
+	// decoding and verifying untrusted data returns errors instead of
+	// panicking, spending outputs of any index of any transaction
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoded, err := DecodeTransaction(data)
+		if err != nil {
+			return
+		}
+		prevTXs := make(map[string]Transaction)
+		for _, in := range decoded.Inputs {
+			prevTXs[hex.EncodeToString(in.ID)] = prev
+		}
+		decoded.Verify(keys.P256, prevTXs)
+		decoded.Verify(keys.Secp256k1, nil)
+		decoded.checkInputs()
+		decoded.checkSignatureEncoding(keys.P256)
+	})
+}
+
+func FuzzDecodeBlock(f *testing.F) {
+	genesis := testGenesis().Block()
+	f.Add(genesis.Serialize())
+	f.Add(genesis.Header().Serialize())
+	f.Add([]byte{blockFormatProtobuf})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if block, err := DecodeBlock(data); err == nil {
+			block.Serialize()
+		}
+		DecodeHeader(data)
+	})
+}
@@ -2,12 +2,12 @@ package blockchain
 
 import (
 	"bytes"
+	"fmt"
 	"log"
-	"os"
-	"strconv"
 
-	"github.com/btcsuite/btcutil/base58"
-	"github.com/edwintcloud/gochain/wallet"
+	"github.com/edwintcloud/gochain/addresses"
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/units"
 )
 
 // TxInput represents an input transaction.
@@ -16,16 +16,20 @@ type TxInput struct {
 	Out       int
 	Signature []byte
 	PubKey    []byte
+
+	// CoinbaseData is the arbitrary data of a coinbase input, which has no
+	// public key or signature
+	CoinbaseData []byte
 }
 
 // TxOutput represents an output transaction.
 type TxOutput struct {
-	Value      int
+	Value      units.Amount
 	PubKeyHash []byte
 }
 
 // CreateTxOutput creates a new TxOutput.
-func CreateTxOutput(value int, address string) *TxOutput {
+func CreateTxOutput(value units.Amount, address string) *TxOutput {
 
 	// create new TxOutput
 	out := TxOutput{
@@ -34,7 +38,7 @@ func CreateTxOutput(value int, address string) *TxOutput {
 	}
 
 	// lock TxOutput by populating PubKeyHash
-	out.Lock([]byte(address))
+	out.lockTo(address)
 
 	// return reference to new TxOutput
 	return &out
@@ -42,22 +46,72 @@ func CreateTxOutput(value int, address string) *TxOutput {
 
 // UsesKey verifies that a TxInput has a valid public key.
 func (in *TxInput) UsesKey(pubKeyHash []byte) bool {
-	return bytes.Compare(wallet.GeneratePublicKeyHash(in.PubKey), pubKeyHash) == 0
+	return bytes.Compare(keys.PublicKeyHash(in.PubKey), pubKeyHash) == 0
 }
 
-// Lock locks TxOutput.
-func (out *TxOutput) Lock(address []byte) {
-	checksumLen, err := strconv.Atoi(os.Getenv("CHECKSUM_LENGTH"))
-	if err != nil {
-		log.Panicln("Unable to convert env var CHECKSUM_LENGTH to int for method (TxOutput) Lock: ", err.Error())
+// Address returns the address of the key that spends the input, or an empty
+// string for coinbase inputs and inputs that have not been signed.
+func (in *TxInput) Address() string {
+	if len(in.ID) == 0 || len(in.PubKey) == 0 {
+		return ""
+	}
+	return keys.AddressFromPublicKeyHash(keys.PublicKeyHash(in.PubKey))
+}
+
+// checkInputs verifies that each input of a transaction only uses the
+// fields of its kind: a coinbase input holds data but no key or signature,
+// and other inputs spend an output but hold no coinbase data, so coinbase
+// data is never taken for a key. It also refuses versions this node can't
+// hash.
+func (tx *Transaction) checkInputs() error {
+	if tx.Version < 0 || tx.Version > TxVersion {
+		return fmt.Errorf("transaction %x has unsupported version %d", tx.ID, tx.Version)
 	}
 
-	// decode address from base58 back to sha256 hash
-	pubKeyHash := base58.Decode(string(address[:]))
+	if tx.IsCoinbase() {
+		in := tx.Inputs[0]
+		if len(in.PubKey) != 0 || len(in.Signature) != 0 {
+			return fmt.Errorf("coinbase transaction %x has a public key or signature", tx.ID)
+		}
+		return nil
+	}
+
+	for inID, in := range tx.Inputs {
+		if len(in.CoinbaseData) != 0 {
+			return fmt.Errorf("input %d of transaction %x has coinbase data", inID, tx.ID)
+		}
+		if len(in.ID) == 0 || in.Out < 0 {
+			return fmt.Errorf("input %d of transaction %x does not spend an output", inID, tx.ID)
+		}
+	}
+	return nil
+}
 
-	// set TxOutput public key hash to decoded hash
-	// without the version or checksum
-	out.PubKeyHash = pubKeyHash[1 : len(pubKeyHash)-checksumLen]
+// Address returns the address the output is locked to, or an empty
+// string for a data output.
+func (out *TxOutput) Address() string {
+	if out.IsData() {
+		return ""
+	}
+	return keys.AddressFromPublicKeyHash(out.PubKeyHash)
+}
+
+// Lock locks TxOutput to an address.
+func (out *TxOutput) Lock(address addresses.Address) {
+
+	// set TxOutput public key hash to the address hash without the
+	// version or checksum
+	out.PubKeyHash = address.PubKeyHash
+}
+
+// lockTo locks TxOutput to an encoded address, which callers have
+// validated.
+func (out *TxOutput) lockTo(address string) {
+	decoded, err := addresses.Decode(address)
+	if err != nil {
+		log.Panicf("Unable to lock output to %q: %s", address, err.Error())
+	}
+	out.Lock(decoded)
 }
 
 // IsLockedWithKey checks to see if output has public key hash equal to given
@@ -67,9 +121,9 @@ func (out *TxOutput) IsLockedWithKey(pubKeyHash []byte) bool {
 }
 
 // NewTXOutput creates a new output Transaction.
-func NewTXOutput(value int, address string) *TxOutput {
+func NewTXOutput(value units.Amount, address string) *TxOutput {
 	txOut := &TxOutput{value, nil}
-	txOut.Lock([]byte(address))
+	txOut.lockTo(address)
 
 	return txOut
 }
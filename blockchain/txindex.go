@@ -0,0 +1,179 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+var (
+	// txPrefix is the key prefix for the transaction index, which maps the
+	// id of a transaction in the best chain to where it is stored: the
+	// height of its block, its offset in the block and the block hash.
+	// Entries are kept when blocks are pruned.
+	txPrefix = []byte("txloc-")
+
+	// txTipKey holds the hash of the block the transaction index reflects.
+	txTipKey = []byte("txtip")
+)
+
+// txLocation is where a transaction of the best chain is stored.
+type txLocation struct {
+	BlockHash []byte
+	Height    int
+	Offset    int
+}
+
+// txKey returns the db key for the transaction index entry of a transaction.
+func txKey(txID []byte) []byte {
+	return append(append([]byte{}, txPrefix...), txID...)
+}
+
+// indexTransactions adds the transactions of a block being connected to the
+// transaction index.
+func indexTransactions(txn *badger.Txn, block *Block) error {
+	for i, tx := range block.Transactions {
+		value := append(append(ToBytes(int64(block.Height)), ToBytes(int64(i))...), block.Hash...)
+		if err := txn.Set(txKey(tx.ID), value); err != nil {
+			return err
+		}
+	}
+
+	// record the new tip of the transaction index
+	return txn.Set(txTipKey, block.Hash)
+}
+
+// unindexTransactions removes the transactions of a block being disconnected
+// from the transaction index.
+func unindexTransactions(txn *badger.Txn, block *Block) error {
+	for _, tx := range block.Transactions {
+		if err := txn.Delete(txKey(tx.ID)); err != nil {
+			return err
+		}
+	}
+
+	// move the tip of the transaction index back
+	return txn.Set(txTipKey, block.PrevHash)
+}
+
+// getTxLocation returns where a transaction of the best chain is stored from
+// the transaction index. found is false if it is not in the best chain.
+func getTxLocation(txn *badger.Txn, txID []byte) (loc txLocation, found bool, err error) {
+	item, err := txn.Get(txKey(txID))
+	if err == badger.ErrKeyNotFound {
+		return txLocation{}, false, nil
+	} else if err != nil {
+		return txLocation{}, false, err
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return txLocation{}, false, err
+	}
+	if len(value) < 16 {
+		return txLocation{}, false, errors.New("unable to read transaction index - entry is truncated")
+	}
+	return txLocation{BlockHash: value[16:], Height: int(FromBytes(value[:8])), Offset: int(FromBytes(value[8:16]))}, true, nil
+}
+
+// indexedHeight returns the height of the block in the best chain holding
+// a transaction, using the transaction index. found is false if the
+// transaction is not in the best chain.
+func indexedHeight(txn *badger.Txn, txID []byte) (height int, found bool, err error) {
+	loc, found, err := getTxLocation(txn, txID)
+	return loc.Height, found, err
+}
+
+// indexedTransaction returns a transaction of the best chain using the
+// transaction index. found is false if it is not in the best chain, or its
+// block was pruned.
+func indexedTransaction(txn *badger.Txn, txID []byte) (tx *Transaction, found bool, err error) {
+	loc, found, err := getTxLocation(txn, txID)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	block, err := getBlock(txn, loc.BlockHash)
+	if err != nil {
+		return nil, false, err
+	}
+	if loc.Offset >= len(block.Transactions) {
+		return nil, false, nil
+	}
+	return block.Transactions[loc.Offset], true, nil
+}
+
+// txIndexTip returns the hash of the block the transaction index reflects,
+// or nil if the index has not been built.
+func (bc *BlockChain) txIndexTip() ([]byte, error) {
+	var tip []byte
+
+	// initiate read only transaction on db to get the tip
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(txTipKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		tip, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New("unable to read transaction index tip - " + err.Error())
+	}
+
+	return tip, nil
+}
+
+// ReindexTransactionsContext rebuilds the transaction index from the blocks
+// in the best chain, calling progress, if set, after each block. If ctx is
+// done it stops between blocks with ErrJobCancelled. If resume is set and
+// the index reflects a block of the best chain, such as after an
+// interrupted rebuild, the blocks after it are indexed instead of starting
+// over. The transactions of pruned blocks can't be indexed and are left
+// out.
+func (bc *BlockChain) ReindexTransactionsContext(ctx context.Context, resume bool, progress func(JobProgress)) error {
+	from := 0
+	if resume {
+		tip, err := bc.txIndexTip()
+		if err != nil {
+			return err
+		}
+		from = bc.resumeHeight(tip)
+	}
+
+	// remove the existing transaction index, starting with its tip so an
+	// interrupted removal is not resumed
+	if from == 0 {
+		if err := bc.deleteTip(txTipKey); err != nil {
+			return err
+		}
+		if err := bc.deletePrefix(txPrefix); err != nil {
+			return err
+		}
+	}
+
+	// index each block from genesis forward, one db transaction per block
+	height := bc.Height()
+	for h := from; h <= height; h++ {
+		if ctx.Err() != nil {
+			return ErrJobCancelled
+		}
+		block, err := bc.GetBlockByHeight(h)
+		if err != nil {
+			return err
+		}
+		err = bc.DB.Update(func(txn *badger.Txn) error {
+			return indexTransactions(txn, block)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to index transactions of block %x - %s", block.Hash, err.Error())
+		}
+		if progress != nil {
+			progress(JobProgress{Done: h + 1, Total: height + 1, Last: block.Hash})
+		}
+	}
+
+	return nil
+}
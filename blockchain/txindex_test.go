@@ -0,0 +1,125 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestTransactionIndex(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := tip(t, bc)
+	tx := send(t, bc, alice, bob, units.Coin, 0)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	mined := minePending(t, bc, carol)
+
+	// confirmed transactions are found where they are stored
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		loc, found, err := getTxLocation(txn, tx.ID)
+		if err != nil {
+			return err
+		}
+		if !found || !bytes.Equal(loc.BlockHash, mined.Hash) || loc.Height != 1 || loc.Offset != 1 {
+			return fmt.Errorf("got location %+v, found %v, want offset 1 of block %x", loc, found, mined.Hash)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []*Transaction{tx, mined.Transactions[0], genesis.Transactions[0]} {
+		got, err := bc.FindTransaction(want.ID)
+		if err != nil || !bytes.Equal(got.ID, want.ID) {
+			t.Fatalf("got %x, %v finding %x", got.ID, err, want.ID)
+		}
+	}
+
+	// transactions of blocks that leave the best chain are removed
+	side := mineOn(t, bc, genesis, alice)
+	if err := bc.AcceptBlock(side); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.AcceptBlock(mineOn(t, bc, side, alice)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.FindTransaction(mined.Transactions[0].ID); err == nil {
+		t.Fatal("found the coinbase of a block that left the best chain")
+	}
+	if got, err := bc.FindTransaction(tx.ID); err != nil || !bytes.Equal(got.ID, tx.ID) {
+		t.Fatalf("got %x, %v finding the payment returned to the mempool", got.ID, err)
+	}
+
+	// a rebuilt index finds the same transactions
+	if err := bc.ReindexTransactionsContext(context.Background(), false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := bc.FindTransaction(side.Transactions[0].ID); err != nil || !bytes.Equal(got.ID, side.Transactions[0].ID) {
+		t.Fatalf("got %x, %v finding the coinbase of the new best chain", got.ID, err)
+	}
+	if _, err := bc.FindTransaction(mined.Transactions[0].ID); err == nil {
+		t.Fatal("found the coinbase of a block that left the best chain after reindexing")
+	}
+}
+
+// benchmarkChain returns a test chain of length blocks in which carol is
+// paid a coin by each of the first payments blocks.
+func benchmarkChain(b *testing.B, length, payments int) *BlockChain {
+	bc := newTestChain(b)
+	for i := 0; i < length; i++ {
+		if i < payments {
+			if err := bc.AddToMempool(send(b, bc, alice, carol, units.Coin, 0)); err != nil {
+				b.Fatal(err)
+			}
+		}
+		minePending(b, bc, alice)
+	}
+	return bc
+}
+
+func BenchmarkFindTransaction(b *testing.B) {
+	for _, length := range []int{100, 1000} {
+		bc := benchmarkChain(b, length, 0)
+		genesis, err := bc.GetBlockByHeight(0)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		// find the deepest transaction, which scanning the chain reaches
+		// last
+		b.Run(fmt.Sprint("blocks=", length), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := bc.FindTransaction(genesis.Transactions[0].ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSignTransaction(b *testing.B) {
+	const inputs = 20
+	for _, length := range []int{100, 1000} {
+		bc := benchmarkChain(b, length, inputs)
+
+		// spend every payment carol received, deep in the chain
+		tx := send(b, bc, carol, bob, inputs*units.Coin, 0)
+		if len(tx.Inputs) != inputs {
+			b.Fatalf("transaction has %d inputs, want %d", len(tx.Inputs), inputs)
+		}
+		b.Run(fmt.Sprint("blocks=", length), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := bc.SignTransaction(tx, carol); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
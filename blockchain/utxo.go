@@ -0,0 +1,463 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/dgraph-io/badger"
+)
+
+var (
+	// utxoPrefix is the key prefix for unspent transaction outputs.
+	utxoPrefix = []byte("utxo-")
+
+	// undoPrefix is the key prefix for the outputs spent by a block,
+	// which are needed to disconnect the block from the chain.
+	undoPrefix = []byte("undo-")
+
+	// utxoTipKey holds the hash of the block the UTXO set reflects.
+	utxoTipKey = []byte("utxotip")
+)
+
+// UnspentOutput is a transaction output that has not been spent.
+type UnspentOutput struct {
+	TxID   []byte
+	Out    int
+	Output TxOutput
+}
+
+// spentOutput records an output spent by a block so it can be restored.
+type spentOutput struct {
+	TxID   []byte
+	Out    int
+	Output TxOutput
+}
+
+// utxoKey returns the db key for an unspent transaction output.
+func utxoKey(txID []byte, out int) []byte {
+	key := append(append([]byte{}, utxoPrefix...), txID...)
+	return append(key, ToBytes(int64(out))...)
+}
+
+// undoKey returns the db key for the undo record of a block.
+func undoKey(hash []byte) []byte {
+	return append(append([]byte{}, undoPrefix...), hash...)
+}
+
+// outpoint returns a string identifying a transaction output.
+func outpoint(txID []byte, out int) string {
+	return fmt.Sprintf("%x:%d", txID, out)
+}
+
+// serializeOutput serializes a TxOutput into a byte slice.
+func serializeOutput(out TxOutput) []byte {
+	var buffer bytes.Buffer
+
+	// use encoder to encode output into byte slice
+	err := gob.NewEncoder(&buffer).Encode(out)
+	if err != nil {
+		log.Panicf("Unable to encode TxOutput structure into byte slice: %s", err.Error())
+	}
+
+	// return bytes from buffer
+	return buffer.Bytes()
+}
+
+// deserializeOutput deserializes a byte slice into a TxOutput.
+func deserializeOutput(data []byte) TxOutput {
+	var out TxOutput
+
+	// use decoder to decode byte slice into output
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&out)
+	if err != nil {
+		log.Panicf("Unable to decode byte slice into a new TxOutput struct: %s", err.Error())
+	}
+
+	// return decoded output
+	return out
+}
+
+// connectBlock updates the UTXO set of a view for a block being added to
+// the tip of the chain, storing the outputs it spends so it can be
+// disconnected later. It returns an error if the block spends an output
+// that is not unspent.
+func connectBlock(v *utxoView, block *Block) error {
+	var spent []spentOutput
+	txn := v.txn
+
+	for _, tx := range block.Transactions {
+
+		// remove the outputs spent by the transaction
+		if !tx.IsCoinbase() {
+			for _, in := range tx.Inputs {
+				output, found, err := v.get(in.ID, in.Out)
+				if err != nil {
+					return err
+				} else if !found {
+					return fmt.Errorf("transaction %x spends missing or spent output %s",
+						tx.ID, outpoint(in.ID, in.Out))
+				}
+				spent = append(spent, spentOutput{in.ID, in.Out, output})
+				v.spend(in.ID, in.Out)
+			}
+		}
+
+		// add the outputs created by the transaction, leaving out data
+		// outputs, which can never be spent
+		for outIdx, out := range tx.Outputs {
+			if out.IsData() {
+				continue
+			}
+			v.add(tx.ID, outIdx, out)
+		}
+	}
+	if err := v.flush(); err != nil {
+		return err
+	}
+
+	// store the undo record for the block
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(spent); err != nil {
+		return err
+	}
+	if err := txn.Set(undoKey(block.Hash), buffer.Bytes()); err != nil {
+		return err
+	}
+
+	// index the block by its height in the chain, and its transactions by
+	// their ids
+	if err := txn.Set(heightKey(block.Height), block.Hash); err != nil {
+		return err
+	}
+	if err := indexTransactions(txn, block); err != nil {
+		return err
+	}
+
+	// add the transactions of the block to the address index
+	if err := indexAddresses(txn, block, spent); err != nil {
+		return err
+	}
+
+	// add the outputs spent by the block to the spent index
+	if err := indexSpends(txn, block); err != nil {
+		return err
+	}
+	if err := indexTokens(txn, block); err != nil {
+		return err
+	}
+
+	// add the block to the chart series
+	if err := recordChartPoint(txn, block, spent); err != nil {
+		return err
+	}
+	if err := recordStats(txn, block, spent); err != nil {
+		return err
+	}
+
+	// record the new tip of the UTXO set
+	return txn.Set(utxoTipKey, block.Hash)
+}
+
+// getUndo returns the outputs spent by a block from its undo record.
+func getUndo(txn *badger.Txn, hash []byte) ([]spentOutput, error) {
+	var spent []spentOutput
+
+	item, err := txn.Get(undoKey(hash))
+	if err != nil {
+		return nil, errors.New("unable to get undo record - " + err.Error())
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&spent); err != nil {
+		return nil, err
+	}
+
+	return spent, nil
+}
+
+// disconnectBlock reverts the UTXO set changes made by the block at the tip
+// of the chain in a view, restoring the outputs it spent.
+func disconnectBlock(v *utxoView, block *Block) error {
+	txn := v.txn
+	if block.Pruned() {
+		return fmt.Errorf("block %x is pruned and can't be disconnected", block.Hash)
+	}
+
+	// read the undo record for the block
+	spent, err := getUndo(txn, block.Hash)
+	if err != nil {
+		return err
+	}
+
+	// remove the outputs created by the block, and restore those it spent
+	for _, tx := range block.Transactions {
+		for outIdx := range tx.Outputs {
+			v.spend(tx.ID, outIdx)
+		}
+	}
+	for _, s := range spent {
+		v.add(s.TxID, s.Out, s.Output)
+	}
+	if err := v.flush(); err != nil {
+		return err
+	}
+
+	// remove the transactions of the block from the address index
+	if err := unindexAddresses(txn, block, spent); err != nil {
+		return err
+	}
+	if err := unindexSpends(txn, block); err != nil {
+		return err
+	}
+	if err := unindexTransactions(txn, block); err != nil {
+		return err
+	}
+	if err := unindexTokens(txn, block); err != nil {
+		return err
+	}
+	if err := removeChartPoint(txn, block); err != nil {
+		return err
+	}
+	if err := removeStats(txn, block); err != nil {
+		return err
+	}
+
+	// remove the undo record and height index entry, and move the tip back
+	if err := txn.Delete(undoKey(block.Hash)); err != nil {
+		return err
+	}
+	if err := txn.Delete(heightKey(block.Height)); err != nil {
+		return err
+	}
+	return txn.Set(utxoTipKey, block.PrevHash)
+}
+
+// utxoTip returns the hash of the block the UTXO set reflects, or nil if
+// the UTXO set has not been built.
+func (bc *BlockChain) utxoTip() ([]byte, error) {
+	var tip []byte
+
+	// initiate read only transaction on db to get the tip
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(utxoTipKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		tip, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New("unable to read UTXO tip - " + err.Error())
+	}
+
+	return tip, nil
+}
+
+// GetUnspentOutput returns a confirmed unspent transaction output, from the
+// UTXO cache if it holds it.
+func (bc *BlockChain) GetUnspentOutput(txID []byte, out int) (TxOutput, bool) {
+	if output, ok := bc.utxoCache.get(utxoKey(txID, out)); ok {
+		return output, true
+	}
+	var output TxOutput
+	found := false
+	generation := bc.utxoCache.currentGeneration()
+
+	// initiate read only transaction on db to look up the output
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(utxoKey(txID, out))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		output = deserializeOutput(value)
+		found = true
+		return nil
+	})
+	if err != nil {
+		bc.panicf("Unable to read unspent output from database: %s", err.Error())
+	}
+	if found {
+		bc.utxoCache.fill(utxoKey(txID, out), output, generation)
+	}
+
+	return output, found
+}
+
+// FindUnspentOutputs finds the unspent transaction outputs that can be
+// unlocked by pubKeyHash. Pending transactions in the mempool are taken
+// into account so that change from unconfirmed transactions can be spent
+// and outputs spent by pending transactions are not spent twice.
+func (bc *BlockChain) FindUnspentOutputs(pubKeyHash []byte) ([]UnspentOutput, error) {
+	var unspent []UnspentOutput
+	pending := bc.MempoolTransactions()
+	spentByPending := make(map[string]bool)
+
+	// collect the outputs spent by pending transactions
+	for _, tx := range pending {
+		for _, in := range tx.Inputs {
+			spentByPending[outpoint(in.ID, in.Out)] = true
+		}
+	}
+
+	// look up the confirmed outputs in the address index
+	confirmed, err := bc.findIndexedUnspentOutputs(pubKeyHash)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range confirmed {
+		if !spentByPending[outpoint(u.TxID, u.Out)] {
+			unspent = append(unspent, u)
+		}
+	}
+
+	// add the unspent outputs of pending transactions
+	for _, tx := range pending {
+		for outIdx, out := range tx.Outputs {
+			if out.IsLockedWithKey(pubKeyHash) && !spentByPending[outpoint(tx.ID, outIdx)] {
+				unspent = append(unspent, UnspentOutput{tx.ID, outIdx, out})
+			}
+		}
+	}
+
+	return unspent, nil
+}
+
+// ReindexUTXO rebuilds the UTXO set, undo records, height index, address
+// index and token index from the blocks in the chain.
+func (bc *BlockChain) ReindexUTXO() error {
+	return bc.ReindexUTXOContext(context.Background(), false, nil)
+}
+
+// ReindexUTXOContext rebuilds the UTXO set and indexes like ReindexUTXO,
+// calling progress, if set, after each block. If ctx is done it stops
+// between blocks with ErrJobCancelled. If resume is set and the UTXO set
+// reflects a block of the best chain, such as after an interrupted rebuild,
+// the blocks after it are connected instead of starting over.
+func (bc *BlockChain) ReindexUTXOContext(ctx context.Context, resume bool, progress func(JobProgress)) error {
+	from := 0
+	if resume {
+		tip, err := bc.utxoTip()
+		if err != nil {
+			return err
+		}
+		from = bc.resumeHeight(tip)
+	}
+
+	// remove the existing UTXO set, undo records and indexes, starting
+	// with the tip so an interrupted removal is not resumed
+	if from == 0 {
+		if err := bc.deleteTip(utxoTipKey); err != nil {
+			return err
+		}
+		for _, prefix := range [][]byte{utxoPrefix, undoPrefix, heightPrefix, addrPrefix, tokenPrefix, tokenUndoPrefix, chartPrefix, statsPrefix} {
+			if err := bc.deletePrefix(prefix); err != nil {
+				return err
+			}
+		}
+		bc.utxoCache.reset()
+	}
+
+	// collect the blocks of the chain from the tip back to genesis
+	var blocks []*Block
+	iter := bc.NewIterator()
+	for {
+		block := iter.Next()
+		blocks = append(blocks, block)
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	// connect each block from genesis forward, one db transaction per block
+	for i := len(blocks) - 1 - from; i >= 0; i-- {
+		if ctx.Err() != nil {
+			return ErrJobCancelled
+		}
+		block := blocks[i]
+		if block.Pruned() {
+			return fmt.Errorf("unable to reindex pruned block %x", block.Hash)
+		}
+		var view *utxoView
+		err := bc.DB.Update(func(txn *badger.Txn) error {
+			view = newUTXOView(txn, bc.utxoCache)
+
+			// blocks stored before heights were recorded have a height of 0
+			if height := len(blocks) - 1 - i; block.Height != height {
+				block.Height = height
+				if err := putBlock(txn, block); err != nil {
+					return err
+				}
+			}
+
+			if err := connectBlock(view, block); err != nil {
+				return err
+			}
+			return setChainWork(txn, block)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to reindex block %x - %s", block.Hash, err.Error())
+		}
+		view.commit()
+		if progress != nil {
+			progress(JobProgress{Done: len(blocks) - i, Total: len(blocks), Last: block.Hash})
+		}
+	}
+
+	return nil
+}
+
+// deletePrefix removes every key with the given prefix from the db.
+func (bc *BlockChain) deletePrefix(prefix []byte) error {
+	var keys [][]byte
+
+	// collect keys in a read only transaction
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.New("unable to read keys - " + err.Error())
+	}
+
+	// delete keys in batches so transactions don't grow too big
+	for len(keys) > 0 {
+		err := bc.DB.Update(func(txn *badger.Txn) error {
+			for len(keys) > 0 {
+				err := txn.Delete(keys[0])
+				if err == badger.ErrTxnTooBig {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				keys = keys[1:]
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.New("unable to delete keys - " + err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package blockchain
+
+import (
+	"sync"
+
+	"github.com/dgraph-io/badger"
+)
+
+// DefaultUTXOCacheSize is the number of unspent outputs cached by nodes
+// that don't set a cache size.
+const DefaultUTXOCacheSize = 100000
+
+// utxoCache keeps recently used and created unspent outputs in memory, so
+// validating pending transactions and connecting the blocks mining them
+// don't read every output they spend from the db. Outputs are cached by
+// their utxoKey.
+type utxoCache struct {
+	mutex   sync.Mutex
+	size    int
+	entries map[string]TxOutput
+
+	// generation changes whenever blocks change the UTXO set, so outputs
+	// read from the db before the change aren't cached after it
+	generation uint64
+}
+
+// newUTXOCache returns a cache of at most size outputs, or nil if size is
+// 0. The methods of a nil cache cache nothing.
+func newUTXOCache(size int) *utxoCache {
+	if size <= 0 {
+		return nil
+	}
+	return &utxoCache{size: size, entries: make(map[string]TxOutput)}
+}
+
+// get returns a cached output.
+func (c *utxoCache) get(key []byte) (TxOutput, bool) {
+	if c == nil {
+		return TxOutput{}, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	out, ok := c.entries[string(key)]
+	return out, ok
+}
+
+// currentGeneration returns the generation to pass to fill for an output
+// about to be read from the db.
+func (c *utxoCache) currentGeneration() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.generation
+}
+
+// fill caches an output read from the db, unless blocks changed the UTXO
+// set since generation was returned by currentGeneration.
+func (c *utxoCache) fill(key []byte, out TxOutput, generation uint64) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if generation == c.generation {
+		c.put(string(key), out)
+	}
+}
+
+// put caches an output, evicting others to stay within the size of the
+// cache. The caller holds c.mutex.
+func (c *utxoCache) put(key string, out TxOutput) {
+	for evict := range c.entries {
+		if len(c.entries) < c.size {
+			break
+		}
+		delete(c.entries, evict)
+	}
+	c.entries[key] = out
+}
+
+// apply updates the cache with the outputs created and spent by committed
+// db transactions, keyed by utxoKey, where spent outputs are nil.
+func (c *utxoCache) apply(changes map[string]*TxOutput) {
+	if c == nil || len(changes) == 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	for key, out := range changes {
+		if out == nil {
+			delete(c.entries, key)
+		} else {
+			c.put(key, *out)
+		}
+	}
+}
+
+// reset empties the cache, such as after the UTXO set was rebuilt.
+func (c *utxoCache) reset() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	c.entries = make(map[string]TxOutput)
+}
+
+// utxoView is the UTXO set as seen by a db transaction connecting and
+// disconnecting blocks. Outputs are read from its changes, then the cache,
+// then the db. Changes are tracked as dirty until flush writes them to the
+// db transaction, and commit applies them to the cache once the db
+// transaction committed, so a failed transaction leaves the cache as it was.
+type utxoView struct {
+	txn   *badger.Txn
+	cache *utxoCache
+
+	// dirty and flushed hold the outputs created and spent by the view,
+	// keyed by utxoKey, where spent outputs are nil
+	dirty   map[string]*TxOutput
+	flushed map[string]*TxOutput
+}
+
+// newUTXOView returns a view of the UTXO set in txn reading through cache,
+// which may be nil.
+func newUTXOView(txn *badger.Txn, cache *utxoCache) *utxoView {
+	return &utxoView{
+		txn:     txn,
+		cache:   cache,
+		dirty:   make(map[string]*TxOutput),
+		flushed: make(map[string]*TxOutput),
+	}
+}
+
+// get returns an unspent output, with found false if it is missing or
+// spent.
+func (v *utxoView) get(txID []byte, out int) (TxOutput, bool, error) {
+	key := utxoKey(txID, out)
+	for _, changes := range []map[string]*TxOutput{v.dirty, v.flushed} {
+		if output, ok := changes[string(key)]; ok {
+			if output == nil {
+				return TxOutput{}, false, nil
+			}
+			return *output, true, nil
+		}
+	}
+	if output, ok := v.cache.get(key); ok {
+		return output, true, nil
+	}
+
+	item, err := v.txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return TxOutput{}, false, nil
+	} else if err != nil {
+		return TxOutput{}, false, err
+	}
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return TxOutput{}, false, err
+	}
+	return deserializeOutput(data), true, nil
+}
+
+// add adds an unspent output.
+func (v *utxoView) add(txID []byte, out int, output TxOutput) {
+	v.dirty[string(utxoKey(txID, out))] = &output
+}
+
+// spend removes an output.
+func (v *utxoView) spend(txID []byte, out int) {
+	v.dirty[string(utxoKey(txID, out))] = nil
+}
+
+// flush writes the dirty outputs to the db transaction.
+func (v *utxoView) flush() error {
+	for key, output := range v.dirty {
+		var err error
+		if output == nil {
+			err = v.txn.Delete([]byte(key))
+		} else {
+			err = v.txn.Set([]byte(key), serializeOutput(*output))
+		}
+		if err != nil {
+			return err
+		}
+		v.flushed[key] = output
+		delete(v.dirty, key)
+	}
+	return nil
+}
+
+// commit applies the flushed outputs to the cache. It is called once the
+// db transaction committed, before another can change the UTXO set.
+func (v *utxoView) commit() {
+	v.cache.apply(v.flushed)
+}
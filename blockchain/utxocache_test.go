@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+)
+
+// checkUTXOCache fails the test if the UTXO cache of bc holds more than
+// its size or an output that isn't unspent in the db.
+func checkUTXOCache(t *testing.T, bc *BlockChain) {
+	t.Helper()
+	c := bc.utxoCache
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.entries) > c.size {
+		t.Fatalf("got %d cached outputs, more than %d", len(c.entries), c.size)
+	}
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		for key, cached := range c.entries {
+			item, err := txn.Get([]byte(key))
+			if err != nil {
+				t.Fatalf("cached output %x is not unspent: %s", key, err)
+			}
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if stored := deserializeOutput(value); !reflect.DeepEqual(stored, cached) {
+				t.Fatalf("cached output %+v of %x, want %+v", cached, key, stored)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUTXOCache(t *testing.T) {
+	bc := newTestChainWithConfig(t, Config{UTXOCacheSize: 3})
+	genesis := tip(t, bc)
+	allocation := genesis.Transactions[0]
+
+	// looking up an output caches it, and spending it removes it
+	if _, ok := bc.GetUnspentOutput(allocation.ID, 0); !ok {
+		t.Fatal("the genesis allocation is not unspent")
+	}
+	if _, ok := bc.utxoCache.get(utxoKey(allocation.ID, 0)); !ok {
+		t.Fatal("the genesis allocation was not cached")
+	}
+	tx := send(t, bc, alice, bob, 10, 0)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, alice)
+	checkUTXOCache(t, bc)
+	if _, ok := bc.GetUnspentOutput(allocation.ID, 0); ok {
+		t.Fatal("the spent genesis allocation is still unspent")
+	}
+
+	// outputs created by blocks are cached
+	if _, ok := bc.utxoCache.get(utxoKey(tx.ID, 0)); !ok {
+		t.Fatal("the output of the mined payment was not cached")
+	}
+
+	// a reorg restores the outputs the payment spent and removes its own
+	side := mineOn(t, bc, genesis, carol)
+	for _, block := range []*Block{side, mineOn(t, bc, side, carol)} {
+		if err := bc.AcceptBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkUTXOCache(t, bc)
+	if _, ok := bc.GetUnspentOutput(tx.ID, 0); ok {
+		t.Fatal("the output of the disconnected payment is still unspent")
+	}
+	if _, ok := bc.GetUnspentOutput(allocation.ID, 0); !ok {
+		t.Fatal("the genesis allocation was not restored")
+	}
+
+	// an invalid block leaves the cache unchanged
+	invalid := mineBlock(t, bc, tip(t, bc), []*Transaction{CoinbaseTx(carol.Address().String(), "", 1)})
+	if err := bc.AcceptBlock(invalid); err == nil {
+		t.Fatal("connected an invalid block")
+	}
+	checkUTXOCache(t, bc)
+
+	// rebuilding the UTXO set keeps the cache in step
+	if err := bc.ReindexUTXO(); err != nil {
+		t.Fatal(err)
+	}
+	checkUTXOCache(t, bc)
+}
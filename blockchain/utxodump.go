@@ -0,0 +1,349 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// utxoSetMagic identifies a file written by UTXOSet.WriteTo.
+var utxoSetMagic = []byte("GOUTXO01")
+
+// maxUTXOScriptSize is the largest public key hash accepted by
+// ReadUTXOSet.
+const maxUTXOScriptSize = 1024
+
+// utxoSetBatch is the number of outputs loadUTXOSet writes to the db in
+// each transaction.
+const utxoSetBatch = 1000
+
+// UTXOSet is the set of unspent outputs of the best chain after the block
+// at Height, ordered by transaction id and output index so that the same
+// set always has the same encoding and commitment.
+type UTXOSet struct {
+	Height    int
+	BlockHash Hash
+	Outputs   []UnspentOutput
+}
+
+// UTXOSetAt returns the unspent outputs of the best chain as they were
+// after the block at height.
+func (bc *BlockChain) UTXOSetAt(height int) (*UTXOSet, error) {
+	s, err := bc.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Discard()
+	return s.UTXOSetAt(height)
+}
+
+// UTXOSetAt returns the unspent outputs of the best chain of the snapshot
+// as they were after the block at height. The current set is rolled back
+// with the undo records of the blocks above height, which must not be
+// pruned.
+func (s *Snapshot) UTXOSetAt(height int) (*UTXOSet, error) {
+	if height < 0 || height > s.Height() {
+		return nil, fmt.Errorf("height %d is not in the chain of height %d", height, s.Height())
+	}
+	unspent := make(map[string]UnspentOutput)
+
+	// read the current UTXO set
+	it := s.txn.NewIterator(badger.DefaultIteratorOptions)
+	for it.Seek(utxoPrefix); it.ValidForPrefix(utxoPrefix); it.Next() {
+
+		// key is prefix + txID + 8 byte output index
+		key := it.Item().Key()
+		if len(key) != len(utxoPrefix)+HashLength+8 {
+			continue
+		}
+		value, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			it.Close()
+			return nil, err
+		}
+		txID := append([]byte{}, key[len(utxoPrefix):len(utxoPrefix)+HashLength]...)
+		outIdx := int(FromBytes(key[len(utxoPrefix)+HashLength:]))
+		unspent[outpoint(txID, outIdx)] = UnspentOutput{txID, outIdx, deserializeOutput(value)}
+	}
+	it.Close()
+
+	// disconnect the blocks above height from the set, newest first
+	for h := s.Height(); h > height; h-- {
+		block, err := s.GetBlockByHeight(h)
+		if err != nil {
+			return nil, err
+		}
+		if block.Pruned() {
+			return nil, fmt.Errorf("block %x is pruned and can't be rolled back", block.Hash)
+		}
+		spent, err := getUndo(s.txn, block.Hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range block.Transactions {
+			for outIdx := range tx.Outputs {
+				delete(unspent, outpoint(tx.ID, outIdx))
+			}
+		}
+		for _, sp := range spent {
+			unspent[outpoint(sp.TxID, sp.Out)] = UnspentOutput{sp.TxID, sp.Out, sp.Output}
+		}
+	}
+
+	block, err := s.GetBlockByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := HashFromBytes(block.Hash)
+	if err != nil {
+		return nil, err
+	}
+	set := &UTXOSet{Height: height, BlockHash: hash}
+	for _, u := range unspent {
+		set.Outputs = append(set.Outputs, u)
+	}
+	set.sort()
+
+	return set, nil
+}
+
+// sort orders the outputs by transaction id and output index.
+func (set *UTXOSet) sort() {
+	sort.Slice(set.Outputs, func(i, j int) bool {
+		a, b := set.Outputs[i], set.Outputs[j]
+		if c := bytes.Compare(a.TxID, b.TxID); c != 0 {
+			return c < 0
+		}
+		return a.Out < b.Out
+	})
+}
+
+// Supply returns the total value of the outputs in the set.
+func (set *UTXOSet) Supply() units.Amount {
+	var supply units.Amount
+	for _, u := range set.Outputs {
+		supply += u.Output.Value
+	}
+	return supply
+}
+
+// encode returns the encoding of the set without its commitment: the
+// height, block hash and number of outputs, then each output as its
+// transaction id, index, value and public key hash. Integers are 8 byte
+// big endian.
+func (set *UTXOSet) encode() []byte {
+	var buffer bytes.Buffer
+	buffer.Write(utxoSetMagic)
+	buffer.Write(ToBytes(int64(set.Height)))
+	buffer.Write(set.BlockHash.Bytes())
+	buffer.Write(ToBytes(int64(len(set.Outputs))))
+	for _, u := range set.Outputs {
+		buffer.Write(u.TxID)
+		buffer.Write(ToBytes(int64(u.Out)))
+		buffer.Write(ToBytes(int64(u.Output.Value)))
+		buffer.Write(ToBytes(int64(len(u.Output.PubKeyHash))))
+		buffer.Write(u.Output.PubKeyHash)
+	}
+	return buffer.Bytes()
+}
+
+// Commitment returns the SHA-256 hash of the encoding of the set, which
+// identifies the set and is written after it by WriteTo.
+func (set *UTXOSet) Commitment() Hash {
+	return sha256.Sum256(set.encode())
+}
+
+// WriteTo writes the set to w followed by its commitment.
+func (set *UTXOSet) WriteTo(w io.Writer) (int64, error) {
+	data := set.encode()
+	commitment := sha256.Sum256(data)
+	n, err := w.Write(append(data, commitment[:]...))
+	return int64(n), err
+}
+
+// ReadUTXOSet reads a set written by WriteTo, verifying its commitment and
+// that its outputs are in order.
+func ReadUTXOSet(r io.Reader) (*UTXOSet, error) {
+	buffered := bufio.NewReader(r)
+	hasher := sha256.New()
+	in := io.TeeReader(buffered, hasher)
+
+	// read reads n bytes of the set
+	read := func(n int) ([]byte, error) {
+		data := make([]byte, n)
+		if _, err := io.ReadFull(in, data); err != nil {
+			return nil, errors.New("UTXO set is truncated - " + err.Error())
+		}
+		return data, nil
+	}
+	readInt := func() (int64, error) {
+		data, err := read(8)
+		if err != nil {
+			return 0, err
+		}
+		return FromBytes(data), nil
+	}
+
+	magic, err := read(len(utxoSetMagic))
+	if err != nil || !bytes.Equal(magic, utxoSetMagic) {
+		return nil, errors.New("file is not a UTXO set")
+	}
+	height, err := readInt()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := read(HashLength)
+	if err != nil {
+		return nil, err
+	}
+	count, err := readInt()
+	if err != nil {
+		return nil, err
+	}
+	if height < 0 || count < 0 {
+		return nil, errors.New("UTXO set has a negative height or count")
+	}
+	set := &UTXOSet{Height: int(height)}
+	copy(set.BlockHash[:], hash)
+
+	// read each output
+	for i := int64(0); i < count; i++ {
+		txID, err := read(HashLength)
+		if err != nil {
+			return nil, err
+		}
+		out, err := readInt()
+		if err != nil {
+			return nil, err
+		}
+		value, err := readInt()
+		if err != nil {
+			return nil, err
+		}
+		size, err := readInt()
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 || size > maxUTXOScriptSize {
+			return nil, fmt.Errorf("output %x:%d has a public key hash of %d bytes", txID, out, size)
+		}
+		pubKeyHash, err := read(int(size))
+		if err != nil {
+			return nil, err
+		}
+		u := UnspentOutput{txID, int(out), TxOutput{Value: units.Amount(value), PubKeyHash: pubKeyHash}}
+		if n := len(set.Outputs); n > 0 {
+			prev := set.Outputs[n-1]
+			if c := bytes.Compare(prev.TxID, u.TxID); c > 0 || c == 0 && prev.Out >= u.Out {
+				return nil, fmt.Errorf("output %x:%d is out of order", txID, out)
+			}
+		}
+		set.Outputs = append(set.Outputs, u)
+	}
+
+	// verify the commitment following the set
+	want := hasher.Sum(nil)
+	commitment := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(buffered, commitment); err != nil {
+		return nil, errors.New("UTXO set has no commitment - " + err.Error())
+	}
+	if !bytes.Equal(commitment, want) {
+		return nil, errors.New("UTXO set does not match its commitment")
+	}
+
+	return set, nil
+}
+
+// connectHeader makes a block extending parent, which is below the block of
+// a UTXO set being imported, the tip by its header. Its proof of work and
+// that it follows parent are checked, but not its transactions, which are
+// not kept, so it is stored pruned.
+func (bc *BlockChain) connectHeader(block, parent *Block) error {
+	if err := block.checkVersion(); err != nil {
+		return err
+	}
+	if err := checkProof(block, bc.mining.authorities); err != nil {
+		return err
+	}
+	if err := bc.checkParent(block, parent); err != nil {
+		return err
+	}
+	block.MerkleRoot = block.HashTransactions()
+	block.Transactions = nil
+
+	// store the header with the index entries of a pruned block
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		if err := putBlock(txn, block); err != nil {
+			return err
+		}
+		if err := setChainWork(txn, block); err != nil {
+			return err
+		}
+		if err := connectBlock(newUTXOView(txn, nil), block); err != nil {
+			return err
+		}
+		if err := txn.Delete(undoKey(block.Hash)); err != nil {
+			return err
+		}
+		if err := txn.Set(prunedHeightKey, ToBytes(int64(block.Height+1))); err != nil {
+			return err
+		}
+		return txn.Set([]byte("lh"), block.Hash)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to store header of block %x - %s", block.Hash, err.Error())
+	}
+	bc.setTip(block.Hash)
+	return nil
+}
+
+// loadUTXOSet replaces the UTXO set with set, which must be of the block at
+// the tip, and records its value as the supply in the chain stats of the
+// tip, which are partial as its pruned blocks are left out of them.
+func (bc *BlockChain) loadUTXOSet(set *UTXOSet, tip *Block) error {
+	if !bytes.Equal(set.BlockHash.Bytes(), tip.Hash) {
+		return fmt.Errorf("UTXO set is of block %s, not block %x at height %d", set.BlockHash, tip.Hash, tip.Height)
+	}
+	if err := bc.deletePrefix(utxoPrefix); err != nil {
+		return err
+	}
+
+	// write the outputs in batches, so large sets fit in db transactions
+	for start := 0; start < len(set.Outputs); start += utxoSetBatch {
+		end := start + utxoSetBatch
+		if end > len(set.Outputs) {
+			end = len(set.Outputs)
+		}
+		err := bc.DB.Update(func(txn *badger.Txn) error {
+			for _, u := range set.Outputs[start:end] {
+				if err := txn.Set(utxoKey(u.TxID, u.Out), serializeOutput(u.Output)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.New("unable to load UTXO set - " + err.Error())
+		}
+	}
+
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		point, err := getStatsPoint(txn, tip.Height)
+		if err != nil {
+			return err
+		}
+		point.Supply = set.Supply()
+		return putStatsPoint(txn, tip.Height, point)
+	})
+	if err != nil {
+		return errors.New("unable to record supply of UTXO set - " + err.Error())
+	}
+	return nil
+}
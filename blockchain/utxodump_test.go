@@ -0,0 +1,146 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/edwintcloud/gochain/logging"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestUTXOSetAt(t *testing.T) {
+	bc := newTestChain(t)
+	before, err := bc.UTXOSetAt(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// spend from alice in a block
+	tx := send(t, bc, alice, bob, units.Coin, 0)
+	if err := bc.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, carol)
+
+	// the set at genesis is the same after the block, and differs from
+	// the set at the tip
+	again, err := bc.UTXOSetAt(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Commitment() != before.Commitment() || again.Supply() != genesisAllocation {
+		t.Fatalf("got supply %s and commitment %s at genesis, want %s and %s",
+			again.Supply(), again.Commitment(), genesisAllocation, before.Commitment())
+	}
+	tipSet, err := bc.UTXOSetAt(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tipSet.Commitment() == before.Commitment() || tipSet.Supply() != genesisAllocation+Subsidy {
+		t.Fatalf("got supply %s at the tip, want %s", tipSet.Supply(), genesisAllocation+Subsidy)
+	}
+	if _, err := bc.UTXOSetAt(2); err == nil {
+		t.Fatal("got a set above the tip")
+	}
+
+	// a written set reads back, and a changed one does not
+	var buffer bytes.Buffer
+	if _, err := tipSet.WriteTo(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	data := buffer.Bytes()
+	read, err := ReadUTXOSet(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Commitment() != tipSet.Commitment() || read.Height != 1 || read.BlockHash != tipSet.BlockHash {
+		t.Fatalf("read set %s at height %d, want %s", read.Commitment(), read.Height, tipSet.Commitment())
+	}
+	data[len(data)-HashLength-1] ^= 1
+	if _, err := ReadUTXOSet(bytes.NewReader(data)); err == nil {
+		t.Fatal("read a set that does not match its commitment")
+	}
+}
+
+func TestImportChainFromUTXOSet(t *testing.T) {
+	bc := newTestChain(t)
+	for _, to := range []*wallet.Wallet{bob, carol, bob} {
+		if err := bc.AddToMempool(send(t, bc, alice, to, units.Coin, 0)); err != nil {
+			t.Fatal(err)
+		}
+		minePending(t, bc, carol)
+	}
+	set, err := bc.UTXOSetAt(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export bytes.Buffer
+	if _, err := bc.ExportChain(&export); err != nil {
+		t.Fatal(err)
+	}
+	want, err := bc.UTXOSetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a set without the trusted commitment is refused
+	cfg := Config{Path: t.TempDir(), Genesis: testGenesis(), Logger: logging.Discard}
+	if _, err := ImportChainFromUTXOSet(cfg, bytes.NewReader(export.Bytes()), set, Hash{}); err == nil {
+		t.Fatal("imported a set that does not have the commitment")
+	}
+
+	// the blocks up to the set are stored pruned, and the block after it
+	// is connected to the set
+	imported, err := ImportChainFromUTXOSet(cfg, bytes.NewReader(export.Bytes()), set, set.Commitment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Height() != 3 {
+		t.Fatalf("imported chain has height %d, want 3", imported.Height())
+	}
+	for height := 1; height <= 3; height++ {
+		block, err := imported.GetBlockByHeight(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block.Pruned() != (height <= 2) {
+			t.Fatalf("block at height %d has pruned %t", height, block.Pruned())
+		}
+	}
+	got, err := imported.UTXOSetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Commitment != want.Commitment || got.Supply != want.Supply {
+		t.Fatalf("imported UTXO set %s of supply %s, want %s of %s", got.Commitment, got.Supply, want.Commitment, want.Supply)
+	}
+	stats, err := imported.ChainStats(DefaultStatsWindow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Supply != want.Supply || !stats.Partial {
+		t.Fatalf("imported chain stats have supply %s and partial %t", stats.Supply, stats.Partial)
+	}
+
+	// the imported chain opens without rebuilding its indexes from the
+	// pruned blocks
+	if err := imported.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.Height() != 3 {
+		t.Fatalf("reopened chain has height %d, want 3", reopened.Height())
+	}
+
+	// a set of a block not in the export is refused
+	set.BlockHash[0] ^= 1
+	cfg.Path = t.TempDir()
+	if _, err := ImportChainFromUTXOSet(cfg, bytes.NewReader(export.Bytes()), set, set.Commitment()); err == nil {
+		t.Fatal("imported a set of a block not in the chain")
+	}
+}
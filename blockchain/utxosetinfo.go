@@ -0,0 +1,64 @@
+package blockchain
+
+import (
+	"errors"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// UTXOSetInfo summarizes the UTXO set of the best chain after the block at
+// Height. Commitment is the hash of the set, the same on every node with the
+// same set. MaxSupply is the most the set may hold: the value of the genesis
+// block plus the subsidy of every block after it. Supply may be less, as
+// miners can claim less than the subsidy and fees, but is inflated if it is
+// more.
+type UTXOSetInfo struct {
+	Height     int          `json:"height"`
+	BlockHash  string       `json:"blockHash"`
+	Outputs    int          `json:"outputs"`
+	Supply     units.Amount `json:"supply"`
+	Commitment string       `json:"commitment"`
+	MaxSupply  units.Amount `json:"maxSupply"`
+	Inflated   bool         `json:"inflated"`
+}
+
+// UTXOSetInfo reads the whole UTXO set at the tip and summarizes it, so
+// operators and auditors can check that no coins were minted beyond the
+// subsidy schedule.
+func (bc *BlockChain) UTXOSetInfo() (*UTXOSetInfo, error) {
+	s, err := bc.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Discard()
+
+	set, err := s.UTXOSetAt(s.Height())
+	if err != nil {
+		return nil, errors.New("unable to read UTXO set - " + err.Error())
+	}
+	genesis, err := s.GetBlockByHeight(0)
+	if err != nil {
+		return nil, errors.New("unable to read genesis block - " + err.Error())
+	}
+
+	// the genesis block creates its allocations and reward, and each block
+	// after it at most the subsidy
+	var maxSupply units.Amount
+	for _, tx := range genesis.Transactions {
+		for _, out := range tx.Outputs {
+			maxSupply += out.Value
+		}
+	}
+	maxSupply += Subsidy * units.Amount(set.Height)
+
+	info := &UTXOSetInfo{
+		Height:     set.Height,
+		BlockHash:  set.BlockHash.String(),
+		Outputs:    len(set.Outputs),
+		Supply:     set.Supply(),
+		Commitment: set.Commitment().String(),
+		MaxSupply:  maxSupply,
+	}
+	info.Inflated = info.Supply > info.MaxSupply
+	return info, nil
+}
@@ -0,0 +1,46 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger"
+)
+
+func TestUTXOSetInfo(t *testing.T) {
+	bc := newTestChain(t)
+	if err := bc.AddToMempool(send(t, bc, alice, bob, 10, 400)); err != nil {
+		t.Fatal(err)
+	}
+	minePending(t, bc, alice)
+
+	// the miner claims the subsidy and the fee, which is not new supply
+	info, err := bc.UTXOSetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := bc.UTXOSetAt(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Height != 1 || info.Outputs != len(set.Outputs) || info.Commitment != set.Commitment().String() {
+		t.Fatalf("got info %+v, want the set at height 1", info)
+	}
+	if info.Supply != info.MaxSupply || info.Inflated {
+		t.Fatalf("got supply %d of at most %d, want the whole schedule", info.Supply, info.MaxSupply)
+	}
+
+	// an output minted outside the rules inflates the supply
+	err = bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(utxoKey(make([]byte, HashLength), 0), serializeOutput(*NewTXOutput(1, bob.Address().String())))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inflated, err := bc.UTXOSetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inflated.Inflated || inflated.Supply != info.Supply+1 || inflated.Commitment == info.Commitment {
+		t.Fatalf("got info %+v after minting an output, want it inflated", inflated)
+	}
+}
@@ -0,0 +1,228 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/consensus"
+	"github.com/edwintcloud/gochain/keys"
+)
+
+// ValidateBlock verifies a block received from elsewhere before it is
+// accepted: its proof of work, that its hash commits to its transactions
+// through their Merkle root, that it links to a known parent at the right
+// height and difficulty, and, if it builds on the tip of the best chain,
+// its coinbase, the signatures of its transactions unless it is below the
+// latest checkpoint, and that they only spend unspent outputs. The
+// transactions of a block extending a side chain are verified against the
+// UTXO set of that chain if it becomes the best chain.
+func (bc *BlockChain) ValidateBlock(b *Block) error {
+	if err := checkBlock(b, bc.mining.authorities); err != nil {
+		return err
+	}
+	parent, err := bc.GetBlock(b.PrevHash)
+	if err != nil {
+		return fmt.Errorf("parent of block %x is unknown", b.Hash)
+	}
+	if err := bc.checkParent(b, parent); err != nil {
+		return err
+	}
+	if !bytes.Equal(b.PrevHash, bc.Tip()) {
+		return nil
+	}
+
+	// verify the transactions against the UTXO set without connecting them
+	return bc.DB.View(func(txn *badger.Txn) error {
+		return verifyBlockTransactions(newUTXOView(txn, bc.utxoCache), b, bc.scheme, bc.verifiesSignatures(b))
+	})
+}
+
+// verifiesSignatures returns whether the signatures of the transactions of
+// a block are verified when it is connected, which they are not below the
+// latest checkpoint.
+func (bc *BlockChain) verifiesSignatures(b *Block) bool {
+	return b.Height > bc.CheckpointHeight()
+}
+
+// checkBlock verifies the parts of a block that don't depend on the chain:
+// it must be of a known version and hold transactions with inputs that only
+// use the fields of their kind, positive value outputs whose total does not
+// overflow and unique ids that match their contents, within the size limits, its hash must be the hash of its proof of work
+// data and meet its target, or be sealed by one of authorities if set, and
+// its header must hold the Merkle root of its transactions.
+func checkBlock(b *Block, authorities *Authorities) error {
+	if b.Pruned() || len(b.Transactions) == 0 {
+		return fmt.Errorf("block %x has no transactions", b.Hash)
+	}
+	if err := b.checkVersion(); err != nil {
+		return err
+	}
+
+	ids := make(map[string]bool)
+	for _, tx := range b.Transactions {
+		if err := tx.checkInputs(); err != nil {
+			return err
+		}
+		if err := tx.checkDataOutputs(); err != nil {
+			return err
+		}
+		if _, err := consensus.CheckOutputs(tx.consensusTx()); err != nil {
+			return err
+		}
+		if !tx.hasValidID() {
+			return fmt.Errorf("transaction %x of block %x has an id that does not match its contents", tx.ID, b.Hash)
+		}
+		if ids[string(tx.ID)] {
+			return fmt.Errorf("block %x holds transaction %x twice", b.Hash, tx.ID)
+		}
+		ids[string(tx.ID)] = true
+	}
+	if err := checkBlockSize(b); err != nil {
+		return err
+	}
+	if err := checkProof(b, authorities); err != nil {
+		return err
+	}
+
+	// the proof of work commits to the transactions, so the header must
+	// hold their root for the proof of the header alone to hold
+	if b.MerkleRoot != nil && !bytes.Equal(b.MerkleRoot, merkleRoot(b.Transactions)) {
+		return fmt.Errorf("block %x has a Merkle root that does not match its transactions", b.Hash)
+	}
+	return nil
+}
+
+// checkProof verifies that the hash of a block is the hash of its proof of
+// work data and meets its target, or, if authorities are set, that it is
+// sealed by the authority whose turn it is. The proof of work data includes
+// the Merkle root, so a block whose transactions were changed no longer has
+// the hash it claims. Genesis blocks are mined on every network.
+func checkProof(b *Block, authorities *Authorities) error {
+	if hash := NewProof(b).Sum(b.Nonce); hash == nil || !bytes.Equal(hash, b.Hash) {
+		return fmt.Errorf("block %x does not match its contents", b.Hash)
+	}
+	if authorities != nil && b.Height > 0 {
+		return authorities.checkSeal(&b.BlockHeader)
+	}
+	if len(b.Signature) != 0 {
+		return fmt.Errorf("block %x is sealed, but its network is not a proof of authority network", b.Hash)
+	}
+	if !consensus.CheckProofOfWork(b.Hash, b.GetDifficulty()) {
+		return fmt.Errorf("block %x has an invalid proof of work", b.Hash)
+	}
+
+	return nil
+}
+
+// checkParent verifies that a block follows parent at the next height and
+// the allowed difficulty, and matches the checkpoint at its height.
+func (bc *BlockChain) checkParent(b, parent *Block) error {
+	if b.Height != parent.Height+1 {
+		return fmt.Errorf("block %x has height %d, expected %d", b.Hash, b.Height, parent.Height+1)
+	}
+	if hash, ok := b.PowHash(); !ok || hash.ID() != bc.mining.powHash().ID() {
+		return fmt.Errorf("block %x is not mined with the %s proof of work hash of the network", b.Hash, bc.mining.powHash().Name())
+	}
+	if err := bc.checkDifficulty(b, parent); err != nil {
+		return err
+	}
+	return bc.checkCheckpoint(b)
+}
+
+// hasValidID returns whether the id of a transaction is the hash of the
+// transaction without its id and signatures, which are made after the id
+// is generated.
+func (tx *Transaction) hasValidID() bool {
+	txCopy := *tx
+	txCopy.Inputs = make([]TxInput, len(tx.Inputs))
+	for i, in := range tx.Inputs {
+		in.Signature = nil
+		txCopy.Inputs[i] = in
+	}
+	return bytes.Equal(txCopy.GenerateHash(), tx.ID)
+}
+
+// verifyBlockTransactions verifies the transactions of a block about to be
+// connected to the tip of the UTXO set in txn. The first transaction must
+// be the only coinbase, every other transaction must be signed, must spend
+// unspent outputs and must not spend more than its inputs, and the coinbase
+// may claim at most the subsidy plus fees, with every output value checked
+// and no total overflowing first. Transactions may spend outputs of
+// earlier transactions in the same block. Signatures are only verified if
+// signatures is set, with scheme.
+func verifyBlockTransactions(v *utxoView, block *Block, scheme keys.Scheme, signatures bool) error {
+	return verifyBlockSpends(v.get, block, scheme, signatures)
+}
+
+// outputLookup returns an unspent output of the UTXO set a block is
+// verified against, with found false if it is missing or spent.
+type outputLookup func(txID []byte, out int) (output TxOutput, found bool, err error)
+
+// UnspentOutput returns an output as the consensus rules see it, so a
+// lookup is a consensus.UTXOView.
+func (lookup outputLookup) UnspentOutput(p consensus.OutPoint) (consensus.Output, bool, error) {
+	out, found, err := lookup(p.TxID, p.Index)
+	return out.consensusOutput(), found, err
+}
+
+// consensusOutput returns an output as the consensus rules see it.
+func (out TxOutput) consensusOutput() consensus.Output {
+	return consensus.Output{Value: out.Value, Lock: out.PubKeyHash, Data: out.IsData()}
+}
+
+// consensusTx returns a transaction as the consensus rules see it.
+func (tx *Transaction) consensusTx() consensus.Tx {
+	c := consensus.Tx{ID: tx.ID, Coinbase: tx.IsCoinbase()}
+	if !c.Coinbase {
+		for _, in := range tx.Inputs {
+			c.Inputs = append(c.Inputs, consensus.OutPoint{TxID: in.ID, Index: in.Out})
+		}
+	}
+	for _, out := range tx.Outputs {
+		c.Outputs = append(c.Outputs, out.consensusOutput())
+	}
+	return c
+}
+
+// verifyBlockSpends verifies the transactions of a block like
+// verifyBlockTransactions with the consensus rules, looking up the outputs
+// they spend with lookup.
+func verifyBlockSpends(lookup outputLookup, block *Block, scheme keys.Scheme, signatures bool) error {
+	txs := make([]consensus.Tx, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		txs[i] = tx.consensusTx()
+	}
+
+	// signatures are made over the outputs the inputs spend, rebuilt as
+	// the previous transactions of the transaction
+	var check consensus.SignatureCheck
+	if signatures {
+		check = func(i int, spent []consensus.Output) bool {
+			tx := block.Transactions[i]
+			prevTXs := make(map[string]Transaction)
+			for j, in := range tx.Inputs {
+				addPrevOutput(prevTXs, in, TxOutput{Value: spent[j].Value, PubKeyHash: spent[j].Lock})
+			}
+			return tx.Verify(scheme, prevTXs)
+		}
+	}
+
+	return consensus.CheckBlockSpends(lookup, txs, check)
+}
+
+// addPrevOutput adds the output spent by an input to the previous
+// transactions used to verify the signature of the input.
+func addPrevOutput(prevTXs map[string]Transaction, in TxInput, out TxOutput) {
+	id := hex.EncodeToString(in.ID)
+	prevTX, ok := prevTXs[id]
+	if !ok {
+		prevTX = Transaction{ID: in.ID}
+	}
+	for len(prevTX.Outputs) <= in.Out {
+		prevTX.Outputs = append(prevTX.Outputs, TxOutput{})
+	}
+	prevTX.Outputs[in.Out] = out
+	prevTXs[id] = prevTX
+}
@@ -0,0 +1,186 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// rehash sets the hash of a block to the hash of its proof of work data,
+// as if it had been mined with its nonce.
+func rehash(b *Block) {
+	hash := sha256.Sum256(NewProof(b).InitData(b.Nonce))
+	b.Hash = hash[:]
+}
+
+func TestValidateBlock(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		block func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block
+		want  string
+	}{
+		{
+			name: "valid",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				return mineOn(t, bc, parent, carol, send(t, bc, bob, carol, units.Coin, 1))
+			},
+		},
+		{
+			name: "wrong hash",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				b := mineOn(t, bc, parent, carol)
+				b.Hash[len(b.Hash)-1] ^= 0xff
+				return b
+			},
+			want: "does not match its contents",
+		},
+		{
+			name: "invalid proof of work",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				b := mineOn(t, bc, parent, carol)
+				for b.Nonce = 0; NewProof(b).Validate(); b.Nonce++ {
+				}
+				rehash(b)
+				return b
+			},
+			want: "invalid proof of work",
+		},
+		{
+			name: "unknown parent",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				return mineOn(t, bc, mineOn(t, bc, parent, carol), carol)
+			},
+			want: "parent",
+		},
+		{
+			name: "wrong height",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				// the proof of work commits to the height, so mine it there
+				wrong := *parent
+				wrong.Height++
+				return mineOn(t, bc, &wrong, carol)
+			},
+			want: "has height",
+		},
+		{
+			name: "changed transaction",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				b := mineOn(t, bc, parent, carol)
+				b.Transactions[0].Outputs[0].Value++
+				return b
+			},
+			want: "id that does not match",
+		},
+		{
+			name: "different Merkle root",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				b := mineOn(t, bc, parent, carol)
+				b.Transactions[0].Outputs[0].Value--
+				b.Transactions[0].ID = b.Transactions[0].GenerateHash()
+				return b
+			},
+			want: "does not match its contents",
+		},
+		{
+			name: "coinbase with a public key",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				coinbase := CoinbaseTx(carol.Address().String(), "", 0)
+				coinbase.Inputs[0].PubKey = carol.PublicKey
+				return mineBlock(t, bc, parent, []*Transaction{coinbase})
+			},
+			want: "has a public key or signature",
+		},
+		{
+			name: "input with coinbase data",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				tx := send(t, bc, bob, carol, units.Coin, 0)
+				tx.Inputs[0].CoinbaseData = []byte("data")
+				return mineOn(t, bc, parent, carol, tx)
+			},
+			want: "has coinbase data",
+		},
+		{
+			name: "inflated coinbase",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				return mineBlock(t, bc, parent, []*Transaction{CoinbaseTx(carol.Address().String(), "", 1)})
+			},
+			want: "more than the subsidy",
+		},
+		{
+			name: "overflowing coinbase",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				coinbase := CoinbaseTx(carol.Address().String(), "", 0)
+				out := coinbase.Outputs[0]
+				coinbase.Outputs = nil
+				for _, value := range []units.Amount{units.Amount(^uint(0) >> 1), units.Amount(^uint(0) >> 1), 2} {
+					out.Value = value
+					coinbase.Outputs = append(coinbase.Outputs, out)
+				}
+				coinbase.SetID()
+				return mineBlock(t, bc, parent, []*Transaction{coinbase})
+			},
+			want: "overflow",
+		},
+		{
+			name: "zero output",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				tx, err := bc.NewTransaction(bob, carol.Address().String(), units.Coin, 0, "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				tx.Outputs[0].Value = 0
+				tx.SetID()
+				if err := bc.SignTransaction(tx, bob); err != nil {
+					t.Fatal(err)
+				}
+				return mineOn(t, bc, parent, carol, tx)
+			},
+			want: "not positive",
+		},
+		{
+			name: "bad signature",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				tx := send(t, bc, bob, carol, units.Coin, 0)
+				tx.Inputs[0].Signature[0] ^= 0xff
+				return mineOn(t, bc, parent, carol, tx)
+			},
+			want: "invalid signature",
+		},
+		{
+			name: "double spend",
+			block: func(t *testing.T, bc *BlockChain, parent *Block, spent *Transaction) *Block {
+				return mineOn(t, bc, parent, carol, spent)
+			},
+			want: "missing or spent output",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bc := newTestChain(t)
+
+			// spend the genesis allocation in the tip, keeping another
+			// transaction spending it
+			spent := send(t, bc, alice, carol, units.Coin, 0)
+			parent := mineOn(t, bc, tip(t, bc), alice, send(t, bc, alice, bob, 10*units.Coin, 0))
+			if err := bc.AcceptBlock(parent); err != nil {
+				t.Fatal(err)
+			}
+
+			block := c.block(t, bc, parent, spent)
+			err := bc.ValidateBlock(block)
+			if c.want == "" && err != nil {
+				t.Fatal(err)
+			}
+			if c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)) {
+				t.Fatalf("got error %v, want %q", err, c.want)
+			}
+
+			// validating a block doesn't connect it
+			if !bytes.Equal(bc.Tip(), parent.Hash) {
+				t.Fatal("validated block was connected")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+
+	"github.com/dgraph-io/badger"
+	"github.com/edwintcloud/gochain/events"
+)
+
+// webhookPrefix is the key prefix of the webhooks registered with the
+// node, followed by their URL.
+var webhookPrefix = []byte("webhook-")
+
+// webhookKey returns the db key of the webhook of url.
+func webhookKey(url string) []byte {
+	return append(append([]byte{}, webhookPrefix...), url...)
+}
+
+// AddWebhook stores a webhook, replacing the topics of the webhook of its
+// URL if there is one, so it is posted events whenever the node serves.
+func (bc *BlockChain) AddWebhook(w events.Webhook) error {
+	if err := w.Validate(); err != nil {
+		return errors.New("unable to add webhook - " + err.Error())
+	}
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(w); err != nil {
+		return errors.New("unable to add webhook - " + err.Error())
+	}
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(webhookKey(w.URL), buffer.Bytes())
+	})
+	if err != nil {
+		return errors.New("unable to add webhook - " + err.Error())
+	}
+	return nil
+}
+
+// RemoveWebhook removes the webhook of url, returning whether there was
+// one.
+func (bc *BlockChain) RemoveWebhook(url string) (bool, error) {
+	found := false
+	err := bc.DB.Update(func(txn *badger.Txn) error {
+		key := webhookKey(url)
+		if _, err := txn.Get(key); err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		found = true
+		return txn.Delete(key)
+	})
+	if err != nil {
+		return false, errors.New("unable to remove webhook - " + err.Error())
+	}
+	return found, nil
+}
+
+// Webhooks returns the stored webhooks ordered by URL.
+func (bc *BlockChain) Webhooks() ([]events.Webhook, error) {
+	webhooks := []events.Webhook{}
+	err := bc.DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(webhookPrefix); it.ValidForPrefix(webhookPrefix); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var w events.Webhook
+			if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&w); err != nil {
+				return err
+			}
+			webhooks = append(webhooks, w)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("unable to read webhooks - " + err.Error())
+	}
+	return webhooks, nil
+}
@@ -0,0 +1,42 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/edwintcloud/gochain/events"
+)
+
+func TestWebhooks(t *testing.T) {
+	bc := newTestChain(t)
+	if err := bc.AddWebhook(events.Webhook{URL: "http://localhost/a", Topics: []string{"unknown"}}); err == nil {
+		t.Fatal("added a webhook with an unknown topic")
+	}
+
+	// adding the webhook of a URL again replaces its topics
+	for _, w := range []events.Webhook{
+		{URL: "http://localhost/b"},
+		{URL: "http://localhost/a", Topics: []string{events.NewTx}},
+		{URL: "http://localhost/a", Topics: []string{events.NewBlock, events.Reorg}},
+	} {
+		if err := bc.AddWebhook(w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	webhooks, err := bc.Webhooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(webhooks) != 2 || webhooks[0].URL != "http://localhost/a" || len(webhooks[0].Topics) != 2 {
+		t.Fatalf("got webhooks %+v, want a with its new topics and b", webhooks)
+	}
+
+	if found, err := bc.RemoveWebhook("http://localhost/a"); err != nil || !found {
+		t.Fatalf("removing the webhook returned %v, %v", found, err)
+	}
+	if found, _ := bc.RemoveWebhook("http://localhost/a"); found {
+		t.Fatal("removed the webhook twice")
+	}
+	if webhooks, _ := bc.Webhooks(); len(webhooks) != 1 {
+		t.Fatalf("got webhooks %+v, want only b", webhooks)
+	}
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// commands are the names of every command, which can be abbreviated to any
+// prefix only one of them starts with.
+var commands = []string{
+	"getbal", "create", "print", "getblock", "send", "propose", "approve",
+	"submit", "mine", "lockunspent", "listlockunspent", "sweepkey",
+	"watchaddress", "exportchain", "importchain", "runscript", "createrawtx",
+	"signrawtx", "sendrawtx", "tail", "history", "reindexaddresses",
+	"getblocktemplate", "submitblock", "minework", "perfstats",
+	"verifychain", "getmerkleproof", "freeze", "unfreeze", "serve",
+	"createwallet", "listwallets", "listaddresses", "importaddress",
+	"importkey", "exportkey", "encryptwallet", "walletunlock", "walletlock",
+	"listdescriptors", "importdescriptor", "minerreport", "selftest",
+	"anchor", "findanchor", "importwallet", "dumputxoset", "issuetoken",
+	"sendtoken", "rescan", "jobs", "gettx", "coinage", "compactdb",
+	"simulate-difficulty", "update", "signrelease", "version",
+	"testmempoolaccept", "getblockcandidate", "estimatefee", "bumpfee",
+	"migrateblocks", "feehistogram", "watchdeposits", "mempoolinfo",
+	"webhooks", "stats", "gettxoutsetinfo", "addcontact", "listcontacts",
+	"setlabel", "sendmany", "removeaddress", "startminer", "forks",
+}
+
+// builtinAliases are short names for common commands.
+var builtinAliases = map[string]string{
+	"bal":       "getbal",
+	"block":     "getblock",
+	"addresses": "listaddresses",
+	"template":  "getblocktemplate",
+}
+
+// aliasesFromEnv returns the built in aliases with the aliases in the
+// ALIASES env var, written as NAME=COMMAND [ARGS...] separated by
+// semicolons, such as "mybal=getbal -address ADDRESS;m=mine -address
+// ADDRESS". Aliases in the env var replace built in aliases of the same
+// name, but not commands.
+func aliasesFromEnv() (map[string]string, error) {
+	aliases := make(map[string]string)
+	for name, command := range builtinAliases {
+		aliases[name] = command
+	}
+
+	for _, alias := range strings.Split(os.Getenv("ALIASES"), ";") {
+		if alias = strings.TrimSpace(alias); alias == "" {
+			continue
+		}
+		parts := strings.SplitN(alias, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || len(strings.Fields(parts[1])) == 0 {
+			return nil, fmt.Errorf("alias %q is not NAME=COMMAND [ARGS...]", alias)
+		}
+		aliases[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return aliases, nil
+}
+
+// expandCommand returns args with the command in args[1] expanded: an
+// alias is replaced by its command and arguments, followed by the rest of
+// args, and an abbreviation is replaced by the only command starting with
+// it. Commands are not expanded, and neither is an abbreviation of more
+// than one command, which is an error.
+func expandCommand(args []string, aliases map[string]string) ([]string, error) {
+	if len(args) < 2 {
+		return args, nil
+	}
+	name := args[1]
+	for _, command := range commands {
+		if name == command {
+			return args, nil
+		}
+	}
+
+	// replace aliases, which may expand to an abbreviation
+	if alias, ok := aliases[name]; ok {
+		expanded := append([]string{args[0]}, strings.Fields(alias)...)
+		expanded = append(expanded, args[2:]...)
+		if expanded[1] == name {
+			return expanded, nil
+		}
+		return expandCommand(expanded, nil)
+	}
+
+	// replace abbreviations of a single command
+	var matches []string
+	for _, command := range commands {
+		if strings.HasPrefix(command, name) {
+			matches = append(matches, command)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return args, nil
+	case 1:
+		return append([]string{args[0], matches[0]}, args[2:]...), nil
+	}
+	sort.Strings(matches)
+	return nil, fmt.Errorf("%s is ambiguous, it could be %s", name, strings.Join(matches, ", "))
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCommandsAreListed(t *testing.T) {
+	source, err := ioutil.ReadFile("cli.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every command parsed by Run can be abbreviated
+	var parsed []string
+	for _, match := range regexp.MustCompile(`case "([\w-]+)":\s+err := \w+Cmd\.Parse`).FindAllSubmatch(source, -1) {
+		parsed = append(parsed, string(match[1]))
+	}
+	listed := append([]string{}, commands...)
+	sort.Strings(parsed)
+	sort.Strings(listed)
+	if !reflect.DeepEqual(parsed, listed) {
+		t.Fatalf("Run parses %q, but commands lists %q", parsed, listed)
+	}
+}
+
+func TestExpandCommand(t *testing.T) {
+	aliases := map[string]string{
+		"bal":   "getbal",
+		"mybal": "getbal -address ADDR",
+		"m":     "minew -address ADDR",
+		"loop":  "loop -n 1",
+	}
+
+	for _, c := range []struct {
+		name string
+		args []string
+		want []string
+		err  string
+	}{
+		{"command", []string{"gochain", "send", "-fee", "1"}, []string{"gochain", "send", "-fee", "1"}, ""},
+		{"prefix of a longer command", []string{"gochain", "mine"}, []string{"gochain", "mine"}, ""},
+		{"alias", []string{"gochain", "bal", "-address", "A"}, []string{"gochain", "getbal", "-address", "A"}, ""},
+		{"alias with arguments", []string{"gochain", "mybal"}, []string{"gochain", "getbal", "-address", "ADDR"}, ""},
+		{"alias to an abbreviation", []string{"gochain", "m"}, []string{"gochain", "minework", "-address", "ADDR"}, ""},
+		{"alias to itself", []string{"gochain", "loop"}, []string{"gochain", "loop", "-n", "1"}, ""},
+		{"abbreviation", []string{"gochain", "getblockt", "-address", "A"}, []string{"gochain", "getblocktemplate", "-address", "A"}, ""},
+		{"ambiguous abbreviation", []string{"gochain", "getb"}, nil, "getbal, getblock, getblockcandidate, getblocktemplate"},
+		{"unknown", []string{"gochain", "nope"}, []string{"gochain", "nope"}, ""},
+		{"no command", []string{"gochain"}, []string{"gochain"}, ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := expandCommand(c.args, aliases)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("got error %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %q, %v, want %q", got, err, c.want)
+			}
+		})
+	}
+}
+
+func TestAliasesFromEnv(t *testing.T) {
+	defer os.Setenv("ALIASES", os.Getenv("ALIASES"))
+
+	for _, c := range []struct {
+		env  string
+		want map[string]string
+		err  bool
+	}{
+		{"", builtinAliases, false},
+		{"mybal = getbal -address A ; bal=getblock", map[string]string{"mybal": "getbal -address A", "bal": "getblock"}, false},
+		{"nope", nil, true},
+		{"x=", nil, true},
+		{"=getbal", nil, true},
+	} {
+		os.Setenv("ALIASES", c.env)
+		got, err := aliasesFromEnv()
+		if (err != nil) != c.err {
+			t.Errorf("%q: got error %v", c.env, err)
+			continue
+		}
+		for name, command := range c.want {
+			if got[name] != command {
+				t.Errorf("%q: got %s=%q, want %q", c.env, name, got[name], command)
+			}
+		}
+	}
+
+	// built in aliases name commands
+	known := make(map[string]bool)
+	for _, command := range commands {
+		known[command] = true
+	}
+	for name, command := range builtinAliases {
+		if !known[command] {
+			t.Errorf("alias %s is for unknown command %s", name, command)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// anchor records hex encoded data in the chain with a transaction from
+// address paying fee, mining it unless queue is set.
+func (cli *CLI) anchor(from, dataHex string, fee units.Amount, queue bool) {
+	if !wallet.ValidateAddress(from) {
+		log.Panicln("Unable to anchor data: from address not valid")
+	}
+	data, err := hex.DecodeString(dataHex)
+	if err != nil {
+		log.Panicln("Unable to anchor data: data is not hex - ", err.Error())
+	}
+	bc := openBlockChain(from)
+	defer bc.Close()
+
+	w, err := walletStore().Get(from)
+	if err != nil {
+		log.Panicln("Unable to load wallet: ", err.Error())
+	}
+	tx, err := bc.NewAnchorTransaction(w, data, fee, "")
+	if err != nil {
+		log.Panicln("Unable to create transaction: ", err.Error())
+	}
+	if err := bc.AddToMempool(tx); err != nil {
+		log.Panicln("Unable to add transaction to mempool: ", err.Error())
+	}
+
+	if queue {
+		fmt.Printf("Transaction %x added to mempool\n", tx.ID)
+		return
+	}
+	if !cli.mineSent(bc, from, tx.ID) {
+		return
+	}
+	fmt.Printf("Data anchored in transaction %x\n", tx.ID)
+}
+
+// findAnchor prints the earliest transaction in the best chain anchoring
+// hex encoded data.
+func (cli *CLI) findAnchor(dataHex string, asJSON bool) {
+	data, err := hex.DecodeString(dataHex)
+	if err != nil {
+		log.Panicln("Unable to find anchor: data is not hex - ", err.Error())
+	}
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	anchor, found, err := bc.FindAnchor(data)
+	if err != nil {
+		log.Panicln("Unable to find anchor: ", err.Error())
+	}
+	if asJSON {
+		if !found {
+			printJSON(map[string]interface{}{"found": false})
+			return
+		}
+		printJSON(map[string]interface{}{
+			"found":     true,
+			"txid":      anchor.TxID,
+			"blockHash": anchor.BlockHash,
+			"height":    anchor.Height,
+			"timestamp": anchor.Timestamp,
+		})
+		return
+	}
+
+	if !found {
+		fmt.Println("Data is not anchored in the chain")
+		return
+	}
+	fmt.Printf("Data anchored in transaction %s\n", anchor.TxID)
+	fmt.Printf("\tBlock:  %s at height %d\n", anchor.BlockHash, anchor.Height)
+	fmt.Printf("\tTime:   %s\n", time.Unix(anchor.Timestamp, 0).UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+)
+
+const (
+	// defaultMaxBodyBytes is the largest request body served without the
+	// API_MAX_BODY_BYTES env var, fitting the largest block posted as hex.
+	defaultMaxBodyBytes = 2*blockchain.MaxBlockSize + 64*1024
+
+	// maxQueryValue is the longest query parameter value served.
+	maxQueryValue = 256
+
+	// maxRateBuckets is the most clients tracked by a rate limit, beyond
+	// which the client whose last request is the oldest is forgotten.
+	maxRateBuckets = 10000
+
+	// readHeaderTimeout is how long clients have to send request headers,
+	// so slow clients can't hold connections open.
+	readHeaderTimeout = 10 * time.Second
+)
+
+// apiError is the body of the errors of the API limits, with a code
+// clients can act on.
+type apiError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// writeAPIError responds to a request with an apiError.
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{Error: message, Code: code})
+}
+
+// apiLimits bound the requests served by a node. Clients are rate limited
+// by token if they send a token of the node, and by IP otherwise.
+type apiLimits struct {
+	ipRate, tokenRate *rateLimit
+	maxBodyBytes      int64
+}
+
+// apiLimitsFromEnv returns the API limits set by the API_RATE_LIMIT,
+// API_TOKEN_RATE_LIMIT and API_MAX_BODY_BYTES env vars. Rate limits are
+// COUNT/DURATION, such as 100/1m, and requests aren't rate limited without
+// them.
+func apiLimitsFromEnv() (apiLimits, error) {
+	var limits apiLimits
+	var err error
+	if limits.ipRate, err = parseRateLimit(os.Getenv("API_RATE_LIMIT")); err != nil {
+		return limits, fmt.Errorf("env var API_RATE_LIMIT - %s", err.Error())
+	}
+	if limits.tokenRate, err = parseRateLimit(os.Getenv("API_TOKEN_RATE_LIMIT")); err != nil {
+		return limits, fmt.Errorf("env var API_TOKEN_RATE_LIMIT - %s", err.Error())
+	}
+	limits.maxBodyBytes = defaultMaxBodyBytes
+	if value := os.Getenv("API_MAX_BODY_BYTES"); value != "" {
+		limits.maxBodyBytes, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || limits.maxBodyBytes <= 0 {
+			return limits, fmt.Errorf("unable to convert env var API_MAX_BODY_BYTES to a byte count: %s", value)
+		}
+	}
+	return limits, nil
+}
+
+// rateLimit allows each client count requests every period, as a token
+// bucket refilled continuously, so clients can burst up to count requests.
+type rateLimit struct {
+	count  float64
+	period time.Duration
+
+	// buckets holds the elements of recent by client, which are ordered by
+	// their last request, most recent first
+	mutex   sync.Mutex
+	buckets map[string]*list.Element
+	recent  *list.List
+}
+
+// rateBucket holds the requests client has left as of updated.
+type rateBucket struct {
+	client  string
+	tokens  float64
+	updated time.Time
+}
+
+// parseRateLimit parses a rate limit given as COUNT/DURATION, returning nil
+// for an empty value.
+func parseRateLimit(value string) (*rateLimit, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("rate limit %q is not COUNT/DURATION", value)
+	}
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("rate limit %q does not have a positive count", value)
+	}
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return nil, fmt.Errorf("rate limit %q does not have a positive duration", value)
+	}
+	return newRateLimit(count, period), nil
+}
+
+// newRateLimit returns a rate limit of count requests every period.
+func newRateLimit(count int, period time.Duration) *rateLimit {
+	return &rateLimit{count: float64(count), period: period, buckets: make(map[string]*list.Element), recent: list.New()}
+}
+
+// allow takes a request from the bucket of client at now, returning
+// whether it had one left and otherwise how long until it does. A nil rate
+// limit allows every request.
+func (l *rateLimit) allow(client string, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// forget the client whose last request is the oldest to make room for
+	// a new one, so the buckets can't grow without bound
+	e := l.buckets[client]
+	if e == nil {
+		if len(l.buckets) >= maxRateBuckets {
+			oldest := l.recent.Back()
+			delete(l.buckets, oldest.Value.(*rateBucket).client)
+			l.recent.Remove(oldest)
+		}
+		e = l.recent.PushFront(&rateBucket{client: client, tokens: l.count, updated: now})
+		l.buckets[client] = e
+	}
+	l.recent.MoveToFront(e)
+
+	perRequest := float64(l.period) / l.count
+	b := e.Value.(*rateBucket)
+	b.tokens = math.Min(l.count, b.tokens+float64(now.Sub(b.updated))/perRequest)
+	b.updated = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * perRequest)
+	}
+	b.tokens--
+	return true, 0
+}
+
+// limited returns a handler calling h for requests within the limits of
+// the node and with only the query parameters in params, responding with
+// an apiError to others. Requests are rate limited before they are
+// authorized, so clients can't guess tokens quickly.
+func (n *node) limited(params []string, h http.Handler) http.Handler {
+	allowed := make(map[string]bool)
+	for _, param := range params {
+		allowed[param] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// rate limit clients by token, or by IP without a token of the node
+		limit, client := n.limits.ipRate, "ip "+remoteIP(r)
+		if token := requestToken(r); n.knownToken(token) {
+			limit, client = n.limits.tokenRate, "token "+token
+		}
+		if ok, retry := limit.allow(client, time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			writeAPIError(w, http.StatusTooManyRequests, "rate-limited", "too many requests, retry in "+retry.Round(time.Millisecond).String())
+			return
+		}
+
+		// refuse bodies over the limit, and stop reading those that don't
+		// say their length once they reach it
+		if n.limits.maxBodyBytes > 0 {
+			if r.ContentLength > n.limits.maxBodyBytes {
+				writeAPIError(w, http.StatusRequestEntityTooLarge, "body-too-large", fmt.Sprintf("request body is over %d bytes", n.limits.maxBodyBytes))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n.limits.maxBodyBytes)
+		}
+
+		// refuse query parameters the endpoint doesn't take
+		query, err := url.ParseQuery(r.URL.RawQuery)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid-parameter", "invalid query - "+err.Error())
+			return
+		}
+		for param, values := range query {
+			if !allowed[param] {
+				writeAPIError(w, http.StatusBadRequest, "invalid-parameter", fmt.Sprintf("unknown query parameter %q", param))
+				return
+			}
+			if len(values) > 1 {
+				writeAPIError(w, http.StatusBadRequest, "invalid-parameter", fmt.Sprintf("query parameter %q is given %d times", param, len(values)))
+				return
+			}
+			if len(values[0]) > maxQueryValue {
+				writeAPIError(w, http.StatusBadRequest, "invalid-parameter", fmt.Sprintf("query parameter %q is over %d bytes", param, maxQueryValue))
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// remoteIP returns the IP a request came from.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	limit, err := parseRateLimit("2/1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	// a client bursts up to the count, then waits for requests to refill
+	for i := 0; i < 2; i++ {
+		if ok, _ := limit.allow("a", now); !ok {
+			t.Fatalf("refused request %d of the burst", i+1)
+		}
+	}
+	if ok, retry := limit.allow("a", now); ok || retry != 500*time.Millisecond {
+		t.Fatalf("got %v retrying in %s, want the request refused for 500ms", ok, retry)
+	}
+	if ok, _ := limit.allow("b", now); !ok {
+		t.Fatal("refused another client")
+	}
+	if ok, _ := limit.allow("a", now.Add(500*time.Millisecond)); !ok {
+		t.Fatal("refused a request once refilled")
+	}
+
+	// the clients tracked are capped, forgetting the least recently seen
+	for i := 0; i < maxRateBuckets-1; i++ {
+		limit.allow(fmt.Sprintf("client %d", i), now.Add(time.Second))
+	}
+	if len(limit.buckets) != maxRateBuckets || limit.buckets["b"] != nil {
+		t.Fatalf("got %d buckets, want %d without the oldest client", len(limit.buckets), maxRateBuckets)
+	}
+	if limit.buckets["a"] == nil {
+		t.Fatal("forgot a client seen more recently than the oldest")
+	}
+
+	for _, value := range []string{"2", "0/1s", "2/0s", "x/1m"} {
+		if _, err := parseRateLimit(value); err == nil {
+			t.Errorf("parsed rate limit %q", value)
+		}
+	}
+}
+
+func TestLimitedEndpoints(t *testing.T) {
+	tokens, _ := parseAPITokens("client=read")
+	n := &node{tokens: tokens, limits: apiLimits{
+		ipRate:       newRateLimit(1, time.Hour),
+		tokenRate:    newRateLimit(5, time.Hour),
+		maxBodyBytes: 10,
+	}}
+	server := httptest.NewServer(n.limited([]string{"address"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	defer server.Close()
+
+	request := func(method, query, body, token string) (int, apiError) {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+query, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var e apiError
+		json.NewDecoder(resp.Body).Decode(&e)
+		return resp.StatusCode, e
+	}
+
+	// invalid requests are refused with the code of their error, using up
+	// requests of the token
+	for _, c := range []struct {
+		method, query, body, code string
+	}{
+		{http.MethodGet, "?other=1", "", "invalid-parameter"},
+		{http.MethodGet, "?address=a&address=b", "", "invalid-parameter"},
+		{http.MethodGet, "?address=" + strings.Repeat("a", maxQueryValue+1), "", "invalid-parameter"},
+		{http.MethodPost, "", strings.Repeat("a", 11), "body-too-large"},
+	} {
+		if status, e := request(c.method, c.query, c.body, "client"); status == http.StatusOK || e.Code != c.code {
+			t.Errorf("got status %d and error %+v for %s %s, want %s", status, e, c.method, c.query, c.code)
+		}
+	}
+
+	// clients without a token are limited by IP, and those with one by token
+	if status, _ := request(http.MethodGet, "?address=a", "", ""); status != http.StatusOK {
+		t.Fatalf("got status %d, want the first request of the IP served", status)
+	}
+	if status, e := request(http.MethodGet, "", "", "unknown"); status != http.StatusTooManyRequests || e.Code != "rate-limited" {
+		t.Fatalf("got status %d and error %+v, want an unknown token limited by IP", status, e)
+	}
+	if status, _ := request(http.MethodGet, "", "", "client"); status != http.StatusOK {
+		t.Fatalf("got status %d, want the last request of the token served", status)
+	}
+	if status, e := request(http.MethodGet, "", "", "client"); status != http.StatusTooManyRequests || e.Code != "rate-limited" {
+		t.Fatalf("got status %d and error %+v, want the token rate limited", status, e)
+	}
+}
@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// scopeRead allows fetching headers, balances, fee estimates, mempool
+	// statistics and charts, and subscribing to events at /ws.
+	scopeRead = "read"
+
+	// scopeWrite allows testing and submitting transactions and blocks and
+	// fetching block templates.
+	scopeWrite = "write"
+
+	// scopeWallet allows using the wallets of the node and managing its
+	// deposit watches and webhooks.
+	scopeWallet = "wallet"
+)
+
+// scopes are the permissions API tokens can be given.
+var scopes = []string{scopeRead, scopeWrite, scopeWallet}
+
+// apiTokens are the scopes of the API tokens of a node, keyed by token.
+type apiTokens map[string]map[string]bool
+
+// parseAPITokens parses API tokens given as TOKEN=SCOPE+SCOPE pairs
+// separated by commas, such as "reader=read,payments=read+wallet".
+func parseAPITokens(value string) (apiTokens, error) {
+	tokens := apiTokens{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("API token %q is not TOKEN=SCOPE+SCOPE", pair)
+		}
+		if tokens[parts[0]] != nil {
+			return nil, fmt.Errorf("API token %s is given twice", parts[0])
+		}
+		tokens[parts[0]] = make(map[string]bool)
+		for _, scope := range strings.Split(parts[1], "+") {
+			if !validScope(scope) {
+				return nil, fmt.Errorf("unknown scope %q, scopes are %s", scope, strings.Join(scopes, ", "))
+			}
+			tokens[parts[0]][scope] = true
+		}
+	}
+	return tokens, nil
+}
+
+// validScope returns whether scope is a permission of API tokens.
+func validScope(scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// allow returns whether a token has scope.
+func (t apiTokens) allow(scope string) bool {
+	for _, s := range t {
+		if s[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+// scopes returns the scopes of token, comparing it with every token in
+// constant time.
+func (t apiTokens) scopes(token string) map[string]bool {
+	var found map[string]bool
+	for candidate, s := range t {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			found = s
+		}
+	}
+	return found
+}
+
+// requestToken returns the token of a request, sent as a bearer token or
+// as the password of HTTP basic authentication.
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return ""
+}
+
+// errUnauthorized is returned by checkToken for a token that is neither
+// the token of the node nor one of its API tokens.
+var errUnauthorized = errors.New("unauthorized")
+
+// checkToken returns nil if token is the token of the node, which has
+// every scope, or an API token with scope, errUnauthorized if it is
+// neither and an error naming the scope if it lacks it.
+func (n *node) checkToken(token, scope string) error {
+	if n.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(n.token)) == 1 {
+		return nil
+	}
+	granted := n.tokens.scopes(token)
+	if granted == nil {
+		return errUnauthorized
+	}
+	if !granted[scope] {
+		return fmt.Errorf("token lacks the %s scope", scope)
+	}
+	return nil
+}
+
+// authorized returns whether a request holds a token with scope, either
+// the token of the node, which has every scope, or an API token,
+// responding with an error if it doesn't. Requests made over the socket of
+// the node have every scope.
+func (n *node) authorized(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if localRequest(r) {
+		return true
+	}
+	switch err := n.checkToken(requestToken(r), scope); err {
+	case nil:
+		return true
+	case errUnauthorized:
+		w.Header().Set("WWW-Authenticate", `Basic realm="gochain"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	default:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	}
+	return false
+}
+
+// knownToken returns whether token is the token of the node or one of its
+// API tokens.
+func (n *node) knownToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	if n.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(n.token)) == 1 {
+		return true
+	}
+	return n.tokens.scopes(token) != nil
+}
+
+// scoped returns a handler calling h for requests holding a token with
+// scope. Without API tokens the endpoints of h are open to every client,
+// as the token of the node only protects its wallets.
+func (n *node) scoped(scope string, h http.Handler) http.Handler {
+	if len(n.tokens) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.authorized(w, r, scope) {
+			h.ServeHTTP(w, r)
+		}
+	})
+}
+
+// servesWallets returns whether the node has a token allowed to use its
+// wallets or serves the commands of its machine on a socket, without which
+// they are not served.
+func (n *node) servesWallets() bool {
+	return n.token != "" || n.tokens.allow(scopeWallet) || n.local
+}
+
+// describeScopes lists the scopes of each token for the log, without the
+// tokens.
+func describeScopes(tokens apiTokens) []string {
+	var described []string
+	for _, s := range tokens {
+		var names []string
+		for scope := range s {
+			names = append(names, scope)
+		}
+		sort.Strings(names)
+		described = append(described, strings.Join(names, "+"))
+	}
+	sort.Strings(described)
+	return described
+}
+
+// tlsIssue returns why a node serving on addr sends tokens in the clear
+// to other machines, or an empty string if it doesn't.
+func tlsIssue(addr string, authenticated, useTLS bool) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || !authenticated || useTLS || !exposed(host) {
+		return ""
+	}
+	return fmt.Sprintf("the node listens on %s beyond this machine without TLS, so clients send their tokens in the clear", addr)
+}
+
+// selfSignedCertificate creates a certificate for addr signed by its own
+// key, for serving TLS in development without a certificate authority. It
+// returns the SHA-256 fingerprint clients can pin the certificate with.
+func selfSignedCertificate(addr string) (tls.Certificate, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "gochain node"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	// the certificate also names the host listened on
+	if host, _, err := net.SplitHostPort(addr); err == nil && host != "" {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "localhost" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	fingerprint := sha256.Sum256(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, fmt.Sprintf("%x", fingerprint), nil
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseAPITokens(t *testing.T) {
+	tokens, err := parseAPITokens("reader=read, payments=read+wallet,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || !tokens["reader"][scopeRead] || tokens["reader"][scopeWallet] || !tokens["payments"][scopeWallet] {
+		t.Fatalf("got tokens %v", tokens)
+	}
+	for _, value := range []string{"reader", "reader=", "reader=admin", "a=read,a=write"} {
+		if _, err := parseAPITokens(value); err == nil {
+			t.Errorf("parsed API tokens %q", value)
+		}
+	}
+}
+
+func TestScopedEndpoints(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tokens, _ := parseAPITokens("reader=read,writer=read+write")
+	n := &node{token: "node", tokens: tokens}
+	server := httptest.NewServer(n.scoped(scopeWrite, ok))
+	defer server.Close()
+
+	for _, c := range []struct {
+		name, bearer, password string
+		want                   int
+	}{
+		{"no token", "", "", http.StatusUnauthorized},
+		{"unknown token", "other", "", http.StatusUnauthorized},
+		{"missing scope", "reader", "", http.StatusForbidden},
+		{"bearer token", "writer", "", http.StatusOK},
+		{"basic auth", "", "writer", http.StatusOK},
+		{"node token", "node", "", http.StatusOK},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			if c.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+c.bearer)
+			}
+			if c.password != "" {
+				req.SetBasicAuth("client", c.password)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != c.want {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, c.want)
+			}
+		})
+	}
+
+	// without API tokens, endpoints are open as before
+	open := httptest.NewServer((&node{token: "node"}).scoped(scopeWrite, ok))
+	defer open.Close()
+	resp, err := http.Get(open.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d without API tokens, want the endpoint open", resp.StatusCode)
+	}
+}
+
+func TestTLSIssue(t *testing.T) {
+	if tlsIssue("0.0.0.0:8546", true, false) == "" {
+		t.Fatal("allowed sending tokens in the clear to other machines")
+	}
+	for _, c := range []struct {
+		addr               string
+		authenticated, tls bool
+	}{
+		{"0.0.0.0:8546", true, true},
+		{"0.0.0.0:8546", false, false},
+		{"localhost:8546", true, false},
+	} {
+		if issue := tlsIssue(c.addr, c.authenticated, c.tls); issue != "" {
+			t.Errorf("got issue %q for %+v", issue, c)
+		}
+	}
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	cert, fingerprint, err := selfSignedCertificate("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+
+	// clients pin the certificate by its fingerprint
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	served := resp.TLS.PeerCertificates[0].Raw
+	if got := fmt.Sprintf("%x", sha256.Sum256(served)); got != fingerprint {
+		t.Fatalf("got fingerprint %s, want %s", got, fingerprint)
+	}
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// bumpFee replaces a pending transaction sent from the wallets file with
+// one paying fee from its change, mining it unless queue is set, so a send
+// stuck with too low a fee can be confirmed.
+func (cli *CLI) bumpFee(txIDHex string, fee units.Amount, queue bool) {
+	txID, err := blockchain.ParseHash(txIDHex)
+	if err != nil {
+		log.Panicln("Unable to bump fee: ", err.Error())
+	}
+	wallets, err := walletStore().Wallets()
+	if err != nil {
+		log.Panicln("Unable to load wallets: ", err.Error())
+	}
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	tx, err := bc.BumpFee(wallet.Keys(wallets), txID.Bytes(), fee)
+	if err != nil {
+		log.Panicln("Unable to bump fee: ", err.Error())
+	}
+	if err := bc.AddToMempool(tx); err != nil {
+		log.Panicln("Unable to add transaction to mempool: ", err.Error())
+	}
+	fmt.Printf("Transaction %x replaced by %x paying %s\n", txID.Bytes(), tx.ID, units.FormatAmount(fee))
+
+	// leave the replacement pending if it was queued, otherwise mine it
+	// rewarding the sender as the miner
+	if queue {
+		return
+	}
+	if !cli.mineSent(bc, tx.Inputs[0].Address(), tx.ID) {
+		return
+	}
+	fmt.Println("Success!")
+}
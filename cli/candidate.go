@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// getBlockCandidate prints the block that would be mined for address right
+// now: the pending transactions it would hold in order with their fee
+// rates, those left out, and the reward.
+func (cli *CLI) getBlockCandidate(address string, asJSON bool) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to assemble block: address not valid")
+	}
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	candidate, err := bc.BlockCandidate(address)
+	if err != nil {
+		log.Panicln("Unable to assemble block: ", err.Error())
+	}
+	if asJSON {
+		printJSON(candidate)
+		return
+	}
+
+	fmt.Printf("Block %d on %s at difficulty %d, %d bytes\n", candidate.Height, candidate.PrevHash, candidate.Difficulty, candidate.Size)
+	for i, tx := range candidate.Transactions {
+		priority := ""
+		if tx.Priority {
+			priority = " priority"
+		}
+		fmt.Printf("%4d %s fee %s, %d bytes, %.2f/byte%s\n", i+1, tx.TxID, units.FormatAmount(tx.Fee), tx.Size, tx.FeeRate, priority)
+	}
+	for _, tx := range candidate.Excluded {
+		fmt.Printf("left %s fee %s, %d bytes, %.2f/byte\n", tx.TxID, units.FormatAmount(tx.Fee), tx.Size, tx.FeeRate)
+	}
+	fmt.Printf("Fees:    %s\n", units.FormatAmount(candidate.Fees))
+	fmt.Printf("Subsidy: %s\n", units.FormatAmount(candidate.Subsidy))
+	fmt.Printf("Reward:  %s\n", units.FormatAmount(candidate.Reward))
+}
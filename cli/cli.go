@@ -1,30 +1,256 @@
 package cli
 
 import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"runtime"
-	"strconv"
+	"sort"
+	"time"
 
-	"github.com/btcsuite/btcutil/base58"
 	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/hooks"
+	"github.com/edwintcloud/gochain/scripts"
+	"github.com/edwintcloud/gochain/units"
 	"github.com/edwintcloud/gochain/wallet"
 )
 
 // CLI is a command line interface structure.
-type CLI struct{}
+type CLI struct {
+
+	// Config is the configuration of commands, which is DefaultConfig if
+	// it is the zero Config, before the config file, env vars and options
+	// override it
+	Config Config
+
+	// EnvFile is the deprecated .env file the env vars were loaded from,
+	// if any, which Run warns about
+	EnvFile string
+
+	// ctx is cancelled when the process is asked to shut down
+	ctx context.Context
+
+	// jsonOutput is set by the -json option given before the command, so
+	// every command prints JSON
+	jsonOutput bool
+}
 
 // printUsage prints usage instructions for the cli.
 func (cli *CLI) printUsage() {
-	fmt.Println("Usage:")
-	fmt.Printf(" getbal -address ADDRESS\t Gets the balance for an address.\n")
+	fmt.Println("Usage: [-network main|test|regtest] [-wallet NAME] [-config FILE] [-json] COMMAND")
+	fmt.Printf(" getbal -address ADDRESS [-token TOKEN] [-detail [-minconf N]]\t Gets the balance for an address, or its confirmed balance of a token.\n")
+	fmt.Printf("\t -detail splits it into trusted value with N confirmations, untrusted\n")
+	fmt.Printf("\t pending and immature value, and shows the value pending transactions\n")
+	fmt.Printf("\t move in and out.\n")
+	fmt.Printf("\t Asks the node serving on the NODE_SOCKET env var if one is running.\n")
 	fmt.Printf(" create -address ADDRESS\t Creates a blockchain and sends genesis reward to address.\n")
-	fmt.Printf(" print\t Prints the blocks in the chain.\n")
-	fmt.Printf(" send -from FROM -to TO -amount AMOUNT\t Sends amount of coins from one address to another.\n")
-	fmt.Printf(" createwallet\t Creates a new Wallet.\n")
-	fmt.Printf(" listaddresses\t List the addresses in the wallets file.\n")
+	fmt.Printf(" print [-json] [-verbosity summary|standard|full]\t Prints the blocks in the chain.\n")
+	fmt.Printf(" getblock [-hash HASH] [-height N] [-json] [-verbosity summary|standard|full]\t Prints a block by hash or height, and whether it is final.\n")
+	fmt.Printf(" gettx -id TXID [-json] [-verbosity summary|standard|full]\t Prints a pending or confirmed transaction, when it was mined or\n")
+	fmt.Printf("\t received, its confirmations and whether it is final.\n")
+	fmt.Printf(" send -from FROM (-to TO -amount AMOUNT | -to TO:AMOUNT [-to TO:AMOUNT ...]) [-fee FEE] [-queue] [-wait-confirmations N] [-request-id ID] [-dry-run]\t Sends amount of coins from one address to another.\n")
+	fmt.Printf("\t With -dry-run the transaction is built, signed and printed with its\n")
+	fmt.Printf("\t inputs, change and fee, but not sent.\n")
+	fmt.Printf("\t If a node serves on the NODE_SOCKET env var, it sends to a single\n")
+	fmt.Printf("\t address from its wallets file and mines the transaction if it runs a\n")
+	fmt.Printf("\t miner.\n")
+	fmt.Printf(" sendmany -from FROM -file FILE [-fee FEE] [-queue]\t Pays every address and amount in a file, a JSON array of {\"address\",\n")
+	fmt.Printf("\t \"amount\"} objects if it ends in .json and ADDRESS,AMOUNT rows\n")
+	fmt.Printf("\t otherwise, in as few transactions as fit, paying the fee for each.\n")
+	fmt.Printf("\t Every entry is checked before anything is sent.\n")
+	fmt.Printf(" propose -from FROM -to TO -amount AMOUNT [-fee FEE] -out FILE\t Saves an unsigned send for approval.\n")
+	fmt.Printf(" approve -in FILE -out FILE\t Signs a proposed send with the wallet for its from address.\n")
+	fmt.Printf(" submit -in FILE [-queue]\t Sends an approved proposal.\n")
+	fmt.Printf(" mine -address ADDRESS\t Mines a block with the transactions in the mempool, rewarding address.\n")
+	fmt.Printf(" lockunspent -txid TXID -vout N [-unlock]\t Locks an output so it is not selected for sends.\n")
+	fmt.Printf(" listlockunspent\t Lists the locked outputs.\n")
+	fmt.Printf(" bumpfee -txid TXID -fee FEE [-queue]\t Replaces a pending send from the wallets file with one paying a higher\n")
+	fmt.Printf("\t fee from its change, evicting it and any pending transactions spending\n")
+	fmt.Printf("\t from it.\n")
+	fmt.Printf(" sweepkey -wif KEY -to ADDRESS [-fee FEE] [-queue]\t Sends all coins held by a private key to an address.\n")
+	fmt.Printf(" watchaddress -address ADDRESS -amount AMOUNT [-timeout DURATION]\t Waits for a payment to an address to be mined.\n")
+	fmt.Printf(" exportchain -file FILE\t Writes the blocks in the chain to a file.\n")
+	fmt.Printf(" importchain -file FILE [-utxoset FILE [-commitment HASH]]\t Creates a blockchain from a file written by exportchain, starting from a\n")
+	fmt.Printf("\t UTXO set written by dumputxoset instead of validating the blocks up to\n")
+	fmt.Printf("\t its height.\n")
+	fmt.Printf(" runscript -file FILE -event EVENT [-memory MB]\t Runs the handler for an event in a script with the payload from stdin.\n")
+	fmt.Printf(" createrawtx -inputs TXID:VOUT[,...] -outputs ADDRESS:AMOUNT[,...]\t Creates an unsigned transaction and prints it as hex.\n")
+	fmt.Printf(" signrawtx -hex HEX\t Signs a raw transaction with the wallets file and prints it as hex.\n")
+	fmt.Printf(" testmempoolaccept -hex HEX [-json]\t Reports whether a signed raw transaction would enter the mempool, or the\n")
+	fmt.Printf("\t rule it breaks, without sending it.\n")
+	fmt.Printf(" sendrawtx -hex HEX [-queue]\t Sends a signed raw transaction.\n")
+	fmt.Printf(" tail [-n N] [-follow] [-json]\t Prints the last blocks in the chain, following new blocks and reorgs if\n")
+	fmt.Printf("\t -follow is set.\n")
+	fmt.Printf(" history -address ADDRESS\t Prints the transactions paying to or spending from an address with the\n")
+	fmt.Printf("\t time of their blocks.\n")
+	fmt.Printf(" reindexaddresses\t Rebuilds the address index from the blocks in the chain, resuming an\n")
+	fmt.Printf("\t interrupted run.\n")
+	fmt.Printf(" rescan\t Rebuilds the UTXO set and indexes from the blocks in the chain, resuming\n")
+	fmt.Printf("\t an interrupted run.\n")
+	fmt.Printf(" jobs [-cancel NAME]\t Shows the progress of rescan, reindexaddresses and verifychain runs, or\n")
+	fmt.Printf("\t cancels one so it starts over.\n")
+	fmt.Printf(" getblocktemplate -address ADDRESS\t Prints a block header and nonce offset for external miners as JSON, with\n")
+	fmt.Printf("\t the previous block, target, selected transactions and their fees, and\n")
+	fmt.Printf("\t the coinbase.\n")
+	fmt.Printf(" submitblock -block HEX -nonce N\t Adds a block from getblocktemplate mined with a nonce.\n")
+	fmt.Printf(" minework -address ADDRESS [-node URL] [-solver PROGRAM] [-refresh DURATION]\t Mines work from a node run with serve on the CPUs or with a solver\n")
+	fmt.Printf("\t program such as a GPU miner.\n")
+	fmt.Printf(" stats [-window N] [-json]\t Prints the height, tip, transactions, coin supply and difficulty of the\n")
+	fmt.Printf("\t chain, with the block interval and estimated hash rate over the last N\n")
+	fmt.Printf("\t blocks, 100 by default.\n")
+	fmt.Printf(" startminer -address ADDRESS [-threads N] [-addr ADDR] [-refresh DURATION]\t Runs a node like serve that mines blocks continuously on N threads,\n")
+	fmt.Printf("\t every CPU by default, paying the rewards to ADDRESS.\n")
+	fmt.Printf("\t Each block template is searched for DURATION at most so new transactions\n")
+	fmt.Printf("\t are mined, and abandoned as soon as a block posted to /block changes the\n")
+	fmt.Printf("\t tip.\n")
+	fmt.Printf("\t The hash rate and the blocks mined are served at /minerstats.\n")
+	fmt.Printf(" forks [-reorgs N] [-json]\t Lists the tip of the best chain and the side chain tips competing with\n")
+	fmt.Printf("\t it, with their height, cumulative work and where they branch off,\n")
+	fmt.Printf("\t followed by the last N reorganizations of the best chain.\n")
+	fmt.Printf("\t Reorganizations are also appended to the file in the REORG_LOG env var\n")
+	fmt.Printf("\t as JSON lines.\n")
+	fmt.Printf(" perfstats [-window N] [-json]\t Prints percentiles of the validation time, size and transactions of\n")
+	fmt.Printf("\t connected blocks.\n")
+	fmt.Printf(" verifychain [-from HEIGHT | -full] [-repair FILE]\t Verifies the best chain, and the signatures of blocks from a height,\n")
+	fmt.Printf("\t after the latest checkpoint by default.\n")
+	fmt.Printf("\t With -full, replays the chain from genesis and checks every signature,\n")
+	fmt.Printf("\t undo record and the UTXO set.\n")
+	fmt.Printf("\t With -repair, restores corrupt block records from a chain export first.\n")
+	fmt.Printf("\t Resumes an interrupted run from the same height.\n")
+	fmt.Printf(" migrateblocks\t Rewrites the blocks stored with gob by earlier versions in the protobuf\n")
+	fmt.Printf("\t format of gochain.proto, so tools outside Go can decode them.\n")
+	fmt.Printf("\t Opening a database written by an earlier version does this once as a\n")
+	fmt.Printf("\t schema migration.\n")
+	fmt.Printf(" compactdb [-ratio R]\t Garbage collects the value log of the database, rewriting the files of\n")
+	fmt.Printf("\t which at least a ratio is reclaimable, and prints the space reclaimed.\n")
+	fmt.Printf(" getmerkleproof -txid TXID\t Prints a proof that a transaction is in a block as JSON.\n")
+	fmt.Printf(" freeze [-reason TEXT]\t Pauses mining and block acceptance for maintenance.\n")
+	fmt.Printf(" unfreeze\t Resumes mining and block acceptance after freeze.\n")
+	fmt.Printf(" feehistogram [-json]\t Prints the number and size of the pending transactions in each fee rate\n")
+	fmt.Printf("\t band, to see the fee rate needed to outbid the backlog.\n")
+	fmt.Printf(" estimatefee [-blocks N] [-size BYTES] [-json]\t Suggests a fee rate for a transaction to be mined within N blocks, 2 by\n")
+	fmt.Printf("\t default, from the fee rates of recent blocks and the mempool backlog,\n")
+	fmt.Printf("\t and the fee of a transaction of the given size, about that of a payment\n")
+	fmt.Printf("\t with change by default.\n")
+	fmt.Printf(" getblockcandidate -address ADDRESS [-json]\t Prints the pending transactions a block mined now would hold, in order\n")
+	fmt.Printf("\t with their fee rates, those left out, and the reward, without mining it.\n")
+	fmt.Printf(" minerreport -address ADDRESS [-json]\t Prints how many blocks an address mined, the subsidies and fees it\n")
+	fmt.Printf("\t earned, and how many of its blocks were orphaned.\n")
+	fmt.Printf(" coinage [-address ADDRESS] [-json]\t Reports the age distribution and value-weighted age of the unspent\n")
+	fmt.Printf("\t outputs of the wallets.\n")
+	fmt.Printf(" anchor -from ADDRESS -data HEX [-fee AMOUNT] [-queue]\t Records up to 80 bytes of data in the chain with an unspendable output.\n")
+	fmt.Printf(" findanchor -data HEX [-json]\t Prints the transaction, block and time data was first anchored in.\n")
+	fmt.Printf(" issuetoken -address ADDRESS -supply N [-fee AMOUNT] [-queue]\t Issues a new token whose id is the id of the issuing transaction.\n")
+	fmt.Printf(" sendtoken -from ADDRESS -to ADDRESS -token TOKEN -amount N [-fee AMOUNT] [-queue]\t Sends tokens, returning the rest to the sender.\n")
+	fmt.Printf(" gettxoutsetinfo [-json]\t Prints the number, total value and SHA-256 commitment of the unspent\n")
+	fmt.Printf("\t outputs at the tip and the most the subsidy schedule allows, failing if\n")
+	fmt.Printf("\t the supply exceeds it.\n")
+	fmt.Printf(" dumputxoset [-height H] -o FILE\t Writes the UTXO set after a block of the best chain to a file ending\n")
+	fmt.Printf("\t with its SHA-256 commitment, for fast sync and supply audits.\n")
+	fmt.Printf(" simulate-difficulty [-blocks N] [-hashrate RATE|HEIGHT:RATE,...] [-algorithm fixed|window|perblock] [-window N] [-spacing SECONDS] [-difficulty N] [-report N] [-seed N] [-json]\t Simulates block production under a hash rate curve and retarget\n")
+	fmt.Printf("\t algorithm and prints the block intervals, for tuning the parameters of a\n")
+	fmt.Printf("\t new network.\n")
+	fmt.Printf(" selftest [-keep]\t Creates wallets, mines, sends, reorgs, rescans and verifies balances on\n")
+	fmt.Printf("\t a throwaway regtest chain, reporting each step.\n")
+	fmt.Printf(" update [-check] [-force]\t Replaces this binary with the latest release at UPDATE_URL if it is\n")
+	fmt.Printf("\t newer, after verifying its checksum is signed by UPDATE_KEY, restoring\n")
+	fmt.Printf("\t the old binary if the new one fails to run.\n")
+	fmt.Printf("\t -force allows reinstalling and downgrading.\n")
+	fmt.Printf(" signrelease -address ADDRESS -version VERSION -file PATH [-url URL] [-platform GOOS-GOARCH]\t Prints the release manifest entry of a binary with its checksum signed\n")
+	fmt.Printf("\t by the wallet for ADDRESS, for serving at UPDATE_URL.\n")
+	fmt.Printf(" version\t Prints the version of this binary.\n")
+	fmt.Printf(" watchdeposits [-address ADDRESS -url URL [-confirmations N] [-remove]] [-json]\t Posts a deposit event to URL once each deposit to ADDRESS has N\n")
+	fmt.Printf("\t confirmations, 6 by default, and again if a reorg takes it out of the\n")
+	fmt.Printf("\t chain, while serve runs.\n")
+	fmt.Printf("\t With -remove, stops watching.\n")
+	fmt.Printf("\t Without -address, lists the watches.\n")
+	fmt.Printf(" webhooks [-url URL [-topics newBlock,newTx,replacedTx,reorg] [-remove]] [-json]\t Posts the events of the chain of the topics given, or of every topic, to\n")
+	fmt.Printf("\t URL as JSON while serve runs, retrying with backoff.\n")
+	fmt.Printf("\t Events are signed with the WEBHOOK_SECRET env var in the\n")
+	fmt.Printf("\t X-Gochain-Signature header.\n")
+	fmt.Printf("\t With -remove, stops posting.\n")
+	fmt.Printf("\t Without -url, lists the webhooks.\n")
+	fmt.Printf(" mempoolinfo [-json]\t Prints the number, size and fees of the pending transactions and the\n")
+	fmt.Printf("\t mempool limits set by the MEMPOOL_MAX_COUNT, MEMPOOL_MAX_BYTES,\n")
+	fmt.Printf("\t MEMPOOL_MAX_AGE, MEMPOOL_EVICT and MEMPOOL_MIN_FEE_RATE env vars.\n")
+	fmt.Printf(" serve [-addr ADDR] [-mine ADDRESS [-interval DURATION | -threads N]] [-token TOKEN] [-wallet NAME[=PATH] ...] [-prioritize-wallets] [-insecure] [-watch DIR] [-tls-cert FILE -tls-key FILE | -tls-self-signed]\t Runs a node taking transactions at /tx, testing them at\n")
+	fmt.Printf("\t /testmempoolaccept and taking blocks at /block, serving block headers at\n")
+	fmt.Printf("\t /headers, balances at /balance, fee estimates at /estimatefee, the\n")
+	fmt.Printf("\t mempool fee histogram at /feehistogram, the mempool size and limits at\n")
+	fmt.Printf("\t /mempoolinfo, the lowest fee rate it takes at /feefilter, chain\n")
+	fmt.Printf("\t statistics at /stats, the UTXO set summary at /txoutsetinfo and fee,\n")
+	fmt.Printf("\t difficulty and block interval charts at /charts, pushing events to\n")
+	fmt.Printf("\t websocket clients at /ws, which browsers may only connect to from pages\n")
+	fmt.Printf("\t of the node or of the origins in the WS_ALLOWED_ORIGINS env var,\n")
+	fmt.Printf("\t separated by commas.\n")
+	fmt.Printf("\t With -mine, mines pending transactions every interval, or with -threads\n")
+	fmt.Printf("\t mines continuously like startminer.\n")
+	fmt.Printf("\t With -token, serves its wallets to importwallet and sends from them at\n")
+	fmt.Printf("\t /wallet/NAME/send, where the wallets file is called by its name and each\n")
+	fmt.Printf("\t -wallet adds another, the wallets file called NAME without a PATH.\n")
+	fmt.Printf("\t With -token, also manages the deposit watches of watchdeposits at\n")
+	fmt.Printf("\t /depositwatches, posting their deposit events, and the webhooks of the\n")
+	fmt.Printf("\t webhooks command at /webhooks, posting them events along with the URLs\n")
+	fmt.Printf("\t in the WEBHOOKS env var.\n")
+	fmt.Printf("\t With -watch, adds the signed transactions in files dropped in DIR by\n")
+	fmt.Printf("\t signrawtx or approve to the mempool, moving them to its processed or\n")
+	fmt.Printf("\t failed subfolder.\n")
+	fmt.Printf("\t With -tls-cert and -tls-key, or a self-signed certificate for\n")
+	fmt.Printf("\t development with -tls-self-signed, serves TLS.\n")
+	fmt.Printf("\t Once the API_TOKENS env var sets tokens, as TOKEN=SCOPE+SCOPE pairs\n")
+	fmt.Printf("\t separated by commas with the scopes read, write and wallet, every\n")
+	fmt.Printf("\t request needs one as a bearer token or basic auth password.\n")
+	fmt.Printf("\t Requests are rate limited per IP by the API_RATE_LIMIT env var and per\n")
+	fmt.Printf("\t token by API_TOKEN_RATE_LIMIT, as COUNT/DURATION, their bodies are\n")
+	fmt.Printf("\t limited to API_MAX_BODY_BYTES, and query parameters an endpoint doesn't\n")
+	fmt.Printf("\t take are refused.\n")
+	fmt.Printf("\t If the NODE_SOCKET env var is set, also serves every endpoint and the\n")
+	fmt.Printf("\t wallets on that unix socket to the user running the node, so getbal and\n")
+	fmt.Printf("\t send work while it runs.\n")
+	fmt.Printf("\t If the GRPC_ADDR env var is set, also serves blocks, transactions,\n")
+	fmt.Printf("\t balances, wallets and a stream of new blocks on that address over gRPC,\n")
+	fmt.Printf("\t as the Node service of cli/node.proto.\n")
+	fmt.Printf(" createwallet [-name NAME]\t Creates a new Wallet, in the wallets file called NAME if given, which is\n")
+	fmt.Printf("\t created next to the default one.\n")
+	fmt.Printf(" listwallets [-json]\t Lists the wallets files, marking the one commands use, which -wallet\n")
+	fmt.Printf("\t NAME before the command or the DEFAULT_WALLET env var select.\n")
+	fmt.Printf(" listaddresses\t List the addresses in the wallets file with their labels.\n")
+	fmt.Printf(" addcontact -name NAME (-address ADDRESS | -remove)\t Saves the address of someone else in the wallets file under a name,\n")
+	fmt.Printf("\t which commands take in place of the address, or removes it.\n")
+	fmt.Printf(" listcontacts [-json]\t Lists the contacts in the wallets file.\n")
+	fmt.Printf(" setlabel -address ADDRESS [-label LABEL]\t Labels a wallet in the wallets file, so commands take the label in place\n")
+	fmt.Printf("\t of its address, or removes its label.\n")
+	fmt.Printf(" removeaddress -address ADDRESS [-archive FILE] [-force] [-yes]\t Removes a wallet from the wallets file after asking to confirm, first\n")
+	fmt.Printf("\t saving it to FILE encrypted with a passphrase read from stdin if\n")
+	fmt.Printf("\t -archive is given.\n")
+	fmt.Printf("\t Wallets still holding coins are only removed with -force.\n")
+	fmt.Printf("\t The backups of the wallets file keep the wallet until they are rotated\n")
+	fmt.Printf("\t out.\n")
+	fmt.Printf(" importaddress -address ADDRESS | -pubkey HEX\t Adds a watch-only wallet for an address or public key to the wallets\n")
+	fmt.Printf("\t file.\n")
+	fmt.Printf(" importkey -wif KEY\t Adds the wallet for a private key in wallet import format to the wallets\n")
+	fmt.Printf("\t file.\n")
+	fmt.Printf(" exportkey -address ADDRESS\t Prints the private key of a wallet in wallet import format.\n")
+	fmt.Printf(" importwallet -from URL -token TOKEN [-keys]\t Adds the wallets of a node run with serve -token to the wallets file,\n")
+	fmt.Printf("\t from URL/wallet/NAME if the node serves several wallets files,\n")
+	fmt.Printf("\t watch-only or with -keys with their private keys, reading the passphrase\n")
+	fmt.Printf("\t of the node's wallets file from stdin.\n")
+	fmt.Printf(" listdescriptors\t Prints the descriptor of the outputs of each wallet, which\n")
+	fmt.Printf("\t importdescriptor can watch elsewhere.\n")
+	fmt.Printf(" importdescriptor -descriptor DESCRIPTOR\t Adds a watch-only wallet for a descriptor such as pkh(KEY) or\n")
+	fmt.Printf("\t addr(ADDRESS) to the wallets file.\n")
+	fmt.Printf(" encryptwallet\t Encrypts the wallets file with a passphrase read from stdin.\n")
+	fmt.Printf(" walletunlock [-timeout SECONDS]\t Keeps the key of the encrypted wallets file in memory for a while,\n")
+	fmt.Printf("\t reading the passphrase from stdin.\n")
+	fmt.Printf(" walletlock\t Wipes the key kept by walletunlock.\n")
+	fmt.Println("Commands can be abbreviated to a prefix of a single command, and have")
+	fmt.Println("aliases such as bal for getbal.")
+	fmt.Println("More aliases are set in the ALIASES env var.")
 }
 
 // Run runs command line interface.
@@ -34,25 +260,325 @@ func (cli *CLI) Run() {
 	// or print instructions and exit gracefully
 	if len(os.Args) < 2 {
 
-		// no command was entered, print usage and
-		// safely shutdown go routines (so we don't
-		// corrupt our database)
+		// no command was entered, print usage and return
+		cli.printUsage()
+		return
+	}
+
+	// select the network, wallets file and output format given before the
+	// command
+	args, options, err := globalFlags(os.Args)
+	if err != nil || len(args) < 2 {
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		cli.printUsage()
+		return
+	}
+	os.Args = args
+	cli.jsonOutput = options.json
+
+	// stop long running commands on SIGINT or SIGTERM
+	cli.ctx = shutdownContext()
+
+	// apply the configuration, refusing to run any command with a setting
+	// that is not valid
+	cfg, err := cli.configure(options)
+	if err != nil {
+		log.Panicln("Unable to load configuration: ", err.Error())
+	}
+	loadConfig(cfg)
+	if cli.EnvFile != "" {
+		logger.Warn("Loading env vars from a .env file is deprecated, set them in the environment instead", "file", cli.EnvFile)
+	}
+	if err := loadNetwork(cfg.Network); err != nil {
+		log.Panicln("Unable to select network: ", err.Error())
+	}
+
+	// run the agent started by walletunlock
+	if os.Args[1] == agentCommand {
+		runAgent(os.Args[2:])
+		return
+	}
+
+	// expand aliases and abbreviations of the command
+	aliases, err := aliasesFromEnv()
+	if err != nil {
+		log.Panicln("Unable to parse env var ALIASES: ", err.Error())
+	}
+	os.Args, err = expandCommand(os.Args, aliases)
+	if err != nil {
+		fmt.Println(err.Error())
 		cli.printUsage()
-		runtime.Goexit()
+		return
 	}
 
+	// take wallet labels and contact names in place of addresses
+	os.Args = resolveAddressArgs(os.Args, func(name string) (string, error) {
+		return walletStore().Resolve(name)
+	})
+
 	// initialize command line flags
 	getBalanceCmd := flag.NewFlagSet("getbal", flag.ExitOnError)
 	createBlockchainCmd := flag.NewFlagSet("create", flag.ExitOnError)
+	getTxCmd := flag.NewFlagSet("gettx", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
 	printBlocksCmd := flag.NewFlagSet("print", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	listWalletsCmd := flag.NewFlagSet("listwallets", flag.ExitOnError)
+	addContactCmd := flag.NewFlagSet("addcontact", flag.ExitOnError)
+	listContactsCmd := flag.NewFlagSet("listcontacts", flag.ExitOnError)
+	setLabelCmd := flag.NewFlagSet("setlabel", flag.ExitOnError)
+	removeAddressCmd := flag.NewFlagSet("removeaddress", flag.ExitOnError)
+	importAddressCmd := flag.NewFlagSet("importaddress", flag.ExitOnError)
+	importKeyCmd := flag.NewFlagSet("importkey", flag.ExitOnError)
+	exportKeyCmd := flag.NewFlagSet("exportkey", flag.ExitOnError)
+	importWalletCmd := flag.NewFlagSet("importwallet", flag.ExitOnError)
+	listDescriptorsCmd := flag.NewFlagSet("listdescriptors", flag.ExitOnError)
+	importDescriptorCmd := flag.NewFlagSet("importdescriptor", flag.ExitOnError)
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	walletUnlockCmd := flag.NewFlagSet("walletunlock", flag.ExitOnError)
+	walletLockCmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+	mineCmd := flag.NewFlagSet("mine", flag.ExitOnError)
+	bumpFeeCmd := flag.NewFlagSet("bumpfee", flag.ExitOnError)
+	sweepKeyCmd := flag.NewFlagSet("sweepkey", flag.ExitOnError)
+	watchAddressCmd := flag.NewFlagSet("watchaddress", flag.ExitOnError)
+	lockUnspentCmd := flag.NewFlagSet("lockunspent", flag.ExitOnError)
+	listLockUnspentCmd := flag.NewFlagSet("listlockunspent", flag.ExitOnError)
+	getBlockCmd := flag.NewFlagSet("getblock", flag.ExitOnError)
+	proposeCmd := flag.NewFlagSet("propose", flag.ExitOnError)
+	approveCmd := flag.NewFlagSet("approve", flag.ExitOnError)
+	submitCmd := flag.NewFlagSet("submit", flag.ExitOnError)
+	exportChainCmd := flag.NewFlagSet("exportchain", flag.ExitOnError)
+	importChainCmd := flag.NewFlagSet("importchain", flag.ExitOnError)
+	runScriptCmd := flag.NewFlagSet("runscript", flag.ExitOnError)
+	createRawTxCmd := flag.NewFlagSet("createrawtx", flag.ExitOnError)
+	signRawTxCmd := flag.NewFlagSet("signrawtx", flag.ExitOnError)
+	testMempoolAcceptCmd := flag.NewFlagSet("testmempoolaccept", flag.ExitOnError)
+	sendRawTxCmd := flag.NewFlagSet("sendrawtx", flag.ExitOnError)
+	tailCmd := flag.NewFlagSet("tail", flag.ExitOnError)
+	historyCmd := flag.NewFlagSet("history", flag.ExitOnError)
+	reindexAddressesCmd := flag.NewFlagSet("reindexaddresses", flag.ExitOnError)
+	rescanCmd := flag.NewFlagSet("rescan", flag.ExitOnError)
+	jobsCmd := flag.NewFlagSet("jobs", flag.ExitOnError)
+	getBlockTemplateCmd := flag.NewFlagSet("getblocktemplate", flag.ExitOnError)
+	submitBlockCmd := flag.NewFlagSet("submitblock", flag.ExitOnError)
+	mineWorkCmd := flag.NewFlagSet("minework", flag.ExitOnError)
+	statsCmd := flag.NewFlagSet("stats", flag.ExitOnError)
+	startMinerCmd := flag.NewFlagSet("startminer", flag.ExitOnError)
+	forksCmd := flag.NewFlagSet("forks", flag.ExitOnError)
+	perfStatsCmd := flag.NewFlagSet("perfstats", flag.ExitOnError)
+	verifyChainCmd := flag.NewFlagSet("verifychain", flag.ExitOnError)
+	migrateBlocksCmd := flag.NewFlagSet("migrateblocks", flag.ExitOnError)
+	compactDBCmd := flag.NewFlagSet("compactdb", flag.ExitOnError)
+	getMerkleProofCmd := flag.NewFlagSet("getmerkleproof", flag.ExitOnError)
+	freezeCmd := flag.NewFlagSet("freeze", flag.ExitOnError)
+	unfreezeCmd := flag.NewFlagSet("unfreeze", flag.ExitOnError)
+	feeHistogramCmd := flag.NewFlagSet("feehistogram", flag.ExitOnError)
+	estimateFeeCmd := flag.NewFlagSet("estimatefee", flag.ExitOnError)
+	getBlockCandidateCmd := flag.NewFlagSet("getblockcandidate", flag.ExitOnError)
+	minerReportCmd := flag.NewFlagSet("minerreport", flag.ExitOnError)
+	coinAgeCmd := flag.NewFlagSet("coinage", flag.ExitOnError)
+	anchorCmd := flag.NewFlagSet("anchor", flag.ExitOnError)
+	findAnchorCmd := flag.NewFlagSet("findanchor", flag.ExitOnError)
+	issueTokenCmd := flag.NewFlagSet("issuetoken", flag.ExitOnError)
+	sendTokenCmd := flag.NewFlagSet("sendtoken", flag.ExitOnError)
+	getTxOutSetInfoCmd := flag.NewFlagSet("gettxoutsetinfo", flag.ExitOnError)
+	dumpUTXOSetCmd := flag.NewFlagSet("dumputxoset", flag.ExitOnError)
+	simulateDifficultyCmd := flag.NewFlagSet("simulate-difficulty", flag.ExitOnError)
+	selftestCmd := flag.NewFlagSet("selftest", flag.ExitOnError)
+	updateCmd := flag.NewFlagSet("update", flag.ExitOnError)
+	signReleaseCmd := flag.NewFlagSet("signrelease", flag.ExitOnError)
+	versionCmd := flag.NewFlagSet("version", flag.ExitOnError)
+	watchDepositsCmd := flag.NewFlagSet("watchdeposits", flag.ExitOnError)
+	webhooksCmd := flag.NewFlagSet("webhooks", flag.ExitOnError)
+	mempoolInfoCmd := flag.NewFlagSet("mempoolinfo", flag.ExitOnError)
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
+	getBalanceToken := getBalanceCmd.String("token", "", "The id of a token to get the balance of instead of coins")
+	getBalanceDetail := getBalanceCmd.Bool("detail", false, "Split the balance into trusted, untrusted pending and immature value")
+	getBalanceMinConf := getBalanceCmd.Int("minconf", 1, "The confirmations outputs need to be trusted with -detail")
 	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
+	getTxID := getTxCmd.String("id", "", "Id of the transaction")
+	getTxJSON := getTxCmd.Bool("json", false, "Print the transaction as JSON")
+	getTxVerbosity := getTxCmd.String("verbosity", "standard", "Level of detail: summary, standard or full")
+	createWalletName := createWalletCmd.String("name", "", "Name of the wallets file to create the wallet in")
+	listWalletsJSON := listWalletsCmd.Bool("json", false, "Print the wallets files as JSON")
+	sendManyFrom := sendManyCmd.String("from", "", "Source wallet address")
+	sendManyFile := sendManyCmd.String("file", "", "JSON or CSV file of the addresses and amounts to pay")
+	sendManyFee := sendManyCmd.String("fee", "0", "Fee in coins paid to the miner for each transaction")
+	sendManyQueue := sendManyCmd.Bool("queue", false, "Add the transactions to the mempool without mining a block")
 	sendFrom := sendCmd.String("from", "", "Source wallet address")
-	sendTo := sendCmd.String("to", "", "Destination wallet address")
-	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	var sendTo paymentFlags
+	sendCmd.Var(&sendTo, "to", "Destination wallet address, or ADDRESS:AMOUNT repeated to pay several addresses")
+	sendAmount := sendCmd.String("amount", "", "Amount of coins to send to a single destination")
+	sendFee := sendCmd.String("fee", "0", "Fee in coins paid to the miner")
+	sendQueue := sendCmd.Bool("queue", false, "Add the transaction to the mempool without mining a block")
+	sendWaitConfirmations := sendCmd.Int("wait-confirmations", 0, "Wait until the transaction has this many confirmations")
+	sendWaitTimeout := sendCmd.Duration("wait-timeout", 24*time.Hour, "How long to wait for confirmations")
+	sendRequestID := sendCmd.String("request-id", "", "Client request ID so retried sends are not sent twice")
+	sendDryRun := sendCmd.Bool("dry-run", false, "Build and sign the transaction and print it without sending it")
+	bumpFeeTxID := bumpFeeCmd.String("txid", "", "Id of the pending transaction to replace")
+	bumpFeeFee := bumpFeeCmd.String("fee", "", "Fee in coins the replacement pays, more than the transaction and those spending from it")
+	bumpFeeQueue := bumpFeeCmd.Bool("queue", false, "Add the replacement to the mempool without mining a block")
+	sweepKeyWIF := sweepKeyCmd.String("wif", "", "Private key to sweep in wallet import format")
+	sweepKeyTo := sweepKeyCmd.String("to", "", "Destination wallet address")
+	sweepKeyFee := sweepKeyCmd.String("fee", "0", "Fee in coins paid to the miner")
+	sweepKeyQueue := sweepKeyCmd.Bool("queue", false, "Add the transaction to the mempool without mining a block")
+	watchAddressAddress := watchAddressCmd.String("address", "", "The address to watch for a payment")
+	watchAddressAmount := watchAddressCmd.String("amount", "", "Minimum amount of coins in the payment")
+	watchAddressTimeout := watchAddressCmd.Duration("timeout", time.Hour, "How long to wait for the payment")
+	mineAddress := mineCmd.String("address", "", "The address to send the block reward to")
+	lockUnspentTxID := lockUnspentCmd.String("txid", "", "ID of the transaction holding the output")
+	lockUnspentVout := lockUnspentCmd.Int("vout", -1, "Index of the output in the transaction")
+	lockUnspentUnlock := lockUnspentCmd.Bool("unlock", false, "Unlock the output instead of locking it")
+	getBlockHash := getBlockCmd.String("hash", "", "Hash of the block")
+	getBlockHeight := getBlockCmd.Int("height", -1, "Height of the block in the chain")
+	getBlockJSON := getBlockCmd.Bool("json", false, "Print the block as JSON")
+	printBlocksJSON := printBlocksCmd.Bool("json", false, "Print the blocks as JSON")
+	printBlocksVerbosity := printBlocksCmd.String("verbosity", "standard", "Level of detail: summary, standard or full")
+	getBlockVerbosity := getBlockCmd.String("verbosity", "standard", "Level of detail: summary, standard or full")
+	proposeFrom := proposeCmd.String("from", "", "Source wallet address")
+	proposeTo := proposeCmd.String("to", "", "Destination wallet address")
+	proposeAmount := proposeCmd.String("amount", "", "Amount of coins to send")
+	proposeFee := proposeCmd.String("fee", "0", "Fee in coins paid to the miner")
+	proposeOut := proposeCmd.String("out", "", "File to save the proposal to")
+	approveIn := approveCmd.String("in", "", "Proposal file to approve")
+	approveOut := approveCmd.String("out", "", "File to save the approved proposal to")
+	submitIn := submitCmd.String("in", "", "Approved proposal file to send")
+	submitQueue := submitCmd.Bool("queue", false, "Add the transaction to the mempool without mining a block")
+	exportChainFile := exportChainCmd.String("file", "", "File to write the blocks to")
+	importChainFile := importChainCmd.String("file", "", "File to read the blocks from")
+	importChainUTXOSet := importChainCmd.String("utxoset", "", "File to read a UTXO set written by dumputxoset from")
+	importChainCommitment := importChainCmd.String("commitment", "", "Commitment the UTXO set must have, as printed by dumputxoset on a trusted node")
+	runScriptFile := runScriptCmd.String("file", "", "Script to run")
+	runScriptEvent := runScriptCmd.String("event", "", "Event to run the handler for")
+	runScriptMemory := runScriptCmd.Int("memory", 64, "Heap size in megabytes the script may use")
+	createRawTxInputs := createRawTxCmd.String("inputs", "", "Outputs to spend as TXID:VOUT separated by commas")
+	createRawTxOutputs := createRawTxCmd.String("outputs", "", "Outputs to create as ADDRESS:AMOUNT separated by commas")
+	signRawTxHex := signRawTxCmd.String("hex", "", "Raw transaction to sign")
+	testMempoolAcceptHex := testMempoolAcceptCmd.String("hex", "", "Signed raw transaction to test")
+	testMempoolAcceptJSON := testMempoolAcceptCmd.Bool("json", false, "Print the result as JSON")
+	sendRawTxHex := sendRawTxCmd.String("hex", "", "Signed raw transaction to send")
+	sendRawTxQueue := sendRawTxCmd.Bool("queue", false, "Add the transaction to the mempool without mining a block")
+	tailN := tailCmd.Int("n", 10, "Number of blocks to print")
+	tailFollow := tailCmd.Bool("follow", false, "Keep printing blocks as they are connected or disconnected")
+	tailJSON := tailCmd.Bool("json", false, "Print each event as a line of JSON")
+	historyAddress := historyCmd.String("address", "", "The address to print the history of")
+	jobsCancel := jobsCmd.String("cancel", "", "Name of a job to cancel")
+	getBlockTemplateAddress := getBlockTemplateCmd.String("address", "", "The address to send the block reward to")
+	submitBlockHex := submitBlockCmd.String("block", "", "Block from getblocktemplate")
+	submitBlockNonce := submitBlockCmd.Int("nonce", -1, "Nonce found for the block header")
+	mineWorkAddress := mineWorkCmd.String("address", "", "The address to send the block rewards to")
+	mineWorkNode := mineWorkCmd.String("node", "http://localhost:"+network.Port, "URL of the node run with serve")
+	mineWorkSolver := mineWorkCmd.String("solver", "", "Program reading work as JSON on stdin and printing a nonce, instead of mining on the CPUs")
+	mineWorkRefresh := mineWorkCmd.Duration("refresh", 30*time.Second, "How long to search work before fetching new work")
+	statsWindow := statsCmd.Int("window", blockchain.DefaultStatsWindow, "The number of blocks to average the block interval and hash rate over")
+	statsJSON := statsCmd.Bool("json", false, "Print the stats as JSON")
+	startMinerAddress := startMinerCmd.String("address", "", "The address to send the block rewards to")
+	startMinerThreads := startMinerCmd.Int("threads", runtime.NumCPU(), "How many threads to mine on")
+	startMinerAddr := startMinerCmd.String("addr", "localhost:"+network.Port, "Address to listen on")
+	startMinerRefresh := startMinerCmd.Duration("refresh", 10*time.Second, "How long to search a block template before fetching a new one")
+	forksReorgs := forksCmd.Int("reorgs", 10, "How many of the latest reorganizations to list")
+	forksJSON := forksCmd.Bool("json", false, "Print the tips and reorganizations as JSON")
+	perfStatsWindow := perfStatsCmd.Int("window", 0, "Summarize blocks in windows of this many heights instead of all together")
+	perfStatsJSON := perfStatsCmd.Bool("json", false, "Print the summaries as JSON")
+	verifyChainFrom := verifyChainCmd.Int("from", -1, "Height to verify signatures from, instead of after the latest checkpoint")
+	verifyChainRepair := verifyChainCmd.String("repair", "", "Chain export to restore corrupt block records from")
+	verifyChainFull := verifyChainCmd.Bool("full", false, "Replay the chain from genesis, verifying every signature, the undo records and the UTXO set")
+	compactDBRatio := compactDBCmd.Float64("ratio", 0, "The fraction of a value log file that must be reclaimable to rewrite it, DB_GC_RATIO by default")
+	getMerkleProofTxID := getMerkleProofCmd.String("txid", "", "Transaction id to prove")
+	freezeReason := freezeCmd.String("reason", "maintenance", "Reason shown while the chain is frozen")
+	feeHistogramJSON := feeHistogramCmd.Bool("json", false, "Print the histogram as JSON")
+	estimateFeeBlocks := estimateFeeCmd.Int("blocks", 2, "Number of blocks the transaction should be mined within")
+	estimateFeeSize := estimateFeeCmd.Int("size", paymentSize, "Size in bytes of the transaction to price")
+	estimateFeeJSON := estimateFeeCmd.Bool("json", false, "Print the estimate as JSON")
+	getBlockCandidateAddress := getBlockCandidateCmd.String("address", "", "The address the block would reward")
+	getBlockCandidateJSON := getBlockCandidateCmd.Bool("json", false, "Print the block as JSON")
+	minerReportAddress := minerReportCmd.String("address", "", "The address to report the mined blocks of")
+	minerReportJSON := minerReportCmd.Bool("json", false, "Print the report as JSON")
+	coinAgeAddress := coinAgeCmd.String("address", "", "The address to report on instead of every address in the wallets file")
+	coinAgeJSON := coinAgeCmd.Bool("json", false, "Print the report as JSON")
+	anchorFrom := anchorCmd.String("from", "", "The wallet address paying for the transaction")
+	anchorData := anchorCmd.String("data", "", "The hex encoded data to anchor, at most 80 bytes")
+	anchorFee := anchorCmd.String("fee", "0", "Fee in coins paid to the miner")
+	anchorQueue := anchorCmd.Bool("queue", false, "Add the transaction to the mempool without mining a block")
+	findAnchorData := findAnchorCmd.String("data", "", "The hex encoded data to look up")
+	findAnchorJSON := findAnchorCmd.Bool("json", false, "Print the anchor as JSON")
+	issueTokenAddress := issueTokenCmd.String("address", "", "The wallet address issuing the token and receiving its supply")
+	issueTokenSupply := issueTokenCmd.Int64("supply", 0, "The number of tokens to issue")
+	issueTokenFee := issueTokenCmd.String("fee", "0", "Fee in coins paid to the miner")
+	issueTokenQueue := issueTokenCmd.Bool("queue", false, "Add the transaction to the mempool without mining a block")
+	sendTokenFrom := sendTokenCmd.String("from", "", "The wallet address holding the tokens")
+	sendTokenTo := sendTokenCmd.String("to", "", "The address to send the tokens to")
+	sendTokenToken := sendTokenCmd.String("token", "", "The id of the token")
+	sendTokenAmount := sendTokenCmd.Int64("amount", 0, "The number of tokens to send")
+	sendTokenFee := sendTokenCmd.String("fee", "0", "Fee in coins paid to the miner")
+	sendTokenQueue := sendTokenCmd.Bool("queue", false, "Add the transaction to the mempool without mining a block")
+	getTxOutSetInfoJSON := getTxOutSetInfoCmd.Bool("json", false, "Print the summary as JSON")
+	dumpUTXOSetHeight := dumpUTXOSetCmd.Int("height", -1, "Height of the block to dump the UTXO set after, the tip by default")
+	dumpUTXOSetOutput := dumpUTXOSetCmd.String("o", "", "File to write the UTXO set to")
+	simulateBlocks := simulateDifficultyCmd.Int("blocks", 2016, "The number of blocks to simulate")
+	simulateHashRate := simulateDifficultyCmd.String("hashrate", "1e6", "The hash rate in hashes per second, or HEIGHT:RATE points separated by commas to interpolate between")
+	simulateAlgorithm := simulateDifficultyCmd.String("algorithm", blockchain.RetargetFixed, "The retarget algorithm, fixed (the rule of the chain), window or perblock")
+	simulateWindow := simulateDifficultyCmd.Int("window", 144, "The number of blocks between retargets of the window algorithm")
+	simulateSpacing := simulateDifficultyCmd.Int64("spacing", 0, "The target seconds between blocks instead of that of the network")
+	simulateDifficultyStart := simulateDifficultyCmd.Int("difficulty", 0, "The difficulty of the genesis block instead of that of the network")
+	simulateReport := simulateDifficultyCmd.Int("report", 100, "Summarize the intervals in windows of this many blocks")
+	simulateSeed := simulateDifficultyCmd.Int64("seed", 1, "The seed of the random block times")
+	simulateJSON := simulateDifficultyCmd.Bool("json", false, "Print the summaries as JSON")
+	selftestKeep := selftestCmd.Bool("keep", false, "Keep the throwaway chain and wallets file")
+	updateCheck := updateCmd.Bool("check", false, "Only report whether a new release is available")
+	updateForce := updateCmd.Bool("force", false, "Install the release even if it is the running or an older version")
+	signReleaseAddress := signReleaseCmd.String("address", "", "The address of the P-256 wallet holding the release key")
+	signReleaseVersion := signReleaseCmd.String("version", "", "The version of the release")
+	signReleaseFile := signReleaseCmd.String("file", "", "The path of the binary")
+	signReleaseURL := signReleaseCmd.String("url", "", "The URL the binary is served at")
+	signReleasePlatform := signReleaseCmd.String("platform", platform(), "The GOOS-GOARCH of the binary")
+	watchDepositsAddress := watchDepositsCmd.String("address", "", "The address to watch deposits to, or none to list the watches")
+	watchDepositsURL := watchDepositsCmd.String("url", "", "The URL posted the deposit events")
+	watchDepositsConfirmations := watchDepositsCmd.Int("confirmations", 6, "The confirmations a deposit needs to be notified")
+	watchDepositsRemove := watchDepositsCmd.Bool("remove", false, "Stop watching the address for the URL")
+	watchDepositsJSON := watchDepositsCmd.Bool("json", false, "Print the watches as JSON")
+	webhooksURL := webhooksCmd.String("url", "", "The URL posted the events, or none to list the webhooks")
+	webhooksTopics := webhooksCmd.String("topics", "", "The event types to post, separated by commas, or none for every type")
+	webhooksRemove := webhooksCmd.Bool("remove", false, "Stop posting events to the URL")
+	webhooksJSON := webhooksCmd.Bool("json", false, "Print the webhooks as JSON")
+	mempoolInfoJSON := mempoolInfoCmd.Bool("json", false, "Print the mempool info as JSON")
+	serveAddr := serveCmd.String("addr", "localhost:"+network.Port, "Address to listen on")
+	serveMine := serveCmd.String("mine", "", "Address rewarded for mining pending transactions")
+	serveInterval := serveCmd.Duration("interval", pollInterval, "How often to mine pending transactions")
+	serveThreads := serveCmd.Int("threads", 0, "Mine continuously on this many threads instead of every interval")
+	serveToken := serveCmd.String("token", "", "Token clients must send to fetch the wallets of the node at /wallets")
+	var serveWallets walletFlags
+	serveCmd.Var(&serveWallets, "wallet", "Another wallets file to serve as NAME=PATH, or NAME for the wallets file called NAME, repeated to serve several")
+	servePrioritizeWallets := serveCmd.Bool("prioritize-wallets", false, "Mine transactions from the wallets file before any other, regardless of fee")
+	serveInsecure := serveCmd.Bool("insecure", false, "Serve despite a dangerous configuration, warning about it")
+	serveWatch := serveCmd.String("watch", "", "Folder to take signed transaction files from")
+	serveTLSCert := serveCmd.String("tls-cert", "", "Certificate file to serve TLS with")
+	serveTLSKey := serveCmd.String("tls-key", "", "Key file of the -tls-cert certificate")
+	serveTLSSelfSigned := serveCmd.Bool("tls-self-signed", false, "Serve TLS with a self-signed certificate for development")
+	addContactName := addContactCmd.String("name", "", "Name of the contact")
+	addContactAddress := addContactCmd.String("address", "", "Address of the contact")
+	addContactRemove := addContactCmd.Bool("remove", false, "Remove the contact instead")
+	listContactsJSON := listContactsCmd.Bool("json", false, "Print the contacts as JSON")
+	setLabelAddress := setLabelCmd.String("address", "", "Address of the wallet")
+	setLabelLabel := setLabelCmd.String("label", "", "Label of the wallet, or none to remove it")
+	removeAddressAddress := removeAddressCmd.String("address", "", "The address of the wallet to remove")
+	removeAddressArchive := removeAddressCmd.String("archive", "", "Save the wallet to this file, encrypted, before removing it")
+	removeAddressForce := removeAddressCmd.Bool("force", false, "Remove the wallet even if it holds coins")
+	removeAddressYes := removeAddressCmd.Bool("yes", false, "Remove the wallet without asking to confirm")
+	importAddressAddress := importAddressCmd.String("address", "", "Address to watch")
+	importAddressPubKey := importAddressCmd.String("pubkey", "", "Public key to watch in hex")
+	importKeyWIF := importKeyCmd.String("wif", "", "Private key in wallet import format")
+	exportKeyAddress := exportKeyCmd.String("address", "", "Address of the wallet")
+	importWalletFrom := importWalletCmd.String("from", "", "URL of the node run with serve -token")
+	importWalletToken := importWalletCmd.String("token", "", "Token of the node")
+	importWalletKeys := importWalletCmd.Bool("keys", false, "Import the private keys from the encrypted backup of the node instead of watch-only descriptors")
+	importDescriptorDescriptor := importDescriptorCmd.String("descriptor", "", "Descriptor of the outputs to watch")
+	walletUnlockTimeout := walletUnlockCmd.Int("timeout", 300, "Seconds to keep the key before wiping it")
 
 	// parse first command line argument
 	switch os.Args[1] {
@@ -61,7 +587,7 @@ func (cli *CLI) Run() {
 		if err != nil {
 			log.Panicf("Unable to parse print command: %s", err.Error())
 		} else {
-			cli.printBlocks()
+			cli.printBlocks(*printBlocksJSON || cli.jsonOutput, parseVerbosity(*printBlocksVerbosity))
 		}
 	case "getbal":
 		err := getBalanceCmd.Parse(os.Args[2:])
@@ -73,6 +599,16 @@ func (cli *CLI) Run() {
 		if err != nil {
 			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
 		}
+	case "gettx":
+		err := getTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "sendmany":
+		err := sendManyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
 	case "send":
 		err := sendCmd.Parse(os.Args[2:])
 		if err != nil {
@@ -83,120 +619,1212 @@ func (cli *CLI) Run() {
 		if err != nil {
 			log.Panicf("Unable to parse createwallet command: %s", err.Error())
 		} else {
-			cli.createWallet()
+			cli.createWallet(*createWalletName)
+		}
+	case "addcontact":
+		err := addContactCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "listcontacts":
+		err := listContactsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "setlabel":
+		err := setLabelCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "removeaddress":
+		err := removeAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "importaddress":
+		err := importAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "importkey":
+		err := importKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "exportkey":
+		err := exportKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "importwallet":
+		err := importWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "listdescriptors":
+		err := listDescriptorsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "importdescriptor":
+		err := importDescriptorCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "encryptwallet":
+		err := encryptWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "walletunlock":
+		err := walletUnlockCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "walletlock":
+		err := walletLockCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "listwallets":
+		err := listWalletsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse listwallets command: %s", err.Error())
+		} else {
+			cli.listWallets(*listWalletsJSON || cli.jsonOutput)
 		}
 	case "listaddresses":
 		err := listAddressesCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panicf("Unable to parse listaddresses command: %s", err.Error())
 		} else {
-			cli.listAddresses()
+			cli.listAddresses(cli.jsonOutput)
+		}
+	case "mine":
+		err := mineCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "bumpfee":
+		err := bumpFeeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "sweepkey":
+		err := sweepKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "watchaddress":
+		err := watchAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "lockunspent":
+		err := lockUnspentCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "listlockunspent":
+		err := listLockUnspentCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse listlockunspent command: %s", err.Error())
+		} else {
+			cli.listLockUnspent()
+		}
+	case "getblock":
+		err := getBlockCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "propose":
+		err := proposeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "approve":
+		err := approveCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "submit":
+		err := submitCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "exportchain":
+		err := exportChainCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "importchain":
+		err := importChainCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "runscript":
+		err := runScriptCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "createrawtx":
+		err := createRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "signrawtx":
+		err := signRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "testmempoolaccept":
+		err := testMempoolAcceptCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "sendrawtx":
+		err := sendRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "tail":
+		err := tailCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "history":
+		err := historyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "reindexaddresses":
+		err := reindexAddressesCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "rescan":
+		err := rescanCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "jobs":
+		err := jobsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "getblocktemplate":
+		err := getBlockTemplateCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "submitblock":
+		err := submitBlockCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "minework":
+		err := mineWorkCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "stats":
+		err := statsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "startminer":
+		err := startMinerCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "forks":
+		err := forksCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "perfstats":
+		err := perfStatsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "verifychain":
+		err := verifyChainCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "migrateblocks":
+		err := migrateBlocksCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "compactdb":
+		err := compactDBCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "getmerkleproof":
+		err := getMerkleProofCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "freeze":
+		err := freezeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "unfreeze":
+		err := unfreezeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "feehistogram":
+		err := feeHistogramCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "estimatefee":
+		err := estimateFeeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "getblockcandidate":
+		err := getBlockCandidateCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "minerreport":
+		err := minerReportCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "coinage":
+		err := coinAgeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "anchor":
+		err := anchorCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "findanchor":
+		err := findAnchorCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "issuetoken":
+		err := issueTokenCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "sendtoken":
+		err := sendTokenCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "gettxoutsetinfo":
+		err := getTxOutSetInfoCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "dumputxoset":
+		err := dumpUTXOSetCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "simulate-difficulty":
+		err := simulateDifficultyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "selftest":
+		err := selftestCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "update":
+		err := updateCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "signrelease":
+		err := signReleaseCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "version":
+		err := versionCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "watchdeposits":
+		err := watchDepositsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "webhooks":
+		err := webhooksCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "mempoolinfo":
+		err := mempoolInfoCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
+		}
+	case "serve":
+		err := serveCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panicf("Unable to parse %s command: %s", os.Args[1], err.Error())
 		}
 	default:
-		// print usage instructions and exit gracefully
+		// print usage instructions and return
 		cli.printUsage()
-		runtime.Goexit()
+		return
 	}
 
 	// continue parsing getBalanceCmd
 	if getBalanceCmd.Parsed() {
 		if *getBalanceAddress == "" {
 			getBalanceCmd.Usage()
-			runtime.Goexit()
+			return
+		}
+		if *getBalanceToken != "" {
+			cli.getTokenBalance(*getBalanceAddress, *getBalanceToken, cli.jsonOutput)
+			return
+		}
+		if *getBalanceDetail {
+			cli.getBalances(*getBalanceAddress, *getBalanceMinConf, cli.jsonOutput)
+			return
 		}
-		cli.getBalance(*getBalanceAddress)
+		cli.getBalance(*getBalanceAddress, cli.jsonOutput)
 	}
 
 	// continue parsing createBlockchainCmd
 	if createBlockchainCmd.Parsed() {
 		if *createBlockchainAddress == "" {
 			createBlockchainCmd.Usage()
-			runtime.Goexit()
+			return
 		}
 		cli.createBlockChain(*createBlockchainAddress)
 	}
 
+	// continue parsing getTxCmd
+	if getTxCmd.Parsed() {
+		if *getTxID == "" {
+			getTxCmd.Usage()
+			return
+		}
+		cli.getTx(*getTxID, *getTxJSON || cli.jsonOutput, parseVerbosity(*getTxVerbosity))
+	}
+
+	// continue parsing sendManyCmd
+	if sendManyCmd.Parsed() {
+		fee := parseAmount(*sendManyFee)
+		if *sendManyFrom == "" || *sendManyFile == "" || fee < 0 {
+			sendManyCmd.Usage()
+			return
+		}
+		cli.sendMany(*sendManyFrom, *sendManyFile, fee, *sendManyQueue)
+	}
+
 	// continue parsing sendCmd
 	if sendCmd.Parsed() {
-		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
+		payments, err := sendTo.payments(*sendAmount)
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		fee := parseAmount(*sendFee)
+		if *sendFrom == "" || err != nil || fee < 0 || *sendWaitConfirmations < 0 || *sendDryRun && *sendWaitConfirmations > 0 {
 			sendCmd.Usage()
-			runtime.Goexit()
+			return
 		}
 
-		cli.send(*sendFrom, *sendTo, *sendAmount)
+		if *sendDryRun {
+			cli.previewSend(*sendFrom, payments, fee)
+			return
+		}
+		txID := cli.send(*sendFrom, payments, fee, *sendQueue, *sendRequestID)
+		if *sendWaitConfirmations > 0 {
+			id, err := blockchain.HashFromBytes(txID)
+			if err != nil {
+				log.Panicln("Unable to wait for confirmations: ", err.Error())
+			}
+			cli.waitForConfirmations(id, *sendWaitConfirmations, *sendWaitTimeout)
+		}
 	}
-}
 
-func (cli *CLI) createBlockChain(address string) {
-	if !wallet.ValidateAddress(address) {
-		log.Panicln("Unable to create blockchain: address not valid")
+	// continue parsing bumpFeeCmd
+	if bumpFeeCmd.Parsed() {
+		fee := parseAmount(*bumpFeeFee)
+		if *bumpFeeTxID == "" || fee <= 0 {
+			bumpFeeCmd.Usage()
+			return
+		}
+		cli.bumpFee(*bumpFeeTxID, fee, *bumpFeeQueue)
+	}
+
+	// continue parsing sweepKeyCmd
+	if sweepKeyCmd.Parsed() {
+		fee := parseAmount(*sweepKeyFee)
+		if *sweepKeyWIF == "" || *sweepKeyTo == "" || fee < 0 {
+			sweepKeyCmd.Usage()
+			return
+		}
+		cli.sweepKey(*sweepKeyWIF, *sweepKeyTo, fee, *sweepKeyQueue)
+	}
+
+	// continue parsing watchAddressCmd
+	if watchAddressCmd.Parsed() {
+		amount := parseAmount(*watchAddressAmount)
+		if *watchAddressAddress == "" || amount <= 0 || *watchAddressTimeout <= 0 {
+			watchAddressCmd.Usage()
+			return
+		}
+		cli.watchAddress(*watchAddressAddress, amount, *watchAddressTimeout)
+	}
+
+	// continue parsing mineCmd
+	if mineCmd.Parsed() {
+		if *mineAddress == "" {
+			mineCmd.Usage()
+			return
+		}
+		cli.mine(*mineAddress)
+	}
+
+	// continue parsing lockUnspentCmd
+	if lockUnspentCmd.Parsed() {
+		if *lockUnspentTxID == "" || *lockUnspentVout < 0 {
+			lockUnspentCmd.Usage()
+			return
+		}
+		cli.lockUnspent(*lockUnspentTxID, *lockUnspentVout, *lockUnspentUnlock)
+	}
+
+	// continue parsing getBlockCmd
+	if getBlockCmd.Parsed() {
+		if (*getBlockHash == "") == (*getBlockHeight < 0) {
+			getBlockCmd.Usage()
+			return
+		}
+		cli.getBlock(*getBlockHash, *getBlockHeight, *getBlockJSON || cli.jsonOutput, parseVerbosity(*getBlockVerbosity))
+	}
+
+	// continue parsing proposeCmd
+	if proposeCmd.Parsed() {
+		amount, fee := parseAmount(*proposeAmount), parseAmount(*proposeFee)
+		if *proposeFrom == "" || *proposeTo == "" || amount <= 0 || fee < 0 || *proposeOut == "" {
+			proposeCmd.Usage()
+			return
+		}
+		cli.propose(*proposeFrom, *proposeTo, amount, fee, *proposeOut)
+	}
+
+	// continue parsing approveCmd
+	if approveCmd.Parsed() {
+		if *approveIn == "" || *approveOut == "" {
+			approveCmd.Usage()
+			return
+		}
+		cli.approve(*approveIn, *approveOut)
+	}
+
+	// continue parsing submitCmd
+	if submitCmd.Parsed() {
+		if *submitIn == "" {
+			submitCmd.Usage()
+			return
+		}
+		cli.submit(*submitIn, *submitQueue)
+	}
+
+	// continue parsing exportChainCmd
+	if exportChainCmd.Parsed() {
+		if *exportChainFile == "" {
+			exportChainCmd.Usage()
+			return
+		}
+		cli.exportChain(*exportChainFile)
+	}
+
+	// continue parsing importChainCmd
+	if importChainCmd.Parsed() {
+		if *importChainFile == "" {
+			importChainCmd.Usage()
+			return
+		}
+		cli.importChain(*importChainFile, *importChainUTXOSet, *importChainCommitment)
+	}
+
+	// continue parsing runScriptCmd
+	if runScriptCmd.Parsed() {
+		if *runScriptFile == "" || *runScriptEvent == "" || *runScriptMemory <= 0 {
+			runScriptCmd.Usage()
+			return
+		}
+		cli.runScript(*runScriptFile, *runScriptEvent, *runScriptMemory)
+	}
+
+	// continue parsing createRawTxCmd
+	if createRawTxCmd.Parsed() {
+		if *createRawTxInputs == "" || *createRawTxOutputs == "" {
+			createRawTxCmd.Usage()
+			return
+		}
+		cli.createRawTx(*createRawTxInputs, *createRawTxOutputs)
+	}
+
+	// continue parsing signRawTxCmd
+	if signRawTxCmd.Parsed() {
+		if *signRawTxHex == "" {
+			signRawTxCmd.Usage()
+			return
+		}
+		cli.signRawTx(*signRawTxHex)
+	}
+
+	// continue parsing testMempoolAcceptCmd
+	if testMempoolAcceptCmd.Parsed() {
+		if *testMempoolAcceptHex == "" {
+			testMempoolAcceptCmd.Usage()
+			return
+		}
+		cli.testMempoolAccept(*testMempoolAcceptHex, *testMempoolAcceptJSON || cli.jsonOutput)
+	}
+
+	// continue parsing sendRawTxCmd
+	if sendRawTxCmd.Parsed() {
+		if *sendRawTxHex == "" {
+			sendRawTxCmd.Usage()
+			return
+		}
+		cli.sendRawTx(*sendRawTxHex, *sendRawTxQueue)
+	}
+
+	// continue parsing tailCmd
+	if tailCmd.Parsed() {
+		if *tailN < 0 {
+			tailCmd.Usage()
+			return
+		}
+		cli.tail(*tailN, *tailFollow, *tailJSON || cli.jsonOutput)
+	}
+
+	// continue parsing historyCmd
+	if historyCmd.Parsed() {
+		if *historyAddress == "" {
+			historyCmd.Usage()
+			return
+		}
+		cli.history(*historyAddress)
+	}
+
+	// continue parsing reindexAddressesCmd
+	if reindexAddressesCmd.Parsed() {
+		cli.reindexAddresses()
+	}
+
+	// continue parsing rescanCmd
+	if rescanCmd.Parsed() {
+		cli.rescan()
+	}
+
+	// continue parsing jobsCmd
+	if jobsCmd.Parsed() {
+		cli.listJobs(*jobsCancel)
+	}
+
+	// continue parsing getBlockTemplateCmd
+	if getBlockTemplateCmd.Parsed() {
+		if *getBlockTemplateAddress == "" {
+			getBlockTemplateCmd.Usage()
+			return
+		}
+		cli.getBlockTemplate(*getBlockTemplateAddress)
+	}
+
+	// continue parsing submitBlockCmd
+	if submitBlockCmd.Parsed() {
+		if *submitBlockHex == "" || *submitBlockNonce < 0 {
+			submitBlockCmd.Usage()
+			return
+		}
+		cli.submitBlock(*submitBlockHex, *submitBlockNonce)
+	}
+
+	// continue parsing mineWorkCmd
+	if mineWorkCmd.Parsed() {
+		if *mineWorkAddress == "" || *mineWorkRefresh <= 0 {
+			mineWorkCmd.Usage()
+			return
+		}
+		cli.mineWork(*mineWorkNode, *mineWorkAddress, *mineWorkSolver, *mineWorkRefresh)
+	}
+
+	// continue parsing statsCmd
+	if statsCmd.Parsed() {
+		if *statsWindow <= 0 {
+			statsCmd.Usage()
+			return
+		}
+		cli.stats(*statsWindow, *statsJSON || cli.jsonOutput)
+	}
+
+	// continue parsing startMinerCmd
+	if startMinerCmd.Parsed() {
+		if *startMinerAddress == "" || *startMinerThreads <= 0 || *startMinerRefresh <= 0 {
+			startMinerCmd.Usage()
+			return
+		}
+		cli.serve(*startMinerAddr, *startMinerAddress, *startMinerRefresh, "", nil, false, false, "", "", "", false, *startMinerThreads)
+	}
+
+	// continue parsing forksCmd
+	if forksCmd.Parsed() {
+		if *forksReorgs < 0 {
+			forksCmd.Usage()
+			return
+		}
+		cli.forks(*forksReorgs, *forksJSON || cli.jsonOutput)
+	}
+
+	// continue parsing perfStatsCmd
+	if perfStatsCmd.Parsed() {
+		if *perfStatsWindow < 0 {
+			perfStatsCmd.Usage()
+			return
+		}
+		cli.perfStats(*perfStatsWindow, *perfStatsJSON || cli.jsonOutput)
+	}
+
+	// continue parsing verifyChainCmd
+	if verifyChainCmd.Parsed() {
+		if *verifyChainFull {
+			cli.auditChain(*verifyChainRepair)
+			return
+		}
+		cli.verifyChain(*verifyChainFrom, *verifyChainRepair)
+	}
+
+	// continue parsing migrateBlocksCmd
+	if migrateBlocksCmd.Parsed() {
+		cli.migrateBlocks()
+	}
+
+	// continue parsing compactDBCmd
+	if compactDBCmd.Parsed() {
+		cli.compactDB(*compactDBRatio)
+	}
+
+	// continue parsing getMerkleProofCmd
+	if getMerkleProofCmd.Parsed() {
+		if *getMerkleProofTxID == "" {
+			getMerkleProofCmd.Usage()
+			return
+		}
+		cli.getMerkleProof(*getMerkleProofTxID)
+	}
+
+	// continue parsing freezeCmd
+	if freezeCmd.Parsed() {
+		cli.freeze(*freezeReason)
+	}
+
+	// continue parsing unfreezeCmd
+	if unfreezeCmd.Parsed() {
+		cli.unfreeze()
+	}
+
+	// continue parsing feeHistogramCmd
+	if feeHistogramCmd.Parsed() {
+		cli.feeHistogram(*feeHistogramJSON || cli.jsonOutput)
+	}
+
+	// continue parsing estimateFeeCmd
+	if estimateFeeCmd.Parsed() {
+		cli.estimateFee(*estimateFeeBlocks, *estimateFeeSize, *estimateFeeJSON || cli.jsonOutput)
+	}
+
+	// continue parsing getBlockCandidateCmd
+	if getBlockCandidateCmd.Parsed() {
+		if *getBlockCandidateAddress == "" {
+			getBlockCandidateCmd.Usage()
+			return
+		}
+		cli.getBlockCandidate(*getBlockCandidateAddress, *getBlockCandidateJSON || cli.jsonOutput)
+	}
+
+	// continue parsing minerReportCmd
+	if minerReportCmd.Parsed() {
+		if *minerReportAddress == "" {
+			minerReportCmd.Usage()
+			return
+		}
+		cli.minerReport(*minerReportAddress, *minerReportJSON || cli.jsonOutput)
+	}
+
+	// continue parsing coinAgeCmd
+	if coinAgeCmd.Parsed() {
+		cli.coinAge(*coinAgeAddress, *coinAgeJSON || cli.jsonOutput)
+	}
+
+	// continue parsing anchorCmd
+	if anchorCmd.Parsed() {
+		fee := parseAmount(*anchorFee)
+		if *anchorFrom == "" || *anchorData == "" || fee < 0 {
+			anchorCmd.Usage()
+			return
+		}
+		cli.anchor(*anchorFrom, *anchorData, fee, *anchorQueue)
+	}
+
+	// continue parsing findAnchorCmd
+	if findAnchorCmd.Parsed() {
+		if *findAnchorData == "" {
+			findAnchorCmd.Usage()
+			return
+		}
+		cli.findAnchor(*findAnchorData, *findAnchorJSON || cli.jsonOutput)
+	}
+
+	// continue parsing issueTokenCmd
+	if issueTokenCmd.Parsed() {
+		fee := parseAmount(*issueTokenFee)
+		if *issueTokenAddress == "" || *issueTokenSupply <= 0 || fee < 0 {
+			issueTokenCmd.Usage()
+			return
+		}
+		cli.issueToken(*issueTokenAddress, *issueTokenSupply, fee, *issueTokenQueue)
+	}
+
+	// continue parsing sendTokenCmd
+	if sendTokenCmd.Parsed() {
+		fee := parseAmount(*sendTokenFee)
+		if *sendTokenFrom == "" || *sendTokenTo == "" || *sendTokenToken == "" || *sendTokenAmount <= 0 || fee < 0 {
+			sendTokenCmd.Usage()
+			return
+		}
+		cli.sendToken(*sendTokenFrom, *sendTokenTo, *sendTokenToken, *sendTokenAmount, fee, *sendTokenQueue)
+	}
+
+	// continue parsing getTxOutSetInfoCmd
+	if getTxOutSetInfoCmd.Parsed() {
+		cli.getTxOutSetInfo(*getTxOutSetInfoJSON || cli.jsonOutput)
+	}
+
+	// continue parsing dumpUTXOSetCmd
+	if dumpUTXOSetCmd.Parsed() {
+		if *dumpUTXOSetOutput == "" {
+			dumpUTXOSetCmd.Usage()
+			return
+		}
+		cli.dumpUTXOSet(*dumpUTXOSetHeight, *dumpUTXOSetOutput)
+	}
+
+	// continue parsing simulateDifficultyCmd
+	if simulateDifficultyCmd.Parsed() {
+		cli.simulateDifficulty(blockchain.SimulationConfig{
+			Difficulty: *simulateDifficultyStart,
+			Blocks:     *simulateBlocks,
+			Algorithm:  *simulateAlgorithm,
+			Window:     *simulateWindow,
+			Seed:       *simulateSeed,
+		}, *simulateHashRate, *simulateSpacing, *simulateReport, *simulateJSON || cli.jsonOutput)
+	}
+
+	// continue parsing selftestCmd
+	if selftestCmd.Parsed() {
+		cli.selftest(*selftestKeep)
+	}
+
+	// continue parsing updateCmd
+	if updateCmd.Parsed() {
+		cli.update(*updateCheck, *updateForce)
+	}
+
+	// continue parsing signReleaseCmd
+	if signReleaseCmd.Parsed() {
+		if *signReleaseAddress == "" || *signReleaseVersion == "" || *signReleaseFile == "" {
+			signReleaseCmd.Usage()
+			return
+		}
+		cli.signRelease(*signReleaseAddress, *signReleaseVersion, *signReleasePlatform, *signReleaseURL, *signReleaseFile)
+	}
+
+	// continue parsing versionCmd
+	if versionCmd.Parsed() {
+		fmt.Println(Version)
+	}
+
+	// continue parsing watchDepositsCmd
+	if watchDepositsCmd.Parsed() {
+		if *watchDepositsAddress != "" && *watchDepositsURL == "" {
+			watchDepositsCmd.Usage()
+			os.Exit(1)
+		}
+		cli.watchDeposits(*watchDepositsAddress, *watchDepositsURL, *watchDepositsConfirmations, *watchDepositsRemove, *watchDepositsJSON || cli.jsonOutput)
+	}
+
+	// continue parsing webhooksCmd
+	if webhooksCmd.Parsed() {
+		cli.webhooks(*webhooksURL, *webhooksTopics, *webhooksRemove, *webhooksJSON || cli.jsonOutput)
+	}
+
+	// continue parsing mempoolInfoCmd
+	if mempoolInfoCmd.Parsed() {
+		cli.mempoolInfo(*mempoolInfoJSON || cli.jsonOutput)
+	}
+
+	// continue parsing serveCmd
+	if serveCmd.Parsed() {
+		if *serveInterval <= 0 || *serveThreads < 0 {
+			serveCmd.Usage()
+			return
+		}
+		cli.serve(*serveAddr, *serveMine, *serveInterval, *serveToken, serveWallets, *servePrioritizeWallets, *serveInsecure, *serveWatch, *serveTLSCert, *serveTLSKey, *serveTLSSelfSigned, *serveThreads)
+	}
+
+	// continue parsing addContactCmd
+	if addContactCmd.Parsed() {
+		if *addContactName == "" || *addContactAddress == "" && !*addContactRemove {
+			addContactCmd.Usage()
+			return
+		}
+		cli.addContact(*addContactName, *addContactAddress, *addContactRemove)
+	}
+
+	// continue parsing listContactsCmd
+	if listContactsCmd.Parsed() {
+		cli.listContacts(*listContactsJSON || cli.jsonOutput)
+	}
+
+	// continue parsing setLabelCmd
+	if setLabelCmd.Parsed() {
+		if *setLabelAddress == "" {
+			setLabelCmd.Usage()
+			return
+		}
+		cli.setLabel(*setLabelAddress, *setLabelLabel)
+	}
+
+	// continue parsing removeAddressCmd
+	if removeAddressCmd.Parsed() {
+		if *removeAddressAddress == "" {
+			removeAddressCmd.Usage()
+			return
+		}
+		cli.removeAddress(*removeAddressAddress, *removeAddressArchive, *removeAddressForce, *removeAddressYes)
+	}
+
+	// continue parsing importAddressCmd
+	if importAddressCmd.Parsed() {
+		if (*importAddressAddress == "") == (*importAddressPubKey == "") {
+			importAddressCmd.Usage()
+			return
+		}
+		cli.importAddress(*importAddressAddress, *importAddressPubKey)
+	}
+
+	// continue parsing importKeyCmd
+	if importKeyCmd.Parsed() {
+		if *importKeyWIF == "" {
+			importKeyCmd.Usage()
+			return
+		}
+		cli.importKey(*importKeyWIF)
+	}
+
+	// continue parsing exportKeyCmd
+	if exportKeyCmd.Parsed() {
+		if *exportKeyAddress == "" {
+			exportKeyCmd.Usage()
+			return
+		}
+		cli.exportKey(*exportKeyAddress)
+	}
+
+	// continue parsing importWalletCmd
+	if importWalletCmd.Parsed() {
+		if *importWalletFrom == "" || *importWalletToken == "" {
+			importWalletCmd.Usage()
+			return
+		}
+		cli.importWallet(*importWalletFrom, *importWalletToken, *importWalletKeys)
+	}
+
+	// continue parsing listDescriptorsCmd
+	if listDescriptorsCmd.Parsed() {
+		cli.listDescriptors()
+	}
+
+	// continue parsing importDescriptorCmd
+	if importDescriptorCmd.Parsed() {
+		if *importDescriptorDescriptor == "" {
+			importDescriptorCmd.Usage()
+			return
+		}
+		cli.importDescriptor(*importDescriptorDescriptor)
+	}
+
+	// continue parsing encryptWalletCmd
+	if encryptWalletCmd.Parsed() {
+		cli.encryptWallet()
+	}
+
+	// continue parsing walletUnlockCmd
+	if walletUnlockCmd.Parsed() {
+		if *walletUnlockTimeout <= 0 {
+			walletUnlockCmd.Usage()
+			return
+		}
+		cli.walletUnlock(time.Duration(*walletUnlockTimeout) * time.Second)
+	}
+
+	// continue parsing walletLockCmd
+	if walletLockCmd.Parsed() {
+		cli.walletLock()
 	}
-	bc := blockchain.InitBlockChain(address)
-	bc.DB.Close()
-	fmt.Println("Finished!")
 }
 
-func (cli *CLI) getBalance(address string) {
+func (cli *CLI) createBlockChain(address string) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to create blockchain: address not valid")
+	}
+	bc := openBlockChain(address)
+	bc.Close()
+	fmt.Println("Finished!")
+}
+
+func (cli *CLI) getBalance(address string, asJSON bool) {
 	if !wallet.ValidateAddress(address) {
 		log.Panicln("Unable to get balance: address not valid")
 	}
-	bc := blockchain.InitBlockChain(address)
-	defer bc.DB.Close()
+	var balance units.Amount
 
-	balance := 0
+	// ask the node running on this machine if there is one, as it holds
+	// the database
+	if node := localNode(); node != nil {
+		balances, err := node.balances(address, 1)
+		if err != nil {
+			log.Panicln("Unable to get balance: ", err.Error())
+		}
+		balance = balances.Trusted + balances.UntrustedPending + balances.Immature
+	} else {
+		bc := queryBlockChain(address)
+		defer bc.Close()
+
+		pubKeyHash := pubKeyHashFromAddress(address)
+		unspentTxOutputs, err := bc.FindUnspentTxOutputs(pubKeyHash)
+		if err != nil {
+			log.Panicln("Unable to get balance: ", err.Error())
+		}
 
-	checksumLen, err := strconv.Atoi(os.Getenv("CHECKSUM_LENGTH"))
+		for _, out := range unspentTxOutputs {
+			balance += out.Value
+		}
+	}
+
+	if asJSON {
+		printJSON(map[string]interface{}{"address": address, "balance": balance})
+		return
+	}
+	fmt.Printf("Balance of %s: %s\n", address, units.FormatAmount(balance))
+}
+
+// getBalances prints the balance of an address split into trusted,
+// untrusted pending and immature value, trusting outputs with minConf
+// confirmations, and the value pending transactions move to and from it.
+func (cli *CLI) getBalances(address string, minConf int, asJSON bool) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to get balance: address not valid")
+	}
+	var balances blockchain.Balances
+	var transfers blockchain.PendingTransfers
+
+	// ask the node running on this machine if there is one, as it holds
+	// the database
+	if node := localNode(); node != nil {
+		b, err := node.balances(address, minConf)
+		if err != nil {
+			log.Panicln("Unable to get balance: ", err.Error())
+		}
+		balances = blockchain.Balances{Trusted: b.Trusted, UntrustedPending: b.UntrustedPending, Immature: b.Immature}
+		transfers = blockchain.PendingTransfers{Incoming: b.PendingIncoming, Outgoing: b.PendingOutgoing}
+	} else {
+		bc := queryBlockChain(address)
+		defer bc.Close()
+
+		pubKeyHash := pubKeyHashFromAddress(address)
+		var err error
+		balances, err = bc.Balances(pubKeyHash, minConf)
+		if err != nil {
+			log.Panicln("Unable to get balance: ", err.Error())
+		}
+		transfers = bc.PendingTransfers(pubKeyHash)
+	}
+	if asJSON {
+		printJSON(map[string]interface{}{
+			"address":           address,
+			"balance":           balances.Total(),
+			"minconf":           minConf,
+			"trusted":           balances.Trusted,
+			"untrusted_pending": balances.UntrustedPending,
+			"immature":          balances.Immature,
+			"pending_incoming":  transfers.Incoming,
+			"pending_outgoing":  transfers.Outgoing,
+		})
+		return
+	}
+	fmt.Printf("Balance of %s: %s\n", address, units.FormatAmount(balances.Total()))
+	fmt.Printf("  Trusted:           %s\n", units.FormatAmount(balances.Trusted))
+	fmt.Printf("  Untrusted pending: %s\n", units.FormatAmount(balances.UntrustedPending))
+	fmt.Printf("  Immature:          %s\n", units.FormatAmount(balances.Immature))
+	fmt.Printf("  Pending incoming:  %s\n", units.FormatAmount(transfers.Incoming))
+	fmt.Printf("  Pending outgoing:  %s\n", units.FormatAmount(transfers.Outgoing))
+}
+
+// pubKeyHashFromAddress decodes an address back into its public key hash.
+func pubKeyHashFromAddress(address string) []byte {
+	pubKeyHash, err := wallet.PublicKeyHashFromAddress(address)
 	if err != nil {
-		log.Panicln("Unable to convert env var CHECKSUM_LENGTH to int for method (TxOutput) Lock: ", err.Error())
+		log.Panicln("Unable to decode address: ", err.Error())
 	}
+	return pubKeyHash
+}
 
-	// decode address from base58 back to sha256 hash
-	pubKeyHash := base58.Decode(string(address[:]))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-checksumLen]
+// send sends payments from an address in a single transaction and returns
+// the id of the new transaction. If requestID has already been completed,
+// nothing is sent and the id of the transaction created for it is returned.
+func (cli *CLI) send(from string, payments []blockchain.Payment, fee units.Amount, queue bool, requestID string) []byte {
+	validateSend(from, payments)
+	if node := localNode(); node != nil {
+		return sendWithNode(node, from, payments, fee, requestID)
+	}
+	bc := openBlockChain(from)
+	defer bc.Close()
 
-	unspentTxOutputs := bc.FindUnspentTxOutputs(pubKeyHash)
+	// return the existing transaction for a retried request
+	if requestID != "" {
+		if txID, ok := bc.RequestTransaction(requestID); ok {
+			fmt.Printf("Request %s already completed with transaction %x\n", requestID, txID)
+			return txID
+		}
+	}
 
-	for _, out := range unspentTxOutputs {
-		balance += out.Value
+	// send any change to a fresh key, which is only saved once the
+	// transaction has been built with a change output
+	tx, change := cli.buildSend(bc, from, payments, fee)
+	if tx.ValueTo(wallet.GeneratePublicKeyHash(change.PublicKey)) > 0 {
+		if err := walletStore().Add(change); err != nil {
+			log.Panicln("Unable to save change address: ", err.Error())
+		}
 	}
+	var err error
+	if requestID != "" {
+		err = bc.AddRequestToMempool(requestID, tx)
+	} else {
+		err = bc.AddToMempool(tx)
+	}
+	if err != nil {
+		log.Panicln("Unable to add transaction to mempool: ", err.Error())
+	}
+
+	// leave the transaction pending if it was queued, otherwise
+	// mine it along with any other pending transactions, rewarding
+	// the sender as the miner
+	if queue {
+		fmt.Printf("Transaction %x added to mempool\n", tx.ID)
+		return tx.ID
+	}
+	if !cli.mineSent(bc, from, tx.ID) {
+		return tx.ID
+	}
+	fmt.Println("Success!")
+
+	return tx.ID
+}
 
-	fmt.Printf("Balance of %s: %d\n", address, balance)
+// sendWithNode sends a payment from an address of the wallets file with
+// the node running on this machine, which holds the database and the
+// wallets file, and returns the id of the new transaction. The node adds it
+// to its mempool, and mines it if it runs a miner.
+func sendWithNode(node *nodeClient, from string, payments []blockchain.Payment, fee units.Amount, requestID string) []byte {
+	if len(payments) != 1 {
+		log.Panicln("Unable to send: a running node pays a single address, pay several with sendmany once it is stopped")
+	}
+	send := walletSend{From: from, To: payments[0].To, Amount: payments[0].Amount, Fee: fee, RequestID: requestID}
+	txID, err := node.send(selectedWallet(), send)
+	if err != nil {
+		log.Panicln("Unable to send: ", err.Error())
+	}
+	fmt.Printf("Transaction %x added to the mempool of the node\n", txID)
+	return txID
 }
 
-func (cli *CLI) send(from, to string, amount int) {
+// sweepKey sends every spendable output of a private key to an address
+// without importing the key into the wallets file.
+func (cli *CLI) sweepKey(wif, to string, fee units.Amount, queue bool) {
 	if !wallet.ValidateAddress(to) {
-		log.Panicln("Unable to initiate send transaction: to address not valid")
+		log.Panicln("Unable to sweep key: to address not valid")
 	}
-	if !wallet.ValidateAddress(from) {
-		log.Panicln("Unable to initiate send transaction: from address not valid")
+	w, err := wallet.DecodeWIF(wif)
+	if err != nil {
+		log.Panicln("Unable to decode private key: ", err.Error())
 	}
-	bc := blockchain.InitBlockChain(from)
-	defer bc.DB.Close()
+	bc := openBlockChain(to)
+	defer bc.Close()
 
-	tx := bc.NewTransaction(from, to, amount)
-	bc.AddBlock([]*blockchain.Transaction{tx})
-	fmt.Println("Success!")
+	tx, err := bc.SweepTransaction(w, to, fee)
+	if err != nil {
+		log.Panicln("Unable to sweep key: ", err.Error())
+	}
+	if err := bc.AddToMempool(tx); err != nil {
+		log.Panicln("Unable to add transaction to mempool: ", err.Error())
+	}
+
+	// leave the transaction pending if it was queued, otherwise
+	// mine it rewarding the destination address
+	if queue {
+		fmt.Printf("Transaction %x added to mempool\n", tx.ID)
+		return
+	}
+	if !cli.mineSent(bc, to, tx.ID) {
+		return
+	}
+	fmt.Printf("Swept %s to %s\n", units.FormatAmount(tx.Outputs[0].Value), to)
+}
+
+// mine mines a block with the pending transactions in the mempool.
+func (cli *CLI) mine(address string) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to mine block: address not valid")
+	}
+	bc := openBlockChain(address)
+	defer bc.Close()
+
+	block, err := bc.MinePendingContext(cli.ctx, address)
+	if err != nil {
+		log.Panicln("Unable to mine block: ", err.Error())
+	}
+	fmt.Printf("Mined block %x with %d transactions\n", block.Hash, len(block.Transactions))
 }
 
 // printBlocks iterates over each block in the blockchain,
-// printing them out one-by-one
-func (cli *CLI) printBlocks() {
-	bc := blockchain.InitBlockChain("")
-	defer bc.DB.Close()
+// printing them out one-by-one, or as a JSON array if asJSON is set
+func (cli *CLI) printBlocks(asJSON bool, verbosity blockchain.Verbosity) {
+	var blocks []*blockchain.Block
+	bc := queryBlockChain("")
+	defer bc.Close()
 	iter := bc.NewIterator()
 
 	// iterate over blocks
 	for {
 		block := iter.Next()
-
-		fmt.Printf("\nPrevious Hash: %x\n", block.PrevHash)
-		fmt.Printf("Hash: %x\n", block.Hash)
-
-		pow := blockchain.NewProof(block)
-		fmt.Printf("PoW: %s\n", strconv.FormatBool(pow.Validate()))
-
-		for _, tx := range block.Transactions {
-			fmt.Println(tx)
+		if asJSON {
+			blocks = append(blocks, block)
+		} else {
+			printBlock(block, verbosity)
 		}
 
 		// break once PrevHash is empty (Genesis block has been reached)
@@ -204,29 +1832,397 @@ func (cli *CLI) printBlocks() {
 			break
 		}
 	}
+
+	// print collected blocks as JSON
+	if asJSON {
+		printJSON(blocks)
+	}
+}
+
+// getBlock prints the block with a hash, or at a height if hash is empty.
+func (cli *CLI) getBlock(hash string, height int, asJSON bool, verbosity blockchain.Verbosity) {
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	var block *blockchain.Block
+	var err error
+	if hash != "" {
+		id, parseErr := blockchain.ParseHash(hash)
+		if parseErr != nil {
+			log.Panicln("Unable to decode block hash: ", parseErr.Error())
+		}
+		block, err = bc.GetBlock(id.Bytes())
+	} else {
+		block, err = bc.GetBlockByHeight(height)
+	}
+	if err != nil {
+		log.Panicln("Unable to get block: ", err.Error())
+	}
+
+	final := bc.Final(bc.BlockConfirmations(block))
+
+	if asJSON {
+		data, err := json.Marshal(block)
+		if err != nil {
+			log.Panicln("Unable to encode JSON: ", err.Error())
+		}
+		printJSON(withJSONField(data, "final", final))
+		return
+	}
+	printBlock(block, verbosity)
+	if final {
+		fmt.Println("Final: true")
+	}
+}
+
+// getTx prints a pending or confirmed transaction by id with its number of
+// confirmations.
+func (cli *CLI) getTx(id string, asJSON bool, verbosity blockchain.Verbosity) {
+	txID, err := blockchain.ParseHash(id)
+	if err != nil {
+		log.Panicln("Unable to decode transaction id: ", err.Error())
+	}
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	tx, err := bc.FindTransaction(txID.Bytes())
+	if err != nil {
+		log.Panicln("Unable to get transaction: ", err.Error())
+	}
+	confirmations := bc.Confirmations(txID)
+	timestamp, known, err := bc.TransactionTime(txID)
+	if err != nil {
+		log.Panicln("Unable to get transaction: ", err.Error())
+	}
+
+	if asJSON {
+		result := map[string]interface{}{"transaction": tx, "confirmations": confirmations, "final": bc.Final(confirmations)}
+		if known {
+			result["timestamp"] = timestamp
+			result["time"] = blockchain.FormatTimestamp(timestamp)
+		}
+		printJSON(result)
+		return
+	}
+	fmt.Println(tx.Format(verbosity, useColor()))
+
+	// confirmed transactions happened when their block was mined, and
+	// pending ones when they entered the mempool
+	if known && confirmations > 0 {
+		fmt.Printf("Mined:         %s\n", blockchain.FormatTimestamp(timestamp))
+	} else if known {
+		fmt.Printf("Received:      %s\n", blockchain.FormatTimestamp(timestamp))
+	}
+	fmt.Printf("Confirmations: %d\n", confirmations)
+	if bc.Final(confirmations) {
+		fmt.Println("Final: true")
+	}
+}
+
+// printJSON prints a value as indented JSON.
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Panicln("Unable to encode JSON: ", err.Error())
+	}
+	fmt.Println(string(data))
+}
+
+// withJSONField adds a field to the end of a JSON object, keeping the order
+// of its other fields.
+func withJSONField(object []byte, name string, value interface{}) json.RawMessage {
+	field, err := json.Marshal(map[string]interface{}{name: value})
+	if err != nil {
+		log.Panicln("Unable to encode JSON: ", err.Error())
+	}
+	return json.RawMessage(append(append(object[:len(object)-1:len(object)-1], ','), field[1:]...))
+}
+
+// printBlock prints a block and its transactions, in color if stdout is a
+// terminal.
+func printBlock(block *blockchain.Block, verbosity blockchain.Verbosity) {
+	fmt.Println(block.Format(verbosity, useColor()))
+	if verbosity > blockchain.Summary {
+		fmt.Println()
+	}
+}
+
+// useColor returns whether stdout is a terminal and NO_COLOR is not set.
+func useColor() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// parseVerbosity parses a verbosity flag, exiting like a flag parse error on
+// a bad value.
+func parseVerbosity(name string) blockchain.Verbosity {
+	verbosity, err := blockchain.ParseVerbosity(name)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(2)
+	}
+	return verbosity
+}
+
+// parseAmount parses an amount of coins flag into base units, exiting like
+// a flag parse error on a bad value. An empty value is 0.
+func parseAmount(value string) units.Amount {
+	if value == "" {
+		return 0
+	}
+	amount, err := units.ParseAmount(value)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(2)
+	}
+	return amount
+}
+
+// exportChain writes the blocks in the chain to a file.
+func (cli *CLI) exportChain(file string) {
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	f, err := os.Create(file)
+	if err != nil {
+		log.Panicln("Unable to create export file: ", err.Error())
+	}
+	defer f.Close()
+
+	count, err := bc.ExportChain(f)
+	if err != nil {
+		log.Panicln("Unable to export chain: ", err.Error())
+	}
+	fmt.Printf("Exported %d blocks to %s\n", count, file)
+}
+
+// importChain creates a blockchain from a file written by exportChain. If
+// utxoSet is set, the chain starts from the UTXO set written by dumpUTXOSet
+// in it, which must have commitment, or is trusted without one, and only
+// the blocks after its height are validated.
+func (cli *CLI) importChain(file, utxoSet, commitment string) {
+	f, err := os.Open(file)
+	if err != nil {
+		log.Panicln("Unable to open import file: ", err.Error())
+	}
+	defer f.Close()
+
+	var bc *blockchain.BlockChain
+	if utxoSet == "" {
+		bc, err = blockchain.ImportChain(blockChainConfig(""), f)
+	} else {
+		set := readUTXOSet(utxoSet)
+		trusted := set.Commitment()
+		if commitment == "" {
+			logger.Warn("Trusting UTXO set without a known commitment", "height", set.Height, "commitment", trusted)
+		} else if trusted, err = blockchain.ParseHash(commitment); err != nil {
+			log.Panicln("Unable to parse commitment: ", err.Error())
+		}
+		bc, err = blockchain.ImportChainFromUTXOSet(blockChainConfig(""), f, set, trusted)
+	}
+	if bc != nil {
+		defer bc.Close()
+	}
+	if err != nil {
+		log.Panicln("Unable to import chain: ", err.Error())
+	}
+	if utxoSet != "" {
+		fmt.Printf("Imported %d blocks, validating those after the UTXO set\n", bc.Height()+1)
+		return
+	}
+	fmt.Printf("Imported %d blocks\n", bc.Height()+1)
+}
+
+// runScript runs the handler for an event in a script, exiting with a
+// non-zero status if it fails so the parent process can reject the event.
+func (cli *CLI) runScript(file, event string, memoryLimit int) {
+	payload, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Panicln("Unable to read event payload: ", err.Error())
+	}
+	if err := scripts.Run(file, event, payload, memoryLimit); err != nil {
+		logger.Error("Script failed", "script", file, "event", event, "err", err)
+		os.Exit(1)
+	}
+}
+
+// lockUnspent locks or unlocks a transaction output.
+func (cli *CLI) lockUnspent(txID string, vout int, unlock bool) {
+	id, err := blockchain.ParseHash(txID)
+	if err != nil {
+		log.Panicln("Unable to decode transaction id: ", err.Error())
+	}
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	if unlock {
+		err = bc.UnlockUnspent(id, vout)
+	} else {
+		err = bc.LockUnspent(id, vout)
+	}
+	if err != nil {
+		log.Panicln("Unable to update output lock: ", err.Error())
+	}
+	fmt.Println("Success!")
+}
+
+// listLockUnspent lists the locked transaction outputs.
+func (cli *CLI) listLockUnspent() {
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	for txID, outs := range bc.LockedOutputs() {
+		for _, out := range outs {
+			fmt.Printf("%s:%d\n", txID, out)
+		}
+	}
 }
 
 // listAddresses lists the addresses in the wallets file.
-func (cli *CLI) listAddresses() {
-	wallets, _ := wallet.CreateWallets()
+func (cli *CLI) listAddresses(asJSON bool) {
+	wallets, err := walletStore().Wallets()
+	if err != nil {
+		log.Panicln("Unable to load wallets: ", err.Error())
+	}
+	if asJSON {
+		type jsonAddress struct {
+			Address   string `json:"address"`
+			WatchOnly bool   `json:"watchOnly"`
+			Label     string `json:"label,omitempty"`
+		}
+		addresses := []jsonAddress{}
+		for address, w := range wallets {
+			addresses = append(addresses, jsonAddress{address, w.WatchOnly(), w.Label})
+		}
+		sort.Slice(addresses, func(i, j int) bool { return addresses[i].Address < addresses[j].Address })
+		printJSON(addresses)
+		return
+	}
+	for address, w := range wallets {
+		line := address
+		if w.Label != "" {
+			line += " " + w.Label
+		}
+		if w.WatchOnly() {
+			line += " (watch-only)"
+		}
+		fmt.Println(line)
+	}
+}
+
+// importAddress adds a watch-only wallet for an address or a public key in
+// hex to the wallets file, so its balance and history can be followed
+// without its private key.
+func (cli *CLI) importAddress(address, pubKeyHex string) {
+	var w *wallet.Wallet
+	var err error
+	if address != "" {
+		w, err = wallet.NewWatchOnly(address)
+	} else {
+		var pubKey []byte
+		if pubKey, err = hex.DecodeString(pubKeyHex); err == nil {
+			w, err = wallet.NewWatchOnlyPublicKey(pubKey)
+		}
+	}
+	if err != nil {
+		log.Panicln("Unable to import address: ", err.Error())
+	}
+	cli.watch(w)
+}
+
+// importDescriptor adds a watch-only wallet for a descriptor to the wallets
+// file.
+func (cli *CLI) importDescriptor(descriptor string) {
+	d, err := wallet.ParseDescriptor(descriptor)
+	if err != nil {
+		log.Panicln("Unable to parse descriptor: ", err.Error())
+	}
+	w, err := d.Wallet()
+	if err != nil {
+		log.Panicln("Unable to import descriptor: ", err.Error())
+	}
+	cli.watch(w)
+}
+
+// listDescriptors prints the descriptor of each wallet in the wallets
+// file, ordered by address.
+func (cli *CLI) listDescriptors() {
+	wallets, err := walletStore().Wallets()
+	if err != nil {
+		log.Panicln("Unable to load wallets: ", err.Error())
+	}
+	addresses := make([]string, 0, len(wallets))
 	for address := range wallets {
-		fmt.Println(address)
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		fmt.Println(wallets[address].Descriptor())
 	}
 }
 
-// createWallet creates a new wallet.
-func (cli *CLI) createWallet() {
-	wallets, _ := wallet.CreateWallets()
+// watch adds a watch-only wallet to the wallets file, unless the wallets
+// file already has the private key of its address.
+func (cli *CLI) watch(w *wallet.Wallet) {
+	address := w.Address().String()
 
-	// make a new wallet and convert address to string
-	newWallet := wallet.CreateWallet()
-	address := fmt.Sprintf("%s", newWallet.Address())
+	// keep the private key of a wallet that is already in the file
+	store := walletStore()
+	if existing, err := store.Get(address); err == nil && !existing.WatchOnly() {
+		fmt.Printf("%s is already in the wallets file with its private key\n", address)
+		return
+	}
+	if err := store.Add(w); err != nil {
+		log.Panicln("Unable to save wallet: ", err.Error())
+	}
+	hooks.Notify(hooks.WalletCreated, map[string]string{"address": address, "watchOnly": "true"})
+
+	fmt.Printf("Watching %s\n", address)
+}
 
-	// add new wallet to wallets map
-	wallets[address] = newWallet
+// importKey adds the wallet for a private key in wallet import format to
+// the wallets file.
+func (cli *CLI) importKey(wif string) {
+	w, err := walletStore().ImportKey(wif)
+	if err != nil {
+		log.Panicln("Unable to import key: ", err.Error())
+	}
+	address := w.Address().String()
+	hooks.Notify(hooks.WalletCreated, map[string]string{"address": address})
 
-	// save wallets file
-	wallet.SaveWalletsFile(&wallets)
+	fmt.Printf("Imported key for %s\n", address)
+}
+
+// exportKey prints the private key of the wallet for an address in wallet
+// import format, so it can be imported elsewhere with importkey.
+func (cli *CLI) exportKey(address string) {
+	wif, err := walletStore().ExportKey(address)
+	if err != nil {
+		log.Panicln("Unable to export key: ", err.Error())
+	}
+	fmt.Println(wif)
+}
+
+// createWallet creates a new wallet in the wallets file called name, or
+// the selected wallets file if name is empty.
+func (cli *CLI) createWallet(name string) {
+	if name != "" {
+		if !walletNamePattern.MatchString(name) {
+			log.Panicf("Unable to create wallet: %q is not a name of letters, digits, - and _", name)
+		}
+		config.Wallet = name
+	}
+
+	// make a new wallet in the wallets file and convert address to string
+	newWallet, err := walletStore().Create()
+	if err != nil {
+		log.Panicln("Unable to save wallet: ", err.Error())
+	}
+	address := newWallet.Address().String()
+	hooks.Notify(hooks.WalletCreated, map[string]string{"address": address})
 
 	// print new wallet address
 	fmt.Printf("New address is: %s\n", address)
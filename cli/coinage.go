@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// coinAge prints the age distribution of the confirmed unspent outputs of
+// address, or of every address in the wallets file if it is empty, with
+// their value-weighted age.
+func (cli *CLI) coinAge(address string, asJSON bool) {
+	var addresses []string
+	if address != "" {
+		if !wallet.ValidateAddress(address) {
+			log.Panicln("Unable to report coin age: address not valid")
+		}
+		addresses = []string{address}
+	} else {
+		wallets, err := walletStore().Wallets()
+		if err != nil {
+			log.Panicln("Unable to load wallets: ", err.Error())
+		}
+		for address := range wallets {
+			addresses = append(addresses, address)
+		}
+	}
+	var pubKeyHashes [][]byte
+	for _, address := range addresses {
+		pubKeyHashes = append(pubKeyHashes, pubKeyHashFromAddress(address))
+	}
+
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	report, err := bc.AgeReport(pubKeyHashes)
+	if err != nil {
+		log.Panicln("Unable to report coin age: ", err.Error())
+	}
+
+	if asJSON {
+		type jsonOutput struct {
+			TxID   string       `json:"txid"`
+			Out    int          `json:"out"`
+			Value  units.Amount `json:"value"`
+			Height int          `json:"height"`
+			Age    int          `json:"age"`
+		}
+		type jsonBucket struct {
+			Min     int          `json:"min"`
+			Max     int          `json:"max,omitempty"`
+			Outputs int          `json:"outputs"`
+			Value   units.Amount `json:"value"`
+		}
+		outputs := []jsonOutput{}
+		for _, o := range report.Outputs {
+			outputs = append(outputs, jsonOutput{fmt.Sprintf("%x", o.TxID), o.Out, o.Output.Value, o.Height, o.Age})
+		}
+		var buckets []jsonBucket
+		for _, b := range report.Buckets {
+			buckets = append(buckets, jsonBucket{b.Min, b.Max, b.Outputs, b.Value})
+		}
+		printJSON(map[string]interface{}{
+			"value":       report.Value,
+			"coinAge":     report.CoinAge,
+			"weightedAge": report.WeightedAge(),
+			"buckets":     buckets,
+			"outputs":     outputs,
+		})
+		return
+	}
+
+	fmt.Printf("%d unspent outputs of %d addresses worth %s, value-weighted age %.1f blocks\n",
+		len(report.Outputs), len(addresses), units.FormatAmount(report.Value), report.WeightedAge())
+	for _, b := range report.Buckets {
+		ages := fmt.Sprintf("%d+", b.Min)
+		if b.Max > 0 {
+			ages = fmt.Sprintf("%d-%d", b.Min, b.Max-1)
+		}
+		fmt.Printf("\t%-12s blocks: %4d outputs, %s\n", ages, b.Outputs, units.FormatAmount(b.Value))
+	}
+	for _, o := range report.Outputs {
+		fmt.Printf("\t%x:%d %s at height %d, %d blocks old\n", o.TxID, o.Out, units.FormatAmount(o.Output.Value), o.Height, o.Age)
+	}
+}
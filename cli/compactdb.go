@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+)
+
+// compactDB garbage collects the value log of the database, rewriting the
+// files of which at least ratio is reclaimable, or DB_GC_RATIO of them if
+// ratio is 0, and prints the space reclaimed.
+func (cli *CLI) compactDB(ratio float64) {
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	report, err := bc.CompactDB(ratio)
+	if err != nil {
+		log.Panicln("Unable to compact database: ", err.Error())
+	}
+
+	if cli.jsonOutput {
+		printJSON(map[string]interface{}{
+			"rewrites": report.Rewrites,
+			"before":   report.Before,
+			"after":    report.After,
+		})
+		return
+	}
+	fmt.Printf("Rewrote %d value log files\n", report.Rewrites)
+	fmt.Printf("Database size: %d bytes, %d bytes before\n", report.After, report.Before)
+}
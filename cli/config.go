@@ -0,0 +1,508 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/edwintcloud/gochain/addresses"
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/hooks"
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/logging"
+	"github.com/edwintcloud/gochain/scripts"
+	"github.com/edwintcloud/gochain/wallet"
+	"github.com/joho/godotenv"
+)
+
+// logger receives the status messages of commands and the packages they
+// use. It is configured by loadConfig.
+var logger = logging.Default
+
+// Config is the configuration shared by every command that must have a
+// value, so commands run without a config file or env vars. Run builds it
+// from DefaultConfig overridden by the config file, then env vars, then the
+// options given before the command, and validates it before any command
+// runs. Other settings are read from optional env vars.
+type Config struct {
+	// Network is the network used unless -network is given.
+	Network string `toml:"network"`
+
+	// DBPath and WalletsFile are the paths of the database and wallets file
+	// of the main network. Other networks add a suffix to them.
+	DBPath      string `toml:"db_path"`
+	WalletsFile string `toml:"wallets_file"`
+
+	// ChecksumLength is the number of checksum bytes in addresses. Every
+	// address and key in wallet import format is encoded with it, so it
+	// must not change once wallets are created.
+	ChecksumLength int `toml:"checksum_length"`
+
+	// Wallet is the name of the wallets file used unless -wallet is given,
+	// the file at WalletsFile if it is empty or default.
+	Wallet string `toml:"wallet"`
+}
+
+// DefaultConfigFile is the config file read if there is one and no other
+// is given with -config or the CONFIG_FILE env var.
+const DefaultConfigFile = "gochain.toml"
+
+// config is the configuration commands use. It is set by Run.
+var config = DefaultConfig()
+
+// DefaultConfig returns the configuration used without env vars, keeping
+// the data of the main network in ./data.
+func DefaultConfig() Config {
+	return Config{
+		Network:        blockchain.MainNetParams.Name,
+		DBPath:         "./data/blocks",
+		WalletsFile:    "./data/wallets.data",
+		ChecksumLength: 4,
+	}
+}
+
+// ConfigFromEnv returns cfg with the values of the NETWORK, DB_PATH,
+// WALLETS_FILE, CHECKSUM_LENGTH and DEFAULT_WALLET env vars that are set
+// and not empty.
+func ConfigFromEnv(cfg Config) (Config, error) {
+	if value := os.Getenv("NETWORK"); value != "" {
+		cfg.Network = value
+	}
+	if value := os.Getenv("DB_PATH"); value != "" {
+		cfg.DBPath = value
+	}
+	if value := os.Getenv("WALLETS_FILE"); value != "" {
+		cfg.WalletsFile = value
+	}
+	if value := os.Getenv("CHECKSUM_LENGTH"); value != "" {
+		checksumLen, err := strconv.Atoi(value)
+		if err != nil || checksumLen <= 0 {
+			return cfg, fmt.Errorf("env var CHECKSUM_LENGTH %q is not a byte count", value)
+		}
+		cfg.ChecksumLength = checksumLen
+	}
+	if value := os.Getenv("DEFAULT_WALLET"); value != "" {
+		if !walletNamePattern.MatchString(value) {
+			return cfg, fmt.Errorf("env var DEFAULT_WALLET %q is not a name of letters, digits, - and _", value)
+		}
+		cfg.Wallet = value
+	}
+	return cfg, nil
+}
+
+// LoadConfigFile returns cfg with the values set in the TOML config file
+// at path, and whether the file exists. Keys the file sets that are not
+// settings of Config are an error, so misspelled settings aren't ignored.
+func LoadConfigFile(cfg Config, path string) (Config, bool, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return cfg, false, nil
+	}
+	meta, err := toml.DecodeFile(path, &cfg)
+	if err != nil {
+		return cfg, true, errors.New("unable to load config file " + path + " - " + err.Error())
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return cfg, true, fmt.Errorf("config file %s sets unknown setting %s", path, undecoded[0])
+	}
+	return cfg, true, nil
+}
+
+// Validate returns an error describing the first setting of cfg that is
+// not valid.
+func (cfg Config) Validate() error {
+	if _, err := blockchain.NetworkByName(cfg.Network); err != nil {
+		return err
+	}
+	if cfg.DBPath == "" {
+		return errors.New("database path is empty")
+	}
+	if cfg.WalletsFile == "" {
+		return errors.New("wallets file path is empty")
+	}
+	if cfg.ChecksumLength < 1 || cfg.ChecksumLength > sha256.Size {
+		return fmt.Errorf("checksum length %d is not between 1 and %d bytes", cfg.ChecksumLength, sha256.Size)
+	}
+	if cfg.Wallet != "" && !walletNamePattern.MatchString(cfg.Wallet) {
+		return fmt.Errorf("wallet %q is not a name of letters, digits, - and _", cfg.Wallet)
+	}
+	return nil
+}
+
+// configure returns the configuration of commands: the Config of cli, or
+// DefaultConfig if it is the zero Config, overridden by the config file
+// given with -config or the CONFIG_FILE env var, or DefaultConfigFile if
+// there is one, then by env vars, then by the options given before the
+// command.
+func (cli *CLI) configure(options globalOptions) (Config, error) {
+	cfg := cli.Config
+	if cfg == (Config{}) {
+		cfg = DefaultConfig()
+	}
+
+	// a config file that is given must exist
+	path, required := options.config, true
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		path, required = DefaultConfigFile, false
+	}
+	cfg, loaded, err := LoadConfigFile(cfg, path)
+	if err != nil {
+		return cfg, err
+	}
+	if required && !loaded {
+		return cfg, fmt.Errorf("config file %s does not exist", path)
+	}
+
+	if cfg, err = ConfigFromEnv(cfg); err != nil {
+		return cfg, err
+	}
+	if options.network != "" {
+		cfg.Network = options.network
+	}
+	if options.wallet != "" {
+		cfg.Wallet = options.wallet
+	}
+	return cfg, cfg.Validate()
+}
+
+// LoadEnvFile sets the env vars in the .env file at path that are not set
+// already, returning whether the file exists. Configuring commands with a
+// .env file is deprecated in favor of env vars and the defaults.
+func LoadEnvFile(path string) (bool, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return false, nil
+	}
+	if err := godotenv.Load(path); err != nil {
+		return true, errors.New("unable to load " + path + " - " + err.Error())
+	}
+	return true, nil
+}
+
+// loadConfig applies cfg and the configuration in env vars that is shared
+// by every command. The blockchain and wallet packages don't read env vars
+// so they can be used as libraries.
+func loadConfig(cfg Config) {
+	loadLogger()
+
+	config = cfg
+	addresses.ChecksumLength = cfg.ChecksumLength
+
+	// send events to the plugins and scripts in the PLUGINS and SCRIPTS
+	// env vars
+	hooks.RegisterFromEnv()
+	scripts.RegisterFromEnv()
+}
+
+// loadLogger sets the logger of commands and the packages they use from the
+// LOG_LEVEL (debug, info, warn or error, info by default) and LOG_FORMAT
+// (text or json, text by default) env vars. The messages of the standard
+// log package, which commands use to report failures, are logged as
+// errors.
+func loadLogger() {
+	level := logging.InfoLevel
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		parsed, err := logging.ParseLevel(value)
+		if err != nil {
+			log.Panicln("Unable to parse env var LOG_LEVEL: ", err.Error())
+		}
+		level = parsed
+	}
+
+	format := logging.Text
+	if value := os.Getenv("LOG_FORMAT"); value != "" {
+		parsed, err := logging.ParseFormat(value)
+		if err != nil {
+			log.Panicln("Unable to parse env var LOG_FORMAT: ", err.Error())
+		}
+		format = parsed
+	}
+
+	logger = logging.New(os.Stderr, level, format)
+	log.SetFlags(0)
+	log.SetOutput(logging.Writer(logger, logging.ErrorLevel))
+	hooks.SetLogger(logger)
+	scripts.SetLogger(logger)
+	events.SetLogger(logger)
+}
+
+// blockChainConfig returns the blockchain configuration of the network from
+// the DB_PATH, GENESIS_FILE, PRUNE_DEPTH, MIDSTATE_MINING, SPENT_INDEX,
+// CHECKPOINTS, FINALITY_DEPTH, DB_GC_INTERVAL, DB_GC_RATIO, DB_TRUNCATE,
+// DB_SYNC_WRITES, JOURNAL, REORG_LOG, MEMPOOL_MAX_COUNT, MEMPOOL_MAX_BYTES,
+// MEMPOOL_MAX_AGE, MEMPOOL_EVICT, MEMPOOL_MIN_FEE_RATE and UTXO_CACHE_SIZE
+// env vars. A new chain pays its genesis reward
+// to genesisAddress unless a genesis file is given.
+func blockChainConfig(genesisAddress string) blockchain.Config {
+	genesis, err := blockchain.LoadGenesis(os.Getenv("GENESIS_FILE"))
+	if err != nil {
+		log.Panicf("Unable to load genesis configuration: %s", err.Error())
+	}
+
+	// keep every block unless a prune depth is given
+	pruneDepth := 0
+	if value := os.Getenv("PRUNE_DEPTH"); value != "" {
+		pruneDepth, err = strconv.Atoi(value)
+		if err != nil || pruneDepth < 0 {
+			log.Panicf("Unable to convert env var PRUNE_DEPTH to a block count: %s", value)
+		}
+	}
+
+	// mine with the saved SHA-256 midstate if asked to
+	midstate := false
+	if value := os.Getenv("MIDSTATE_MINING"); value != "" {
+		midstate, err = strconv.ParseBool(value)
+		if err != nil {
+			log.Panicf("Unable to convert env var MIDSTATE_MINING to a bool: %s", value)
+		}
+	}
+
+	// keep the spent index for explorers if asked to
+	spentIndex := false
+	if value := os.Getenv("SPENT_INDEX"); value != "" {
+		spentIndex, err = strconv.ParseBool(value)
+		if err != nil {
+			log.Panicf("Unable to convert env var SPENT_INDEX to a bool: %s", value)
+		}
+	}
+
+	// checkpoints are HEIGHT:HASH pairs separated by commas
+	checkpoints, err := blockchain.ParseCheckpoints(os.Getenv("CHECKPOINTS"))
+	if err != nil {
+		log.Panicf("Unable to parse env var CHECKPOINTS: %s", err.Error())
+	}
+
+	// make blocks final after a number of confirmations if asked to
+	finalityDepth := 0
+	if value := os.Getenv("FINALITY_DEPTH"); value != "" {
+		finalityDepth, err = strconv.Atoi(value)
+		if err != nil || finalityDepth < 0 {
+			log.Panicf("Unable to convert env var FINALITY_DEPTH to a block count: %s", value)
+		}
+	}
+
+	// garbage collect the value log of long-running commands if asked to
+	var gcInterval time.Duration
+	if value := os.Getenv("DB_GC_INTERVAL"); value != "" {
+		gcInterval, err = time.ParseDuration(value)
+		if err != nil || gcInterval < 0 {
+			log.Panicf("Unable to convert env var DB_GC_INTERVAL to a duration: %s", value)
+		}
+	}
+	gcRatio := 0.0
+	if value := os.Getenv("DB_GC_RATIO"); value != "" {
+		gcRatio, err = strconv.ParseFloat(value, 64)
+		if err != nil || gcRatio <= 0 || gcRatio >= 1 {
+			log.Panicf("Unable to convert env var DB_GC_RATIO to a ratio between 0 and 1: %s", value)
+		}
+	}
+
+	// truncate a value log left partially written by a crash if asked to,
+	// and sync writes to disk unless asked not to
+	truncate := false
+	if value := os.Getenv("DB_TRUNCATE"); value != "" {
+		truncate, err = strconv.ParseBool(value)
+		if err != nil {
+			log.Panicf("Unable to convert env var DB_TRUNCATE to a bool: %s", value)
+		}
+	}
+	syncWrites := true
+	if value := os.Getenv("DB_SYNC_WRITES"); value != "" {
+		syncWrites, err = strconv.ParseBool(value)
+		if err != nil {
+			log.Panicf("Unable to convert env var DB_SYNC_WRITES to a bool: %s", value)
+		}
+	}
+
+	// journal the state transitions of the chain next to the database if
+	// asked to
+	journalPath := ""
+	if value := os.Getenv("JOURNAL"); value != "" {
+		journal, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Panicf("Unable to convert env var JOURNAL to a bool: %s", value)
+		}
+		if journal {
+			journalPath = dbPath() + ".journal"
+		}
+	}
+
+	// bound the mempool if asked to
+	mempool := blockchain.MempoolLimits{Evict: os.Getenv("MEMPOOL_EVICT")}
+	if value := os.Getenv("MEMPOOL_MAX_COUNT"); value != "" {
+		mempool.MaxCount, err = strconv.Atoi(value)
+		if err != nil || mempool.MaxCount < 0 {
+			log.Panicf("Unable to convert env var MEMPOOL_MAX_COUNT to a transaction count: %s", value)
+		}
+	}
+	if value := os.Getenv("MEMPOOL_MAX_BYTES"); value != "" {
+		mempool.MaxBytes, err = strconv.Atoi(value)
+		if err != nil || mempool.MaxBytes < 0 {
+			log.Panicf("Unable to convert env var MEMPOOL_MAX_BYTES to a byte count: %s", value)
+		}
+	}
+	if value := os.Getenv("MEMPOOL_MAX_AGE"); value != "" {
+		mempool.MaxAge, err = time.ParseDuration(value)
+		if err != nil || mempool.MaxAge < 0 {
+			log.Panicf("Unable to convert env var MEMPOOL_MAX_AGE to a duration: %s", value)
+		}
+	}
+	if value := os.Getenv("MEMPOOL_MIN_FEE_RATE"); value != "" {
+		mempool.MinFeeRate, err = strconv.ParseFloat(value, 64)
+		if err != nil || mempool.MinFeeRate < 0 {
+			log.Panicf("Unable to convert env var MEMPOOL_MIN_FEE_RATE to a fee rate: %s", value)
+		}
+	}
+
+	// cache unspent outputs unless asked not to with a size of 0
+	utxoCacheSize := blockchain.DefaultUTXOCacheSize
+	if value := os.Getenv("UTXO_CACHE_SIZE"); value != "" {
+		utxoCacheSize, err = strconv.Atoi(value)
+		if err != nil || utxoCacheSize < 0 {
+			log.Panicf("Unable to convert env var UTXO_CACHE_SIZE to an output count: %s", value)
+		}
+	}
+
+	// seal the blocks of a proof of authority network with the wallet of
+	// an authority if asked to
+	var authority keys.Key
+	if address := os.Getenv("AUTHORITY_ADDRESS"); address != "" {
+		w, err := walletStore().Get(address)
+		if err != nil {
+			log.Panicf("Unable to load the wallet of env var AUTHORITY_ADDRESS: %s", err.Error())
+		}
+		authority = w
+	}
+
+	return blockchain.Config{
+		Path:           dbPath(),
+		Genesis:        genesis,
+		GenesisAddress: genesisAddress,
+		Network:        network,
+		PruneDepth:     pruneDepth,
+		MiningProgress: printMiningProgress,
+		MidstateMining: midstate,
+		SpentIndex:     spentIndex,
+		Logger:         logger,
+		Checkpoints:    checkpoints,
+		FinalityDepth:  finalityDepth,
+
+		GCInterval:       gcInterval,
+		GCDiscardRatio:   gcRatio,
+		TruncateValueLog: truncate,
+		NoSyncWrites:     !syncWrites,
+		JournalPath:      journalPath,
+		ReorgLogPath:     os.Getenv("REORG_LOG"),
+		Mempool:          mempool,
+		UTXOCacheSize:    utxoCacheSize,
+		Authority:        authority,
+
+		MigrationProgress: printMigrationProgress,
+	}
+}
+
+// printMiningProgress prints the hash rate of blocks being mined to stderr
+// so it doesn't mix with the output of commands.
+func printMiningProgress(hashes uint64, elapsed time.Duration, done bool) {
+	rate := float64(hashes) / elapsed.Seconds()
+	if done {
+		fmt.Fprintf(os.Stderr, "\rMined block in %s at %.0f hashes/s\n", elapsed.Round(time.Millisecond), rate)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\rMining at %.0f hashes/s", rate)
+}
+
+// printMigrationProgress prints the progress of the migrations of a
+// database written by an earlier version to stderr.
+func printMigrationProgress(version int, name string, progress blockchain.JobProgress) {
+	fmt.Fprintf(os.Stderr, "\rMigrating database to version %d (%s): %d/%d", version, name, progress.Done, progress.Total)
+	if progress.Done == progress.Total {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// openBlockChain opens the blockchain configured by env vars, creating it
+// with a genesis reward to genesisAddress if it does not exist.
+func openBlockChain(genesisAddress string) *blockchain.BlockChain {
+	bc, err := blockchain.Open(blockChainConfig(genesisAddress))
+	if err != nil {
+		log.Panicf("Unable to open blockchain: %s", err.Error())
+	}
+	return bc
+}
+
+// queryBlockChain opens the blockchain like openBlockChain for commands that
+// only read it, read-only so several can run at once.
+func queryBlockChain(genesisAddress string) *blockchain.BlockChain {
+	bc, err := openReadOnly(genesisAddress)
+	if err == blockchain.ErrLocked {
+		log.Panicln("Unable to open blockchain: the database is in use by another process, such as a node or miner, query it through its RPC server or stop it first")
+	} else if err != nil {
+		log.Panicf("Unable to open blockchain: %s", err.Error())
+	}
+	return bc
+}
+
+// openReadOnly opens the blockchain read-only, falling back to opening it
+// writable when it must be created or brought up to date first, which
+// only happens once.
+func openReadOnly(genesisAddress string) (*blockchain.BlockChain, error) {
+	cfg := blockChainConfig(genesisAddress)
+	cfg.ReadOnly = true
+	bc, err := blockchain.Open(cfg)
+	if err == blockchain.ErrNoBlockChain || err == blockchain.ErrNeedsWrite {
+		cfg.ReadOnly = false
+		return blockchain.Open(cfg)
+	}
+	return bc, err
+}
+
+// sharedStore is the wallets file of the network shared by the commands and the
+// RPC server of the process, opened by walletStore.
+var (
+	sharedStore     *wallet.Store
+	sharedStoreOnce sync.Once
+)
+
+// walletStore returns the wallets file of the network, with the key kept
+// by walletunlock if the file is encrypted, warning if other users can
+// read it. The store is opened once and shared.
+func walletStore() *wallet.Store {
+	sharedStoreOnce.Do(func() {
+		sharedStore = openWalletStore(walletsPath())
+		if issue := walletPermissionIssue(walletsPath()); issue != "" {
+			logger.Warn("Wallets file is not private", "issue", issue)
+		}
+	})
+	return sharedStore
+}
+
+// openWalletStore returns the wallets file at path, with the key kept by
+// walletunlock if the file is encrypted, keeping the number of backups in
+// the WALLETS_BACKUPS env var.
+func openWalletStore(path string) *wallet.Store {
+	store := wallet.NewStore(path)
+
+	// keep the default number of backups unless another is given
+	if value := os.Getenv("WALLETS_BACKUPS"); value != "" {
+		backups, err := strconv.Atoi(value)
+		if err != nil || backups < 0 {
+			log.Panicf("Unable to convert env var WALLETS_BACKUPS to a file count: %s", value)
+		}
+		store.SetBackups(backups)
+	}
+	if encrypted, err := store.Encrypted(); err == nil && encrypted {
+		if key, err := agentKey(walletsAgentPath(path)); err == nil {
+			store.SetKey(key)
+		}
+	}
+	return store
+}
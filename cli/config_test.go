@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	for _, name := range []string{"NETWORK", "DB_PATH", "WALLETS_FILE", "CHECKSUM_LENGTH", "DEFAULT_WALLET"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+
+	// without env vars the defaults are kept
+	cfg, err := ConfigFromEnv(DefaultConfig())
+	if err != nil || cfg != DefaultConfig() {
+		t.Fatalf("got %+v, %v, want the defaults", cfg, err)
+	}
+
+	os.Setenv("NETWORK", "regtest")
+	os.Setenv("DB_PATH", "/tmp/blocks")
+	os.Setenv("CHECKSUM_LENGTH", "")
+	cfg, err = ConfigFromEnv(DefaultConfig())
+	want := DefaultConfig()
+	want.Network, want.DBPath = "regtest", "/tmp/blocks"
+	if err != nil || cfg != want {
+		t.Fatalf("got %+v, %v, want %+v", cfg, err, want)
+	}
+
+	os.Setenv("DEFAULT_WALLET", "savings")
+	cfg, err = ConfigFromEnv(DefaultConfig())
+	want.Wallet = "savings"
+	if err != nil || cfg != want {
+		t.Fatalf("got %+v, %v, want %+v", cfg, err, want)
+	}
+	os.Setenv("DEFAULT_WALLET", "../savings")
+	if _, err := ConfigFromEnv(DefaultConfig()); err == nil {
+		t.Fatal("got no error for a DEFAULT_WALLET that is not a name")
+	}
+	os.Unsetenv("DEFAULT_WALLET")
+
+	os.Setenv("CHECKSUM_LENGTH", "four")
+	if _, err := ConfigFromEnv(DefaultConfig()); err == nil {
+		t.Fatal("got no error for a bad CHECKSUM_LENGTH")
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	defer os.Setenv("WALLETS_FILE", os.Getenv("WALLETS_FILE"))
+	defer os.Setenv("DB_PATH", os.Getenv("DB_PATH"))
+	os.Unsetenv("WALLETS_FILE")
+	os.Setenv("DB_PATH", "set")
+
+	path := filepath.Join(t.TempDir(), ".env")
+	if loaded, err := LoadEnvFile(path); loaded || err != nil {
+		t.Fatalf("got %v, %v for a missing file, want false, nil", loaded, err)
+	}
+
+	// variables already set are not overridden
+	if err := ioutil.WriteFile(path, []byte("WALLETS_FILE=wallets\nDB_PATH=file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := LoadEnvFile(path); !loaded || err != nil {
+		t.Fatalf("got %v, %v, want the file loaded", loaded, err)
+	}
+	if os.Getenv("WALLETS_FILE") != "wallets" || os.Getenv("DB_PATH") != "set" {
+		t.Fatalf("got WALLETS_FILE=%s DB_PATH=%s", os.Getenv("WALLETS_FILE"), os.Getenv("DB_PATH"))
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	for _, name := range []string{"CONFIG_FILE", "NETWORK", "DB_PATH", "WALLETS_FILE", "CHECKSUM_LENGTH", "DEFAULT_WALLET"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+	path := filepath.Join(t.TempDir(), "gochain.toml")
+	file := "network = \"test\"\ndb_path = \"/file/blocks\"\nwallets_file = \"/file/wallets\"\nwallet = \"file\"\n"
+	if err := ioutil.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the config file overrides the defaults, env vars override the file
+	// and options override both
+	os.Setenv("DB_PATH", "/env/blocks")
+	os.Setenv("DEFAULT_WALLET", "env")
+	cli := &CLI{}
+	cfg, err := cli.configure(globalOptions{config: path, wallet: "option"})
+	want := Config{Network: "test", DBPath: "/env/blocks", WalletsFile: "/file/wallets", ChecksumLength: 4, Wallet: "option"}
+	if err != nil || cfg != want {
+		t.Fatalf("got %+v, %v, want %+v", cfg, err, want)
+	}
+	os.Setenv("CONFIG_FILE", path)
+	if cfg, err := cli.configure(globalOptions{network: "regtest"}); err != nil || cfg.Network != "regtest" || cfg.WalletsFile != "/file/wallets" {
+		t.Fatalf("got %+v, %v with CONFIG_FILE set", cfg, err)
+	}
+	os.Unsetenv("CONFIG_FILE")
+	os.Unsetenv("DB_PATH")
+	os.Unsetenv("DEFAULT_WALLET")
+
+	for _, c := range []struct {
+		name    string
+		file    string
+		options globalOptions
+		want    string
+	}{
+		{"missing config file", "", globalOptions{config: path + ".missing"}, "does not exist"},
+		{"unknown setting", "db_pth = \"x\"\n", globalOptions{}, "unknown setting db_pth"},
+		{"bad syntax", "network = \n", globalOptions{}, "unable to load config file"},
+		{"unknown network", "", globalOptions{network: "moon"}, "not main, test or regtest"},
+		{"zero checksum length", "checksum_length = 0\n", globalOptions{}, "checksum length 0"},
+		{"long checksum length", "checksum_length = 33\n", globalOptions{}, "checksum length 33"},
+		{"empty wallets file", "wallets_file = \"\"\n", globalOptions{}, "wallets file path is empty"},
+		{"wallet not a name", "wallet = \"../hot\"\n", globalOptions{}, "not a name"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(path, []byte(c.file), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if c.options.config == "" {
+				c.options.config = path
+			}
+			if _, err := cli.configure(c.options); err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("got error %v, want %q", err, c.want)
+			}
+		})
+	}
+}
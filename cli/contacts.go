@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// addressFlags are the flags of commands taking an address, which also
+// take the label of a wallet or the name of a contact. -to may be
+// ADDRESS:AMOUNT.
+var addressFlags = map[string]bool{"address": true, "from": true, "to": true, "mine": true}
+
+// resolveAddressArgs replaces the labels and contact names given to the
+// address flags of a command in args with the addresses resolve returns for
+// them. Values that are addresses or name nothing are left for the command
+// to check, so resolve is only called once a name is given.
+func resolveAddressArgs(args []string, resolve func(name string) (string, error)) []string {
+	resolved := append([]string{}, args...)
+	for i := 2; i < len(resolved); i++ {
+		option := strings.TrimPrefix(strings.TrimPrefix(resolved[i], "-"), "-")
+		if option == resolved[i] {
+			continue
+		}
+
+		// the value follows the flag, or is given with it after =
+		name, value, inline := option, "", false
+		if eq := strings.Index(option, "="); eq >= 0 {
+			name, value, inline = option[:eq], option[eq+1:], true
+		} else if i+1 < len(resolved) {
+			value = resolved[i+1]
+		}
+		if !addressFlags[name] || value == "" {
+			continue
+		}
+		value = resolveAddress(value, resolve)
+		if inline {
+			resolved[i] = resolved[i][:len(resolved[i])-len(option)] + name + "=" + value
+		} else {
+			resolved[i+1] = value
+			i++
+		}
+	}
+	return resolved
+}
+
+// resolveAddress returns value with its address part, which may be
+// followed by :AMOUNT, resolved, or unchanged if it doesn't name a wallet
+// or contact.
+func resolveAddress(value string, resolve func(name string) (string, error)) string {
+	name, amount := value, ""
+	if colon := strings.Index(value, ":"); colon >= 0 {
+		name, amount = value[:colon], value[colon:]
+	}
+	if !wallet.ValidName(name) {
+		return value
+	}
+	address, err := resolve(name)
+	if err != nil {
+		return value
+	}
+	return address + amount
+}
+
+// addContact saves the address of a contact called name in the wallets
+// file, or removes the contact if remove is set.
+func (cli *CLI) addContact(name, address string, remove bool) {
+	if remove {
+		if err := walletStore().RemoveContact(name); err != nil {
+			log.Panicln("Unable to remove contact: ", err.Error())
+		}
+		fmt.Printf("Removed contact %s\n", name)
+		return
+	}
+	if err := walletStore().AddContact(wallet.Contact{Name: name, Address: address}); err != nil {
+		log.Panicln("Unable to add contact: ", err.Error())
+	}
+	fmt.Printf("Saved %s as %s\n", address, name)
+}
+
+// listContacts prints the contacts in the wallets file.
+func (cli *CLI) listContacts(asJSON bool) {
+	contacts, err := walletStore().Contacts()
+	if err != nil {
+		log.Panicln("Unable to load contacts: ", err.Error())
+	}
+	if asJSON {
+		printJSON(contacts)
+		return
+	}
+	for _, c := range contacts {
+		fmt.Printf("%s\t%s\n", c.Name, c.Address)
+	}
+}
+
+// setLabel labels the wallet for address, or removes its label if label
+// is empty.
+func (cli *CLI) setLabel(address, label string) {
+	if err := walletStore().SetLabel(address, label); err != nil {
+		log.Panicln("Unable to label wallet: ", err.Error())
+	}
+	if label == "" {
+		fmt.Printf("Removed the label of %s\n", address)
+		return
+	}
+	fmt.Printf("Labeled %s as %s\n", address, label)
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestResolveAddressArgs(t *testing.T) {
+	store := wallet.NewStore(filepath.Join(t.TempDir(), "wallets.data"))
+	w, err := store.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	own := w.Address().String()
+	if err := store.SetLabel(own, "savings"); err != nil {
+		t.Fatal(err)
+	}
+	bob := wallet.CreateWallet().Address().String()
+	if err := store.AddContact(wallet.Contact{Name: "bob", Address: bob}); err != nil {
+		t.Fatal(err)
+	}
+
+	// labels and contact names are resolved, other values are left alone
+	args := []string{"gochain", "send", "-from", "savings", "--to=bob:1.5", "-amount", "3", "-mine", "-address", "carol"}
+	got := resolveAddressArgs(args, store.Resolve)
+	want := []string{"gochain", "send", "-from", own, "--to=" + bob + ":1.5", "-amount", "3", "-mine", "-address", "carol"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got args %q, want %q", got, want)
+	}
+	if args[3] != "savings" {
+		t.Fatal("resolving changed the args given")
+	}
+}
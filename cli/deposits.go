@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// depositTimeout is how long a deposit watch URL has to take an event.
+const depositTimeout = 10 * time.Second
+
+// watchDeposits adds a deposit watch notifying url once deposits to address
+// have confirmations confirmations, or removes it if remove is set. The
+// events are posted by serve. Without an address, the watches are listed.
+func (cli *CLI) watchDeposits(address, url string, confirmations int, remove, asJSON bool) {
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	// list the watches
+	if address == "" {
+		watches, err := bc.DepositWatches()
+		if err != nil {
+			log.Panicln("Unable to list deposit watches: ", err.Error())
+		}
+		if asJSON {
+			printJSON(watches)
+			return
+		}
+		for _, w := range watches {
+			fmt.Printf("%s at %d confirmations to %s\n", w.Address, w.Confirmations, w.URL)
+		}
+		return
+	}
+
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to watch deposits: address not valid")
+	}
+	if remove {
+		found, err := bc.UnwatchDeposits(address, url)
+		if err != nil {
+			log.Panicln("Unable to unwatch deposits: ", err.Error())
+		}
+		if !found {
+			log.Panicln("Unable to unwatch deposits: no watch of", url, "on", address)
+		}
+		fmt.Printf("Stopped watching deposits to %s for %s\n", address, url)
+		return
+	}
+
+	err := bc.WatchDeposits(blockchain.DepositWatch{Address: address, Confirmations: confirmations, URL: url})
+	if err != nil {
+		log.Panicln("Unable to watch deposits: ", err.Error())
+	}
+	fmt.Printf("Watching deposits to %s at %d confirmations for %s\n", address, confirmations, url)
+}
+
+// deliverDepositsEvery delivers the pending deposit events every interval
+// until ctx is done, signed with secret unless it is empty.
+func deliverDepositsEvery(ctx context.Context, bc *blockchain.BlockChain, interval time.Duration, secret []byte) {
+	client := &http.Client{Timeout: depositTimeout}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deliverDeposits(ctx, bc, client, secret)
+		}
+	}
+}
+
+// deliverDeposits posts each pending deposit event as JSON to the URL of
+// its watch, signed with secret unless it is empty, acknowledging the
+// events taken with a 2xx status. Events a URL doesn't take are retried on
+// the next call, and later events of the same watch wait for them so they
+// arrive in order.
+func deliverDeposits(ctx context.Context, bc *blockchain.BlockChain, client *http.Client, secret []byte) {
+	events, err := bc.PendingDepositEvents()
+	if err != nil {
+		logger.Error("Unable to find deposit events", "err", err)
+		return
+	}
+
+	failed := make(map[string]bool)
+	for _, e := range events {
+		watch := e.Address + " " + e.URL
+		if failed[watch] || ctx.Err() != nil {
+			continue
+		}
+		if err := postDepositEvent(ctx, client, e, secret); err != nil {
+			logger.Warn("Unable to deliver deposit event", "url", e.URL, "txid", e.TxID, "type", e.Type, "err", err)
+			failed[watch] = true
+			continue
+		}
+		if err := bc.AckDepositEvent(e); err != nil {
+			logger.Error("Unable to acknowledge deposit event", "txid", e.TxID, "err", err)
+			failed[watch] = true
+		}
+	}
+}
+
+// postDepositEvent posts a deposit event to the URL of its watch, signed
+// with secret unless it is empty.
+func postDepositEvent(ctx context.Context, client *http.Client, e blockchain.DepositEvent, secret []byte) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return events.Post(ctx, client, e.URL, secret, body)
+}
+
+// handleDepositWatches lists the deposit watches of the node on GET, adds
+// the DepositWatch posted as JSON on POST, and removes the watch of the url
+// query parameter on the address parameter on DELETE.
+func (n *node) handleDepositWatches(w http.ResponseWriter, r *http.Request) {
+	if !n.authorized(w, r, scopeWallet) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		watches, err := n.bc.DepositWatches()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, watches)
+	case http.MethodPost:
+		var watch blockchain.DepositWatch
+		if err := json.NewDecoder(r.Body).Decode(&watch); err != nil {
+			http.Error(w, "invalid deposit watch - "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := n.bc.WatchDeposits(watch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, watch)
+	case http.MethodDelete:
+		query := r.URL.Query()
+		found, err := n.bc.UnwatchDeposits(query.Get("address"), query.Get("url"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !found {
+			http.Error(w, "no such deposit watch", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]bool{"removed": true})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
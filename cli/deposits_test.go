@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestDeliverDeposits(t *testing.T) {
+	address := wallet.CreateWallet().Address().String()
+	bc, err := blockchain.InitInMemory(&blockchain.Genesis{
+		Network:     "deposits",
+		Allocations: map[string]units.Amount{address: 5 * units.Coin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	// the URL fails the first post
+	var received []blockchain.DepositEvent
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(events.SignatureHeader) != events.Sign([]byte("secret"), body) {
+			t.Error("got a post without the signature of its body")
+		}
+		var e blockchain.DepositEvent
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Error(err)
+		}
+		received = append(received, e)
+		rw.WriteHeader(status)
+		status = http.StatusOK
+	}))
+	defer server.Close()
+
+	err = bc.WatchDeposits(blockchain.DepositWatch{Address: address, Confirmations: 1, URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the event is retried until the URL takes it, then not sent again
+	for i := 0; i < 3; i++ {
+		deliverDeposits(context.Background(), bc, server.Client(), []byte("secret"))
+	}
+	if len(received) != 2 {
+		t.Fatalf("got %d posts, want the failed one and its retry", len(received))
+	}
+	if e := received[1]; e.Type != blockchain.DepositConfirmed || e.Address != address || e.Value != 5*units.Coin {
+		t.Fatalf("got event %+v, want the allocation confirmed", e)
+	}
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// dumpUTXOSet writes the UTXO set of the best chain after the block at
+// height, or at the tip if height is negative, to the file at path, and
+// prints its commitment and supply.
+func (cli *CLI) dumpUTXOSet(height int, path string) {
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	if height < 0 {
+		height = bc.Height()
+	}
+	set, err := bc.UTXOSetAt(height)
+	if err != nil {
+		log.Panicln("Unable to read UTXO set: ", err.Error())
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		log.Panicln("Unable to create UTXO set file: ", err.Error())
+	}
+	defer file.Close()
+	if _, err := set.WriteTo(file); err != nil {
+		log.Panicln("Unable to write UTXO set: ", err.Error())
+	}
+	if err := file.Close(); err != nil {
+		log.Panicln("Unable to write UTXO set: ", err.Error())
+	}
+
+	fmt.Printf("Wrote %d unspent outputs at height %d (block %s) to %s\n", len(set.Outputs), set.Height, set.BlockHash, path)
+	fmt.Printf("\tSupply:     %s\n", units.FormatAmount(set.Supply()))
+	fmt.Printf("\tCommitment: %s\n", set.Commitment())
+}
+
+// readUTXOSet reads a UTXO set written by dumpUTXOSet from the file at
+// path, verifying it matches its commitment.
+func readUTXOSet(path string) *blockchain.UTXOSet {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Panicln("Unable to open UTXO set file: ", err.Error())
+	}
+	defer file.Close()
+	set, err := blockchain.ReadUTXOSet(file)
+	if err != nil {
+		log.Panicln("Unable to read UTXO set: ", err.Error())
+	}
+	return set
+}
+
+// getTxOutSetInfo prints the number, value and commitment of the unspent
+// outputs at the tip, and whether their value exceeds the subsidy
+// schedule, failing if it does.
+func (cli *CLI) getTxOutSetInfo(asJSON bool) {
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	info, err := bc.UTXOSetInfo()
+	if err != nil {
+		log.Panicln("Unable to read UTXO set: ", err.Error())
+	}
+	if asJSON {
+		printJSON(info)
+	} else {
+		fmt.Printf("Height:     %d (block %s)\n", info.Height, info.BlockHash)
+		fmt.Printf("Outputs:    %d\n", info.Outputs)
+		fmt.Printf("Supply:     %s\n", units.FormatAmount(info.Supply))
+		fmt.Printf("Max supply: %s\n", units.FormatAmount(info.MaxSupply))
+		fmt.Printf("Commitment: %s\n", info.Commitment)
+	}
+	if info.Inflated {
+		log.Panicf("Supply exceeds the subsidy schedule by %s", units.FormatAmount(info.Supply-info.MaxSupply))
+	}
+}
+
+// handleTxOutSetInfo serves the number, value and commitment of the unspent
+// outputs at the tip, and whether their value exceeds the subsidy schedule.
+func (n *node) handleTxOutSetInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	info, err := n.bc.UTXOSetInfo()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, info)
+}
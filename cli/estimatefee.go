@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// paymentSize is about the size in bytes of a payment spending one output
+// with change, the transaction estimatefee prices by default.
+const paymentSize = 530
+
+// estimateFee prints the fee rate suggested for a transaction to be mined
+// within blocks blocks, and the fee of a transaction of size bytes.
+func (cli *CLI) estimateFee(blocks, size int, asJSON bool) {
+	if size < 1 {
+		log.Panicln("Unable to estimate fee: size must be positive")
+	}
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	estimate, err := bc.EstimateFee(blocks)
+	if err != nil {
+		log.Panicln("Unable to estimate fee: ", err.Error())
+	}
+	if asJSON {
+		printJSON(map[string]interface{}{
+			"estimate": estimate,
+			"size":     size,
+			"fee":      estimate.Fee(size),
+		})
+		return
+	}
+
+	fmt.Printf("Fee rate:   %.2f/byte within %d blocks\n", estimate.FeeRate, estimate.TargetBlocks)
+	fmt.Printf("Fee:        %s for %d bytes\n", units.FormatAmount(estimate.Fee(size)), size)
+	fmt.Printf("Recent:     %.2f/byte median of %d transactions in %d blocks\n", estimate.RecentRate, estimate.Samples, estimate.Blocks)
+	fmt.Printf("Congestion: %.2f/byte with %d bytes pending\n", estimate.CongestionRate, estimate.MempoolSize)
+}
@@ -0,0 +1,26 @@
+package cli
+
+import "fmt"
+
+// feeHistogram prints the number and size of the pending transactions in
+// each fee rate band, skipping empty bands.
+func (cli *CLI) feeHistogram(asJSON bool) {
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	bands := bc.FeeHistogram()
+	if asJSON {
+		printJSON(bands)
+		return
+	}
+
+	total := 0
+	for _, band := range bands {
+		if band.Transactions == 0 {
+			continue
+		}
+		fmt.Printf(">= %-8g/byte %6d transactions %10d bytes\n", band.MinRate, band.Transactions, band.Bytes)
+		total += band.Transactions
+	}
+	fmt.Printf("%d pending transactions\n", total)
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/blockchain"
+)
+
+// forks prints the tip of the best chain and the tips of the side chains
+// competing with it, with their height and cumulative work, followed by the
+// latest reorgs reorganizations of the best chain.
+func (cli *CLI) forks(reorgs int, asJSON bool) {
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	tips, err := bc.ChainTips()
+	if err != nil {
+		log.Panicln("Unable to list chain tips: ", err.Error())
+	}
+	records := []blockchain.ReorgRecord{}
+	if reorgs > 0 {
+		if records, err = bc.Reorgs(reorgs); err != nil {
+			log.Panicln("Unable to list reorgs: ", err.Error())
+		}
+	}
+
+	if asJSON {
+		list := make([]map[string]interface{}, 0, len(tips))
+		for _, tip := range tips {
+			list = append(list, map[string]interface{}{
+				"hash":         hex.EncodeToString(tip.Hash),
+				"height":       tip.Height,
+				"work":         tip.Work.String(),
+				"forkHeight":   tip.ForkHeight,
+				"branchLength": tip.BranchLength,
+				"active":       tip.Active,
+			})
+		}
+		result := map[string]interface{}{"tips": list}
+		if reorgs > 0 {
+			result["reorgs"] = records
+		}
+		printJSON(result)
+		return
+	}
+
+	for _, tip := range tips {
+		if tip.Active {
+			fmt.Printf("active  height %d, work %s, %x\n", tip.Height, tip.Work, tip.Hash)
+			continue
+		}
+		fmt.Printf("fork    height %d, work %s, %d blocks above height %d, %x\n", tip.Height, tip.Work, tip.BranchLength, tip.ForkHeight, tip.Hash)
+	}
+	for _, r := range records {
+		fmt.Printf("reorg %d at %s: %s at height %d replaced by %s at height %d, forking at height %d, %d blocks deep\n",
+			r.Seq, blockchain.FormatTimestamp(r.Time), r.OldTip, r.OldHeight, r.NewTip, r.NewHeight, r.ForkHeight, r.Depth)
+	}
+}
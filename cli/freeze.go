@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/blockchain"
+)
+
+// freeze stops blocks from being mined or accepted until unfreeze is run.
+func (cli *CLI) freeze(reason string) {
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	if err := bc.Freeze(reason); err != nil {
+		log.Panicln("Unable to freeze chain: ", err.Error())
+	}
+	fmt.Printf("Chain frozen at block %x\n", bc.Tip())
+}
+
+// unfreeze lets blocks be mined and accepted again.
+func (cli *CLI) unfreeze() {
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	if _, frozen := bc.Frozen(); !frozen {
+		fmt.Println("Chain is not frozen")
+		return
+	}
+	if err := bc.Unfreeze(); err != nil {
+		log.Panicln("Unable to unfreeze chain: ", err.Error())
+	}
+	fmt.Println("Chain unfrozen")
+}
+
+// mineSent mines the pending transactions after the transaction txID was
+// added to the mempool, rewarding minerAddress with the block subsidy plus
+// their fees. If the chain is frozen, or another authority of a proof of authority network is
+// to seal the next block, the transaction is left pending and false is
+// returned.
+func (cli *CLI) mineSent(bc *blockchain.BlockChain, minerAddress string, txID []byte) bool {
+	_, err := bc.MinePendingContext(cli.ctx, minerAddress)
+	if err == blockchain.ErrChainFrozen {
+		reason, _ := bc.Frozen()
+		fmt.Printf("Transaction %x added to mempool, mining is paused while the chain is frozen: %s\n", txID, reason)
+		return false
+	} else if err == blockchain.ErrNotAuthority || err == blockchain.ErrNotInTurn {
+		fmt.Printf("Transaction %x added to mempool, to be sealed by an authority: %s\n", txID, err.Error())
+		return false
+	} else if err != nil {
+		log.Panicln("Unable to mine block: ", err.Error())
+	}
+	return true
+}
@@ -0,0 +1,685 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// grpcService is the name of the Node service of node.proto.
+	grpcService = "gochain.Node"
+
+	// grpcSubscriptionBuffer is the number of blocks buffered for a
+	// SubscribeBlocks stream before it is ended for falling behind.
+	grpcSubscriptionBuffer = 256
+)
+
+// grpcScopes are the scopes API tokens need for each method of the Node
+// service, keyed by full method name.
+var grpcScopes = map[string]string{
+	"/" + grpcService + "/GetBlock":        scopeRead,
+	"/" + grpcService + "/GetTransaction":  scopeRead,
+	"/" + grpcService + "/GetBalance":      scopeRead,
+	"/" + grpcService + "/SubscribeBlocks": scopeRead,
+	"/" + grpcService + "/SendTransaction": scopeWrite,
+	"/" + grpcService + "/ListWallets":     scopeWallet,
+	"/" + grpcService + "/WalletSend":      scopeWallet,
+}
+
+// protoMessage is a message of node.proto, encoded by hand like the
+// messages of gochain.proto since the node isn't built with protoc.
+type protoMessage interface {
+	marshalProto() []byte
+	unmarshalProto(data []byte) error
+}
+
+// protoCodec encodes the messages of the Node service in place of the
+// codec of gRPC, which needs messages generated by protoc.
+type protoCodec struct{}
+
+// Marshal encodes a protoMessage.
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(protoMessage)
+	if !ok {
+		return nil, fmt.Errorf("unable to marshal %T, which is not a message of node.proto", v)
+	}
+	return m.marshalProto(), nil
+}
+
+// Unmarshal decodes a protoMessage.
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(protoMessage)
+	if !ok {
+		return fmt.Errorf("unable to unmarshal %T, which is not a message of node.proto", v)
+	}
+	return m.unmarshalProto(data)
+}
+
+// Name returns the content subtype of the codec, which is that of protobuf.
+func (protoCodec) Name() string {
+	return "proto"
+}
+
+// protoFields are the fields of a message by number, of the varint and
+// bytes wire types node.proto uses, with repeated fields in order.
+type protoFields struct {
+	varints map[protowire.Number]uint64
+	bytes   map[protowire.Number][][]byte
+}
+
+// parseProtoFields reads the fields of a message, skipping those of other
+// wire types.
+func parseProtoFields(data []byte) (protoFields, error) {
+	fields := protoFields{varints: make(map[protowire.Number]uint64), bytes: make(map[protowire.Number][][]byte)}
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fields, protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch typ {
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(data)
+			fields.varints[num] = v
+		case protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(data)
+			fields.bytes[num] = append(fields.bytes[num], v)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return fields, protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return fields, nil
+}
+
+// int returns an int64 field, 0 if it is not set.
+func (f protoFields) int(num protowire.Number) int64 {
+	return int64(f.varints[num])
+}
+
+// has returns whether a varint field is set.
+func (f protoFields) has(num protowire.Number) bool {
+	_, ok := f.varints[num]
+	return ok
+}
+
+// bytesField returns the last value of a bytes field, nil if it is not set.
+func (f protoFields) bytesField(num protowire.Number) []byte {
+	values := f.bytes[num]
+	if len(values) == 0 {
+		return nil
+	}
+	return values[len(values)-1]
+}
+
+// appendProtoInt appends an int64 field to b, leaving it out if it is 0 as
+// proto3 does.
+func appendProtoInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+// appendProtoBytes appends a bytes or string field to b, leaving it out if
+// it is empty as proto3 does.
+func appendProtoBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// protoBlock is a Block message.
+type protoBlock struct {
+	*blockchain.Block
+}
+
+// marshalProto encodes the message.
+func (m *protoBlock) marshalProto() []byte {
+	return m.Block.MarshalProto()
+}
+
+// unmarshalProto decodes the message.
+func (m *protoBlock) unmarshalProto(data []byte) error {
+	block, err := blockchain.UnmarshalBlockProto(data)
+	m.Block = block
+	return err
+}
+
+// protoTransaction is a Transaction message.
+type protoTransaction struct {
+	*blockchain.Transaction
+}
+
+// marshalProto encodes the message.
+func (m *protoTransaction) marshalProto() []byte {
+	return m.Transaction.MarshalProto()
+}
+
+// unmarshalProto decodes the message.
+func (m *protoTransaction) unmarshalProto(data []byte) error {
+	tx, err := blockchain.UnmarshalTransactionProto(data)
+	m.Transaction = tx
+	return err
+}
+
+// blockRequest is a BlockRequest message.
+type blockRequest struct {
+	hash   []byte
+	height int64
+}
+
+// marshalProto encodes the message.
+func (m *blockRequest) marshalProto() []byte {
+	b := appendProtoBytes(nil, 1, m.hash)
+	return appendProtoInt(b, 2, m.height)
+}
+
+// unmarshalProto decodes the message.
+func (m *blockRequest) unmarshalProto(data []byte) error {
+	fields, err := parseProtoFields(data)
+	m.hash, m.height = fields.bytesField(1), fields.int(2)
+	return err
+}
+
+// transactionRequest is a TransactionRequest message.
+type transactionRequest struct {
+	id []byte
+}
+
+// marshalProto encodes the message.
+func (m *transactionRequest) marshalProto() []byte {
+	return appendProtoBytes(nil, 1, m.id)
+}
+
+// unmarshalProto decodes the message.
+func (m *transactionRequest) unmarshalProto(data []byte) error {
+	fields, err := parseProtoFields(data)
+	m.id = fields.bytesField(1)
+	return err
+}
+
+// sendResponse is a SendResponse message.
+type sendResponse struct {
+	txID   []byte
+	wallet string
+}
+
+// marshalProto encodes the message.
+func (m *sendResponse) marshalProto() []byte {
+	b := appendProtoBytes(nil, 1, m.txID)
+	return appendProtoBytes(b, 2, []byte(m.wallet))
+}
+
+// unmarshalProto decodes the message.
+func (m *sendResponse) unmarshalProto(data []byte) error {
+	fields, err := parseProtoFields(data)
+	m.txID, m.wallet = fields.bytesField(1), string(fields.bytesField(2))
+	return err
+}
+
+// balanceRequest is a BalanceRequest message, with a nil minConf if it is
+// not set.
+type balanceRequest struct {
+	address string
+	minConf *int64
+}
+
+// marshalProto encodes the message.
+func (m *balanceRequest) marshalProto() []byte {
+	b := appendProtoBytes(nil, 1, []byte(m.address))
+	if m.minConf != nil {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(*m.minConf))
+	}
+	return b
+}
+
+// unmarshalProto decodes the message.
+func (m *balanceRequest) unmarshalProto(data []byte) error {
+	fields, err := parseProtoFields(data)
+	m.address = string(fields.bytesField(1))
+	if fields.has(2) {
+		minConf := fields.int(2)
+		m.minConf = &minConf
+	}
+	return err
+}
+
+// balance is a Balance message.
+type balance struct {
+	trusted, untrustedPending, immature units.Amount
+	pendingIncoming, pendingOutgoing    units.Amount
+}
+
+// marshalProto encodes the message.
+func (m *balance) marshalProto() []byte {
+	b := appendProtoInt(nil, 1, int64(m.trusted))
+	b = appendProtoInt(b, 2, int64(m.untrustedPending))
+	b = appendProtoInt(b, 3, int64(m.immature))
+	b = appendProtoInt(b, 4, int64(m.pendingIncoming))
+	return appendProtoInt(b, 5, int64(m.pendingOutgoing))
+}
+
+// unmarshalProto decodes the message.
+func (m *balance) unmarshalProto(data []byte) error {
+	fields, err := parseProtoFields(data)
+	m.trusted = units.Amount(fields.int(1))
+	m.untrustedPending = units.Amount(fields.int(2))
+	m.immature = units.Amount(fields.int(3))
+	m.pendingIncoming = units.Amount(fields.int(4))
+	m.pendingOutgoing = units.Amount(fields.int(5))
+	return err
+}
+
+// subscribeBlocksRequest is a SubscribeBlocksRequest message, which has no
+// fields.
+type subscribeBlocksRequest struct{}
+
+// marshalProto encodes the message.
+func (m *subscribeBlocksRequest) marshalProto() []byte {
+	return nil
+}
+
+// unmarshalProto decodes the message.
+func (m *subscribeBlocksRequest) unmarshalProto(data []byte) error {
+	_, err := parseProtoFields(data)
+	return err
+}
+
+// walletRequest is a WalletRequest message.
+type walletRequest struct {
+	wallet string
+}
+
+// marshalProto encodes the message.
+func (m *walletRequest) marshalProto() []byte {
+	return appendProtoBytes(nil, 1, []byte(m.wallet))
+}
+
+// unmarshalProto decodes the message.
+func (m *walletRequest) unmarshalProto(data []byte) error {
+	fields, err := parseProtoFields(data)
+	m.wallet = string(fields.bytesField(1))
+	return err
+}
+
+// walletsResponse is a Wallets message.
+type walletsResponse struct {
+	descriptors []string
+}
+
+// marshalProto encodes the message.
+func (m *walletsResponse) marshalProto() []byte {
+	var b []byte
+	for _, descriptor := range m.descriptors {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, descriptor)
+	}
+	return b
+}
+
+// unmarshalProto decodes the message.
+func (m *walletsResponse) unmarshalProto(data []byte) error {
+	fields, err := parseProtoFields(data)
+	m.descriptors = nil
+	for _, descriptor := range fields.bytes[1] {
+		m.descriptors = append(m.descriptors, string(descriptor))
+	}
+	return err
+}
+
+// walletSendRequest is a WalletSendRequest message.
+type walletSendRequest struct {
+	wallet string
+	send   walletSend
+}
+
+// marshalProto encodes the message.
+func (m *walletSendRequest) marshalProto() []byte {
+	b := appendProtoBytes(nil, 1, []byte(m.wallet))
+	b = appendProtoBytes(b, 2, []byte(m.send.From))
+	b = appendProtoBytes(b, 3, []byte(m.send.To))
+	b = appendProtoInt(b, 4, int64(m.send.Amount))
+	b = appendProtoInt(b, 5, int64(m.send.Fee))
+	return appendProtoBytes(b, 6, []byte(m.send.RequestID))
+}
+
+// unmarshalProto decodes the message.
+func (m *walletSendRequest) unmarshalProto(data []byte) error {
+	fields, err := parseProtoFields(data)
+	m.wallet = string(fields.bytesField(1))
+	m.send = walletSend{
+		From:      string(fields.bytesField(2)),
+		To:        string(fields.bytesField(3)),
+		Amount:    units.Amount(fields.int(4)),
+		Fee:       units.Amount(fields.int(5)),
+		RequestID: string(fields.bytesField(6)),
+	}
+	return err
+}
+
+// newGRPCServer returns a server of the Node service of node.proto, with
+// the wallet methods only if the node serves its wallets. Requests are
+// authorized and rate limited like those of the HTTP API, and refused if
+// they are over its body limit.
+func (n *node) newGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	if n.limits.maxBodyBytes > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(int(n.limits.maxBodyBytes)))
+	}
+	opts = append(opts,
+		grpc.ForceServerCodec(protoCodec{}),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := n.grpcAuthorized(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := n.grpcAuthorized(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	server := grpc.NewServer(opts...)
+
+	service := grpc.ServiceDesc{
+		ServiceName: grpcService,
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{
+			unaryMethod("GetBlock", func() protoMessage { return &blockRequest{} }, n.grpcGetBlock),
+			unaryMethod("GetTransaction", func() protoMessage { return &transactionRequest{} }, n.grpcGetTransaction),
+			unaryMethod("SendTransaction", func() protoMessage { return &protoTransaction{} }, n.grpcSendTransaction),
+			unaryMethod("GetBalance", func() protoMessage { return &balanceRequest{} }, n.grpcGetBalance),
+		},
+		Streams: []grpc.StreamDesc{
+			{StreamName: "SubscribeBlocks", Handler: n.grpcSubscribeBlocks, ServerStreams: true},
+		},
+		Metadata: "cli/node.proto",
+	}
+	if n.servesWallets() {
+		service.Methods = append(service.Methods,
+			unaryMethod("ListWallets", func() protoMessage { return &walletRequest{} }, n.grpcListWallets),
+			unaryMethod("WalletSend", func() protoMessage { return &walletSendRequest{} }, n.grpcWalletSend),
+		)
+	}
+	server.RegisterService(&service, n)
+	return server
+}
+
+// unaryMethod describes a unary method of the Node service decoding its
+// request with newRequest and handling it with call.
+func unaryMethod(name string, newRequest func() protoMessage, call func(ctx context.Context, req protoMessage) (protoMessage, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newRequest()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(ctx, req.(protoMessage))
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + grpcService + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+// grpcAuthorized rate limits a call of method by token, or by IP without a
+// token of the node, and returns an error unless it holds a token with the
+// scope of the method. Without API tokens only the wallet methods need
+// one, as on the HTTP API. Tokens are sent in the authorization metadata
+// as "Bearer TOKEN".
+func (n *node) grpcAuthorized(ctx context.Context, method string) error {
+	var token string
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, auth := range md.Get("authorization") {
+		if strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+	}
+
+	// rate limit before authorizing, so clients can't guess tokens quickly
+	limit, client := n.limits.ipRate, "ip "+peerIP(ctx)
+	if n.knownToken(token) {
+		limit, client = n.limits.tokenRate, "token "+token
+	}
+	if ok, retry := limit.allow(client, time.Now()); !ok {
+		return status.Errorf(codes.ResourceExhausted, "too many requests, retry in %s", retry.Round(time.Millisecond))
+	}
+
+	scope, ok := grpcScopes[method]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	}
+	if scope != scopeWallet && len(n.tokens) == 0 {
+		return nil
+	}
+	switch err := n.checkToken(token, scope); err {
+	case nil:
+		return nil
+	case errUnauthorized:
+		return status.Error(codes.Unauthenticated, err.Error())
+	default:
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+}
+
+// peerIP returns the IP a call came from.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// grpcCode returns the code of the gRPC status matching an HTTP status.
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// grpcGetBlock returns a block by hash, or the block of the best chain at
+// the height of the request if it has no hash.
+func (n *node) grpcGetBlock(ctx context.Context, req protoMessage) (protoMessage, error) {
+	r := req.(*blockRequest)
+	var block *blockchain.Block
+	var err error
+	if len(r.hash) > 0 {
+		block, err = n.bc.GetBlock(r.hash)
+	} else if r.height < 0 || r.height > math.MaxInt32 {
+		return nil, status.Error(codes.InvalidArgument, "height not valid")
+	} else {
+		block, err = n.bc.GetBlockByHeight(int(r.height))
+	}
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &protoBlock{block}, nil
+}
+
+// grpcGetTransaction returns a pending transaction or one of the best
+// chain by id.
+func (n *node) grpcGetTransaction(ctx context.Context, req protoMessage) (protoMessage, error) {
+	r := req.(*transactionRequest)
+	if len(r.id) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id not valid")
+	}
+	tx, err := n.bc.FindTransaction(r.id)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &protoTransaction{&tx}, nil
+}
+
+// grpcSendTransaction adds a signed transaction to the mempool.
+func (n *node) grpcSendTransaction(ctx context.Context, req protoMessage) (protoMessage, error) {
+	tx := req.(*protoTransaction).Transaction
+	if tx.ID == nil {
+		return nil, status.Error(codes.InvalidArgument, "transaction has not been signed")
+	}
+	if err := n.bc.AddToMempool(tx); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &sendResponse{txID: tx.ID}, nil
+}
+
+// grpcGetBalance returns the balance of an address like /balance.
+func (n *node) grpcGetBalance(ctx context.Context, req protoMessage) (protoMessage, error) {
+	r := req.(*balanceRequest)
+	if !wallet.ValidateAddress(r.address) {
+		return nil, status.Error(codes.InvalidArgument, "address not valid")
+	}
+	minConf := 1
+	if r.minConf != nil {
+		if *r.minConf < 0 || *r.minConf > math.MaxInt32 {
+			return nil, status.Error(codes.InvalidArgument, "min_conf not valid")
+		}
+		minConf = int(*r.minConf)
+	}
+	pubKeyHash, err := wallet.PublicKeyHashFromAddress(r.address)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	balances, err := n.bc.Balances(pubKeyHash, minConf)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	transfers := n.bc.PendingTransfers(pubKeyHash)
+	return &balance{
+		trusted:          balances.Trusted,
+		untrustedPending: balances.UntrustedPending,
+		immature:         balances.Immature,
+		pendingIncoming:  transfers.Incoming,
+		pendingOutgoing:  transfers.Outgoing,
+	}, nil
+}
+
+// grpcSubscribeBlocks streams the blocks connected to the best chain until
+// the client goes away, ending the stream if it falls too far behind.
+func (n *node) grpcSubscribeBlocks(srv interface{}, stream grpc.ServerStream) error {
+	if err := stream.RecvMsg(&subscribeBlocksRequest{}); err != nil {
+		return err
+	}
+	sub := n.events.Subscribe(grpcSubscriptionBuffer)
+	defer sub.Close()
+	sub.Add(events.NewBlock)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case event, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "client fell too far behind")
+			}
+			block, ok := event.Payload.(*blockchain.Block)
+			if !ok {
+				continue
+			}
+			if err := stream.SendMsg(&protoBlock{block}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// grpcWallet returns the wallets file of the node called name, which may
+// be left empty if the node has a single one.
+func (n *node) grpcWallet(name string) (string, *wallet.Store, error) {
+	if name == "" {
+		if len(n.wallets) != 1 {
+			return "", nil, status.Errorf(codes.InvalidArgument, "node has wallets %s, select one", strings.Join(n.walletNames(), ", "))
+		}
+		for name, store := range n.wallets {
+			return name, store, nil
+		}
+	}
+	store := n.wallets[name]
+	if store == nil {
+		return "", nil, status.Errorf(codes.NotFound, "no wallet %s, node has wallets %s", name, strings.Join(n.walletNames(), ", "))
+	}
+	return name, store, nil
+}
+
+// grpcListWallets returns the descriptors of the wallets in a wallets file
+// of the node.
+func (n *node) grpcListWallets(ctx context.Context, req protoMessage) (protoMessage, error) {
+	_, store, err := n.grpcWallet(req.(*walletRequest).wallet)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := store.Wallets()
+	if err == wallet.ErrLocked {
+		return nil, status.Error(codes.Unavailable, "wallets file is locked, run walletunlock on the node")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	response := &walletsResponse{}
+	for _, w := range wallets {
+		response.descriptors = append(response.descriptors, w.Descriptor().String())
+	}
+	sort.Strings(response.descriptors)
+	return response, nil
+}
+
+// grpcWalletSend sends coins from an address of a wallets file of the node
+// like /wallet/NAME/send.
+func (n *node) grpcWalletSend(ctx context.Context, req protoMessage) (protoMessage, error) {
+	r := req.(*walletSendRequest)
+	name, store, err := n.grpcWallet(r.wallet)
+	if err != nil {
+		return nil, err
+	}
+	txID, httpStatus, err := n.sendFromWallet(name, store, r.send)
+	if err != nil {
+		return nil, status.Error(grpcCode(httpStatus), err.Error())
+	}
+	return &sendResponse{txID: txID, wallet: name}, nil
+}
@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCNode(t *testing.T) {
+	store := wallet.NewStore(filepath.Join(t.TempDir(), "wallets.dat"))
+	hot, err := store.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := wallet.CreateWallet()
+	bc, err := blockchain.InitInMemory(&blockchain.Genesis{
+		Network: "grpc",
+		Allocations: map[string]units.Amount{
+			hot.Address().String():   100 * units.Coin,
+			other.Address().String(): 100 * units.Coin,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	bus := events.NewBus()
+	n := &node{
+		bc:      bc,
+		token:   "secret",
+		wallets: map[string]*wallet.Store{defaultWallet: store},
+		tokens:  apiTokens{"reader": {scopeRead: true}},
+		events:  bus,
+	}
+	listener := bufconn.Listen(1 << 20)
+	server := n.newGRPCServer()
+	defer server.Stop()
+	go server.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(protoCodec{})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	call := func(token, method string, req, resp protoMessage) codes.Code {
+		ctx := ctx
+		if token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+		}
+		return status.Code(conn.Invoke(ctx, "/gochain.Node/"+method, req, resp))
+	}
+
+	// every call needs a token with its scope once there are API tokens
+	genesis, err := bc.GetBlockByHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &protoBlock{}
+	if code := call("", "GetBlock", &blockRequest{}, block); code != codes.Unauthenticated {
+		t.Fatalf("got %s without a token, want unauthenticated", code)
+	}
+	if code := call("reader", "GetBlock", &blockRequest{}, block); code != codes.OK {
+		t.Fatalf("got %s fetching the genesis block", code)
+	}
+	if !bytes.Equal(block.Hash, genesis.Hash) {
+		t.Fatalf("got block %x, want the genesis block %x", block.Hash, genesis.Hash)
+	}
+	if code := call("reader", "GetBlock", &blockRequest{height: 5}, block); code != codes.NotFound {
+		t.Fatalf("got %s for a height beyond the tip, want not found", code)
+	}
+	tx := &protoTransaction{}
+	if code := call("reader", "GetTransaction", &transactionRequest{id: genesis.Transactions[0].ID}, tx); code != codes.OK {
+		t.Fatalf("got %s fetching the genesis transaction", code)
+	}
+	if !bytes.Equal(tx.ID, genesis.Transactions[0].ID) {
+		t.Fatalf("got transaction %x, want %x", tx.ID, genesis.Transactions[0].ID)
+	}
+	var got balance
+	if code := call("reader", "GetBalance", &balanceRequest{address: hot.Address().String()}, &got); code != codes.OK {
+		t.Fatalf("got %s fetching a balance", code)
+	}
+	if got.trusted+got.immature != 100*units.Coin {
+		t.Fatalf("got balance %+v, want 100 coins", got)
+	}
+
+	// transactions need the write scope
+	payment, err := bc.NewPaymentTransaction(other, []blockchain.Payment{{To: hot.Address().String(), Amount: units.Coin}}, units.Coin/100, other.Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent sendResponse
+	if code := call("reader", "SendTransaction", &protoTransaction{payment}, &sent); code != codes.PermissionDenied {
+		t.Fatalf("got %s sending with a read token, want permission denied", code)
+	}
+	if code := call("secret", "SendTransaction", &protoTransaction{payment}, &sent); code != codes.OK {
+		t.Fatalf("got %s sending a transaction", code)
+	}
+	if !bytes.Equal(sent.txID, payment.ID) {
+		t.Fatalf("got txid %x, want %x", sent.txID, payment.ID)
+	}
+
+	// the wallets of the node need the wallet scope
+	var wallets walletsResponse
+	if code := call("reader", "ListWallets", &walletRequest{}, &wallets); code != codes.PermissionDenied {
+		t.Fatalf("got %s listing wallets with a read token, want permission denied", code)
+	}
+	if code := call("secret", "ListWallets", &walletRequest{}, &wallets); code != codes.OK {
+		t.Fatalf("got %s listing wallets", code)
+	}
+	if len(wallets.descriptors) != 1 || wallets.descriptors[0] != hot.Descriptor().String() {
+		t.Fatalf("got %v, want the descriptor of the wallet", wallets.descriptors)
+	}
+	send := &walletSendRequest{send: walletSend{From: other.Address().String(), To: hot.Address().String(), Amount: units.Coin}}
+	if code := call("secret", "WalletSend", send, &sent); code != codes.InvalidArgument {
+		t.Fatalf("got %s sending from an address of no wallet, want invalid argument", code)
+	}
+	send.send.From = hot.Address().String()
+	if code := call("secret", "WalletSend", send, &sent); code != codes.OK {
+		t.Fatalf("got %s sending from the wallet", code)
+	}
+	if sent.wallet != defaultWallet || len(bc.MempoolTransactions()) != 2 {
+		t.Fatalf("got wallet %q with %d pending transactions, want %q with 2", sent.wallet, len(bc.MempoolTransactions()), defaultWallet)
+	}
+
+	// new blocks are streamed to subscribers
+	stream, err := conn.NewStream(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer reader"), &grpc.StreamDesc{ServerStreams: true}, "/gochain.Node/SubscribeBlocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&subscribeBlocksRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// publish until the subscription has been made
+		for {
+			bus.Publish(events.Event{Type: events.NewBlock, Payload: genesis})
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+	streamed := &protoBlock{}
+	if err := stream.RecvMsg(streamed); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamed.Hash, genesis.Hash) {
+		t.Fatalf("got block %x from the stream, want %x", streamed.Hash, genesis.Hash)
+	}
+}
+
+func TestGRPCWithoutWallets(t *testing.T) {
+	bc, err := blockchain.InitInMemory(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	// without tokens, read and write calls are open and wallets aren't served
+	n := &node{bc: bc, wallets: map[string]*wallet.Store{}}
+	listener := bufconn.Listen(1 << 20)
+	server := n.newGRPCServer()
+	defer server.Stop()
+	go server.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(protoCodec{})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := conn.Invoke(ctx, "/gochain.Node/GetBlock", &blockRequest{}, &protoBlock{}); err != nil {
+		t.Fatalf("got %v fetching a block without tokens", err)
+	}
+	err = conn.Invoke(ctx, "/gochain.Node/ListWallets", &walletRequest{}, &walletsResponse{})
+	if code := status.Code(err); code != codes.Unimplemented {
+		t.Fatalf("got %s listing wallets of a node not serving them, want unimplemented", code)
+	}
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// history prints every confirmed transaction that pays to or spends from
+// address, oldest first, with the change in its balance.
+func (cli *CLI) history(address string) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to get history: address not valid")
+	}
+	pubKeyHash := pubKeyHashFromAddress(address)
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	// read the history and balance from a snapshot so they agree if a
+	// block is added in between
+	s, err := bc.Snapshot()
+	if err != nil {
+		log.Panicln("Unable to get history: ", err.Error())
+	}
+	defer s.Discard()
+	history, err := s.History(pubKeyHash)
+	if err != nil {
+		log.Panicln("Unable to get history: ", err.Error())
+	}
+	balance, err := s.Balance(pubKeyHash)
+	if err != nil {
+		log.Panicln("Unable to get balance: ", err.Error())
+	}
+
+	for _, entry := range history {
+		// show the amount with an explicit sign
+		amount := units.FormatAmount(entry.Amount())
+		if entry.Amount() >= 0 {
+			amount = "+" + amount
+		}
+		final := ""
+		if bc.Final(entry.Confirmations) {
+			final = ", final"
+		}
+		fmt.Printf("%x %-8s %s\n\t%s, block %d %x, %d confirmations%s\n",
+			entry.Tx.ID, entry.Direction(), amount, blockchain.FormatTimestamp(entry.Timestamp), entry.Height, entry.BlockHash, entry.Confirmations, final)
+
+		// link the outputs of the address to where they were spent, which
+		// is known with SPENT_INDEX set
+		outs := make([]int, 0, len(entry.Spends))
+		for out := range entry.Spends {
+			outs = append(outs, out)
+		}
+		sort.Ints(outs)
+		for _, out := range outs {
+			spend := entry.Spends[out]
+			fmt.Printf("\tOutput %d spent by %x input %d, block %d\n", out, spend.TxID, spend.Input, spend.Height)
+		}
+	}
+
+	fmt.Printf("%d transactions, balance of %s: %s\n", len(history), address, units.FormatAmount(balance))
+}
+
+// reindexAddresses rebuilds the address index used by history and balance
+// queries as a job, resuming an interrupted run.
+func (cli *CLI) reindexAddresses() {
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	err := cli.runJob("reindexaddresses", 0, func(ctx context.Context, resume *blockchain.JobProgress, progress func(blockchain.JobProgress)) error {
+		return bc.ReindexAddressesContext(ctx, resume != nil, progress)
+	})
+	if err == blockchain.ErrJobCancelled {
+		return
+	} else if err != nil {
+		log.Panicln("Unable to reindex addresses: ", err.Error())
+	}
+	fmt.Printf("Indexed %d blocks\n", bc.Height()+1)
+}
+
+// rescan rebuilds the UTXO set and the indexes from the blocks in the
+// chain as a job, resuming an interrupted run.
+func (cli *CLI) rescan() {
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	err := cli.runJob("rescan", 0, func(ctx context.Context, resume *blockchain.JobProgress, progress func(blockchain.JobProgress)) error {
+		return bc.ReindexUTXOContext(ctx, resume != nil, progress)
+	})
+	if err == blockchain.ErrJobCancelled {
+		return
+	} else if err != nil {
+		log.Panicln("Unable to rescan: ", err.Error())
+	}
+	fmt.Printf("Rescanned %d blocks\n", bc.Height()+1)
+}
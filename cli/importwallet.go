@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/edwintcloud/gochain/hooks"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// walletExport is the body served at /wallets, holding the descriptors of
+// the wallets of a node and, if asked for, the backup of its encrypted
+// wallets file as hex.
+type walletExport struct {
+	Descriptors []string `json:"descriptors"`
+	Backup      string   `json:"backup,omitempty"`
+}
+
+// serveWallets returns the descriptors of the wallets in store, and the
+// backup of the wallets file if keys is set in the query. Keys are only
+// served encrypted, so the passphrase of the file is needed to read them.
+func (n *node) serveWallets(w http.ResponseWriter, r *http.Request, store *wallet.Store) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	wallets, err := store.Wallets()
+	if err == wallet.ErrLocked {
+		http.Error(w, "wallets file is locked, run walletunlock on the node", http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	export := walletExport{Descriptors: []string{}}
+	for _, wallet := range wallets {
+		export.Descriptors = append(export.Descriptors, wallet.Descriptor().String())
+	}
+	sort.Strings(export.Descriptors)
+
+	if r.URL.Query().Get("keys") == "true" {
+		backup, err := store.Backup()
+		if err == wallet.ErrNotEncrypted {
+			http.Error(w, "keys are only served from an encrypted wallets file, run encryptwallet on the node", http.StatusForbidden)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		export.Backup = hex.EncodeToString(backup)
+	}
+	writeJSON(w, export)
+}
+
+// fetchWallets gets the wallets of the node run with serve at url, with
+// the backup of its wallets file if keys is set.
+func fetchWallets(url, token string, keys bool) (*walletExport, error) {
+	u := strings.TrimSuffix(url, "/") + "/wallets"
+	if keys {
+		u += "?keys=true"
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var export walletExport
+	if err := json.NewDecoder(resp.Body).Decode(&export); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// importWallet adds the wallets of the node run with serve at url to the
+// wallets file, as watch-only wallets from their descriptors, or with
+// their private keys if keys is set, decrypting the backup of the node
+// with the passphrase of its wallets file read from stdin.
+func (cli *CLI) importWallet(url, token string, keys bool) {
+	export, err := fetchWallets(url, token, keys)
+	if err != nil {
+		log.Panicln("Unable to fetch wallets: ", err.Error())
+	}
+
+	if !keys {
+		for _, descriptor := range export.Descriptors {
+			d, err := wallet.ParseDescriptor(descriptor)
+			if err != nil {
+				log.Panicln("Unable to parse descriptor: ", err.Error())
+			}
+			w, err := d.Wallet()
+			if err != nil {
+				log.Panicln("Unable to import descriptor: ", err.Error())
+			}
+			cli.watch(w)
+		}
+		return
+	}
+
+	backup, err := hex.DecodeString(export.Backup)
+	if err != nil || len(backup) == 0 {
+		log.Panicln("Unable to import keys: node sent no backup")
+	}
+	wallets, err := wallet.OpenBackup(backup, readPassphrase("Passphrase of the node's wallets file: "))
+	if err != nil {
+		log.Panicln("Unable to open backup: ", err.Error())
+	}
+	addresses := make([]string, 0, len(wallets))
+	for address := range wallets {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	// replace watch-only wallets with the imported keys
+	store := walletStore()
+	for _, address := range addresses {
+		if existing, err := store.Get(address); err == nil && !existing.WatchOnly() {
+			fmt.Printf("%s is already in the wallets file with its private key\n", address)
+			continue
+		}
+		if err := store.Add(wallets[address]); err != nil {
+			log.Panicln("Unable to save wallet: ", err.Error())
+		}
+		hooks.Notify(hooks.WalletCreated, map[string]string{"address": address})
+		fmt.Printf("Imported key for %s\n", address)
+	}
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestFetchWallets(t *testing.T) {
+	store := wallet.NewStore(filepath.Join(t.TempDir(), "wallets.dat"))
+	w, err := store.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc((&node{token: "secret", wallets: map[string]*wallet.Store{defaultWallet: store}}).handleWallets))
+	defer server.Close()
+
+	// the token is required
+	if _, err := fetchWallets(server.URL, "wrong", false); err == nil {
+		t.Fatal("wallets were served without the token")
+	}
+
+	export, err := fetchWallets(server.URL, "secret", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Descriptors) != 1 || export.Descriptors[0] != w.Descriptor().String() || export.Backup != "" {
+		t.Fatalf("got %+v, want the descriptor of %s", export, w.Address())
+	}
+
+	// keys are only served once the wallets file is encrypted
+	if _, err := fetchWallets(server.URL, "secret", true); err == nil {
+		t.Fatal("keys were served from a plain wallets file")
+	}
+	if err := store.Encrypt("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	export, err = fetchWallets(server.URL, "secret", true)
+	if err != nil || export.Backup == "" {
+		t.Fatalf("got %+v, %v, want a backup", export, err)
+	}
+}
@@ -0,0 +1,259 @@
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+)
+
+// states of a job in the jobs file
+const (
+	jobRunning     = "running"
+	jobInterrupted = "interrupted"
+	jobFailed      = "failed"
+)
+
+// jobHeartbeat is how often a running job records its progress and checks
+// whether it was asked to cancel, and jobStale how long a running job can
+// go without recording it before it is taken to have died.
+const (
+	jobHeartbeat = time.Second
+	jobStale     = 10 * time.Second
+)
+
+// job is the progress of a long-running job over the blocks of the chain,
+// recorded in the jobs file so it can be monitored with the jobs command
+// and an interrupted run can be resumed.
+type job struct {
+	Name    string    `json:"name"`
+	State   string    `json:"state"`
+	Done    int       `json:"done"`
+	Total   int       `json:"total"`
+	Last    string    `json:"last,omitempty"`
+	From    int       `json:"from"`
+	Error   string    `json:"error,omitempty"`
+	Cancel  bool      `json:"cancel,omitempty"`
+	Started time.Time `json:"started"`
+	Updated time.Time `json:"updated"`
+}
+
+// state returns the state of the job, taking a running job that stopped
+// recording its progress to have been interrupted.
+func (j *job) state() string {
+	if j.State == jobRunning && time.Since(j.Updated) > jobStale {
+		return jobInterrupted
+	}
+	return j.State
+}
+
+// progress returns the progress of the job for resuming it.
+func (j *job) progress() *blockchain.JobProgress {
+	last, err := hex.DecodeString(j.Last)
+	if err != nil || len(last) == 0 {
+		return nil
+	}
+	return &blockchain.JobProgress{Done: j.Done, Total: j.Total, Last: last}
+}
+
+// jobsPath returns the file recording the jobs of the network. It is kept
+// outside the database, which other commands can't open while a job runs.
+func jobsPath() string {
+	return dbPath() + ".jobs"
+}
+
+// readJobs returns the jobs in the jobs file by name.
+func readJobs() (map[string]*job, error) {
+	jobs := make(map[string]*job)
+	data, err := ioutil.ReadFile(jobsPath())
+	if os.IsNotExist(err) {
+		return jobs, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("jobs file %s is invalid - %s", jobsPath(), err.Error())
+	}
+	return jobs, nil
+}
+
+// writeJobs replaces the jobs file with jobs, through a temporary file so
+// the jobs command never reads half a file.
+func writeJobs(jobs map[string]*job) error {
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := jobsPath() + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, jobsPath())
+}
+
+// updateJob applies update to the job called name in the jobs file and
+// writes it back, removing the job if update returns nil.
+func updateJob(name string, update func(j *job) *job) error {
+	jobs, err := readJobs()
+	if err != nil {
+		return err
+	}
+	if j := update(jobs[name]); j != nil {
+		jobs[name] = j
+	} else {
+		delete(jobs, name)
+	}
+	return writeJobs(jobs)
+}
+
+// runJob runs the job called name with the progress of its interrupted run
+// on the blocks from height from, if there is one, recording its progress
+// in the jobs file until it finishes. A job cancelled with the jobs command
+// is forgotten and starts over when run again, while one interrupted by a
+// shutdown or an error keeps its progress to be resumed.
+func (cli *CLI) runJob(name string, from int, run func(ctx context.Context, resume *blockchain.JobProgress, progress func(blockchain.JobProgress)) error) error {
+	jobs, err := readJobs()
+	if err != nil {
+		return err
+	}
+
+	// resume an earlier run on the same blocks, which is no longer running
+	// as the database is only opened by one command at a time
+	var resume *blockchain.JobProgress
+	current := &job{Name: name, State: jobRunning, From: from, Started: time.Now(), Updated: time.Now()}
+	if prev := jobs[name]; prev != nil && prev.From == from {
+		resume = &blockchain.JobProgress{Done: prev.Done, Total: prev.Total}
+		if p := prev.progress(); p != nil {
+			resume = p
+		}
+		current.Started = prev.Started
+		fmt.Printf("Resuming %s at %d of %d blocks\n", name, prev.Done, prev.Total)
+	}
+	jobs[name] = current
+	if err := writeJobs(jobs); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(cli.ctx)
+	defer cancel()
+	var mutex sync.Mutex
+	cancelled := false
+
+	// record the progress every heartbeat, cancelling the job if the jobs
+	// command asked to
+	record := func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		updateJob(name, func(j *job) *job {
+			if j != nil && j.Cancel {
+				cancelled = true
+				cancel()
+			}
+			current.Updated = time.Now()
+			return current
+		})
+	}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(jobHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				record()
+			}
+		}
+	}()
+
+	err = run(ctx, resume, func(p blockchain.JobProgress) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		current.Done, current.Total, current.Last = p.Done, p.Total, hex.EncodeToString(p.Last)
+	})
+	close(stop)
+	<-stopped
+
+	// forget finished and cancelled jobs, and keep the progress of the rest
+	switch {
+	case err == nil:
+		return updateJob(name, func(*job) *job { return nil })
+	case err == blockchain.ErrJobCancelled && cancelled:
+		fmt.Printf("Cancelled %s at %d of %d blocks\n", name, current.Done, current.Total)
+		if updateErr := updateJob(name, func(*job) *job { return nil }); updateErr != nil {
+			return updateErr
+		}
+		return err
+	case err == blockchain.ErrJobCancelled:
+		current.State = jobInterrupted
+		fmt.Printf("Interrupted %s at %d of %d blocks, run it again to resume\n", name, current.Done, current.Total)
+	default:
+		current.State = jobFailed
+		current.Error = err.Error()
+	}
+	current.Updated = time.Now()
+	if updateErr := updateJob(name, func(*job) *job { return current }); updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+// listJobs prints the jobs in the jobs file, or asks the job called cancel
+// to stop if it is set. A job that is not running is forgotten instead, so
+// it starts over when run again.
+func (cli *CLI) listJobs(cancel string) {
+	jobs, err := readJobs()
+	if err != nil {
+		log.Panicln("Unable to read jobs: ", err.Error())
+	}
+
+	if cancel != "" {
+		j := jobs[cancel]
+		if j == nil {
+			log.Panicf("Unable to cancel job: no job %s", cancel)
+		}
+		if j.state() == jobRunning {
+			j.Cancel = true
+			fmt.Printf("Asked %s to cancel\n", cancel)
+		} else {
+			delete(jobs, cancel)
+			fmt.Printf("Cancelled %s, it will start over when run again\n", cancel)
+		}
+		if err := writeJobs(jobs); err != nil {
+			log.Panicln("Unable to write jobs: ", err.Error())
+		}
+		return
+	}
+
+	if len(jobs) == 0 {
+		fmt.Println("No jobs")
+		return
+	}
+	var names []string
+	for name := range jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		j := jobs[name]
+		percent := 0.0
+		if j.Total > 0 {
+			percent = 100 * float64(j.Done) / float64(j.Total)
+		}
+		fmt.Printf("%s\t%s\t%d/%d blocks (%.1f%%)\tupdated %s\n", name, j.state(), j.Done, j.Total, percent, j.Updated.Format(time.RFC3339))
+		if j.Error != "" {
+			fmt.Printf("\t%s\n", j.Error)
+		}
+	}
+}
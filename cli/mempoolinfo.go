@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// expireInterval is how often serve expires the pending transactions older
+// than the MEMPOOL_MAX_AGE env var.
+const expireInterval = time.Minute
+
+// mempoolInfo prints the size, fees and limits of the mempool, and how many
+// transactions the limits removed while the chain was open.
+func (cli *CLI) mempoolInfo(asJSON bool) {
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	info := bc.MempoolInfo()
+	if asJSON {
+		printJSON(info)
+		return
+	}
+
+	fmt.Printf("Transactions: %d\n", info.Transactions)
+	fmt.Printf("Bytes:        %d\n", info.Bytes)
+	fmt.Printf("Fees:         %s\n", info.Fees)
+	fmt.Printf("Min fee rate: %g/byte\n", info.MinFeeRate)
+	fmt.Printf("Fee filter:   %g/byte\n", info.FeeFilter)
+	if info.Oldest != 0 {
+		fmt.Printf("Oldest:       %s\n", time.Unix(info.Oldest, 0).Format(time.RFC3339))
+	}
+	limit := func(value int) string {
+		if value == 0 {
+			return "none"
+		}
+		return fmt.Sprint(value)
+	}
+	fmt.Printf("Max count:    %s\n", limit(info.MaxCount))
+	fmt.Printf("Max bytes:    %s\n", limit(info.MaxBytes))
+	if info.MaxAge == "" {
+		info.MaxAge = "none"
+	}
+	fmt.Printf("Max age:      %s\n", info.MaxAge)
+	fmt.Printf("Evict:        %s\n", info.Evict)
+}
+
+// handleMempoolInfo serves the size, fees and limits of the mempool, and
+// how many transactions the limits removed since the node started.
+func (n *node) handleMempoolInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, n.bc.MempoolInfo())
+}
+
+// handleFeeFilter serves the lowest fee rate the mempool takes, so wallets
+// and relays don't send transactions paying less.
+func (n *node) handleFeeFilter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]float64{"minFeeRate": n.bc.FeeFilter()})
+}
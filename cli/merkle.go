@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"encoding/hex"
+	"log"
+
+	"github.com/edwintcloud/gochain/blockchain"
+)
+
+// merkleProof is a Merkle proof as printed by getmerkleproof.
+type merkleProof struct {
+	Block  string   `json:"block"`
+	Root   string   `json:"root"`
+	Index  int      `json:"index"`
+	Branch []string `json:"branch"`
+}
+
+// getMerkleProof prints a proof that a transaction is in a block as JSON.
+func (cli *CLI) getMerkleProof(txIDHex string) {
+	txID, err := blockchain.ParseHash(txIDHex)
+	if err != nil {
+		log.Panicln("Unable to decode transaction id: ", err.Error())
+	}
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	proof, err := bc.GetMerkleProof(txID)
+	if err != nil {
+		log.Panicln("Unable to get Merkle proof: ", err.Error())
+	}
+	block, err := bc.GetBlock(proof.BlockHash)
+	if err != nil {
+		log.Panicln("Unable to get block: ", err.Error())
+	}
+
+	branch := []string{}
+	for _, hash := range proof.Branch {
+		branch = append(branch, hex.EncodeToString(hash))
+	}
+	printJSON(merkleProof{
+		Block:  hex.EncodeToString(proof.BlockHash),
+		Root:   hex.EncodeToString(block.MerkleRoot),
+		Index:  proof.Index,
+		Branch: branch,
+	})
+}
@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+)
+
+// migrateBlocks rewrites the blocks stored in the gob format of earlier
+// versions in the protobuf format.
+func (cli *CLI) migrateBlocks() {
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	migrated, err := bc.MigrateBlocks()
+	if err != nil {
+		log.Panicln("Unable to migrate blocks: ", err.Error())
+	}
+
+	if cli.jsonOutput {
+		printJSON(map[string]interface{}{"migrated": migrated})
+		return
+	}
+	fmt.Printf("Migrated %d blocks\n", migrated)
+}
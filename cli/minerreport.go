@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// minerReport prints how many blocks address was rewarded for mining, the
+// subsidies and fees it earned in the best chain, and how many of its
+// blocks were orphaned.
+func (cli *CLI) minerReport(address string, asJSON bool) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to report mined blocks: address not valid")
+	}
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	report, err := bc.MinerReport(pubKeyHashFromAddress(address))
+	if err != nil {
+		log.Panicln("Unable to report mined blocks: ", err.Error())
+	}
+	if asJSON {
+		printJSON(map[string]interface{}{
+			"address":    address,
+			"blocks":     report.Blocks,
+			"subsidy":    report.Subsidy,
+			"fees":       report.Fees,
+			"orphaned":   report.Orphaned,
+			"orphanRate": report.OrphanRate(),
+			"pruned":     report.Pruned,
+		})
+		return
+	}
+
+	fmt.Printf("Blocks mined by %s: %d\n", address, report.Blocks)
+	fmt.Printf("\tSubsidies: %s\n", units.FormatAmount(report.Subsidy))
+	fmt.Printf("\tFees:      %s\n", units.FormatAmount(report.Fees))
+	fmt.Printf("\tTotal:     %s\n", units.FormatAmount(report.Subsidy+report.Fees))
+	fmt.Printf("\tOrphaned:  %d (%.1f%%)\n", report.Orphaned, 100*report.OrphanRate())
+	if report.Pruned > 0 {
+		fmt.Printf("%d pruned blocks could not be attributed\n", report.Pruned)
+	}
+}
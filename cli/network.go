@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/keys"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// network is the network commands use. It is set by loadNetwork.
+var network = &blockchain.MainNetParams
+
+// globalOptions are the options given before the command.
+type globalOptions struct {
+	// network and wallet are the names of the network and wallets file,
+	// empty if they are not given
+	network string
+	wallet  string
+
+	// config is the path of the config file, empty if it is not given
+	config string
+
+	// json is whether JSON output is asked for
+	json bool
+}
+
+// globalFlags removes the -network, -wallet, -config and -json options
+// given before the command, in any order, from args, returning the
+// remaining args and the options.
+func globalFlags(args []string) (rest []string, options globalOptions, err error) {
+	rest = args
+	for {
+		n := len(rest)
+		var value string
+		var flagged bool
+		if rest, flagged = jsonFlag(rest); flagged {
+			options.json = true
+		}
+		if rest, value, err = networkFlag(rest); err != nil {
+			return nil, globalOptions{}, err
+		} else if value != "" {
+			options.network = value
+		}
+		if rest, value, err = walletFlag(rest); err != nil {
+			return nil, globalOptions{}, err
+		} else if value != "" {
+			if !walletNamePattern.MatchString(value) {
+				return nil, globalOptions{}, fmt.Errorf("wallet %q is not a name of letters, digits, - and _", value)
+			}
+			options.wallet = value
+		}
+		if rest, value, err = valueFlag(rest, "config", "the path of a config file"); err != nil {
+			return nil, globalOptions{}, err
+		} else if value != "" {
+			options.config = value
+		}
+		if len(rest) == n {
+			return rest, options, nil
+		}
+	}
+}
+
+// networkFlag removes the -network NAME option given before the command
+// from args, returning the remaining args and the name, or an empty name
+// if the option is not given.
+func networkFlag(args []string) ([]string, string, error) {
+	return valueFlag(args, "network", "main, test or regtest")
+}
+
+// walletFlag removes the -wallet NAME option given before the command from
+// args, returning the remaining args and the name of the wallets file, or
+// an empty name if the option is not given.
+func walletFlag(args []string) ([]string, string, error) {
+	return valueFlag(args, "wallet", "the name of a wallets file")
+}
+
+// valueFlag removes the -option VALUE option given before the command from
+// args, returning the remaining args and the value, or an empty value if
+// the option is not given. needs describes the value for the error of an
+// option without one.
+func valueFlag(args []string, option, needs string) ([]string, string, error) {
+	if len(args) < 2 {
+		return args, "", nil
+	}
+	given := strings.TrimPrefix(args[1], "-")
+	switch {
+	case strings.HasPrefix(given, "-"+option+"="), strings.HasPrefix(given, option+"="):
+		value := given[strings.Index(given, "=")+1:]
+		return append([]string{args[0]}, args[2:]...), value, nil
+	case given == "-"+option || given == option:
+		if len(args) < 3 {
+			return nil, "", fmt.Errorf("-%s needs %s", option, needs)
+		}
+		return append([]string{args[0]}, args[3:]...), args[2], nil
+	}
+	return args, "", nil
+}
+
+// jsonFlag removes the -json option given before the command from args,
+// returning the remaining args and whether it was given.
+func jsonFlag(args []string) ([]string, bool) {
+	if len(args) < 2 || strings.TrimPrefix(args[1], "-") != "-json" && strings.TrimPrefix(args[1], "-") != "json" {
+		return args, false
+	}
+	return append([]string{args[0]}, args[2:]...), true
+}
+
+// loadNetwork selects the network called name, or that of the
+// configuration if name is empty. Wallets create addresses and keys of the
+// network, whose signature scheme and address prefix may be set by the
+// genesis file.
+func loadNetwork(name string) error {
+	if name == "" {
+		name = config.Network
+	}
+	params, err := blockchain.NetworkByName(name)
+	if err != nil {
+		return err
+	}
+	genesis, err := blockchain.LoadGenesis(os.Getenv("GENESIS_FILE"))
+	if err != nil {
+		return err
+	}
+	cfg := blockchain.Config{Network: params, Genesis: genesis}
+	scheme, err := cfg.SignatureScheme()
+	if err != nil {
+		return err
+	}
+	version, err := cfg.AddressVersion()
+	if err != nil {
+		return err
+	}
+	network = params
+	keys.AddressVersion = version
+	wallet.DefaultScheme = scheme
+	return nil
+}
+
+// dbPath returns the database of the network at the configured path.
+func dbPath() string {
+	return config.DBPath + network.PathSuffix
+}
+
+// walletsPath returns the wallets file of the network selected by -wallet
+// or the configuration.
+func walletsPath() string {
+	return namedWalletsPath(config.Wallet)
+}
+
+// namedWalletsPath returns the wallets file of the network called name. The
+// default wallets file is at the configured path, and others are next to it
+// with their name added before its extension, such as
+// ./data/wallets-savings.data.
+func namedWalletsPath(name string) string {
+	if name == "" || name == defaultWallet {
+		return config.WalletsFile + network.PathSuffix
+	}
+	ext := filepath.Ext(config.WalletsFile)
+	return strings.TrimSuffix(config.WalletsFile, ext) + "-" + name + ext + network.PathSuffix
+}
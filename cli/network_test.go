@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNetworkFlag(t *testing.T) {
+	for _, c := range []struct {
+		args    []string
+		want    []string
+		network string
+	}{
+		{[]string{"gochain", "getbal", "-address", "A"}, []string{"gochain", "getbal", "-address", "A"}, ""},
+		{[]string{"gochain", "-network", "regtest", "mine"}, []string{"gochain", "mine"}, "regtest"},
+		{[]string{"gochain", "--network=test", "mine"}, []string{"gochain", "mine"}, "test"},
+		{[]string{"gochain"}, []string{"gochain"}, ""},
+	} {
+		args, network, err := networkFlag(c.args)
+		if err != nil || !reflect.DeepEqual(args, c.want) || network != c.network {
+			t.Errorf("%q: got %q, %q, %v, want %q, %q", c.args, args, network, err, c.want, c.network)
+		}
+	}
+
+	if _, _, err := networkFlag([]string{"gochain", "-network"}); err == nil {
+		t.Error("-network without a name was accepted")
+	}
+}
+
+func TestJSONFlag(t *testing.T) {
+	for _, c := range []struct {
+		args   []string
+		want   []string
+		asJSON bool
+	}{
+		{[]string{"gochain", "getbal", "-json"}, []string{"gochain", "getbal", "-json"}, false},
+		{[]string{"gochain", "-json", "listaddresses"}, []string{"gochain", "listaddresses"}, true},
+		{[]string{"gochain", "--json", "print"}, []string{"gochain", "print"}, true},
+		{[]string{"gochain"}, []string{"gochain"}, false},
+	} {
+		args, asJSON := jsonFlag(c.args)
+		if !reflect.DeepEqual(args, c.want) || asJSON != c.asJSON {
+			t.Errorf("%q: got %q, %v, want %q, %v", c.args, args, asJSON, c.want, c.asJSON)
+		}
+	}
+}
+
+func TestGlobalFlags(t *testing.T) {
+	args, options, err := globalFlags([]string{"gochain", "-wallet", "hot", "-json", "--network=regtest", "-config", "node.toml", "listaddresses", "-json"})
+	want := []string{"gochain", "listaddresses", "-json"}
+	if err != nil || !reflect.DeepEqual(args, want) || options != (globalOptions{network: "regtest", wallet: "hot", config: "node.toml", json: true}) {
+		t.Fatalf("got %q, %+v, %v", args, options, err)
+	}
+
+	for _, args := range [][]string{{"gochain", "-wallet"}, {"gochain", "-wallet", "../hot", "mine"}, {"gochain", "-config"}} {
+		if _, _, err := globalFlags(args); err == nil {
+			t.Errorf("%q was accepted", args)
+		}
+	}
+}
+
+func TestNamedWalletsPath(t *testing.T) {
+	defer func(cfg Config) { config = cfg }(config)
+	config.WalletsFile = "./data/wallets.data"
+
+	for name, want := range map[string]string{
+		"":        "./data/wallets.data",
+		"default": "./data/wallets.data",
+		"savings": "./data/wallets-savings.data",
+	} {
+		if got := namedWalletsPath(name); got != want {
+			t.Errorf("wallets file %q is at %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// paymentFlags collects the -to flags of send, which are either a single
+// address paid -amount, or ADDRESS:AMOUNT pairs.
+type paymentFlags []string
+
+// String returns the flags separated by commas.
+func (f *paymentFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set adds a -to flag.
+func (f *paymentFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// payments returns the payments of the flags. A single address without an
+// amount is paid amount, otherwise every flag must be ADDRESS:AMOUNT and
+// amount must be empty.
+func (f paymentFlags) payments(amount string) ([]blockchain.Payment, error) {
+	if len(f) == 0 {
+		return nil, errors.New("no destination address given")
+	}
+
+	// a single destination paid -amount
+	if amount != "" {
+		if len(f) != 1 || strings.Contains(f[0], ":") {
+			return nil, errors.New("-amount can only be used with a single -to address")
+		}
+		value, err := units.ParseAmount(amount)
+		if err != nil {
+			return nil, err
+		}
+		if value <= 0 {
+			return nil, errors.New("amount must be positive")
+		}
+		return []blockchain.Payment{{To: f[0], Amount: value}}, nil
+	}
+
+	// pairs of addresses and amounts
+	var payments []blockchain.Payment
+	for _, pair := range f {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("destination %q is not ADDRESS:AMOUNT", pair)
+		}
+		value, err := units.ParseAmount(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("destination %q has an invalid amount - %s", pair, err.Error())
+		}
+		if value <= 0 {
+			return nil, fmt.Errorf("destination %q has an amount that is not positive", pair)
+		}
+		payments = append(payments, blockchain.Payment{To: parts[0], Amount: value})
+	}
+	return payments, nil
+}
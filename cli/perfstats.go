@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+)
+
+// perfStats prints percentiles of the validation time, size and
+// transaction count of the blocks connected by this node, in windows of
+// window blocks by height, or over every block if window is 0.
+func (cli *CLI) perfStats(window int, asJSON bool) {
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	metrics, err := bc.BlockMetrics()
+	if err != nil {
+		log.Panicln("Unable to read block metrics: ", err.Error())
+	}
+	stats := blockchain.SummarizeMetrics(metrics, window)
+	if asJSON {
+		printJSON(stats)
+		return
+	}
+
+	if len(stats) == 0 {
+		fmt.Println("No block metrics recorded")
+		return
+	}
+	for _, s := range stats {
+		fmt.Printf("Heights %d to %d, %d blocks\n", s.FromHeight, s.ToHeight, s.Blocks)
+		fmt.Printf("\tValidation:   p50 %s, p90 %s, p99 %s, max %s\n",
+			duration(s.Validation.P50), duration(s.Validation.P90), duration(s.Validation.P99), duration(s.Validation.Max))
+		fmt.Printf("\tSize:         p50 %.0f, p90 %.0f, p99 %.0f, max %.0f bytes\n",
+			s.Size.P50, s.Size.P90, s.Size.P99, s.Size.Max)
+		fmt.Printf("\tTransactions: p50 %.0f, p90 %.0f, p99 %.0f, max %.0f\n",
+			s.Transactions.P50, s.Transactions.P90, s.Transactions.P99, s.Transactions.Max)
+	}
+}
+
+// duration formats nanoseconds as a duration rounded to microseconds.
+func duration(nanoseconds float64) time.Duration {
+	return time.Duration(nanoseconds).Round(time.Microsecond)
+}
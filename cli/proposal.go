@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// propose creates an unsigned send and saves it to a file so it can be
+// approved by the holder of the from address's key.
+func (cli *CLI) propose(from, to string, amount, fee units.Amount, file string) {
+	if !wallet.ValidateAddress(to) {
+		log.Panicln("Unable to propose transaction: to address not valid")
+	}
+	if !wallet.ValidateAddress(from) {
+		log.Panicln("Unable to propose transaction: from address not valid")
+	}
+	bc := openBlockChain(from)
+	defer bc.Close()
+
+	p, err := bc.ProposeTransaction(from, to, amount, fee)
+	if err != nil {
+		log.Panicln("Unable to propose transaction: ", err.Error())
+	}
+	if err := ioutil.WriteFile(file, p.Serialize(), 0644); err != nil {
+		log.Panicln("Unable to write proposal file: ", err.Error())
+	}
+	fmt.Printf("Proposal saved to %s\n", file)
+}
+
+// approve signs a proposed send with the wallet for its from address and
+// saves the signed proposal to a file. The blockchain is not needed, so
+// proposals can be approved on a machine that holds only the wallet.
+func (cli *CLI) approve(in, out string) {
+	p := readProposal(in)
+
+	w, err := walletStore().Get(p.From)
+	if err != nil {
+		log.Panicln("Unable to approve proposal: ", err.Error())
+	}
+
+	// print what is being approved
+	fmt.Printf("Approving send from %s with fee %s:\n", p.From, units.FormatAmount(p.Fee()))
+	for _, output := range p.Tx.Outputs {
+		fmt.Printf("\t%s to %s\n", units.FormatAmount(output.Value), output.Address())
+	}
+
+	if err := p.Approve(w); err != nil {
+		log.Panicln("Unable to approve proposal: ", err.Error())
+	}
+	if err := ioutil.WriteFile(out, p.Serialize(), 0644); err != nil {
+		log.Panicln("Unable to write proposal file: ", err.Error())
+	}
+	fmt.Printf("Approved transaction %x saved to %s\n", p.Tx.ID, out)
+}
+
+// submit adds an approved proposal to the mempool, mining it unless queue
+// is set.
+func (cli *CLI) submit(in string, queue bool) {
+	p := readProposal(in)
+	if p.Tx.ID == nil {
+		log.Panicln("Unable to submit proposal: proposal has not been approved")
+	}
+	bc := openBlockChain(p.From)
+	defer bc.Close()
+
+	if err := bc.AddToMempool(&p.Tx); err != nil {
+		log.Panicln("Unable to add transaction to mempool: ", err.Error())
+	}
+
+	// leave the transaction pending if it was queued, otherwise
+	// mine it rewarding the sender as the miner
+	if queue {
+		fmt.Printf("Transaction %x added to mempool\n", p.Tx.ID)
+		return
+	}
+	if !cli.mineSent(bc, p.From, p.Tx.ID) {
+		return
+	}
+	fmt.Println("Success!")
+}
+
+// readProposal reads a proposal from a file.
+func readProposal(file string) *blockchain.Proposal {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Panicln("Unable to read proposal file: ", err.Error())
+	}
+	p, err := blockchain.DeserializeProposal(data)
+	if err != nil {
+		log.Panicln("Unable to read proposal file: ", err.Error())
+	}
+	return p
+}
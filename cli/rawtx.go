@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// createRawTx creates an unsigned transaction spending the given outputs,
+// written as TXID:VOUT separated by commas, to the given outputs, written
+// as ADDRESS:AMOUNT separated by commas, and prints it as hex.
+func (cli *CLI) createRawTx(inputs, outputs string) {
+	var txInputs []blockchain.TxInput
+	var txOutputs []blockchain.TxOutput
+
+	// parse the outputs to spend
+	for _, input := range strings.Split(inputs, ",") {
+		parts := strings.Split(strings.TrimSpace(input), ":")
+		if len(parts) != 2 {
+			log.Panicf("Unable to parse input %q: expected TXID:VOUT", input)
+		}
+		txID, err := blockchain.ParseHash(parts[0])
+		if err != nil {
+			log.Panicf("Unable to parse input %q: %s", input, err.Error())
+		}
+		vout, err := strconv.Atoi(parts[1])
+		if err != nil {
+			log.Panicf("Unable to parse input %q: %s", input, err.Error())
+		}
+		txInputs = append(txInputs, blockchain.TxInput{ID: txID.Bytes(), Out: vout})
+	}
+
+	// parse the outputs to create
+	for _, output := range strings.Split(outputs, ",") {
+		parts := strings.Split(strings.TrimSpace(output), ":")
+		if len(parts) != 2 || !wallet.ValidateAddress(parts[0]) {
+			log.Panicf("Unable to parse output %q: expected a valid ADDRESS:AMOUNT", output)
+		}
+		amount, err := units.ParseAmount(parts[1])
+		if err != nil {
+			log.Panicf("Unable to parse output %q: %s", output, err.Error())
+		}
+		txOutputs = append(txOutputs, *blockchain.NewTXOutput(amount, parts[0]))
+	}
+
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	r, err := bc.CreateRawTransaction(txInputs, txOutputs)
+	if err != nil {
+		log.Panicln("Unable to create raw transaction: ", err.Error())
+	}
+
+	// print the fee to stderr so the hex can be piped
+	fee, err := r.Fee()
+	if err != nil {
+		log.Panicln("Unable to create raw transaction: ", err.Error())
+	}
+	fmt.Fprintf(os.Stderr, "Fee: %s\n", units.FormatAmount(fee))
+	fmt.Println(r.Hex())
+}
+
+// signRawTx signs a raw transaction with the wallets in the wallets file
+// and prints the signed transaction as hex. The blockchain is not needed,
+// so transactions can be signed on a machine that holds only the wallets.
+func (cli *CLI) signRawTx(rawHex string) {
+	r, err := blockchain.DecodeRawTransaction(rawHex)
+	if err != nil {
+		log.Panicln("Unable to read raw transaction: ", err.Error())
+	}
+	wallets, err := walletStore().Wallets()
+	if err != nil {
+		log.Panicln("Unable to load wallets: ", err.Error())
+	}
+
+	// print what is being signed to stderr so the hex can be piped
+	fee, err := r.Fee()
+	if err != nil {
+		log.Panicln("Unable to sign raw transaction: ", err.Error())
+	}
+	fmt.Fprintf(os.Stderr, "Signing transaction with fee %s:\n", units.FormatAmount(fee))
+	for _, output := range r.Tx.Outputs {
+		fmt.Fprintf(os.Stderr, "\t%s to %s\n", units.FormatAmount(output.Value), output.Address())
+	}
+
+	if err := r.Sign(wallet.Keys(wallets)); err != nil {
+		log.Panicln("Unable to sign raw transaction: ", err.Error())
+	}
+	fmt.Println(r.Hex())
+}
+
+// sendRawTx adds a signed raw transaction to the mempool, mining it unless
+// queue is set.
+func (cli *CLI) sendRawTx(rawHex string, queue bool) {
+	r, err := blockchain.DecodeRawTransaction(rawHex)
+	if err != nil {
+		log.Panicln("Unable to read raw transaction: ", err.Error())
+	}
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	if err := bc.SendRawTransaction(r); err != nil {
+		log.Panicln("Unable to send raw transaction: ", err.Error())
+	}
+
+	// leave the transaction pending if it was queued, otherwise mine it
+	// rewarding the owner of the first input as the miner
+	if queue {
+		fmt.Printf("Transaction %x added to mempool\n", r.Tx.ID)
+		return
+	}
+	if !cli.mineSent(bc, r.Tx.Inputs[0].Address(), r.Tx.ID) {
+		return
+	}
+	fmt.Printf("Transaction %x mined\n", r.Tx.ID)
+}
+
+// testMempoolAccept runs a signed raw transaction through the checks of the
+// mempool without adding it, printing whether it would be accepted or the
+// rule it breaks.
+func (cli *CLI) testMempoolAccept(rawHex string, asJSON bool) {
+	r, err := blockchain.DecodeRawTransaction(rawHex)
+	if err != nil {
+		log.Panicln("Unable to read raw transaction: ", err.Error())
+	}
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	result := bc.TestRawTransaction(r)
+	if asJSON {
+		printJSON(result)
+		return
+	}
+	if result.Allowed {
+		fmt.Printf("Transaction %s would be accepted with fee %s\n", result.TxID, units.FormatAmount(result.Fee))
+		return
+	}
+	fmt.Printf("Transaction %s would be rejected by rule %s: %s\n", result.TxID, result.Rule, result.Reason)
+}
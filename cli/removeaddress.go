@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// removeAddress removes the wallet for an address from the wallets file
+// after asking to confirm unless yes is set. If archive is given the wallet
+// is first saved there, encrypted with a passphrase read from stdin, and
+// read back to check it can be restored. Wallets still holding coins are
+// only removed if force is set.
+func (cli *CLI) removeAddress(address, archive string, force, yes bool) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to remove address: address not valid")
+	}
+	store := walletStore()
+	w, err := store.Get(address)
+	if err != nil {
+		log.Panicln("Unable to remove address: ", err.Error())
+	}
+
+	// refuse to drop the key of coins unless told to
+	held, err := heldValue(address)
+	if err != nil {
+		log.Panicln("Unable to remove address: ", err.Error())
+	}
+	if held > 0 && !force {
+		log.Panicf("Unable to remove address: %s still holds %s, use -force to remove it anyway", address, units.FormatAmount(held))
+	}
+
+	if !yes && !confirm(fmt.Sprintf("Remove the wallet for %s from the wallets file? [y/N] ", address)) {
+		fmt.Println("Wallet not removed")
+		return
+	}
+
+	if archive != "" {
+		if err := archiveWallet(w, archive, readPassphrase("Archive passphrase: ")); err != nil {
+			log.Panicln("Unable to archive wallet: ", err.Error())
+		}
+	}
+
+	if err := store.Delete(address); err != nil {
+		log.Panicln("Unable to remove address: ", err.Error())
+	}
+
+	if cli.jsonOutput {
+		printJSON(map[string]interface{}{"address": address, "archive": archive, "held": held})
+		return
+	}
+	if archive != "" {
+		fmt.Printf("Removed %s from the wallets file, archived to %s\n", address, archive)
+		return
+	}
+	fmt.Printf("Removed %s from the wallets file\n", address)
+}
+
+// heldValue returns the value of the unspent outputs of an address,
+// including those of pending transactions, or zero if there is no
+// blockchain yet.
+func heldValue(address string) (units.Amount, error) {
+	bc, err := blockchain.Open(blockChainConfig(""))
+	if err == blockchain.ErrNoBlockChain {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.New("unable to open blockchain - " + err.Error())
+	}
+	defer bc.Close()
+
+	outs, err := bc.FindUnspentTxOutputs(pubKeyHashFromAddress(address))
+	if err != nil {
+		return 0, err
+	}
+	var total units.Amount
+	for _, out := range outs {
+		total += out.Value
+	}
+	return total, nil
+}
+
+// archiveWallet saves w to a new file at path readable only by its owner,
+// encrypted with passphrase, and reads it back to check it restores the
+// wallet before the wallet is removed. An existing file is never
+// overwritten.
+func archiveWallet(w *wallet.Wallet, path, passphrase string) error {
+	data, err := wallet.Archive(w, passphrase)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// check the archive as written restores the wallet
+	written, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	wallets, err := wallet.OpenBackup(written, passphrase)
+	if err != nil {
+		return errors.New("unable to read back archive - " + err.Error())
+	}
+	address := w.Address().String()
+	if restored, ok := wallets[address]; !ok || restored.Address().String() != address {
+		return fmt.Errorf("archive %s does not restore %s", path, address)
+	}
+	return nil
+}
+
+// confirm prints prompt to stderr and returns whether the line read from
+// stdin answers yes.
+func confirm(prompt string) bool {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestArchiveWallet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.dat")
+	w := wallet.CreateWallet()
+	if err := archiveWallet(w, path, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("archive has mode %v, want 0600", info.Mode().Perm())
+	}
+
+	// an existing archive is never overwritten
+	if err := archiveWallet(wallet.CreateWallet(), path, "correct horse"); err == nil {
+		t.Fatal("archiving over an existing file succeeded")
+	}
+}
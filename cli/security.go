@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// securityIssues returns the dangerous parts of the configuration of a
+// node serving on addr for the chain of cfg and the wallets files at
+// walletsPaths.
+func securityIssues(addr string, cfg blockchain.Config, walletsPaths ...string) []string {
+	var issues []string
+
+	for _, walletsPath := range walletsPaths {
+		if issue := walletPermissionIssue(walletsPath); issue != "" {
+			issues = append(issues, issue)
+		}
+	}
+
+	// keys are only protected by the machine, which others can reach
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return append(issues, fmt.Sprintf("address %s is invalid", addr))
+	}
+	for _, walletsPath := range walletsPaths {
+		if _, err := os.Stat(walletsPath); err == nil && exposed(host) {
+			if encrypted, err := wallet.NewStore(walletsPath).Encrypted(); err == nil && !encrypted {
+				issues = append(issues, fmt.Sprintf("the node listens on %s beyond this machine while the wallets file %s is not encrypted", addr, walletsPath))
+			}
+		}
+	}
+
+	// main network nodes and miners would connect to a test network
+	mainNetPort := blockchain.MainNetParams.Port
+	if port == mainNetPort && cfg.DifficultyRules().AllowMinDifficulty {
+		issues = append(issues, fmt.Sprintf("network %s allows minimum difficulty blocks but listens on the main network port %s", cfg.NetworkName(), mainNetPort))
+	}
+
+	return issues
+}
+
+// walletPermissionIssue returns why the wallets file at path can be read
+// by other users, or an empty string if it can't or does not exist.
+func walletPermissionIssue(path string) string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return fmt.Sprintf("wallets file %s can be read by other users (mode %04o), run chmod 600 on it", path, info.Mode().Perm())
+	}
+	return ""
+}
+
+// exposed returns whether listening on host accepts connections from
+// other machines.
+func exposed(host string) bool {
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
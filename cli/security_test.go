@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestSecurityIssues(t *testing.T) {
+	mainNet := blockchain.Config{}
+	testNet := blockchain.Config{Network: &blockchain.TestNetParams}
+	testGenesis := blockchain.Config{Genesis: &blockchain.Genesis{Network: "private", AllowMinDifficulty: true}}
+	for _, c := range []struct {
+		name      string
+		addr      string
+		cfg       blockchain.Config
+		mode      os.FileMode
+		encrypted bool
+		want      []string
+	}{
+		{"safe", "localhost:8546", mainNet, 0600, false, nil},
+		{"readable wallets", "localhost:8546", mainNet, 0644, false, []string{"can be read by other users"}},
+		{"exposed plain wallets", "0.0.0.0:9000", mainNet, 0600, false, []string{"not encrypted"}},
+		{"exposed on a hostname", "node.example.com:9000", mainNet, 0600, false, []string{"not encrypted"}},
+		{"exposed encrypted wallets", ":9000", mainNet, 0600, true, nil},
+		{"loopback plain wallets", "127.0.0.1:9000", mainNet, 0600, false, nil},
+		{"test network on main port", "localhost:8546", testNet, 0600, false, []string{"main network port"}},
+		{"test network on own port", "localhost:18546", testNet, 0600, false, nil},
+		{"test genesis on main port", "localhost:8546", testGenesis, 0600, false, []string{"network private"}},
+		{"everything", "[::]:8546", testNet, 0640, false, []string{"other users", "not encrypted", "main network port"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "wallets.dat")
+			store := wallet.NewStore(path)
+			if err := store.Save(map[string]*wallet.Wallet{}); err != nil {
+				t.Fatal(err)
+			}
+			if c.encrypted {
+				if err := store.Encrypt("passphrase"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.Chmod(path, c.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			issues := securityIssues(c.addr, c.cfg, path)
+			if len(issues) != len(c.want) {
+				t.Fatalf("got issues %q, want %q", issues, c.want)
+			}
+			for i := range issues {
+				if !strings.Contains(issues[i], c.want[i]) {
+					t.Errorf("got issue %q, want %q", issues[i], c.want[i])
+				}
+			}
+		})
+	}
+
+	// a missing wallets file holds no keys to protect
+	if issues := securityIssues("0.0.0.0:8546", mainNet, filepath.Join(t.TempDir(), "missing")); len(issues) != 0 {
+		t.Fatalf("got issues %q without a wallets file", issues)
+	}
+}
+
+func TestWalletsFileIsPrivate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.dat")
+	if err := wallet.NewStore(path).Save(map[string]*wallet.Wallet{}); err != nil {
+		t.Fatal(err)
+	}
+	if issue := walletPermissionIssue(path); issue != "" {
+		t.Fatalf("new wallets file has issue %q", issue)
+	}
+}
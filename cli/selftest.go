@@ -0,0 +1,284 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/logging"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// selfTest is a run of selftest against a throwaway regtest chain and
+// wallets file in dir.
+type selfTest struct {
+	dir   string
+	store *wallet.Store
+	bc    *blockchain.BlockChain
+
+	// miner receives the genesis reward and mines, paying alice, who
+	// mines the fork of the reorg, and bob
+	miner, alice, bob *wallet.Wallet
+
+	// want are the confirmed balances expected after each step
+	want map[*wallet.Wallet]units.Amount
+}
+
+// selfTestStep is a named step of selftest, which fails with an error.
+type selfTestStep struct {
+	name string
+	run  func(*selfTest) error
+}
+
+// selfTestSteps are the steps of selftest in order. Each step builds on
+// the state the earlier ones left, so the run stops at the first failure.
+var selfTestSteps = []selfTestStep{
+	{"create wallets", (*selfTest).createWallets},
+	{"create chain", (*selfTest).createChain},
+	{"mine blocks", (*selfTest).mineBlocks},
+	{"send", (*selfTest).send},
+	{"reorg", (*selfTest).reorg},
+	{"mine after reorg", (*selfTest).mineAfterReorg},
+	{"rescan", (*selfTest).rescan},
+	{"verify chain", (*selfTest).verifyChain},
+	{"reopen", (*selfTest).reopen},
+}
+
+// sendAmount and sendFee are the payment from the miner to alice.
+const (
+	sendAmount = 5 * units.Coin
+	sendFee    = units.Coin
+)
+
+// selftest creates wallets, mines, sends, reorgs, rescans and verifies
+// balances against a throwaway regtest chain, printing whether each step
+// passed, so operators can check a build after upgrading. The chain and
+// wallets are removed afterwards unless keep is set.
+func (cli *CLI) selftest(keep bool) {
+	dir, err := ioutil.TempDir("", "gochain-selftest")
+	if err != nil {
+		log.Panicln("Unable to create selftest directory: ", err.Error())
+	}
+	t := &selfTest{dir: dir, want: make(map[*wallet.Wallet]units.Amount)}
+
+	// the wallets sign with the scheme of regtest whatever the network in
+	// use
+	scheme, err := blockchain.Config{Network: &blockchain.RegTestParams}.SignatureScheme()
+	if err != nil {
+		log.Panicln("Unable to run selftest: ", err.Error())
+	}
+	wallet.DefaultScheme = scheme
+	defer func() {
+		if t.bc != nil {
+			t.bc.Close()
+		}
+		if keep {
+			fmt.Printf("Kept selftest data in %s\n", dir)
+		} else {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	// run the steps until one fails
+	passed := 0
+	start := time.Now()
+	for _, step := range selfTestSteps {
+		stepStart := time.Now()
+		if err := step.run(t); err != nil {
+			fmt.Printf("FAIL %-18s %s\n", step.name, err.Error())
+			break
+		}
+		fmt.Printf("PASS %-18s %s\n", step.name, time.Since(stepStart).Round(time.Millisecond))
+		passed++
+	}
+	if passed < len(selfTestSteps) {
+		for _, step := range selfTestSteps[passed+1:] {
+			fmt.Printf("SKIP %s\n", step.name)
+		}
+	}
+
+	fmt.Printf("%d of %d steps passed in %s\n", passed, len(selfTestSteps), time.Since(start).Round(time.Millisecond))
+	if passed < len(selfTestSteps) {
+		log.Panicln("Selftest failed")
+	}
+}
+
+// createWallets creates the wallets of the test in a new wallets file.
+func (t *selfTest) createWallets() error {
+	t.store = wallet.NewStore(filepath.Join(t.dir, "wallets.dat"))
+	for _, w := range []**wallet.Wallet{&t.miner, &t.alice, &t.bob} {
+		created, err := t.store.Create()
+		if err != nil {
+			return err
+		}
+		*w = created
+	}
+
+	addresses, err := t.store.List()
+	if err != nil {
+		return err
+	}
+	if len(addresses) != 3 {
+		return fmt.Errorf("wallets file has %d wallets, want 3", len(addresses))
+	}
+	return nil
+}
+
+// open opens the regtest chain of the test, creating it with the genesis
+// reward paid to the miner.
+func (t *selfTest) open() error {
+	bc, err := blockchain.Open(blockchain.Config{
+		Path:           filepath.Join(t.dir, "blocks"),
+		GenesisAddress: t.miner.Address().String(),
+		Network:        &blockchain.RegTestParams,
+		Logger:         logging.Discard,
+	})
+	if err != nil {
+		return err
+	}
+	t.bc = bc
+	return nil
+}
+
+// createChain creates the chain of the test.
+func (t *selfTest) createChain() error {
+	if err := t.open(); err != nil {
+		return err
+	}
+	t.want[t.miner] = blockchain.Subsidy
+	return t.check(0)
+}
+
+// mineBlocks mines two empty blocks for the miner.
+func (t *selfTest) mineBlocks() error {
+	for i := 0; i < 2; i++ {
+		if _, err := t.bc.MinePending(t.miner.Address().String()); err != nil {
+			return err
+		}
+	}
+	t.want[t.miner] += 2 * blockchain.Subsidy
+	return t.check(2)
+}
+
+// send pays alice from the miner in a block mined by bob, who collects the
+// fee.
+func (t *selfTest) send() error {
+	tx, err := t.bc.NewTransaction(t.miner, t.alice.Address().String(), sendAmount, sendFee, "")
+	if err != nil {
+		return err
+	}
+	if err := t.bc.AddToMempool(tx); err != nil {
+		return err
+	}
+	if _, err := t.bc.MinePending(t.bob.Address().String()); err != nil {
+		return err
+	}
+
+	t.want[t.miner] -= sendAmount + sendFee
+	t.want[t.alice] += sendAmount
+	t.want[t.bob] += blockchain.Subsidy + sendFee
+	return t.check(3)
+}
+
+// reorg replaces the block of the payment with a longer fork mined by
+// alice, which returns the payment to the mempool.
+func (t *selfTest) reorg() error {
+	parent, err := t.bc.GetBlockByHeight(2)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		coinbase := blockchain.CoinbaseTx(t.alice.Address().String(), fmt.Sprintf("selftest fork %d", i), 0)
+		difficulty := t.bc.NextDifficulty(parent, time.Now().Unix())
+		block := blockchain.CreateBlock([]*blockchain.Transaction{coinbase}, parent.Hash, parent.Height+1, difficulty)
+		if err := t.bc.AcceptBlock(block); err != nil {
+			return err
+		}
+		parent = block
+	}
+
+	if pending := len(t.bc.MempoolTransactions()); pending != 1 {
+		return fmt.Errorf("mempool has %d transactions after the reorg, want the payment", pending)
+	}
+	t.want[t.miner] += sendAmount + sendFee
+	t.want[t.alice] += 2*blockchain.Subsidy - sendAmount
+	t.want[t.bob] = 0
+	return t.check(4)
+}
+
+// mineAfterReorg mines the payment returned to the mempool again.
+func (t *selfTest) mineAfterReorg() error {
+	if _, err := t.bc.MinePending(t.bob.Address().String()); err != nil {
+		return err
+	}
+	t.want[t.miner] -= sendAmount + sendFee
+	t.want[t.alice] += sendAmount
+	t.want[t.bob] += blockchain.Subsidy + sendFee
+	return t.check(5)
+}
+
+// rescan rebuilds the UTXO set and address index from the blocks.
+func (t *selfTest) rescan() error {
+	if err := t.bc.ReindexUTXO(); err != nil {
+		return err
+	}
+	if err := t.bc.ReindexAddresses(); err != nil {
+		return err
+	}
+	return t.check(5)
+}
+
+// verifyChain verifies every block and signature of the chain.
+func (t *selfTest) verifyChain() error {
+	verified, err := t.bc.VerifyChain(0)
+	if err != nil {
+		return err
+	}
+	if verified != t.bc.Height()+1 {
+		return fmt.Errorf("verified %d blocks, want %d", verified, t.bc.Height()+1)
+	}
+	return nil
+}
+
+// reopen closes and opens the chain again.
+func (t *selfTest) reopen() error {
+	if err := t.bc.Close(); err != nil {
+		t.bc = nil
+		return err
+	}
+	t.bc = nil
+	if err := t.open(); err != nil {
+		return err
+	}
+	return t.check(5)
+}
+
+// check returns an error if the chain is not at height or a wallet does
+// not have its expected confirmed balance.
+func (t *selfTest) check(height int) error {
+	if got := t.bc.Height(); got != height {
+		return fmt.Errorf("chain has height %d, want %d", got, height)
+	}
+
+	s, err := t.bc.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer s.Discard()
+	for _, w := range []*wallet.Wallet{t.miner, t.alice, t.bob} {
+		balance, err := s.Balance(wallet.GeneratePublicKeyHash(w.PublicKey))
+		if err != nil {
+			return err
+		}
+		if balance != t.want[w] {
+			return fmt.Errorf("%s has %s, want %s", w.Address(),
+				units.FormatAmount(balance), units.FormatAmount(t.want[w]))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestSelfTestSteps(t *testing.T) {
+	st := &selfTest{dir: t.TempDir(), want: make(map[*wallet.Wallet]units.Amount)}
+	defer func() {
+		if st.bc != nil {
+			st.bc.Close()
+		}
+	}()
+
+	for _, step := range selfTestSteps {
+		if err := step.run(st); err != nil {
+			t.Fatalf("%s: %s", step.name, err)
+		}
+	}
+}
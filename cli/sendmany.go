@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// sendManyBatch is the most payments packed into one transaction by
+// sendmany, leaving an output for the change.
+const sendManyBatch = blockchain.MaxTxOutputs - 1
+
+// payout is an entry of a sendmany file.
+type payout struct {
+	Address string       `json:"address"`
+	Amount  units.Amount `json:"amount"`
+}
+
+// payoutResult reports the payment of a recipient of sendmany, with the
+// transaction paying it or the reason it wasn't paid.
+type payoutResult struct {
+	Address string       `json:"address"`
+	Amount  units.Amount `json:"amount"`
+	TxID    string       `json:"txid,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// readPayouts reads the payouts of a sendmany file, a JSON array of
+// objects with an address and an amount if it ends in .json, and otherwise
+// CSV rows of ADDRESS,AMOUNT with an optional header. Amounts are coin
+// strings, or numbers of base units in JSON.
+func readPayouts(path string) ([]payout, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var payouts []payout
+		if err := json.Unmarshal(data, &payouts); err != nil {
+			return nil, errors.New("unable to decode payouts - " + err.Error())
+		}
+		return payouts, nil
+	}
+
+	var payouts []payout
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	for row := 1; ; row++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.New("unable to read payouts - " + err.Error())
+		}
+		amount, err := units.ParseAmount(record[1])
+		if err != nil {
+			// the first row may name the columns
+			if row == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("row %d has an invalid amount - %s", row, err.Error())
+		}
+		payouts = append(payouts, payout{Address: strings.TrimSpace(record[0]), Amount: amount})
+	}
+	return payouts, nil
+}
+
+// validatePayouts returns the payments of payouts, resolving the labels
+// and contact names given in place of addresses with resolve, or an error
+// listing every invalid entry.
+func validatePayouts(payouts []payout, resolve func(name string) (string, error)) ([]blockchain.Payment, error) {
+	if len(payouts) == 0 {
+		return nil, errors.New("no payouts given")
+	}
+	var payments []blockchain.Payment
+	var problems []string
+	for i, p := range payouts {
+		address := resolveAddress(p.Address, resolve)
+		switch {
+		case !wallet.ValidateAddress(address):
+			problems = append(problems, fmt.Sprintf("entry %d: address %q not valid", i+1, p.Address))
+		case p.Amount <= 0:
+			problems = append(problems, fmt.Sprintf("entry %d: amount %s is not positive", i+1, units.FormatAmount(p.Amount)))
+		}
+		payments = append(payments, blockchain.Payment{To: address, Amount: p.Amount})
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "\n"))
+	}
+	return payments, nil
+}
+
+// packPayments creates transactions from the wallet w paying payments,
+// packing as many into each as fit the transaction limits and sending the
+// change back to w, and adds them to the mempool. It returns the result of
+// each payment, in order. Once a transaction fails the payments after it
+// are still tried, as a later batch may need fewer coins.
+func packPayments(bc *blockchain.BlockChain, w *wallet.Wallet, payments []blockchain.Payment, fee units.Amount) []payoutResult {
+	results := make([]payoutResult, len(payments))
+	for i, p := range payments {
+		results[i] = payoutResult{Address: p.To, Amount: p.Amount}
+	}
+
+	for start := 0; start < len(payments); {
+		end := start + sendManyBatch
+		if end > len(payments) {
+			end = len(payments)
+		}
+
+		// halve the batch until its transaction fits
+		var tx *blockchain.Transaction
+		var err error
+		for {
+			tx, err = bc.NewPaymentTransaction(w, payments[start:end], fee, "")
+			if err != nil || len(tx.Serialize()) <= blockchain.MaxTxSize || end-start == 1 {
+				break
+			}
+			end = start + (end-start)/2
+		}
+		if err == nil {
+			err = bc.AddToMempool(tx)
+		}
+
+		for i := start; i < end; i++ {
+			if err != nil {
+				results[i].Error = err.Error()
+			} else {
+				results[i].TxID = hex.EncodeToString(tx.ID)
+			}
+		}
+		start = end
+	}
+	return results
+}
+
+// sendMany pays every entry of a sendmany file from an address, in as few
+// transactions as fit, paying fee for each. Every entry is checked before
+// anything is sent. The transactions are mined in one block unless queue
+// is set.
+func (cli *CLI) sendMany(from, path string, fee units.Amount, queue bool) {
+	if !wallet.ValidateAddress(from) {
+		log.Panicln("Unable to send payouts: from address not valid")
+	}
+	payouts, err := readPayouts(path)
+	if err != nil {
+		log.Panicln("Unable to read payouts: ", err.Error())
+	}
+	payments, err := validatePayouts(payouts, func(name string) (string, error) {
+		return walletStore().Resolve(name)
+	})
+	if err != nil {
+		log.Panicf("Unable to send payouts:\n%s", err.Error())
+	}
+	w, err := walletStore().Get(from)
+	if err != nil {
+		log.Panicln("Unable to load wallet: ", err.Error())
+	}
+	bc := openBlockChain(from)
+	defer bc.Close()
+
+	results := packPayments(bc, w, payments, fee)
+
+	// mine the transactions sent, unless they were queued
+	var sent [][]byte
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		} else if len(sent) == 0 || hex.EncodeToString(sent[len(sent)-1]) != r.TxID {
+			txID, _ := hex.DecodeString(r.TxID)
+			sent = append(sent, txID)
+		}
+	}
+	if len(sent) > 0 && !queue {
+		cli.mineSent(bc, from, sent[len(sent)-1])
+	}
+
+	if cli.jsonOutput {
+		printJSON(results)
+	} else {
+		for _, r := range results {
+			if r.Error != "" {
+				fmt.Printf("%s\t%s\tfailed: %s\n", r.Address, units.FormatAmount(r.Amount), r.Error)
+				continue
+			}
+			fmt.Printf("%s\t%s\t%s\n", r.Address, units.FormatAmount(r.Amount), r.TxID)
+		}
+		fmt.Printf("Paid %d of %d recipients in %d transactions\n", len(results)-failed, len(results), len(sent))
+		if queue && len(sent) > 0 {
+			fmt.Println("The transactions were added to the mempool")
+		}
+	}
+	if failed > 0 {
+		log.Panicf("Unable to pay %d of %d recipients", failed, len(results))
+	}
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestReadPayouts(t *testing.T) {
+	dir := t.TempDir()
+	bob := wallet.CreateWallet().Address().String()
+	files := map[string]string{
+		"payouts.json": `[{"address": "` + bob + `", "amount": "1.5"}, {"address": "carol", "amount": 200}]`,
+		"payouts.csv":  "address,amount\n# a comment\n" + bob + ",1.5\ncarol, 0.000002\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		payouts, err := readPayouts(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []payout{{bob, units.Coin * 3 / 2}, {"carol", 200}}
+		if len(payouts) != 2 || payouts[0] != want[0] || payouts[1] != want[1] {
+			t.Fatalf("got payouts %+v from %s, want %+v", payouts, name, want)
+		}
+	}
+
+	// names are resolved, and every invalid entry is reported
+	resolve := func(name string) (string, error) { return bob, nil }
+	payments, err := validatePayouts([]payout{{"carol", units.Coin}}, resolve)
+	if err != nil || payments[0].To != bob {
+		t.Fatalf("got payments %+v, %v, want carol resolved", payments, err)
+	}
+	_, err = validatePayouts([]payout{{"nowhere!", units.Coin}, {bob, 0}}, resolve)
+	if err == nil || !strings.Contains(err.Error(), "entry 1") || !strings.Contains(err.Error(), "entry 2") {
+		t.Fatalf("got %v, want both entries reported", err)
+	}
+}
+
+func TestPackPayments(t *testing.T) {
+	from := wallet.CreateWallet()
+	bc, err := blockchain.InitInMemory(&blockchain.Genesis{
+		Network:     "sendmany",
+		Allocations: map[string]units.Amount{from.Address().String(): 1000 * units.Coin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	// more payments than fit one transaction are split, the second
+	// spending the change of the first
+	var payments []blockchain.Payment
+	for i := 0; i < sendManyBatch+10; i++ {
+		payments = append(payments, blockchain.Payment{To: wallet.CreateWallet().Address().String(), Amount: units.Coin})
+	}
+	results := packPayments(bc, from, payments, units.Coin/100)
+	for _, r := range results {
+		if r.Error != "" || r.TxID == "" {
+			t.Fatalf("got result %+v, want every payment sent", r)
+		}
+	}
+	if results[0].TxID == results[len(results)-1].TxID || len(bc.MempoolTransactions()) != 2 {
+		t.Fatalf("got %d pending transactions, want 2", len(bc.MempoolTransactions()))
+	}
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// sendPreview describes a transaction built by send -dry-run, which is
+// signed but neither added to the mempool nor mined.
+type sendPreview struct {
+	TxID    string          `json:"txid"`
+	Inputs  []previewInput  `json:"inputs"`
+	Outputs []previewOutput `json:"outputs"`
+	Change  units.Amount    `json:"change"`
+	Fee     units.Amount    `json:"fee"`
+	Size    int             `json:"size"`
+	Hex     string          `json:"hex"`
+
+	// Allowed is whether the mempool would take the transaction, and
+	// Reason why it wouldn't
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// previewInput is an output selected to be spent by a previewed send.
+type previewInput struct {
+	TxID  string       `json:"txid"`
+	Out   int          `json:"out"`
+	Value units.Amount `json:"value"`
+}
+
+// previewOutput is an output created by a previewed send. Change is set
+// for the output paying the change address.
+type previewOutput struct {
+	Address string       `json:"address"`
+	Value   units.Amount `json:"value"`
+	Change  bool         `json:"change,omitempty"`
+}
+
+// previewSend builds and signs the transaction send would for payments
+// from an address and prints it, without adding it to the mempool or
+// saving its change address.
+func (cli *CLI) previewSend(from string, payments []blockchain.Payment, fee units.Amount) {
+	validateSend(from, payments)
+	bc := openBlockChain(from)
+	defer bc.Close()
+
+	tx, change := cli.buildSend(bc, from, payments, fee)
+	printSendPreview(describeSend(bc, tx, change.Address().String()), cli.jsonOutput)
+}
+
+// describeSend describes a signed transaction paying any change to the
+// change address, looking up the values of the outputs it spends in the
+// UTXO set or the pending transactions of bc.
+func describeSend(bc *blockchain.BlockChain, tx *blockchain.Transaction, change string) sendPreview {
+	preview := sendPreview{
+		TxID: hex.EncodeToString(tx.ID),
+		Size: len(tx.Serialize()),
+		Hex:  hex.EncodeToString(tx.Serialize()),
+	}
+
+	// index the outputs of pending transactions, which change may be
+	// spent from
+	pending := make(map[string]*blockchain.Transaction)
+	for _, p := range bc.MempoolTransactions() {
+		pending[hex.EncodeToString(p.ID)] = p
+	}
+
+	for _, in := range tx.Inputs {
+		out, ok := bc.GetUnspentOutput(in.ID, in.Out)
+		if p, found := pending[hex.EncodeToString(in.ID)]; !ok && found && in.Out < len(p.Outputs) {
+			out = p.Outputs[in.Out]
+		}
+		preview.Inputs = append(preview.Inputs, previewInput{hex.EncodeToString(in.ID), in.Out, out.Value})
+		preview.Fee += out.Value
+	}
+	for _, out := range tx.Outputs {
+		isChange := out.Address() == change
+		preview.Outputs = append(preview.Outputs, previewOutput{out.Address(), out.Value, isChange})
+		if isChange {
+			preview.Change += out.Value
+		}
+		preview.Fee -= out.Value
+	}
+
+	result := bc.TestMempoolAccept(tx)
+	preview.Allowed, preview.Reason = result.Allowed, result.Reason
+	return preview
+}
+
+// validateSend ensures the addresses of a send are valid.
+func validateSend(from string, payments []blockchain.Payment) {
+	for _, payment := range payments {
+		if !wallet.ValidateAddress(payment.To) {
+			log.Panicf("Unable to initiate send transaction: to address %s not valid", payment.To)
+		}
+	}
+	if !wallet.ValidateAddress(from) {
+		log.Panicln("Unable to initiate send transaction: from address not valid")
+	}
+}
+
+// buildSend creates and signs a transaction for payments from an address,
+// sending any change to a fresh key that is returned unsaved.
+func (cli *CLI) buildSend(bc *blockchain.BlockChain, from string, payments []blockchain.Payment, fee units.Amount) (*blockchain.Transaction, *wallet.Wallet) {
+	w, err := walletStore().Get(from)
+	if err != nil {
+		log.Panicln("Unable to load wallet: ", err.Error())
+	}
+	change := wallet.CreateWallet()
+	tx, err := bc.NewPaymentTransaction(w, payments, fee, change.Address().String())
+	if err != nil {
+		log.Panicln("Unable to create transaction: ", err.Error())
+	}
+	return tx, change
+}
+
+// printSendPreview prints a previewed send, as JSON if asJSON is set.
+func printSendPreview(preview sendPreview, asJSON bool) {
+	if asJSON {
+		printJSON(preview)
+		return
+	}
+	fmt.Println("Dry run, the transaction was not sent")
+	fmt.Printf("Transaction: %s (%d bytes)\n", preview.TxID, preview.Size)
+	fmt.Println("Inputs:")
+	for _, in := range preview.Inputs {
+		fmt.Printf("\t%s:%d\t%s\n", in.TxID, in.Out, units.FormatAmount(in.Value))
+	}
+	fmt.Println("Outputs:")
+	for _, out := range preview.Outputs {
+		if out.Change {
+			fmt.Printf("\t%s to %s (change)\n", units.FormatAmount(out.Value), out.Address)
+			continue
+		}
+		fmt.Printf("\t%s to %s\n", units.FormatAmount(out.Value), out.Address)
+	}
+	fmt.Printf("Change: %s\n", units.FormatAmount(preview.Change))
+	fmt.Printf("Fee: %s\n", units.FormatAmount(preview.Fee))
+	if !preview.Allowed {
+		fmt.Printf("The mempool would refuse it: %s\n", preview.Reason)
+	}
+}
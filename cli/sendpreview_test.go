@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestDescribeSend(t *testing.T) {
+	from := wallet.CreateWallet()
+	bc, err := blockchain.InitInMemory(&blockchain.Genesis{
+		Network:     "preview",
+		Allocations: map[string]units.Amount{from.Address().String(): 5 * units.Coin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	to := wallet.CreateWallet().Address().String()
+	change := wallet.CreateWallet().Address().String()
+	payments := []blockchain.Payment{{To: to, Amount: 2 * units.Coin}}
+	tx, err := bc.NewPaymentTransaction(from, payments, units.Coin/10, change)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the allocation is spent, paying the change back, and nothing is sent
+	preview := describeSend(bc, tx, change)
+	if len(preview.Inputs) != 1 || preview.Inputs[0].Value != 5*units.Coin {
+		t.Fatalf("got inputs %+v, want the allocation", preview.Inputs)
+	}
+	if preview.Fee != units.Coin/10 || preview.Change != 5*units.Coin-2*units.Coin-units.Coin/10 {
+		t.Fatalf("got fee %s and change %s", units.FormatAmount(preview.Fee), units.FormatAmount(preview.Change))
+	}
+	if !preview.Allowed || len(bc.MempoolTransactions()) != 0 {
+		t.Fatalf("got preview %+v and %d pending transactions", preview, len(bc.MempoolTransactions()))
+	}
+}
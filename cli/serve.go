@@ -0,0 +1,670 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/events"
+	"github.com/edwintcloud/gochain/mining"
+	"github.com/edwintcloud/gochain/wallet"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// node is a blockchain kept open by serve. Requests are handled
+// concurrently, which the chain is safe for, and mutex serializes sends
+// from wallets so a retried request finds the transaction of the first.
+type node struct {
+	mutex sync.Mutex
+	bc    *blockchain.BlockChain
+
+	// token authorizes requests for the wallets files in wallets, keyed by
+	// name, which are not served if neither it nor an API token with the
+	// wallet scope is set
+	token   string
+	wallets map[string]*wallet.Store
+
+	// tokens are the API tokens of the node, which every request needs
+	// once there are some
+	tokens apiTokens
+
+	// limits bound the rate and size of requests
+	limits apiLimits
+
+	// webhooks are posted the events of the chain
+	webhooks *events.Webhooks
+
+	// events are the events of the chain, streamed to gRPC clients
+	events *events.Bus
+
+	// miner mines blocks continuously if the node runs one
+	miner *mining.Miner
+
+	// local is set if the node serves the commands run on its machine on
+	// the socket in the NODE_SOCKET env var, which can use its wallets
+	local bool
+}
+
+// submittedBlock is the body posted to /block, holding a block from
+// getblocktemplate as hex and the nonce it was mined with.
+type submittedBlock struct {
+	Block string `json:"block"`
+	Nonce int    `json:"nonce"`
+}
+
+// serve runs a node on addr that keeps the blockchain open and pushes the
+// blocks, transactions and reorgs of the chain to websocket clients at /ws.
+// Signed raw transactions are posted to /tx as hex, block templates for
+// external miners are fetched from /template?address=ADDRESS, and blocks
+// mined from them or from getblocktemplate are posted to /block. Block
+// headers are synced from /headers?from=HEIGHT&count=N. Balances
+// split into trusted, pending and immature value, with the value pending
+// transactions move in and out, are fetched from
+// /balance?address=ADDRESS&minconf=N, fee estimates from
+// /estimatefee?blocks=N, the mempool fee histogram from /feehistogram, the
+// mempool size and limits from /mempoolinfo, the lowest fee rate it takes
+// from /feefilter, chain statistics from /stats?window=N, the UTXO set
+// summary with its commitment from /txoutsetinfo and fee, difficulty and
+// block interval series for charts from /charts?bucket=DURATION and the
+// confirmations of a transaction from /confirmations?txid=TXID. If
+// minerAddress is set, pending transactions are mined every interval, or
+// if threads is set blocks are mined continuously on that many threads,
+// searching each template for interval at most and abandoning it when a
+// block from elsewhere changes the tip, with the stats of the miner served
+// at /minerstats. Pending
+// transactions older than the MaxAge of the mempool limits are expired
+// every expireInterval. The node runs until the process is asked to
+// shut down, and other commands can't use the database meanwhile, but
+// getbal, send and their waits ask the node instead when the NODE_SOCKET
+// env var sets the unix socket it serves them on, which only the user
+// running it can connect to and which has every scope. If
+// token is set, clients holding it can fetch the wallets of the node from
+// /wallets for importwallet and send from them. The wallets files in
+// wallets are served along with that of the network, called by its name,
+// default unless -wallet selected another, at
+// /wallet/NAME/wallets and /wallet/NAME/send, and /wallets is refused once
+// there are several so clients pick one explicitly. Clients holding it can
+// also list, add and remove deposit watches at /depositwatches, whose URLs
+// are posted deposit events as deposits reach their confirmations and if a
+// reorg takes them out of the chain, and list, add and remove the webhooks
+// posted the events of the chain at /webhooks. Webhooks are also added with
+// the webhooks command and the WEBHOOKS env var, and events posted to them
+// and to deposit watches are signed with the WEBHOOK_SECRET env var. If
+// prioritizeWallets is set, transactions
+// from the wallets file are mined before any other regardless of fee. It
+// refuses to run with a dangerous configuration unless insecure is set. If
+// watchDir is set, signed transaction files dropped in it by offline
+// signers are added to the mempool and moved to its processed or failed
+// subfolder. TLS is served with the certificate in tlsCert and its key in
+// tlsKey, or with a certificate created at startup if tlsSelfSigned is set.
+// Once the API_TOKENS env var sets tokens, every request needs one with the
+// scope of its endpoint: read, write or wallet, which the token of the
+// node has all of. Requests are limited by the API_RATE_LIMIT,
+// API_TOKEN_RATE_LIMIT and API_MAX_BODY_BYTES env vars, and refused if they
+// have query parameters their endpoint doesn't take. If the GRPC_ADDR env
+// var is set, the blocks, transactions, balances and wallets of the node
+// are also served on that address over gRPC, as the Node service of
+// cli/node.proto, with the same TLS, tokens and limits, and blocks
+// connected to the best chain are streamed to clients of SubscribeBlocks.
+func (cli *CLI) serve(addr, minerAddress string, interval time.Duration, token string, wallets walletFlags, prioritizeWallets, insecure bool, watchDir, tlsCert, tlsKey string, tlsSelfSigned bool, threads int) {
+	if minerAddress != "" && !wallet.ValidateAddress(minerAddress) {
+		log.Panicln("Unable to serve: miner address not valid")
+	}
+	if (tlsCert == "") != (tlsKey == "") || (tlsCert != "" && tlsSelfSigned) {
+		log.Panicln("Unable to serve: TLS needs both -tls-cert and -tls-key, or -tls-self-signed")
+	}
+	cfg := blockChainConfig("")
+	stores, paths, err := wallets.stores()
+	if err != nil {
+		log.Panicln("Unable to serve: ", err.Error())
+	}
+	tokens, err := parseAPITokens(os.Getenv("API_TOKENS"))
+	if err != nil {
+		log.Panicln("Unable to parse env var API_TOKENS: ", err.Error())
+	}
+	limits, err := apiLimitsFromEnv()
+	if err != nil {
+		log.Panicln("Unable to serve: ", err.Error())
+	}
+	useTLS := tlsCert != "" || tlsSelfSigned
+	grpcAddr := os.Getenv("GRPC_ADDR")
+
+	// refuse dangerous configurations, or warn about them if asked to
+	issues := securityIssues(addr, cfg, paths...)
+	if issue := tlsIssue(addr, token != "" || len(tokens) > 0, useTLS); issue != "" {
+		issues = append(issues, issue)
+	}
+	if issue := tlsIssue(grpcAddr, token != "" || len(tokens) > 0, useTLS); issue != "" {
+		issues = append(issues, issue)
+	}
+	for _, issue := range issues {
+		logger.Warn("Dangerous configuration", "issue", issue)
+	}
+	if len(issues) > 0 && !insecure {
+		log.Panicln("Unable to serve with a dangerous configuration, fix it or run with -insecure")
+	}
+
+	// mine the transactions of the operator first if asked to
+	if prioritizeWallets {
+		for _, store := range stores {
+			wallets, err := store.Wallets()
+			if err != nil {
+				log.Panicln("Unable to load wallets: ", err.Error())
+			}
+			for address := range wallets {
+				cfg.PriorityKeys = append(cfg.PriorityKeys, pubKeyHashFromAddress(address))
+			}
+		}
+	}
+
+	// open the chain publishing its events on the bus
+	bus := events.NewBus()
+	cfg.Events = bus
+	bc, err := blockchain.Open(cfg)
+	if err != nil {
+		log.Panicf("Unable to open blockchain: %s", err.Error())
+	}
+	defer bc.Close()
+	n := &node{bc: bc, token: token, wallets: stores, tokens: tokens, limits: limits, events: bus, local: nodeSocket() != ""}
+	if len(tokens) > 0 {
+		logger.Info("Requiring API tokens", "scopes", strings.Join(describeScopes(tokens), ", "))
+	}
+
+	// post the events of the chain to webhooks, stopping before the chain
+	// is closed
+	n.webhooks = startWebhooks(bc, bus)
+	defer n.webhooks.Close()
+
+	// post deposit events to the URLs watching addresses, stopping before
+	// the chain is closed
+	var delivering sync.WaitGroup
+	defer delivering.Wait()
+	delivering.Add(1)
+	go func() {
+		defer delivering.Done()
+		deliverDepositsEvery(cli.ctx, bc, pollInterval, webhookSecret())
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/ws", n.limited(nil, n.scoped(scopeRead, events.Handler(bus, wsAllowedOrigins()...))))
+	mux.Handle("/tx", n.limited(nil, n.scoped(scopeWrite, http.HandlerFunc(n.handleTx))))
+	mux.Handle("/testmempoolaccept", n.limited(nil, n.scoped(scopeWrite, http.HandlerFunc(n.handleTestMempoolAccept))))
+	mux.Handle("/template", n.limited([]string{"address"}, n.scoped(scopeWrite, http.HandlerFunc(n.handleTemplate))))
+	mux.Handle("/block", n.limited(nil, n.scoped(scopeWrite, http.HandlerFunc(n.handleBlock))))
+	mux.Handle("/headers", n.limited([]string{"from", "count"}, n.scoped(scopeRead, http.HandlerFunc(n.handleHeaders))))
+	mux.Handle("/balance", n.limited([]string{"address", "minconf"}, n.scoped(scopeRead, http.HandlerFunc(n.handleBalance))))
+	mux.Handle("/confirmations", n.limited([]string{"txid"}, n.scoped(scopeRead, http.HandlerFunc(n.handleConfirmations))))
+	mux.Handle("/estimatefee", n.limited([]string{"blocks", "size"}, n.scoped(scopeRead, http.HandlerFunc(n.handleEstimateFee))))
+	mux.Handle("/feehistogram", n.limited(nil, n.scoped(scopeRead, http.HandlerFunc(n.handleFeeHistogram))))
+	mux.Handle("/mempoolinfo", n.limited(nil, n.scoped(scopeRead, http.HandlerFunc(n.handleMempoolInfo))))
+	mux.Handle("/feefilter", n.limited(nil, n.scoped(scopeRead, http.HandlerFunc(n.handleFeeFilter))))
+	mux.Handle("/stats", n.limited([]string{"window"}, n.scoped(scopeRead, http.HandlerFunc(n.handleStats))))
+	mux.Handle("/txoutsetinfo", n.limited(nil, n.scoped(scopeRead, http.HandlerFunc(n.handleTxOutSetInfo))))
+	mux.Handle("/charts", n.limited([]string{"bucket", "from", "to"}, n.scoped(scopeRead, http.HandlerFunc(n.handleCharts))))
+
+	// mine continuously in the background if asked to, stopping before the
+	// chain is closed
+	var minerErr <-chan error
+	if minerAddress != "" && threads > 0 {
+		var mining sync.WaitGroup
+		defer mining.Wait()
+		ctx, cancel := context.WithCancel(cli.ctx)
+		defer cancel()
+		n.miner, minerErr = startMiner(ctx, bc, minerAddress, threads, interval, &mining)
+		mux.Handle("/minerstats", n.limited(nil, n.scoped(scopeRead, http.HandlerFunc(n.handleMinerStats))))
+	}
+	if n.servesWallets() {
+		mux.Handle("/wallets", n.limited([]string{"keys"}, http.HandlerFunc(n.handleWallets)))
+		mux.Handle("/wallet/", n.limited([]string{"keys"}, http.HandlerFunc(n.handleWallet)))
+		mux.Handle("/depositwatches", n.limited([]string{"address", "url"}, http.HandlerFunc(n.handleDepositWatches)))
+		mux.Handle("/webhooks", n.limited([]string{"url"}, http.HandlerFunc(n.handleWebhooks)))
+	}
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+
+	// serve TLS with the certificate given, or one signed by its own key
+	// for development
+	scheme := "ws"
+	if tlsSelfSigned {
+		cert, fingerprint, err := selfSignedCertificate(addr)
+		if err != nil {
+			log.Panicln("Unable to create a self-signed certificate: ", err.Error())
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		fmt.Printf("Serving a self-signed certificate with SHA-256 fingerprint %s\n", fingerprint)
+	}
+	if useTLS {
+		scheme = "wss"
+	}
+
+	listenErr := make(chan error, 1)
+	go func() {
+		if useTLS {
+			listenErr <- server.ListenAndServeTLS(tlsCert, tlsKey)
+		} else {
+			listenErr <- server.ListenAndServe()
+		}
+	}()
+	fmt.Printf("Serving events at %s://%s/ws\n", scheme, addr)
+
+	// serve the node over gRPC with the same TLS if asked to, stopping
+	// before the chain is closed without waiting for block streams, which
+	// don't end on their own
+	if grpcAddr != "" {
+		var opts []grpc.ServerOption
+		if useTLS {
+			config := server.TLSConfig
+			if tlsCert != "" {
+				cert, err := tls.LoadX509KeyPair(tlsCert, tlsKey)
+				if err != nil {
+					log.Panicln("Unable to load TLS certificate: ", err.Error())
+				}
+				config = &tls.Config{Certificates: []tls.Certificate{cert}}
+			}
+			opts = append(opts, grpc.Creds(credentials.NewTLS(config)))
+		}
+		grpcServer := n.newGRPCServer(opts...)
+		defer grpcServer.Stop()
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			log.Panicln("Unable to listen for gRPC: ", err.Error())
+		}
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				listenErr <- err
+			}
+		}()
+		fmt.Printf("Serving gRPC at %s\n", grpcAddr)
+	}
+
+	// serve the commands run on this machine on the socket, authorized by
+	// who can connect to it rather than by tokens
+	if n.local {
+		listener, err := listenSocket(nodeSocket())
+		if err != nil {
+			log.Panicln("Unable to listen on socket: ", err.Error())
+		}
+		local := &http.Server{Handler: localRequests(mux), ReadHeaderTimeout: readHeaderTimeout}
+		defer local.Shutdown(context.Background())
+		go func() {
+			if err := local.Serve(listener); err != http.ErrServerClosed {
+				listenErr <- err
+			}
+		}()
+		fmt.Printf("Serving commands on socket %s\n", nodeSocket())
+	}
+
+	// a nil channel never fires, so nothing is mined every interval without
+	// an address or while the background miner runs
+	var mineTick <-chan time.Time
+	if minerAddress != "" && n.miner == nil {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		mineTick = ticker.C
+	}
+	var watchTick <-chan time.Time
+	if watchDir != "" {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		watchTick = ticker.C
+	}
+	var expireTick <-chan time.Time
+	if cfg.Mempool.MaxAge > 0 {
+		ticker := time.NewTicker(expireInterval)
+		defer ticker.Stop()
+		expireTick = ticker.C
+	}
+
+	for {
+		select {
+		case <-cli.ctx.Done():
+			server.Shutdown(context.Background())
+			return
+		case err := <-listenErr:
+			log.Panicln("Unable to serve events: ", err.Error())
+		case err := <-minerErr:
+			log.Panicln("Unable to mine: ", err.Error())
+		case <-mineTick:
+			n.minePending(cli.ctx, minerAddress)
+		case <-watchTick:
+			if err := processWatchFolder(n.bc, watchDir, watchSettle); err != nil {
+				log.Panicln("Unable to watch folder: ", err.Error())
+			}
+		case <-expireTick:
+			if _, err := bc.ExpireMempool(); err != nil {
+				log.Panicln("Unable to expire mempool: ", err.Error())
+			}
+		}
+	}
+}
+
+// wsAllowedOrigins returns the origins, separated by commas in the
+// WS_ALLOWED_ORIGINS env var, of the pages browsers may subscribe to
+// events from besides those of the node.
+func wsAllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// minePending mines the pending transactions, if there are any, rewarding
+// minerAddress.
+func (n *node) minePending(ctx context.Context, minerAddress string) {
+	if len(n.bc.MempoolTransactions()) == 0 {
+		return
+	}
+	block, err := n.bc.MinePendingContext(ctx, minerAddress)
+	switch err {
+	case nil:
+		logger.Info("Mined block", "height", block.Height, "hash", block.Hash, "transactions", len(block.Transactions))
+	case blockchain.ErrChainFrozen, blockchain.ErrMiningCancelled, blockchain.ErrTipChanged, blockchain.ErrNotInTurn:
+	default:
+		log.Panicln("Unable to mine block: ", err.Error())
+	}
+}
+
+// handleTx adds a signed raw transaction posted as hex to the mempool.
+func (n *node) handleTx(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	raw, err := blockchain.DecodeRawTransaction(strings.TrimSpace(string(body)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := n.bc.SendRawTransaction(raw); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]string{"txid": hex.EncodeToString(raw.Tx.ID)})
+}
+
+// handleTestMempoolAccept reports whether a signed raw transaction posted
+// as hex would enter the mempool, or the rule it breaks, without adding it.
+func (n *node) handleTestMempoolAccept(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	raw, err := blockchain.DecodeRawTransaction(strings.TrimSpace(string(body)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, n.bc.TestRawTransaction(raw))
+}
+
+// handleTemplate returns a block template rewarding the address in the
+// query as mining.Work.
+func (n *node) handleTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	address := r.URL.Query().Get("address")
+	if !wallet.ValidateAddress(address) {
+		http.Error(w, "address not valid", http.StatusBadRequest)
+		return
+	}
+
+	template, err := n.bc.GetBlockTemplate(address)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, mining.NewTemplateWork(template))
+}
+
+// handleBlock adds a block from getblocktemplate mined with a nonce to the
+// chain.
+func (n *node) handleBlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var submitted submittedBlock
+	if err := json.NewDecoder(r.Body).Decode(&submitted); err != nil {
+		http.Error(w, "invalid block - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := hex.DecodeString(submitted.Block)
+	if err != nil {
+		http.Error(w, "invalid block - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	block := blockchain.Deserialize(data)
+
+	if err := n.bc.SubmitBlock(block, submitted.Nonce); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"hash": hex.EncodeToString(block.Hash), "height": block.Height})
+}
+
+// handleHeaders serves the serialized headers of the blocks of the best
+// chain from the height in the from query parameter, 0 by default, at most
+// count of them, or blockchain.MaxHeaders by default.
+func (n *node) handleHeaders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	from := 0
+	if s := query.Get("from"); s != "" {
+		var err error
+		if from, err = strconv.Atoi(s); err != nil || from < 0 {
+			http.Error(w, "from not valid", http.StatusBadRequest)
+			return
+		}
+	}
+	count := blockchain.MaxHeaders
+	if s := query.Get("count"); s != "" {
+		var err error
+		if count, err = strconv.Atoi(s); err != nil || count < 1 {
+			http.Error(w, "count not valid", http.StatusBadRequest)
+			return
+		}
+	}
+
+	headers, err := n.bc.Headers(from, count)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	serialized := []string{}
+	for _, header := range headers {
+		serialized = append(serialized, hex.EncodeToString(header.Serialize()))
+	}
+	writeJSON(w, map[string]interface{}{"headers": serialized})
+}
+
+// handleBalance returns the balance of the address in the query split into
+// trusted, untrusted pending and immature value, trusting outputs with the
+// minconf confirmations of the query, or 1 if it is not given, and the
+// value pending transactions move to and from it.
+func (n *node) handleBalance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	address := query.Get("address")
+	if !wallet.ValidateAddress(address) {
+		http.Error(w, "address not valid", http.StatusBadRequest)
+		return
+	}
+	minConf := 1
+	if s := query.Get("minconf"); s != "" {
+		var err error
+		if minConf, err = strconv.Atoi(s); err != nil || minConf < 0 {
+			http.Error(w, "minconf not valid", http.StatusBadRequest)
+			return
+		}
+	}
+	pubKeyHash, err := wallet.PublicKeyHashFromAddress(address)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	balances, err := n.bc.Balances(pubKeyHash, minConf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	transfers := n.bc.PendingTransfers(pubKeyHash)
+	writeJSON(w, map[string]interface{}{
+		"address":           address,
+		"minconf":           minConf,
+		"trusted":           balances.Trusted,
+		"untrusted_pending": balances.UntrustedPending,
+		"immature":          balances.Immature,
+		"pending_incoming":  transfers.Incoming,
+		"pending_outgoing":  transfers.Outgoing,
+	})
+}
+
+// handleConfirmations serves the number of confirmations of the
+// transaction given by the txid query parameter, which is 0 if it is
+// pending or unknown.
+func (n *node) handleConfirmations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	txID, err := blockchain.ParseHash(r.URL.Query().Get("txid"))
+	if err != nil {
+		http.Error(w, "txid not valid", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"txid": txID.String(), "confirmations": n.bc.Confirmations(txID)})
+}
+
+// handleEstimateFee serves the fee rate suggested for a transaction to be
+// mined within the blocks given by the blocks query parameter, 2 by
+// default, and the fee of a transaction of size bytes if given.
+func (n *node) handleEstimateFee(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	blocks := 2
+	if s := query.Get("blocks"); s != "" {
+		var err error
+		if blocks, err = strconv.Atoi(s); err != nil || blocks < 1 {
+			http.Error(w, "blocks not valid", http.StatusBadRequest)
+			return
+		}
+	}
+	size := 0
+	if s := query.Get("size"); s != "" {
+		var err error
+		if size, err = strconv.Atoi(s); err != nil || size < 1 {
+			http.Error(w, "size not valid", http.StatusBadRequest)
+			return
+		}
+	}
+
+	estimate, err := n.bc.EstimateFee(blocks)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if size == 0 {
+		writeJSON(w, estimate)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"estimate": estimate,
+		"size":     size,
+		"fee":      estimate.Fee(size),
+	})
+}
+
+// handleFeeHistogram serves the pending transactions and their size in each
+// fee rate band.
+func (n *node) handleFeeHistogram(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, n.bc.FeeHistogram())
+}
+
+// handleCharts serves the median fee rate, difficulty and block interval
+// of the best chain in buckets of the duration given by the bucket query
+// parameter, an hour by default, for the blocks with a timestamp between
+// the from and to parameters in unix seconds, all of them by default.
+func (n *node) handleCharts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	bucket := time.Hour
+	if s := query.Get("bucket"); s != "" {
+		var err error
+		if bucket, err = time.ParseDuration(s); err != nil || bucket < time.Second {
+			http.Error(w, "bucket not valid", http.StatusBadRequest)
+			return
+		}
+	}
+	from, to := int64(0), int64(math.MaxInt64)
+	for name, value := range map[string]*int64{"from": &from, "to": &to} {
+		if s := query.Get(name); s != "" {
+			var err error
+			if *value, err = strconv.ParseInt(s, 10, 64); err != nil {
+				http.Error(w, name+" not valid", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	series, err := n.bc.ChartSeries(from, to, int64(bucket/time.Second))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"bucket": int64(bucket / time.Second),
+		"series": series,
+	})
+}
+
+// writeJSON writes v as the JSON body of a response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// shutdownContext returns a context that is cancelled when the process
+// receives SIGINT or SIGTERM, so long running commands can stop and close
+// the database cleanly. A second signal exits immediately.
+func shutdownContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		logger.Info("Shutting down", "signal", sig)
+		cancel()
+
+		<-signals
+		logger.Warn("Forced shutdown")
+		os.Exit(1)
+	}()
+
+	return ctx
+}
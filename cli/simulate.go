@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+)
+
+// simulateDifficulty simulates the production of blocks under the
+// difficulty rules of the network, with the spacing and starting
+// difficulty overridden if set, and prints the block intervals in windows
+// of report blocks, so chain parameters can be picked before a network is
+// launched. It doesn't use the database.
+func (cli *CLI) simulateDifficulty(cfg blockchain.SimulationConfig, hashRate string, spacing int64, report int, asJSON bool) {
+	chainConfig := blockChainConfig("")
+	cfg.Rules = chainConfig.DifficultyRules()
+	if spacing > 0 {
+		cfg.Rules.TargetSpacing = spacing
+	}
+	if cfg.Difficulty <= 0 {
+		cfg.Difficulty = network.GenesisDifficulty
+		if chainConfig.Genesis != nil {
+			cfg.Difficulty = chainConfig.Genesis.Difficulty
+		}
+	}
+	curve, err := blockchain.ParseHashRateCurve(hashRate)
+	if err != nil {
+		log.Panicln("Unable to parse hash rate: ", err.Error())
+	}
+	cfg.HashRate = curve
+
+	blocks, err := blockchain.SimulateDifficulty(cfg)
+	if err != nil {
+		log.Panicln("Unable to simulate difficulty: ", err.Error())
+	}
+	stats := blockchain.SummarizeSimulation(blocks, report)
+	total := blockchain.SummarizeSimulation(blocks, 0)[0]
+	if asJSON {
+		printJSON(map[string]interface{}{
+			"windows": stats,
+			"total":   total,
+		})
+		return
+	}
+
+	seconds := func(s float64) time.Duration {
+		return time.Duration(s * float64(time.Second)).Round(time.Millisecond)
+	}
+	fmt.Printf("Simulated %d blocks with the %s algorithm, target spacing %ds\n", len(blocks), cfg.Algorithm, cfg.Rules.TargetSpacing)
+	for _, s := range stats {
+		fmt.Printf("Heights %d to %d: mean %s, p50 %s, p90 %s, max %s, difficulty %d to %d, %d at minimum difficulty\n",
+			s.FromHeight, s.ToHeight, seconds(s.MeanInterval), seconds(s.Interval.P50), seconds(s.Interval.P90),
+			seconds(s.Interval.Max), s.MinDifficulty, s.MaxDifficulty, s.MinDifficultyBlocks)
+	}
+	fmt.Printf("Total: mean %s, p50 %s, p99 %s, max %s, %s for all blocks\n",
+		seconds(total.MeanInterval), seconds(total.Interval.P50), seconds(total.Interval.P99),
+		seconds(total.Interval.Max), seconds(total.MeanInterval*float64(total.Blocks)))
+}
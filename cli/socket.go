@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+)
+
+const (
+	// socketDialTimeout is how long commands wait to connect to the socket
+	// of a node before opening the database themselves.
+	socketDialTimeout = time.Second
+
+	// socketTimeout is how long commands wait for the node to answer.
+	socketTimeout = time.Minute
+)
+
+// localKey marks the context of requests made over the socket of a node.
+type localKey struct{}
+
+// nodeSocket returns the path of the socket in the NODE_SOCKET env var,
+// which serve listens on for the commands run on the same machine.
+func nodeSocket() string {
+	return os.Getenv("NODE_SOCKET")
+}
+
+// listenSocket listens on the unix socket at path, which only the user
+// running the node can connect to. A socket left by a node that didn't
+// shut down cleanly is replaced, but not one a node is still serving on.
+func listenSocket(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, socketDialTimeout); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another node is serving on socket %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return listenPrivate(path)
+}
+
+// listenPrivate listens on the unix socket at path, created with a umask
+// leaving it to the user alone, so other users can't connect to it before
+// its mode could be changed. The umask is that of the process, so files
+// created meanwhile by other goroutines are private too.
+func listenPrivate(path string) (net.Listener, error) {
+	umask := syscall.Umask(0077)
+	defer syscall.Umask(umask)
+	return net.Listen("unix", path)
+}
+
+// localRequests returns a handler marking the requests to h as made over
+// the socket, so they are authorized like the token of the node: only the
+// user running the node can connect to it.
+func localRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), localKey{}, true)))
+	})
+}
+
+// localRequest returns whether a request was made over the socket.
+func localRequest(r *http.Request) bool {
+	local, _ := r.Context().Value(localKey{}).(bool)
+	return local
+}
+
+// nodeClient calls the API of a node run with serve on the same machine
+// over its socket.
+type nodeClient struct {
+	client *http.Client
+}
+
+// nodeBalances are the balances of an address served at /balance.
+type nodeBalances struct {
+	Trusted          units.Amount `json:"trusted"`
+	UntrustedPending units.Amount `json:"untrusted_pending"`
+	Immature         units.Amount `json:"immature"`
+	PendingIncoming  units.Amount `json:"pending_incoming"`
+	PendingOutgoing  units.Amount `json:"pending_outgoing"`
+}
+
+// localNode returns a client of the node serving on the socket in the
+// NODE_SOCKET env var, or nil if it is not set or no node is serving on
+// it, in which case commands open the database themselves.
+func localNode() *nodeClient {
+	socket := nodeSocket()
+	if socket == "" {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", socket, socketDialTimeout)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+	return newNodeClient(socket)
+}
+
+// newNodeClient returns a client of the node serving on socket.
+func newNodeClient(socket string) *nodeClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &nodeClient{client: &http.Client{Transport: transport, Timeout: socketTimeout}}
+}
+
+// do sends a request to the node with body encoded as JSON, if it is not
+// nil, and decodes the JSON response into v.
+func (c *nodeClient) do(method, path string, body, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	// the host is ignored, every request goes to the socket
+	req, err := http.NewRequest(method, "http://node"+path, reader)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("node returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// balances fetches the balances of address, trusting outputs with minConf
+// confirmations.
+func (c *nodeClient) balances(address string, minConf int) (nodeBalances, error) {
+	var balances nodeBalances
+	query := url.Values{"address": {address}, "minconf": {strconv.Itoa(minConf)}}
+	err := c.do(http.MethodGet, "/balance?"+query.Encode(), nil, &balances)
+	return balances, err
+}
+
+// send sends amount from an address of the wallets file called name on the
+// node to another, returning the id of the transaction it added to its
+// mempool.
+func (c *nodeClient) send(name string, send walletSend) ([]byte, error) {
+	var sent struct {
+		TxID string `json:"txid"`
+	}
+	if err := c.do(http.MethodPost, "/wallet/"+url.PathEscape(name)+"/send", send, &sent); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(sent.TxID)
+}
+
+// confirmations fetches the number of confirmations of a transaction.
+func (c *nodeClient) confirmations(txID blockchain.Hash) (int, error) {
+	var confirmed struct {
+		Confirmations int `json:"confirmations"`
+	}
+	query := url.Values{"txid": {txID.String()}}
+	err := c.do(http.MethodGet, "/confirmations?"+query.Encode(), nil, &confirmed)
+	return confirmed.Confirmations, err
+}
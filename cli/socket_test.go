@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestLocalNode(t *testing.T) {
+	dir := t.TempDir()
+	stores := map[string]*wallet.Store{defaultWallet: wallet.NewStore(filepath.Join(dir, "wallets.dat"))}
+	payer, err := stores[defaultWallet].Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payee := wallet.CreateWallet()
+	bc, err := blockchain.InitInMemory(&blockchain.Genesis{
+		Network:     "socket",
+		Allocations: map[string]units.Amount{payer.Address().String(): 100 * units.Coin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	n := &node{bc: bc, wallets: stores, local: true}
+	mux := http.NewServeMux()
+	mux.Handle("/balance", n.scoped(scopeRead, http.HandlerFunc(n.handleBalance)))
+	mux.Handle("/confirmations", n.scoped(scopeRead, http.HandlerFunc(n.handleConfirmations)))
+	mux.HandleFunc("/wallet/", n.handleWallet)
+
+	socket := filepath.Join(dir, "node.sock")
+	listener, err := listenSocket(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: localRequests(mux)}
+	go server.Serve(listener)
+	defer server.Close()
+	client := newNodeClient(socket)
+
+	// only the user can connect to the socket
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		t.Fatalf("socket has mode %s, want no access for other users", info.Mode())
+	}
+
+	// a second node can't take the socket
+	if _, err := listenSocket(socket); err == nil {
+		t.Fatal("listened on the socket of a running node")
+	}
+
+	// balances and sends go through the node
+	balances, err := client.balances(payer.Address().String(), 1)
+	if err != nil || balances.Trusted != 100*units.Coin {
+		t.Fatalf("got balances %+v, %v, want 100 coins trusted", balances, err)
+	}
+	txID, err := client.send(defaultWallet, walletSend{From: payer.Address().String(), To: payee.Address().String(), Amount: units.Coin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := blockchain.HashFromBytes(txID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmations, err := client.confirmations(id); err != nil || confirmations != 0 {
+		t.Fatalf("got %d confirmations, %v for a pending transaction", confirmations, err)
+	}
+	if _, err := bc.MinePending(payer.Address().String()); err != nil {
+		t.Fatal(err)
+	}
+	if confirmations, err := client.confirmations(id); err != nil || confirmations != 1 {
+		t.Fatalf("got %d confirmations, %v, want 1", confirmations, err)
+	}
+	if balances, err := client.balances(payee.Address().String(), 1); err != nil || balances.Trusted != units.Coin {
+		t.Fatalf("got payee balances %+v, %v, want a coin trusted", balances, err)
+	}
+
+	// the wallets are only open to the socket, not to clients without a
+	// token over TCP
+	tcp := httptest.NewServer(mux)
+	defer tcp.Close()
+	data, _ := json.Marshal(walletSend{From: payer.Address().String(), To: payee.Address().String(), Amount: units.Coin})
+	resp, err := http.Post(tcp.URL+"/wallet/default/send", "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("got %s sending over TCP without a token, want unauthorized", resp.Status)
+	}
+
+	// a socket left by a node that is gone is replaced
+	server.Close()
+	stale := filepath.Join(dir, "stale.sock")
+	if err := ioutil.WriteFile(stale, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	listener, err = listenSocket(stale)
+	if err != nil {
+		t.Fatalf("unable to replace a stale socket: %s", err)
+	}
+	listener.Close()
+}
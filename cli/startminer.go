@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/mining"
+)
+
+// startMiner mines blocks rewarding address on threads CPUs in the
+// background until ctx is done, searching each template for refresh at
+// most so new transactions are picked up, and fetching a new template as
+// soon as a block from elsewhere changes the tip. It returns the miner,
+// for its stats, and a channel receiving the error it stopped with, if
+// any. running is done once the miner stopped.
+func startMiner(ctx context.Context, bc *blockchain.BlockChain, address string, threads int, refresh time.Duration, running *sync.WaitGroup) (*mining.Miner, <-chan error) {
+
+	// interrupt the search when the tip changes, which the miner ignores
+	// for its own blocks
+	interrupt := make(chan struct{}, 1)
+	bc.OnBlockConnected(func(block *blockchain.Block) {
+		select {
+		case interrupt <- struct{}{}:
+		default:
+		}
+	})
+
+	counter := &mining.HashCounter{}
+	miner := &mining.Miner{
+		Provider:  &mining.Local{BlockChain: bc, Address: address},
+		Solver:    mining.CPUSolver{Threads: threads, Counter: counter},
+		Refresh:   refresh,
+		Interrupt: interrupt,
+		Counter:   counter,
+		Logger:    logger,
+	}
+
+	errs := make(chan error, 1)
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		if err := miner.Run(ctx); err != nil {
+			errs <- err
+		}
+	}()
+	logger.Info("Mining", "address", address, "threads", threads)
+	return miner, errs
+}
+
+// handleMinerStats serves the hash rate and the blocks mined by the
+// background miner of the node.
+func (n *node) handleMinerStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, n.miner.Stats())
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+)
+
+// stats prints the height, tip, transactions, supply and difficulty of the
+// best chain, with the block interval and hash rate averaged over the last
+// window blocks.
+func (cli *CLI) stats(window int, asJSON bool) {
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	stats, err := bc.ChainStats(window)
+	if err != nil {
+		log.Panicln("Unable to read chain stats: ", err.Error())
+	}
+	if asJSON {
+		printJSON(stats)
+		return
+	}
+
+	fmt.Printf("Height:       %d\n", stats.Height)
+	fmt.Printf("Tip:          %s\n", stats.Tip)
+	fmt.Printf("Transactions: %d\n", stats.Transactions)
+	fmt.Printf("Supply:       %s\n", stats.Supply)
+	fmt.Printf("Difficulty:   %d\n", stats.Difficulty)
+	fmt.Printf("Interval:     %s over %d blocks\n", time.Duration(stats.Interval*float64(time.Second)).Round(time.Millisecond), stats.Window)
+	fmt.Printf("Hash rate:    %s\n", hashRate(stats.HashRate))
+	if stats.Partial {
+		fmt.Println("Pruned blocks are left out of the transactions and supply")
+	}
+}
+
+// hashRate formats hashes per second with a metric prefix.
+func hashRate(rate float64) string {
+	prefixes := []string{"", "k", "M", "G", "T", "P", "E"}
+	i := 0
+	for rate >= 1000 && i < len(prefixes)-1 {
+		rate /= 1000
+		i++
+	}
+	return fmt.Sprintf("%.3g %sH/s", rate, prefixes[i])
+}
+
+// handleStats serves the statistics of the best chain, averaged over the
+// last window blocks, DefaultStatsWindow by default.
+func (n *node) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	window := blockchain.DefaultStatsWindow
+	if s := r.URL.Query().Get("window"); s != "" {
+		var err error
+		if window, err = strconv.Atoi(s); err != nil || window <= 0 {
+			http.Error(w, "window not valid", http.StatusBadRequest)
+			return
+		}
+	}
+
+	stats, err := n.bc.ChainStats(window)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, stats)
+}
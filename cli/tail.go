@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/blockchain"
+)
+
+// tailEvent is a block joining or leaving the best chain, printed as a line
+// of JSON so indexers can follow the chain.
+type tailEvent struct {
+	Event string            `json:"event"`
+	Block *blockchain.Block `json:"block"`
+}
+
+// printTailEvent prints a connect or disconnect event for a block.
+func printTailEvent(event string, block *blockchain.Block, asJSON bool) {
+	if !asJSON {
+		fmt.Printf("%-10s %s\n", event, block.Format(blockchain.Summary, useColor()))
+		return
+	}
+
+	data, err := json.Marshal(tailEvent{Event: event, Block: block})
+	if err != nil {
+		log.Panicln("Unable to encode JSON: ", err.Error())
+	}
+	fmt.Println(string(data))
+}
+
+// tail prints the last n blocks of the best chain. If follow is set, it
+// keeps printing blocks as they are connected, and blocks disconnected by a
+// reorg, until the process is asked to shut down.
+func (cli *CLI) tail(n int, follow, asJSON bool) {
+	var last []byte
+
+	// print the last n blocks
+	bc := queryBlockChain("")
+	start := bc.Height() - n + 1
+	if start < 0 {
+		start = 0
+	}
+	err := bc.IterateRange(start, -1, func(block *blockchain.Block) error {
+		printTailEvent("connect", block, asJSON)
+		last = block.Hash
+		return nil
+	})
+	bc.Close()
+	if err != nil {
+		log.Panicln("Unable to get block: ", err.Error())
+	}
+
+	if !follow {
+		return
+	}
+
+	// print the changes to the best chain every time it is checked, until
+	// poll returns because the process is shutting down
+	poll(cli.ctx, func(bc *blockchain.BlockChain) bool {
+		disconnected, connected, err := bc.TipChanges(last)
+		if err != nil {
+			return false
+		}
+		for _, block := range disconnected {
+			printTailEvent("disconnect", block, asJSON)
+		}
+		for _, block := range connected {
+			printTailEvent("connect", block, asJSON)
+		}
+		last = bc.Tip()
+		return false
+	})
+}
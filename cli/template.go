@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/mining"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// getBlockTemplate prints a block template rewarding address as JSON. An
+// external miner writes nonces as nonceSize big endian bytes at nonceOffset
+// in the header until the sha256 hash of the header is below the target,
+// then submits the block with the nonce. The template describes the
+// transactions selected for the block and the coinbase collecting their
+// fees.
+func (cli *CLI) getBlockTemplate(address string) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to create block template: address not valid")
+	}
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	template, err := bc.GetBlockTemplate(address)
+	if err != nil {
+		log.Panicln("Unable to create block template: ", err.Error())
+	}
+	printJSON(mining.NewTemplateWork(template))
+}
+
+// submitBlock adds a block from a block template mined with nonce to the
+// chain.
+func (cli *CLI) submitBlock(blockHex string, nonce int) {
+	data, err := hex.DecodeString(blockHex)
+	if err != nil {
+		log.Panicln("Unable to decode block: ", err.Error())
+	}
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	block := blockchain.Deserialize(data)
+	if err := bc.SubmitBlock(block, nonce); err != nil {
+		log.Panicln("Unable to submit block: ", err.Error())
+	}
+	fmt.Printf("Block %x accepted at height %d\n", block.Hash, block.Height)
+}
+
+// mineWork mines blocks rewarding address with work from the node serving
+// at nodeURL until the process is asked to shut down. The work is solved by
+// the solver program, such as a GPU miner, or on every CPU if solver is
+// empty, and fetched again every refresh so new transactions are mined.
+func (cli *CLI) mineWork(nodeURL, address, solver string, refresh time.Duration) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to mine work: address not valid")
+	}
+
+	var s mining.Solver = mining.CPUSolver{}
+	if solver != "" {
+		s = &mining.ProcessSolver{Path: solver}
+	}
+	miner := &mining.Miner{
+		Provider: &mining.HTTPProvider{URL: nodeURL, Address: address},
+		Solver:   s,
+		Refresh:  refresh,
+		Logger:   logger,
+	}
+
+	logger.Info("Mining work", "node", nodeURL, "address", address)
+	if err := miner.Run(cli.ctx); err != nil && err != context.Canceled {
+		log.Panicln("Unable to mine work: ", err.Error())
+	}
+}
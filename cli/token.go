@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// getTokenBalance prints the confirmed amount of a token held by address.
+func (cli *CLI) getTokenBalance(address, token string, asJSON bool) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to get token balance: address not valid")
+	}
+	tokenID, err := blockchain.ParseHash(token)
+	if err != nil {
+		log.Panicln("Unable to get token balance: ", err.Error())
+	}
+	bc := queryBlockChain("")
+	defer bc.Close()
+
+	amount, err := bc.TokenBalance(pubKeyHashFromAddress(address), tokenID)
+	if err != nil {
+		log.Panicln("Unable to get token balance: ", err.Error())
+	}
+	if asJSON {
+		printJSON(map[string]interface{}{"address": address, "token": tokenID.String(), "balance": amount})
+		return
+	}
+	fmt.Printf("Balance of %s in token %s: %d\n", address, tokenID, amount)
+}
+
+// issueToken issues supply of a new token to address, paying fee, and
+// mines the issuance unless queue is set.
+func (cli *CLI) issueToken(address string, supply int64, fee units.Amount, queue bool) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to issue token: address not valid")
+	}
+	bc := openBlockChain(address)
+	defer bc.Close()
+
+	w, err := walletStore().Get(address)
+	if err != nil {
+		log.Panicln("Unable to load wallet: ", err.Error())
+	}
+	tx, err := bc.NewTokenIssueTransaction(w, supply, fee)
+	if err != nil {
+		log.Panicln("Unable to create transaction: ", err.Error())
+	}
+	if cli.sendTokenTransaction(bc, address, tx, queue) {
+		fmt.Printf("Issued %d of token %x\n", supply, tx.ID)
+	}
+}
+
+// sendToken sends amount of a token from address to the to address,
+// paying fee, and mines the transfer unless queue is set.
+func (cli *CLI) sendToken(from, to, token string, amount int64, fee units.Amount, queue bool) {
+	if !wallet.ValidateAddress(from) || !wallet.ValidateAddress(to) {
+		log.Panicln("Unable to send token: address not valid")
+	}
+	tokenID, err := blockchain.ParseHash(token)
+	if err != nil {
+		log.Panicln("Unable to send token: ", err.Error())
+	}
+	bc := openBlockChain(from)
+	defer bc.Close()
+
+	w, err := walletStore().Get(from)
+	if err != nil {
+		log.Panicln("Unable to load wallet: ", err.Error())
+	}
+	tx, err := bc.NewTokenTransferTransaction(w, tokenID, to, amount, fee)
+	if err != nil {
+		log.Panicln("Unable to create transaction: ", err.Error())
+	}
+	if cli.sendTokenTransaction(bc, from, tx, queue) {
+		fmt.Printf("Sent %d of token %s to %s\n", amount, tokenID, to)
+	}
+}
+
+// sendTokenTransaction adds a token transaction from address to the
+// mempool and mines it unless queue is set, rewarding address as the miner. It
+// returns whether the transaction was mined.
+func (cli *CLI) sendTokenTransaction(bc *blockchain.BlockChain, address string, tx *blockchain.Transaction, queue bool) bool {
+	if err := bc.AddToMempool(tx); err != nil {
+		log.Panicln("Unable to add transaction to mempool: ", err.Error())
+	}
+	if queue {
+		fmt.Printf("Transaction %x added to mempool\n", tx.ID)
+		return false
+	}
+	return cli.mineSent(bc, address, tx.ID)
+}
@@ -0,0 +1,355 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edwintcloud/gochain/keys"
+)
+
+// Version is the version of the binary, set when building a release with
+// -ldflags "-X github.com/edwintcloud/gochain/cli.Version=VERSION".
+var Version = "dev"
+
+// updateTimeout bounds fetching the release manifest and binary.
+const updateTimeout = 10 * time.Minute
+
+// releaseManifest is the document served at UPDATE_URL describing the
+// latest release, with a binary for each platform keyed by GOOS-GOARCH.
+type releaseManifest struct {
+	Version  string                   `json:"version"`
+	Binaries map[string]releaseBinary `json:"binaries"`
+}
+
+// releaseBinary is the binary of a release for a platform, with its
+// SHA-256 checksum and the signature of releaseDigest by the release key,
+// both as hex.
+type releaseBinary struct {
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+}
+
+// platform returns the key of the binaries of this platform in a release
+// manifest.
+func platform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// releaseDigest returns the hash the release key signs for the binary of a
+// version for a platform with a checksum, so a signed binary can't be
+// passed off as another version or for another platform.
+func releaseDigest(version, platform string, checksum []byte) []byte {
+	digest := sha256.Sum256([]byte("gochain release\n" + version + "\n" + platform + "\n" + hex.EncodeToString(checksum)))
+	return digest[:]
+}
+
+// compareVersions compares two versions of the form [v]MAJOR.MINOR.PATCH
+// with any number of numeric parts and an optional -PRERELEASE suffix,
+// returning -1, 0 or 1 as a is older than, the same as or newer than b. A
+// prerelease is older than its release. Versions not of that form, such as
+// dev builds, can't be compared.
+func compareVersions(a, b string) (int, error) {
+	parse := func(version string) ([]int, string, error) {
+		release, prerelease := strings.TrimPrefix(version, "v"), ""
+		if i := strings.IndexByte(release, '-'); i >= 0 {
+			release, prerelease = release[:i], release[i+1:]
+		}
+		var parts []int
+		for _, part := range strings.Split(release, ".") {
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 {
+				return nil, "", fmt.Errorf("version %q is not of the form MAJOR.MINOR.PATCH", version)
+			}
+			parts = append(parts, n)
+		}
+		return parts, prerelease, nil
+	}
+	aParts, aPre, err := parse(a)
+	if err != nil {
+		return 0, err
+	}
+	bParts, bPre, err := parse(b)
+	if err != nil {
+		return 0, err
+	}
+
+	// compare the numeric parts, missing parts counting as zero
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x = aParts[i]
+		}
+		if i < len(bParts) {
+			y = bParts[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+
+	// a release is newer than its prereleases
+	switch {
+	case aPre == bPre:
+		return 0, nil
+	case aPre == "":
+		return 1, nil
+	case bPre == "":
+		return -1, nil
+	case aPre < bPre:
+		return -1, nil
+	}
+	return 1, nil
+}
+
+// fetchRelease gets the release manifest at url.
+func fetchRelease(client *http.Client, url string) (*releaseManifest, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, errors.New("unable to fetch release manifest - " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch release manifest - %s", resp.Status)
+	}
+	var manifest releaseManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, errors.New("release manifest is invalid - " + err.Error())
+	}
+	if manifest.Version == "" {
+		return nil, errors.New("release manifest has no version")
+	}
+	return &manifest, nil
+}
+
+// downloadBinary downloads a binary to a new file in dir, returning its
+// path and SHA-256 checksum.
+func downloadBinary(client *http.Client, url, dir string) (string, []byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", nil, errors.New("unable to download binary - " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("unable to download binary - %s", resp.Status)
+	}
+
+	file, err := ioutil.TempFile(dir, ".gochain-update-")
+	if err != nil {
+		return "", nil, errors.New("unable to create binary - " + err.Error())
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", nil, errors.New("unable to download binary - " + err.Error())
+	}
+	return file.Name(), hash.Sum(nil), nil
+}
+
+// verifyBinary returns an error unless checksum is the checksum of the
+// binary of a release, signed by the release key pubKey.
+func verifyBinary(binary releaseBinary, version, platform string, pubKey, checksum []byte) error {
+	want, err := hex.DecodeString(binary.SHA256)
+	if err != nil || !bytes.Equal(want, checksum) {
+		return fmt.Errorf("binary has checksum %x, the release says %s", checksum, binary.SHA256)
+	}
+	signature, err := hex.DecodeString(binary.Signature)
+	if err != nil || !keys.P256.Verify(pubKey, releaseDigest(version, platform, checksum), signature) {
+		return errors.New("checksum of the binary is not signed by the release key")
+	}
+	return nil
+}
+
+// checkBinary runs the binary at path with the version command, returning
+// an error unless it reports version.
+func checkBinary(path, version string) error {
+	out, err := exec.Command(path, "version").Output()
+	if err != nil {
+		return errors.New("new binary does not run - " + err.Error())
+	}
+	if got := strings.TrimSpace(string(out)); got != version {
+		return fmt.Errorf("new binary reports version %q, the release is %s", got, version)
+	}
+	return nil
+}
+
+// swapBinary replaces the binary at exe with the one at next, keeping the
+// old one at exe.old, and runs check on the result. If the swap or check
+// fails, the old binary is put back.
+func swapBinary(exe, next string, check func(path string) error) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(next, info.Mode()); err != nil {
+		return err
+	}
+
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		return errors.New("unable to move the current binary aside - " + err.Error())
+	}
+	err = os.Rename(next, exe)
+	if err == nil {
+		err = check(exe)
+	}
+	if err != nil {
+		os.Remove(exe)
+		if rollbackErr := os.Rename(old, exe); rollbackErr != nil {
+			return fmt.Errorf("%s, and unable to restore the old binary from %s - %s", err.Error(), old, rollbackErr.Error())
+		}
+		return fmt.Errorf("%s, the old binary was restored", err.Error())
+	}
+	return nil
+}
+
+// applyUpdate updates the binary at exe, of version current, to the
+// release described at manifestURL if it is a newer version. force installs
+// it even if it is the same or an older version, or the versions can't be
+// compared, so a replayed manifest of an older signed release can't
+// downgrade the binary on its own. The binary must be signed by the release
+// key pubKey. It returns the version of the release and whether the binary
+// was replaced.
+func applyUpdate(client *http.Client, manifestURL string, pubKey []byte, exe, current string, force bool) (string, bool, error) {
+	manifest, err := fetchRelease(client, manifestURL)
+	if err != nil {
+		return "", false, err
+	}
+	if !force {
+		cmp, err := compareVersions(manifest.Version, current)
+		if err != nil {
+			return manifest.Version, false, errors.New(err.Error() + ", pass -force to install release " + manifest.Version)
+		}
+		if cmp == 0 {
+			return manifest.Version, false, nil
+		}
+		if cmp < 0 {
+			return manifest.Version, false, fmt.Errorf("release %s is older than the running version %s, pass -force to downgrade", manifest.Version, current)
+		}
+	}
+	binary, ok := manifest.Binaries[platform()]
+	if !ok {
+		return manifest.Version, false, fmt.Errorf("release %s has no binary for %s", manifest.Version, platform())
+	}
+
+	// download next to the binary so it can be renamed into place
+	next, checksum, err := downloadBinary(client, binary.URL, filepath.Dir(exe))
+	if err != nil {
+		return manifest.Version, false, err
+	}
+	defer os.Remove(next)
+	if err := verifyBinary(binary, manifest.Version, platform(), pubKey, checksum); err != nil {
+		return manifest.Version, false, err
+	}
+
+	err = swapBinary(exe, next, func(path string) error {
+		return checkBinary(path, manifest.Version)
+	})
+	return manifest.Version, err == nil, err
+}
+
+// update replaces the running binary with the latest release described at
+// the UPDATE_URL env var, whose binary must be signed by the P-256 public
+// key in UPDATE_KEY as hex, if it is newer than the running version and
+// check is not set. force installs the release even if it is the running
+// or an older version.
+func (cli *CLI) update(check, force bool) {
+	manifestURL := os.Getenv("UPDATE_URL")
+	if manifestURL == "" {
+		log.Panicln("Unable to update: env var UPDATE_URL is not set")
+	}
+	pubKey, err := hex.DecodeString(os.Getenv("UPDATE_KEY"))
+	if err != nil || len(pubKey) == 0 {
+		log.Panicln("Unable to update: env var UPDATE_KEY is not a public key")
+	}
+	client := &http.Client{Timeout: updateTimeout}
+
+	if check {
+		manifest, err := fetchRelease(client, manifestURL)
+		if err != nil {
+			log.Panicln("Unable to check for updates: ", err.Error())
+		}
+		cmp, err := compareVersions(manifest.Version, Version)
+		switch {
+		case err != nil:
+			fmt.Printf("Version %s is the latest release, running %s\n", manifest.Version, Version)
+		case cmp > 0:
+			fmt.Printf("Version %s is available, running %s\n", manifest.Version, Version)
+		case cmp < 0:
+			fmt.Printf("Version %s is the latest release, older than the running %s\n", manifest.Version, Version)
+		default:
+			fmt.Printf("Version %s is the latest release\n", Version)
+		}
+		return
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		log.Panicln("Unable to find the running binary: ", err.Error())
+	}
+	version, updated, err := applyUpdate(client, manifestURL, pubKey, exe, Version, force)
+	if err != nil {
+		log.Panicln("Unable to update: ", err.Error())
+	}
+	if !updated {
+		fmt.Printf("Version %s is the latest release\n", version)
+		return
+	}
+	fmt.Printf("Updated from %s to %s, the old binary is at %s.old\n", Version, version, exe)
+}
+
+// signRelease prints the manifest entry of the binary at path as the
+// release version for a platform, signed with the wallet for address as
+// the release key, and the UPDATE_KEY nodes verify it with.
+func (cli *CLI) signRelease(address, version, platform, url, path string) {
+	w, err := walletStore().Get(address)
+	if err != nil {
+		log.Panicln("Unable to load wallet: ", err.Error())
+	}
+	if w.Scheme() != keys.P256 {
+		log.Panicln("Unable to sign release: the release key must be a P-256 wallet")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Panicln("Unable to read binary: ", err.Error())
+	}
+	checksum := sha256.Sum256(data)
+	signature, pubKey, err := w.Sign(releaseDigest(version, platform, checksum[:]))
+	if err != nil {
+		log.Panicln("Unable to sign release: ", err.Error())
+	}
+
+	printJSON(map[string]interface{}{
+		"version":   version,
+		"updateKey": hex.EncodeToString(pubKey),
+		"binaries": map[string]releaseBinary{
+			platform: {URL: url, SHA256: hex.EncodeToString(checksum[:]), Signature: hex.EncodeToString(signature)},
+		},
+	})
+}
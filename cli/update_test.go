@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestApplyUpdate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test binaries are shell scripts")
+	}
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "gochain")
+	if err := ioutil.WriteFile(exe, []byte("#!/bin/sh\necho 1.0.0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// serve a release of a binary signed by the release key
+	key := wallet.NewFromSeed([]byte("release key"))
+	binary := []byte("#!/bin/sh\necho 1.1.0\n")
+	var manifest string
+	release := func(version string, binary []byte, signer *wallet.Wallet) {
+		checksum := sha256.Sum256(binary)
+		signature, _, err := signer.Sign(releaseDigest(version, platform(), checksum[:]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest = `{"version": "` + version + `", "binaries": {"` + platform() + `": {"url": "URL/binary", "sha256": "` +
+			hex.EncodeToString(checksum[:]) + `", "signature": "` + hex.EncodeToString(signature) + `"}}}`
+	}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/binary" {
+			w.Write(binary)
+			return
+		}
+		w.Write([]byte(strings.Replace(manifest, "URL", server.URL, 1)))
+	}))
+	defer server.Close()
+	update := func(force bool) (string, bool, error) {
+		return applyUpdate(server.Client(), server.URL+"/release.json", key.PublicKey, exe, "1.0.0", force)
+	}
+	running := func() string {
+		data, err := ioutil.ReadFile(exe)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// a binary signed by another key is refused
+	release("1.1.0", binary, wallet.NewFromSeed([]byte("attacker")))
+	if _, _, err := update(false); err == nil || !strings.Contains(running(), "1.0.0") {
+		t.Fatalf("installed a binary signed by another key, got %v", err)
+	}
+
+	// a binary that isn't the one signed is refused
+	release("1.1.0", []byte("#!/bin/sh\necho other\n"), key)
+	if _, _, err := update(false); err == nil || !strings.Contains(running(), "1.0.0") {
+		t.Fatalf("installed a binary with the wrong checksum, got %v", err)
+	}
+
+	// a binary that doesn't run as the release is rolled back
+	release("1.2.0", binary, key)
+	if _, _, err := update(false); err == nil || !strings.Contains(running(), "1.0.0") {
+		t.Fatalf("kept a binary reporting the wrong version, got %v", err)
+	}
+
+	// a signed release is swapped in, keeping the old binary
+	release("1.1.0", binary, key)
+	version, updated, err := update(false)
+	if err != nil || !updated || version != "1.1.0" {
+		t.Fatalf("got %s, %v, %v, want an update to 1.1.0", version, updated, err)
+	}
+	if !strings.Contains(running(), "1.1.0") {
+		t.Fatal("binary was not replaced")
+	}
+	if old, err := ioutil.ReadFile(exe + ".old"); err != nil || !strings.Contains(string(old), "1.0.0") {
+		t.Fatal("old binary was not kept")
+	}
+
+	// the running version isn't reinstalled unless forced, and no
+	// downloads are left behind
+	release("1.0.0", binary, key)
+	if _, updated, err := update(false); err != nil || updated {
+		t.Fatalf("got %v, %v, want no update of the running version", updated, err)
+	}
+
+	// an older release, such as a replayed manifest, is refused unless
+	// forced
+	older := []byte("#!/bin/sh\necho 0.9.0\n")
+	binary = older
+	release("0.9.0", older, key)
+	if _, updated, err := update(false); err == nil || updated || !strings.Contains(running(), "1.1.0") {
+		t.Fatalf("got %v, %v, want a downgrade refused", updated, err)
+	}
+	if _, updated, err := update(true); err != nil || !updated || !strings.Contains(running(), "0.9.0") {
+		t.Fatalf("got %v, %v, want a forced downgrade", updated, err)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), ".gochain-update-") {
+			t.Fatalf("download %s was left behind", file.Name())
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.2.0", "1.2", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"0.9.0", "1.0.0", -1},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"1.0.0-rc2", "1.0.0-rc1", 1},
+	} {
+		if got, err := compareVersions(c.a, c.b); err != nil || got != c.want {
+			t.Errorf("got %d, %v comparing %s and %s, want %d", got, err, c.a, c.b, c.want)
+		}
+	}
+	if _, err := compareVersions("1.0.0", "dev"); err == nil {
+		t.Error("compared a release with a dev build")
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+)
+
+// verifyChain verifies the best chain, checking the signatures of the
+// blocks from height from, or of the blocks after the latest checkpoint if
+// from is negative, so auditors can verify what syncing trusted. Corrupt
+// block records are first restored from the chain export at repairFile if
+// it is set. It runs as a job, resuming an interrupted run from the same
+// height.
+func (cli *CLI) verifyChain(from int, repairFile string) {
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	if repairFile != "" {
+		repairBlocks(bc, repairFile)
+	}
+
+	if from < 0 {
+		from = bc.CheckpointHeight() + 1
+	}
+	var verified int
+	err := cli.runJob("verifychain", from, func(ctx context.Context, resume *blockchain.JobProgress, progress func(blockchain.JobProgress)) error {
+		var err error
+		verified, err = bc.VerifyChainContext(ctx, from, resume, progress)
+		return err
+	})
+	if err == blockchain.ErrJobCancelled {
+		return
+	} else if err != nil {
+		if _, ok := err.(*blockchain.CorruptBlockError); ok {
+			fmt.Println("Corrupt block records can be restored with verifychain -repair and a chain export from a peer")
+		}
+		log.Panicf("Chain is invalid after verifying %d blocks: %s", verified, err.Error())
+	}
+	fmt.Printf("Verified %d blocks, with signatures from height %d\n", verified, from)
+}
+
+// auditChain replays the best chain from genesis to the tip, verifying
+// every block, signature and undo record and that the UTXO set matches the
+// chain, and prints a report. Corrupt block records are first restored from
+// the chain export at repairFile if it is set.
+func (cli *CLI) auditChain(repairFile string) {
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	if repairFile != "" {
+		repairBlocks(bc, repairFile)
+	}
+
+	report, err := bc.AuditChain(cli.ctx, nil)
+	if err == blockchain.ErrJobCancelled {
+		fmt.Printf("Audit cancelled after %d blocks\n", report.Blocks)
+		return
+	} else if err != nil {
+		log.Panicf("Chain is invalid after auditing %d blocks: %s", report.Blocks, err.Error())
+	}
+	fmt.Printf("Audited %d blocks and %d transactions\n", report.Blocks, report.Transactions)
+	fmt.Printf("UTXO set matches the chain: %d unspent outputs worth %s\n", report.Unspent, units.FormatAmount(report.Supply))
+}
+
+// repairBlocks restores the corrupt block records of bc from the chain
+// export at file, reporting the blocks it could not restore.
+func repairBlocks(bc *blockchain.BlockChain, file string) {
+	f, err := os.Open(file)
+	if err != nil {
+		log.Panicln("Unable to open chain export: ", err.Error())
+	}
+	defer f.Close()
+
+	repaired, unrepaired, err := bc.RepairFromExport(f)
+	if err != nil {
+		log.Panicln("Unable to repair block records: ", err.Error())
+	}
+	fmt.Printf("Repaired %d corrupt block records\n", repaired)
+	for _, hash := range unrepaired {
+		fmt.Printf("Block %x is corrupt and not in the export\n", hash)
+	}
+}
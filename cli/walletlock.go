@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// agentCommand is the hidden command walletunlock runs in the background
+// to keep the key of the wallets file in memory.
+const agentCommand = "walletagent"
+
+// agentPath returns the socket of the agent keeping the key of the wallets
+// file of the network.
+func agentPath() string {
+	return walletsAgentPath(walletsPath())
+}
+
+// walletsAgentPath returns the socket of the agent keeping the key of the
+// wallets file at path, so each wallets file is unlocked on its own.
+func walletsAgentPath(path string) string {
+	return path + ".agent"
+}
+
+// stdin buffers the lines commands read from stdin, shared so a command
+// reading several lines doesn't lose those buffered by an earlier read.
+var stdin = bufio.NewReader(os.Stdin)
+
+// readPassphrase prints prompt to stderr and reads a passphrase from a
+// line of stdin.
+func readPassphrase(prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		log.Panicln("Unable to read passphrase: ", err.Error())
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+// encryptWallet encrypts the wallets file with a passphrase read from
+// stdin, or changes the passphrase of an unlocked encrypted file.
+func (cli *CLI) encryptWallet() {
+	store := walletStore()
+	if err := store.Encrypt(readPassphrase("New passphrase: ")); err != nil {
+		log.Panicf("Unable to encrypt wallets file: %s", err.Error())
+	}
+	store.Lock()
+
+	// the key kept by walletunlock no longer decrypts the file
+	agentRequest(agentPath(), "lock")
+	fmt.Println("Wallets file encrypted, run walletunlock to use it")
+}
+
+// walletUnlock checks a passphrase read from stdin and starts an agent
+// keeping the key of the wallets file in memory for timeout, after which
+// it is wiped, so commands can use the wallets without the passphrase.
+func (cli *CLI) walletUnlock(timeout time.Duration) {
+	key, err := walletStore().Unlock(readPassphrase("Passphrase: "))
+	if err != nil {
+		log.Panicf("Unable to unlock wallets file: %s", err.Error())
+	}
+	data, err := json.Marshal(key)
+	key.Wipe()
+	if err != nil {
+		log.Panicf("Unable to encode key: %s", err.Error())
+	}
+
+	// replace the agent of an earlier unlock
+	agentRequest(agentPath(), "lock")
+
+	// run the agent in the background and wait for it to listen
+	exe, err := os.Executable()
+	if err != nil {
+		log.Panicf("Unable to find executable to run agent: %s", err.Error())
+	}
+	cmd := exec.Command(exe, "-network", network.Name, agentCommand, strconv.FormatInt(int64(timeout/time.Second), 10))
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Panicf("Unable to run agent: %s", err.Error())
+	}
+	if err := cmd.Start(); err != nil {
+		log.Panicf("Unable to run agent: %s", err.Error())
+	}
+	line, _ := bufio.NewReader(stdout).ReadString('\n')
+	for i := range data {
+		data[i] = 0
+	}
+	if line != "ready\n" {
+		log.Panicln("Agent failed to start")
+	}
+	cmd.Process.Release()
+
+	fmt.Printf("Wallets unlocked for %s\n", timeout)
+}
+
+// walletLock wipes the key kept by walletunlock.
+func (cli *CLI) walletLock() {
+	if _, err := agentRequest(agentPath(), "lock"); err != nil {
+		fmt.Println("Wallets were not unlocked")
+		return
+	}
+	fmt.Println("Wallets locked")
+}
+
+// runAgent runs the agent started by walletunlock, which reads the key from
+// stdin and keeps it for the number of seconds in args.
+func runAgent(args []string) {
+	if len(args) != 1 {
+		log.Panicln("Agent needs a timeout")
+	}
+	seconds, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Panicf("Agent has an invalid timeout: %s", args[0])
+	}
+	var key wallet.Key
+	if err := json.NewDecoder(os.Stdin).Decode(&key); err != nil {
+		log.Panicf("Agent is unable to read key: %s", err.Error())
+	}
+
+	// only the user can connect to the socket
+	path := agentPath()
+	os.Remove(path)
+	listener, err := listenPrivate(path)
+	if err != nil {
+		log.Panicf("Agent is unable to listen: %s", err.Error())
+	}
+
+	fmt.Println("ready")
+	serveKey(listener, &key, time.Duration(seconds)*time.Second)
+}
+
+// serveKey gives key to clients of listener that ask for it until a client
+// locks the wallets or timeout passes, and then wipes the key.
+func serveKey(listener net.Listener, key *wallet.Key, timeout time.Duration) {
+	defer key.Wipe()
+	timer := time.AfterFunc(timeout, func() { listener.Close() })
+	defer timer.Stop()
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		// answer a single request of the client
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		request, _ := bufio.NewReader(conn).ReadString('\n')
+		switch strings.TrimSpace(request) {
+		case "key":
+			json.NewEncoder(conn).Encode(key)
+		case "lock":
+			fmt.Fprintln(conn, "locked")
+			conn.Close()
+			return
+		}
+		conn.Close()
+	}
+}
+
+// agentRequest sends a request to the agent listening at path and returns
+// its response.
+func agentRequest(path, request string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return "", errors.New("wallets are not unlocked")
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := fmt.Fprintln(conn, request); err != nil {
+		return "", err
+	}
+	response, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", errors.New("agent did not respond - " + err.Error())
+	}
+	return response, nil
+}
+
+// agentKey returns the key kept by the agent listening at path.
+func agentKey(path string) (*wallet.Key, error) {
+	response, err := agentRequest(path, "key")
+	if err != nil {
+		return nil, err
+	}
+	var key wallet.Key
+	if err := json.Unmarshal([]byte(response), &key); err != nil {
+		return nil, errors.New("unable to decode key from agent - " + err.Error())
+	}
+	return &key, nil
+}
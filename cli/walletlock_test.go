@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// startAgent serves key at a socket in a temporary directory until timeout,
+// returning the socket and a channel closed when the agent stops.
+func startAgent(t *testing.T, key *wallet.Key, timeout time.Duration) (string, chan struct{}) {
+	path := filepath.Join(t.TempDir(), "agent")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		serveKey(listener, key, timeout)
+		close(done)
+	}()
+	return path, done
+}
+
+func TestAgentKeepsKeyUntilLocked(t *testing.T) {
+	key := &wallet.Key{Iterations: 1, Salt: []byte("salt"), Secret: []byte("secret")}
+	path, done := startAgent(t, key, time.Minute)
+
+	got, err := agentKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Secret, []byte("secret")) || !bytes.Equal(got.Salt, []byte("salt")) {
+		t.Fatalf("got key %+v", got)
+	}
+
+	if _, err := agentRequest(path, "lock"); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if key.Secret != nil {
+		t.Fatal("key was not wiped")
+	}
+	if _, err := agentKey(path); err == nil {
+		t.Fatal("got key after locking")
+	}
+}
+
+func TestAgentWipesKeyAfterTimeout(t *testing.T) {
+	key := &wallet.Key{Iterations: 1, Salt: []byte("salt"), Secret: []byte("secret")}
+	path, done := startAgent(t, key, 50*time.Millisecond)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent did not time out")
+	}
+	if key.Secret != nil {
+		t.Fatal("key was not wiped")
+	}
+	if _, err := agentKey(path); err == nil {
+		t.Fatal("got key after the timeout")
+	}
+}
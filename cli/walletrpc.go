@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// defaultWallet is the name serve gives the wallets file of the network.
+const defaultWallet = "default"
+
+// walletNamePattern matches the names of the wallets files of serve, which
+// are part of the paths of their endpoints.
+var walletNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// walletFlags collects the -wallet NAME=PATH and -wallet NAME flags of
+// serve.
+type walletFlags []string
+
+// String returns the flags separated by commas.
+func (f *walletFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set adds a -wallet flag.
+func (f *walletFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// stores returns the wallets files of the flags by name, along with the
+// wallets file of the network under its name, default unless -wallet
+// selected another, and their paths. A flag without a path serves the
+// wallets file of the network called NAME.
+func (f walletFlags) stores() (map[string]*wallet.Store, []string, error) {
+	stores := map[string]*wallet.Store{selectedWallet(): walletStore()}
+	paths := []string{walletsPath()}
+	for _, flag := range f {
+		parts := strings.SplitN(flag, "=", 2)
+		if len(parts) == 1 && walletNamePattern.MatchString(parts[0]) {
+			parts = append(parts, namedWalletsPath(parts[0]))
+		}
+		if len(parts) != 2 || parts[1] == "" || !walletNamePattern.MatchString(parts[0]) {
+			return nil, nil, fmt.Errorf("wallet %q is not NAME=PATH or NAME with a name of letters, digits, - and _", flag)
+		}
+		if stores[parts[0]] != nil {
+			return nil, nil, fmt.Errorf("wallet %s is given twice", parts[0])
+		}
+		stores[parts[0]] = wallet.NewStore(parts[1])
+		paths = append(paths, parts[1])
+	}
+	return stores, paths, nil
+}
+
+// walletSend is the body posted to /wallet/NAME/send. Amounts are numbers
+// of base units or coin strings.
+type walletSend struct {
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	Amount    units.Amount `json:"amount"`
+	Fee       units.Amount `json:"fee"`
+	RequestID string       `json:"requestId"`
+}
+
+// walletNames returns the names of the wallets files of the node in order.
+func (n *node) walletNames() []string {
+	var names []string
+	for name := range n.wallets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handleWallets serves the wallets of the node at /wallets like
+// /wallet/NAME/wallets when it has a single wallets file. With several,
+// the wallets file must be selected by name, so a client can't act on
+// the wrong one.
+func (n *node) handleWallets(w http.ResponseWriter, r *http.Request) {
+	if !n.authorized(w, r, scopeWallet) {
+		return
+	}
+	if len(n.wallets) != 1 {
+		http.Error(w, fmt.Sprintf("node has wallets %s, select one at /wallet/NAME/wallets", strings.Join(n.walletNames(), ", ")), http.StatusBadRequest)
+		return
+	}
+	for _, store := range n.wallets {
+		n.serveWallets(w, r, store)
+	}
+}
+
+// handleWallet serves the endpoints of the wallets file called NAME at
+// /wallet/NAME/wallets and /wallet/NAME/send.
+func (n *node) handleWallet(w http.ResponseWriter, r *http.Request) {
+	if !n.authorized(w, r, scopeWallet) {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/wallet/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	store := n.wallets[parts[0]]
+	if store == nil {
+		http.Error(w, fmt.Sprintf("no wallet %s, node has wallets %s", parts[0], strings.Join(n.walletNames(), ", ")), http.StatusNotFound)
+		return
+	}
+	switch parts[1] {
+	case "wallets":
+		n.serveWallets(w, r, store)
+	case "send":
+		n.serveSend(w, r, parts[0], store)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveSend serves sendFromWallet at /wallet/NAME/send.
+func (n *node) serveSend(w http.ResponseWriter, r *http.Request, name string, store *wallet.Store) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var send walletSend
+	if err := json.NewDecoder(r.Body).Decode(&send); err != nil {
+		http.Error(w, "invalid send - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	txID, status, err := n.sendFromWallet(name, store, send)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, map[string]string{"txid": hex.EncodeToString(txID), "wallet": name})
+}
+
+// sendFromWallet sends coins from an address of the wallets file called
+// name, refusing addresses of other wallets files, and adds the
+// transaction to the mempool, where the node mines it if it was run with
+// -mine. A request id makes retries return the transaction of the first
+// attempt. It returns the id of the transaction, or an error with the HTTP
+// status it is served with.
+func (n *node) sendFromWallet(name string, store *wallet.Store, send walletSend) ([]byte, int, error) {
+	if !wallet.ValidateAddress(send.From) || !wallet.ValidateAddress(send.To) {
+		return nil, http.StatusBadRequest, errors.New("from or to address not valid")
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if send.RequestID != "" {
+		if txID, ok := n.bc.RequestTransaction(send.RequestID); ok {
+			return txID, http.StatusOK, nil
+		}
+	}
+
+	// only the keys of the selected wallets file can pay
+	wallets, err := store.Wallets()
+	if err == wallet.ErrLocked {
+		return nil, http.StatusServiceUnavailable, errors.New("wallets file is locked, run walletunlock on the node")
+	} else if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	from, ok := wallets[send.From]
+	if !ok {
+		return nil, http.StatusBadRequest, fmt.Errorf("address %s is not in wallet %s", send.From, name)
+	}
+
+	// send any change to a fresh key of the same wallets file
+	change := wallet.CreateWallet()
+	payments := []blockchain.Payment{{To: send.To, Amount: send.Amount}}
+	tx, err := n.bc.NewPaymentTransaction(from, payments, send.Fee, change.Address().String())
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if tx.ValueTo(wallet.GeneratePublicKeyHash(change.PublicKey)) > 0 {
+		if err := store.Add(change); err != nil {
+			return nil, http.StatusInternalServerError, errors.New("unable to save change address - " + err.Error())
+		}
+	}
+	if send.RequestID != "" {
+		err = n.bc.AddRequestToMempool(send.RequestID, tx)
+	} else {
+		err = n.bc.AddToMempool(tx)
+	}
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return tx.ID, http.StatusOK, nil
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestWalletScoping(t *testing.T) {
+	dir := t.TempDir()
+	stores := map[string]*wallet.Store{
+		defaultWallet: wallet.NewStore(filepath.Join(dir, "default.dat")),
+		"cold":        wallet.NewStore(filepath.Join(dir, "cold.dat")),
+	}
+	hot, err := stores[defaultWallet].Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold, err := stores["cold"].Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := blockchain.InitInMemory(&blockchain.Genesis{
+		Network: "wallets",
+		Allocations: map[string]units.Amount{
+			hot.Address().String():  100 * units.Coin,
+			cold.Address().String(): 100 * units.Coin,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	n := &node{bc: bc, token: "secret", wallets: stores}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wallets", n.handleWallets)
+	mux.HandleFunc("/wallet/", n.handleWallet)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	request := func(method, path string, body interface{}) *http.Response {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, server.URL+path, bytes.NewReader(data))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// with several wallets files, one must be selected
+	if resp := request(http.MethodGet, "/wallets", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got %s from /wallets, want a bad request", resp.Status)
+	}
+	export, err := fetchWallets(server.URL+"/wallet/cold", "secret", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Descriptors) != 1 || export.Descriptors[0] != cold.Descriptor().String() {
+		t.Fatalf("got %v, want the descriptor of the cold wallet", export.Descriptors)
+	}
+	if resp := request(http.MethodGet, "/wallet/spare/wallets", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("got %s for an unknown wallet, want not found", resp.Status)
+	}
+
+	// a wallet can only pay from its own addresses
+	send := walletSend{From: cold.Address().String(), To: hot.Address().String(), Amount: units.Coin}
+	if resp := request(http.MethodPost, "/wallet/default/send", send); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got %s sending from another wallet, want a bad request", resp.Status)
+	}
+	if len(bc.MempoolTransactions()) != 0 {
+		t.Fatal("payment from another wallet reached the mempool")
+	}
+	if resp := request(http.MethodPost, "/wallet/cold/send", send); resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %s sending from the cold wallet", resp.Status)
+	}
+	if len(bc.MempoolTransactions()) != 1 {
+		t.Fatal("payment from the cold wallet is not pending")
+	}
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// walletsFile describes a wallets file of the network. Its addresses are
+// only counted if it isn't encrypted or walletunlock unlocked it.
+type walletsFile struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Encrypted bool   `json:"encrypted"`
+	Locked    bool   `json:"locked,omitempty"`
+	Addresses int    `json:"addresses"`
+	Selected  bool   `json:"selected"`
+}
+
+// selectedWallet returns the name of the wallets file commands use.
+func selectedWallet() string {
+	if config.Wallet == "" {
+		return defaultWallet
+	}
+	return config.Wallet
+}
+
+// walletsFileNames returns the names of the wallets files of the network
+// in order: default, those found next to it, and the selected one even if
+// it hasn't been created yet.
+func walletsFileNames() ([]string, error) {
+	names := map[string]bool{defaultWallet: true, selectedWallet(): true}
+
+	// named wallets files are the default path with a name before its
+	// extension, so find the files of that form next to it
+	dir := filepath.Dir(config.WalletsFile)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	ext := filepath.Ext(config.WalletsFile)
+	prefix := strings.TrimSuffix(filepath.Base(config.WalletsFile), ext) + "-"
+	suffix := ext + network.PathSuffix
+	for _, entry := range entries {
+		base := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(base, prefix) || !strings.HasSuffix(base, suffix) || len(base) <= len(prefix)+len(suffix) {
+			continue
+		}
+		if name := base[len(prefix) : len(base)-len(suffix)]; walletNamePattern.MatchString(name) {
+			names[name] = true
+		}
+	}
+
+	sorted := []string{defaultWallet}
+	for name := range names {
+		if name != defaultWallet {
+			sorted = append(sorted, name)
+		}
+	}
+	sort.Strings(sorted[1:])
+	return sorted, nil
+}
+
+// describeWalletsFile describes the wallets file called name.
+func describeWalletsFile(name string) (walletsFile, error) {
+	path := namedWalletsPath(name)
+	file := walletsFile{Name: name, Path: path, Selected: name == selectedWallet()}
+	store := openWalletStore(path)
+	encrypted, err := store.Encrypted()
+	if err != nil {
+		return file, err
+	}
+	file.Encrypted = encrypted
+	addresses, err := store.List()
+	if err == wallet.ErrLocked {
+		file.Locked = true
+		return file, nil
+	} else if err != nil {
+		return file, err
+	}
+	file.Addresses = len(addresses)
+	return file, nil
+}
+
+// listWallets prints the wallets files of the network, marking the one
+// commands use, which -wallet or the DEFAULT_WALLET env var select.
+func (cli *CLI) listWallets(asJSON bool) {
+	names, err := walletsFileNames()
+	if err != nil {
+		log.Panicln("Unable to list wallets files: ", err.Error())
+	}
+	files := []walletsFile{}
+	for _, name := range names {
+		file, err := describeWalletsFile(name)
+		if err != nil {
+			log.Panicf("Unable to read wallets file %s: %s", name, err.Error())
+		}
+		files = append(files, file)
+	}
+
+	if asJSON {
+		printJSON(files)
+		return
+	}
+	for _, file := range files {
+		marker := " "
+		if file.Selected {
+			marker = "*"
+		}
+		addresses := fmt.Sprintf("%d addresses", file.Addresses)
+		if file.Locked {
+			addresses = "locked"
+		}
+		state := "unencrypted"
+		if file.Encrypted {
+			state = "encrypted"
+		}
+		fmt.Printf("%s %s\t%s, %s\t%s\n", marker, file.Name, state, addresses, file.Path)
+	}
+}
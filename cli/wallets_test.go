@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalletsFileNames(t *testing.T) {
+	defer func(cfg Config) { config = cfg }(config)
+	config.WalletsFile = filepath.Join(t.TempDir(), "wallets.data")
+	config.Wallet = "hot"
+
+	// wallets files are found by name next to the default one
+	for _, name := range []string{"savings", "cold"} {
+		if _, err := openWalletStore(namedWalletsPath(name)).Create(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := walletsFileNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"default", "cold", "hot", "savings"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("got wallets files %q, want %q", names, want)
+	}
+
+	file, err := describeWalletsFile("savings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Addresses != 1 || file.Encrypted || file.Selected {
+		t.Fatalf("got %+v for the savings wallets file", file)
+	}
+	if file, err := describeWalletsFile("hot"); err != nil || file.Addresses != 0 || !file.Selected {
+		t.Fatalf("got %+v, %v for the selected wallets file", file, err)
+	}
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// pollInterval is how often the chain is checked while waiting.
+const pollInterval = 5 * time.Second
+
+// poll calls check with a freshly opened blockchain until it returns true or
+// ctx is done. The database is closed between checks so that other
+// processes can mine blocks in the meantime. It returns the error of ctx if
+// check never returned true.
+func poll(ctx context.Context, check func(bc *blockchain.BlockChain) bool) error {
+	return pollUntil(ctx, func() bool { return tryCheck(check) })
+}
+
+// pollUntil calls done every pollInterval until it returns true or ctx is
+// done, returning the error of ctx if done never returned true.
+func pollUntil(ctx context.Context, done func() bool) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if done() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollWithTimeout polls like poll, stopping after timeout or when the
+// process is asked to shut down.
+func (cli *CLI) pollWithTimeout(timeout time.Duration, check func(bc *blockchain.BlockChain) bool) error {
+	ctx, cancel := context.WithTimeout(cli.ctx, timeout)
+	defer cancel()
+
+	return poll(ctx, check)
+}
+
+// watchAddress waits until a new confirmed payment of at least amount is
+// made to address and prints the funding transaction id.
+func (cli *CLI) watchAddress(address string, amount units.Amount, timeout time.Duration) {
+	if !wallet.ValidateAddress(address) {
+		log.Panicln("Unable to watch address: address not valid")
+	}
+	pubKeyHash := pubKeyHashFromAddress(address)
+
+	// record payments that were made before watching started
+	seen := make(map[string]bool)
+	bc := openBlockChain("")
+	for _, tx := range bc.FindPayments(pubKeyHash) {
+		seen[hex.EncodeToString(tx.ID)] = true
+	}
+	bc.Close()
+
+	fmt.Printf("Waiting for a payment of %s to %s\n", units.FormatAmount(amount), address)
+
+	var funding []byte
+	err := cli.pollWithTimeout(timeout, func(bc *blockchain.BlockChain) bool {
+		for _, tx := range bc.FindPayments(pubKeyHash) {
+			if !seen[hex.EncodeToString(tx.ID)] && tx.ValueTo(pubKeyHash) >= amount {
+				funding = tx.ID
+				return true
+			}
+		}
+		return false
+	})
+	if err == context.DeadlineExceeded {
+		log.Panicf("Timed out after %s waiting for a payment to %s", timeout, address)
+	} else if err != nil {
+		log.Panicln("Stopped waiting for a payment: ", err.Error())
+	}
+
+	fmt.Printf("%x\n", funding)
+}
+
+// tryCheck opens the blockchain read-only only for the duration of a
+// check. The database may be locked by another process, in which case the
+// check is treated as not done so it can be retried. Any other failure
+// panics.
+func tryCheck(check func(bc *blockchain.BlockChain) bool) bool {
+	bc, err := openReadOnly("")
+	if err == blockchain.ErrLocked {
+		return false
+	} else if err != nil {
+		log.Panicf("Unable to open blockchain: %s", err.Error())
+	}
+	defer bc.Close()
+
+	return check(bc)
+}
+
+// waitForConfirmations waits until a transaction is buried the given number
+// of blocks deep, printing progress as new blocks are mined.
+func (cli *CLI) waitForConfirmations(txID blockchain.Hash, confirmations int, timeout time.Duration) {
+	last := -1
+	confirmed := func(current int) bool {
+		if current != last {
+			fmt.Printf("Transaction %s has %d/%d confirmations\n", txID, current, confirmations)
+			last = current
+		}
+		return current >= confirmations
+	}
+
+	// ask the node running on this machine if there is one, as it holds
+	// the database
+	var err error
+	if node := localNode(); node != nil {
+		ctx, cancel := context.WithTimeout(cli.ctx, timeout)
+		defer cancel()
+		err = pollUntil(ctx, func() bool {
+			current, err := node.confirmations(txID)
+			if err != nil {
+				log.Panicln("Unable to get confirmations: ", err.Error())
+			}
+			return confirmed(current)
+		})
+	} else {
+		err = cli.pollWithTimeout(timeout, func(bc *blockchain.BlockChain) bool {
+			return confirmed(bc.Confirmations(txID))
+		})
+	}
+	if err == context.DeadlineExceeded {
+		log.Panicf("Timed out after %s waiting for %d confirmations", timeout, confirmations)
+	} else if err != nil {
+		log.Panicln("Stopped waiting for confirmations: ", err.Error())
+	}
+}
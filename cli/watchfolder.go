@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+)
+
+// watchSettle is how long a file must be left unchanged in the watch
+// folder before it is read, so files still being copied in are not read
+// partway.
+const watchSettle = 2 * time.Second
+
+// The subfolders of the watch folder files are moved to once submitted or
+// refused.
+const (
+	processedFolder = "processed"
+	failedFolder    = "failed"
+)
+
+// readSignedTransaction reads a signed transaction from the contents of a
+// file, either a raw transaction as hex from signrawtx or a proposal signed
+// by approve.
+func readSignedTransaction(data []byte) (*blockchain.Transaction, error) {
+	text := strings.TrimSpace(string(data))
+	if _, err := hex.DecodeString(text); err == nil && text != "" {
+		r, err := blockchain.DecodeRawTransaction(text)
+		if err != nil {
+			return nil, err
+		}
+		if r.Tx.ID == nil {
+			return nil, errors.New("raw transaction has not been signed")
+		}
+		return &r.Tx, nil
+	}
+	p, err := blockchain.DeserializeProposal(data)
+	if err != nil {
+		return nil, errors.New("file holds neither a raw transaction as hex nor a proposal")
+	}
+	if p.Tx.ID == nil {
+		return nil, errors.New("proposal has not been approved")
+	}
+	return &p.Tx, nil
+}
+
+// processWatchFolder adds the signed transactions in the files of dir to
+// the mempool, moving each file to the processed subfolder once added or to
+// the failed subfolder with the reason in a .error file next to it. Hidden
+// files and files changed within settle are left for a later pass.
+func processWatchFolder(bc *blockchain.BlockChain, dir string, settle time.Duration) error {
+	for _, sub := range []string{processedFolder, failedFolder} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return err
+		}
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") || time.Since(file.ModTime()) < settle {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		// submit the transaction, recording why it was refused
+		tx, err := readSignedTransaction(data)
+		if err == nil {
+			err = bc.AddToMempool(tx)
+		}
+		if err != nil {
+			logger.Warn("Refused transaction file", "file", file.Name(), "error", err.Error())
+			dest, moveErr := moveUnique(path, filepath.Join(dir, failedFolder))
+			if moveErr != nil {
+				return moveErr
+			}
+			if err := ioutil.WriteFile(dest+".error", []byte(err.Error()+"\n"), 0644); err != nil {
+				return err
+			}
+			continue
+		}
+		logger.Info("Added transaction file to mempool", "file", file.Name(), "txid", hex.EncodeToString(tx.ID))
+		if _, err := moveUnique(path, filepath.Join(dir, processedFolder)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveUnique moves the file at path into dir, adding a number to its name
+// if dir already holds a file of that name, and returns its new path.
+func moveUnique(path, dir string) (string, error) {
+	name := filepath.Base(path)
+	dest := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			break
+		}
+		dest = filepath.Join(dir, fmt.Sprintf("%s.%d", name, i))
+	}
+	return dest, os.Rename(path, dest)
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+func TestProcessWatchFolder(t *testing.T) {
+	hot := wallet.NewFromSeed([]byte("hot"))
+	cold := wallet.NewFromSeed([]byte("cold"))
+	bc, err := blockchain.InitInMemory(&blockchain.Genesis{
+		Network: "watch",
+		Allocations: map[string]units.Amount{
+			hot.Address().String():  100 * units.Coin,
+			cold.Address().String(): 100 * units.Coin,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	dir := t.TempDir()
+	write := func(name string, data []byte) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(path ...string) bool {
+		_, err := os.Stat(filepath.Join(append([]string{dir}, path...)...))
+		return err == nil
+	}
+
+	// a raw transaction from signrawtx and a proposal signed by approve
+	tx, err := bc.NewTransaction(hot, cold.Address().String(), units.Coin, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("raw.hex", []byte((&blockchain.RawTransaction{Tx: *tx}).Hex()+"\n"))
+	p, err := bc.ProposeTransaction(cold.Address().String(), hot.Address().String(), units.Coin, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Approve(cold); err != nil {
+		t.Fatal(err)
+	}
+	write("approved.dat", p.Serialize())
+
+	// a file that isn't a transaction, one read after raw.hex spending the
+	// same outputs, and a hidden file being copied in
+	write("junk.txt", []byte("not a transaction"))
+	write("respent.hex", []byte((&blockchain.RawTransaction{Tx: *tx}).Hex()))
+	write(".partial", []byte("in progress"))
+
+	if err := processWatchFolder(bc, dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(bc.MempoolTransactions()) != 2 {
+		t.Fatalf("got %d pending transactions, want 2", len(bc.MempoolTransactions()))
+	}
+	for _, name := range []string{"raw.hex", "approved.dat"} {
+		if !exists(processedFolder, name) || exists(name) {
+			t.Errorf("%s was not moved to the processed folder", name)
+		}
+	}
+	for _, name := range []string{"junk.txt", "respent.hex"} {
+		if !exists(failedFolder, name) || !exists(failedFolder, name+".error") || exists(name) {
+			t.Errorf("%s was not moved to the failed folder with its error", name)
+		}
+	}
+	if !exists(".partial") {
+		t.Error("hidden file was moved")
+	}
+
+	// files changed within the settle time are left for a later pass, and
+	// names already in a subfolder are kept
+	write("junk.txt", []byte("again"))
+	if err := processWatchFolder(bc, dir, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if !exists("junk.txt") {
+		t.Fatal("a file that had just changed was moved")
+	}
+	if err := processWatchFolder(bc, dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(failedFolder, "junk.txt.1") || !exists(failedFolder, "junk.txt") {
+		t.Fatal("a failed file of the same name was overwritten")
+	}
+}
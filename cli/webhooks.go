@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/events"
+)
+
+// webhookTimeout is how long a webhook URL has to take an event.
+const webhookTimeout = 10 * time.Second
+
+// webhooks adds a webhook posting the events of topics, separated by
+// commas, or every event if there are none, to url, or removes it if remove
+// is set. The events are posted by serve. Without a URL, the webhooks are
+// listed.
+func (cli *CLI) webhooks(url, topics string, remove, asJSON bool) {
+	bc := openBlockChain("")
+	defer bc.Close()
+
+	// list the webhooks
+	if url == "" {
+		webhooks, err := bc.Webhooks()
+		if err != nil {
+			log.Panicln("Unable to list webhooks: ", err.Error())
+		}
+		if asJSON {
+			printJSON(webhooks)
+			return
+		}
+		for _, w := range webhooks {
+			fmt.Printf("%s for %s\n", w.URL, webhookTopics(w))
+		}
+		return
+	}
+
+	if remove {
+		found, err := bc.RemoveWebhook(url)
+		if err != nil {
+			log.Panicln("Unable to remove webhook: ", err.Error())
+		}
+		if !found {
+			log.Panicln("Unable to remove webhook: no webhook of", url)
+		}
+		fmt.Printf("Stopped posting events to %s\n", url)
+		return
+	}
+
+	w := events.Webhook{URL: url}
+	if topics != "" {
+		w.Topics = strings.Split(topics, ",")
+	}
+	if err := bc.AddWebhook(w); err != nil {
+		log.Panicln("Unable to add webhook: ", err.Error())
+	}
+	fmt.Printf("Posting %s to %s\n", webhookTopics(w), url)
+}
+
+// webhookTopics describes the topics of a webhook.
+func webhookTopics(w events.Webhook) string {
+	if len(w.Topics) == 0 {
+		return "every event"
+	}
+	return strings.Join(w.Topics, ", ")
+}
+
+// webhookSecret returns the secret signing the events posted to webhooks
+// and deposit watches, from the WEBHOOK_SECRET env var. Events aren't
+// signed without it.
+func webhookSecret() []byte {
+	return []byte(os.Getenv("WEBHOOK_SECRET"))
+}
+
+// startWebhooks posts the events of bus to the webhooks stored in the
+// chain and to the URLs in the WEBHOOKS env var, separated by commas, which
+// are posted every event.
+func startWebhooks(bc *blockchain.BlockChain, bus *events.Bus) *events.Webhooks {
+	webhooks, err := bc.Webhooks()
+	if err != nil {
+		log.Panicln("Unable to load webhooks: ", err.Error())
+	}
+	for _, url := range strings.Split(os.Getenv("WEBHOOKS"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			webhooks = append(webhooks, events.Webhook{URL: url})
+		}
+	}
+
+	h := events.NewWebhooks(bus, &http.Client{Timeout: webhookTimeout}, webhookSecret())
+	for _, w := range webhooks {
+		if err := h.Add(w); err != nil {
+			log.Panicf("Unable to add webhook %s: %s", w.URL, err.Error())
+		}
+	}
+	return h
+}
+
+// handleWebhooks lists the webhooks of the node on GET, adds the Webhook
+// posted as JSON on POST, and removes the webhook of the url query
+// parameter on DELETE. Webhooks added and removed are stored, so they last
+// across restarts.
+func (n *node) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !n.authorized(w, r, scopeWallet) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, n.webhooks.List())
+	case http.MethodPost:
+		var webhook events.Webhook
+		if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+			http.Error(w, "invalid webhook - "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := n.bc.AddWebhook(webhook); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := n.webhooks.Add(webhook); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, webhook)
+	case http.MethodDelete:
+		url := r.URL.Query().Get("url")
+		if _, err := n.bc.RemoveWebhook(url); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !n.webhooks.Remove(url) {
+			http.Error(w, "no such webhook", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]bool{"removed": true})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,33 @@
+// Package consensus holds the rules every node of a network must apply the
+// same way to accept a block: its proof of work, the block subsidy, the
+// size limits and the spending and signature rules of its transactions.
+//
+// The rules work on the in-memory types of this package and a UTXOView of
+// the outputs blocks spend, without a database, so they can be tested on
+// their own and reused by light clients. The blockchain package applies
+// them to the blocks it stores, with a view of its UTXO set.
+package consensus
+
+import "github.com/edwintcloud/gochain/units"
+
+const (
+	// Subsidy is the amount of new tokens in base units rewarded for
+	// mining a block.
+	Subsidy = 100 * units.Coin
+
+	// CoinbaseMaturity is the number of confirmations a mining reward needs
+	// before balance queries count it as trusted. Rewards vanish with their
+	// block when it is reorganized away, so younger ones are reported apart
+	// as immature. Blocks may spend younger rewards.
+	CoinbaseMaturity = 100
+
+	// DefaultDifficulty is the difficulty of blocks created before the
+	// difficulty was recorded in them.
+	DefaultDifficulty = 18
+)
+
+// Mature returns whether a mining reward with confirmations confirmations
+// is old enough to be trusted.
+func Mature(confirmations int) bool {
+	return confirmations >= CoinbaseMaturity
+}
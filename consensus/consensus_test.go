@@ -0,0 +1,173 @@
+package consensus
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// mapView is a UTXOView of the outputs in a map keyed by OutPoint.String.
+type mapView map[string]Output
+
+func (v mapView) UnspentOutput(p OutPoint) (Output, bool, error) {
+	out, ok := v[p.String()]
+	return out, ok, nil
+}
+
+func TestCheckBlockSpends(t *testing.T) {
+	funding := OutPoint{TxID: []byte{1}, Index: 0}
+	maxAmount := units.Amount(^uint(0) >> 1)
+	large := []OutPoint{{TxID: []byte{8}, Index: 0}, {TxID: []byte{8}, Index: 1}}
+	view := mapView{
+		funding.String():  {Value: 1000, Lock: []byte("alice")},
+		large[0].String(): {Value: maxAmount},
+		large[1].String(): {Value: maxAmount},
+	}
+	coinbase := func(rewards ...units.Amount) Tx {
+		tx := Tx{ID: []byte{2}, Coinbase: true}
+		for _, reward := range rewards {
+			tx.Outputs = append(tx.Outputs, Output{Value: reward})
+		}
+		return tx
+	}
+	pay := Tx{ID: []byte{3}, Inputs: []OutPoint{funding}, Outputs: []Output{{Value: 900}, {Data: true}}}
+	child := Tx{ID: []byte{4}, Inputs: []OutPoint{{TxID: pay.ID}}, Outputs: []Output{{Value: 850}}}
+
+	for _, c := range []struct {
+		name string
+		txs  []Tx
+		want string
+	}{
+		{"valid", []Tx{coinbase(Subsidy + 150), pay, child}, ""},
+		{"no coinbase", []Tx{pay}, "not a coinbase"},
+		{"two coinbases", []Tx{coinbase(1), coinbase(1)}, "more than one coinbase"},
+		{"missing output", []Tx{coinbase(), child}, "missing or spent"},
+		{"double spend", []Tx{coinbase(), pay, pay}, "twice in the block"},
+		{"data output", []Tx{coinbase(), pay, {ID: []byte{5}, Inputs: []OutPoint{{TxID: pay.ID, Index: 1}}}}, "missing or spent"},
+		{"overspend", []Tx{coinbase(), {ID: []byte{6}, Inputs: []OutPoint{funding}, Outputs: []Output{{Value: 1001}}}}, "exceed its inputs"},
+		{"negative output", []Tx{coinbase(), {ID: []byte{7}, Inputs: []OutPoint{funding}, Outputs: []Output{{Value: -1}}}}, "not positive"},
+		{"zero output", []Tx{coinbase(), {ID: []byte{7}, Inputs: []OutPoint{funding}, Outputs: []Output{{Value: 0}}}}, "not positive"},
+		{"zero coinbase output", []Tx{coinbase(0)}, "not positive"},
+		{"inflated coinbase", []Tx{coinbase(Subsidy + 101), pay}, "more than the subsidy"},
+
+		// totals that wrap around must not let a block create coins
+		{"coinbase overflow", []Tx{coinbase(maxAmount, maxAmount, 2)}, "overflow"},
+		{"output overflow", []Tx{coinbase(), {ID: []byte{9}, Inputs: []OutPoint{funding}, Outputs: []Output{{Value: maxAmount}, {Value: maxAmount}, {Value: 2}}}}, "overflow"},
+		{"input overflow", []Tx{coinbase(), {ID: []byte{9}, Inputs: large, Outputs: []Output{{Value: 1}}}}, "overflow"},
+		{"fee overflow", []Tx{coinbase(), {ID: []byte{9}, Inputs: large[:1], Outputs: []Output{{Value: 1}}}, {ID: []byte{10}, Inputs: large[1:], Outputs: []Output{{Value: 1}}}, pay}, "overflow"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := CheckBlockSpends(view, c.txs, nil)
+			if c.want == "" && err != nil {
+				t.Fatal(err)
+			}
+			if c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)) {
+				t.Fatalf("got error %v, want %q", err, c.want)
+			}
+		})
+	}
+
+	// signatures are checked with the outputs the inputs spend
+	var checked []Output
+	err := CheckBlockSpends(view, []Tx{coinbase(), pay}, func(tx int, spent []Output) bool {
+		checked = spent
+		return false
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid signature") {
+		t.Fatalf("got error %v, want the signature refused", err)
+	}
+	if len(checked) != 1 || !bytes.Equal(checked[0].Lock, []byte("alice")) {
+		t.Fatalf("got spent outputs %+v, want the funding output", checked)
+	}
+}
+
+func TestCheckTxSize(t *testing.T) {
+	if err := CheckTxSize(nil, MaxTxInputs, MaxTxOutputs, MaxTxSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckTxSize(nil, MaxTxInputs+1, 1, 1); err == nil {
+		t.Fatal("allowed too many inputs")
+	}
+	if err := CheckTxSize(nil, 1, 1, MaxTxSize+1); err == nil {
+		t.Fatal("allowed a transaction over the size limit")
+	}
+	if err := CheckBlockSize(nil, MaxBlockSize+1); err == nil {
+		t.Fatal("allowed a block over the size limit")
+	}
+}
+
+func TestCheckProofOfWork(t *testing.T) {
+	hash := make([]byte, 32)
+	hash[1] = 0x80
+	if !CheckProofOfWork(hash, 8) {
+		t.Fatal("hash below the target of difficulty 8 refused")
+	}
+	if CheckProofOfWork(hash, 9) {
+		t.Fatal("hash above the target of difficulty 9 accepted")
+	}
+}
+
+func TestMature(t *testing.T) {
+	if Mature(CoinbaseMaturity-1) || !Mature(CoinbaseMaturity) {
+		t.Fatal("maturity does not start at CoinbaseMaturity confirmations")
+	}
+}
+
+func FuzzCheckBlockSpends(f *testing.F) {
+	f.Add([]byte{0, 0, 50})
+	f.Add([]byte{0, 0, 50, 0, 0, 50})
+	f.Add([]byte{1, 0, 90, 4, 1, 80, 5, 0, 70})
+
+	// each three bytes are a transaction spending two outputs, picked among
+	// four funding outputs and the outputs of earlier transactions, into
+	// one output of the third byte
+	view := mapView{}
+	for i := 0; i < 4; i++ {
+		view[OutPoint{TxID: []byte{0xf0}, Index: i}.String()] = Output{Value: 100}
+	}
+	pick := func(txs []Tx, b byte) OutPoint {
+		if b%8 < 4 || len(txs) == 1 {
+			return OutPoint{TxID: []byte{0xf0}, Index: int(b % 4)}
+		}
+		return OutPoint{TxID: txs[1+int(b)%(len(txs)-1)].ID}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		txs := []Tx{{ID: []byte{0}, Coinbase: true}}
+		for i := 0; i+2 < len(data) && len(txs) < 64; i += 3 {
+			txs = append(txs, Tx{
+				ID:      []byte{byte(len(txs))},
+				Inputs:  []OutPoint{pick(txs, data[i]), pick(txs, data[i+1])},
+				Outputs: []Output{{Value: units.Amount(data[i+2])}},
+			})
+		}
+		if CheckBlockSpends(view, txs, nil) != nil {
+			return
+		}
+
+		// an accepted block spends each output once, after it is created,
+		// and creates no value
+		created := make(map[string]units.Amount)
+		for point, out := range view {
+			created[point] = out.Value
+		}
+		spent := make(map[string]bool)
+		for _, tx := range txs[1:] {
+			var value units.Amount
+			for _, in := range tx.Inputs {
+				inValue, ok := created[in.String()]
+				if spent[in.String()] || !ok {
+					t.Fatalf("accepted block spends %s, which is spent or missing", in)
+				}
+				spent[in.String()] = true
+				value += inValue
+			}
+			if tx.Outputs[0].Value > value {
+				t.Fatalf("accepted transaction %x creates %d from outputs of %d", tx.ID, tx.Outputs[0].Value, value)
+			}
+			created[OutPoint{TxID: tx.ID}.String()] = tx.Outputs[0].Value
+		}
+	})
+}
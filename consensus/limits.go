@@ -0,0 +1,44 @@
+package consensus
+
+import "fmt"
+
+// The size limits of blocks and transactions. Sizes are those of
+// serialized transactions.
+const (
+	// MaxBlockSize is the most bytes the serialized transactions of a
+	// block may take together.
+	MaxBlockSize = 1000000
+
+	// MaxTxSize is the most bytes a serialized transaction may take.
+	MaxTxSize = 100000
+
+	// MaxTxInputs and MaxTxOutputs are the most inputs and outputs a
+	// transaction may have.
+	MaxTxInputs  = 500
+	MaxTxOutputs = 500
+)
+
+// CheckTxSize verifies that the transaction with id, which has inputs
+// inputs and outputs outputs and is size bytes serialized, is within the
+// size limits.
+func CheckTxSize(id []byte, inputs, outputs, size int) error {
+	if inputs > MaxTxInputs {
+		return fmt.Errorf("transaction %x has %d inputs, more than %d", id, inputs, MaxTxInputs)
+	}
+	if outputs > MaxTxOutputs {
+		return fmt.Errorf("transaction %x has %d outputs, more than %d", id, outputs, MaxTxOutputs)
+	}
+	if size > MaxTxSize {
+		return fmt.Errorf("transaction %x is %d bytes, more than %d", id, size, MaxTxSize)
+	}
+	return nil
+}
+
+// CheckBlockSize verifies that the transactions of the block with hash,
+// which take size bytes serialized together, are within the size limit.
+func CheckBlockSize(hash []byte, size int) error {
+	if size > MaxBlockSize {
+		return fmt.Errorf("block %x holds %d bytes of transactions, more than %d", hash, size, MaxBlockSize)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package consensus
+
+import "math/big"
+
+// Target returns the number the hash of a block of difficulty must be
+// below, 2 to the power of 256 minus difficulty.
+func Target(difficulty int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(256-difficulty))
+}
+
+// CheckProofOfWork returns whether hash, read as a big endian number, is
+// below the target of difficulty.
+func CheckProofOfWork(hash []byte, difficulty int) bool {
+	return new(big.Int).SetBytes(hash).Cmp(Target(difficulty)) == -1
+}
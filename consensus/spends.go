@@ -0,0 +1,169 @@
+package consensus
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/edwintcloud/gochain/units"
+)
+
+// OutPoint identifies the output Index of the transaction TxID.
+type OutPoint struct {
+	TxID  []byte
+	Index int
+}
+
+// String returns the transaction id in hex and the index of the output.
+func (p OutPoint) String() string {
+	return fmt.Sprintf("%x:%d", p.TxID, p.Index)
+}
+
+// Output is a transaction output: its value, the public key hash locking
+// it and whether it is a data output, which can never be spent.
+type Output struct {
+	Value units.Amount
+	Lock  []byte
+	Data  bool
+}
+
+// Tx is a transaction as far as the spending rules are concerned: the
+// outputs its inputs spend and the outputs it creates. A coinbase has no
+// inputs to spend.
+type Tx struct {
+	ID       []byte
+	Coinbase bool
+	Inputs   []OutPoint
+	Outputs  []Output
+}
+
+// UTXOView gives the unspent outputs a block is verified against, such as
+// the UTXO set of the chain it extends.
+type UTXOView interface {
+
+	// UnspentOutput returns an output, with found false if it is missing
+	// or spent.
+	UnspentOutput(p OutPoint) (out Output, found bool, err error)
+}
+
+// SignatureCheck returns whether the signatures of the transaction at index
+// tx of a block are valid, given the outputs its inputs spend in order.
+type SignatureCheck func(tx int, spent []Output) bool
+
+// CheckBlockSpends verifies the transactions of a block against view. The
+// first transaction must be the only coinbase, every other transaction
+// must spend unspent outputs once and must not spend more than its inputs,
+// and the coinbase may claim at most the Subsidy plus fees. Value outputs
+// must be positive and no total of values may overflow. Transactions
+// may spend outputs of earlier transactions in the same block. Signatures
+// are verified with signatures unless it is nil.
+func CheckBlockSpends(view UTXOView, txs []Tx, signatures SignatureCheck) error {
+	created := make(map[string]Output)
+	spent := make(map[string]bool)
+	var fees units.Amount
+
+	if len(txs) == 0 || !txs[0].Coinbase {
+		return errors.New("first transaction of block is not a coinbase")
+	}
+
+	for i, tx := range txs {
+		if i > 0 {
+			if tx.Coinbase {
+				return errors.New("block has more than one coinbase transaction")
+			}
+
+			// find the outputs spent by the inputs in the view and the
+			// earlier transactions of the block
+			spends := make([]Output, 0, len(tx.Inputs))
+			var value units.Amount
+			for _, in := range tx.Inputs {
+				point := in.String()
+				if spent[point] {
+					return fmt.Errorf("transaction %x spends output %s twice in the block", tx.ID, point)
+				}
+				spent[point] = true
+
+				out, ok := created[point]
+				if !ok {
+					var err error
+					out, ok, err = view.UnspentOutput(in)
+					if err != nil {
+						return err
+					} else if !ok {
+						return fmt.Errorf("transaction %x spends missing or spent output %s", tx.ID, point)
+					}
+				}
+				spends = append(spends, out)
+				if value, ok = units.Add(value, out.Value); !ok {
+					return fmt.Errorf("values of the outputs spent by transaction %x overflow", tx.ID)
+				}
+			}
+
+			// verify signatures and that the outputs do not exceed the inputs
+			if signatures != nil && !signatures(i, spends) {
+				return fmt.Errorf("transaction %x has an invalid signature", tx.ID)
+			}
+			outputValue, err := CheckOutputs(tx)
+			if err != nil {
+				return err
+			}
+			if outputValue > value {
+				return fmt.Errorf("transaction %x outputs exceed its inputs", tx.ID)
+			}
+			var ok bool
+			if fees, ok = units.Add(fees, value-outputValue); !ok {
+				return errors.New("fees of the block overflow")
+			}
+		}
+
+		for outIdx, out := range tx.Outputs {
+			if !out.Data {
+				created[OutPoint{tx.ID, outIdx}.String()] = out
+			}
+		}
+	}
+
+	// ensure the coinbase does not claim more than the subsidy plus fees
+	reward, err := CheckOutputs(txs[0])
+	if err != nil {
+		return err
+	}
+	return CheckCoinbaseReward(reward, fees)
+}
+
+// CheckOutputs verifies that the value outputs of a transaction are
+// positive and returns their total, or an error if it overflows. Data
+// outputs hold no value.
+func CheckOutputs(tx Tx) (units.Amount, error) {
+	var total units.Amount
+	for outIdx, out := range tx.Outputs {
+		if out.Data {
+			continue
+		}
+		if out.Value <= 0 {
+			return 0, fmt.Errorf("output %d of transaction %x has value %s, which is not positive", outIdx, tx.ID, units.FormatAmount(out.Value))
+		}
+		var ok bool
+		if total, ok = units.Add(total, out.Value); !ok {
+			return 0, fmt.Errorf("values of the outputs of transaction %x overflow", tx.ID)
+		}
+	}
+	return total, nil
+}
+
+// CheckCoinbaseReward verifies that the coinbase of a block claims at most
+// the Subsidy plus the fees of its transactions, neither of which may be
+// negative.
+func CheckCoinbaseReward(reward, fees units.Amount) error {
+	if reward < 0 || fees < 0 {
+		return errors.New("coinbase reward and fees must not be negative")
+	}
+	limit, ok := units.Add(Subsidy, fees)
+	if !ok {
+		return errors.New("subsidy and fees of the block overflow")
+	}
+	if reward > limit {
+		return fmt.Errorf("coinbase claims %s, more than the subsidy and fees of %s",
+			units.FormatAmount(reward), units.FormatAmount(limit))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package events pushes chain events, such as new blocks and transactions,
+// to subscribers. Events are published on a Bus, and websocket clients
+// subscribe to them through Handler.
+package events
+
+import "sync"
+
+const (
+	// NewBlock is published when a block is connected to the best chain.
+	NewBlock = "newBlock"
+
+	// NewTx is published when a transaction is added to the mempool.
+	NewTx = "newTx"
+
+	// ReplacedTx is published when pending transactions are replaced by
+	// one paying a higher fee.
+	ReplacedTx = "replacedTx"
+
+	// Reorg is published when blocks are disconnected from the best chain.
+	Reorg = "reorg"
+)
+
+// Topics are the event types that can be subscribed to.
+var Topics = []string{NewBlock, NewTx, ReplacedTx, Reorg}
+
+// Event is a chain event with a payload that is encoded as JSON.
+type Event struct {
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload"`
+}
+
+// Bus delivers published events to the subscriptions for their type.
+type Bus struct {
+	mutex         sync.Mutex
+	subscriptions map[*Subscription]bool
+}
+
+// Subscription receives the events of the types it is subscribed to on C.
+// C is closed when the subscription is closed, including when the
+// subscriber falls too far behind.
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	bus    *Bus
+	topics map[string]bool
+}
+
+// NewBus creates a Bus without subscriptions.
+func NewBus() *Bus {
+	return &Bus{subscriptions: make(map[*Subscription]bool)}
+}
+
+// Subscribe creates a subscription, initially to no event types, that
+// buffers up to size events.
+func (b *Bus) Subscribe(size int) *Subscription {
+	c := make(chan Event, size)
+	s := &Subscription{C: c, c: c, bus: b, topics: make(map[string]bool)}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.subscriptions[s] = true
+	return s
+}
+
+// Publish sends an event to every subscription for its type. Subscriptions
+// whose buffer is full are closed rather than blocking the publisher.
+// Publishing on a nil Bus does nothing, so publishers don't need to check
+// whether anyone is listening.
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for s := range b.subscriptions {
+		if !s.topics[event.Type] {
+			continue
+		}
+		select {
+		case s.c <- event:
+		default:
+			b.remove(s)
+		}
+	}
+}
+
+// remove closes a subscription. The mutex of the bus must be held.
+func (b *Bus) remove(s *Subscription) {
+	if b.subscriptions[s] {
+		delete(b.subscriptions, s)
+		close(s.c)
+	}
+}
+
+// Add subscribes to an event type.
+func (s *Subscription) Add(topic string) {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+
+	s.topics[topic] = true
+}
+
+// Remove unsubscribes from an event type.
+func (s *Subscription) Remove(topic string) {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+
+	delete(s.topics, topic)
+}
+
+// Close stops the subscription and closes C.
+func (s *Subscription) Close() {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+
+	s.bus.remove(s)
+}
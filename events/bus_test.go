@@ -0,0 +1,53 @@
+package events
+
+import "testing"
+
+func TestBusDeliversSubscribedTopics(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(10)
+	defer sub.Close()
+	sub.Add(NewBlock)
+
+	bus.Publish(Event{Type: NewTx, Payload: 1})
+	bus.Publish(Event{Type: NewBlock, Payload: 2})
+	sub.Remove(NewBlock)
+	bus.Publish(Event{Type: NewBlock, Payload: 3})
+
+	select {
+	case event := <-sub.C:
+		if event.Type != NewBlock || event.Payload != 2 {
+			t.Fatalf("got event %+v, want newBlock 2", event)
+		}
+	default:
+		t.Fatal("expected an event")
+	}
+	select {
+	case event := <-sub.C:
+		t.Fatalf("got unexpected event %+v", event)
+	default:
+	}
+}
+
+func TestBusClosesSlowSubscriptions(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(1)
+	sub.Add(NewTx)
+
+	bus.Publish(Event{Type: NewTx, Payload: 1})
+	bus.Publish(Event{Type: NewTx, Payload: 2})
+
+	if event, ok := <-sub.C; !ok || event.Payload != 1 {
+		t.Fatalf("got event %+v, %v, want the buffered event", event, ok)
+	}
+	if _, ok := <-sub.C; ok {
+		t.Fatal("expected subscription to be closed")
+	}
+
+	// closing again must not panic
+	sub.Close()
+}
+
+func TestNilBusPublish(t *testing.T) {
+	var bus *Bus
+	bus.Publish(Event{Type: NewBlock})
+}
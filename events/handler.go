@@ -0,0 +1,122 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/edwintcloud/gochain/logging"
+)
+
+// subscriptionBuffer is the number of events buffered for a client before
+// it is disconnected for falling behind.
+const subscriptionBuffer = 256
+
+// logger receives the errors of encoding events for clients.
+var logger = logging.Default
+
+// SetLogger sets the logger receiving the errors of encoding events for
+// clients, which are written to stderr by default. It should be called
+// before handlers are served.
+func SetLogger(l logging.Logger) {
+	logger = l
+}
+
+// request is a message from a client changing its subscriptions.
+type request struct {
+	Subscribe   []string `json:"subscribe"`
+	Unsubscribe []string `json:"unsubscribe"`
+}
+
+// errorMessage is sent to a client that sent an invalid request.
+type errorMessage struct {
+	Error string `json:"error"`
+}
+
+// Handler serves websocket clients, sending them the events of bus they
+// subscribe to as JSON messages. Clients subscribe by sending a message
+// such as {"subscribe": ["newBlock", "reorg"]}, and unsubscribe with
+// {"unsubscribe": ["newBlock"]}. Browsers may only connect from pages
+// served by the host of the handler or from allowedOrigins, such as
+// https://example.com.
+func Handler(bus *Bus, allowedOrigins ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrade(w, r, allowedOrigins)
+		if err != nil {
+			return
+		}
+		defer c.close()
+
+		sub := bus.Subscribe(subscriptionBuffer)
+		defer sub.Close()
+
+		// send events until the subscription is closed or a write fails
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for event := range sub.C {
+				data, err := json.Marshal(event)
+				if err != nil {
+					logger.Error("Unable to encode event", "event", event.Type, "err", err)
+					continue
+				}
+				if err := c.writeMessage(data); err != nil {
+					return
+				}
+			}
+
+			// the subscription was closed because the client fell behind
+			c.close()
+		}()
+
+		// apply subscription requests until the client disconnects
+		for {
+			message, err := c.readMessage()
+			if err != nil {
+				break
+			}
+			if err := handleRequest(sub, message); err != nil {
+				data, _ := json.Marshal(errorMessage{err.Error()})
+				if c.writeMessage(data) != nil {
+					break
+				}
+			}
+		}
+
+		sub.Close()
+		<-done
+	})
+}
+
+// handleRequest applies a subscription request from a client.
+func handleRequest(sub *Subscription, message []byte) error {
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		return fmt.Errorf("invalid request: %s", err.Error())
+	}
+
+	// validate every topic before changing anything
+	for _, topic := range append(append([]string{}, req.Subscribe...), req.Unsubscribe...) {
+		if !validTopic(topic) {
+			return fmt.Errorf("unknown event type %q", topic)
+		}
+	}
+
+	for _, topic := range req.Subscribe {
+		sub.Add(topic)
+	}
+	for _, topic := range req.Unsubscribe {
+		sub.Remove(topic)
+	}
+	return nil
+}
+
+// validTopic returns whether topic is an event type.
+func validTopic(topic string) bool {
+	for _, t := range Topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader is the header of webhook posts holding the HMAC-SHA256
+	// of the body with the webhook secret, as "sha256=" and the hex digest,
+	// so receivers can check posts came from the node.
+	SignatureHeader = "X-Gochain-Signature"
+
+	// webhookAttempts is the number of times an event is posted to a
+	// webhook before it is dropped.
+	webhookAttempts = 6
+
+	// DefaultWebhookBackoff is the wait before the first retry of a post,
+	// which doubles with every retry.
+	DefaultWebhookBackoff = time.Second
+)
+
+// Webhook asks for the events of Topics, or of every topic if it is empty,
+// to be posted as JSON to URL.
+type Webhook struct {
+	URL    string   `json:"url"`
+	Topics []string `json:"topics"`
+}
+
+// Validate returns an error if the webhook has no URL or an unknown topic.
+func (w Webhook) Validate() error {
+	if w.URL == "" {
+		return errors.New("no URL given")
+	}
+	for _, topic := range w.Topics {
+		if !validTopic(topic) {
+			return fmt.Errorf("unknown event type %q", topic)
+		}
+	}
+	return nil
+}
+
+// Sign returns the value of the SignatureHeader of a post of body signed
+// with secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post posts body as JSON to url, signed with secret unless it is empty,
+// returning an error unless the URL took it with a 2xx status.
+func Post(ctx context.Context, client *http.Client, url string, secret, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("got status %s", resp.Status)
+	}
+	return nil
+}
+
+// Webhooks posts the events of a bus to webhooks. Each webhook has its own
+// subscription and posts its events in order, retrying a failed post with
+// exponential backoff before dropping the event, so a slow URL only delays
+// its own events.
+type Webhooks struct {
+	// Backoff is the wait before the first retry of a post. It should be set
+	// before webhooks are added.
+	Backoff time.Duration
+
+	mutex   sync.Mutex
+	bus     *Bus
+	client  *http.Client
+	secret  []byte
+	running map[string]*runningWebhook
+	wg      sync.WaitGroup
+}
+
+// runningWebhook is a webhook being posted events, until cancel is called.
+type runningWebhook struct {
+	webhook Webhook
+	cancel  context.CancelFunc
+}
+
+// NewWebhooks returns Webhooks posting the events of bus with client,
+// signed with secret unless it is empty.
+func NewWebhooks(bus *Bus, client *http.Client, secret []byte) *Webhooks {
+	return &Webhooks{
+		Backoff: DefaultWebhookBackoff,
+		bus:     bus,
+		client:  client,
+		secret:  secret,
+		running: make(map[string]*runningWebhook),
+	}
+}
+
+// Add starts posting events to a webhook, replacing the topics of the
+// webhook of its URL if there is one.
+func (h *Webhooks) Add(w Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	h.Remove(w.URL)
+
+	// subscribe before returning, so the events published after Add are
+	// posted
+	sub := h.subscribe(w)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.running[w.URL] = &runningWebhook{webhook: w, cancel: cancel}
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.deliver(ctx, w, sub)
+	}()
+	return nil
+}
+
+// Remove stops posting events to the webhook of url, returning whether
+// there was one.
+func (h *Webhooks) Remove(url string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	running, ok := h.running[url]
+	if ok {
+		running.cancel()
+		delete(h.running, url)
+	}
+	return ok
+}
+
+// List returns the webhooks events are posted to.
+func (h *Webhooks) List() []Webhook {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	webhooks := []Webhook{}
+	for _, running := range h.running {
+		webhooks = append(webhooks, running.webhook)
+	}
+	return webhooks
+}
+
+// Close stops posting events to every webhook, waiting for the posts in
+// progress to stop.
+func (h *Webhooks) Close() {
+	h.mutex.Lock()
+	for url, running := range h.running {
+		running.cancel()
+		delete(h.running, url)
+	}
+	h.mutex.Unlock()
+	h.wg.Wait()
+}
+
+// subscribe subscribes to the topics of w, or to every topic if it has
+// none.
+func (h *Webhooks) subscribe(w Webhook) *Subscription {
+	topics := w.Topics
+	if len(topics) == 0 {
+		topics = Topics
+	}
+	sub := h.bus.Subscribe(subscriptionBuffer)
+	for _, topic := range topics {
+		sub.Add(topic)
+	}
+	return sub
+}
+
+// deliver posts the events of sub to the URL of w until ctx is done. If
+// the webhook falls so far behind that its subscription is closed, the
+// events missed are dropped and it subscribes again.
+func (h *Webhooks) deliver(ctx context.Context, w Webhook, sub *Subscription) {
+	for h.deliverSubscription(ctx, w.URL, sub) {
+		logger.Warn("Webhook fell behind, dropping the events it missed", "url", w.URL)
+		sub = h.subscribe(w)
+	}
+}
+
+// deliverSubscription posts the events of sub to url, returning false once
+// ctx is done and true if the subscription was closed.
+func (h *Webhooks) deliverSubscription(ctx context.Context, url string, sub *Subscription) bool {
+	defer sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case event, ok := <-sub.C:
+			if !ok {
+				return true
+			}
+			h.post(ctx, url, event)
+		}
+	}
+}
+
+// post posts an event to url, retrying with exponential backoff until it
+// is taken, it was tried webhookAttempts times or ctx is done.
+func (h *Webhooks) post(ctx context.Context, url string, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Error("Unable to encode event", "event", event.Type, "err", err)
+		return
+	}
+
+	backoff := h.Backoff
+	for attempt := 1; ; attempt++ {
+		err := Post(ctx, h.client, url, h.secret, body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			logger.Warn("Unable to post event to webhook, dropping it", "url", url, "event", event.Type, "err", err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
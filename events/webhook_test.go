@@ -0,0 +1,71 @@
+package events
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhooks(t *testing.T) {
+	secret := []byte("secret")
+	received := make(chan Event, 10)
+	failures := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign(secret, body) {
+			t.Error("got a post without the signature of its body")
+		}
+
+		// fail the first posts so they are retried
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Error(err)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	bus := NewBus()
+	h := NewWebhooks(bus, server.Client(), secret)
+	h.Backoff = time.Millisecond
+	defer h.Close()
+	if err := h.Add(Webhook{URL: server.URL, Topics: []string{NewBlock}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the events of the topics of the webhook are posted, in order
+	bus.Publish(Event{Type: NewTx, Payload: "tx"})
+	bus.Publish(Event{Type: NewBlock, Payload: "first"})
+	bus.Publish(Event{Type: NewBlock, Payload: "second"})
+	for _, want := range []string{"first", "second"} {
+		select {
+		case event := <-received:
+			if event.Type != NewBlock || event.Payload != want {
+				t.Fatalf("got event %+v, want newBlock %s", event, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for newBlock %s", want)
+		}
+	}
+
+	if !h.Remove(server.URL) || len(h.List()) != 0 {
+		t.Fatal("the webhook was not removed")
+	}
+}
+
+func TestWebhookValidate(t *testing.T) {
+	if err := (Webhook{URL: "http://localhost", Topics: []string{"unknown"}}).Validate(); err == nil {
+		t.Fatal("allowed an unknown topic")
+	}
+	if err := (Webhook{Topics: []string{NewBlock}}).Validate(); err == nil {
+		t.Fatal("allowed a webhook without a URL")
+	}
+}
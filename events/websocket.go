@@ -0,0 +1,214 @@
+package events
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// websocketGUID is appended to the key of a handshake to compute the
+// accept header, as defined by RFC 6455.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize is the largest message accepted from a client.
+const maxMessageSize = 64 << 10
+
+// websocket opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// conn is a server side websocket connection.
+type conn struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+
+	// writes come from the reading and sending goroutines
+	writeMutex sync.Mutex
+}
+
+// upgrade completes the websocket handshake of an HTTP request and takes
+// over its connection. Requests from browsers on other sites than the
+// node and the allowedOrigins are refused, so pages can't use the
+// credentials of the browser to subscribe.
+func upgrade(w http.ResponseWriter, r *http.Request, allowedOrigins []string) (*conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, errors.New("request is not a websocket upgrade")
+	}
+	if !allowedOrigin(r, allowedOrigins) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return nil, fmt.Errorf("origin %s is not allowed", r.Header.Get("Origin"))
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("connection can't be hijacked")
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	// accept the upgrade with the hash of the key
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(hash[:]))
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return &conn{netConn: netConn, reader: rw.Reader}, nil
+}
+
+// allowedOrigin returns whether the Origin header of a request is the host
+// it was sent to or one of allowed, such as https://example.com. Requests
+// without an Origin don't come from browsers and are allowed.
+func allowedOrigin(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(origin, strings.TrimSuffix(a, "/")) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+// readMessage reads the next text or binary message, answering pings and
+// returning io.EOF when the client closes the connection.
+func (c *conn) readMessage() ([]byte, error) {
+	var message []byte
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opClose:
+			c.writeFrame(opClose, nil)
+			return nil, io.EOF
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opText, opBinary, opContinuation:
+			message = append(message, payload...)
+			if len(message) > maxMessageSize {
+				return nil, errors.New("websocket message too large")
+			}
+		default:
+			return nil, fmt.Errorf("unknown websocket opcode %d", opcode)
+		}
+
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// readFrame reads a single frame, unmasking its payload.
+func (c *conn) readFrame() (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+
+	// read the extended payload length if needed
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, errors.New("websocket frame too large")
+	}
+
+	// clients must mask every frame
+	if !masked {
+		return false, 0, nil, errors.New("websocket frame from client is not masked")
+	}
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(c.reader, mask); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unmasked frame.
+func (c *conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	frame := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+
+	_, err := c.netConn.Write(append(frame, payload...))
+	return err
+}
+
+// writeMessage writes a text message.
+func (c *conn) writeMessage(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// close closes the connection.
+func (c *conn) close() error {
+	return c.netConn.Close()
+}
@@ -0,0 +1,217 @@
+package events
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClient is the client side of a websocket connection to Handler.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dial connects to the websocket handler of server with the handshake key
+// from RFC 6455.
+func dial(t *testing.T, server *httptest.Server) *testClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\n"+
+		"Connection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("got status %d, want 101", resp.StatusCode)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("got accept %q", accept)
+	}
+	return &testClient{t: t, conn: conn, reader: reader}
+}
+
+// writeFrame writes a frame masked like a client must.
+func (c *testClient) writeFrame(opcode byte, payload []byte, masked bool) {
+	frame := []byte{0x80 | opcode, 0}
+	if len(payload) < 126 {
+		frame[1] = byte(len(payload))
+	} else {
+		frame[1] = 126
+		frame = append(frame, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	}
+	data := append([]byte{}, payload...)
+	if masked {
+		frame[1] |= 0x80
+		mask := []byte{1, 2, 3, 4}
+		frame = append(frame, mask...)
+		for i := range data {
+			data[i] ^= mask[i%4]
+		}
+	}
+	if _, err := c.conn.Write(append(frame, data...)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// readFrame reads an unmasked frame from the server.
+func (c *testClient) readFrame() (byte, []byte) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		c.t.Fatal(err)
+	}
+	if header[1]&0x80 != 0 {
+		c.t.Fatal("server frame is masked")
+	}
+	length := int(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		io.ReadFull(c.reader, extended)
+		length = int(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		io.ReadFull(c.reader, extended)
+		length = int(binary.BigEndian.Uint64(extended))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	server := httptest.NewServer(Handler(NewBus()))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestUpgradeChecksOrigin(t *testing.T) {
+	server := httptest.NewServer(Handler(NewBus(), "https://wallet.example.com"))
+	defer server.Close()
+
+	// a page of another site is refused before the handshake
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "https://evil.example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("got status %d, want 403", resp.StatusCode)
+	}
+
+	for _, c := range []struct {
+		origin, host string
+		want         bool
+	}{
+		{"", "node:3000", true},
+		{"http://node:3000", "node:3000", true},
+		{"http://NODE:3000", "node:3000", true},
+		{"https://wallet.example.com", "node:3000", true},
+		{"http://node:3001", "node:3000", false},
+		{"https://evil.example.com", "node:3000", false},
+		{"null", "node:3000", false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		r.Host = c.host
+		if c.origin != "" {
+			r.Header.Set("Origin", c.origin)
+		}
+		if got := allowedOrigin(r, []string{"https://wallet.example.com/"}); got != c.want {
+			t.Errorf("got %v for origin %q on host %s, want %v", got, c.origin, c.host, c.want)
+		}
+	}
+}
+
+func TestHandlerSendsSubscribedEvents(t *testing.T) {
+	bus := NewBus()
+	server := httptest.NewServer(Handler(bus))
+	defer server.Close()
+	c := dial(t, server)
+	defer c.conn.Close()
+
+	// a ping is answered with a pong carrying the same payload
+	c.writeFrame(opPing, []byte("hi"), true)
+	if opcode, payload := c.readFrame(); opcode != opPong || string(payload) != "hi" {
+		t.Fatalf("got opcode %d payload %q, want pong", opcode, payload)
+	}
+
+	// an unknown topic is reported without closing the connection
+	c.writeFrame(opText, []byte(`{"subscribe": ["nope"]}`), true)
+	if _, payload := c.readFrame(); !strings.Contains(string(payload), "unknown event type") {
+		t.Fatalf("got %q, want an error", payload)
+	}
+
+	// a subscription split over a fragmented message is applied, which is
+	// confirmed by the pong that follows it
+	c.conn.Write([]byte{opText, 0x80 | 14, 0, 0, 0, 0})
+	c.conn.Write([]byte(`{"subscribe": `))
+	c.writeFrame(opContinuation, []byte(`["newBlock"]}`), true)
+	c.writeFrame(opPing, nil, true)
+	c.readFrame()
+
+	// a large event uses the 16 bit length
+	bus.Publish(Event{Type: NewTx, Payload: "ignored"})
+	bus.Publish(Event{Type: NewBlock, Payload: strings.Repeat("a", 300)})
+	opcode, payload := c.readFrame()
+	if opcode != opText {
+		t.Fatalf("got opcode %d, want text", opcode)
+	}
+	var event struct {
+		Type    string
+		Payload string
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != NewBlock || len(event.Payload) != 300 {
+		t.Fatalf("got event %s with %d byte payload", event.Type, len(event.Payload))
+	}
+
+	// a close is echoed
+	c.writeFrame(opClose, nil, true)
+	if opcode, _ := c.readFrame(); opcode != opClose {
+		t.Fatalf("got opcode %d, want close", opcode)
+	}
+}
+
+func TestHandlerClosesOnUnmaskedFrames(t *testing.T) {
+	server := httptest.NewServer(Handler(NewBus()))
+	defer server.Close()
+	c := dial(t, server)
+	defer c.conn.Close()
+
+	c.writeFrame(opText, []byte(`{"subscribe": ["newTx"]}`), false)
+	if _, err := c.reader.ReadByte(); err == nil {
+		t.Fatal("expected connection to be closed")
+	}
+}
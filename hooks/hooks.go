@@ -0,0 +1,154 @@
+// Package hooks lets plugins extend the node without changes to its code.
+// Plugins are called with an event name and a JSON payload when blocks are
+// connected or disconnected, transactions are accepted, and wallets are
+// created.
+//
+// Plugins are either registered in process with Register, or are
+// executables listed in the PLUGINS env var, separated by commas, which are
+// registered by RegisterFromEnv. An
+// executable plugin is run with the event name as its only argument and the
+// payload on stdin. A plugin rejects a transaction by returning an error, or
+// by exiting with a non-zero status.
+//
+// Programs embedding the blockchain package can instead register functions
+// called with the blocks and transactions themselves, with the OnBlock and
+// OnTx methods of a BlockChain.
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/edwintcloud/gochain/logging"
+)
+
+const (
+	// BlockConnected is sent after a block is added to the best chain.
+	BlockConnected = "blockconnected"
+
+	// BlockDisconnected is sent after a block is removed from the best
+	// chain by a reorganization.
+	BlockDisconnected = "blockdisconnected"
+
+	// TxAccept is sent before a transaction is added to the mempool.
+	// An error from a plugin rejects the transaction.
+	TxAccept = "txaccept"
+
+	// WalletCreated is sent after a wallet is created, or after a
+	// watch-only wallet is imported, with watchOnly set in the payload.
+	WalletCreated = "walletcreated"
+)
+
+// Plugin handles events sent by the node.
+type Plugin interface {
+	Handle(event string, payload []byte) error
+}
+
+// Command is a plugin that runs an executable for each event.
+type Command struct {
+	Path string
+}
+
+var (
+	plugins []Plugin
+	mutex   sync.Mutex
+
+	// logger receives the errors of plugins for events they can't reject
+	logger = logging.Default
+)
+
+// SetLogger sets the logger receiving the errors of plugins for events they
+// can't reject, which are written to stderr by default. It should be called
+// before events are sent.
+func SetLogger(l logging.Logger) {
+	logger = l
+}
+
+// Handle runs the executable with the event as its argument and the payload
+// on stdin.
+func (c *Command) Handle(event string, payload []byte) error {
+	cmd := exec.Command(c.Path, event)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("plugin %s failed - %s", c.Path, err.Error())
+	}
+	return nil
+}
+
+// Register adds a plugin that is called for every event.
+func Register(p Plugin) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	plugins = append(plugins, p)
+}
+
+// RegisterFromEnv registers the executables listed in the PLUGINS env var.
+func RegisterFromEnv() {
+	for _, path := range strings.Split(os.Getenv("PLUGINS"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			Register(&Command{Path: path})
+		}
+	}
+}
+
+// Run sends an event to every plugin, stopping at and returning the first
+// error. The payload is encoded as JSON.
+func Run(event string, payload interface{}) error {
+	registered, data, err := prepare(payload)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range registered {
+		if err := p.Handle(event, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Notify sends an event to every plugin like Run, logging errors instead of
+// returning them. It is used for events that plugins can not reject.
+func Notify(event string, payload interface{}) {
+	registered, data, err := prepare(payload)
+	if err != nil {
+		logger.Error("Unable to encode event payload", "event", event, "err", err)
+		return
+	}
+
+	for _, p := range registered {
+		if err := p.Handle(event, data); err != nil {
+			logger.Warn("Plugin error", "event", event, "err", err)
+		}
+	}
+}
+
+// prepare returns the registered plugins and the payload encoded as JSON.
+func prepare(payload interface{}) ([]Plugin, []byte, error) {
+	mutex.Lock()
+	registered := append([]Plugin{}, plugins...)
+	mutex.Unlock()
+
+	// skip encoding without plugins
+	if len(registered) == 0 {
+		return nil, nil, nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, errors.New("unable to encode event payload - " + err.Error())
+	}
+
+	return registered, data, nil
+}
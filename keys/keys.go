@@ -0,0 +1,79 @@
+// Package keys holds what transactions are addressed, signed and verified
+// with: public key hashes, the addresses of the network in use, signature
+// schemes and the Key interface. Package blockchain validates and funds
+// transactions with it and package wallet stores keys implementing it, so
+// validation does not depend on how keys are stored.
+package keys
+
+import (
+	"crypto/sha256"
+	"errors"
+	"log"
+
+	"github.com/edwintcloud/gochain/addresses"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// AddressVersion is the version byte of the addresses of the network in
+// use.
+var AddressVersion = addresses.MainNet
+
+// ErrWatchOnly is returned when signing with a key that has no private key
+// or signer.
+var ErrWatchOnly = errors.New("wallet is watch-only and can not sign")
+
+// Signer signs transaction hashes with a private key it holds, so the key
+// can live outside the process, such as in a hardware wallet, a remote key
+// management service or a group of threshold signers.
+type Signer interface {
+	// Sign signs hash, returning the signature and the public key it
+	// verifies against, the concatenated x and y coordinates of a point.
+	Sign(hash []byte) (signature, pubKey []byte, err error)
+}
+
+// Key is a key transactions are funded from and signed with, such as a
+// wallet.
+type Key interface {
+	Signer
+
+	// PubKey returns the public key, or nil for a watch-only key only
+	// knowing its address.
+	PubKey() []byte
+
+	// Address returns the address of the key on the network in use.
+	Address() addresses.Address
+
+	// CanSign returns whether Sign can sign.
+	CanSign() bool
+}
+
+// PublicKeyHash generates a hash for a public key using sha256 and
+// ripemd160.
+func PublicKeyHash(pubKey []byte) []byte {
+
+	// hash using sha256
+	pubHash := sha256.Sum256(pubKey)
+
+	// write pubHash into a ripemd160 hash
+	rmdHash := ripemd160.New()
+	_, err := rmdHash.Write(pubHash[:])
+	if err != nil {
+		log.Panicln("Unable to write pubHash into ripemd160 hash: ", err.Error())
+	}
+
+	// generate and return final hash
+	return rmdHash.Sum(nil)
+}
+
+// AddressFromPublicKeyHash returns the address for a public key hash on the
+// network in use.
+func AddressFromPublicKeyHash(pubHash []byte) string {
+	return addresses.Encode(AddressVersion, pubHash)
+}
+
+// ValidateAddress validates an address, which must be of the network in
+// use.
+func ValidateAddress(address string) bool {
+	a, err := addresses.Decode(address)
+	return err == nil && a.Version == AddressVersion
+}
@@ -0,0 +1,207 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	btcecdsa "github.com/btcsuite/btcd/btcec/v2/ecdsa"
+)
+
+// Scheme is a signature scheme that keys are made with and transactions
+// are signed with. Each network signs with one scheme, so chains of
+// other schemes, such as secp256k1 for interop with Bitcoin tools, are
+// added by registering a Scheme with RegisterScheme.
+type Scheme interface {
+	// Name identifies the scheme in genesis and wallets files.
+	Name() string
+
+	// Curve is the curve of the keys of the scheme.
+	Curve() elliptic.Curve
+
+	// Sign signs hash with key, which must be on the curve of the scheme.
+	Sign(key *ecdsa.PrivateKey, hash []byte) ([]byte, error)
+
+	// Verify reports whether signature is a signature of hash by the public
+	// key pubKey, the concatenated x and y coordinates of a point.
+	Verify(pubKey, hash, signature []byte) bool
+
+	// CheckEncoding returns an error if signature is not in the encoding
+	// Sign makes, which new transactions must use.
+	CheckEncoding(signature []byte) error
+}
+
+var (
+	// P256 signs with ECDSA on the NIST P-256 curve, the scheme of the
+	// existing networks.
+	P256 = NewECDSAScheme("p256", elliptic.P256())
+
+	// Secp256k1 signs with ECDSA on the secp256k1 curve used by Bitcoin,
+	// with the deterministic nonces of RFC 6979 made by btcec.
+	Secp256k1 Scheme = &ecdsaScheme{"secp256k1", btcec.S256(), new(big.Int).Rsh(btcec.S256().N, 1), signSecp256k1}
+)
+
+// schemes are the registered schemes by name.
+var schemes = map[string]Scheme{P256.Name(): P256, Secp256k1.Name(): Secp256k1}
+
+// RegisterScheme makes a scheme available by its name.
+func RegisterScheme(s Scheme) {
+	schemes[s.Name()] = s
+}
+
+// SchemeByName returns the registered scheme called name, or P256 if name
+// is empty.
+func SchemeByName(name string) (Scheme, error) {
+	if name == "" {
+		return P256, nil
+	}
+	s, ok := schemes[name]
+	if !ok {
+		return nil, fmt.Errorf("signature scheme %q is not known", name)
+	}
+	return s, nil
+}
+
+// SchemeOf returns the registered scheme for keys on curve, or nil if
+// there is none.
+func SchemeOf(curve elliptic.Curve) Scheme {
+	for _, s := range schemes {
+		if s.Curve() == curve {
+			return s
+		}
+	}
+	return nil
+}
+
+// ecdsaScheme signs with ECDSA on a curve. Signatures are DER encoded with
+// a low s, and signatures made before they were DER encoded, r and s
+// concatenated, still verify. Keys sign with crypto/ecdsa unless
+// sign is set.
+type ecdsaScheme struct {
+	name      string
+	curve     elliptic.Curve
+	halfOrder *big.Int
+	sign      func(key *ecdsa.PrivateKey, hash []byte) ([]byte, error)
+}
+
+// ecdsaSignature is the ASN.1 structure of a DER encoded signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// NewECDSAScheme returns a scheme called name signing with ECDSA on curve.
+func NewECDSAScheme(name string, curve elliptic.Curve) Scheme {
+	return &ecdsaScheme{name, curve, new(big.Int).Rsh(curve.Params().N, 1), nil}
+}
+
+// Name returns the name of the scheme.
+func (e *ecdsaScheme) Name() string {
+	return e.name
+}
+
+// Curve returns the curve of the scheme.
+func (e *ecdsaScheme) Curve() elliptic.Curve {
+	return e.curve
+}
+
+// Sign returns the DER encoding of the signature of hash by key, with s
+// replaced by the order minus s if it is in the upper half, which verifies
+// the same. Allowing only the lower s stops others from making a second
+// valid signature of a transaction.
+func (e *ecdsaScheme) Sign(key *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	if key.Curve != e.curve {
+		return nil, fmt.Errorf("key is not a %s key", e.name)
+	}
+	if e.sign != nil {
+		return e.sign(key, hash)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash)
+	if err != nil {
+		return nil, err
+	}
+	if s.Cmp(e.halfOrder) > 0 {
+		s = new(big.Int).Sub(e.curve.Params().N, s)
+	}
+	return asn1.Marshal(ecdsaSignature{r, s})
+}
+
+// Verify reports whether signature is a signature of hash by pubKey,
+// returning false for malformed keys and signatures.
+func (e *ecdsaScheme) Verify(pubKey, hash, signature []byte) bool {
+
+	// refuse keys and signatures that can't be points or values of the
+	// curve before parsing them
+	size := (e.curve.Params().BitSize + 7) / 8
+	if len(pubKey) == 0 || len(pubKey) > 2*size || len(signature) == 0 || len(signature) > maxSignatureLength(size) {
+		return false
+	}
+
+	// unpack x and y from public key
+	keyMedian := len(pubKey) / 2
+	x := new(big.Int).SetBytes(pubKey[:keyMedian])
+	y := new(big.Int).SetBytes(pubKey[keyMedian:])
+	if !e.curve.IsOnCurve(x, y) {
+		return false
+	}
+
+	// unpack r and s from signature, splitting signatures made before
+	// they were DER encoded in half
+	r, s, err := e.parse(signature)
+	if err != nil {
+		median := len(signature) / 2
+		r = new(big.Int).SetBytes(signature[:median])
+		s = new(big.Int).SetBytes(signature[median:])
+	}
+
+	return ecdsa.Verify(&ecdsa.PublicKey{Curve: e.curve, X: x, Y: y}, hash, r, s)
+}
+
+// signSecp256k1 signs hash with a secp256k1 key using btcec, whose
+// signatures are DER encoded with a low s.
+func signSecp256k1(key *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	var d [32]byte
+	key.D.FillBytes(d[:])
+	privKey, _ := btcec.PrivKeyFromBytes(d[:])
+	defer privKey.Zero()
+	return btcecdsa.Sign(privKey, hash).Serialize(), nil
+}
+
+// maxSignatureLength returns the length of the longest DER signature with
+// values of size bytes: a sequence of two integers, each with a leading
+// zero byte keeping it positive.
+func maxSignatureLength(size int) int {
+	return 2 + 2*(2+size+1)
+}
+
+// CheckEncoding returns an error if signature is not in canonical DER
+// encoding with a low s.
+func (e *ecdsaScheme) CheckEncoding(signature []byte) error {
+	_, _, err := e.parse(signature)
+	return err
+}
+
+// parse parses a canonical DER signature: one with nothing after it,
+// positive r and s in their shortest encoding, and a low s.
+func (e *ecdsaScheme) parse(signature []byte) (r, s *big.Int, err error) {
+	var sig ecdsaSignature
+	rest, err := asn1.Unmarshal(signature, &sig)
+	if err != nil || len(rest) != 0 {
+		return nil, nil, errors.New("signature is not DER encoded")
+	}
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 {
+		return nil, nil, errors.New("signature values are not positive")
+	}
+	if canonical, err := asn1.Marshal(sig); err != nil || !bytes.Equal(canonical, signature) {
+		return nil, nil, errors.New("signature is not in canonical DER encoding")
+	}
+	if sig.S.Cmp(e.halfOrder) > 0 {
+		return nil, nil, errors.New("signature has a high s value")
+	}
+	return sig.R, sig.S, nil
+}
@@ -0,0 +1,124 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+func TestSchemeSignatures(t *testing.T) {
+	for _, scheme := range []Scheme{P256, Secp256k1} {
+		t.Run(scheme.Name(), func(t *testing.T) {
+			key, pubKey := newKey(t, scheme)
+			hash := sha256.Sum256([]byte("transaction"))
+			signature, err := scheme.Sign(key, hash[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := scheme.(*ecdsaScheme)
+			r, s, err := e.parse(signature)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// signatures before DER were r and s left padded to 32 bytes
+			legacy := make([]byte, 64)
+			copy(legacy[32-len(r.Bytes()):32], r.Bytes())
+			copy(legacy[64-len(s.Bytes()):], s.Bytes())
+
+			// the same signature with s in the upper half verifies with
+			// ecdsa, but is not canonical
+			highS, err := asn1.Marshal(ecdsaSignature{r, new(big.Int).Sub(scheme.Curve().Params().N, s)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cases := []struct {
+				name      string
+				signature []byte
+				verifies  bool
+				canonical bool
+			}{
+				{"der", signature, true, true},
+				{"legacy", legacy, true, false},
+				{"high s", highS, false, false},
+				{"empty", nil, false, false},
+			}
+			for _, c := range cases {
+				if got := scheme.Verify(pubKey, hash[:], c.signature); got != c.verifies {
+					t.Errorf("%s: got verifies %v, want %v", c.name, got, c.verifies)
+				}
+				if err := scheme.CheckEncoding(c.signature); (err == nil) != c.canonical {
+					t.Errorf("%s: got encoding error %v, want canonical %v", c.name, err, c.canonical)
+				}
+			}
+		})
+	}
+
+	// secp256k1 nonces are derived from the key and hash, so signing twice
+	// makes the same signature
+	key, _ := newKey(t, Secp256k1)
+	hash := sha256.Sum256([]byte("transaction"))
+	first, err := Secp256k1.Sign(key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, err := Secp256k1.Sign(key, hash[:]); err != nil || !bytes.Equal(first, second) {
+		t.Errorf("got signatures %x and %x, %v, want the same deterministic signature", first, second, err)
+	}
+
+	// keys of one scheme don't sign or verify with another
+	key, pubKey := newKey(t, P256)
+	if _, err := Secp256k1.Sign(key, hash[:]); err == nil {
+		t.Error("signed with a P-256 key on secp256k1")
+	}
+	signature, err := P256.Sign(key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Secp256k1.Verify(pubKey, hash[:], signature) {
+		t.Error("verified a P-256 signature on secp256k1")
+	}
+}
+
+func TestSchemeByName(t *testing.T) {
+	if s, err := SchemeByName(""); err != nil || s != P256 {
+		t.Errorf("got %v, %v for no name, want p256", s, err)
+	}
+	if _, err := SchemeByName("ed25519"); err == nil {
+		t.Error("got a scheme for an unknown name")
+	}
+}
+
+// newKey generates a key of scheme and its public key.
+func newKey(t testing.TB, scheme Scheme) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(scheme.Curve(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, append(key.X.Bytes(), key.Y.Bytes()...)
+}
+
+func FuzzVerify(f *testing.F) {
+	key, pubKey := newKey(f, P256)
+	hash := sha256.Sum256([]byte("transaction"))
+	signature, err := P256.Sign(key, hash[:])
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(pubKey, hash[:], signature)
+	f.Add(pubKey[1:], hash[:], signature[:len(signature)-1])
+	f.Add([]byte{}, []byte{}, []byte{})
+
+	// malformed keys and signatures don't verify, and never panic
+	f.Fuzz(func(t *testing.T, pubKey, hash, signature []byte) {
+		for _, scheme := range []Scheme{P256, Secp256k1} {
+			scheme.Verify(pubKey, hash, signature)
+			scheme.CheckEncoding(signature)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+// Package logging is the leveled logger used by the blockchain, hooks,
+// scripts, events and cli packages. Messages have a level and key value
+// pairs, and are written as text or JSON lines:
+//
+//	logger := logging.New(os.Stderr, logging.InfoLevel, logging.JSON)
+//	logger.Info("Block connected", "height", 12, "hash", block.Hash)
+//
+// Library consumers can pass their own implementation of Logger, such as an
+// adapter to the logger of their application, or Discard to silence the
+// packages.
+package logging
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a message.
+type Level int
+
+const (
+	// DebugLevel is for details that are only useful when debugging.
+	DebugLevel Level = iota
+
+	// InfoLevel is for the progress of normal operation.
+	InfoLevel
+
+	// WarnLevel is for problems the node recovers from.
+	WarnLevel
+
+	// ErrorLevel is for failures.
+	ErrorLevel
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	}
+	return "level(" + strconv.Itoa(int(l)) + ")"
+}
+
+// ParseLevel returns the level named by s, which is debug, info, warn or
+// error in any case.
+func ParseLevel(s string) (Level, error) {
+	for l := DebugLevel; l <= ErrorLevel; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// Format is how messages are written.
+type Format int
+
+const (
+	// Text writes messages as a time, level and message followed by
+	// key=value pairs.
+	Text Format = iota
+
+	// JSON writes every message as a JSON object with time, level and msg
+	// keys and the key value pairs of the message.
+	JSON
+)
+
+// ParseFormat returns the format named by s, which is text or json in any
+// case.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "text":
+		return Text, nil
+	case "json":
+		return JSON, nil
+	}
+	return 0, fmt.Errorf("unknown log format %q", s)
+}
+
+// Logger writes leveled messages. The arguments after the message are
+// alternating keys and values describing it. Implementations must be safe
+// for concurrent use.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// Default is the logger used by packages that are not given one. It writes
+// info and more severe messages to stderr as text, so they don't mix with
+// the output of commands.
+var Default Logger = New(os.Stderr, InfoLevel, Text)
+
+// Discard is a logger that ignores every message.
+var Discard Logger = discard{}
+
+// discard is the Logger of Discard.
+type discard struct{}
+
+func (discard) Debug(string, ...interface{}) {}
+func (discard) Info(string, ...interface{})  {}
+func (discard) Warn(string, ...interface{})  {}
+func (discard) Error(string, ...interface{}) {}
+
+// writer is a Logger writing lines to an io.Writer.
+type writer struct {
+	mutex  sync.Mutex
+	w      io.Writer
+	level  Level
+	format Format
+
+	// now returns the time of messages, and is replaced by tests
+	now func() time.Time
+}
+
+// New returns a logger writing messages of level or more severe to w in
+// format.
+func New(w io.Writer, level Level, format Format) Logger {
+	return &writer{w: w, level: level, format: format, now: time.Now}
+}
+
+func (l *writer) Debug(msg string, keyvals ...interface{}) { l.log(DebugLevel, msg, keyvals) }
+func (l *writer) Info(msg string, keyvals ...interface{})  { l.log(InfoLevel, msg, keyvals) }
+func (l *writer) Warn(msg string, keyvals ...interface{})  { l.log(WarnLevel, msg, keyvals) }
+func (l *writer) Error(msg string, keyvals ...interface{}) { l.log(ErrorLevel, msg, keyvals) }
+
+// log writes a message as a single line if its level is enabled.
+func (l *writer) log(level Level, msg string, keyvals []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	// a key without a value is kept so the mistake is visible
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, "MISSING")
+	}
+
+	var buf bytes.Buffer
+	timestamp := l.now().UTC().Format(time.RFC3339)
+	if l.format == JSON {
+		buf.WriteString(`{"time":`)
+		writeJSON(&buf, timestamp)
+		buf.WriteString(`,"level":`)
+		writeJSON(&buf, level.String())
+		buf.WriteString(`,"msg":`)
+		writeJSON(&buf, msg)
+		for i := 0; i < len(keyvals); i += 2 {
+			buf.WriteByte(',')
+			writeJSON(&buf, fmt.Sprint(keyvals[i]))
+			buf.WriteByte(':')
+			writeJSON(&buf, value(keyvals[i+1]))
+		}
+		buf.WriteByte('}')
+	} else {
+		fmt.Fprintf(&buf, "%s %-5s %s", timestamp, strings.ToUpper(level.String()), msg)
+		for i := 0; i < len(keyvals); i += 2 {
+			fmt.Fprintf(&buf, " %s=%s", keyvals[i], quote(fmt.Sprint(value(keyvals[i+1]))))
+		}
+	}
+	buf.WriteByte('\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.w.Write(buf.Bytes())
+}
+
+// value returns v in the form it is logged in: byte slices such as hashes
+// and ids are hex encoded, and errors are their message.
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return hex.EncodeToString(v)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+// writeJSON writes v encoded as JSON, or as a JSON string of its default
+// format if it can't be encoded.
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(data)
+}
+
+// quote quotes text values that would be ambiguous in key=value pairs.
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// Writer returns an io.Writer logging every line written to it as a message
+// of level, so output meant for the standard log package, such as with
+// log.SetOutput, goes through logger.
+func Writer(logger Logger, level Level) io.Writer {
+	return lineWriter{logger, level}
+}
+
+// lineWriter is the io.Writer of Writer.
+type lineWriter struct {
+	logger Logger
+	level  Level
+}
+
+// Write logs each non-empty line of p.
+func (w lineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(string(p), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		switch w.level {
+		case DebugLevel:
+			w.logger.Debug(line)
+		case InfoLevel:
+			w.logger.Info(line)
+		case WarnLevel:
+			w.logger.Warn(line)
+		case ErrorLevel:
+			w.logger.Error(line)
+		default:
+			return 0, errors.New("unknown log level " + w.level.String())
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLogger returns a logger writing to buf at a fixed time.
+func newTestLogger(buf *bytes.Buffer, level Level, format Format) Logger {
+	l := New(buf, level, format).(*writer)
+	l.now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+	return l
+}
+
+func TestLevels(t *testing.T) {
+	for _, c := range []struct {
+		level Level
+		want  string
+	}{
+		{DebugLevel, "debug info warn error"},
+		{InfoLevel, "info warn error"},
+		{WarnLevel, "warn error"},
+		{ErrorLevel, "error"},
+	} {
+		t.Run(c.level.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			l := newTestLogger(&buf, c.level, JSON)
+			l.Debug("debug")
+			l.Info("info")
+			l.Warn("warn")
+			l.Error("error")
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var msg struct{ Level, Msg string }
+				if err := json.Unmarshal([]byte(line), &msg); err != nil {
+					t.Fatalf("line %q is not JSON: %s", line, err)
+				}
+				if msg.Level != msg.Msg {
+					t.Fatalf("got level %s for message %s", msg.Level, msg.Msg)
+				}
+				got = append(got, msg.Msg)
+			}
+			if strings.Join(got, " ") != c.want {
+				t.Fatalf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	keyvals := []interface{}{"hash", []byte{0xab, 0xcd}, "height", 3, "err", errors.New("not found"), "odd"}
+	for _, c := range []struct {
+		format Format
+		want   string
+	}{
+		{Text, `2020-01-02T03:04:05Z WARN  Block rejected hash=abcd height=3 err="not found" odd=MISSING` + "\n"},
+		{JSON, `{"time":"2020-01-02T03:04:05Z","level":"warn","msg":"Block rejected","hash":"abcd","height":3,"err":"not found","odd":"MISSING"}` + "\n"},
+	} {
+		var buf bytes.Buffer
+		newTestLogger(&buf, DebugLevel, c.format).Warn("Block rejected", keyvals...)
+		if buf.String() != c.want {
+			t.Errorf("got %q, want %q", buf.String(), c.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		s     string
+		level Level
+		ok    bool
+	}{
+		{"debug", DebugLevel, true},
+		{"INFO", InfoLevel, true},
+		{"Warn", WarnLevel, true},
+		{"error", ErrorLevel, true},
+		{"verbose", 0, false},
+	} {
+		level, err := ParseLevel(c.s)
+		if (err == nil) != c.ok || level != c.level {
+			t.Errorf("ParseLevel(%q) = %s, %v", c.s, level, err)
+		}
+	}
+
+	for _, c := range []struct {
+		s      string
+		format Format
+		ok     bool
+	}{
+		{"text", Text, true},
+		{"JSON", JSON, true},
+		{"xml", 0, false},
+	} {
+		format, err := ParseFormat(c.s)
+		if (err == nil) != c.ok || format != c.format {
+			t.Errorf("ParseFormat(%q) = %d, %v", c.s, format, err)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	std := log.New(Writer(newTestLogger(&buf, InfoLevel, Text), ErrorLevel), "", 0)
+	std.Println("Unable to open blockchain:", "locked")
+
+	want := "2020-01-02T03:04:05Z ERROR Unable to open blockchain: locked\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
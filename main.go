@@ -1,29 +1,38 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/edwintcloud/gochain/cli"
-	_ "github.com/joho/godotenv/autoload" // load .env
 )
 
-// Initialize function which runs before main
-func init() {
-
-	// ensure DB_PATH is created
-	os.MkdirAll(os.Getenv("DB_PATH"), os.ModePerm)
-}
-
 // MAIN FUNCTION
 func main() {
 
-	// defer os exit as last deferment to ensure db is properly closed
-	// (probably unneccesary)
-	defer os.Exit(0)
+	// commands log a message and panic on failure, closing the db in their
+	// deferred calls, so exit with a non-zero status without a stack trace
+	defer func() {
+		if r := recover(); r != nil {
+			os.Exit(1)
+		}
+	}()
+
+	// read a .env file if there is one, which is deprecated. Run overrides
+	// the defaults with the config file and env vars
+	envFile := ""
+	loaded, err := cli.LoadEnvFile(".env")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	if loaded {
+		envFile = ".env"
+	}
 
 	// create new cli and run CLI
-	cli := cli.CLI{}
-	cli.Run()
+	c := cli.CLI{Config: cli.DefaultConfig(), EnvFile: envFile}
+	c.Run()
 
 	// w := wallet.CreateWallet()
 
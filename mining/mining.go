@@ -0,0 +1,241 @@
+// Package mining lets blocks be mined outside the node, such as on a GPU.
+//
+// A WorkProvider hands out Work, the header of a block template with the
+// offset of its nonce and the target its hash must be below, and takes the
+// nonces that solve it. A Solver searches for such a nonce. Miner joins the
+// two, fetching new work whenever the old work is solved or goes stale.
+//
+// HTTPProvider gets work from a node run with the serve command, and
+// Local from a BlockChain in the same process. CPUSolver searches on every
+// CPU, and ProcessSolver runs an external program for each piece of work,
+// which is how GPU miners are bridged: the program is given the work as a
+// line of JSON on stdin, in the format printed by getblocktemplate, and
+// prints the nonce it found in decimal on stdout. It exits with a non-zero
+// status, or is killed, if it doesn't find one. An OpenCL or CUDA program
+// only has to hash the header with each nonce written big endian at the
+// nonce offset and compare the hash with the target. The hash is sha256
+// unless the work names another proof of work hash of the network, such as
+// sha3 or scrypt.
+package mining
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/logging"
+)
+
+// Work is a block template to be mined. The hash of Header with a nonce
+// written as NonceSize big endian bytes at NonceOffset must be below
+// Target. Block is the serialized template, which is submitted with the
+// nonce. PowHash is the name of the proof of work hash of the network,
+// sha256 if empty. Template, if set, describes the transactions and
+// coinbase of the block for miners choosing what they mine.
+type Work struct {
+	Header      string `json:"header"`
+	NonceOffset int    `json:"nonceOffset"`
+	NonceSize   int    `json:"nonceSize"`
+	Target      string `json:"target"`
+	Height      int    `json:"height"`
+	Block       string `json:"block"`
+	PowHash     string `json:"powHash,omitempty"`
+
+	Template *blockchain.BlockTemplate `json:"template,omitempty"`
+}
+
+// NewWork returns the work of mining block.
+func NewWork(block *blockchain.Block) *Work {
+	pow := blockchain.NewProof(block)
+	header, offset := pow.Header()
+	work := &Work{
+		Header:      hex.EncodeToString(header),
+		NonceOffset: offset,
+		NonceSize:   blockchain.NonceSize,
+		Target:      fmt.Sprintf("%064x", pow.Target),
+		Height:      block.Height,
+		Block:       hex.EncodeToString(block.Serialize()),
+	}
+	if pow.Hash != nil && pow.Hash.ID() != blockchain.SHA256.ID() {
+		work.PowHash = pow.Hash.Name()
+	}
+	return work
+}
+
+// NewTemplateWork returns the work of mining the block of template,
+// described by it.
+func NewTemplateWork(template *blockchain.BlockTemplate) *Work {
+	work := NewWork(template.Block)
+	work.Template = template
+	return work
+}
+
+// powHash returns the proof of work hash of the work.
+func (w *Work) powHash() (blockchain.PowHash, error) {
+	hash, err := blockchain.PowHashByName(w.PowHash)
+	if err != nil {
+		return nil, errors.New("unable to decode work - " + err.Error())
+	}
+	return hash, nil
+}
+
+// decode returns the header and target of the work as bytes.
+func (w *Work) decode() ([]byte, []byte, error) {
+	header, err := hex.DecodeString(w.Header)
+	if err != nil {
+		return nil, nil, errors.New("unable to decode work header - " + err.Error())
+	}
+	target, err := hex.DecodeString(w.Target)
+	if err != nil {
+		return nil, nil, errors.New("unable to decode work target - " + err.Error())
+	}
+	if w.NonceSize != blockchain.NonceSize || w.NonceOffset < 0 || w.NonceOffset+w.NonceSize > len(header) {
+		return nil, nil, fmt.Errorf("work nonce of %d bytes at %d is outside the header", w.NonceSize, w.NonceOffset)
+	}
+	return header, target, nil
+}
+
+// WorkProvider hands out work and takes the nonces that solve it.
+type WorkProvider interface {
+	GetWork(ctx context.Context) (*Work, error)
+	SubmitWork(ctx context.Context, work *Work, nonce int64) error
+}
+
+// Solver searches for a nonce solving work. It returns false if ctx is done
+// before one is found.
+type Solver interface {
+	Solve(ctx context.Context, work *Work) (int64, bool, error)
+}
+
+// Miner mines blocks with work from Provider solved by Solver.
+type Miner struct {
+	Provider WorkProvider
+	Solver   Solver
+
+	// Refresh is how long work is searched before new work is fetched, so
+	// that new transactions and blocks are picked up
+	Refresh time.Duration
+
+	// Interrupt, if set, abandons the work being searched when it
+	// receives, such as when a block from elsewhere changes the tip, so
+	// new work is fetched without waiting for Refresh
+	Interrupt <-chan struct{}
+
+	// Counter, if set, counts the hashes tried by Solver, reported with
+	// the hash rate in Stats
+	Counter *HashCounter
+
+	// Logger, if set, receives the blocks mined and rejected
+	Logger logging.Logger
+
+	mutex sync.Mutex
+	stats Stats
+}
+
+// Stats are the counts of a Miner since it started running.
+type Stats struct {
+	Started     time.Time `json:"started"`
+	Hashes      uint64    `json:"hashes"`
+	HashRate    float64   `json:"hashRate"`
+	Work        int       `json:"work"`
+	Mined       int       `json:"mined"`
+	Rejected    int       `json:"rejected"`
+	Interrupted int       `json:"interrupted"`
+	LastHeight  int       `json:"lastHeight"`
+	LastMined   time.Time `json:"lastMined"`
+}
+
+// Stats returns the counts of the miner, with the hashes per second tried
+// since it started if it has a Counter.
+func (m *Miner) Stats() Stats {
+	m.mutex.Lock()
+	stats := m.stats
+	m.mutex.Unlock()
+	if m.Counter != nil && !stats.Started.IsZero() {
+		stats.Hashes = m.Counter.Count()
+		if elapsed := time.Since(stats.Started).Seconds(); elapsed > 0 {
+			stats.HashRate = float64(stats.Hashes) / elapsed
+		}
+	}
+	return stats
+}
+
+// record updates the stats of the miner with f.
+func (m *Miner) record(f func(stats *Stats)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	f(&m.stats)
+}
+
+// Run mines until ctx is done, returning nil, or until the provider fails
+// to hand out work. A solution the provider rejects, such as for work that
+// went stale while it was being solved, is logged and mining goes on.
+func (m *Miner) Run(ctx context.Context) error {
+	logger := m.Logger
+	if logger == nil {
+		logger = logging.Default
+	}
+	m.record(func(stats *Stats) { stats.Started = time.Now() })
+
+	for ctx.Err() == nil {
+
+		// interruptions before the work is fetched are already part of it
+		select {
+		case <-m.Interrupt:
+		default:
+		}
+
+		work, err := m.Provider.GetWork(ctx)
+		if ctx.Err() != nil {
+			break
+		} else if err != nil {
+			return err
+		}
+		m.record(func(stats *Stats) { stats.Work++ })
+
+		// search the work until it is solved, goes stale or is interrupted
+		solveCtx, cancel := context.WithTimeout(ctx, m.Refresh)
+		searched := make(chan struct{})
+		interrupted := make(chan struct{})
+		go func() {
+			select {
+			case <-m.Interrupt:
+				close(interrupted)
+				cancel()
+			case <-searched:
+			}
+		}()
+		nonce, found, err := m.Solver.Solve(solveCtx, work)
+		close(searched)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if !found {
+			select {
+			case <-interrupted:
+				m.record(func(stats *Stats) { stats.Interrupted++ })
+			default:
+			}
+			continue
+		}
+
+		if err := m.Provider.SubmitWork(ctx, work, nonce); err != nil {
+			m.record(func(stats *Stats) { stats.Rejected++ })
+			logger.Warn("Mined block was rejected", "height", work.Height, "nonce", nonce, "err", err)
+			continue
+		}
+		m.record(func(stats *Stats) {
+			stats.Mined++
+			stats.LastHeight = work.Height
+			stats.LastMined = time.Now()
+		})
+		logger.Info("Mined block", "height", work.Height, "nonce", nonce)
+	}
+
+	return nil
+}
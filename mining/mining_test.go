@@ -0,0 +1,311 @@
+package mining
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/logging"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+var miner = wallet.NewFromSeed([]byte("miner"))
+
+// newTestChain opens a chain with the lowest difficulty in a temporary
+// directory.
+func newTestChain(t *testing.T) *blockchain.BlockChain {
+	t.Helper()
+	bc, err := blockchain.Open(blockchain.Config{
+		Path: t.TempDir(),
+		Genesis: &blockchain.Genesis{
+			Network:    "test",
+			Difficulty: 1,
+			Timestamp:  1,
+		},
+		Logger: logging.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bc.Close() })
+	return bc
+}
+
+// testWork returns work with a made up header at difficulty.
+func testWork(difficulty uint) *Work {
+	header := make([]byte, 80)
+	for i := range header {
+		header[i] = byte(i)
+	}
+	target := make([]byte, 32)
+	target[difficulty/8] = 0x80 >> (difficulty % 8)
+	return &Work{
+		Header:      hex.EncodeToString(header),
+		NonceOffset: 64,
+		NonceSize:   blockchain.NonceSize,
+		Target:      hex.EncodeToString(target),
+	}
+}
+
+// solves returns whether nonce solves work.
+func solves(work *Work, nonce int64) bool {
+	header, target, err := work.decode()
+	if err != nil {
+		return false
+	}
+	hash, err := work.powHash()
+	if err != nil {
+		return false
+	}
+	binary.BigEndian.PutUint64(header[work.NonceOffset:], uint64(nonce))
+	return string(hash.Sum(header)) < string(target)
+}
+
+func TestCPUSolver(t *testing.T) {
+	for _, difficulty := range []uint{1, 4, 12} {
+		t.Run(fmt.Sprint(difficulty), func(t *testing.T) {
+			work := testWork(difficulty)
+			nonce, found, err := CPUSolver{}.Solve(context.Background(), work)
+			if err != nil || !found {
+				t.Fatalf("got %v, %v, want a nonce", found, err)
+			}
+			if !solves(work, nonce) {
+				t.Fatalf("nonce %d does not solve the work", nonce)
+			}
+
+			// the lowest nonce is returned
+			for lower := int64(0); lower < nonce; lower++ {
+				if solves(work, lower) {
+					t.Fatalf("got nonce %d, but %d is lower", nonce, lower)
+				}
+			}
+		})
+	}
+
+	// work names the proof of work hash of its network
+	work := testWork(4)
+	work.PowHash = blockchain.SHA3.Name()
+	nonce, found, err := CPUSolver{}.Solve(context.Background(), work)
+	if err != nil || !found || !solves(work, nonce) {
+		t.Fatalf("got nonce %d, %v, %v, want one solving the sha3 work", nonce, found, err)
+	}
+
+	// cancelled searches return without a nonce
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, found, err := (CPUSolver{}).Solve(ctx, testWork(255)); found || err != nil {
+		t.Fatalf("got %v, %v, want no nonce", found, err)
+	}
+}
+
+func TestProcessSolver(t *testing.T) {
+	work := testWork(8)
+	nonce, _, err := CPUSolver{}.Solve(context.Background(), work)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := nonce + 1
+	for solves(work, wrong) {
+		wrong++
+	}
+
+	for _, c := range []struct {
+		name   string
+		script string
+		found  bool
+		err    string
+	}{
+		{"valid nonce", fmt.Sprintf("cat > /dev/null; echo %d", nonce), true, ""},
+		{"not found", "cat > /dev/null; exit 1", false, ""},
+		{"garbage", "cat > /dev/null; echo nonce", false, "instead of a nonce"},
+		{"wrong nonce", fmt.Sprintf("cat > /dev/null; echo %d", wrong), false, "does not solve"},
+		{"reads work", `grep -q '"nonceOffset":64' && echo ` + fmt.Sprint(nonce), true, ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "solver")
+			if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+c.script+"\n"), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			got, found, err := (&ProcessSolver{Path: path}).Solve(context.Background(), work)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("got error %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil || found != c.found || found && got != nonce {
+				t.Fatalf("got %d, %v, %v, want %d, %v", got, found, err, nonce, c.found)
+			}
+		})
+	}
+}
+
+// countingProvider cancels mining after blocks submissions.
+type countingProvider struct {
+	WorkProvider
+	blocks int
+	cancel context.CancelFunc
+}
+
+func (p *countingProvider) SubmitWork(ctx context.Context, work *Work, nonce int64) error {
+	err := p.WorkProvider.SubmitWork(ctx, work, nonce)
+	if p.blocks--; p.blocks == 0 {
+		p.cancel()
+	}
+	return err
+}
+
+func TestMinerWithLocal(t *testing.T) {
+	bc := newTestChain(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	counter := &HashCounter{}
+	m := &Miner{
+		Provider: &countingProvider{&Local{BlockChain: bc, Address: miner.Address().String()}, 3, cancel},
+		Solver:   CPUSolver{Threads: 2, Counter: counter},
+		Refresh:  time.Minute,
+		Counter:  counter,
+		Logger:   logging.Discard,
+	}
+	if err := m.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if bc.Height() != 3 {
+		t.Fatalf("got height %d, want 3", bc.Height())
+	}
+	stats := m.Stats()
+	if stats.Mined != 3 || stats.Work != 3 || stats.LastHeight != 3 || stats.Hashes == 0 || stats.HashRate <= 0 {
+		t.Fatalf("got stats %+v after mining 3 blocks", stats)
+	}
+}
+
+// blockingSolver searches until ctx is done without finding a nonce.
+type blockingSolver struct{}
+
+func (blockingSolver) Solve(ctx context.Context, work *Work) (int64, bool, error) {
+	<-ctx.Done()
+	return 0, false, nil
+}
+
+// fetchingProvider hands out work, cancelling mining once it was fetched
+// fetches times and reporting each fetch on fetched.
+type fetchingProvider struct {
+	fetches int
+	fetched chan int
+	cancel  context.CancelFunc
+}
+
+func (p *fetchingProvider) GetWork(ctx context.Context) (*Work, error) {
+	p.fetches--
+	if p.fetches == 0 {
+		p.cancel()
+	}
+	p.fetched <- p.fetches
+	return testWork(255), nil
+}
+
+func (p *fetchingProvider) SubmitWork(ctx context.Context, work *Work, nonce int64) error {
+	return nil
+}
+
+func TestMinerInterrupt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan struct{}, 1)
+	provider := &fetchingProvider{fetches: 2, fetched: make(chan int, 2), cancel: cancel}
+	m := &Miner{
+		Provider:  provider,
+		Solver:    blockingSolver{},
+		Refresh:   time.Hour,
+		Interrupt: interrupt,
+		Logger:    logging.Discard,
+	}
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+
+	// new work is fetched without waiting for the refresh once the search
+	// is interrupted
+	<-provider.fetched
+	interrupt <- struct{}{}
+	select {
+	case <-provider.fetched:
+	case <-time.After(10 * time.Second):
+		t.Fatal("interrupted miner did not fetch new work")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if stats := m.Stats(); stats.Work != 1 || stats.Interrupted != 1 {
+		t.Fatalf("got stats %+v, want one interrupted piece of work", stats)
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	bc := newTestChain(t)
+	local := &Local{BlockChain: bc}
+
+	// serve templates and blocks like the serve command
+	mux := http.NewServeMux()
+	mux.HandleFunc("/template", func(w http.ResponseWriter, r *http.Request) {
+		local.Address = r.URL.Query().Get("address")
+		work, err := local.GetWork(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(work)
+	})
+	mux.HandleFunc("/block", func(w http.ResponseWriter, r *http.Request) {
+		var s submission
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := local.SubmitWork(r.Context(), &Work{Block: s.Block}, s.Nonce); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p := &HTTPProvider{URL: server.URL + "/", Address: miner.Address().String()}
+	work, err := p.GetWork(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work.Height != 1 || local.Address != p.Address {
+		t.Fatalf("got work at height %d for %s", work.Height, local.Address)
+	}
+	nonce, _, err := CPUSolver{}.Solve(context.Background(), work)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a wrong nonce is rejected with the error of the node
+	wrong := nonce + 1
+	for solves(work, wrong) {
+		wrong++
+	}
+	if err := p.SubmitWork(context.Background(), work, wrong); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("got error %v, want a rejection", err)
+	}
+	if err := p.SubmitWork(context.Background(), work, nonce); err != nil {
+		t.Fatal(err)
+	}
+	if bc.Height() != 1 {
+		t.Fatalf("got height %d, want 1", bc.Height())
+	}
+}
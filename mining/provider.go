@@ -0,0 +1,130 @@
+package mining
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/edwintcloud/gochain/blockchain"
+)
+
+// HTTPProvider gets work from a node run with the serve command at URL,
+// such as http://localhost:8546, rewarding Address.
+type HTTPProvider struct {
+	URL     string
+	Address string
+
+	// Client is used for requests, or http.DefaultClient if it is nil
+	Client *http.Client
+}
+
+// submission is the body posted to /block, as read by the serve command.
+type submission struct {
+	Block string `json:"block"`
+	Nonce int64  `json:"nonce"`
+}
+
+// GetWork gets a block template from /template.
+func (p *HTTPProvider) GetWork(ctx context.Context) (*Work, error) {
+	u := strings.TrimSuffix(p.URL, "/") + "/template?address=" + url.QueryEscape(p.Address)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var work Work
+	if err := p.do(req.WithContext(ctx), &work); err != nil {
+		return nil, errors.New("unable to get work - " + err.Error())
+	}
+	return &work, nil
+}
+
+// SubmitWork posts the block of work mined with nonce to /block.
+func (p *HTTPProvider) SubmitWork(ctx context.Context, work *Work, nonce int64) error {
+	body, err := json.Marshal(submission{Block: work.Block, Nonce: nonce})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(p.URL, "/")+"/block", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := p.do(req.WithContext(ctx), nil); err != nil {
+		return errors.New("unable to submit work - " + err.Error())
+	}
+	return nil
+}
+
+// do sends req and decodes the JSON response into v if it is not nil. The
+// body of responses with an error status is returned as the error.
+func (p *HTTPProvider) do(req *http.Request, v interface{}) error {
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Local hands out work from a BlockChain in the same process, rewarding
+// Address. Calls to the chain hold Mutex, if it is set, so the chain can be
+// shared with other users of the mutex.
+type Local struct {
+	BlockChain *blockchain.BlockChain
+	Address    string
+	Mutex      *sync.Mutex
+}
+
+// lock locks the mutex if there is one, returning the function unlocking
+// it.
+func (p *Local) lock() func() {
+	if p.Mutex == nil {
+		return func() {}
+	}
+	p.Mutex.Lock()
+	return p.Mutex.Unlock
+}
+
+// GetWork returns a block template on the tip of the chain.
+func (p *Local) GetWork(ctx context.Context) (*Work, error) {
+	defer p.lock()()
+
+	block, err := p.BlockChain.NewBlockTemplate(p.Address)
+	if err != nil {
+		return nil, err
+	}
+	return NewWork(block), nil
+}
+
+// SubmitWork adds the block of work mined with nonce to the chain.
+func (p *Local) SubmitWork(ctx context.Context, work *Work, nonce int64) error {
+	data, err := hex.DecodeString(work.Block)
+	if err != nil {
+		return errors.New("unable to decode work block - " + err.Error())
+	}
+	defer p.lock()()
+
+	return p.BlockChain.SubmitBlock(blockchain.Deserialize(data), int(nonce))
+}
@@ -0,0 +1,162 @@
+package mining
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// CPUSolver searches for nonces on Threads CPUs, or on every CPU if Threads
+// is zero. It is the reference for solvers run by ProcessSolver.
+type CPUSolver struct {
+	Threads int
+
+	// Counter, if set, counts the hashes tried
+	Counter *HashCounter
+}
+
+// HashCounter counts the hashes a solver tries. It is safe for concurrent
+// use.
+type HashCounter struct {
+	hashes uint64
+}
+
+// Add counts n more hashes.
+func (c *HashCounter) Add(n uint64) {
+	atomic.AddUint64(&c.hashes, n)
+}
+
+// Count returns the number of hashes counted.
+func (c *HashCounter) Count() uint64 {
+	return atomic.LoadUint64(&c.hashes)
+}
+
+// Solve tries nonces from zero, each worker taking every nth nonce, and
+// returns the lowest nonce found.
+func (s CPUSolver) Solve(ctx context.Context, work *Work) (int64, bool, error) {
+	header, target, err := work.decode()
+	if err != nil {
+		return 0, false, err
+	}
+	powHash, err := work.powHash()
+	if err != nil {
+		return 0, false, err
+	}
+
+	workers := s.Threads
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	best := int64(math.MaxInt64)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(nonce int64) {
+			defer wg.Done()
+
+			// count the hashes tried in batches to keep the counter cheap
+			var tried uint64
+			defer func() {
+				if s.Counter != nil {
+					s.Counter.Add(tried)
+				}
+			}()
+
+			// each worker writes its nonces into its own copy of the header
+			data := append([]byte{}, header...)
+			for ; nonce >= 0 && nonce < atomic.LoadInt64(&best); nonce += int64(workers) {
+
+				// check for cancellation every so often
+				if nonce%4096 < int64(workers) {
+					if ctx.Err() != nil {
+						return
+					}
+					if s.Counter != nil {
+						s.Counter.Add(tried)
+						tried = 0
+					}
+				}
+
+				binary.BigEndian.PutUint64(data[work.NonceOffset:], uint64(nonce))
+				tried++
+				if bytes.Compare(powHash.Sum(data), target) < 0 {
+					for {
+						current := atomic.LoadInt64(&best)
+						if nonce >= current || atomic.CompareAndSwapInt64(&best, current, nonce) {
+							break
+						}
+					}
+					return
+				}
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	if best == math.MaxInt64 {
+		return 0, false, nil
+	}
+	return best, true, nil
+}
+
+// ProcessSolver runs the program at Path with Args for each piece of work,
+// such as a GPU miner. The program reads the work as JSON from stdin and
+// prints the nonce it found in decimal on stdout. It is killed when the
+// work goes stale.
+type ProcessSolver struct {
+	Path string
+	Args []string
+}
+
+// Solve runs the program for work. It returns false if the program is
+// killed or exits with a non-zero status.
+func (s *ProcessSolver) Solve(ctx context.Context, work *Work) (int64, bool, error) {
+	header, target, err := work.decode()
+	if err != nil {
+		return 0, false, err
+	}
+	powHash, err := work.powHash()
+	if err != nil {
+		return 0, false, err
+	}
+	input, err := json.Marshal(work)
+	if err != nil {
+		return 0, false, err
+	}
+
+	cmd := exec.CommandContext(ctx, s.Path, s.Args...)
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return 0, false, nil
+	}
+	if _, ok := err.(*exec.ExitError); ok {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, errors.New("unable to run solver - " + err.Error())
+	}
+
+	nonce, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("solver printed %q instead of a nonce", strings.TrimSpace(string(output)))
+	}
+
+	// check the nonce so a broken solver doesn't get blocks rejected
+	binary.BigEndian.PutUint64(header[work.NonceOffset:], uint64(nonce))
+	if bytes.Compare(powHash.Sum(header), target) >= 0 {
+		return 0, false, fmt.Errorf("solver found nonce %d, which does not solve the work", nonce)
+	}
+	return nonce, true, nil
+}
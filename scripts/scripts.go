@@ -0,0 +1,204 @@
+// Package scripts runs small Starlark scripts on node events, so operators
+// can add behaviors such as alerts or transaction policy without writing a
+// plugin executable.
+//
+// Scripts are listed in the SCRIPTS env var, separated by commas. When an
+// event is sent, each script is run in a child process and the function
+// named on_<event> is called with the event payload as its only argument,
+// decoded from JSON into dicts, lists, strings, ints and bools. Events and
+// their payloads are described in the hooks package:
+//
+//	def on_blockconnected(block):
+//	    print("block", block["height"], block["hash"])
+//
+//	def on_txaccept(tx):
+//	    for out in tx["outputs"]:
+//	        if out["value"] > 1000:
+//	            fail("transfers over 1000 need approval")
+//
+// A script rejects a transaction by calling fail. Scripts can only use the
+// Starlark builtins; load, files, the network and the clock are not
+// available. A script is stopped after SCRIPT_TIMEOUT (a duration, 5s by
+// default) or when its heap grows past SCRIPT_MEMORY_MB megabytes (64 by
+// default).
+package scripts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edwintcloud/gochain/hooks"
+	"github.com/edwintcloud/gochain/logging"
+	"go.starlark.net/starlark"
+)
+
+const (
+	// defaultTimeout is how long a script may run for one event.
+	defaultTimeout = 5 * time.Second
+
+	// defaultMemoryLimit is the heap size in megabytes a script may use.
+	defaultMemoryLimit = 64
+)
+
+// logger receives the output of print calls in scripts and the errors of
+// scripts that exceed their memory limit.
+var logger = logging.Default
+
+// SetLogger sets the logger receiving the output of print calls in scripts
+// and the errors of scripts that exceed their memory limit, which are
+// written to stderr by default. It should be called before scripts are run.
+func SetLogger(l logging.Logger) {
+	logger = l
+}
+
+// Script is a hooks.Plugin that runs a Starlark script in a child process
+// with time and memory limits.
+type Script struct {
+	File        string
+	Timeout     time.Duration
+	MemoryLimit int
+}
+
+// RegisterFromEnv registers the scripts listed in the SCRIPTS env var with
+// the hooks package.
+func RegisterFromEnv() {
+	timeout := defaultTimeout
+	if value := os.Getenv("SCRIPT_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Panicln("Unable to parse env var SCRIPT_TIMEOUT: ", err.Error())
+		}
+		timeout = parsed
+	}
+
+	memoryLimit := defaultMemoryLimit
+	if value := os.Getenv("SCRIPT_MEMORY_MB"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			log.Panicln("Unable to convert env var SCRIPT_MEMORY_MB to int: ", err.Error())
+		}
+		memoryLimit = parsed
+	}
+
+	for _, file := range strings.Split(os.Getenv("SCRIPTS"), ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			hooks.Register(&Script{file, timeout, memoryLimit})
+		}
+	}
+}
+
+// Handle runs the script for an event in a child process, which is this
+// executable running the runscript command.
+func (s *Script) Handle(event string, payload []byte) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return errors.New("unable to find executable - " + err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, executable, "runscript",
+		"-file", s.File,
+		"-event", event,
+		"-memory", strconv.Itoa(s.MemoryLimit))
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("script %s timed out after %s", s.File, s.Timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("script %s failed - %s", s.File, err.Error())
+	}
+	return nil
+}
+
+// Run runs the handler for an event in a script in the current process. The
+// process exits if the heap grows past memoryLimit megabytes.
+func Run(file, event string, payload []byte, memoryLimit int) error {
+	go watchMemory(uint64(memoryLimit) << 20)
+
+	var data interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return errors.New("unable to decode event payload - " + err.Error())
+	}
+
+	// scripts may not load other modules
+	thread := &starlark.Thread{
+		Name: file,
+		Print: func(thread *starlark.Thread, msg string) {
+			logger.Info(msg, "script", file)
+		},
+		Load: func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+			return nil, errors.New("load is not available to scripts")
+		},
+	}
+
+	globals, err := starlark.ExecFile(thread, file, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	// scripts only need to define handlers for the events they use
+	handler, ok := globals["on_"+event]
+	if !ok {
+		return nil
+	}
+	_, err = starlark.Call(thread, handler, starlark.Tuple{toValue(data)}, nil)
+	return err
+}
+
+// watchMemory exits the process once the heap grows past limit bytes.
+func watchMemory(limit uint64) {
+	var stats runtime.MemStats
+	for range time.Tick(10 * time.Millisecond) {
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > limit {
+			logger.Error("Script exceeded its memory limit", "limit", limit)
+			os.Exit(1)
+		}
+	}
+}
+
+// toValue converts a value decoded from JSON into a Starlark value.
+func toValue(data interface{}) starlark.Value {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		dict := starlark.NewDict(len(v))
+		for key, value := range v {
+			dict.SetKey(starlark.String(key), toValue(value))
+		}
+		return dict
+	case []interface{}:
+		var elems []starlark.Value
+		for _, value := range v {
+			elems = append(elems, toValue(value))
+		}
+		return starlark.NewList(elems)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return starlark.MakeInt64(int64(v))
+		}
+		return starlark.Float(v)
+	case string:
+		return starlark.String(v)
+	case bool:
+		return starlark.Bool(v)
+	default:
+		return starlark.None
+	}
+}
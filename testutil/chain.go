@@ -0,0 +1,136 @@
+package testutil
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/logging"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// DefaultSpacing is how far the clock of a Chain moves before each block
+// unless its Spacing is changed.
+const DefaultSpacing = time.Minute
+
+// Chain is a blockchain for tests, closed when the test ends. Its blocks
+// are timestamped by Clock, which Mine advances by Spacing before each
+// block, and their coinbases carry their height instead of random data.
+type Chain struct {
+	*blockchain.BlockChain
+
+	// Clock gives the time blocks are mined and transactions enter the
+	// mempool at.
+	Clock *Clock
+
+	// Spacing is how far Clock moves before each block.
+	Spacing time.Duration
+
+	t testing.TB
+}
+
+// NewChain opens a chain in a temporary directory with cfg, filling in the
+// path and the clock, the network of Genesis unless cfg has a genesis
+// configuration, and discarding its status messages unless it has a
+// logger. The clock starts at the timestamp of the genesis block.
+func NewChain(t testing.TB, cfg blockchain.Config) *Chain {
+	t.Helper()
+	if cfg.Genesis == nil {
+		cfg.Genesis = Genesis()
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = logging.Discard
+	}
+	cfg.Path = t.TempDir()
+	cfg.NoSyncWrites = true
+	clock := NewClock(time.Unix(cfg.Genesis.Timestamp, 0).UTC())
+	cfg.Clock = clock.Now
+
+	bc, err := blockchain.Open(cfg)
+	if err != nil {
+		t.Fatalf("unable to open chain: %s", err)
+	}
+	t.Cleanup(func() { bc.Close() })
+	return &Chain{BlockChain: bc, Clock: clock, Spacing: DefaultSpacing, t: t}
+}
+
+// Mine adds txs to the mempool, failing the test if one is refused, and
+// mines the mempool into a block paying the subsidy and fees to miner, one
+// Spacing after the clock stood.
+func (c *Chain) Mine(miner *wallet.Wallet, txs ...*blockchain.Transaction) *blockchain.Block {
+	c.t.Helper()
+	for _, tx := range txs {
+		if err := c.AddToMempool(tx); err != nil {
+			c.t.Fatalf("transaction %x is refused from the mempool: %s", tx.ID, err)
+		}
+	}
+
+	// collect the fees of the pending transactions in a coinbase that is
+	// the same every run
+	pending := c.MempoolTransactions()
+	var fees units.Amount
+	for _, tx := range pending {
+		fee, err := c.TransactionFee(tx)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		fees += fee
+	}
+	coinbase := blockchain.CoinbaseTx(miner.Address().String(), fmt.Sprintf("testutil block %d", c.Height()+1), fees)
+
+	c.Clock.Advance(c.Spacing)
+	block, err := c.AddBlock(append([]*blockchain.Transaction{coinbase}, pending...))
+	if err != nil {
+		c.t.Fatalf("unable to mine block: %s", err)
+	}
+	return block
+}
+
+// MineBlocks mines n blocks paying miner, with the transactions pending in
+// the mempool in the first, and returns them in order.
+func (c *Chain) MineBlocks(n int, miner *wallet.Wallet) []*blockchain.Block {
+	c.t.Helper()
+	blocks := make([]*blockchain.Block, n)
+	for i := range blocks {
+		blocks[i] = c.Mine(miner)
+	}
+	return blocks
+}
+
+// Pay returns a transaction signed by from paying amount to to and fee,
+// with the change back to from, without adding it to the mempool.
+func (c *Chain) Pay(from, to *wallet.Wallet, amount, fee units.Amount) *blockchain.Transaction {
+	c.t.Helper()
+	tx, err := c.NewTransaction(from, to.Address().String(), amount, fee, "")
+	if err != nil {
+		c.t.Fatalf("unable to pay %s: %s", units.FormatAmount(amount), err)
+	}
+	return tx
+}
+
+// Balance returns the value of the unspent outputs of w, including those of
+// pending transactions.
+func (c *Chain) Balance(w *wallet.Wallet) units.Amount {
+	c.t.Helper()
+	outs, err := c.FindUnspentTxOutputs(wallet.GeneratePublicKeyHash(w.PublicKey))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var total units.Amount
+	for _, out := range outs {
+		total += out.Value
+	}
+	return total
+}
+
+// TipBlock returns the block at the tip of the best chain.
+func (c *Chain) TipBlock() *blockchain.Block {
+	c.t.Helper()
+	block, err := c.GetBlock(c.Tip())
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return block
+}
@@ -0,0 +1,88 @@
+// Package testutil builds what consensus and wallet tests need without
+// waiting on real mining or the system clock: chains whose blocks are
+// mined instantly at the lowest difficulty, a clock that only moves when
+// told to, and wallets derived from names.
+//
+// Block hashes commit to the ids of their transactions, which don't cover
+// signatures, and to timestamps taken from the clock, so the same steps on
+// a Chain always give the same blocks.
+package testutil
+
+import (
+	"sync"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// GenesisAllocation is the amount Alice holds in a new chain.
+const GenesisAllocation = 1000 * units.Coin
+
+// Epoch is the timestamp of the genesis block of new chains, where their
+// clock starts.
+var Epoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	// Alice receives the genesis allocation of new chains
+	Alice = NewWallet("alice")
+
+	// Bob and Carol start without coins
+	Bob   = NewWallet("bob")
+	Carol = NewWallet("carol")
+)
+
+// NewWallet returns the wallet for a name, which is the same every time.
+// Its key is guessable, so it must only hold coins of tests.
+func NewWallet(name string) *wallet.Wallet {
+	return wallet.NewFromSeed([]byte(name))
+}
+
+// Genesis returns the configuration of a network allocating
+// GenesisAllocation to Alice at Epoch, whose blocks are all of difficulty 1
+// so they are mined instantly.
+func Genesis() *blockchain.Genesis {
+	return &blockchain.Genesis{
+		Network:       "testutil",
+		Message:       "testutil",
+		Allocations:   map[string]units.Amount{Alice.Address().String(): GenesisAllocation},
+		Difficulty:    1,
+		MinDifficulty: 1,
+		MaxDifficulty: 1,
+		Timestamp:     Epoch.Unix(),
+	}
+}
+
+// Clock is a clock for tests that only moves when told to. It is safe for
+// concurrent use.
+type Clock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewClock returns a clock standing at start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to t, which may be in its past.
+func (c *Clock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = t
+}
@@ -0,0 +1,67 @@
+package testutil
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/edwintcloud/gochain/blockchain"
+	"github.com/edwintcloud/gochain/units"
+)
+
+func TestChainIsDeterministic(t *testing.T) {
+	build := func() *Chain {
+		c := NewChain(t, blockchain.Config{})
+		c.Mine(Carol, c.Pay(Alice, Bob, 10*units.Coin, units.Coin))
+		c.MineBlocks(3, Bob)
+		return c
+	}
+	first, second := build(), build()
+
+	if !bytes.Equal(first.Tip(), second.Tip()) {
+		t.Fatalf("the same steps gave tips %x and %x", first.Tip(), second.Tip())
+	}
+	if got, want := first.Balance(Bob), 10*units.Coin+3*blockchain.Subsidy; got != want {
+		t.Fatalf("bob holds %s, want %s", got, want)
+	}
+	if got, want := first.Balance(Carol), blockchain.Subsidy+units.Coin; got != want {
+		t.Fatalf("carol holds %s, want %s", got, want)
+	}
+	if got, want := first.TipBlock().Timestamp, Epoch.Add(4*DefaultSpacing).Unix(); got != want {
+		t.Fatalf("tip has timestamp %d, want %d", got, want)
+	}
+}
+
+func TestClockDrivesDifficulty(t *testing.T) {
+	genesis := Genesis()
+	genesis.Difficulty = 4
+	genesis.MaxDifficulty = 4
+	genesis.TargetSpacing = 60
+	genesis.AllowMinDifficulty = true
+	c := NewChain(t, blockchain.Config{Genesis: genesis})
+
+	// blocks on time keep the difficulty, and a stall allows the minimum
+	if got := c.Mine(Alice).GetDifficulty(); got != 4 {
+		t.Fatalf("block on time has difficulty %d, want 4", got)
+	}
+	c.Spacing = 3 * time.Minute
+	if got := c.Mine(Alice).GetDifficulty(); got != 1 {
+		t.Fatalf("block after a stall has difficulty %d, want 1", got)
+	}
+	c.Spacing = time.Minute
+	if got := c.Mine(Alice).GetDifficulty(); got != 4 {
+		t.Fatalf("block after the stall has difficulty %d, want 4", got)
+	}
+}
+
+func TestClock(t *testing.T) {
+	clock := NewClock(Epoch)
+	clock.Advance(time.Hour)
+	if got := clock.Now(); !got.Equal(Epoch.Add(time.Hour)) {
+		t.Fatalf("clock stands at %s after advancing an hour", got)
+	}
+	clock.Set(Epoch)
+	if got := clock.Now(); !got.Equal(Epoch) {
+		t.Fatalf("clock stands at %s after being set back", got)
+	}
+}
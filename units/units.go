@@ -0,0 +1,138 @@
+// Package units converts between amounts in base units, which are stored
+// in transactions, and decimal coin strings shown to and entered by users.
+// One coin is 10^8 base units.
+package units
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Decimals is the number of decimal places in a coin amount.
+	Decimals = 8
+
+	// Coin is the number of base units in one coin.
+	Coin Amount = 100000000
+)
+
+// Amount is an amount in base units. Its type keeps amounts from being
+// mixed up with heights, counts and other ints.
+type Amount int
+
+// String formats the amount as a decimal coin string.
+func (a Amount) String() string {
+	return FormatAmount(a)
+}
+
+// MarshalJSON encodes the amount as a number of base units.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int(a))
+}
+
+// UnmarshalJSON decodes a number of base units or a decimal coin string.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		amount, err := ParseAmount(s)
+		if err != nil {
+			return err
+		}
+		*a = amount
+		return nil
+	}
+
+	var units int
+	if err := json.Unmarshal(data, &units); err != nil {
+		return errors.New("amount must be a number of base units or a coin string")
+	}
+	*a = Amount(units)
+	return nil
+}
+
+// Add returns the sum of a and b, or false if it overflows an Amount.
+func Add(a, b Amount) (Amount, bool) {
+	sum := a + b
+	if b > 0 && sum < a || b < 0 && sum > a {
+		return 0, false
+	}
+	return sum, true
+}
+
+// ParseAmount parses a decimal coin string such as "1.25" into base units.
+// Digits past Decimals decimal places are rounded half away from zero.
+func ParseAmount(s string) (Amount, error) {
+	input := strings.TrimSpace(s)
+
+	// split the sign from the digits
+	negative := strings.HasPrefix(input, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(input, "-"), "+")
+
+	// split the whole and fractional parts
+	whole, frac := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		whole, frac = digits[:i], digits[i+1:]
+	}
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return 0, errors.New("invalid amount " + strconv.Quote(input))
+	}
+
+	// round digits past the last decimal place
+	roundUp := len(frac) > Decimals && frac[Decimals] >= '5'
+	if len(frac) > Decimals {
+		frac = frac[:Decimals]
+	}
+	frac += strings.Repeat("0", Decimals-len(frac))
+
+	// combine the parts, checking for overflow
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err == nil && roundUp {
+		if units == math.MaxInt64 {
+			err = strconv.ErrRange
+		}
+		units++
+	}
+	if err != nil || int64(int(units)) != units {
+		return 0, errors.New("amount " + strconv.Quote(input) + " is out of range")
+	}
+
+	if negative {
+		units = -units
+	}
+	return Amount(units), nil
+}
+
+// FormatAmount formats an amount in base units as a decimal coin string
+// without trailing zeros, such as "1.25" for 125000000.
+func FormatAmount(units Amount) string {
+	sign := ""
+	magnitude := uint64(units)
+	if units < 0 {
+		sign = "-"
+		magnitude = uint64(-int64(units))
+	}
+
+	// split into whole coins and the fraction of a coin
+	whole := strconv.FormatUint(magnitude/uint64(Coin), 10)
+	frac := strconv.FormatUint(magnitude%uint64(Coin), 10)
+	if frac == "0" {
+		return sign + whole
+	}
+
+	// left pad the fraction and trim trailing zeros
+	frac = strings.Repeat("0", Decimals-len(frac)) + frac
+	return sign + whole + "." + strings.TrimRight(frac, "0")
+}
+
+// isDigits returns whether s contains only decimal digits.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
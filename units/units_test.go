@@ -0,0 +1,121 @@
+package units
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want Amount
+	}{
+		{"1.25", 125000000},
+		{"0", 0},
+		{"1", Coin},
+		{"1.", Coin},
+		{".5", Coin / 2},
+		{" +2.5 ", 250000000},
+		{"-0.00000001", -1},
+		{"0.00000001", 1},
+		{"0.000000005", 1},
+		{"0.000000004", 0},
+		{"-0.000000005", -1},
+		{"0.123456789", 12345679},
+		{"0.99999999999", Coin},
+		{"92233720368.54775807", math.MaxInt64},
+	} {
+		got, err := ParseAmount(c.in)
+		if err != nil {
+			t.Errorf("ParseAmount(%q): %s", c.in, err)
+		} else if got != c.want {
+			t.Errorf("ParseAmount(%q) = %d, want %d", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseAmountRejectsInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		".",
+		"-",
+		"abc",
+		"1.2.3",
+		"1,5",
+		"1e8",
+		"--1",
+		"92233720368.54775808",
+		"92233720368.547758075",
+	} {
+		if got, err := ParseAmount(in); err == nil {
+			t.Errorf("ParseAmount(%q) = %d, want error", in, got)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	for _, c := range []struct {
+		in   Amount
+		want string
+	}{
+		{125000000, "1.25"},
+		{0, "0"},
+		{Coin, "1"},
+		{1, "0.00000001"},
+		{-1, "-0.00000001"},
+		{10 * Coin, "10"},
+		{123456789, "1.23456789"},
+		{math.MaxInt64, "92233720368.54775807"},
+		{math.MinInt64, "-92233720368.54775808"},
+	} {
+		if got := FormatAmount(c.in); got != c.want {
+			t.Errorf("FormatAmount(%d) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestFormatParseRoundTrip(t *testing.T) {
+	for _, units := range []Amount{0, 1, -1, 99999999, Coin + 1, 2100000000000000} {
+		got, err := ParseAmount(FormatAmount(units))
+		if err != nil || got != units {
+			t.Errorf("round trip of %d gave %d, %v", units, got, err)
+		}
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	data, err := json.Marshal(Amount(125000000))
+	if err != nil || string(data) != "125000000" {
+		t.Fatalf("got %s, %v, want 125000000", data, err)
+	}
+
+	for _, in := range []string{`125000000`, `"1.25"`} {
+		var a Amount
+		if err := json.Unmarshal([]byte(in), &a); err != nil || a != 125000000 {
+			t.Errorf("unmarshaling %s gave %d, %v", in, a, err)
+		}
+	}
+	for _, in := range []string{`"abc"`, `true`, `1.5`} {
+		var a Amount
+		if err := json.Unmarshal([]byte(in), &a); err == nil {
+			t.Errorf("unmarshaling %s gave %d, want error", in, a)
+		}
+	}
+
+	if s := Amount(-Coin / 2).String(); s != "-0.5" {
+		t.Fatalf("got %q, want -0.5", s)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	if sum, ok := Add(2*Coin, -Coin); !ok || sum != Coin {
+		t.Fatalf("Add(2, -1) = %s, %v", sum, ok)
+	}
+	if _, ok := Add(math.MaxInt64, 1); ok {
+		t.Fatal("Add overflowed past the largest amount")
+	}
+	if _, ok := Add(math.MinInt64, -1); ok {
+		t.Fatal("Add overflowed past the smallest amount")
+	}
+}
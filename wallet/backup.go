@@ -0,0 +1,90 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"os"
+)
+
+// ErrNotEncrypted is returned when backing up a wallets file that is not
+// encrypted, as backups leave the machine and must not hold plain keys.
+var ErrNotEncrypted = errors.New("wallets file is not encrypted")
+
+// Backup returns the encrypted wallets file as stored, which OpenBackup
+// reads with the passphrase of the file. It does not need the key of the
+// store.
+func (s *Store) Backup() ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	fileBytes, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotEncrypted
+	} else if err != nil {
+		return nil, errors.New("unable to read wallets file - " + err.Error())
+	}
+	if !isEncrypted(fileBytes) {
+		return nil, ErrNotEncrypted
+	}
+	return fileBytes, nil
+}
+
+// OpenBackup decrypts a backup from Backup with the passphrase of the
+// wallets file it was taken from, returning its wallets keyed by address.
+func OpenBackup(data []byte, passphrase string) (map[string]*Wallet, error) {
+	if !isEncrypted(data) {
+		return nil, errors.New("backup is not an encrypted wallets file")
+	}
+	file, err := decodeEncrypted(data)
+	if err != nil {
+		return nil, err
+	}
+	if file.Iterations < 1 {
+		return nil, errors.New("backup has an invalid iteration count")
+	}
+
+	key := deriveKey(passphrase, file.Salt, file.Iterations)
+	defer key.Wipe()
+	plain, err := key.open(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// backups of files not yet migrated hold the gob format
+	var wallets map[string]*Wallet
+	if isJSON(plain) {
+		wallets, err = decodeWallets(plain)
+	} else {
+		wallets, err = decodeLegacyWallets(plain)
+	}
+	if err != nil {
+		return nil, errors.New("unable to decode backup - " + err.Error())
+	}
+	return wallets, nil
+}
+
+// Archive returns an encrypted wallets file holding only w, encrypted with
+// passphrase, so a key removed from a wallets file can be kept apart and
+// read again with OpenBackup.
+func Archive(w *Wallet, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is empty")
+	}
+	data, err := encodeWallets(map[string]*Wallet{w.Address().String(): w}, nil)
+	if err != nil {
+		return nil, errors.New("unable to encode wallet - " + err.Error())
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.New("unable to generate salt - " + err.Error())
+	}
+	key := deriveKey(passphrase, salt, keyIterations)
+	defer key.Wipe()
+	sealed, err := key.seal(data)
+	if err != nil {
+		return nil, errors.New("unable to encrypt wallet - " + err.Error())
+	}
+	return sealed, nil
+}
@@ -0,0 +1,80 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "wallets.dat"))
+	w, err := store.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// plain wallets files are not backed up
+	if _, err := store.Backup(); err != ErrNotEncrypted {
+		t.Fatalf("got error %v, want ErrNotEncrypted", err)
+	}
+
+	if err := store.Encrypt("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	store.Lock()
+	backup, err := store.Backup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenBackup(backup, "wrong"); err != ErrWrongPassphrase {
+		t.Fatalf("got error %v, want ErrWrongPassphrase", err)
+	}
+	wallets, err := OpenBackup(backup, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, ok := wallets[w.Address().String()]
+	if !ok || !restored.CanSign() {
+		t.Fatalf("backup does not hold the key of %s", w.Address())
+	}
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "wallets.dat"))
+	w, err := store.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the archive holds only the wallet archived
+	archive, err := Archive(w, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenBackup(archive, "wrong"); err != ErrWrongPassphrase {
+		t.Fatalf("got error %v, want ErrWrongPassphrase", err)
+	}
+	wallets, err := OpenBackup(archive, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored, ok := wallets[w.Address().String()]; len(wallets) != 1 || !ok || !restored.CanSign() {
+		t.Fatalf("archive holds %d wallets, want only the key of %s", len(wallets), w.Address())
+	}
+
+	// rewriting the wallets file leaves no temporary files behind
+	if err := store.Delete(w.Address().String()); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmp-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("wallets directory holds temporary files %v", files)
+	}
+}
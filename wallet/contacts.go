@@ -0,0 +1,197 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Contact is the address of someone else saved in a wallets file under a
+// name, so it can be given in place of the address.
+type Contact struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// namePattern matches the names of contacts and the labels of wallets,
+// which start with a letter so they are not mistaken for amounts or
+// heights.
+var namePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+
+// ValidName returns whether name can name a contact or label a wallet. It
+// must not be an address itself.
+func ValidName(name string) bool {
+	return len(name) <= 64 && namePattern.MatchString(name) && !ValidateAddress(name)
+}
+
+// readContacts returns the contacts in the wallets file ordered by name.
+// The caller must hold the lock of the store.
+func (s *Store) readContacts() ([]Contact, error) {
+	plain, _, err := s.contents()
+	if err != nil {
+		return nil, err
+	}
+	if plain == nil || !isJSON(plain) {
+		return nil, nil
+	}
+	contacts, err := decodeContacts(plain)
+	if err != nil {
+		return nil, errors.New("unable to decode wallets file - " + err.Error())
+	}
+	sort.Slice(contacts, func(i, j int) bool { return contacts[i].Name < contacts[j].Name })
+	return contacts, nil
+}
+
+// Contacts returns the contacts in the store ordered by name.
+func (s *Store) Contacts() ([]Contact, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	contacts, err := s.readContacts()
+	if contacts == nil && err == nil {
+		contacts = []Contact{}
+	}
+	return contacts, err
+}
+
+// AddContact saves a contact, replacing the address of the contact of its
+// name if there is one. Its name must not label a wallet in the store.
+func (s *Store) AddContact(c Contact) error {
+	if !ValidName(c.Name) {
+		return fmt.Errorf("contact name %q is not letters, digits, ., - and _ starting with a letter", c.Name)
+	}
+	if !ValidateAddress(c.Address) {
+		return fmt.Errorf("contact address %q is invalid", c.Address)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	wallets, err := s.load()
+	if err != nil {
+		return err
+	}
+	if address, ok := labeled(wallets, c.Name); ok {
+		return fmt.Errorf("%s already labels the wallet for %s", c.Name, address)
+	}
+	contacts, err := s.readContacts()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range contacts {
+		if contacts[i].Name == c.Name {
+			contacts[i].Address = c.Address
+			replaced = true
+		}
+	}
+	if !replaced {
+		contacts = append(contacts, c)
+	}
+	return s.saveWithContacts(wallets, contacts)
+}
+
+// RemoveContact removes the contact called name.
+func (s *Store) RemoveContact(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	wallets, err := s.load()
+	if err != nil {
+		return err
+	}
+	contacts, err := s.readContacts()
+	if err != nil {
+		return err
+	}
+	for i, c := range contacts {
+		if c.Name == name {
+			return s.saveWithContacts(wallets, append(contacts[:i], contacts[i+1:]...))
+		}
+	}
+	return fmt.Errorf("no contact called %s", name)
+}
+
+// SetLabel labels the wallet for an address, or removes its label if
+// label is empty. A label can't name a contact or label another wallet.
+func (s *Store) SetLabel(address, label string) error {
+	if label != "" && !ValidName(label) {
+		return fmt.Errorf("label %q is not letters, digits, ., - and _ starting with a letter", label)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	wallets, err := s.load()
+	if err != nil {
+		return err
+	}
+	w, ok := wallets[address]
+	if !ok {
+		return fmt.Errorf("no wallet for %s", address)
+	}
+	if label != "" {
+		if other, ok := labeled(wallets, label); ok && other != address {
+			return fmt.Errorf("%s already labels the wallet for %s", label, other)
+		}
+		contacts, err := s.readContacts()
+		if err != nil {
+			return err
+		}
+		for _, c := range contacts {
+			if c.Name == label {
+				return fmt.Errorf("%s already names a contact", label)
+			}
+		}
+	}
+	w.Label = label
+	if err := s.save(wallets); err != nil {
+		return err
+	}
+
+	s.notify(Change{WalletAdded, address})
+	return nil
+}
+
+// Resolve returns the address named by name: name itself if it is an
+// address, the address of the wallet it labels, or the address of the
+// contact it names.
+func (s *Store) Resolve(name string) (string, error) {
+	if ValidateAddress(name) {
+		return name, nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	wallets, err := s.read()
+	if err == errLegacyFormat {
+		wallets, err = nil, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if address, ok := labeled(wallets, name); ok {
+		return address, nil
+	}
+	contacts, err := s.readContacts()
+	if err != nil {
+		return "", err
+	}
+	for _, c := range contacts {
+		if c.Name == name {
+			return c.Address, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not an address, the label of a wallet or a contact", name)
+}
+
+// labeled returns the address of the wallet labeled label in wallets.
+func labeled(wallets map[string]*Wallet, label string) (string, bool) {
+	if label == "" {
+		return "", false
+	}
+	for address, w := range wallets {
+		if w.Label == label {
+			return address, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,63 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestContactsAndLabels(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "wallets.dat"))
+	own, err := store.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := own.Address().String()
+	bob := CreateWallet().Address().String()
+
+	if err := store.AddContact(Contact{Name: "bob", Address: bob}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetLabel(address, "savings"); err != nil {
+		t.Fatal(err)
+	}
+
+	// names, labels and addresses resolve to addresses
+	for name, want := range map[string]string{"bob": bob, "savings": address, bob: bob} {
+		if got, err := store.Resolve(name); err != nil || got != want {
+			t.Errorf("%s resolved to %s, %v, want %s", name, got, err, want)
+		}
+	}
+	if _, err := store.Resolve("carol"); err == nil {
+		t.Error("resolved an unknown name")
+	}
+
+	// a name can't label a wallet and name a contact, or be an address
+	if err := store.SetLabel(address, "bob"); err == nil {
+		t.Error("labeled a wallet with the name of a contact")
+	}
+	if err := store.AddContact(Contact{Name: "savings", Address: bob}); err == nil {
+		t.Error("added a contact with the label of a wallet")
+	}
+	if err := store.AddContact(Contact{Name: bob, Address: bob}); err == nil {
+		t.Error("added a contact named by an address")
+	}
+
+	// contacts are kept when wallets change, and labels are stored
+	if _, err := store.Create(); err != nil {
+		t.Fatal(err)
+	}
+	contacts, err := NewStore(store.path).Contacts()
+	if err != nil || len(contacts) != 1 || contacts[0] != (Contact{"bob", bob}) {
+		t.Fatalf("got contacts %v, %v", contacts, err)
+	}
+	if w, err := NewStore(store.path).Get(address); err != nil || w.Label != "savings" {
+		t.Fatalf("got wallet %v, %v, want the savings label", w, err)
+	}
+
+	if err := store.RemoveContact("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RemoveContact("bob"); err == nil {
+		t.Fatal("removed a contact twice")
+	}
+}
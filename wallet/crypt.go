@@ -0,0 +1,134 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ErrLocked is returned when reading or writing an encrypted wallets file
+// without its key.
+var ErrLocked = errors.New("wallets file is encrypted and locked")
+
+// ErrWrongPassphrase is returned when unlocking a wallets file with the
+// wrong passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase for wallets file")
+
+// encryptedMagic starts encrypted wallets files, followed by the JSON
+// encoding of an encryptedFile.
+var encryptedMagic = []byte("gochain encrypted wallets\n")
+
+// keyIterations is the number of PBKDF2 iterations used to derive keys from
+// passphrases, which makes guessing passphrases slow.
+const keyIterations = 100000
+
+// encryptedFile is an encrypted wallets file. Data is the plain wallets
+// file sealed with AES-GCM under a key derived from a passphrase and
+// Salt.
+type encryptedFile struct {
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// Key decrypts a wallets file. It is derived from the passphrase of the
+// file, so it can be kept in place of the passphrase while the file is
+// unlocked.
+type Key struct {
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Secret     []byte `json:"secret"`
+}
+
+// deriveKey derives the key for passphrase and salt.
+func deriveKey(passphrase string, salt []byte, iterations int) *Key {
+	return &Key{
+		Iterations: iterations,
+		Salt:       salt,
+		Secret:     pbkdf2.Key([]byte(passphrase), salt, iterations, 32, sha256.New),
+	}
+}
+
+// Wipe overwrites the secret of the key so it does not stay in memory.
+func (k *Key) Wipe() {
+	for i := range k.Secret {
+		k.Secret[i] = 0
+	}
+	k.Secret = nil
+}
+
+// isEncrypted returns whether the contents of a wallets file are encrypted.
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// aead returns the AES-GCM cipher of the key.
+func (k *Key) aead() (cipher.AEAD, error) {
+	if len(k.Secret) == 0 {
+		return nil, ErrLocked
+	}
+	block, err := aes.NewCipher(k.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plain contents of a wallets file with the key.
+func (k *Key) seal(plain []byte) ([]byte, error) {
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.New("unable to generate nonce - " + err.Error())
+	}
+
+	data, err := json.Marshal(encryptedFile{
+		Iterations: k.Iterations,
+		Salt:       k.Salt,
+		Nonce:      nonce,
+		Data:       aead.Seal(nil, nonce, plain, encryptedMagic),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, encryptedMagic...), data...), nil
+}
+
+// decodeEncrypted decodes the header of an encrypted wallets file.
+func decodeEncrypted(data []byte) (*encryptedFile, error) {
+	var file encryptedFile
+	if err := json.Unmarshal(data[len(encryptedMagic):], &file); err != nil {
+		return nil, errors.New("unable to decode encrypted wallets file - " + err.Error())
+	}
+	return &file, nil
+}
+
+// open decrypts the contents of an encrypted wallets file with the key.
+func (k *Key) open(data []byte) ([]byte, error) {
+	file, err := decodeEncrypted(data)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(file.Salt, k.Salt) || file.Iterations != k.Iterations || len(file.Nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	plain, err := aead.Open(nil, file.Nonce, file.Data, encryptedMagic)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
@@ -0,0 +1,69 @@
+package wallet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.dat")
+	store := NewStore(path)
+	if err := store.Save(map[string]*Wallet{}); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Encrypt("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if encrypted, err := store.Encrypted(); err != nil || !encrypted {
+		t.Fatalf("got %v, %v, want the file to be encrypted", encrypted, err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, plain) {
+		t.Fatal("encrypted file holds the plain wallets")
+	}
+
+	// without the key the file can't be read, or replaced by a plain file
+	locked := NewStore(path)
+	if _, err := locked.Wallets(); err != ErrLocked {
+		t.Fatalf("got error %v, want ErrLocked", err)
+	}
+	if err := locked.Save(map[string]*Wallet{}); err != ErrLocked {
+		t.Fatalf("got error %v, want ErrLocked", err)
+	}
+	if _, err := locked.Unlock("wrong"); err != ErrWrongPassphrase {
+		t.Fatalf("got error %v, want ErrWrongPassphrase", err)
+	}
+
+	// the key from unlocking reads and writes the file in another store
+	key, err := locked.Unlock("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := NewStore(path)
+	cached.SetKey(&Key{Iterations: key.Iterations, Salt: key.Salt, Secret: append([]byte{}, key.Secret...)})
+	if err := cached.Save(map[string]*Wallet{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locked.Wallets(); err != nil {
+		t.Fatal(err)
+	}
+
+	// locking wipes the key
+	locked.Lock()
+	if key.Secret != nil {
+		t.Fatal("key was not wiped")
+	}
+	if _, err := locked.Wallets(); err != ErrLocked {
+		t.Fatalf("got error %v, want ErrLocked", err)
+	}
+}
@@ -0,0 +1,146 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Descriptor types.
+const (
+	// DescriptorPKH describes outputs locked to the hash of a public key
+	DescriptorPKH = "pkh"
+
+	// DescriptorAddr describes outputs locked to an address whose public
+	// key is not known
+	DescriptorAddr = "addr"
+
+	// DescriptorMulti describes outputs any Threshold of Keys can spend,
+	// which the chain can't lock outputs to yet
+	DescriptorMulti = "multi"
+)
+
+// Descriptor describes the outputs a wallet recognizes and how they are
+// spent, written as pkh(KEY), addr(ADDRESS) or multi(K, KEY1, KEY2, ...)
+// with public keys in hex. It lets watch-only wallets be exported and
+// imported without a flag for each kind of output.
+type Descriptor struct {
+	Type      string
+	Threshold int
+	Keys      [][]byte
+	Address   string
+}
+
+// ParseDescriptor parses a descriptor, verifying the checksum after a #
+// if it has one.
+func ParseDescriptor(s string) (*Descriptor, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, "#"); i >= 0 {
+		if descriptorChecksum(s[:i]) != s[i+1:] {
+			return nil, errors.New("descriptor checksum does not match")
+		}
+		s = s[:i]
+	}
+
+	open := strings.Index(s, "(")
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("descriptor %q is not TYPE(ARGS)", s)
+	}
+	args := strings.Split(s[open+1:len(s)-1], ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+
+	d := &Descriptor{Type: s[:open]}
+	switch d.Type {
+	case DescriptorPKH:
+		if len(args) != 1 {
+			return nil, errors.New("pkh descriptor needs one key")
+		}
+		key, err := parseDescriptorKey(args[0])
+		if err != nil {
+			return nil, err
+		}
+		d.Keys = [][]byte{key}
+	case DescriptorAddr:
+		if len(args) != 1 || !ValidateAddress(args[0]) {
+			return nil, errors.New("addr descriptor needs one valid address")
+		}
+		d.Address = args[0]
+	case DescriptorMulti:
+		threshold, err := strconv.Atoi(args[0])
+		if err != nil || threshold < 1 || threshold > len(args)-1 {
+			return nil, errors.New("multi descriptor needs a threshold from 1 to its number of keys")
+		}
+		d.Threshold = threshold
+		for _, arg := range args[1:] {
+			key, err := parseDescriptorKey(arg)
+			if err != nil {
+				return nil, err
+			}
+			d.Keys = append(d.Keys, key)
+		}
+	default:
+		return nil, fmt.Errorf("descriptor type %q is not supported", d.Type)
+	}
+	return d, nil
+}
+
+// parseDescriptorKey decodes a public key in hex, ensuring it is a point
+// on the curve.
+func parseDescriptorKey(arg string) ([]byte, error) {
+	key, err := hex.DecodeString(arg)
+	if err != nil {
+		return nil, fmt.Errorf("descriptor key %q is not hex", arg)
+	}
+	if _, err := NewWatchOnlyPublicKey(key); err != nil {
+		return nil, fmt.Errorf("descriptor key %q is invalid - %s", arg, err.Error())
+	}
+	return key, nil
+}
+
+// String returns the descriptor with its checksum.
+func (d *Descriptor) String() string {
+	var args []string
+	switch d.Type {
+	case DescriptorAddr:
+		args = []string{d.Address}
+	case DescriptorMulti:
+		args = []string{strconv.Itoa(d.Threshold)}
+	}
+	for _, key := range d.Keys {
+		args = append(args, hex.EncodeToString(key))
+	}
+
+	s := d.Type + "(" + strings.Join(args, ",") + ")"
+	return s + "#" + descriptorChecksum(s)
+}
+
+// descriptorChecksum returns the checksum of a descriptor, which catches
+// descriptors that were copied wrong.
+func descriptorChecksum(s string) string {
+	return hex.EncodeToString(GenerateChecksum([]byte(s)))
+}
+
+// Wallet returns a watch-only wallet recognizing the outputs of the
+// descriptor.
+func (d *Descriptor) Wallet() (*Wallet, error) {
+	switch d.Type {
+	case DescriptorPKH:
+		return NewWatchOnlyPublicKey(d.Keys[0])
+	case DescriptorAddr:
+		return NewWatchOnly(d.Address)
+	default:
+		return nil, fmt.Errorf("%s descriptors can not be watched yet", d.Type)
+	}
+}
+
+// Descriptor returns the descriptor of the outputs the wallet recognizes.
+func (w *Wallet) Descriptor() *Descriptor {
+	if len(w.PublicKey) == 0 {
+		return &Descriptor{Type: DescriptorAddr, Address: w.Address().String()}
+	}
+	return &Descriptor{Type: DescriptorPKH, Keys: [][]byte{w.PublicKey}}
+}
@@ -0,0 +1,65 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestDescriptorRoundTrip(t *testing.T) {
+	alice := NewFromSeed([]byte("alice"))
+	bob := NewFromSeed([]byte("bob"))
+	watched, err := NewWatchOnly(bob.Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, w := range []*Wallet{alice, watched} {
+		d, err := ParseDescriptor(w.Descriptor().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		imported, err := d.Wallet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !imported.WatchOnly() || !imported.Address().Equal(w.Address()) {
+			t.Errorf("got wallet for %s, want watch-only wallet for %s", imported.Address(), w.Address())
+		}
+	}
+
+	// multi descriptors parse but can't be watched until the chain has
+	// outputs for them
+	multi := "multi(2," + hex.EncodeToString(alice.PublicKey) + "," + hex.EncodeToString(bob.PublicKey) + ")"
+	d, err := ParseDescriptor(multi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Threshold != 2 || len(d.Keys) != 2 || !strings.HasPrefix(d.String(), multi+"#") {
+		t.Fatalf("got %s, want %s", d, multi)
+	}
+	if _, err := d.Wallet(); err == nil {
+		t.Fatal("multi descriptor was watched")
+	}
+}
+
+func TestParseDescriptorErrors(t *testing.T) {
+	key := hex.EncodeToString(NewFromSeed([]byte("alice")).PublicKey)
+	for _, c := range []struct {
+		descriptor string
+		err        string
+	}{
+		{"pkh(" + key + ")#00000000", "checksum"},
+		{"pkh" + key, "not TYPE(ARGS)"},
+		{"sh(" + key + ")", "not supported"},
+		{"pkh(" + key + "," + key + ")", "one key"},
+		{"pkh(zz)", "not hex"},
+		{"pkh(" + key[2:] + ")", "invalid"},
+		{"addr(1nope)", "valid address"},
+		{"multi(3," + key + "," + key + ")", "threshold"},
+	} {
+		if _, err := ParseDescriptor(c.descriptor); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: got error %v, want %q", c.descriptor, err, c.err)
+		}
+	}
+}
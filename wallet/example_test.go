@@ -0,0 +1,18 @@
+package wallet_test
+
+import (
+	"fmt"
+
+	"github.com/edwintcloud/gochain/wallet"
+)
+
+// This example derives a wallet from a seed, which always gives the same
+// keys, and prints its address on the network in use.
+func ExampleWallet_Address() {
+	w := wallet.NewFromSeed([]byte("example seed"))
+	fmt.Println(w.Address())
+	fmt.Println(wallet.ValidateAddress(w.Address().String()))
+	// Output:
+	// 1DbR2oV39FyYnxU67HFggQmnzVcm4DFcA8
+	// true
+}
@@ -0,0 +1,192 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/edwintcloud/gochain/addresses"
+	"github.com/edwintcloud/gochain/keys"
+)
+
+// fileVersion is the version of the JSON wallets file format.
+const fileVersion = 1
+
+// walletsFile is the JSON encoding of a wallets file.
+type walletsFile struct {
+	Version  int           `json:"version"`
+	Wallets  []walletEntry `json:"wallets"`
+	Contacts []Contact     `json:"contacts,omitempty"`
+}
+
+// walletEntry is the JSON encoding of a wallet. Watch-only wallets have no
+// private key, and only have a public key hash if they were imported from
+// an address.
+type walletEntry struct {
+	Address    string `json:"address"`
+	PrivateKey string `json:"privateKey,omitempty"`
+	PublicKey  string `json:"publicKey,omitempty"`
+	PubKeyHash string `json:"pubKeyHash,omitempty"`
+	CreatedAt  int64  `json:"createdAt,omitempty"`
+	Label      string `json:"label,omitempty"`
+
+	// Scheme is the name of the signature scheme of the keys, which is
+	// p256 if it is empty
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// encodeWallets encodes wallets in the JSON wallets file format, ordered by
+// address, along with contacts.
+func encodeWallets(wallets map[string]*Wallet, contacts []Contact) ([]byte, error) {
+	file := walletsFile{Version: fileVersion, Wallets: []walletEntry{}, Contacts: contacts}
+	for address, w := range wallets {
+		entry := walletEntry{
+			Address:    address,
+			PublicKey:  hex.EncodeToString(w.PublicKey),
+			PubKeyHash: hex.EncodeToString(w.PubKeyHash),
+			CreatedAt:  w.CreatedAt,
+			Label:      w.Label,
+		}
+		if !w.WatchOnly() {
+			entry.PrivateKey = hex.EncodeToString(w.privateKeyBytes())
+		}
+		if scheme := w.Scheme(); scheme != keys.P256 {
+			entry.Scheme = scheme.Name()
+		}
+		file.Wallets = append(file.Wallets, entry)
+	}
+	sort.Slice(file.Wallets, func(i, j int) bool {
+		return file.Wallets[i].Address < file.Wallets[j].Address
+	})
+
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// decodeWallets decodes a wallets file in the JSON format.
+func decodeWallets(data []byte) (map[string]*Wallet, error) {
+	var file walletsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Version != fileVersion {
+		return nil, fmt.Errorf("version %d is not supported", file.Version)
+	}
+
+	return walletsFromEntries(file.Wallets)
+}
+
+// decodeContacts decodes the contacts of a wallets file in the JSON format.
+func decodeContacts(data []byte) ([]Contact, error) {
+	var file walletsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return file.Contacts, nil
+}
+
+// walletsFromEntries rebuilds the wallets of entries, checking that each
+// wallet has the address it is stored under.
+func walletsFromEntries(entries []walletEntry) (map[string]*Wallet, error) {
+	wallets := make(map[string]*Wallet)
+	for _, entry := range entries {
+		w, err := entry.wallet()
+		if err != nil {
+			return nil, fmt.Errorf("wallet for %s is invalid - %s", entry.Address, err.Error())
+		}
+		if w.Address().String() != entry.Address {
+
+			// addresses encoded with another checksum length don't decode
+			if _, err := addresses.Decode(entry.Address); err != nil {
+				return nil, fmt.Errorf("address %s does not decode with a checksum length of %d bytes, the wallets file may have been made with another checksum length - %s", entry.Address, addresses.ChecksumLength, err.Error())
+			}
+			return nil, fmt.Errorf("wallet for %s has the keys of %s", entry.Address, w.Address())
+		}
+		wallets[entry.Address] = w
+	}
+	return wallets, nil
+}
+
+// wallet rebuilds the wallet of an entry from its keys.
+func (entry walletEntry) wallet() (*Wallet, error) {
+	var w *Wallet
+	scheme, err := keys.SchemeByName(entry.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case entry.PrivateKey != "":
+		privKey, err := hex.DecodeString(entry.PrivateKey)
+		if err != nil || len(privKey) != privKeyLen || !validPrivateKey(scheme.Curve(), privKey) {
+			return nil, errors.New("private key is invalid")
+		}
+		w = fromPrivateKey(scheme.Curve(), privKey)
+	case entry.PublicKey != "":
+		pubKey, err := hex.DecodeString(entry.PublicKey)
+		if err != nil {
+			return nil, errors.New("public key is invalid")
+		}
+		if w, err = newWatchOnlyPublicKey(scheme, pubKey); err != nil {
+			return nil, err
+		}
+	default:
+		if w, err = NewWatchOnly(entry.Address); err != nil {
+			return nil, err
+		}
+		w.scheme = scheme
+	}
+
+	w.CreatedAt = entry.CreatedAt
+	w.Label = entry.Label
+	return w, nil
+}
+
+// isJSON returns whether the contents of a plain wallets file are in the
+// JSON format rather than the gob format of older versions.
+func isJSON(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{'
+}
+
+// legacyWallet is a wallet in the gob wallets files of older versions. The
+// curve of the private key is left out, as it was encoded as a type the
+// elliptic package no longer has, and every key is on P-256.
+type legacyWallet struct {
+	PrivateKey struct {
+		D *big.Int
+	}
+	PublicKey  []byte
+	PubKeyHash []byte
+}
+
+// decodeLegacyWallets decodes a gob wallets file of an older version.
+func decodeLegacyWallets(data []byte) (map[string]*Wallet, error) {
+	var legacy map[string]*legacyWallet
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&legacy); err != nil {
+		return nil, err
+	}
+
+	entries := make([]walletEntry, 0, len(legacy))
+	for address, l := range legacy {
+		entry := walletEntry{
+			Address:    address,
+			PublicKey:  hex.EncodeToString(l.PublicKey),
+			PubKeyHash: hex.EncodeToString(l.PubKeyHash),
+		}
+		if d := l.PrivateKey.D; d != nil {
+			if len(d.Bytes()) > privKeyLen {
+				return nil, fmt.Errorf("wallet for %s has an invalid private key", address)
+			}
+			w := &Wallet{PrivateKey: ecdsa.PrivateKey{D: d}}
+			entry.PrivateKey = hex.EncodeToString(w.privateKeyBytes())
+		}
+		entries = append(entries, entry)
+	}
+	return walletsFromEntries(entries)
+}
@@ -0,0 +1,186 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/gob"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edwintcloud/gochain/addresses"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.dat")
+	store := NewStore(path)
+	alice := NewFromSeed([]byte("alice"))
+	alice.Label = "savings"
+	bob, err := NewWatchOnly(NewFromSeed([]byte("bob")).Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []*Wallet{alice, bob} {
+		if err := store.Add(w); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"version": 1`)) || !bytes.Contains(data, []byte(`"label": "savings"`)) {
+		t.Fatalf("wallets file is not versioned JSON with labels:\n%s", data)
+	}
+
+	wallets, err := store.Wallets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := wallets[alice.Address().String()]
+	if loaded == nil || loaded.WIF() != alice.WIF() || loaded.Label != "savings" || loaded.CreatedAt == 0 {
+		t.Fatalf("got %+v, want alice with her label and creation time", loaded)
+	}
+	if watched := wallets[bob.Address().String()]; watched == nil || !watched.WatchOnly() {
+		t.Fatalf("got %+v, want watch-only bob", watched)
+	}
+}
+
+func TestDecodeWalletsRejectsInvalidFiles(t *testing.T) {
+	alice := NewFromSeed([]byte("alice"))
+	bob := NewFromSeed([]byte("bob"))
+	data, err := encodeWallets(map[string]*Wallet{bob.Address().String(): alice}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		data string
+		err  string
+	}{
+		{`{"version": 2, "wallets": []}`, "not supported"},
+		{`{"version": 1, "wallets": [{"address": "x", "privateKey": "00"}]}`, "private key is invalid"},
+		{string(data), "has the keys of"},
+	} {
+		if _, err := decodeWallets([]byte(c.data)); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: got error %v, want %q", c.data, err, c.err)
+		}
+	}
+
+	// files made with another checksum length say so
+	defer func(length int) { addresses.ChecksumLength = length }(addresses.ChecksumLength)
+	if data, err = encodeWallets(map[string]*Wallet{alice.Address().String(): alice}, nil); err != nil {
+		t.Fatal(err)
+	}
+	addresses.ChecksumLength = 2
+	if _, err := decodeWallets(data); err == nil || !strings.Contains(err.Error(), "another checksum length") {
+		t.Errorf("got error %v decoding with another checksum length", err)
+	}
+}
+
+// gobCurve stands in for the curve of the keys in gob wallets files of
+// older versions, which was encoded under the name of the P-256 type.
+type gobCurve struct {
+	*elliptic.CurveParams
+}
+
+// gobWallet is a wallet as older versions encoded it.
+type gobWallet struct {
+	PrivateKey struct {
+		PublicKey struct {
+			Curve elliptic.Curve
+			X, Y  *big.Int
+		}
+		D *big.Int
+	}
+	PublicKey []byte
+}
+
+// writeGobWallets writes w to a wallets file at path in the gob format of
+// older versions.
+func writeGobWallets(t *testing.T, path string, w *Wallet) []byte {
+	gob.RegisterName("crypto/elliptic.p256Curve", gobCurve{})
+	var old gobWallet
+	old.PrivateKey.PublicKey.Curve = gobCurve{elliptic.P256().Params()}
+	old.PrivateKey.PublicKey.X = w.PrivateKey.X
+	old.PrivateKey.PublicKey.Y = w.PrivateKey.Y
+	old.PrivateKey.D = w.PrivateKey.D
+	old.PublicKey = w.PublicKey
+
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(map[string]*gobWallet{w.Address().String(): &old}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, buffer.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestMigrateGobWallets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.dat")
+	alice := NewFromSeed([]byte("alice"))
+	original := writeGobWallets(t, path, alice)
+
+	loaded, err := NewStore(path).Get(alice.Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.WIF() != alice.WIF() {
+		t.Fatal("migrated wallet has a different key")
+	}
+
+	// the file is rewritten as JSON and the original kept
+	if data := mustRead(t, path); !isJSON(data) {
+		t.Fatalf("wallets file was not migrated:\n%x", data)
+	}
+	backup, err := ioutil.ReadFile(path + ".gob.bak")
+	if err != nil || !bytes.Equal(backup, original) {
+		t.Fatalf("got backup %x, %v, want the original file", backup, err)
+	}
+}
+
+func TestMigrateEncryptedGobWallets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.dat")
+	alice := NewFromSeed([]byte("alice"))
+	plain := writeGobWallets(t, path, alice)
+
+	// encrypt the gob file as older versions did
+	key := deriveKey("correct horse", []byte("salt"), 1)
+	sealed, err := key.seal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, sealed, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(path)
+	if _, err := store.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(alice.Address().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the migrated file stays encrypted
+	if encrypted, err := store.Encrypted(); err != nil || !encrypted {
+		t.Fatalf("got %v, %v, want the migrated file to be encrypted", encrypted, err)
+	}
+	opened, err := store.key.open(mustRead(t, path))
+	if err != nil || !isJSON(opened) {
+		t.Fatalf("got %v, want the encrypted file to hold JSON", err)
+	}
+}
+
+// mustRead returns the contents of the file at path.
+func mustRead(t *testing.T, path string) []byte {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,7 @@
+package wallet
+
+import "github.com/edwintcloud/gochain/keys"
+
+// DefaultScheme is the scheme of the wallets created, which is that of the
+// network in use.
+var DefaultScheme = keys.P256
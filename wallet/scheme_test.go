@@ -0,0 +1,23 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/edwintcloud/gochain/keys"
+)
+
+func TestSchemeWalletsFile(t *testing.T) {
+	w := NewFromSeedWithScheme(keys.Secp256k1, []byte("alice"))
+	data, err := encodeWallets(map[string]*Wallet{w.Address().String(): w}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallets, err := decodeWallets(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := wallets[w.Address().String()]
+	if loaded == nil || loaded.Scheme() != keys.Secp256k1 || loaded.PrivateKey.D.Cmp(w.PrivateKey.D) != 0 {
+		t.Fatalf("got %+v, want the secp256k1 wallet", loaded)
+	}
+}
@@ -0,0 +1,35 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/edwintcloud/gochain/keys"
+)
+
+// NewFromSeed creates a Wallet whose key is derived from seed, so the same
+// seed always gives the same address. It is meant for tests, examples and
+// simulations; seeds must not be guessable for wallets that hold real coins.
+// The key is on the curve of the default scheme.
+func NewFromSeed(seed []byte) *Wallet {
+	return NewFromSeedWithScheme(DefaultScheme, seed)
+}
+
+// NewFromSeedWithScheme creates a Wallet like NewFromSeed with a key on the
+// curve of scheme.
+func NewFromSeedWithScheme(scheme keys.Scheme, seed []byte) *Wallet {
+	curve := scheme.Curve()
+	n := curve.Params().N
+	var counter byte
+
+	// hash the seed with a counter until the hash is a valid private key,
+	// which is a number from 1 to n-1
+	for {
+		hash := sha256.Sum256(append(append([]byte{}, seed...), counter))
+		d := new(big.Int).SetBytes(hash[:])
+		if d.Sign() > 0 && d.Cmp(n) < 0 {
+			return fromPrivateKey(curve, hash[:])
+		}
+		counter++
+	}
+}
@@ -0,0 +1,44 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"testing"
+
+	"github.com/edwintcloud/gochain/addresses"
+	"github.com/edwintcloud/gochain/keys"
+)
+
+func TestNewFromSeed(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		a, b []byte
+		same bool
+	}{
+		{"same seed", []byte("alice"), []byte("alice"), true},
+		{"different seeds", []byte("alice"), []byte("bob"), false},
+		{"empty seed", nil, []byte{}, true},
+	} {
+		a, b := NewFromSeed(c.a), NewFromSeed(c.b)
+		if got := a.Address().Equal(b.Address()); got != c.same {
+			t.Errorf("%s: got same address %v, want %v", c.name, got, c.same)
+		}
+		if !elliptic.P256().IsOnCurve(a.PrivateKey.X, a.PrivateKey.Y) {
+			t.Errorf("%s: public key is not on the curve", c.name)
+		}
+		if !ValidateAddress(a.Address().String()) {
+			t.Errorf("%s: address %s is not valid", c.name, a.Address())
+		}
+	}
+}
+
+func TestValidateAddressVersion(t *testing.T) {
+	w := NewFromSeed([]byte("alice"))
+	main := w.Address().String()
+
+	// addresses of another network are not valid
+	defer func(version byte) { keys.AddressVersion = version }(keys.AddressVersion)
+	keys.AddressVersion = addresses.TestNet
+	if ValidateAddress(main) || !ValidateAddress(w.Address().String()) {
+		t.Fatal("address validation does not follow the address version")
+	}
+}
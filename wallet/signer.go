@@ -0,0 +1,35 @@
+package wallet
+
+import "github.com/edwintcloud/gochain/keys"
+
+// Signer signs transaction hashes with a private key it holds. A Wallet
+// signs with its own private key, or with the Signer set by SetSigner.
+type Signer = keys.Signer
+
+// Sign signs hash with the signer of the wallet if it has one, or with its
+// private key otherwise.
+func (w *Wallet) Sign(hash []byte) ([]byte, []byte, error) {
+	if w.signer != nil {
+		return w.signer.Sign(hash)
+	}
+	if w.WatchOnly() {
+		return nil, nil, ErrWatchOnly
+	}
+	signature, err := w.Scheme().Sign(&w.PrivateKey, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signature, w.PublicKey, nil
+}
+
+// SetSigner makes the wallet sign with s, such as a watch-only wallet for
+// the public key of a hardware wallet. The signer is not saved in the
+// wallets file.
+func (w *Wallet) SetSigner(s Signer) {
+	w.signer = s
+}
+
+// CanSign returns whether the wallet has a private key or a signer.
+func (w *Wallet) CanSign() bool {
+	return w.signer != nil || !w.WatchOnly()
+}
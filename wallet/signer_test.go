@@ -0,0 +1,33 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/edwintcloud/gochain/keys"
+)
+
+func TestWalletSign(t *testing.T) {
+	w := NewFromSeed([]byte("alice"))
+	hash := sha256.Sum256([]byte("transaction"))
+
+	signature, pubKey, err := w.Sign(hash[:])
+	if err != nil || !bytes.Equal(pubKey, w.PublicKey) || !w.Scheme().Verify(pubKey, hash[:], signature) {
+		t.Fatalf("got %x, %x, %v, want a signature by the wallet key", signature, pubKey, err)
+	}
+
+	// watch-only wallets sign with their signer
+	watched, err := NewWatchOnlyPublicKey(w.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := watched.Sign(hash[:]); err != ErrWatchOnly || watched.CanSign() {
+		t.Fatalf("got %v, want watch-only wallets to be unable to sign", err)
+	}
+	watched.SetSigner(w)
+	signature, pubKey, err = watched.Sign(hash[:])
+	if err != nil || !watched.CanSign() || !bytes.Equal(pubKey, w.PublicKey) || !keys.P256.Verify(pubKey, hash[:], signature) {
+		t.Fatalf("got %x, %x, %v, want a signature by the signer", signature, pubKey, err)
+	}
+}
@@ -1,28 +1,93 @@
 package wallet
 
 import (
-	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/sha256"
+	"errors"
 	"log"
-	"os"
-	"strconv"
+	"math/big"
 
-	"github.com/btcsuite/btcutil/base58"
-	"golang.org/x/crypto/ripemd160"
+	"github.com/edwintcloud/gochain/addresses"
+	"github.com/edwintcloud/gochain/keys"
 )
 
-const (
-	version = byte(0x00)
-)
-
-// Wallet represents a token wallet for an address.
+// Wallet represents a token wallet for an address. A watch-only wallet has
+// no private key, so it can be used to follow the balance and history of
+// an address but not to sign.
 type Wallet struct {
 	// eliptical curve digital signing algorithm private key
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
+
+	// PubKeyHash is the public key hash of a watch-only wallet imported
+	// from an address, which has no public key
+	PubKeyHash []byte
+
+	// CreatedAt is when the wallet was added to the wallets file in unix
+	// seconds, or 0 if unknown
+	CreatedAt int64
+
+	// Label is an optional name for the wallet given by its user
+	Label string
+
+	// scheme is the signature scheme of a watch-only wallet for a public
+	// key
+	scheme keys.Scheme
+
+	// signer signs for the wallet instead of its private key
+	signer keys.Signer
+}
+
+// ErrWatchOnly is returned when signing with a watch-only wallet without a
+// signer.
+var ErrWatchOnly = keys.ErrWatchOnly
+
+// NewWatchOnly creates a watch-only wallet for an address.
+func NewWatchOnly(address string) (*Wallet, error) {
+	pubKeyHash, err := PublicKeyHashFromAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{PubKeyHash: pubKeyHash}, nil
+}
+
+// NewWatchOnlyPublicKey creates a watch-only wallet for a public key, the
+// concatenated x and y coordinates of a point on the curve of the default
+// scheme.
+func NewWatchOnlyPublicKey(pubKey []byte) (*Wallet, error) {
+	return newWatchOnlyPublicKey(DefaultScheme, pubKey)
+}
+
+// newWatchOnlyPublicKey creates a watch-only wallet for a public key on the
+// curve of scheme.
+func newWatchOnlyPublicKey(scheme keys.Scheme, pubKey []byte) (*Wallet, error) {
+	keyMedian := len(pubKey) / 2
+	x := new(big.Int).SetBytes(pubKey[:keyMedian])
+	y := new(big.Int).SetBytes(pubKey[keyMedian:])
+	if len(pubKey) == 0 || len(pubKey)%2 != 0 || !scheme.Curve().IsOnCurve(x, y) {
+		return nil, errors.New("public key is not a point on the curve")
+	}
+	return &Wallet{PublicKey: append([]byte{}, pubKey...), scheme: scheme}, nil
+}
+
+// Scheme returns the signature scheme of the wallet, which is that of the
+// curve of its private key, or the default scheme for watch-only wallets
+// of a public key hash.
+func (w *Wallet) Scheme() keys.Scheme {
+	if w.PrivateKey.Curve != nil {
+		if scheme := keys.SchemeOf(w.PrivateKey.Curve); scheme != nil {
+			return scheme
+		}
+	}
+	if w.scheme != nil {
+		return w.scheme
+	}
+	return DefaultScheme
+}
+
+// WatchOnly returns whether the wallet has no private key.
+func (w *Wallet) WatchOnly() bool {
+	return w.PrivateKey.D == nil
 }
 
 // CreateWallet creates a new Wallet.
@@ -38,22 +103,38 @@ func CreateWallet() *Wallet {
 	}
 }
 
-// Address returns the generated Wallet address which is a base58 formed
-// from the public key hash, version, and checksum.
-func (w *Wallet) Address() []byte {
+// Address returns the Wallet address on the network in use, whose String
+// is the base58 form of its version, public key hash and checksum.
+func (w *Wallet) Address() addresses.Address {
 
-	// generate public key hash
-	pubHash := GeneratePublicKeyHash(w.PublicKey)
+	// watch-only wallets may only know the public key hash
+	if len(w.PublicKey) == 0 {
+		return addresses.Address{Version: keys.AddressVersion, PubKeyHash: w.PubKeyHash}
+	}
 
-	// concatenate the version to the begining of pubHash
-	vHash := append([]byte{version}, pubHash...)
+	// generate public key hash and return its address
+	return addresses.Address{Version: keys.AddressVersion, PubKeyHash: keys.PublicKeyHash(w.PublicKey)}
+}
 
-	// concatenate the checksum to the end of vHash
-	finalHash := append(vHash, GenerateChecksum(vHash)...)
+// PubKey returns the public key of the Wallet, so it is a keys.Key.
+func (w *Wallet) PubKey() []byte {
+	return w.PublicKey
+}
 
-	// return the byte slice representation of the base58
-	// encoding of finalHash
-	return []byte(base58.Encode(finalHash))
+// Keys returns wallets, keyed by address, as keys to sign transactions
+// with.
+func Keys(wallets map[string]*Wallet) map[string]keys.Key {
+	result := make(map[string]keys.Key, len(wallets))
+	for address, w := range wallets {
+		result[address] = w
+	}
+	return result
+}
+
+// AddressFromPublicKeyHash returns the address for a public key hash on the
+// network in use.
+func AddressFromPublicKeyHash(pubHash []byte) string {
+	return keys.AddressFromPublicKeyHash(pubHash)
 }
 
 // GenerateKeyPair generates a new ecdsa private and public key pair.
@@ -61,8 +142,9 @@ func (w *Wallet) Address() []byte {
 // more than the number of known atoms in the universe O_O
 func GenerateKeyPair() (ecdsa.PrivateKey, []byte) {
 
-	// define curve type as p256 (outputs will be 256 bytes)
-	curve := elliptic.P256()
+	// use the curve of the default scheme, which is p256 unless the
+	// network signs with another
+	curve := DefaultScheme.Curve()
 
 	// generate key using curve and random number generator
 	privKey, err := ecdsa.GenerateKey(curve, rand.Reader)
@@ -79,52 +161,26 @@ func GenerateKeyPair() (ecdsa.PrivateKey, []byte) {
 
 // GeneratePublicKeyHash generates a hash for a public key using sha256 and ripemd160.
 func GeneratePublicKeyHash(pubKey []byte) []byte {
-
-	// hash using sha256
-	pubHash := sha256.Sum256(pubKey)
-
-	// write pubHash into a ripemd160 hash
-	rmdHash := ripemd160.New()
-	_, err := rmdHash.Write(pubHash[:])
-	if err != nil {
-		log.Panicln("Unable to write pubHash into ripemd160 hash: ", err.Error())
-	}
-
-	// generate and return final hash
-	return rmdHash.Sum(nil)
+	return keys.PublicKeyHash(pubKey)
 }
 
 // GenerateChecksum generates a checksum for a public key hash.
 func GenerateChecksum(payload []byte) []byte {
-	checksumLen, err := strconv.Atoi(os.Getenv("CHECKSUM_LENGTH"))
-	if err != nil {
-		log.Panicln("Unable to convert env var CHECKSUM_LENGTH to int for method GenerateChecksum: ", err.Error())
-	}
-
-	// generate a sha256 hash from payload
-	hash := sha256.Sum256(payload)
-
-	// generate a sha256 hash from hash
-	rehash := sha256.Sum256(hash[:])
-
-	// return checksum of checksumLen bytes
-	return rehash[:checksumLen]
+	return addresses.Checksum(payload)
 }
 
-// ValidateAddress validates a wallet address.
+// ValidateAddress validates a wallet address, which must be of the network
+// in use.
 func ValidateAddress(address string) bool {
-	checksumLen, err := strconv.Atoi(os.Getenv("CHECKSUM_LENGTH"))
+	return keys.ValidateAddress(address)
+}
+
+// PublicKeyHashFromAddress decodes an address back into its public key
+// hash, validating its checksum.
+func PublicKeyHashFromAddress(address string) ([]byte, error) {
+	a, err := addresses.Decode(address)
 	if err != nil {
-		log.Panicln("Unable to convert env var CHECKSUM_LENGTH to int for method (TxOutput) Lock: ", err.Error())
+		return nil, err
 	}
-
-	// decode address from base58 back to sha256 hash
-	pubKeyHash := base58.Decode(address)
-
-	actualChecksum := pubKeyHash[len(pubKeyHash)-checksumLen:]
-	version := pubKeyHash[0]
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-checksumLen]
-	targetChecksum := GenerateChecksum(append([]byte{version}, pubKeyHash...))
-
-	return bytes.Compare(actualChecksum, targetChecksum) == 0
+	return a.PubKeyHash, nil
 }
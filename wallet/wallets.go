@@ -2,62 +2,548 @@ package wallet
 
 import (
 	"bytes"
-	"crypto/elliptic"
-	"encoding/gob"
+	"crypto/rand"
+	"errors"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 )
 
-// CreateWallets makes a map of wallets and populates it with
-// data from the wallets file if it exists.
-func CreateWallets() (map[string]*Wallet, error) {
-	wallets := make(map[string]*Wallet)
+// Store is a wallets file holding wallets keyed by address. An encrypted
+// wallets file can only be read and written once the store has its key.
+// A Store is safe for concurrent use, so the RPC server and commands can
+// share one, and reports changes to its wallets to the channels given to
+// Notify.
+type Store struct {
+	path string
 
-	// try to load wallets from file
-	err := LoadWalletsFile(&wallets)
+	// backups is the number of earlier versions of the wallets file kept
+	// next to it
+	backups int
 
-	// return wallets and err
-	return wallets, err
+	// mutex guards the wallets file and key, so a change reads and writes
+	// the file without another change in between
+	mutex sync.RWMutex
+	key   *Key
+
+	watchMutex sync.Mutex
+	watchers   map[chan<- Change]bool
+}
+
+// Change kinds reported to the channels given to Notify.
+const (
+	// WalletAdded is reported when a wallet is added or replaced.
+	WalletAdded = "added"
+
+	// WalletDeleted is reported when a wallet is deleted.
+	WalletDeleted = "deleted"
+
+	// WalletsReplaced is reported when every wallet is replaced by Save.
+	WalletsReplaced = "replaced"
+)
+
+// Change is a change to the wallets of a store. Address is empty when
+// every wallet was replaced.
+type Change struct {
+	Kind    string
+	Address string
+}
+
+// DefaultBackups is the number of earlier versions of the wallets file a
+// new Store keeps, in files named after it ending in .bak.1 for the latest
+// up to .bak.N for the oldest.
+const DefaultBackups = 3
+
+// errLegacyFormat is returned by read for wallets files in the gob format
+// of older versions, which must be migrated under the write lock.
+var errLegacyFormat = errors.New("wallets file is in the legacy format")
+
+// NewStore returns a Store for the wallets file at path. The file is
+// created when wallets are first saved.
+func NewStore(path string) *Store {
+	return &Store{path: path, backups: DefaultBackups, watchers: make(map[chan<- Change]bool)}
+}
+
+// SetBackups sets the number of earlier versions of the wallets file kept
+// when it is written, keeping none if n is 0.
+func (s *Store) SetBackups(n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.backups = n
+}
+
+// BackupPath returns the file holding the nth latest earlier version of
+// the wallets file, counting from 1.
+func (s *Store) BackupPath(n int) string {
+	return fmt.Sprintf("%s.bak.%d", s.path, n)
+}
+
+// Wallets loads the wallets in the store into a map keyed by address. An
+// empty map is returned if the wallets file does not exist yet.
+func (s *Store) Wallets() (map[string]*Wallet, error) {
+	s.mutex.RLock()
+	wallets, err := s.read()
+	s.mutex.RUnlock()
+	if err != errLegacyFormat {
+		return wallets, err
+	}
+
+	// migrate the file under the write lock, loading it again in case
+	// another caller migrated it in between
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.load()
+}
+
+// contents returns the plain contents of the wallets file and its contents
+// as stored, or nil if the file does not exist yet.
+func (s *Store) contents() (plain, original []byte, err error) {
+
+	// try to read file, which is missing until wallets are saved
+	fileBytes, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, errors.New("unable to read wallets file - " + err.Error())
+	}
+
+	// decrypt an encrypted file with the key of the store
+	if !isEncrypted(fileBytes) {
+		return fileBytes, fileBytes, nil
+	}
+	if s.key == nil {
+		return nil, nil, ErrLocked
+	}
+	plain, err = s.key.open(fileBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plain, fileBytes, nil
 }
 
-// LoadWalletsFile loads wallets from a file into a map.
-func LoadWalletsFile(wallets *map[string]*Wallet) error {
+// read decodes the wallets file, returning errLegacyFormat if it must be
+// migrated first. The caller must hold the lock of the store.
+func (s *Store) read() (map[string]*Wallet, error) {
+	plain, _, err := s.contents()
+	if err != nil {
+		return nil, err
+	}
+	if plain == nil {
+		return make(map[string]*Wallet), nil
+	}
+	if !isJSON(plain) {
+		return nil, errLegacyFormat
+	}
 
-	// try to read file or return error
-	fileBytes, err := ioutil.ReadFile(os.Getenv("WALLETS_FILE"))
+	wallets, err := decodeWallets(plain)
+	if err != nil {
+		return nil, errors.New("unable to decode wallets file - " + err.Error())
+	}
+	return wallets, nil
+}
+
+// load decodes the wallets file, migrating files in the gob format of
+// older versions. The caller must hold the write lock of the store.
+func (s *Store) load() (map[string]*Wallet, error) {
+	wallets, err := s.read()
+	if err != errLegacyFormat {
+		return wallets, err
+	}
+	plain, original, err := s.contents()
+	if err != nil {
+		return nil, err
+	}
+	return s.migrate(plain, original)
+}
+
+// migrate decodes the plain contents of a wallets file in the gob format
+// of older versions and rewrites the file in the JSON format, keeping the
+// original file next to it.
+func (s *Store) migrate(plain, original []byte) (map[string]*Wallet, error) {
+	wallets, err := decodeLegacyWallets(plain)
+	if err != nil {
+		return nil, errors.New("unable to decode wallets file - " + err.Error())
+	}
+
+	if err := ioutil.WriteFile(s.path+".gob.bak", original, 0600); err != nil {
+		return nil, errors.New("unable to migrate wallets file - " + err.Error())
+	}
+	if err := s.save(wallets); err != nil {
+		return nil, errors.New("unable to migrate wallets file - " + err.Error())
+	}
+	return wallets, nil
+}
+
+// Get returns the wallet for an address in the store.
+func (s *Store) Get(address string) (*Wallet, error) {
+	wallets, err := s.Wallets()
+	if err != nil {
+		return nil, err
+	}
+
+	w, ok := wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("no wallet for %s", address)
+	}
+	return w, nil
+}
+
+// List returns the addresses of the wallets in the store in order.
+func (s *Store) List() ([]string, error) {
+	wallets, err := s.Wallets()
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, 0, len(wallets))
+	for address := range wallets {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// Create creates a new wallet and adds it to the store.
+func (s *Store) Create() (*Wallet, error) {
+	w := CreateWallet()
+	if err := s.Add(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Add adds a wallet to the store, replacing the wallet for its address.
+func (s *Store) Add(w *Wallet) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	wallets, err := s.load()
 	if err != nil {
 		return err
 	}
+	if w.CreatedAt == 0 {
+		w.CreatedAt = time.Now().Unix()
+	}
+	address := w.Address().String()
+	wallets[address] = w
+	if err := s.save(wallets); err != nil {
+		return err
+	}
+
+	s.notify(Change{WalletAdded, address})
+	return nil
+}
 
-	// register gob encoder to read file format and create a
-	// new decoder
-	gob.Register(elliptic.P256())
-	gobDecoder := gob.NewDecoder(bytes.NewReader(fileBytes))
+// Delete removes the wallet for an address from the store.
+func (s *Store) Delete(address string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	// attempt to decode file into wallets or return err
-	return gobDecoder.Decode(wallets)
+	wallets, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := wallets[address]; !ok {
+		return fmt.Errorf("no wallet for %s", address)
+	}
+	delete(wallets, address)
+	if err := s.save(wallets); err != nil {
+		return err
+	}
 
+	s.notify(Change{WalletDeleted, address})
+	return nil
 }
 
-// SaveWalletsFile saves wallets to a file as bytes to the
-// specified wallets file.
-func SaveWalletsFile(wallets *map[string]*Wallet) {
-	var buffer bytes.Buffer
+// Save writes wallets to the store, replacing the wallets in the file.
+func (s *Store) Save(wallets map[string]*Wallet) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.save(wallets); err != nil {
+		return err
+	}
 
-	// register gob encoder and create a new encoder
-	gob.Register(elliptic.P256())
-	gobEncoder := gob.NewEncoder(&buffer)
+	s.notify(Change{Kind: WalletsReplaced})
+	return nil
+}
 
-	// attempt to encode wallets into bytes
-	err := gobEncoder.Encode(wallets)
+// save writes wallets to the wallets file, keeping its contacts. The
+// caller must hold the write lock of the store.
+func (s *Store) save(wallets map[string]*Wallet) error {
+	contacts, err := s.readContacts()
 	if err != nil {
-		log.Panicln("Unable to encode wallets using gob encoder: ", err.Error())
+		return err
 	}
+	return s.saveWithContacts(wallets, contacts)
+}
 
-	// write the bytes from the buffer into the specified file
-	err = ioutil.WriteFile(os.Getenv("WALLETS_FILE"), buffer.Bytes(), 0644)
+// saveWithContacts writes wallets and contacts to the wallets file. The
+// caller must hold the write lock of the store.
+func (s *Store) saveWithContacts(wallets map[string]*Wallet, contacts []Contact) error {
+	data, err := encodeWallets(wallets, contacts)
 	if err != nil {
-		log.Panicln("Unable to write wallets bytes buffer to a file: ", err.Error())
+		return errors.New("unable to encode wallets - " + err.Error())
+	}
+	return s.write(data)
+}
+
+// Notify reports the changes to the wallets of the store to c. Changes are
+// dropped rather than blocking the store when c is not ready, so c should
+// be buffered.
+func (s *Store) Notify(c chan<- Change) {
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+	s.watchers[c] = true
+}
+
+// Stop stops reporting changes to c.
+func (s *Store) Stop(c chan<- Change) {
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+	delete(s.watchers, c)
+}
+
+// notify reports a change to the channels given to Notify.
+func (s *Store) notify(change Change) {
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+
+	for c := range s.watchers {
+		select {
+		case c <- change:
+		default:
+		}
+	}
+}
+
+// write writes the contents of the wallets file, encrypting them if the
+// store has a key, after checking they decode to the wallets written and
+// keeping the file they replace as the latest backup. An encrypted file is
+// never replaced by a plain one. The caller must hold the write lock of the
+// store.
+func (s *Store) write(data []byte) error {
+	plain := data
+	if s.key != nil {
+		sealed, err := s.key.seal(data)
+		if err != nil {
+			return errors.New("unable to encrypt wallets - " + err.Error())
+		}
+		data = sealed
+	} else if encrypted, err := s.encrypted(); err != nil {
+		return err
+	} else if encrypted {
+		return ErrLocked
+	}
+	if err := s.verify(data, plain); err != nil {
+		return errors.New("unable to verify wallets file before writing it - " + err.Error())
+	}
+
+	// write the bytes from the buffer into the wallets file, creating its
+	// directory if needed
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return errors.New("unable to create wallets directory - " + err.Error())
+	}
+	if err := s.rotateBackups(); err != nil {
+		return errors.New("unable to back up wallets file - " + err.Error())
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return errors.New("unable to write wallets file - " + err.Error())
+	}
+
+	return nil
+}
+
+// verify checks that data, the contents of the wallets file as stored,
+// decrypts with the key of the store to plain and decodes, so a file that
+// can't be read back never replaces the previous version.
+func (s *Store) verify(data, plain []byte) error {
+	if s.key != nil {
+		opened, err := s.key.open(data)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(opened, plain) {
+			return errors.New("decrypted contents differ from those encrypted")
+		}
+	}
+	_, err := decodeWallets(plain)
+	return err
+}
+
+// rotateBackups shifts the backups of the wallets file one version older,
+// dropping the oldest, and copies the file to the latest backup, doing
+// nothing if the store keeps no backups or the file does not exist yet.
+func (s *Store) rotateBackups() error {
+	if s.backups <= 0 {
+		return nil
+	}
+	current, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for n := s.backups - 1; n >= 1; n-- {
+		err := os.Rename(s.BackupPath(n), s.BackupPath(n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return writeFileAtomic(s.BackupPath(1), current)
+}
+
+// removeBackups removes the backups of the wallets file.
+func (s *Store) removeBackups() error {
+	for n := 1; n <= s.backups; n++ {
+		if err := os.Remove(s.BackupPath(n)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path with data readable only by its
+// owner, writing and syncing a temporary file next to it before renaming
+// it over the file, so a crash leaves either the old or the new contents
+// and never a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// sync the directory so the rename survives a crash
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Encrypted returns whether the wallets file is encrypted.
+func (s *Store) Encrypted() (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.encrypted()
+}
+
+// encrypted returns whether the wallets file is encrypted. The caller must
+// hold the lock of the store.
+func (s *Store) encrypted() (bool, error) {
+	fileBytes, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.New("unable to read wallets file - " + err.Error())
+	}
+	return isEncrypted(fileBytes), nil
+}
+
+// Encrypt encrypts the wallets file with a key derived from passphrase,
+// creating it if it does not exist, and keeps the key in the store. An
+// encrypted file must be unlocked first, and is encrypted with the new
+// passphrase. The backups of the file are removed, since they hold the
+// keys in the clear or under the old passphrase.
+func (s *Store) Encrypt(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("passphrase is empty")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	wallets, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.New("unable to generate salt - " + err.Error())
+	}
+	previous := s.key
+	s.key = deriveKey(passphrase, salt, keyIterations)
+	if err := s.save(wallets); err != nil {
+		s.key = previous
+		return err
+	}
+	if previous != nil {
+		previous.Wipe()
+	}
+
+	if err := s.removeBackups(); err != nil {
+		return errors.New("unable to remove backups of wallets file - " + err.Error())
+	}
+	return nil
+}
+
+// Unlock derives the key of the encrypted wallets file from passphrase and
+// keeps it in the store, returning it so it can be cached. It returns
+// ErrWrongPassphrase if the passphrase doesn't decrypt the file.
+func (s *Store) Unlock(passphrase string) (*Key, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fileBytes, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, errors.New("unable to read wallets file - " + err.Error())
+	}
+	if !isEncrypted(fileBytes) {
+		return nil, errors.New("wallets file is not encrypted")
+	}
+	file, err := decodeEncrypted(fileBytes)
+	if err != nil {
+		return nil, err
+	}
+	if file.Iterations < 1 {
+		return nil, errors.New("encrypted wallets file has an invalid iteration count")
+	}
+
+	key := deriveKey(passphrase, file.Salt, file.Iterations)
+	if _, err := key.open(fileBytes); err != nil {
+		key.Wipe()
+		return nil, err
+	}
+	s.key = key
+	return key, nil
+}
+
+// SetKey sets the key used to read and write an encrypted wallets file,
+// such as a key cached after Unlock.
+func (s *Store) SetKey(key *Key) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.key = key
+}
+
+// Lock wipes the key of the store.
+func (s *Store) Lock() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key != nil {
+		s.key.Wipe()
+		s.key = nil
 	}
 }
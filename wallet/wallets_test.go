@@ -0,0 +1,127 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestStoreConcurrentCreate(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "wallets.dat"))
+
+	// wallets created at the same time are all kept
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Create(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	addresses, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 10 || !sort.StringsAreSorted(addresses) {
+		t.Fatalf("got addresses %v, want 10 in order", addresses)
+	}
+}
+
+func TestStoreNotify(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "wallets.dat"))
+	changes := make(chan Change, 10)
+	store.Notify(changes)
+
+	w, err := store.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := w.Address().String()
+	if err := store.Delete(address); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(address); err == nil {
+		t.Fatal("deleted a missing wallet")
+	}
+	if _, err := store.Get(address); err == nil {
+		t.Fatal("got a deleted wallet")
+	}
+
+	for _, want := range []Change{{WalletAdded, address}, {WalletDeleted, address}} {
+		if got := <-changes; got != want {
+			t.Fatalf("got change %+v, want %+v", got, want)
+		}
+	}
+
+	// stopped channels get no more changes
+	store.Stop(changes)
+	if _, err := store.Create(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case change := <-changes:
+		t.Fatalf("got change %+v after Stop", change)
+	default:
+	}
+}
+
+func TestStoreBackups(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "wallets.dat"))
+	store.SetBackups(2)
+
+	// each write keeps the file it replaces, dropping the oldest backup
+	var created []string
+	for i := 0; i < 4; i++ {
+		w, err := store.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, w.Address().String())
+	}
+	for n, want := range map[int]int{1: 3, 2: 2} {
+		data, err := ioutil.ReadFile(store.BackupPath(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		wallets, err := decodeWallets(data)
+		if err != nil {
+			t.Fatalf("backup %d does not decode: %s", n, err)
+		}
+		if len(wallets) != want {
+			t.Fatalf("backup %d holds %d wallets, want %d", n, len(wallets), want)
+		}
+		if _, ok := wallets[created[want]]; ok {
+			t.Fatalf("backup %d holds the wallet created after it", n)
+		}
+	}
+	if _, err := os.Stat(store.BackupPath(3)); !os.IsNotExist(err) {
+		t.Fatalf("third backup kept with two allowed: %v", err)
+	}
+
+	// encrypting removes the backups holding the keys in the clear
+	if err := store.Encrypt("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	for n := 1; n <= 2; n++ {
+		if _, err := os.Stat(store.BackupPath(n)); !os.IsNotExist(err) {
+			t.Fatalf("backup %d of the plain file kept after encrypting: %v", n, err)
+		}
+	}
+	if _, err := store.Create(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(store.BackupPath(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(data) {
+		t.Fatal("backup of the encrypted file is in the clear")
+	}
+}
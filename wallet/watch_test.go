@@ -0,0 +1,52 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWatchOnly(t *testing.T) {
+	w := NewFromSeed([]byte("alice"))
+	if w.WatchOnly() {
+		t.Fatal("wallet with a private key is watch-only")
+	}
+
+	fromAddress, err := NewWatchOnly(w.Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromPubKey, err := NewWatchOnlyPublicKey(w.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, watched := range []*Wallet{fromAddress, fromPubKey} {
+		if !watched.WatchOnly() || !watched.Address().Equal(w.Address()) {
+			t.Errorf("got watch-only %v for %s, want watch-only for %s", watched.WatchOnly(), watched.Address(), w.Address())
+		}
+	}
+
+	// invalid addresses and points off the curve are refused
+	if _, err := NewWatchOnly("not an address"); err == nil {
+		t.Error("watch-only wallet created from an invalid address")
+	}
+	offCurve := append([]byte{}, w.PublicKey...)
+	offCurve[len(offCurve)-1] ^= 1
+	for _, pubKey := range [][]byte{nil, w.PublicKey[1:], offCurve} {
+		if _, err := NewWatchOnlyPublicKey(pubKey); err == nil {
+			t.Errorf("watch-only wallet created from public key %x", pubKey)
+		}
+	}
+
+	// watch-only wallets are kept in the wallets file
+	store := NewStore(filepath.Join(t.TempDir(), "wallets.dat"))
+	if err := store.Add(fromAddress); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.Get(w.Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.WatchOnly() || !loaded.Address().Equal(w.Address()) {
+		t.Fatalf("got wallet for %s, want watch-only wallet for %s", loaded.Address(), w.Address())
+	}
+}
@@ -0,0 +1,120 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/edwintcloud/gochain/addresses"
+)
+
+const (
+	// wifVersion is the version byte prefixed to private keys in
+	// wallet import format.
+	wifVersion = byte(0x80)
+
+	// privKeyLen is the length in bytes of a serialized private key.
+	privKeyLen = 32
+)
+
+// WIF returns the private key of the Wallet in wallet import format, a
+// base58 encoding of the version, private key, and checksum.
+func (w *Wallet) WIF() string {
+
+	// concatenate the version to the begining of the private key
+	vKey := append([]byte{wifVersion}, w.privateKeyBytes()...)
+
+	// concatenate the checksum to the end of vKey and encode
+	return base58.Encode(append(vKey, GenerateChecksum(vKey)...))
+}
+
+// DecodeWIF decodes a private key in wallet import format into a new Wallet.
+func DecodeWIF(wif string) (*Wallet, error) {
+
+	// decode wif from base58 and validate its layout
+	decoded := base58.Decode(wif)
+	if len(decoded) != 1+privKeyLen+addresses.ChecksumLength {
+		return nil, errors.New("invalid key length")
+	}
+	if decoded[0] != wifVersion {
+		return nil, errors.New("invalid key version")
+	}
+
+	// validate checksum
+	vKey := decoded[:1+privKeyLen]
+	if !bytes.Equal(decoded[1+privKeyLen:], GenerateChecksum(vKey)) {
+		return nil, errors.New("invalid key checksum")
+	}
+
+	// ensure the private key is a number from 1 to n-1 on the curve of the
+	// default scheme
+	curve := DefaultScheme.Curve()
+	if !validPrivateKey(curve, vKey[1:]) {
+		return nil, errors.New("private key out of range")
+	}
+
+	// rebuild the wallet from the private key
+	return fromPrivateKey(curve, vKey[1:]), nil
+}
+
+// privateKeyBytes returns the private key of the Wallet left padded to a
+// fixed length.
+func (w *Wallet) privateKeyBytes() []byte {
+	privKey := make([]byte, privKeyLen)
+	d := w.PrivateKey.D.Bytes()
+	copy(privKey[privKeyLen-len(d):], d)
+	return privKey
+}
+
+// validPrivateKey returns whether d is a private key on curve, a number
+// from 1 to n-1.
+func validPrivateKey(curve elliptic.Curve, d []byte) bool {
+	k := new(big.Int).SetBytes(d)
+	return k.Sign() > 0 && k.Cmp(curve.Params().N) < 0
+}
+
+// fromPrivateKey rebuilds the ecdsa key pair for a private key on curve
+// into a new Wallet.
+func fromPrivateKey(curve elliptic.Curve, d []byte) *Wallet {
+	privKey := ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	privKey.PublicKey.Curve = curve
+	privKey.PublicKey.X, privKey.PublicKey.Y = curve.ScalarBaseMult(d)
+
+	// concatenate ecdsa pubKey x and y to make a public key
+	pubKey := append(privKey.PublicKey.X.Bytes(), privKey.PublicKey.Y.Bytes()...)
+
+	// return new wallet
+	return &Wallet{
+		PrivateKey: privKey,
+		PublicKey:  pubKey,
+	}
+}
+
+// ExportKey returns the private key of the wallet for an address in the
+// store in wallet import format.
+func (s *Store) ExportKey(address string) (string, error) {
+	w, err := s.Get(address)
+	if err != nil {
+		return "", err
+	}
+	if w.WatchOnly() {
+		return "", ErrWatchOnly
+	}
+	return w.WIF(), nil
+}
+
+// ImportKey decodes a private key in wallet import format and adds its
+// wallet to the store, replacing a watch-only wallet for its address.
+func (s *Store) ImportKey(wif string) (*Wallet, error) {
+	w, err := DecodeWIF(wif)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Add(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
@@ -0,0 +1,83 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+// encodeWIF encodes a version and private key in wallet import format.
+func encodeWIF(version byte, privKey []byte) string {
+	vKey := append([]byte{version}, privKey...)
+	return base58.Encode(append(vKey, GenerateChecksum(vKey)...))
+}
+
+func TestWIFRoundTrip(t *testing.T) {
+	for _, w := range []*Wallet{CreateWallet(), NewFromSeed([]byte("alice"))} {
+		decoded, err := DecodeWIF(w.WIF())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Address().Equal(w.Address()) {
+			t.Fatalf("got address %s, want %s", decoded.Address(), w.Address())
+		}
+	}
+}
+
+func TestDecodeWIFRejectsInvalidKeys(t *testing.T) {
+	n := elliptic.P256().Params().N
+	valid := NewFromSeed([]byte("alice")).WIF()
+	badChecksum := base58.Decode(valid)
+	badChecksum[len(badChecksum)-1] ^= 0xff
+
+	for _, c := range []struct {
+		name string
+		wif  string
+		want string
+	}{
+		{"too short", encodeWIF(wifVersion, make([]byte, privKeyLen-1)), "length"},
+		{"wrong version", encodeWIF(0x81, bytes.Repeat([]byte{1}, privKeyLen)), "version"},
+		{"bad checksum", base58.Encode(badChecksum), "checksum"},
+		{"zero", encodeWIF(wifVersion, make([]byte, privKeyLen)), "out of range"},
+		{"curve order", encodeWIF(wifVersion, n.Bytes()), "out of range"},
+		{"above curve order", encodeWIF(wifVersion, new(big.Int).Add(n, big.NewInt(1)).Bytes()), "out of range"},
+		{"all ones", encodeWIF(wifVersion, bytes.Repeat([]byte{0xff}, privKeyLen)), "out of range"},
+	} {
+		if _, err := DecodeWIF(c.wif); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %v, want %q", c.name, err, c.want)
+		}
+	}
+
+	// the largest valid key decodes
+	max := new(big.Int).Sub(n, big.NewInt(1))
+	if _, err := DecodeWIF(encodeWIF(wifVersion, max.Bytes())); err != nil {
+		t.Fatalf("n-1 was rejected: %s", err)
+	}
+}
+
+func TestExportKey(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "wallets.dat"))
+	alice := NewFromSeed([]byte("alice"))
+	watched, err := NewWatchOnly(alice.Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(watched); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.ExportKey(alice.Address().String()); err != ErrWatchOnly {
+		t.Fatalf("got error %v, want ErrWatchOnly", err)
+	}
+	if _, err := store.ExportKey(NewFromSeed([]byte("bob")).Address().String()); err == nil {
+		t.Fatal("exported the key of a wallet that is not in the store")
+	}
+	if _, err := store.ImportKey("not a key"); err == nil {
+		t.Fatal("imported an invalid key")
+	}
+}